@@ -0,0 +1,147 @@
+// Package adminevent posts summaries of significant administrative
+// events to outbound integrations such as Slack-compatible webhooks
+// and email.
+package adminevent
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// TypeSetDeclarationRemoved is the type of the event of a declaration
+// being removed from a large set.
+const TypeSetDeclarationRemoved = "set.declaration.removed"
+
+// DefaultLargeSet is the default minimum number of enrollments of a
+// large set.
+const DefaultLargeSet = 100
+
+// sendTimeout limits how long sending an event to a sender may take.
+const sendTimeout = 30 * time.Second
+
+// Event is a significant administrative event.
+type Event struct {
+	Type      string    `json:"type"`
+	Summary   string    `json:"summary"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Sender sends events to an outbound integration.
+type Sender interface {
+	Send(ctx context.Context, e *Event) error
+}
+
+// route sends events of types (or all events if empty) to sender.
+type route struct {
+	sender Sender
+	types  map[string]struct{}
+}
+
+// Dispatcher sends events to the senders that are subscribed to them.
+// Events are sent in the background so that callers are not delayed
+// by slow integrations; errors are logged.
+// Methods may be called on a nil Dispatcher, which does nothing.
+type Dispatcher struct {
+	routes   []route
+	store    storage.EnrollmentIDRetriever
+	largeSet int
+	logger   log.Logger
+}
+
+type Option func(*Dispatcher)
+
+func WithLogger(logger log.Logger) Option {
+	return func(d *Dispatcher) {
+		d.logger = logger
+	}
+}
+
+// WithLargeSet sets the minimum number of enrollments of a large set.
+func WithLargeSet(n int) Option {
+	return func(d *Dispatcher) {
+		d.largeSet = n
+	}
+}
+
+// WithSender sends events of types to sender.
+// If no types are given then all events are sent to sender.
+func WithSender(sender Sender, types ...string) Option {
+	return func(d *Dispatcher) {
+		r := route{sender: sender}
+		if len(types) > 0 {
+			r.types = make(map[string]struct{})
+			for _, t := range types {
+				r.types[t] = struct{}{}
+			}
+		}
+		d.routes = append(d.routes, r)
+	}
+}
+
+// New creates a new Dispatcher. Store is used to count the enrollments of sets.
+func New(store storage.EnrollmentIDRetriever, opts ...Option) *Dispatcher {
+	if store == nil {
+		panic("nil store")
+	}
+	d := &Dispatcher{
+		store:    store,
+		largeSet: DefaultLargeSet,
+		logger:   log.NopLogger,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Dispatch sends e to the subscribed senders in the background.
+func (d *Dispatcher) Dispatch(ctx context.Context, e *Event) {
+	if d == nil {
+		return
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	logger := ctxlog.Logger(ctx, d.logger).With("event", e.Type)
+	for _, r := range d.routes {
+		if r.types != nil {
+			if _, ok := r.types[e.Type]; !ok {
+				continue
+			}
+		}
+		go func(sender Sender) {
+			// the request context may be cancelled before we're done
+			ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+			defer cancel()
+			if err := sender.Send(ctx, e); err != nil {
+				logger.Info(logkeys.Message, "sending admin event", logkeys.Error, err)
+			}
+		}(r.sender)
+	}
+}
+
+// SetDeclarationRemoved dispatches an event if setName, from which
+// declarationID was removed, is a large set.
+func (d *Dispatcher) SetDeclarationRemoved(ctx context.Context, setName, declarationID string) {
+	if d == nil || len(d.routes) < 1 {
+		return
+	}
+	ids, err := d.store.RetrieveEnrollmentIDs(ctx, nil, []string{setName}, nil)
+	if err != nil {
+		ctxlog.Logger(ctx, d.logger).Info(logkeys.Message, "retrieving set enrollments", logkeys.Error, err)
+		return
+	}
+	if len(ids) < d.largeSet {
+		return
+	}
+	d.Dispatch(ctx, &Event{
+		Type:    TypeSetDeclarationRemoved,
+		Summary: fmt.Sprintf("Declaration %q was removed from set %q with %d enrollments.", declarationID, setName, len(ids)),
+	})
+}
@@ -0,0 +1,156 @@
+package adminevent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSender chan *Event
+
+func (s testSender) Send(_ context.Context, e *Event) error {
+	s <- e
+	return nil
+}
+
+type testStore struct {
+	ids []string
+}
+
+func (s *testStore) RetrieveEnrollmentIDs(_ context.Context, _ []string, _ []string, _ []string) ([]string, error) {
+	return s.ids, nil
+}
+
+func receive(t *testing.T, s testSender) *Event {
+	t.Helper()
+	select {
+	case e := <-s:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for event")
+	}
+	return nil
+}
+
+func TestDispatcher(t *testing.T) {
+	all := make(testSender, 1)
+	other := make(testSender, 1)
+	store := &testStore{ids: []string{"a", "b"}}
+	d := New(store, WithLargeSet(2), WithSender(all), WithSender(other, "other.event"))
+
+	d.SetDeclarationRemoved(context.Background(), "set1", "decl1")
+	e := receive(t, all)
+	if have, want := e.Type, TypeSetDeclarationRemoved; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if !strings.Contains(e.Summary, "set1") || !strings.Contains(e.Summary, "decl1") {
+		t.Errorf("summary missing set or declaration: %s", e.Summary)
+	}
+	if e.Timestamp.IsZero() {
+		t.Error("zero timestamp")
+	}
+	select {
+	case <-other:
+		t.Error("event sent to sender not subscribed to it")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// not a large set
+	store.ids = []string{"a"}
+	d.SetDeclarationRemoved(context.Background(), "set1", "decl1")
+	select {
+	case <-all:
+		t.Error("event sent for small set")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// nil dispatchers do nothing
+	var nilD *Dispatcher
+	nilD.SetDeclarationRemoved(context.Background(), "set1", "decl1")
+	nilD.Dispatch(context.Background(), &Event{})
+}
+
+func TestSlackWebhook(t *testing.T) {
+	var text string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/404" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		text = body.Text
+	}))
+	defer srv.Close()
+
+	s := &SlackWebhook{URL: srv.URL}
+	err := s.Send(context.Background(), &Event{Type: "test.event", Summary: "hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := text, "[kmfddm] test.event: hello"; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	s.URL = srv.URL + "/404"
+	if err = s.Send(context.Background(), &Event{}); err == nil {
+		t.Error("expected error for HTTP status")
+	}
+}
+
+func TestSMTPMessage(t *testing.T) {
+	s := &SMTP{From: "kmfddm@example.com", To: []string{"a@example.com", "b@example.com"}}
+	msg := string(s.message(&Event{Type: "test.event", Summary: "hello", Timestamp: time.Now()}))
+	for _, want := range []string{
+		"From: kmfddm@example.com\r\n",
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: [kmfddm] test.event\r\n",
+		"\r\n\r\nhello\r\n",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q: %q", want, msg)
+		}
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`{
+		"large_set": 10,
+		"slack": [{"url": "https://hooks.example.com/x", "events": ["set.declaration.removed"]}],
+		"smtp": [{"addr": "smtp.example.com:587", "from": "kmfddm@example.com", "to": ["ops@example.com"]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts, err := c.Options()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := New(&testStore{}, opts...)
+	if have, want := d.largeSet, 10; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := len(d.routes), 2; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	c, err = ReadConfig(strings.NewReader(`{"smtp": [{"addr": "smtp.example.com:587"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = c.Options(); err == nil {
+		t.Error("expected error for incomplete smtp config")
+	}
+
+	if _, err = ReadConfig(strings.NewReader(`{"unknown": true}`)); err == nil {
+		t.Error("expected error for unknown field")
+	}
+}
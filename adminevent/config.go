@@ -0,0 +1,80 @@
+package adminevent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Config configures the outbound integrations of a Dispatcher.
+// The events of each integration are the event types it is sent; all
+// events are sent if empty.
+type Config struct {
+	// LargeSet is the minimum number of enrollments of a large set.
+	// DefaultLargeSet is used if zero.
+	LargeSet int `json:"large_set,omitempty"`
+
+	Slack []struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events,omitempty"`
+	} `json:"slack,omitempty"`
+
+	SMTP []struct {
+		Addr     string   `json:"addr"`
+		From     string   `json:"from"`
+		To       []string `json:"to"`
+		Username string   `json:"username,omitempty"`
+		Password string   `json:"password,omitempty"`
+		Events   []string `json:"events,omitempty"`
+	} `json:"smtp,omitempty"`
+}
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding admin event config: %w", err)
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Options returns the Dispatcher options configured by c.
+func (c *Config) Options() ([]Option, error) {
+	var opts []Option
+	if c.LargeSet > 0 {
+		opts = append(opts, WithLargeSet(c.LargeSet))
+	}
+	for i, s := range c.Slack {
+		if s.URL == "" {
+			return nil, fmt.Errorf("slack %d: empty URL", i)
+		}
+		opts = append(opts, WithSender(&SlackWebhook{URL: s.URL}, s.Events...))
+	}
+	for i, s := range c.SMTP {
+		if s.Addr == "" || s.From == "" || len(s.To) < 1 {
+			return nil, fmt.Errorf("smtp %d: %w", i, errors.New("addr, from, and to are required"))
+		}
+		opts = append(opts, WithSender(&SMTP{
+			Addr:     s.Addr,
+			From:     s.From,
+			To:       s.To,
+			Username: s.Username,
+			Password: s.Password,
+		}, s.Events...))
+	}
+	return opts, nil
+}
@@ -0,0 +1,43 @@
+package adminevent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SlackWebhook sends events to a Slack-compatible incoming webhook.
+type SlackWebhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the summary of e to the webhook.
+func (s *SlackWebhook) Send(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(map[string]string{
+		"text": fmt.Sprintf("[kmfddm] %s: %s", e.Type, e.Summary),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected HTTP status: %s", resp.Status)
+	}
+	return nil
+}
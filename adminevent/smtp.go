@@ -0,0 +1,55 @@
+package adminevent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTP sends events as email.
+type SMTP struct {
+	// Addr is the host and port of the SMTP server.
+	Addr string
+	From string
+	To   []string
+
+	// Username and Password are used for PLAIN authentication if set.
+	Username string
+	Password string
+}
+
+// message formats e as an email message.
+func (s *SMTP) message(e *Event) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "From: %s\r\n", s.From)
+	fmt.Fprintf(buf, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(buf, "Subject: [kmfddm] %s\r\n", e.Type)
+	fmt.Fprintf(buf, "Date: %s\r\n", e.Timestamp.Format(time.RFC1123Z))
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(e.Summary)
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
+
+// Send emails the summary of e.
+// The context is not used as the standard library SMTP client does not support it.
+func (s *SMTP) Send(_ context.Context, e *Event) error {
+	if len(s.To) < 1 {
+		return errors.New("smtp: no recipients")
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		host, _, err := net.SplitHostPort(s.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	return smtp.SendMail(s.Addr, auth, s.From, s.To, s.message(e))
+}
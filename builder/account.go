@@ -0,0 +1,68 @@
+package builder
+
+import "errors"
+
+// DAVAccount are the fields shared by CalDAV and CardDAV accounts.
+// The asset references are the identifiers of asset declarations.
+type DAVAccount struct {
+	VisibleName                             string `json:"VisibleName,omitempty"`
+	HostName                                string `json:"HostName"`
+	Port                                    *int   `json:"Port,omitempty"`
+	Path                                    string `json:"Path,omitempty"`
+	UserIdentityAssetReference              string `json:"UserIdentityAssetReference,omitempty"`
+	AuthenticationCredentialsAssetReference string `json:"AuthenticationCredentialsAssetReference,omitempty"`
+}
+
+func (a *DAVAccount) Validate() error {
+	if a.HostName == "" {
+		return errors.New("missing HostName")
+	}
+	return checkRange("Port", a.Port, 1, 65535)
+}
+
+// CalDAVAccount is the payload of a CalDAV account configuration.
+type CalDAVAccount struct {
+	DAVAccount
+}
+
+func (a *CalDAVAccount) DeclarationType() string {
+	return "com.apple.configuration.account.caldav"
+}
+
+// CardDAVAccount is the payload of a CardDAV account configuration.
+type CardDAVAccount struct {
+	DAVAccount
+}
+
+func (a *CardDAVAccount) DeclarationType() string {
+	return "com.apple.configuration.account.carddav"
+}
+
+// GoogleAccount is the payload of a Google account configuration.
+type GoogleAccount struct {
+	VisibleName                string `json:"VisibleName,omitempty"`
+	UserIdentityAssetReference string `json:"UserIdentityAssetReference,omitempty"`
+}
+
+func (a *GoogleAccount) DeclarationType() string {
+	return "com.apple.configuration.account.google"
+}
+
+func (a *GoogleAccount) Validate() error {
+	return nil
+}
+
+// SubscribedCalendarAccount is the payload of a subscribed calendar account configuration.
+type SubscribedCalendarAccount struct {
+	VisibleName                             string `json:"VisibleName,omitempty"`
+	CalendarURL                             string `json:"CalendarURL"`
+	AuthenticationCredentialsAssetReference string `json:"AuthenticationCredentialsAssetReference,omitempty"`
+}
+
+func (a *SubscribedCalendarAccount) DeclarationType() string {
+	return "com.apple.configuration.account.subscribed-calendar"
+}
+
+func (a *SubscribedCalendarAccount) Validate() error {
+	return checkURL("CalendarURL", a.CalendarURL)
+}
@@ -0,0 +1,93 @@
+// Package builder builds common configuration declarations from typed
+// and validated Go payloads.
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// ErrMissingIdentifier is returned when building a declaration without an identifier.
+var ErrMissingIdentifier = errors.New("missing declaration identifier")
+
+// Payload is the payload of a configuration declaration.
+type Payload interface {
+	// DeclarationType returns the declaration type of the payload
+	// (e.g. "com.apple.configuration.passcode.settings").
+	DeclarationType() string
+
+	// Validate checks the payload fields.
+	Validate() error
+}
+
+// declaration is the JSON form of a declaration.
+type declaration struct {
+	Type       string
+	Identifier string
+	Payload    Payload
+}
+
+// Build validates p and builds a declaration with identifier and p as its payload.
+func Build(identifier string, p Payload) (*ddm.Declaration, error) {
+	if identifier == "" {
+		return nil, ErrMissingIdentifier
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", p.DeclarationType(), err)
+	}
+	raw, err := json.Marshal(&declaration{
+		Type:       p.DeclarationType(),
+		Identifier: identifier,
+		Payload:    p,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal declaration: %w", err)
+	}
+	return ddm.ParseDeclaration(raw)
+}
+
+// payloads are the constructors of the payloads by builder name.
+var payloads = map[string]func() Payload{
+	"passcode":            func() Payload { return new(Passcode) },
+	"legacy":              func() Payload { return new(LegacyProfile) },
+	"legacy-interactive":  func() Payload { return new(LegacyInteractiveProfile) },
+	"caldav":              func() Payload { return new(CalDAVAccount) },
+	"carddav":             func() Payload { return new(CardDAVAccount) },
+	"google":              func() Payload { return new(GoogleAccount) },
+	"subscribed-calendar": func() Payload { return new(SubscribedCalendarAccount) },
+	"credential-cert":     func() Payload { return new(CredentialCertificate) },
+	"credential-identity": func() Payload { return new(CredentialIdentity) },
+	"credential-scep":     func() Payload { return new(CredentialSCEP) },
+	"credential-acme":     func() Payload { return new(CredentialACME) },
+}
+
+// New returns a new empty payload for the builder name.
+func New(name string) (Payload, bool) {
+	newPayload, ok := payloads[name]
+	if !ok {
+		return nil, false
+	}
+	return newPayload(), true
+}
+
+// Names returns the sorted builder names.
+func Names() []string {
+	names := make([]string, 0, len(payloads))
+	for name := range payloads {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkRange returns an error if the optional value v is outside of min to max.
+func checkRange(name string, v *int, min, max int) error {
+	if v != nil && (*v < min || *v > max) {
+		return fmt.Errorf("%s out of range %d to %d: %d", name, min, max, *v)
+	}
+	return nil
+}
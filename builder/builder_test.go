@@ -0,0 +1,146 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int    { return &i }
+func boolPtr(b bool) *bool { return &b }
+
+func TestBuildPasscode(t *testing.T) {
+	d, err := Build("test_golang_passcode", &Passcode{
+		RequirePasscode: boolPtr(true),
+		MinimumLength:   intPtr(8),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := d.Type, "com.apple.configuration.passcode.settings"; have != want {
+		t.Errorf("type: have: %v, want: %v", have, want)
+	}
+	var payload map[string]interface{}
+	if err = json.Unmarshal(d.PayloadJSON, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 2 || payload["RequirePasscode"] != true || payload["MinimumLength"] != 8.0 {
+		t.Errorf("unexpected payload: %s", d.PayloadJSON)
+	}
+
+	if _, err = Build("test_golang_passcode", &Passcode{MaximumFailedAttempts: intPtr(1)}); err == nil {
+		t.Error("expected error for out of range value")
+	}
+	if _, err = Build("test_golang_passcode", &Passcode{CustomRegex: &PasscodeCustomRegex{Regex: "("}}); err == nil {
+		t.Error("expected error for invalid regex")
+	}
+	if _, err = Build("", &Passcode{}); !errors.Is(err, ErrMissingIdentifier) {
+		t.Errorf("expected missing identifier error, got: %v", err)
+	}
+}
+
+func TestBuildAccountRefs(t *testing.T) {
+	d, err := Build("test_golang_caldav", &CalDAVAccount{DAVAccount{
+		HostName:                                "caldav.example.com",
+		AuthenticationCredentialsAssetReference: "test_golang_creds",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.IdentifierRefs) != 1 || d.IdentifierRefs[0] != "test_golang_creds" {
+		t.Errorf("identifier refs: %v", d.IdentifierRefs)
+	}
+	if _, err = Build("test_golang_caldav", &CalDAVAccount{DAVAccount{HostName: "caldav.example.com", Port: intPtr(0)}}); err == nil {
+		t.Error("expected error for invalid port")
+	}
+}
+
+func TestBuilders(t *testing.T) {
+	for _, name := range Names() {
+		p, ok := New(name)
+		if !ok {
+			t.Fatalf("builder not found: %s", name)
+		}
+		if !strings.HasPrefix(p.DeclarationType(), "com.apple.configuration.") && !strings.HasPrefix(p.DeclarationType(), "com.apple.asset.") {
+			t.Errorf("%s: not a configuration or asset type: %s", name, p.DeclarationType())
+		}
+	}
+	for _, url := range []string{"", "/relative", "ftp://example.com/p"} {
+		if err := (&LegacyProfile{ProfileURL: url}).Validate(); err == nil {
+			t.Errorf("expected error for profile URL: %q", url)
+		}
+	}
+	if _, ok := New("nonexistent"); ok {
+		t.Error("found nonexistent builder")
+	}
+}
+
+func TestRewriteAssetReference(t *testing.T) {
+	d, err := Build("test_golang_cert", &CredentialCertificate{Reference: AssetReference{
+		DataURL:     "https://example.com/credential/old",
+		ContentType: CertificateContentType,
+		HashSHA256:  "old",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := AssetReference{DataURL: "https://example.com/credential/new", Size: 3, HashSHA256: "new"}
+	if d2, err := RewriteAssetReference(d, "https://example.com/credential/other", ref); err != nil || d2 != nil {
+		t.Errorf("expected no rewrite: %v, %v", d2, err)
+	}
+	d2, err := RewriteAssetReference(d, "https://example.com/credential/old", ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(CredentialCertificate)
+	if err = json.Unmarshal(d2.PayloadJSON, p); err != nil {
+		t.Fatal(err)
+	}
+	// Size was not present so it is not added
+	want := AssetReference{DataURL: ref.DataURL, ContentType: CertificateContentType, HashSHA256: "new"}
+	if p.Reference != want {
+		t.Errorf("have: %v, want: %v", p.Reference, want)
+	}
+	if d2.Identifier != d.Identifier || d2.Type != d.Type {
+		t.Errorf("declaration changed: %s %s", d2.Identifier, d2.Type)
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	const profileFmt = `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>PayloadDisplayName</key>
+	<string>Test Profile</string>
+	<key>PayloadIdentifier</key>
+	<string>com.example.profile</string>
+	<key>PayloadType</key>
+	<string>%s</string>
+</dict>
+</plist>`
+	p, err := ParseProfile([]byte(fmt.Sprintf(profileFmt, "Configuration")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.PayloadIdentifier != "com.example.profile" || p.PayloadDisplayName != "Test Profile" || p.Signed {
+		t.Errorf("unexpected profile: %+v", p)
+	}
+
+	if _, err = ParseProfile([]byte(fmt.Sprintf(profileFmt, "com.apple.wifi.managed"))); !errors.Is(err, ErrInvalidProfile) {
+		t.Errorf("expected invalid profile error, got: %v", err)
+	}
+
+	if p, err = ParseProfile([]byte{0x30, 0x82, 0x01}); err != nil || !p.Signed {
+		t.Errorf("expected signed profile: %+v, %v", p, err)
+	}
+
+	d, err := Build("test_golang_legacy", &LegacyProfile{ProfileURL: ProfileDataURL([]byte("profile"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Type != "com.apple.configuration.legacy" {
+		t.Errorf("unexpected type: %s", d.Type)
+	}
+}
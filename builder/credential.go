@@ -0,0 +1,139 @@
+package builder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// Credential asset content types.
+const (
+	CertificateContentType    = "application/pkix-cert"
+	PEMCertificateContentType = "application/x-pem-file"
+	PKCS12ContentType         = "application/pkcs12"
+
+	// JSONContentType is the content type of SCEP and ACME credential data.
+	JSONContentType = "application/json"
+)
+
+// AssetReference references the data of an asset declaration.
+type AssetReference struct {
+	DataURL     string `json:"DataURL"`
+	ContentType string `json:"ContentType,omitempty"`
+	Size        int    `json:"Size,omitempty"`
+	HashSHA256  string `json:"Hash-SHA-256,omitempty"`
+}
+
+func (r *AssetReference) validate(contentTypes ...string) error {
+	if r.DataURL == "" {
+		return errors.New("missing Reference DataURL")
+	}
+	for _, contentType := range contentTypes {
+		if r.ContentType == contentType {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid Reference ContentType: %q", r.ContentType)
+}
+
+// CredentialCertificate is the payload of a certificate credential asset.
+type CredentialCertificate struct {
+	Reference AssetReference `json:"Reference"`
+}
+
+func (a *CredentialCertificate) DeclarationType() string {
+	return "com.apple.asset.credential.certificate"
+}
+
+func (a *CredentialCertificate) Validate() error {
+	return a.Reference.validate(CertificateContentType, PEMCertificateContentType)
+}
+
+// CredentialIdentity is the payload of an identity (PKCS #12) credential asset.
+type CredentialIdentity struct {
+	Reference AssetReference `json:"Reference"`
+}
+
+func (a *CredentialIdentity) DeclarationType() string {
+	return "com.apple.asset.credential.identity"
+}
+
+func (a *CredentialIdentity) Validate() error {
+	return a.Reference.validate(PKCS12ContentType)
+}
+
+// CredentialSCEP is the payload of a SCEP credential asset.
+// The referenced data is a JSON object of the SCEP request.
+type CredentialSCEP struct {
+	Reference AssetReference `json:"Reference"`
+}
+
+func (a *CredentialSCEP) DeclarationType() string {
+	return "com.apple.asset.credential.scep"
+}
+
+func (a *CredentialSCEP) Validate() error {
+	return a.Reference.validate(JSONContentType)
+}
+
+// CredentialACME is the payload of an ACME credential asset.
+// The referenced data is a JSON object of the ACME request.
+type CredentialACME struct {
+	Reference AssetReference `json:"Reference"`
+}
+
+func (a *CredentialACME) DeclarationType() string {
+	return "com.apple.asset.credential.acme"
+}
+
+func (a *CredentialACME) Validate() error {
+	return a.Reference.validate(JSONContentType)
+}
+
+// RewriteAssetReference returns d with the asset Reference DataURL of
+// its payload changed from oldURL to ref.DataURL. The Size and
+// Hash-SHA-256 of the Reference are updated from ref if d has them.
+// Returns nil if d does not reference oldURL.
+func RewriteAssetReference(d *ddm.Declaration, oldURL string, ref AssetReference) (*ddm.Declaration, error) {
+	var decl map[string]json.RawMessage
+	if err := json.Unmarshal(d.Raw, &decl); err != nil {
+		return nil, fmt.Errorf("unmarshal declaration: %w", err)
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(d.PayloadJSON, &payload); err != nil {
+		return nil, fmt.Errorf("unmarshal payload: %w", err)
+	}
+	var reference map[string]interface{}
+	if raw, ok := payload["Reference"]; !ok {
+		return nil, nil
+	} else if err := json.Unmarshal(raw, &reference); err != nil {
+		// not an asset reference object
+		return nil, nil
+	}
+	if dataURL, _ := reference["DataURL"].(string); dataURL != oldURL {
+		return nil, nil
+	}
+	reference["DataURL"] = ref.DataURL
+	if _, ok := reference["Size"]; ok {
+		reference["Size"] = ref.Size
+	}
+	if _, ok := reference["Hash-SHA-256"]; ok {
+		reference["Hash-SHA-256"] = ref.HashSHA256
+	}
+	var err error
+	if payload["Reference"], err = json.Marshal(reference); err != nil {
+		return nil, fmt.Errorf("marshal reference: %w", err)
+	}
+	if decl["Payload"], err = json.Marshal(payload); err != nil {
+		return nil, fmt.Errorf("marshal payload: %w", err)
+	}
+	// the new server token is computed when stored
+	delete(decl, "ServerToken")
+	raw, err := json.Marshal(decl)
+	if err != nil {
+		return nil, fmt.Errorf("marshal declaration: %w", err)
+	}
+	return ddm.ParseDeclaration(raw)
+}
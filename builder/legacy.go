@@ -0,0 +1,67 @@
+package builder
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// checkURL returns an error if s is not an absolute HTTP(S) URL.
+func checkURL(name, s string) error {
+	if s == "" {
+		return fmt.Errorf("missing %s", name)
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %w", name, err)
+	}
+	if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("%s must be an absolute HTTP(S) URL: %s", name, s)
+	}
+	return nil
+}
+
+// checkProfileURL returns an error if s is neither an absolute HTTP(S)
+// URL nor a base64 data URL (see ProfileDataURL).
+func checkProfileURL(s string) error {
+	if strings.HasPrefix(s, "data:") {
+		if !strings.Contains(s, ";base64,") {
+			return errors.New("ProfileURL data URL must be base64 encoded")
+		}
+		return nil
+	}
+	return checkURL("ProfileURL", s)
+}
+
+// LegacyProfile is the payload of a legacy profile configuration which
+// installs the configuration profile at ProfileURL.
+type LegacyProfile struct {
+	ProfileURL string `json:"ProfileURL"`
+}
+
+func (p *LegacyProfile) DeclarationType() string {
+	return "com.apple.configuration.legacy"
+}
+
+func (p *LegacyProfile) Validate() error {
+	return checkProfileURL(p.ProfileURL)
+}
+
+// LegacyInteractiveProfile is the payload of an interactive legacy
+// profile configuration. The user installs the profile at ProfileURL.
+type LegacyInteractiveProfile struct {
+	ProfileURL  string `json:"ProfileURL"`
+	VisibleName string `json:"VisibleName"`
+}
+
+func (p *LegacyInteractiveProfile) DeclarationType() string {
+	return "com.apple.configuration.legacy.interactive"
+}
+
+func (p *LegacyInteractiveProfile) Validate() error {
+	if p.VisibleName == "" {
+		return errors.New("missing VisibleName")
+	}
+	return checkProfileURL(p.ProfileURL)
+}
@@ -0,0 +1,65 @@
+package builder
+
+import (
+	"errors"
+	"regexp"
+)
+
+// PasscodeCustomRegex is a regular expression passcodes must match.
+type PasscodeCustomRegex struct {
+	Regex string `json:"Regex"`
+
+	// Description is keyed by language code (e.g. "en" or "default").
+	Description map[string]string `json:"Description,omitempty"`
+}
+
+// Passcode is the payload of a passcode settings configuration.
+// Unset (nil) fields are omitted from the declaration.
+type Passcode struct {
+	RequirePasscode             *bool                `json:"RequirePasscode,omitempty"`
+	RequireAlphanumericPasscode *bool                `json:"RequireAlphanumericPasscode,omitempty"`
+	RequireComplexPasscode      *bool                `json:"RequireComplexPasscode,omitempty"`
+	MinimumLength               *int                 `json:"MinimumLength,omitempty"`
+	MinimumComplexCharacters    *int                 `json:"MinimumComplexCharacters,omitempty"`
+	MaximumFailedAttempts       *int                 `json:"MaximumFailedAttempts,omitempty"`
+	MaximumGracePeriodInMinutes *int                 `json:"MaximumGracePeriodInMinutes,omitempty"`
+	MaximumInactivityInMinutes  *int                 `json:"MaximumInactivityInMinutes,omitempty"`
+	MaximumPasscodeAgeInDays    *int                 `json:"MaximumPasscodeAgeInDays,omitempty"`
+	PasscodeReuseLimit          *int                 `json:"PasscodeReuseLimit,omitempty"`
+	ChangeAtNextAuth            *bool                `json:"ChangeAtNextAuth,omitempty"`
+	CustomRegex                 *PasscodeCustomRegex `json:"CustomRegex,omitempty"`
+}
+
+func (p *Passcode) DeclarationType() string {
+	return "com.apple.configuration.passcode.settings"
+}
+
+// Validate checks the ranges of the passcode settings.
+func (p *Passcode) Validate() error {
+	for _, r := range []struct {
+		name     string
+		v        *int
+		min, max int
+	}{
+		{"MinimumLength", p.MinimumLength, 0, 16},
+		{"MinimumComplexCharacters", p.MinimumComplexCharacters, 0, 4},
+		{"MaximumFailedAttempts", p.MaximumFailedAttempts, 2, 11},
+		{"MaximumGracePeriodInMinutes", p.MaximumGracePeriodInMinutes, 0, 1 << 20},
+		{"MaximumInactivityInMinutes", p.MaximumInactivityInMinutes, 0, 15},
+		{"MaximumPasscodeAgeInDays", p.MaximumPasscodeAgeInDays, 0, 730},
+		{"PasscodeReuseLimit", p.PasscodeReuseLimit, 1, 50},
+	} {
+		if err := checkRange(r.name, r.v, r.min, r.max); err != nil {
+			return err
+		}
+	}
+	if p.CustomRegex != nil {
+		if p.CustomRegex.Regex == "" {
+			return errors.New("empty CustomRegex Regex")
+		}
+		if _, err := regexp.Compile(p.CustomRegex.Regex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
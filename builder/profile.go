@@ -0,0 +1,56 @@
+package builder
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/groob/plist"
+)
+
+// ProfileContentType is the MIME type of configuration profiles.
+const ProfileContentType = "application/x-apple-aspen-config"
+
+// ErrInvalidProfile is returned when a configuration profile can not be parsed.
+var ErrInvalidProfile = errors.New("invalid configuration profile")
+
+// Profile is the top-level information of a configuration profile.
+type Profile struct {
+	PayloadType        string
+	PayloadIdentifier  string
+	PayloadDisplayName string
+
+	// Signed is true for signed (CMS) profiles. The other fields are
+	// not populated for signed profiles.
+	Signed bool `plist:"-"`
+}
+
+// ParseProfile parses the top-level information of the configuration
+// profile in raw. Both XML and binary property list profiles are
+// parsed. Signed profiles are detected but not parsed.
+func ParseProfile(raw []byte) (*Profile, error) {
+	if len(raw) < 1 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidProfile)
+	}
+	if raw[0] == 0x30 {
+		// DER-encoded ASN.1 SEQUENCE: assume a CMS signed profile
+		return &Profile{Signed: true}, nil
+	}
+	p := new(Profile)
+	if err := plist.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+	}
+	if p.PayloadType != "Configuration" {
+		return nil, fmt.Errorf("%w: payload type: %q", ErrInvalidProfile, p.PayloadType)
+	}
+	if p.PayloadIdentifier == "" {
+		return nil, fmt.Errorf("%w: missing payload identifier", ErrInvalidProfile)
+	}
+	return p, nil
+}
+
+// ProfileDataURL returns raw as a base64 data URL for embedding the
+// profile in a legacy profile declaration.
+func ProfileDataURL(raw []byte) string {
+	return "data:" + ProfileContentType + ";base64," + base64.StdEncoding.EncodeToString(raw)
+}
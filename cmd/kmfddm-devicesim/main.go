@@ -0,0 +1,104 @@
+// Command kmfddm-devicesim simulates DDM-capable devices against a running KMFDDM server.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/internal/devicesim"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/log/stdlogfmt"
+)
+
+// overridden by -ldflags -X
+var version = "unknown"
+
+func main() {
+	var (
+		flDebug      = flag.Bool("debug", false, "log debug messages")
+		flVersion    = flag.Bool("version", false, "print version")
+		flURL        = flag.String("url", "http://localhost:9002", "base URL of KMFDDM server DDM endpoints")
+		flID         = flag.String("id", "devicesim", "enrollment ID (or prefix if simulating many devices)")
+		flDevices    = flag.Int("devices", 1, "number of devices to simulate")
+		flSyncs      = flag.Int("syncs", 1, "number of synchronizations per device (0 for no limit)")
+		flInterval   = flag.Duration("interval", 10*time.Second, "interval between synchronizations")
+		flConcurrent = flag.Int("concurrency", 10, "maximum number of devices synchronizing at once")
+	)
+	flag.Parse()
+
+	if *flVersion {
+		fmt.Println(version)
+		return
+	}
+
+	logger := stdlogfmt.New(stdlogfmt.WithDebugFlag(*flDebug))
+
+	if *flDevices < 1 || *flConcurrent < 1 {
+		logger.Info(logkeys.Message, "devices and concurrency must be positive")
+		os.Exit(1)
+	}
+
+	devices := make([]*devicesim.Device, *flDevices)
+	for i := range devices {
+		id := *flID
+		if *flDevices > 1 {
+			id = fmt.Sprintf("%s-%06d", *flID, i)
+		}
+		var err error
+		devices[i], err = devicesim.New(*flURL, id, devicesim.WithLogger(logger.With("service", "devicesim")))
+		if err != nil {
+			logger.Info(logkeys.Message, "creating device", logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+
+	var syncs, changed, fetched, failed int64
+	sem := make(chan struct{}, *flConcurrent)
+	ctx := context.Background()
+	start := time.Now()
+
+	var wg sync.WaitGroup
+	for _, device := range devices {
+		wg.Add(1)
+		go func(device *devicesim.Device) {
+			defer wg.Done()
+			for i := 0; *flSyncs == 0 || i < *flSyncs; i++ {
+				if i > 0 {
+					time.Sleep(*flInterval)
+				}
+				sem <- struct{}{}
+				result, err := device.Sync(ctx)
+				<-sem
+				atomic.AddInt64(&syncs, 1)
+				if err != nil {
+					atomic.AddInt64(&failed, 1)
+					logger.Info(logkeys.Message, "sync", "enrollment_id", device.EnrollmentID(), logkeys.Error, err)
+					continue
+				}
+				if result.Changed {
+					atomic.AddInt64(&changed, 1)
+				}
+				atomic.AddInt64(&fetched, int64(len(result.Fetched)))
+			}
+		}(device)
+	}
+	wg.Wait()
+
+	logger.Info(
+		logkeys.Message, "simulation complete",
+		"devices", len(devices),
+		"syncs", syncs,
+		"changed", changed,
+		"declarations_fetched", fetched,
+		"errors", failed,
+		"duration", time.Since(start).String(),
+	)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/internal/devicesim"
+)
+
+// EndpointStats are the latency statistics of a single endpoint.
+// Latencies are in milliseconds.
+type EndpointStats struct {
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	P50      float64 `json:"p50_ms"`
+	P90      float64 `json:"p90_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+}
+
+// recorder records request latencies by endpoint.
+type recorder struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (r *recorder) record(endpoint string, d time.Duration, failed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies[endpoint] = append(r.latencies[endpoint], d)
+	if failed {
+		r.errors[endpoint]++
+	}
+}
+
+// percentile returns the p-th percentile of the sorted durations in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) < 1 {
+		return 0
+	}
+	i := int(float64(len(sorted))*p+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
+
+// stats computes the latency statistics of each endpoint.
+func (r *recorder) stats() map[string]EndpointStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ret := make(map[string]EndpointStats, len(r.latencies))
+	for endpoint, latencies := range r.latencies {
+		sorted := append([]time.Duration{}, latencies...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		ret[endpoint] = EndpointStats{
+			Requests: len(sorted),
+			Errors:   r.errors[endpoint],
+			P50:      percentile(sorted, 0.50),
+			P90:      percentile(sorted, 0.90),
+			P99:      percentile(sorted, 0.99),
+			Max:      percentile(sorted, 1),
+		}
+	}
+	return ret
+}
+
+// endpointName returns the DDM endpoint name of the request path.
+func endpointName(path string) string {
+	for _, endpoint := range []string{"tokens", "declaration-items", "declaration", "status"} {
+		if strings.HasSuffix(path, "/"+endpoint) || strings.Contains(path, "/"+endpoint+"/") {
+			return endpoint
+		}
+	}
+	return "other"
+}
+
+// timingClient records the latency of requests made with next.
+type timingClient struct {
+	next     devicesim.Doer
+	recorder *recorder
+}
+
+func (c *timingClient) Do(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := c.next.Do(req)
+	c.recorder.record(
+		endpointName(req.URL.Path),
+		time.Since(start),
+		err != nil || resp.StatusCode < 200 || resp.StatusCode >= 300,
+	)
+	return resp, err
+}
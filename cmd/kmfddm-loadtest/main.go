@@ -0,0 +1,215 @@
+// Command kmfddm-loadtest drives simulated DDM enrollments against a
+// running KMFDDM server at a target rate and reports per-endpoint latencies.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/internal/devicesim"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/log/stdlogfmt"
+)
+
+// overridden by -ldflags -X
+var version = "unknown"
+
+const apiUsername = "kmfddm"
+
+// Report is the result of a load test.
+type Report struct {
+	Backend     string  `json:"backend,omitempty"`
+	Enrollments int     `json:"enrollments"`
+	TargetRate  float64 `json:"target_rate"`
+	Duration    string  `json:"duration"`
+
+	// Syncs is the number of device synchronizations performed.
+	Syncs int64 `json:"syncs"`
+
+	// ActualRate is the achieved synchronizations per second.
+	ActualRate float64 `json:"actual_rate"`
+
+	// Missed is the number of synchronizations that could not be
+	// started on time because all workers were busy.
+	Missed int64 `json:"missed"`
+
+	Errors int64 `json:"errors"`
+
+	Endpoints map[string]EndpointStats `json:"endpoints"`
+}
+
+// associate associates each enrollment ID with setName using the KMFDDM API.
+// Enrollments are not notified.
+func associate(ctx context.Context, apiURL, apiKey, setName string, ids []string) error {
+	for _, id := range ids {
+		u := strings.TrimSuffix(apiURL, "/") + "/v1/enrollment-sets/" + url.PathEscape(id) +
+			"?nonotify=1&set=" + url.QueryEscape(setName)
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, u, nil)
+		if err != nil {
+			return err
+		}
+		req.SetBasicAuth(apiUsername, apiKey)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusNotModified {
+			return fmt.Errorf("associating %s: HTTP status %d", id, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+func main() {
+	var (
+		flDebug       = flag.Bool("debug", false, "log debug messages")
+		flVersion     = flag.Bool("version", false, "print version")
+		flURL         = flag.String("url", "http://localhost:9002", "base URL of KMFDDM server DDM endpoints")
+		flAPIURL      = flag.String("api-url", "", "base URL of KMFDDM server API endpoints (defaults to -url)")
+		flAPIKey      = flag.String("api-key", "", "API key; if set enrollments are associated with -set before testing")
+		flSet         = flag.String("set", "default", "set to associate enrollments with")
+		flID          = flag.String("id", "loadtest", "enrollment ID prefix")
+		flEnrollments = flag.Int("enrollments", 100, "number of simulated enrollments")
+		flRate        = flag.Float64("rate", 10, "target synchronizations per second")
+		flDuration    = flag.Duration("duration", time.Minute, "duration of the load test")
+		flConcurrent  = flag.Int("concurrency", 20, "maximum number of concurrent synchronizations")
+		flStatus      = flag.Bool("always-status", false, "send a status report on every sync, even if unchanged")
+		flBackend     = flag.String("backend", "", "storage backend name to include in the report (e.g. file, mysql)")
+	)
+	flag.Parse()
+
+	if *flVersion {
+		fmt.Println(version)
+		return
+	}
+
+	logger := stdlogfmt.New(stdlogfmt.WithDebugFlag(*flDebug))
+
+	if *flEnrollments < 1 || *flConcurrent < 1 || *flRate <= 0 {
+		logger.Info(logkeys.Message, "enrollments, concurrency, and rate must be positive")
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	ids := make([]string, *flEnrollments)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("%s-%06d", *flID, i)
+	}
+
+	if *flAPIKey != "" {
+		apiURL := *flAPIURL
+		if apiURL == "" {
+			apiURL = *flURL
+		}
+		if err := associate(ctx, apiURL, *flAPIKey, *flSet, ids); err != nil {
+			logger.Info(logkeys.Message, "associating enrollments", logkeys.Error, err)
+			os.Exit(1)
+		}
+		logger.Debug(logkeys.Message, "associated enrollments", "set", *flSet, "count", len(ids))
+	}
+
+	rec := newRecorder()
+	client := &timingClient{next: http.DefaultClient, recorder: rec}
+
+	// idle devices; a device is only used by one worker at a time
+	idle := make(chan *devicesim.Device, len(ids))
+	for _, id := range ids {
+		device, err := devicesim.New(
+			*flURL, id,
+			devicesim.WithClient(client),
+			devicesim.WithLogger(logger.With("service", "devicesim")),
+		)
+		if err != nil {
+			logger.Info(logkeys.Message, "creating device", logkeys.Error, err)
+			os.Exit(1)
+		}
+		idle <- device
+	}
+
+	report := &Report{
+		Backend:     *flBackend,
+		Enrollments: len(ids),
+		TargetRate:  *flRate,
+	}
+
+	jobs := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < *flConcurrent; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				device := <-idle
+				if err := syncDevice(ctx, device, *flStatus, logger); err != nil {
+					atomic.AddInt64(&report.Errors, 1)
+				}
+				atomic.AddInt64(&report.Syncs, 1)
+				idle <- device
+			}
+		}()
+	}
+
+	start := time.Now()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *flRate))
+	deadline := time.After(*flDuration)
+loop:
+	for {
+		select {
+		case <-deadline:
+			break loop
+		case <-ticker.C:
+			select {
+			case jobs <- struct{}{}:
+			default:
+				report.Missed++
+			}
+		}
+	}
+	ticker.Stop()
+	close(jobs)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	report.Duration = elapsed.String()
+	report.ActualRate = float64(report.Syncs) / elapsed.Seconds()
+	report.Endpoints = rec.stats()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		logger.Info(logkeys.Message, "encoding report", logkeys.Error, err)
+		os.Exit(1)
+	}
+}
+
+// syncDevice synchronizes device and optionally sends a status report
+// if the synchronization did not.
+func syncDevice(ctx context.Context, device *devicesim.Device, alwaysStatus bool, logger log.Logger) error {
+	result, err := device.Sync(ctx)
+	if err != nil {
+		logger.Info(logkeys.Message, "sync", "enrollment_id", device.EnrollmentID(), logkeys.Error, err)
+		return err
+	}
+	if alwaysStatus && !result.StatusSent {
+		report, err := device.StatusReport()
+		if err == nil {
+			err = device.SendStatus(ctx, report)
+		}
+		if err != nil {
+			logger.Info(logkeys.Message, "sending status", "enrollment_id", device.EnrollmentID(), logkeys.Error, err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Demo mode defaults.
+const (
+	demoAPIKey       = "demo"
+	demoSet          = "demo"
+	demoEnrollmentID = "DEMO-ENROLLMENT-ID"
+)
+
+// demoDeclarations are the example declarations seeded in demo mode.
+var demoDeclarations = []string{
+	`{
+	"Type": "com.apple.configuration.management.status-subscriptions",
+	"Identifier": "com.example.demo.status-subscriptions",
+	"Payload": {
+		"StatusItems": [
+			{"Name": "device.model.family"},
+			{"Name": "device.operating-system.version"}
+		]
+	}
+}`,
+	`{
+	"Type": "com.apple.configuration.passcode.settings",
+	"Identifier": "com.example.demo.passcode",
+	"Payload": {
+		"RequirePasscode": true,
+		"MinimumLength": 6
+	}
+}`,
+	`{
+	"Type": "com.apple.activation.simple",
+	"Identifier": "com.example.demo.activation",
+	"Payload": {
+		"StandardConfigurations": [
+			"com.example.demo.status-subscriptions",
+			"com.example.demo.passcode"
+		]
+	}
+}`,
+}
+
+// demoStorage is the storage needed to seed demo mode.
+type demoStorage interface {
+	storage.DeclarationStorer
+	storage.SetDeclarationStorage
+	storage.EnrollmentSetStorage
+}
+
+// seedDemo stores the example declarations in the demo set and
+// associates the demo enrollment with it.
+func seedDemo(ctx context.Context, store demoStorage) error {
+	for _, raw := range demoDeclarations {
+		d, err := ddm.ParseDeclaration([]byte(raw))
+		if err != nil {
+			return fmt.Errorf("parsing demo declaration: %w", err)
+		}
+		if _, err = store.StoreDeclaration(ctx, d); err != nil {
+			return fmt.Errorf("storing demo declaration %s: %w", d.Identifier, err)
+		}
+		if _, err = store.StoreSetDeclaration(ctx, demoSet, d.Identifier); err != nil {
+			return fmt.Errorf("storing demo set declaration %s: %w", d.Identifier, err)
+		}
+	}
+	if _, err := store.StoreEnrollmentSet(ctx, demoEnrollmentID, demoSet); err != nil {
+		return fmt.Errorf("storing demo enrollment set: %w", err)
+	}
+	return nil
+}
+
+// printDemoUsage writes example curl commands for the demo server to w.
+func printDemoUsage(w io.Writer, listen, apiKey, dir string) {
+	base := "http://localhost" + listen
+	if !strings.HasPrefix(listen, ":") {
+		base = "http://" + listen
+	}
+	api := "curl -u kmfddm:" + apiKey + " " + base
+	ddm := "curl -H 'X-Enrollment-ID: " + demoEnrollmentID + "' " + base
+	fmt.Fprintf(w, `
+KMFDDM demo mode
+================
+
+Storage is a throwaway directory (removed when the server exits): %s
+Notifications are logged rather than sent (unless -enqueue is set).
+
+List the seeded declarations, the demo set, and the enrollment's sets:
+
+  %s/v1/declarations
+  %s/v1/set-declarations/%s
+  %s/v1/enrollment-sets/%s
+
+Fetch DDM as the demo enrollment (like a device would):
+
+  %s/tokens
+  %s/declaration-items
+  %s/declaration/configuration/com.example.demo.passcode
+
+Change a declaration (the demo enrollment is notified):
+
+  %s/v1/declarations -X PUT --data-binary '{"Type":"com.apple.configuration.passcode.settings","Identifier":"com.example.demo.passcode","Payload":{"RequirePasscode":true,"MinimumLength":8}}'
+
+`,
+		dir,
+		api, api, demoSet, api, demoEnrollmentID,
+		ddm, ddm, ddm,
+		api,
+	)
+}
+
+// demoEnqueuer logs DM commands instead of enqueueing them.
+type demoEnqueuer struct {
+	logger log.Logger
+}
+
+func (e *demoEnqueuer) EnqueueDMCommand(ctx context.Context, ids []string, tokensJSON []byte) error {
+	ctxlog.Logger(ctx, e.logger).Info(
+		logkeys.Message, "demo: not enqueueing command",
+		logkeys.GenericCount, len(ids),
+		logkeys.FirstEnrollmentID, ids[0],
+		"tokens", len(tokensJSON) > 0,
+	)
+	return nil
+}
+
+// removeDemoDirOnSignal removes the demo storage directory and exits
+// when the server is interrupted or terminated.
+func removeDemoDirOnSignal(dir string, logger log.Logger) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		if err := os.RemoveAll(dir); err != nil {
+			logger.Info(logkeys.Message, "removing demo storage", "path", dir, logkeys.Error, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/declsync"
+	"github.com/jessepeterson/kmfddm/lint"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// lintDir lints the declarations in dir and writes the JSON report to stdout.
+// The exit status is returned: non-zero if there were errors.
+func lintDir(dir string, linter *lint.Linter, logger log.Logger) int {
+	src, err := declsync.ReadDir(dir)
+	if err != nil {
+		logger.Info(logkeys.Message, "reading lint directory", "path", dir, logkeys.Error, err)
+		return 1
+	}
+	for _, e := range src.Errors {
+		logger.Info(logkeys.Message, "reading declaration", logkeys.Error, e)
+	}
+	decls := make([]*ddm.Declaration, 0, len(src.Declarations))
+	for _, d := range src.Declarations {
+		decls = append(decls, d)
+	}
+	report := linter.Lint(decls, nil)
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(report); err != nil {
+		logger.Info(logkeys.Message, "encoding lint report", logkeys.Error, err)
+		return 1
+	}
+	if report.HasErrors() || len(src.Errors) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,22 +1,53 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"math/rand"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/alexedwards/flow"
+	"github.com/jessepeterson/kmfddm/adminevent"
+	"github.com/jessepeterson/kmfddm/compliance"
+	"github.com/jessepeterson/kmfddm/credservice"
+	"github.com/jessepeterson/kmfddm/ddmindex"
+	"github.com/jessepeterson/kmfddm/declevent"
+	"github.com/jessepeterson/kmfddm/declsync"
+	"github.com/jessepeterson/kmfddm/freeze"
+	"github.com/jessepeterson/kmfddm/groupsync"
+	"github.com/jessepeterson/kmfddm/guard"
 	httpddm "github.com/jessepeterson/kmfddm/http"
 	apihttp "github.com/jessepeterson/kmfddm/http/api"
 	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/jobs"
+	"github.com/jessepeterson/kmfddm/lint"
 	"github.com/jessepeterson/kmfddm/log/logkeys"
 	"github.com/jessepeterson/kmfddm/log/stdlogfmt"
+	"github.com/jessepeterson/kmfddm/metering"
 	"github.com/jessepeterson/kmfddm/notifier"
 	"github.com/jessepeterson/kmfddm/notifier/foss"
+	"github.com/jessepeterson/kmfddm/ostarget"
+	"github.com/jessepeterson/kmfddm/policy"
+	"github.com/jessepeterson/kmfddm/quota"
+	"github.com/jessepeterson/kmfddm/rebuild"
+	"github.com/jessepeterson/kmfddm/redact"
+	"github.com/jessepeterson/kmfddm/remediation"
+	"github.com/jessepeterson/kmfddm/reqcache"
+	"github.com/jessepeterson/kmfddm/scan"
+	"github.com/jessepeterson/kmfddm/schedule"
+	"github.com/jessepeterson/kmfddm/selftest"
+	"github.com/jessepeterson/kmfddm/servedhistory"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/chaos"
+	"github.com/jessepeterson/kmfddm/transform"
 )
 
 // overridden by -ldflags -X
@@ -25,6 +56,10 @@ var version = "unknown"
 const (
 	apiUsername = "kmfddm"
 	apiRealm    = "kmfddm"
+
+	// shutdownTimeout is how long in-flight requests may take to
+	// finish when the server is stopped.
+	shutdownTimeout = 10 * time.Second
 )
 
 func main() {
@@ -32,10 +67,31 @@ func main() {
 		flDebug   = flag.Bool("debug", false, "log debug messages")
 		flListen  = flag.String("listen", ":9002", "HTTP listen address")
 		flAPIKey  = flag.String("api", "", "API key for API endpoints")
+		flAPIRO   = flag.String("api-readonly", "", "read-only API key for API endpoints")
+		flAPIPrin = flag.String("api-principals", "", "path to JSON config of additional API principals")
+		flQuotas  = flag.String("api-quotas", "", "path to JSON config of per-principal API quotas")
+		flPolicy  = flag.String("api-policies", "", "path to JSON bundle or directory of policies of which declarations principals may store and assign to sets")
 		flVersion = flag.Bool("version", false, "print version")
+		flDemo    = flag.Bool("demo", false, "start a demo server with throwaway storage seeded with example declarations")
 		flStorage = flag.String("storage", "file", "storage backend")
 		flDSN     = flag.String("storage-dsn", "", "storage data source name")
 		flOptions = flag.String("storage-options", "", "storage backend options")
+		flChaos   = flag.String("storage-chaos", "", "path to JSON config of storage faults to inject (for testing only)")
+		flHash    = flag.String("hash", storage.DefaultHashAlgorithm, "hash algorithm of tokens (xxhash or sha256)")
+
+		flHashLazy = flag.Bool("hash-lazy", false, "recompute tokens of a changed -hash algorithm as declarations are stored rather than at startup")
+
+		flReadOnly = flag.Bool("read-only", false, "reject API requests that change data and disable background changes")
+
+		flHistory    = flag.String("status-history", "", "comma-separated status paths to record the value history of")
+		flHistoryMax = flag.Uint("status-history-max", storage.DefaultStatusValueHistoryMax, "maximum number of recorded values per enrollment and status path")
+
+		flMaxValues       = flag.Uint("status-max-values", 0, "maximum number of status values kept per enrollment (0 is unlimited)")
+		flMaxErrors       = flag.Uint("status-max-errors", 0, "maximum number of status errors kept per enrollment (0 is unlimited)")
+		flReportMaxValues = flag.Uint("status-report-max-values", 0, "maximum number of status values stored per status report (0 is unlimited)")
+		flReportMaxErrors = flag.Uint("status-report-max-errors", 0, "maximum number of status errors stored per status report (0 is unlimited)")
+
+		flIDHashing = flag.String("status-id-hashing", "", "path to JSON config to store status records under salted hashes of enrollment IDs")
 
 		flDumpStatus = flag.String("dump-status", "", "file name to dump status reports to (\"-\" for stdout)")
 
@@ -43,6 +99,69 @@ func main() {
 		flEnqueueKey = flag.String("enqueue-key", "", "MDM server enqueue API key")
 		flCORSOrigin = flag.String("cors-origin", "", "CORS Origin; for browser-based API access")
 		flMicro      = flag.Bool("micromdm", false, "Use MicroMDM command API calling conventions")
+
+		flNotifyQueue    = flag.Bool("notify-queue", false, "queue notifications in lanes by priority (set with the API \"priority\" query parameter)")
+		flNotifyRate     = flag.Float64("notify-rate", 0, "maximum enrollments per second notified from the normal priority lane of -notify-queue (0 for unlimited)")
+		flNotifyRateHigh = flag.Float64("notify-rate-high", 0, "maximum enrollments per second notified from the high priority lane of -notify-queue (0 for unlimited)")
+		flNotifyThrottle = flag.Duration("notify-throttle", 0, "skip notifying enrollments notified within this duration whose tokens have not changed since (0 disables)")
+		flNotifyWindow   = flag.String("notify-window", "", "daily window (e.g. \"01:00-05:00\") in the local time zone of enrollments to defer non-urgent notifications to (set with the API \"urgent\" query parameter)")
+		flNotifyZoneKey  = flag.String("notify-window-zone-key", notifier.DefaultZoneKey, "enrollment registration metadata key of the time zone of enrollments for -notify-window")
+		flNotifyZone     = flag.String("notify-window-zone", "", "time zone of enrollments without a time zone for -notify-window (not deferred if empty)")
+
+		flEvents     = flag.String("admin-events", "", "path to JSON config of webhooks and email to post admin events to")
+		flRemediate  = flag.String("remediation", "", "path to JSON config of rules to remediate reported declaration status")
+		flDeclEvents = flag.String("declaration-events", "", "path to JSON config of webhooks to post declaration lifecycle events to")
+		flMetering   = flag.Bool("metering", false, "tally the daily usage of sets by enrollments")
+		flCompliance = flag.Bool("compliance", false, "evaluate the compliance of enrollments with the requirements of their sets when they report status")
+
+		flServedHistory       = flag.Bool("served-history", false, "record the hashes of tokens and declaration items served to enrollments when they change")
+		flServedHistoryBodies = flag.Bool("served-history-bodies", false, "also record the bodies of served documents with -served-history")
+		flServedHistoryMax    = flag.Int("served-history-max", storage.DefaultServedHistoryMax, "maximum number of served documents of each kind recorded per enrollment (0 for unlimited)")
+
+		flWarnDecls  = flag.Int("warn-declarations", 500, "warn when an enrollment's resolved declaration count exceeds this (0 disables)")
+		flWarnDISize = flag.Int("warn-declaration-items-size", 1<<20, "warn when an enrollment's declaration-items JSON exceeds this many bytes (0 disables)")
+
+		flRedact = flag.String("redact-config", "", "path to JSON config of sensitive payload keys to redact for read-only API reads")
+
+		flTransformID = flag.Bool("transform-enrollment-id", false, "replace "+transform.EnrollmentIDPlaceholder+" in served declaration payloads with the enrollment ID")
+		flOSReqs      = flag.String("os-requirements", "", "path to JSON config of minimum OS versions of declarations and sets served to enrollments")
+
+		flProfileURL = flag.String("profile-url", "", "base URL that enrollments use to download hosted profiles (e.g. https://kmfddm.example.com/profile)")
+
+		flCredentialURL      = flag.String("credential-url", "", "base URL that enrollments use to download hosted credentials (e.g. https://kmfddm.example.com/credential)")
+		flCredentialServices = flag.String("credential-services", "", "path to JSON config of SCEP and ACME credential services")
+
+		flLint       = flag.String("lint", "", "lint the declarations in directory and exit")
+		flLintConfig = flag.String("lint-config", "", "path to JSON lint rules config")
+		flLintUpload = flag.Bool("lint-upload", false, "add lint findings of declarations stored via the API to responses as Warning headers")
+		flScanners   = flag.String("scanners", "", "path to JSON config of scanners that may reject or annotate declarations stored via the API")
+
+		flRepairDDM     = flag.Bool("repair-ddm", false, "repair the derived DDM data of all enrollments and exit")
+		flRepairDryRun  = flag.Bool("repair-ddm-dry-run", false, "only report mismatched derived DDM data with -repair-ddm or -verify-restore")
+		flVerifyRestore = flag.Bool("verify-restore", false, "verify storage integrity after a restore from backup, repair derived DDM data, and exit")
+		flRebuildRetry  = flag.Duration("ddm-rebuild-retry", time.Minute, "interval to retry failed rebuilds of the derived DDM data of enrollments (0 disables)")
+
+		flSyncDir       = flag.String("sync-dir", "", "directory of declarations and set files to sync from")
+		flSyncGit       = flag.String("sync-git", "", "URL of git repository to clone into the sync directory")
+		flSyncGitBranch = flag.String("sync-git-branch", "", "branch of git repository to sync")
+		flSyncPrune     = flag.Bool("sync-prune", false, "dissociate declarations not listed in set files when syncing")
+		flSyncDelete    = flag.Bool("sync-delete", false, "delete declarations whose files are removed when syncing")
+		flSyncWatch     = flag.Duration("sync-watch", 0, "interval to check the sync directory for changes (0 disables)")
+
+		flScheduleInterval = flag.Duration("schedule-interval", time.Minute, "interval to run assignment schedules (0 disables)")
+
+		flGroupSync         = flag.String("group-sync", "", "path to JSON config of directory groups to sync to sets")
+		flGroupSyncInterval = flag.Duration("group-sync-interval", 15*time.Minute, "interval to sync directory groups to sets")
+
+		flJobThreshold = flag.Int("notify-job-threshold", 0, "notify API changes affecting at least this many enrollments in background jobs (0 disables)")
+
+		flIndexTTL = flag.Duration("ddm-index-ttl", 0, "serve the DDM of enrollments from an in-memory index rebuilt after this duration (0 disables)")
+		flIndexMax = flag.Int("ddm-index-max", ddmindex.DefaultMaxEntries, "maximum number of enrollments in the in-memory DDM index")
+
+		flSelfTest = flag.Bool("self-test", false, "check storage and notifier reachability at startup and exit if a check fails")
+
+		flMaintenance           = flag.Bool("maintenance", false, "start with the DDM protocol endpoints in maintenance mode")
+		flMaintenanceRetryAfter = flag.Duration("maintenance-retry-after", ddmhttp.DefaultMaintenanceRetryAfter, "duration after which enrollments retry requests made in maintenance mode")
 	)
 	flag.Parse()
 
@@ -51,19 +170,223 @@ func main() {
 		return
 	}
 
+	if *flDemo {
+		// demo mode is verbose and usable without configuration
+		*flDebug = true
+		if *flAPIKey == "" {
+			*flAPIKey = demoAPIKey
+		}
+	}
+
 	logger := stdlogfmt.New(stdlogfmt.WithDebugFlag(*flDebug))
 
+	var demoDir string
+	if *flDemo {
+		var err error
+		if demoDir, err = os.MkdirTemp("", "kmfddm-demo-"); err != nil {
+			logger.Info(logkeys.Message, "creating demo storage", logkeys.Error, err)
+			os.Exit(1)
+		}
+		removeDemoDirOnSignal(demoDir, logger)
+		*flStorage = "file"
+		*flDSN = demoDir
+	}
+
+	var lintConfig *lint.Config
+	if *flLintConfig != "" {
+		var err error
+		if lintConfig, err = lint.ReadConfigFile(*flLintConfig); err != nil {
+			logger.Info(logkeys.Message, "reading lint config", "path", *flLintConfig, logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+	linter := lint.New(lintConfig)
+
+	var scanner *scan.Chain
+	if *flScanners != "" {
+		scanConfig, err := scan.ReadConfigFile(*flScanners)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading scanners config", "path", *flScanners, logkeys.Error, err)
+			os.Exit(1)
+		}
+		scanOpts, err := scanConfig.Options()
+		if err != nil {
+			logger.Info(logkeys.Message, "configuring scanners", "path", *flScanners, logkeys.Error, err)
+			os.Exit(1)
+		}
+		scanner = scan.New(scanOpts...)
+	}
+
+	if *flLint != "" {
+		os.Exit(lintDir(*flLint, linter, logger))
+	}
+
+	var redactor *redact.Redactor
+	if *flRedact != "" {
+		redactConfig, err := redact.ReadConfigFile(*flRedact)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading redaction config", "path", *flRedact, logkeys.Error, err)
+			os.Exit(1)
+		}
+		redactor = redact.New(redactConfig)
+	}
+
 	if *flAPIKey == "" {
 		logger.Info(logkeys.Message, "empty API key; API disabled")
 	}
 
+	var principals []httpddm.Principal
+	if *flAPIPrin != "" {
+		var err error
+		if principals, err = httpddm.ReadPrincipalsFile(*flAPIPrin); err != nil {
+			logger.Info(logkeys.Message, "reading API principals", "path", *flAPIPrin, logkeys.Error, err)
+			os.Exit(1)
+		}
+		// the API keys are principals, too
+		principals = append(principals, httpddm.Principal{Name: apiUsername, Key: *flAPIKey})
+		if *flAPIRO != "" {
+			principals = append(principals, httpddm.Principal{Name: apiUsername, Key: *flAPIRO, ReadOnly: true})
+		}
+	}
+
+	if hasher = hashers[*flHash]; hasher == nil {
+		logger.Info(logkeys.Message, "unknown hash algorithm", "hash", *flHash)
+		os.Exit(1)
+	}
+	if *flHashLazy {
+		// tokens may have been computed with any other algorithm
+		for name, newHash := range hashers {
+			if name != *flHash {
+				previousHashes = append(previousHashes, newHash)
+			}
+		}
+	}
+
 	var store allStorage
 	var err error
-	store, err = setupStorage(*flStorage, *flDSN, *flOptions, logger)
+	var history *storage.StatusValueHistory
+	if *flHistory != "" {
+		history = &storage.StatusValueHistory{
+			Paths: strings.Split(*flHistory, ","),
+			Max:   int(*flHistoryMax),
+		}
+	}
+	limits := &storage.StatusLimits{
+		ReportValues: int(*flReportMaxValues),
+		ReportErrors: int(*flReportMaxErrors),
+		Values:       int(*flMaxValues),
+		Errors:       int(*flMaxErrors),
+	}
+	store, err = setupStorage(*flStorage, *flDSN, *flOptions, history, limits, logger)
 	if err != nil {
 		logger.Info(logkeys.Message, "init storage", "name", *flStorage, logkeys.Error, err)
 		os.Exit(1)
 	}
+	// keep the unwrapped storage to flush its counters
+	flusher, _ := store.(counterFlusher)
+	if flusher != nil && flusher.CountersFlushInterval() > 0 {
+		go watchCounters(context.Background(), flusher, logger.With("service", "counters"))
+	}
+	if *flChaos != "" {
+		chaosConfig, err := chaos.ReadConfigFile(*flChaos)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading storage chaos config", "path", *flChaos, logkeys.Error, err)
+			os.Exit(1)
+		}
+		logger.Info(logkeys.Message, "injecting storage faults", "path", *flChaos)
+		store = chaos.New(store, chaosConfig)
+	}
+	if *flIDHashing != "" {
+		if store, err = newHashedStorage(store, *flIDHashing); err != nil {
+			logger.Info(logkeys.Message, "configuring status ID hashing", "path", *flIDHashing, logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+
+	var declEvents *declevent.Publisher
+	if *flDeclEvents != "" {
+		eventStore, err := newDeclEventStorage(store, *flDeclEvents, logger.With("service", "declaration-events"))
+		if err != nil {
+			logger.Info(logkeys.Message, "configuring declaration events", "path", *flDeclEvents, logkeys.Error, err)
+			os.Exit(1)
+		}
+		store, declEvents = eventStore, eventStore.events
+	}
+
+	// a read-only server leaves migrating the tokens to a writable one
+	if !*flReadOnly {
+		if err = migrateHash(store, *flHash, logger); err != nil {
+			logger.Info(logkeys.Message, "migrating hash algorithm", "hash", *flHash, logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+
+	if *flDemo {
+		if err = seedDemo(context.Background(), store); err != nil {
+			logger.Info(logkeys.Message, "seeding demo storage", logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+
+	if *flRepairDDM {
+		os.Exit(repairDDM(store, *flRepairDryRun, logger))
+	}
+
+	if *flVerifyRestore {
+		os.Exit(verifyRestore(store, *flRepairDryRun, logger))
+	}
+
+	var credServices *credservice.Services
+	if *flCredentialServices != "" {
+		credServicesConfig, err := credservice.ReadConfigFile(*flCredentialServices)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading credential services config", "path", *flCredentialServices, logkeys.Error, err)
+			os.Exit(1)
+		}
+		if credServices, err = credservice.New(credServicesConfig); err != nil {
+			logger.Info(logkeys.Message, "configuring credential services", logkeys.Error, err)
+			os.Exit(1)
+		}
+	}
+
+	// declarations served to enrollments may be transformed
+	var ddmStore transform.Storage = store
+	var transformers []transform.Transformer
+	if *flTransformID || credServices != nil {
+		transformers = append(transformers, transform.EnrollmentIDTemplate())
+	}
+	if credServices != nil {
+		transformers = append(transformers, credServices.Transformer())
+	}
+	if len(transformers) > 0 {
+		ddmStore = transform.New(store, hasher, transformers...)
+	}
+
+	// declarations may be excluded for the reported OS of enrollments
+	if *flOSReqs != "" {
+		osConfig, err := ostarget.ReadConfigFile(*flOSReqs)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading OS requirements", "path", *flOSReqs, logkeys.Error, err)
+			os.Exit(1)
+		}
+		ddmStore = ostarget.New(ddmStore, store, osConfig, hasher)
+	}
+
+	// the DDM of enrollments may be served from memory
+	var index *ddmindex.Index
+	if *flIndexTTL > 0 {
+		index = ddmindex.New(
+			ddmStore,
+			ddmindex.WithTTL(*flIndexTTL),
+			ddmindex.WithMaxEntries(*flIndexMax),
+			ddmindex.WithLogger(logger.With("service", "ddm-index")),
+		)
+		ddmStore = index
+	}
+
+	// frozen enrollments are served the DDM they were frozen with
+	liveStore := ddmStore
+	ddmStore = freeze.New(liveStore, store)
 
 	nOpts := []foss.Option{
 		foss.WithLogger(logger.With("service", "notifier-foss")),
@@ -76,37 +399,233 @@ func main() {
 		logger.Info(logkeys.Message, "creating notifier", logkeys.Error, err)
 		os.Exit(1)
 	}
-	nanoNotif, err := notifier.New(fossNotif, store, notifier.WithLogger(logger.With("service", "notifier")))
+	var enqueuer notifier.Enqueuer = fossNotif
+	if *flDemo && *flEnqueueURL == "" {
+		enqueuer = &demoEnqueuer{logger: logger.With("service", "notifier-demo")}
+	}
+	if *flNotifyQueue {
+		lanes := notifier.NewLanes(
+			enqueuer,
+			notifier.WithLaneRate(notifier.PriorityNormal, *flNotifyRate),
+			notifier.WithLaneRate(notifier.PriorityHigh, *flNotifyRateHigh),
+			notifier.WithLanesStorage(store),
+			notifier.WithLanesLogger(logger.With("service", "notifier-lanes")),
+		)
+		// resume the notifications queued before a restart
+		resumed, err := lanes.Resume(context.Background())
+		if err != nil {
+			logger.Info(logkeys.Message, "resuming queued notifications", logkeys.Error, err)
+			os.Exit(1)
+		} else if resumed > 0 {
+			logger.Info(logkeys.Message, "resuming queued notifications", logkeys.GenericCount, resumed)
+		}
+		go lanes.Run(context.Background())
+		enqueuer = lanes
+	}
+	if *flNotifyWindow != "" {
+		window, err := notifier.ParseWindow(*flNotifyWindow)
+		if err != nil {
+			logger.Info(logkeys.Message, "parsing notification window", logkeys.Error, err)
+			os.Exit(1)
+		}
+		windowOpts := []notifier.WindowsOption{
+			notifier.WithZoneKey(*flNotifyZoneKey),
+			notifier.WithWindowsStorage(store),
+			notifier.WithWindowsLogger(logger.With("service", "notifier-windows")),
+		}
+		if *flNotifyZone != "" {
+			loc, err := time.LoadLocation(*flNotifyZone)
+			if err != nil {
+				logger.Info(logkeys.Message, "loading notification window time zone", logkeys.Error, err)
+				os.Exit(1)
+			}
+			windowOpts = append(windowOpts, notifier.WithDefaultLocation(loc))
+		}
+		windows := notifier.NewWindows(enqueuer, store, window, windowOpts...)
+		// resume the notifications deferred before a restart
+		resumed, err := windows.Resume(context.Background())
+		if err != nil {
+			logger.Info(logkeys.Message, "resuming deferred notifications", logkeys.Error, err)
+			os.Exit(1)
+		} else if resumed > 0 {
+			logger.Info(logkeys.Message, "resuming deferred notifications", logkeys.GenericCount, resumed)
+		}
+		go windows.Run(context.Background())
+		enqueuer = windows
+	}
+	notifOpts := []notifier.Option{
+		notifier.WithLogger(logger.With("service", "notifier")),
+		notifier.WithCounters(store),
+		notifier.WithFrozen(store),
+	}
+	if index != nil {
+		notifOpts = append(notifOpts, notifier.WithInvalidator(index))
+	}
+	if *flNotifyThrottle > 0 {
+		notifOpts = append(notifOpts, notifier.WithThrottle(store, *flNotifyThrottle))
+	}
+	nanoNotif, err := notifier.New(enqueuer, store, notifOpts...)
 	if err != nil {
 		logger.Info(logkeys.Message, "creating notifier", logkeys.Error, err)
 		os.Exit(1)
 	}
 
+	if *flSelfTest {
+		checks := []selftest.Check{selftest.StorageCheck(store)}
+		if *flEnqueueURL != "" {
+			checks = append(checks, selftest.ReachableCheck("notifier", *flEnqueueURL, nil))
+		}
+		if !selfTest(checks, logger) {
+			os.Exit(1)
+		}
+	}
+
+	// API changes that notify many enrollments may be notified in
+	// background jobs whose progress is reported by the API
+	jobManager := jobs.New(jobs.WithLogger(logger.With("service", "jobs")))
+	var apiNotif apihttp.Notifier = nanoNotif
+	if *flJobThreshold > 0 {
+		apiNotif = jobManager.Notifier(nanoNotif, store, *flJobThreshold)
+	}
+
+	var events *adminevent.Dispatcher
+	if *flEvents != "" {
+		eventsConfig, err := adminevent.ReadConfigFile(*flEvents)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading admin events config", "path", *flEvents, logkeys.Error, err)
+			os.Exit(1)
+		}
+		eOpts, err := eventsConfig.Options()
+		if err != nil {
+			logger.Info(logkeys.Message, "configuring admin events", "path", *flEvents, logkeys.Error, err)
+			os.Exit(1)
+		}
+		eOpts = append(eOpts, adminevent.WithLogger(logger.With("service", "admin-events")))
+		events = adminevent.New(store, eOpts...)
+	}
+
+	var syncer *declsync.Syncer
+	if *flSyncDir != "" {
+		sOpts := []declsync.Option{
+			declsync.WithLogger(logger.With("service", "sync")),
+			declsync.WithNotifier(nanoNotif),
+		}
+		if events != nil {
+			sOpts = append(sOpts, declsync.WithAdminEvents(events))
+		}
+		if *flSyncGit != "" {
+			sOpts = append(sOpts, declsync.WithGit(*flSyncGit, *flSyncGitBranch))
+		}
+		if *flSyncPrune {
+			sOpts = append(sOpts, declsync.WithPrune())
+		}
+		if *flSyncDelete {
+			sOpts = append(sOpts, declsync.WithDelete())
+		}
+		syncer = declsync.New(store, *flSyncDir, sOpts...)
+		if *flSyncWatch > 0 && !*flReadOnly {
+			go syncer.Watch(context.Background(), *flSyncWatch)
+		}
+	} else if *flSyncGit != "" {
+		logger.Info(logkeys.Message, "sync git repository requires sync directory")
+		os.Exit(1)
+	}
+
+	if *flScheduleInterval > 0 && !*flReadOnly {
+		scheduler := schedule.New(
+			store,
+			schedule.WithLogger(logger.With("service", "schedule")),
+			schedule.WithNotifier(nanoNotif),
+		)
+		go scheduler.Watch(context.Background(), *flScheduleInterval)
+	}
+
+	if *flRebuildRetry > 0 && !*flReadOnly {
+		retrier := rebuild.New(
+			store,
+			rebuild.WithLogger(logger.With("service", "rebuild")),
+			rebuild.WithNotifier(nanoNotif),
+		)
+		go retrier.Watch(context.Background(), *flRebuildRetry)
+	}
+
+	if *flGroupSync != "" && !*flReadOnly {
+		if *flGroupSyncInterval <= 0 {
+			logger.Info(logkeys.Message, "group sync interval must be positive", "interval", flGroupSyncInterval.String())
+			os.Exit(1)
+		}
+		groupSyncConfig, err := groupsync.ReadConfigFile(*flGroupSync)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading group sync config", "path", *flGroupSync, logkeys.Error, err)
+			os.Exit(1)
+		}
+		dir, err := groupSyncConfig.Directory()
+		if err != nil {
+			logger.Info(logkeys.Message, "configuring group sync directory", logkeys.Error, err)
+			os.Exit(1)
+		}
+		groupSyncer := groupsync.New(
+			store,
+			dir,
+			groupSyncConfig.Mappings,
+			groupsync.WithLogger(logger.With("service", "groupsync")),
+			groupsync.WithNotifier(nanoNotif),
+		)
+		go groupSyncer.Watch(context.Background(), *flGroupSyncInterval)
+	}
+
+	sizeLimits := apihttp.SizeLimits{
+		Declarations:         *flWarnDecls,
+		DeclarationItemsSize: *flWarnDISize,
+	}
+
 	mux := flow.New()
 
 	mux.Handle("/version", httpddm.VersionHandler(version))
 
-	mux.Handle(
-		"/declaration-items",
-		ddmhttp.TokensOrDeclarationItemsHandler(store, false, logger.With(logkeys.Handler, "declaration-items")),
-		"GET",
-	)
+	var statusStore storage.StatusStorer = store
+	if *flRemediate != "" {
+		remediationConfig, err := remediation.ReadConfigFile(*flRemediate)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading remediation config", "path", *flRemediate, logkeys.Error, err)
+			os.Exit(1)
+		}
+		statusStore = remediation.New(
+			store,
+			remediationConfig,
+			remediation.WithLogger(logger.With("service", "remediation")),
+			remediation.WithNotifier(nanoNotif),
+		)
+	}
+	if *flCompliance {
+		evaluator := compliance.New(store, compliance.WithLogger(logger.With("service", "compliance")))
+		statusStore = evaluator.StatusStorer(statusStore)
+	}
+	if index != nil {
+		statusStore = index.StatusStorer(statusStore)
+	}
 
-	mux.Handle(
-		"/tokens",
-		ddmhttp.TokensOrDeclarationItemsHandler(store, true, logger.With(logkeys.Handler, "tokens")),
-		"GET",
-	)
+	// only the DDM served to enrollments is recorded (and not previews)
+	var servedStore storage.TokensDeclarationItemsRetriever = ddmStore
+	if *flServedHistory {
+		opts := []servedhistory.Option{
+			servedhistory.WithMax(*flServedHistoryMax),
+			servedhistory.WithLogger(logger.With("service", "served-history")),
+		}
+		if *flServedHistoryBodies {
+			opts = append(opts, servedhistory.WithBodies())
+		}
+		servedStore = servedhistory.New(ddmStore, store, opts...)
+	}
 
-	mux.Handle(
-		"/declaration/:type/:id",
-		http.StripPrefix("/declaration/",
-			ddmhttp.DeclarationHandler(store, logger.With(logkeys.Handler, "declaration")),
-		),
-		"GET",
-	)
+	var diHandler http.Handler = ddmhttp.TokensOrDeclarationItemsHandler(servedStore, false, store, logger.With(logkeys.Handler, "declaration-items"))
+	var statusHandler http.Handler = ddmhttp.StatusReportHandler(statusStore, store, logger.With(logkeys.Handler, "status"))
+	if *flMetering {
+		meter := metering.New(store, metering.WithLogger(logger.With("service", "metering")))
+		diHandler = meter.Handler(diHandler, metering.Syncs)
+		statusHandler = meter.Handler(statusHandler, metering.StatusReports)
+	}
 
-	var statusHandler http.Handler = ddmhttp.StatusReportHandler(store, logger.With(logkeys.Handler, "status"))
 	if *flDumpStatus != "" {
 		f := os.Stdout
 		if *flDumpStatus != "-" {
@@ -119,7 +638,131 @@ func main() {
 		}
 		statusHandler = DumpHandler(statusHandler, f)
 	}
-	mux.Handle("/status", statusHandler, "PUT")
+
+	var readOnly httpddm.Middleware
+	if *flReadOnly {
+		readOnly = func(h http.Handler) http.Handler {
+			return httpddm.ReadOnlyServerMiddleware(h, changesNothing)
+		}
+	}
+
+	chains := httpddm.RouteGroupChains{
+		API: httpddm.NewChain(
+			func(h http.Handler) http.Handler {
+				if principals != nil {
+					return httpddm.PrincipalBasicAuthMiddleware(h, principals, apiRealm)
+				}
+				if *flAPIRO != "" {
+					return httpddm.ReadOnlyBasicAuthMiddleware(h, apiUsername, *flAPIKey, *flAPIRO, apiRealm)
+				}
+				return httpddm.BasicAuthMiddleware(h, apiUsername, *flAPIKey, apiRealm)
+			},
+			readOnly,
+			func(h http.Handler) http.Handler {
+				return httpddm.IdempotencyMiddleware(h, store, httpddm.DefaultIdempotencyTTL, logger.With(logkeys.Handler, "idempotency"))
+			},
+		),
+	}
+	// answer enrollments with 503s while in maintenance mode
+	maintenance := ddmhttp.NewMaintenance(*flMaintenanceRetryAfter)
+	if *flMaintenance {
+		maintenance.Enable(0)
+	}
+	chains.Protocol = chains.Protocol.Append(maintenance.Middleware)
+	if index != nil {
+		// API changes made without notifying enrollments must also be
+		// reflected in the index
+		chains.API = chains.API.Append(index.Middleware)
+	}
+	var quotas *quota.Quotas
+	if *flQuotas != "" {
+		quotaConfig, err := quota.ReadConfigFile(*flQuotas)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading API quotas", "path", *flQuotas, logkeys.Error, err)
+			os.Exit(1)
+		}
+		quotas = quota.New(quotaConfig, store, quota.WithLogger(logger.With("service", "quota")))
+		chains.API = chains.API.Append(func(h http.Handler) http.Handler {
+			return quotas.RateMiddleware(h)
+		})
+	}
+	var guardOpts []guard.Option
+	if *flPolicy != "" {
+		loaded, err := policy.Load(*flPolicy)
+		if err != nil {
+			logger.Info(logkeys.Message, "loading API policies", "path", *flPolicy, logkeys.Error, err)
+			os.Exit(1)
+		}
+		policies := policy.New(loaded, store, policy.WithLogger(logger.With("service", "policy")))
+		guardOpts = append(guardOpts, guard.WithDeclarationChecker(policies), guard.WithSetDeclarationChecker(policies))
+	}
+	if quotas != nil {
+		// count the declarations and sets created against the quotas
+		// of their principals
+		guardOpts = append(guardOpts, guard.WithDeclarationChecker(quotas), guard.WithSetDeclarationChecker(quotas))
+	}
+	if scanner != nil {
+		guardOpts = append(guardOpts, guard.WithDeclarationChecker(scanner))
+		// report the annotations of scanned declarations
+		chains.API = chains.API.Append(func(h http.Handler) http.Handler {
+			return apihttp.ScanMiddleware(h, logger.With(logkeys.Handler, "scan"))
+		})
+	}
+	// check the declarations and set assignments stored by every API
+	// route alike (rather than per route)
+	apiStore := newGuardedStorage(store, guardOpts...)
+	// report the IDs of jobs started by API requests
+	chains.API = chains.API.Append(jobManager.Middleware)
+	// notify API changes with the requested priority
+	chains.API = chains.API.Append(notifier.PriorityMiddleware)
+	// notify API changes with the requested urgency
+	chains.API = chains.API.Append(notifier.UrgencyMiddleware)
+	// memoize repeated lookups within API requests
+	chains.API = chains.API.Append(reqcache.Middleware)
+	cachedStore := newCachedStorage(store)
+
+	// DDM protocol
+	mux.Group(func(mux *flow.Mux) {
+		chains.Protocol.Use(mux)
+
+		mux.Handle("/declaration-items", diHandler, "GET")
+
+		mux.Handle(
+			"/tokens",
+			ddmhttp.TokensOrDeclarationItemsHandler(servedStore, true, store, logger.With(logkeys.Handler, "tokens")),
+			"GET",
+		)
+
+		mux.Handle(
+			"/declaration/:type/:id",
+			http.StripPrefix("/declaration/",
+				ddmhttp.DeclarationHandler(ddmStore, store, logger.With(logkeys.Handler, "declaration")),
+			),
+			"GET",
+		)
+
+		mux.Handle(
+			"/profile/:id",
+			apihttp.GetProfileHandler(store, logger.With(logkeys.Handler, "profile")),
+			"GET",
+		)
+
+		mux.Handle(
+			"/credential/:id",
+			apihttp.GetCredentialDataHandler(store, logger.With(logkeys.Handler, "credential")),
+			"GET",
+		)
+
+		if credServices != nil {
+			mux.Handle(
+				"/credential-service/:name/:id/:token",
+				apihttp.GetCredentialServiceDataHandler(credServices, logger.With(logkeys.Handler, "credential-service")),
+				"GET",
+			)
+		}
+
+		mux.Handle("/status", statusHandler, "PUT")
+	})
 
 	if *flAPIKey != "" {
 		if *flCORSOrigin != "" {
@@ -131,9 +774,34 @@ func main() {
 		}
 
 		mux.Group(func(mux *flow.Mux) {
-			mux.Use(func(h http.Handler) http.Handler {
-				return httpddm.BasicAuthMiddleware(h, apiUsername, *flAPIKey, apiRealm)
-			})
+			chains.API.Use(mux)
+
+			if principals != nil {
+				// pending changes are registered before the approval
+				// middleware so that approving is not held for approval
+				mux.Handle(
+					"/v1/pending-changes",
+					apihttp.GetPendingChangesHandler(store, logger.With(logkeys.Handler, "get-pending-changes")),
+					"GET",
+				)
+
+				mux.Handle(
+					"/v1/pending-changes/:id/approve",
+					// approved changes are replayed to the API routes
+					apihttp.ApprovePendingChangeHandler(store, mux, logger.With(logkeys.Handler, "approve-pending-change")),
+					"POST",
+				)
+
+				mux.Handle(
+					"/v1/pending-changes/:id/reject",
+					apihttp.RejectPendingChangeHandler(store, logger.With(logkeys.Handler, "reject-pending-change")),
+					"POST",
+				)
+
+				mux.Use(func(h http.Handler) http.Handler {
+					return httpddm.ApprovalMiddleware(h, store, changesNothing, logger.With(logkeys.Handler, "approval"))
+				})
+			}
 
 			// declarations
 			mux.Handle(
@@ -142,15 +810,19 @@ func main() {
 				"GET",
 			)
 
+			var putDeclarationHandler http.Handler = apihttp.PutDeclarationHandler(apiStore, apiNotif, logger.With(logkeys.Handler, "put-declaration"))
+			if *flLintUpload {
+				putDeclarationHandler = apihttp.LintWarningMiddleware(putDeclarationHandler, cachedStore, linter, logger.With(logkeys.Handler, "lint-upload"))
+			}
 			mux.Handle(
 				"/v1/declarations",
-				apihttp.PutDeclarationHandler(store, nanoNotif, logger.With(logkeys.Handler, "put-declaration")),
+				putDeclarationHandler,
 				"PUT",
 			)
 
 			mux.Handle(
 				"/v1/declarations/:id",
-				apihttp.GetDeclarationHandler(store, logger.With(logkeys.Handler, "get-declaration")),
+				apihttp.GetDeclarationHandler(cachedStore, redactor, logger.With(logkeys.Handler, "get-declaration")),
 				"GET",
 			)
 
@@ -162,7 +834,7 @@ func main() {
 
 			mux.Handle(
 				"/v1/declarations/:id/touch",
-				apihttp.TouchDeclarationHandler(store, nanoNotif, logger.With(logkeys.Handler, "touch-declaration")),
+				apihttp.TouchDeclarationHandler(store, apiNotif, logger.With(logkeys.Handler, "touch-declaration")),
 				"POST",
 			)
 
@@ -176,109 +848,729 @@ func main() {
 			// set declarations
 			mux.Handle(
 				"/v1/set-declarations/:id",
-				apihttp.GetSetDeclarationsHandler(store, logger.With(logkeys.Handler, "get-set-declarations")),
+				apihttp.GetSetDeclarationsHandler(cachedStore, logger.With(logkeys.Handler, "get-set-declarations")),
 				"GET",
 			)
 
+			setPreconditions := apihttp.NewSetPreconditions(store, logger.With(logkeys.Handler, "set-preconditions"))
+
+			var putSetDeclarationHandler http.Handler = apihttp.PutSetDeclarationHandler(apiStore, apiNotif, logger.With(logkeys.Handler, "put-set-declarations"))
+			var deleteSetDeclarationHandler http.Handler = apihttp.DeleteSetDeclarationHandler(apiStore, apiNotif, events, logger.With(logkeys.Handler, "delete-set-delcarations"))
 			mux.Handle(
 				"/v1/set-declarations/:id",
-				apihttp.PutSetDeclarationHandler(store, nanoNotif, logger.With(logkeys.Handler, "put-set-declarations")),
+				setPreconditions.Middleware(putSetDeclarationHandler),
 				"PUT",
 			)
 
 			mux.Handle(
 				"/v1/set-declarations/:id",
-				apihttp.DeleteSetDeclarationHandler(store, nanoNotif, logger.With(logkeys.Handler, "delete-set-delcarations")),
+				setPreconditions.Middleware(deleteSetDeclarationHandler),
 				"DELETE",
 			)
 
-			// enrollment sets
+			// declaration tags
 			mux.Handle(
-				"/v1/enrollment-sets/:id",
-				apihttp.GetEnrollmentSetsHandler(store, logger.With(logkeys.Handler, "get-enrollment-sets")),
+				"/v1/declaration-tags/:id",
+				apihttp.GetDeclarationTagsHandler(store, logger.With(logkeys.Handler, "get-declaration-tags")),
 				"GET",
 			)
 
 			mux.Handle(
-				"/v1/enrollment-sets/:id",
-				apihttp.PutEnrollmentSetHandler(store, nanoNotif, logger.With(logkeys.Handler, "put-enrollment-sets")),
+				"/v1/declaration-tags/:id",
+				apihttp.PutDeclarationTagHandler(store, logger.With(logkeys.Handler, "put-declaration-tag")),
 				"PUT",
 			)
 
 			mux.Handle(
-				"/v1/enrollment-sets/:id",
-				apihttp.DeleteEnrollmentSetHandler(store, nanoNotif, logger.With(logkeys.Handler, "delete-enrollment-sets")),
+				"/v1/declaration-tags/:id",
+				apihttp.DeleteDeclarationTagHandler(store, logger.With(logkeys.Handler, "delete-declaration-tag")),
 				"DELETE",
 			)
 
-			// declarations sets
 			mux.Handle(
-				"/v1/declaration-sets/:id",
-				apihttp.GetDeclarationSetsHandler(store, logger.With(logkeys.Handler, "get-declaration-sets")),
+				"/v1/tags",
+				apihttp.GetTagsHandler(store, logger.With(logkeys.Handler, "get-tags")),
 				"GET",
 			)
 
-			// status queries
 			mux.Handle(
-				"/v1/declaration-status/:id",
-				apihttp.GetDeclarationStatusHandler(store, logger.With(logkeys.Handler, "get-declaration-status")),
+				"/v1/tag-declarations/:id",
+				apihttp.GetTagDeclarationsHandler(store, logger.With(logkeys.Handler, "get-tag-declarations")),
 				"GET",
 			)
 
+			var putTagSetDeclarationsHandler http.Handler = apihttp.PutTagSetDeclarationsHandler(apiStore, apiNotif, logger.With(logkeys.Handler, "put-tag-set-declarations"))
+			var deleteTagSetDeclarationsHandler http.Handler = apihttp.DeleteTagSetDeclarationsHandler(apiStore, apiNotif, events, logger.With(logkeys.Handler, "delete-tag-set-declarations"))
 			mux.Handle(
-				"/v1/status-errors/:id",
-				apihttp.GetStatusErrorsHandler(store, logger.With(logkeys.Handler, "get-status-errors")),
+				"/v1/tag-set-declarations/:id",
+				setPreconditions.Serialize(putTagSetDeclarationsHandler),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/tag-set-declarations/:id",
+				setPreconditions.Serialize(deleteTagSetDeclarationsHandler),
+				"DELETE",
+			)
+
+			// set declaration conditions
+			mux.Handle(
+				"/v1/set-declaration-conditions/:id",
+				apihttp.GetSetDeclarationConditionsHandler(store, logger.With(logkeys.Handler, "get-set-declaration-conditions")),
 				"GET",
 			)
 
 			mux.Handle(
-				"/v1/status-values/:id",
-				apihttp.GetStatusValuesHandler(store, logger.With(logkeys.Handler, "get-status-values")),
+				"/v1/set-declaration-conditions/:id",
+				apihttp.PutSetDeclarationConditionHandler(store, apiNotif, logger.With(logkeys.Handler, "put-set-declaration-condition")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/set-declaration-conditions/:id",
+				apihttp.DeleteSetDeclarationConditionHandler(store, apiNotif, logger.With(logkeys.Handler, "delete-set-declaration-condition")),
+				"DELETE",
+			)
+
+			// set patterns
+			mux.Handle(
+				"/v1/set-patterns/:id",
+				apihttp.GetSetPatternsHandler(store, logger.With(logkeys.Handler, "get-set-patterns")),
 				"GET",
 			)
 
 			mux.Handle(
-				"/v1/status-report/:id",
-				apihttp.GetStatusReportHandler(store, logger.With(logkeys.Handler, "get-status-report")),
+				"/v1/set-patterns/:id",
+				apihttp.PutSetPatternHandler(store, apiNotif, logger.With(logkeys.Handler, "put-set-pattern")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/set-patterns/:id",
+				apihttp.DeleteSetPatternHandler(store, apiNotif, logger.With(logkeys.Handler, "delete-set-pattern")),
+				"DELETE",
+			)
+
+			// set snapshots
+			mux.Handle(
+				"/v1/set-snapshots/:id",
+				apihttp.GetSetSnapshotsHandler(store, logger.With(logkeys.Handler, "get-set-snapshots")),
 				"GET",
 			)
 
-			// notifier
 			mux.Handle(
-				"/v1/notify",
-				apihttp.NotifyHandler(nanoNotif, logger.With(logkeys.Handler, "notify")),
+				"/v1/set-snapshots/:id",
+				apihttp.PostSetSnapshotRestoreHandler(apiStore, apiNotif, logger.With(logkeys.Handler, "restore-set-snapshot")),
 				"POST",
 			)
-		})
-	}
 
-	// init for newTraceID()
-	rand.Seed(time.Now().UnixNano())
+			// enrollment sets
+			mux.Handle(
+				"/v1/enrollment-sets/:id",
+				apihttp.GetEnrollmentSetsHandler(cachedStore, logger.With(logkeys.Handler, "get-enrollment-sets")),
+				"GET",
+			)
 
-	logger.Info(logkeys.Message, "starting server", "listen", *flListen)
-	err = http.ListenAndServe(*flListen, httpddm.TraceLoggingMiddleware(mux, logger.With(logkeys.Handler, "log"), newTraceID))
-	logs := []interface{}{logkeys.Message, "server shutdown"}
-	if err != nil {
-		logs = append(logs, logkeys.Error, err)
-	}
-	logger.Info(logs...)
-}
+			mux.Handle(
+				"/v1/enrollment-sets/:id",
+				apihttp.SizeWarningMiddleware(
+					apihttp.PutEnrollmentSetHandler(store, apiNotif, logger.With(logkeys.Handler, "put-enrollment-sets")),
+					store,
+					sizeLimits,
+					logger.With(logkeys.Handler, "size-warning"),
+				),
+				"PUT",
+			)
 
-// newTraceID generates a new HTTP trace ID for context logging.
-// Currently this just makes a random string. This would be better
-// served by e.g. https://github.com/oklog/ulid or something like
-// https://opentelemetry.io/ someday.
-func newTraceID(_ *http.Request) string {
-	b := make([]byte, 8)
-	rand.Read(b)
-	return fmt.Sprintf("%x", b)
-}
+			mux.Handle(
+				"/v1/enrollment-sets/:id",
+				apihttp.DeleteEnrollmentSetHandler(store, apiNotif, logger.With(logkeys.Handler, "delete-enrollment-sets")),
+				"DELETE",
+			)
 
-func DumpHandler(next http.Handler, output io.Writer) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+			mux.Handle(
+				"/v1/enrollment-sets-import",
+				apihttp.PostEnrollmentSetsImportHandler(store, apiNotif, logger.With(logkeys.Handler, "post-enrollment-sets-import")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-staged-sets/:id",
+				apihttp.GetEnrollmentStagedSetsHandler(store, logger.With(logkeys.Handler, "get-enrollment-staged-sets")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-staged-sets/:id",
+				apihttp.PutEnrollmentStagedSetHandler(store, logger.With(logkeys.Handler, "put-enrollment-staged-sets")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-staged-sets/:id",
+				apihttp.DeleteEnrollmentStagedSetHandler(store, logger.With(logkeys.Handler, "delete-enrollment-staged-sets")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-staged-sets/:id",
+				apihttp.PostEnrollmentStagedSetsSwapHandler(store, apiNotif, logger.With(logkeys.Handler, "swap-enrollment-staged-sets")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/lifecycle-stages",
+				apihttp.GetLifecycleStagesHandler(store, logger.With(logkeys.Handler, "get-lifecycle-stages")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/lifecycle-stages/:id",
+				apihttp.PutLifecycleStageHandler(store, logger.With(logkeys.Handler, "put-lifecycle-stage")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/lifecycle-stages/:id",
+				apihttp.DeleteLifecycleStageHandler(store, logger.With(logkeys.Handler, "delete-lifecycle-stage")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-lifecycle-stage/:id",
+				apihttp.GetEnrollmentLifecycleStageHandler(store, logger.With(logkeys.Handler, "get-enrollment-lifecycle-stage")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-lifecycle-stage/:id",
+				apihttp.PutEnrollmentLifecycleStageHandler(store, apiNotif, logger.With(logkeys.Handler, "put-enrollment-lifecycle-stage")),
+				"PUT",
+			)
+
+			// compliance
+			mux.Handle(
+				"/v1/compliance-requirements",
+				apihttp.GetComplianceRequirementsHandler(store, logger.With(logkeys.Handler, "get-compliance-requirements")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/compliance-requirements/:id",
+				apihttp.PutComplianceRequirementHandler(store, logger.With(logkeys.Handler, "put-compliance-requirement")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/compliance-requirements/:id",
+				apihttp.DeleteComplianceRequirementHandler(store, logger.With(logkeys.Handler, "delete-compliance-requirement")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-compliance",
+				apihttp.GetEnrollmentComplianceHandler(store, logger.With(logkeys.Handler, "get-enrollment-compliance")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-served-history/:id",
+				apihttp.GetServedHistoryHandler(store, logger.With(logkeys.Handler, "get-enrollment-served-history")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-declarations/:id",
+				apihttp.GetEnrollmentDeclarationsHandler(cachedStore, logger.With(logkeys.Handler, "get-enrollment-declarations")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-stats/:id",
+				apihttp.GetEnrollmentStatsHandler(cachedStore, sizeLimits, logger.With(logkeys.Handler, "get-enrollment-stats")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/explain/:id",
+				apihttp.GetExplainHandler(cachedStore, logger.With(logkeys.Handler, "get-explain")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-freeze/:id",
+				apihttp.GetEnrollmentFreezeHandler(store, logger.With(logkeys.Handler, "get-enrollment-freeze")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-freeze/:id",
+				apihttp.PutEnrollmentFreezeHandler(liveStore, store, logger.With(logkeys.Handler, "put-enrollment-freeze")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-freeze/:id",
+				apihttp.DeleteEnrollmentFreezeHandler(store, apiNotif, logger.With(logkeys.Handler, "delete-enrollment-freeze")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-annotations/:id",
+				apihttp.GetEnrollmentAnnotationsHandler(store, logger.With(logkeys.Handler, "get-enrollment-annotations")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-annotations/:id",
+				apihttp.PutEnrollmentAnnotationHandler(store, logger.With(logkeys.Handler, "put-enrollment-annotation")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-annotations/:id",
+				apihttp.DeleteEnrollmentAnnotationHandler(store, logger.With(logkeys.Handler, "delete-enrollment-annotation")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/set-metadata",
+				apihttp.GetAllSetMetadataHandler(store, logger.With(logkeys.Handler, "get-all-set-metadata")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/set-metadata/:id",
+				apihttp.GetSetMetadataHandler(store, logger.With(logkeys.Handler, "get-set-metadata")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/set-metadata/:id",
+				apihttp.PutSetMetadataHandler(store, logger.With(logkeys.Handler, "put-set-metadata")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/set-metadata/:id",
+				apihttp.DeleteSetMetadataHandler(store, logger.With(logkeys.Handler, "delete-set-metadata")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-registrations/:id",
+				apihttp.GetEnrollmentRegistrationHandler(store, logger.With(logkeys.Handler, "get-enrollment-registration")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-registrations/:id",
+				apihttp.PutEnrollmentRegistrationHandler(store, apiNotif, logger.With(logkeys.Handler, "put-enrollment-registration")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-registrations/:id",
+				apihttp.DeleteEnrollmentRegistrationHandler(store, apiNotif, logger.With(logkeys.Handler, "delete-enrollment-registration")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/journal",
+				apihttp.GetJournalHandler(store, logger.With(logkeys.Handler, "get-journal")),
+				"GET",
+			)
+
+			// assignment schedules
+			mux.Handle(
+				"/v1/assignment-schedules",
+				apihttp.GetAssignmentSchedulesHandler(store, logger.With(logkeys.Handler, "get-assignment-schedules")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/assignment-schedules",
+				apihttp.PutAssignmentScheduleHandler(store, logger.With(logkeys.Handler, "put-assignment-schedule")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/assignment-schedules/:id",
+				apihttp.DeleteAssignmentScheduleHandler(store, logger.With(logkeys.Handler, "delete-assignment-schedule")),
+				"DELETE",
+			)
+
+			mux.Handle(
+				"/v1/preview/:id",
+				apihttp.GetPreviewHandler(ddmStore, redactor, logger.With(logkeys.Handler, "get-preview")),
+				"GET",
+			)
+
+			// declarations sets
+			mux.Handle(
+				"/v1/declaration-sets/:id",
+				apihttp.GetDeclarationSetsHandler(cachedStore, logger.With(logkeys.Handler, "get-declaration-sets")),
+				"GET",
+			)
+
+			// declaration usage
+			mux.Handle(
+				"/v1/declaration-usage",
+				apihttp.GetDeclarationUsageHandler(store, logger.With(logkeys.Handler, "get-declaration-usage")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/declaration-usage/:id",
+				apihttp.GetDeclarationUsageHandler(store, logger.With(logkeys.Handler, "get-declaration-usage")),
+				"GET",
+			)
+
+			// status queries
+			mux.Handle(
+				"/v1/declaration-status/:id",
+				apihttp.GetDeclarationStatusHandler(store, logger.With(logkeys.Handler, "get-declaration-status")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/pending-removals/:id",
+				apihttp.GetPendingRemovalsHandler(store, logger.With(logkeys.Handler, "get-pending-removals")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/status-errors/:id",
+				apihttp.GetStatusErrorsHandler(store, logger.With(logkeys.Handler, "get-status-errors")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/status-values/:id",
+				apihttp.GetStatusValuesHandler(store, logger.With(logkeys.Handler, "get-status-values")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/device-status-values/:id",
+				apihttp.GetDeviceStatusValuesHandler(store, logger.With(logkeys.Handler, "get-device-status-values")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/status-value-history/:id",
+				apihttp.GetStatusValueHistoryHandler(store, logger.With(logkeys.Handler, "get-status-value-history")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/set-status/:id",
+				apihttp.GetSetStatusSummaryHandler(store, logger.With(logkeys.Handler, "get-set-status")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/declaration-status-enrollments/:id",
+				apihttp.GetDeclarationStatusEnrollmentsHandler(store, logger.With(logkeys.Handler, "get-declaration-status-enrollments")),
+				"GET",
+			)
+
+			// batch status queries
+			mux.Handle(
+				"/v1/declaration-status",
+				apihttp.BatchDeclarationStatusHandler(store, logger.With(logkeys.Handler, "batch-declaration-status")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/status-errors",
+				apihttp.BatchStatusErrorsHandler(store, logger.With(logkeys.Handler, "batch-status-errors")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/status-values",
+				apihttp.BatchStatusValuesHandler(store, logger.With(logkeys.Handler, "batch-status-values")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/status-quarantine/:id",
+				apihttp.GetStatusQuarantineHandler(store, logger.With(logkeys.Handler, "get-status-quarantine")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/status-report/:id",
+				apihttp.GetStatusReportHandler(store, logger.With(logkeys.Handler, "get-status-report")),
+				"GET",
+			)
+
+			// lint
+			mux.Handle(
+				"/v1/lint",
+				apihttp.GetLintHandler(cachedStore, linter, logger.With(logkeys.Handler, "get-lint")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/lint",
+				apihttp.PostLintHandler(store, linter, logger.With(logkeys.Handler, "post-lint")),
+				"POST",
+			)
+
+			// declaration builders
+			mux.Handle(
+				"/v1/declaration-builders",
+				apihttp.GetDeclarationBuildersHandler(logger.With(logkeys.Handler, "get-declaration-builders")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/declaration-builders/:id",
+				apihttp.PostDeclarationBuilderHandler(logger.With(logkeys.Handler, "post-declaration-builder")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/legacy-profiles",
+				apihttp.PostLegacyProfileHandler(apiStore, *flProfileURL, apiNotif, logger.With(logkeys.Handler, "post-legacy-profile")),
+				"POST",
+			)
+
+			// credentials
+			mux.Handle(
+				"/v1/credentials",
+				apihttp.GetCredentialsHandler(store, logger.With(logkeys.Handler, "get-credentials")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/credentials/:id",
+				apihttp.GetCredentialHandler(store, logger.With(logkeys.Handler, "get-credential")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/credentials/:id",
+				apihttp.PutCredentialHandler(apiStore, *flCredentialURL, apiNotif, logger.With(logkeys.Handler, "put-credential")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/credentials/:id",
+				apihttp.DeleteCredentialHandler(store, logger.With(logkeys.Handler, "delete-credential")),
+				"DELETE",
+			)
+
+			if credServices != nil {
+				mux.Handle(
+					"/v1/credential-services",
+					apihttp.GetCredentialServicesHandler(credServices, logger.With(logkeys.Handler, "get-credential-services")),
+					"GET",
+				)
+
+				mux.Handle(
+					"/v1/credential-services/:id/declaration",
+					apihttp.PostCredentialServiceDeclarationHandler(apiStore, credServices, apiNotif, logger.With(logkeys.Handler, "post-credential-service-declaration")),
+					"POST",
+				)
+			}
+
+			// set bundles
+			mux.Handle(
+				"/v1/set-bundle/:id",
+				apihttp.GetSetBundleHandler(cachedStore, logger.With(logkeys.Handler, "get-set-bundle")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/set-bundle",
+				setPreconditions.Serialize(apihttp.PutSetBundleHandler(apiStore, apiNotif, logger.With(logkeys.Handler, "put-set-bundle"))),
+				"PUT",
+			)
+
+			// sync
+			if syncer != nil {
+				mux.Handle(
+					"/v1/sync",
+					apihttp.SyncHandler(syncer, logger.With(logkeys.Handler, "sync")),
+					"POST",
+				)
+			}
+
+			// repair
+			mux.Handle(
+				"/v1/repair-ddm",
+				apihttp.RepairDDMHandler(store, apiNotif, logger.With(logkeys.Handler, "repair-ddm")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/enrollment-refresh/:id",
+				apihttp.RefreshEnrollmentHandler(store, apiNotif, logger.With(logkeys.Handler, "refresh-enrollment")),
+				"POST",
+			)
+
+			mux.Handle(
+				"/v1/ddm-rebuilds",
+				apihttp.GetDDMRebuildsHandler(store, logger.With(logkeys.Handler, "get-ddm-rebuilds")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/ddm-rebuilds",
+				apihttp.RetryDDMRebuildsHandler(store, apiNotif, logger.With(logkeys.Handler, "retry-ddm-rebuilds")),
+				"POST",
+			)
+
+			// stats
+			mux.Handle(
+				"/v1/stats",
+				apihttp.GetStatsHandler(store, logger.With(logkeys.Handler, "get-stats")),
+				"GET",
+			)
+
+			// usage
+			mux.Handle(
+				"/v1/usage",
+				apihttp.GetUsageHandler(store, logger.With(logkeys.Handler, "get-usage")),
+				"GET",
+			)
+
+			// notifier
+			mux.Handle(
+				"/v1/notify",
+				apihttp.NotifyHandler(apiNotif, logger.With(logkeys.Handler, "notify")),
+				"POST",
+			)
+
+			if quotas != nil {
+				mux.Handle(
+					"/v1/quotas",
+					apihttp.GetQuotasHandler(quotas, logger.With(logkeys.Handler, "get-quotas")),
+					"GET",
+				)
+			}
+
+			// maintenance
+			mux.Handle(
+				"/v1/maintenance",
+				apihttp.GetMaintenanceHandler(maintenance, logger.With(logkeys.Handler, "get-maintenance")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/maintenance",
+				apihttp.PutMaintenanceHandler(maintenance, logger.With(logkeys.Handler, "put-maintenance")),
+				"PUT",
+			)
+
+			mux.Handle(
+				"/v1/maintenance",
+				apihttp.DeleteMaintenanceHandler(maintenance, logger.With(logkeys.Handler, "delete-maintenance")),
+				"DELETE",
+			)
+
+			if declEvents != nil {
+				mux.Handle(
+					"/v1/declaration-event-deliveries",
+					apihttp.GetDeclarationEventDeliveriesHandler(declEvents, logger.With(logkeys.Handler, "get-declaration-event-deliveries")),
+					"GET",
+				)
+			}
+
+			// jobs
+			mux.Handle(
+				"/v1/jobs",
+				apihttp.GetJobsHandler(jobManager, logger.With(logkeys.Handler, "get-jobs")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/jobs/:id",
+				apihttp.GetJobHandler(jobManager, logger.With(logkeys.Handler, "get-job")),
+				"GET",
+			)
+
+			mux.Handle(
+				"/v1/jobs/:id",
+				apihttp.DeleteJobHandler(jobManager, logger.With(logkeys.Handler, "delete-job")),
+				"DELETE",
+			)
+		})
+	}
+
+	// init for newTraceID()
+	rand.Seed(time.Now().UnixNano())
+
+	if *flDemo {
+		printDemoUsage(os.Stdout, *flListen, *flAPIKey, demoDir)
+	}
+
+	logger.Info(logkeys.Message, "starting server", "listen", *flListen)
+	root := httpddm.NewChain(func(h http.Handler) http.Handler {
+		return httpddm.TraceLoggingMiddleware(h, logger.With(logkeys.Handler, "log"), newTraceID)
+	})
+	srv := &http.Server{Addr: *flListen, Handler: root.Then(mux)}
+	// stop serving when interrupted or terminated so that storage can
+	// be flushed before exiting
+	shutdown := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		defer close(shutdown)
+		<-sig
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			logger.Info(logkeys.Message, "shutting down server", logkeys.Error, err)
+		}
+	}()
+	err = srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		// wait for in-flight requests
+		<-shutdown
+		err = nil
+	}
+	if flusher != nil {
+		if err := flusher.FlushCounters(); err != nil {
+			logger.Info(logkeys.Message, "flushing counters", logkeys.Error, err)
+		}
+	}
+	logs := []interface{}{logkeys.Message, "server shutdown"}
+	if err != nil {
+		logs = append(logs, logkeys.Error, err)
+	}
+	logger.Info(logs...)
+}
+
+// newTraceID generates a new HTTP trace ID for context logging.
+// Currently this just makes a random string. This would be better
+// served by e.g. https://github.com/oklog/ulid or something like
+// https://opentelemetry.io/ someday.
+func newTraceID(_ *http.Request) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+func DumpHandler(next http.Handler, output io.Writer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		respBytes, _ := httpddm.ReadAllAndReplaceBody(r)
 		output.Write(respBytes)
 		output.Write([]byte{'\n'})
 		next.ServeHTTP(w, r)
 	}
 }
+
+// changesNothing reports whether r uses a mutating method but does not
+// change anything and thus does not need approval and is served by a
+// read-only server.
+func changesNothing(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/v1/declaration-status", "/v1/status-errors", "/v1/status-values", "/v1/lint":
+		return true
+	}
+	return strings.HasPrefix(r.URL.Path, "/v1/declaration-builders/")
+}
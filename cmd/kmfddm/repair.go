@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// repairDDM repairs the derived DDM data of all enrollments and writes
+// the JSON report to stdout. The exit status is returned: non-zero if
+// there was an error or if mismatches were found in a dry run.
+func repairDDM(store storage.DDMRepairer, dryRun bool, logger log.Logger) int {
+	report, err := store.RepairEnrollmentDDM(context.Background(), dryRun)
+	if err != nil {
+		logger.Info(logkeys.Message, "repairing enrollment DDM", logkeys.Error, err)
+		return 1
+	}
+	if report.Mismatches == nil {
+		// encode as an empty JSON array
+		report.Mismatches = []storage.DDMMismatch{}
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(report); err != nil {
+		logger.Info(logkeys.Message, "encoding repair report", logkeys.Error, err)
+		return 1
+	}
+	if dryRun && len(report.Mismatches) > 0 {
+		return 1
+	}
+	return 0
+}
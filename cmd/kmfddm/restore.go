@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/integrity"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// verifyRestore verifies the integrity of store after a restore from
+// backup, repairs the derived DDM data of enrollments (unless dryRun),
+// and writes the JSON report to stdout. The exit status is returned:
+// non-zero if there was an error or if any problems were found.
+func verifyRestore(store integrity.Storage, dryRun bool, logger log.Logger) int {
+	report, err := integrity.Verify(context.Background(), store, dryRun)
+	if err != nil {
+		logger.Info(logkeys.Message, "verifying restore", logkeys.Error, err)
+		return 1
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(report); err != nil {
+		logger.Info(logkeys.Message, "encoding restore report", logkeys.Error, err)
+		return 1
+	}
+	if len(report.MissingDeclarations) > 0 || len(report.Unreciprocated) > 0 {
+		return 1
+	}
+	if dryRun && !report.OK() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/selftest"
+)
+
+// selfTest runs the startup self-test checks and logs their results.
+// Returns true if all checks passed.
+func selfTest(checks []selftest.Check, logger log.Logger) bool {
+	results, ok := selftest.Run(context.Background(), checks...)
+	for _, r := range results {
+		logger := logger.With("check", r.Name, "duration", r.Duration.String())
+		if r.OK {
+			logger.Debug(logkeys.Message, "self-test check passed")
+		} else {
+			logger.Info(logkeys.Message, "self-test check failed", logkeys.Error, r.Error)
+		}
+	}
+	if ok {
+		logger.Info(logkeys.Message, "self-test passed", logkeys.GenericCount, len(results))
+	}
+	return ok
+}
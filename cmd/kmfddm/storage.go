@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"hash"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/declevent"
+	"github.com/jessepeterson/kmfddm/guard"
+	"github.com/jessepeterson/kmfddm/idhash"
 	"github.com/jessepeterson/kmfddm/log"
 	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/reqcache"
 	"github.com/jessepeterson/kmfddm/storage"
 	"github.com/jessepeterson/kmfddm/storage/file"
 	"github.com/jessepeterson/kmfddm/storage/mysql"
+	"github.com/jessepeterson/kmfddm/storage/registry"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -25,32 +35,364 @@ type allStorage interface {
 	storage.SetRetreiver
 	storage.EnrollmentSetStorage
 	storage.StatusAPIStorage
+	storage.IdempotencyStorage
+	storage.SetStatusSummaryRetriever
+	storage.EnrollmentDeclarationsRetriever
+	storage.CounterStorage
+	storage.StatusValueHistoryRetriever
+	storage.ProfileStorage
+	storage.SetSnapshotStorage
+	storage.PendingChangeStorage
+	storage.UsageStorage
+	storage.DDMRepairer
+	storage.EnrollmentDDMRefresher
+	storage.DDMRebuildStorage
+	storage.PendingRemovalsRetriever
+	storage.DeclarationsPager
+	storage.SetsPager
+	storage.SetDeclarationConditionStorage
+	storage.DeclarationUsageRetriever
+	storage.HashMigrator
+	storage.EnrollmentFreezeStorage
+	storage.AssignmentScheduleStorage
+	storage.StatusQuarantineRetriever
+	storage.EnrollmentAnnotationStorage
+	storage.SetPatternStorage
+	storage.JournalRetriever
+	storage.EnrollmentStagedSetStorage
+	storage.LifecycleStageStorage
+	storage.EnrollmentRegistrationStorage
+	storage.CredentialStorage
+	storage.ComplianceStorage
+	storage.ServedHistoryStorage
+	storage.NotificationQueueStorage
+	storage.EnrollmentNotificationStorage
+	storage.DeclarationStatusEnrollmentsRetriever
+	storage.SetMetadataStorage
+	storage.DeclarationTagStorage
+	storage.OwnerStorage
 }
 
-var hasher func() hash.Hash = func() hash.Hash { return xxhash.New() }
+// cachedStorage is allStorage with the lookups of reqcache memoized
+// within requests. See also the reqcache middleware.
+type cachedStorage struct {
+	allStorage
+	cache *reqcache.Cache
+}
+
+func newCachedStorage(store allStorage) *cachedStorage {
+	return &cachedStorage{allStorage: store, cache: reqcache.New(store)}
+}
+
+func (s *cachedStorage) RetrieveDeclaration(ctx context.Context, declarationID string) (*ddm.Declaration, error) {
+	return s.cache.RetrieveDeclaration(ctx, declarationID)
+}
+
+func (s *cachedStorage) RetrieveDeclarationModTime(ctx context.Context, declarationID string) (time.Time, error) {
+	return s.cache.RetrieveDeclarationModTime(ctx, declarationID)
+}
+
+func (s *cachedStorage) RetrieveDeclarationSets(ctx context.Context, declarationID string) ([]string, error) {
+	return s.cache.RetrieveDeclarationSets(ctx, declarationID)
+}
+
+func (s *cachedStorage) RetrieveSetDeclarations(ctx context.Context, setName string) ([]string, error) {
+	return s.cache.RetrieveSetDeclarations(ctx, setName)
+}
+
+func (s *cachedStorage) RetrieveEnrollmentSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	return s.cache.RetrieveEnrollmentSets(ctx, enrollmentID)
+}
+
+func (s *cachedStorage) RetrieveEnrollmentDeclarations(ctx context.Context, enrollmentID string) ([]storage.EnrollmentDeclaration, error) {
+	return s.cache.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+}
+
+// hashedStorage is allStorage with the status records of enrollments
+// stored under hashed enrollment IDs. See also the idhash package.
+type hashedStorage struct {
+	allStorage
+	hashed *idhash.Store
+}
+
+func (s *hashedStorage) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	return s.hashed.StoreDeclarationStatus(ctx, enrollmentID, status)
+}
+
+func (s *hashedStorage) RetrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	return s.hashed.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+}
+
+func (s *hashedStorage) RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	return s.hashed.RetrievePendingRemovals(ctx, enrollmentIDs)
+}
+
+func (s *hashedStorage) RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]storage.StatusError, error) {
+	return s.hashed.RetrieveStatusErrors(ctx, enrollmentIDs, since, until, offset, limit)
+}
+
+func (s *hashedStorage) RetrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string) (map[string][]storage.QuarantinedStatus, error) {
+	return s.hashed.RetrieveStatusQuarantine(ctx, enrollmentIDs)
+}
+
+func (s *hashedStorage) RetrieveStatusReport(ctx context.Context, q storage.StatusReportQuery) (*storage.StoredStatusReport, error) {
+	return s.hashed.RetrieveStatusReport(ctx, q)
+}
+
+func (s *hashedStorage) RetrieveDeclarationStatusEnrollments(ctx context.Context, q *storage.DeclarationStatusQuery) ([]string, error) {
+	return s.hashed.RetrieveDeclarationStatusEnrollments(ctx, q)
+}
+
+func (s *hashedStorage) RetrieveSetStatusSummary(ctx context.Context, setName string) ([]storage.DeclarationStatusSummary, error) {
+	return s.hashed.RetrieveSetStatusSummary(ctx, setName)
+}
+
+// newHashedStorage wraps store to hash the enrollment IDs of status
+// records as configured by the JSON config at path.
+func newHashedStorage(store allStorage, path string) (*hashedStorage, error) {
+	config, err := idhash.ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	hasher, err := config.Hasher()
+	if err != nil {
+		return nil, err
+	}
+	var opts []idhash.Option
+	lookup, err := config.NewLookup()
+	if err != nil {
+		return nil, err
+	} else if lookup != nil {
+		opts = append(opts, idhash.WithLookup(lookup))
+	}
+	return &hashedStorage{allStorage: store, hashed: idhash.New(store, hasher, opts...)}, nil
+}
+
+// declEventStorage wraps storage to post declaration lifecycle events
+// to webhooks.
+type declEventStorage struct {
+	allStorage
+	events *declevent.Publisher
+}
+
+func (s *declEventStorage) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	return s.events.StoreDeclaration(ctx, d)
+}
+
+func (s *declEventStorage) TouchDeclaration(ctx context.Context, declarationID string) error {
+	return s.events.TouchDeclaration(ctx, declarationID)
+}
+
+func (s *declEventStorage) DeleteDeclaration(ctx context.Context, declarationID string) (bool, error) {
+	return s.events.DeleteDeclaration(ctx, declarationID)
+}
+
+func (s *declEventStorage) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.events.StoreSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *declEventStorage) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.events.RemoveSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *declEventStorage) StoreEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	return s.events.StoreEnrollmentSet(ctx, enrollmentID, setName)
+}
+
+func (s *declEventStorage) RemoveEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	return s.events.RemoveEnrollmentSet(ctx, enrollmentID, setName)
+}
+
+// newDeclEventStorage wraps store to post declaration lifecycle events
+// to the webhooks of the JSON config at path.
+func newDeclEventStorage(store allStorage, path string, logger log.Logger) (*declEventStorage, error) {
+	config, err := declevent.ReadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &declEventStorage{
+		allStorage: store,
+		events:     declevent.New(store, config, declevent.WithLogger(logger)),
+	}, nil
+}
+
+// guardedStorage wraps storage to check the declarations and set
+// assignments stored through the API (see the guard package).
+type guardedStorage struct {
+	allStorage
+	guard *guard.Guard
+}
+
+func (s *guardedStorage) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	return s.guard.StoreDeclaration(ctx, d)
+}
+
+func (s *guardedStorage) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.guard.StoreSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *guardedStorage) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.guard.RemoveSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *guardedStorage) RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error) {
+	return s.guard.RestoreSetSnapshot(ctx, setName, snapshotID)
+}
+
+// newGuardedStorage wraps store to check the declarations and set
+// assignments stored through the API. Store is returned as-is if there
+// are no options (checkers).
+func newGuardedStorage(store allStorage, opts ...guard.Option) allStorage {
+	if len(opts) < 1 {
+		return store
+	}
+	return &guardedStorage{allStorage: store, guard: guard.New(store, opts...)}
+}
+
+// hashers are the hash algorithms for tokens by name.
+var hashers = map[string]ddm.NewHash{
+	"xxhash": func() hash.Hash { return xxhash.New() },
+	"sha256": sha256.New,
+}
+
+var hasher = hashers[storage.DefaultHashAlgorithm]
+
+// previousHashes are the hashes that stored tokens may have been
+// computed with that are lazily recomputed with hasher.
+var previousHashes []ddm.NewHash
+
+// migrateHash recomputes the stored tokens if they were computed with
+// a hash algorithm other than algorithm.
+func migrateHash(store storage.HashMigrator, algorithm string, logger log.Logger) error {
+	migrated, err := store.MigrateHash(context.Background(), algorithm)
+	if err != nil {
+		return err
+	}
+	if migrated {
+		logger.Info(logkeys.Message, "recomputed tokens for new hash algorithm", "hash", algorithm)
+	}
+	return nil
+}
+
+func init() {
+	registry.Register("file", newFileStorage)
+	registry.Register("mysql", newMySQLStorage)
+}
 
-func setupStorage(name, dsn, options string, logger log.Logger) (allStorage, error) {
+func setupStorage(name, dsn, options string, history *storage.StatusValueHistory, limits *storage.StatusLimits, logger log.Logger) (allStorage, error) {
 	logger = logger.With("storage", name)
 	var mapOptions map[string]string
 	if options != "" {
 		mapOptions = splitOptions(options)
 	}
-	switch name {
-	case "mysql":
-		return setupMySQLStorage(dsn, mapOptions, logger)
-	case "file":
-		if dsn == "" {
-			dsn = "db"
+	if history.Enabled() {
+		logger.Debug(logkeys.Message, "status value history", "paths", strings.Join(history.Paths, ","), "max", history.Max)
+	}
+	if limits.Enabled() {
+		logger.Debug(
+			logkeys.Message, "status limits",
+			"report_values", limits.ReportValues,
+			"report_errors", limits.ReportErrors,
+			"values", limits.Values,
+			"errors", limits.Errors,
+		)
+	}
+	s, err := registry.New(name, &registry.Config{
+		DSN:     dsn,
+		Options: mapOptions,
+		History: history,
+		Limits:  limits,
+		NewHash: hasher,
+		Logger:  logger,
+
+		PreviousHashes: previousHashes,
+	})
+	if errors.Is(err, registry.ErrUnknownStorage) {
+		return nil, fmt.Errorf("%w (available: %s)", err, strings.Join(registry.Names(), ", "))
+	} else if err != nil {
+		return nil, err
+	}
+	store, ok := s.(allStorage)
+	if !ok {
+		return nil, fmt.Errorf("storage does not implement all storage interfaces: %s", name)
+	}
+	return store, nil
+}
+
+// counterFlusher is storage that keeps counter increments in memory
+// until they are flushed (i.e. the file backend).
+type counterFlusher interface {
+	FlushCounters() error
+	CountersFlushInterval() time.Duration
+}
+
+// watchCounters flushes the counter increments of store every flush
+// interval until ctx is done so that increments are written even when
+// no more counters are incremented.
+func watchCounters(ctx context.Context, store counterFlusher, logger log.Logger) {
+	ticker := time.NewTicker(store.CountersFlushInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := store.FlushCounters(); err != nil {
+			logger.Info(logkeys.Message, "flushing counters", logkeys.Error, err)
+		}
+	}
+}
+
+func newFileStorage(config *registry.Config) (interface{}, error) {
+	dsn := config.DSN
+	if dsn == "" {
+		dsn = "db"
+	}
+	opts := []file.Option{file.WithClock(config.Clock)}
+	if len(config.PreviousHashes) > 0 {
+		newHashes := make([]func() hash.Hash, len(config.PreviousHashes))
+		for i, newHash := range config.PreviousHashes {
+			newHashes[i] = newHash
 		}
-		return file.New(dsn, hasher)
-	default:
-		return nil, fmt.Errorf("unknown storage name: %s", name)
+		opts = append(opts, file.WithPreviousHashes(newHashes...))
+	}
+	if config.History.Enabled() {
+		opts = append(opts, file.WithValueHistory(config.History.Paths, config.History.Max))
+	}
+	if config.Limits.Enabled() {
+		opts = append(opts, file.WithStatusLimits(config.Limits))
 	}
+	for k, v := range config.Options {
+		switch k {
+		case "counters_flush":
+			interval, err := time.ParseDuration(v)
+			if err != nil {
+				return nil, fmt.Errorf("parsing counters_flush: %w", err)
+			}
+			opts = append(opts, file.WithCountersFlushInterval(interval))
+		case "preserve_declarations":
+			opts = append(opts, file.WithPreservedDeclarations())
+			config.Logger.Debug(logkeys.Message, "preserving declaration bytes")
+		case "compress":
+			opts = append(opts, file.WithCompression())
+			config.Logger.Debug(logkeys.Message, "compressing JSON files")
+		default:
+			return nil, fmt.Errorf("invalid option: %q", k)
+		}
+	}
+	return file.New(dsn, config.NewHash, opts...)
 }
 
-func setupMySQLStorage(dsn string, options map[string]string, logger log.Logger) (allStorage, error) {
-	opts := []mysql.Option{mysql.WithDSN(dsn)}
-	for k, v := range options {
+func newMySQLStorage(config *registry.Config) (interface{}, error) {
+	opts := []mysql.Option{mysql.WithDSN(config.DSN), mysql.WithClock(config.Clock)}
+	if config.History.Enabled() {
+		opts = append(opts, mysql.WithValueHistory(config.History.Paths, uint(config.History.Max)))
+	}
+	if config.Limits.Enabled() {
+		opts = append(opts, mysql.WithStatusLimits(config.Limits))
+	}
+	for k, v := range config.Options {
 		switch k {
 		case "delete_errors":
 			const errorDeleteOption = "error delete option"
@@ -59,7 +401,7 @@ func setupMySQLStorage(dsn string, options map[string]string, logger log.Logger)
 				return nil, fmt.Errorf("invalid value for %s: %w", errorDeleteOption, err)
 			}
 			opts = append(opts, mysql.WithErrorDeletion(uint(n)))
-			logger.Debug(logkeys.Message, errorDeleteOption, logkeys.GenericCount, int(n))
+			config.Logger.Debug(logkeys.Message, errorDeleteOption, logkeys.GenericCount, int(n))
 		case "delete_status_reports":
 			const reportDeleteOption = "status report delete option"
 			n, err := strconv.ParseUint(v, 10, 64)
@@ -67,12 +409,15 @@ func setupMySQLStorage(dsn string, options map[string]string, logger log.Logger)
 				return nil, fmt.Errorf("invalid value for %s: %w", reportDeleteOption, err)
 			}
 			opts = append(opts, mysql.WithStatusReportDeletion(uint(n)))
-			logger.Debug(logkeys.Message, reportDeleteOption, logkeys.GenericCount, int(n))
+			config.Logger.Debug(logkeys.Message, reportDeleteOption, logkeys.GenericCount, int(n))
+		case "compress":
+			opts = append(opts, mysql.WithStatusReportCompression())
+			config.Logger.Debug(logkeys.Message, "compressing status reports")
 		default:
 			return nil, fmt.Errorf("invalid option: %q", k)
 		}
 	}
-	return mysql.New(hasher, opts...)
+	return mysql.New(config.NewHash, opts...)
 }
 
 func splitOptions(s string) map[string]string {
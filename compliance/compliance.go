@@ -0,0 +1,121 @@
+// Package compliance evaluates the compliance of enrollments with the
+// requirements of their sets when they report status.
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the storage needed to evaluate compliance.
+type Storage interface {
+	storage.EnrollmentSetsRetriever
+	storage.StatusDeclarationsRetriever
+	storage.StatusValuesRetriever
+	storage.ComplianceStorage
+}
+
+// Evaluator evaluates and stores the compliance of enrollments.
+type Evaluator struct {
+	store  Storage
+	logger log.Logger
+	now    func() time.Time
+}
+
+type Option func(*Evaluator)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(e *Evaluator) {
+		e.logger = logger
+	}
+}
+
+// New creates a new Evaluator.
+func New(store Storage, opts ...Option) *Evaluator {
+	if store == nil {
+		panic("nil store")
+	}
+	e := &Evaluator{
+		store:  store,
+		logger: log.NopLogger,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Evaluate evaluates the compliance of enrollmentID with the
+// requirements of its sets using its stored status and stores it.
+func (e *Evaluator) Evaluate(ctx context.Context, enrollmentID string) (*storage.EnrollmentCompliance, error) {
+	setNames, err := e.store.RetrieveEnrollmentSets(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment sets: %w", err)
+	}
+	allRequirements, err := e.store.RetrieveComplianceRequirements(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving compliance requirements: %w", err)
+	}
+	inSet := make(map[string]bool)
+	for _, setName := range setNames {
+		inSet[setName] = true
+	}
+	var requirements []storage.ComplianceRequirement
+	for _, r := range allRequirements {
+		if inSet[r.Set] {
+			requirements = append(requirements, r)
+		}
+	}
+	statuses, err := e.store.RetrieveDeclarationStatus(ctx, []string{enrollmentID})
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declaration status: %w", err)
+	}
+	values, err := e.store.RetrieveStatusValues(ctx, []string{enrollmentID}, "")
+	if err != nil {
+		return nil, fmt.Errorf("retrieving status values: %w", err)
+	}
+	c := storage.EvaluateCompliance(enrollmentID, requirements, statuses[enrollmentID], values[enrollmentID])
+	c.Timestamp = e.now().UTC().Truncate(time.Second)
+	if err = e.store.StoreEnrollmentCompliance(ctx, c); err != nil {
+		return nil, fmt.Errorf("storing enrollment compliance: %w", err)
+	}
+	return c, nil
+}
+
+// statusStorer evaluates the compliance of enrollments that report status.
+type statusStorer struct {
+	storage.StatusStorer
+	evaluator *Evaluator
+}
+
+// StoreDeclarationStatus stores the status report and then evaluates
+// the compliance of the enrollment. Failing to evaluate compliance is
+// logged and does not fail storing the status report.
+func (s *statusStorer) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	if err := s.StatusStorer.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		return err
+	}
+	logger := ctxlog.Logger(ctx, s.evaluator.logger).With(logkeys.EnrollmentID, enrollmentID)
+	c, err := s.evaluator.Evaluate(ctx, enrollmentID)
+	if err != nil {
+		logger.Info(logkeys.Message, "evaluating compliance", logkeys.Error, err)
+		return nil
+	}
+	logger.Debug(logkeys.Message, "evaluated compliance", "compliant", c.Compliant, "score", c.Score)
+	return nil
+}
+
+// StatusStorer wraps store to evaluate the compliance of enrollments
+// when they report status.
+func (e *Evaluator) StatusStorer(store storage.StatusStorer) storage.StatusStorer {
+	return &statusStorer{StatusStorer: store, evaluator: e}
+}
@@ -0,0 +1,89 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestEvaluate(t *testing.T) {
+	ctx := context.Background()
+	const enrollmentID = "EB9DE86C-2E95-4F73-80A3-34F1D8111FA2"
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"hi"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = fs.RetrieveDeclaration(ctx, d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreSetDeclaration(ctx, "finance", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreEnrollmentSet(ctx, enrollmentID, "finance"); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []storage.ComplianceRequirement{
+		{
+			Set:          "finance",
+			Declarations: []string{d.Identifier},
+			Conditions: []storage.DeclarationCondition{{
+				Path:              ".StatusItems.device.operating-system.version",
+				StatusValueFilter: storage.StatusValueFilter{Op: storage.OpGreaterThanOrEqual, Value: "17"},
+			}},
+		},
+		// not a set of the enrollment
+		{Set: "other", Declarations: []string{"com.example.other"}},
+	} {
+		if _, err = fs.StoreComplianceRequirement(ctx, &r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New(fs).StatusStorer(fs)
+	for _, tc := range []struct {
+		active    bool
+		version   string
+		compliant bool
+		met       int
+	}{
+		{false, "16.4", false, 0},
+		{true, "16.4", false, 1},
+		{true, "17.1", true, 2},
+	} {
+		raw := fmt.Sprintf(`{"StatusItems":{"device":{"operating-system":{"version":%q}},"management":{"declarations":{"configurations":[{"identifier":%q,"active":%v,"valid":"valid","server-token":%q}]}}},"Errors":[]}`, tc.version, d.Identifier, tc.active, d.ServerToken)
+		_, status, err := ddm.ParseStatus([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = s.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+			t.Fatal(err)
+		}
+		states, err := fs.RetrieveEnrollmentCompliance(ctx, []string{enrollmentID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(states) != 1 {
+			t.Fatalf("expected 1 compliance state, have: %d", len(states))
+		}
+		c := states[0]
+		if c.Compliant != tc.compliant || c.Met != tc.met || c.Total != 2 || len(c.Failures) != 2-tc.met {
+			t.Errorf("%s: unexpected compliance: %+v", tc.version, c)
+		}
+		if have, want := c.Score, float64(tc.met)/2; have != want {
+			t.Errorf("score: have: %v, want: %v", have, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package credservice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxChallengeSize is the maximum size of a dynamic challenge response.
+const maxChallengeSize = 4096
+
+// Challenger returns SCEP challenges for enrollments.
+type Challenger interface {
+	Challenge(ctx context.Context, enrollmentID string) (string, error)
+}
+
+// StaticChallenge is the same challenge for every enrollment.
+type StaticChallenge string
+
+// Challenge returns c.
+func (c StaticChallenge) Challenge(_ context.Context, _ string) (string, error) {
+	return string(c), nil
+}
+
+// HTTPChallenger retrieves a challenge per enrollment from a dynamic
+// challenge service. The service is sent a POST request with a JSON
+// object body of the "enrollment_id" and responds with the challenge
+// as the plain text body.
+type HTTPChallenger struct {
+	client *http.Client
+	url    string
+	token  string
+}
+
+// NewHTTPChallenger creates a new dynamic challenger of the service at
+// challengeURL using the optional bearer token.
+func NewHTTPChallenger(challengeURL, token string) (*HTTPChallenger, error) {
+	u, err := url.Parse(challengeURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing challenge URL: %w", err)
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid challenge URL: %q", challengeURL)
+	}
+	return &HTTPChallenger{client: http.DefaultClient, url: challengeURL, token: token}, nil
+}
+
+// Challenge retrieves a challenge for enrollmentID.
+func (c *HTTPChallenger) Challenge(ctx context.Context, enrollmentID string) (string, error) {
+	body, err := json.Marshal(map[string]string{"enrollment_id": enrollmentID})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("challenge: unexpected HTTP status: %s", resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxChallengeSize))
+	if err != nil {
+		return "", fmt.Errorf("reading challenge: %w", err)
+	}
+	challenge := strings.TrimSpace(string(b))
+	if challenge == "" {
+		return "", errors.New("empty challenge")
+	}
+	return challenge, nil
+}
+
+// newChallenger creates the Challenger of config.
+func newChallenger(config *ChallengeConfig) (Challenger, error) {
+	switch {
+	case config.URL != "" && config.Env != "":
+		return nil, errors.New("both challenge env and url configured")
+	case config.URL != "":
+		var token string
+		if config.TokenEnv != "" {
+			token = os.Getenv(config.TokenEnv)
+		}
+		return NewHTTPChallenger(config.URL, token)
+	case config.Env != "":
+		challenge := os.Getenv(config.Env)
+		if challenge == "" {
+			return nil, fmt.Errorf("empty challenge env: %s", config.Env)
+		}
+		return StaticChallenge(challenge), nil
+	}
+	return nil, errors.New("no challenge env or url configured")
+}
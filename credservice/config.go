@@ -0,0 +1,96 @@
+package credservice
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ChallengeConfig configures the SCEP challenge of a service.
+// One of Env or URL is required.
+type ChallengeConfig struct {
+	// Env is the name of the environment variable containing a static challenge.
+	Env string `json:"env,omitempty"`
+
+	// URL is the URL of a dynamic challenge service.
+	URL string `json:"url,omitempty"`
+
+	// TokenEnv is the name of the environment variable containing the
+	// bearer token of the dynamic challenge service.
+	TokenEnv string `json:"token_env,omitempty"`
+}
+
+// ServiceConfig configures a SCEP or ACME service.
+// Exactly one of SCEP or ACME is required.
+type ServiceConfig struct {
+	Name string `json:"name"`
+
+	// SCEP is the JSON object of the SCEP credential data. It requires
+	// a "URL" and has the keys of the SCEP payload.
+	SCEP json.RawMessage `json:"scep,omitempty"`
+
+	// ACME is the JSON object of the ACME credential data. It requires
+	// a "DirectoryURL" and a "ClientIdentifier".
+	ACME json.RawMessage `json:"acme,omitempty"`
+
+	// Challenge injects a challenge into SCEP credential data.
+	Challenge *ChallengeConfig `json:"challenge,omitempty"`
+}
+
+// Config is the credential services configuration.
+type Config struct {
+	// URL is the base URL that enrollments use to download credential
+	// data (e.g. https://kmfddm.example.com/credential-service).
+	URL string `json:"url"`
+
+	// SecretEnv is the name of the environment variable containing the
+	// secret that enrollment tokens are derived from.
+	SecretEnv string `json:"secret_env"`
+
+	Services []ServiceConfig `json:"services"`
+}
+
+// ReadConfig reads and validates a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding credential services config: %w", err)
+	}
+	if c.URL == "" {
+		return nil, errors.New("empty URL")
+	}
+	if c.SecretEnv == "" {
+		return nil, errors.New("empty secret env")
+	}
+	if len(c.Services) < 1 {
+		return nil, errors.New("no services")
+	}
+	for i, svc := range c.Services {
+		if err := storage.ValidateIdentifier("service name", svc.Name); err != nil {
+			return nil, fmt.Errorf("service %d: %w", i, err)
+		}
+		if (svc.SCEP == nil) == (svc.ACME == nil) {
+			return nil, fmt.Errorf("service %s: exactly one of scep or acme required", svc.Name)
+		}
+		if svc.Challenge != nil && svc.SCEP == nil {
+			return nil, fmt.Errorf("service %s: challenge requires scep", svc.Name)
+		}
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads and validates a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
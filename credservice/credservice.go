@@ -0,0 +1,167 @@
+// Package credservice generates SCEP and ACME credential asset
+// declarations and serves their per-enrollment credential data.
+//
+// Declarations reference the credential data of a service with a URL
+// containing the transform.EnrollmentIDPlaceholder and TokenPlaceholder
+// templates. When served to an enrollment the templates are replaced
+// (see Transformer) so that each enrollment downloads its own data: the
+// configured SCEP or ACME request with the enrollment ID templated and,
+// for SCEP, a challenge injected.
+package credservice
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/builder"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/transform"
+)
+
+// TokenPlaceholder is replaced with the enrollment token by the
+// Transformer of Services.
+const TokenPlaceholder = "${CredentialServiceToken}"
+
+var (
+	// ErrServiceNotFound is returned when a service is not configured.
+	ErrServiceNotFound = storage.Categorize(storage.ErrNotFound, errors.New("credential service not found"))
+
+	// ErrInvalidToken is returned when an enrollment token is invalid.
+	// It is not found so as not to disclose the enrollment.
+	ErrInvalidToken = storage.Categorize(storage.ErrNotFound, errors.New("invalid credential service token"))
+)
+
+type service struct {
+	acme       bool
+	data       []byte
+	challenger Challenger
+}
+
+// Services are the configured SCEP and ACME services.
+type Services struct {
+	url      string
+	secret   []byte
+	services map[string]*service
+}
+
+// New creates the Services of config.
+func New(config *Config) (*Services, error) {
+	if config == nil {
+		return nil, errors.New("nil config")
+	}
+	s := &Services{
+		url:      strings.TrimSuffix(config.URL, "/"),
+		secret:   []byte(os.Getenv(config.SecretEnv)),
+		services: make(map[string]*service),
+	}
+	if len(s.secret) < 16 {
+		return nil, fmt.Errorf("secret env %s must be at least 16 bytes", config.SecretEnv)
+	}
+	for _, svcConfig := range config.Services {
+		svc := &service{acme: svcConfig.ACME != nil, data: svcConfig.SCEP}
+		required := []string{"URL"}
+		if svc.acme {
+			svc.data = svcConfig.ACME
+			required = []string{"DirectoryURL", "ClientIdentifier"}
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(svc.data, &data); err != nil {
+			return nil, fmt.Errorf("service %s: decoding data: %w", svcConfig.Name, err)
+		}
+		for _, key := range required {
+			if v, _ := data[key].(string); v == "" {
+				return nil, fmt.Errorf("service %s: missing %s", svcConfig.Name, key)
+			}
+		}
+		if svcConfig.Challenge != nil {
+			var err error
+			if svc.challenger, err = newChallenger(svcConfig.Challenge); err != nil {
+				return nil, fmt.Errorf("service %s: %w", svcConfig.Name, err)
+			}
+		}
+		s.services[svcConfig.Name] = svc
+	}
+	return s, nil
+}
+
+// Names returns the sorted names of the services.
+func (s *Services) Names() []string {
+	names := make([]string, 0, len(s.services))
+	for name := range s.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Token returns the token that authorizes enrollmentID to download its credential data.
+func (s *Services) Token(enrollmentID string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(enrollmentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Transformer returns a transformer that replaces TokenPlaceholder
+// with the token of the enrollment. The transform.EnrollmentIDTemplate
+// transformer is also required for the credential data URLs.
+func (s *Services) Transformer() transform.Transformer {
+	return transform.Template(TokenPlaceholder, func(_ context.Context, enrollmentID string) (string, error) {
+		return s.Token(enrollmentID), nil
+	})
+}
+
+// Payload returns the credential asset declaration payload of the service name.
+func (s *Services) Payload(name string) (builder.Payload, error) {
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+	ref := builder.AssetReference{
+		DataURL:     s.url + "/" + url.PathEscape(name) + "/" + transform.EnrollmentIDPlaceholder + "/" + TokenPlaceholder,
+		ContentType: builder.JSONContentType,
+	}
+	if svc.acme {
+		return &builder.CredentialACME{Reference: ref}, nil
+	}
+	return &builder.CredentialSCEP{Reference: ref}, nil
+}
+
+// Data returns the credential data JSON of the service name for
+// enrollmentID. The transform.EnrollmentIDPlaceholder is replaced in
+// the string values of the data and the challenge, if any, is injected.
+// ErrInvalidToken is returned if token is not the enrollment's token.
+func (s *Services) Data(ctx context.Context, name, enrollmentID, token string) ([]byte, error) {
+	svc, ok := s.services[name]
+	if !ok {
+		return nil, ErrServiceNotFound
+	}
+	if !hmac.Equal([]byte(token), []byte(s.Token(enrollmentID))) {
+		return nil, ErrInvalidToken
+	}
+	dec := json.NewDecoder(bytes.NewReader(svc.data))
+	// keep numbers as they were
+	dec.UseNumber()
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, fmt.Errorf("decoding data: %w", err)
+	}
+	transform.ReplaceStrings(data, transform.EnrollmentIDPlaceholder, enrollmentID)
+	if svc.challenger != nil {
+		challenge, err := svc.challenger.Challenge(ctx, enrollmentID)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving challenge: %w", err)
+		}
+		data["Challenge"] = challenge
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,143 @@
+package credservice
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/builder"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage/file"
+	"github.com/jessepeterson/kmfddm/transform"
+)
+
+func TestServices(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			EnrollmentID string `json:"enrollment_id"`
+		}
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "challenge-"+req.EnrollmentID+"\n")
+	}))
+	defer srv.Close()
+
+	t.Setenv("CREDSERVICE_TEST_SECRET", "0123456789abcdef")
+	t.Setenv("CREDSERVICE_TEST_TOKEN", "s3cr3t")
+	config, err := ReadConfig(strings.NewReader(`{
+		"url": "https://kmfddm.example.com/credential-service/",
+		"secret_env": "CREDSERVICE_TEST_SECRET",
+		"services": [
+			{
+				"name": "corp-scep",
+				"scep": {"URL": "https://scep.example.com/scep", "Subject": [[["CN", "${EnrollmentID}"]]], "KeySize": 2048},
+				"challenge": {"url": "` + srv.URL + `", "token_env": "CREDSERVICE_TEST_TOKEN"}
+			},
+			{
+				"name": "corp-acme",
+				"acme": {"DirectoryURL": "https://acme.example.com/directory", "ClientIdentifier": "${EnrollmentID}"}
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := strings.Join(s.Names(), ","), "corp-acme,corp-scep"; have != want {
+		t.Errorf("names: have: %v, want: %v", have, want)
+	}
+
+	const enrollmentID = "EB9DE86C-2E95-4F73-80A3-34F1D8111FA2"
+	ctx := context.Background()
+	p, err := s.Payload("corp-scep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := builder.Build("com.example.scep", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// serve the declaration through the transformers
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreSetDeclaration(ctx, "default", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreEnrollmentSet(ctx, enrollmentID, "default"); err != nil {
+		t.Fatal(err)
+	}
+	ts := transform.New(fs, func() hash.Hash { return xxhash.New() }, transform.EnrollmentIDTemplate(), s.Transformer())
+	raw, err := ts.RetrieveEnrollmentDeclarationJSON(ctx, d.Identifier, "asset", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d, err = ddm.ParseDeclaration(raw); err != nil {
+		t.Fatal(err)
+	}
+	scep := new(builder.CredentialSCEP)
+	if err = json.Unmarshal(d.PayloadJSON, scep); err != nil {
+		t.Fatal(err)
+	}
+	token := s.Token(enrollmentID)
+	if have, want := scep.Reference.DataURL, "https://kmfddm.example.com/credential-service/corp-scep/"+enrollmentID+"/"+token; have != want {
+		t.Errorf("data URL: have: %v, want: %v", have, want)
+	}
+
+	b, err := s.Data(ctx, "corp-scep", enrollmentID, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(b), `{"Challenge":"challenge-`+enrollmentID+`","KeySize":2048,"Subject":[[["CN","`+enrollmentID+`"]]],"URL":"https://scep.example.com/scep"}`; have != want {
+		t.Errorf("data: have: %v, want: %v", have, want)
+	}
+	if b, err = s.Data(ctx, "corp-acme", enrollmentID, token); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(b), `{"ClientIdentifier":"`+enrollmentID+`","DirectoryURL":"https://acme.example.com/directory"}`; have != want {
+		t.Errorf("data: have: %v, want: %v", have, want)
+	}
+
+	if _, err = s.Data(ctx, "corp-scep", "other", token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("have: %v, want: %v", err, ErrInvalidToken)
+	}
+	if _, err = s.Data(ctx, "missing", enrollmentID, token); !errors.Is(err, ErrServiceNotFound) {
+		t.Errorf("have: %v, want: %v", err, ErrServiceNotFound)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+	}{
+		{"no services", `{"url":"https://example.com","secret_env":"S","services":[]}`},
+		{"no url", `{"secret_env":"S","services":[{"name":"s","scep":{}}]}`},
+		{"no secret", `{"url":"https://example.com","services":[{"name":"s","scep":{}}]}`},
+		{"invalid name", `{"url":"https://example.com","secret_env":"S","services":[{"name":"a/b","scep":{}}]}`},
+		{"both", `{"url":"https://example.com","secret_env":"S","services":[{"name":"s","scep":{},"acme":{}}]}`},
+		{"acme challenge", `{"url":"https://example.com","secret_env":"S","services":[{"name":"s","acme":{},"challenge":{"env":"C"}}]}`},
+		{"unknown field", `{"url":"https://example.com","secret_env":"S","services":[{"name":"s","scep":{}}],"x":1}`},
+	} {
+		if _, err := ReadConfig(strings.NewReader(tc.config)); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
@@ -8,7 +8,13 @@ import (
 	"github.com/valyala/fastjson"
 )
 
-var ErrInvalidDeclaration = errors.New("invalid declaration")
+var (
+	ErrInvalidDeclaration = errors.New("invalid declaration")
+	ErrTooLarge           = errors.New("too large")
+)
+
+// MaxDeclarationSize is the maximum size in bytes of declaration JSON.
+const MaxDeclarationSize = 1 << 20
 
 type Declaration struct {
 	Identifier  string
@@ -99,7 +105,11 @@ func parseDeclarationValue(v *fastjson.Value, d *Declaration) error {
 }
 
 // ParseDeclaration parses raw into a Declaration structure.
+// Declarations larger than MaxDeclarationSize are rejected.
 func ParseDeclaration(raw []byte) (*Declaration, error) {
+	if len(raw) > MaxDeclarationSize {
+		return nil, fmt.Errorf("declaration %w: %d bytes", ErrTooLarge, len(raw))
+	}
 	v, err := fastjson.ParseBytes(raw)
 	if err != nil {
 		return nil, fmt.Errorf("parsing json: %w", err)
@@ -1,6 +1,8 @@
 package ddm
 
 import (
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -117,3 +119,20 @@ func TestIDRefs2(t *testing.T) {
 		}
 	}
 }
+
+func TestParseDeclarationHostile(t *testing.T) {
+	deep := `{"Identifier":"a","Type":"b","Payload":` + strings.Repeat(`{"a":`, 100000) + `1` + strings.Repeat(`}`, 100000) + `}`
+	if _, err := ParseDeclaration([]byte(deep)); err == nil {
+		t.Error("expected error for deeply nested declaration")
+	}
+
+	huge := `{"Identifier":"a","Type":"b","Payload":{"a":"` + strings.Repeat("a", MaxDeclarationSize) + `"}}`
+	if _, err := ParseDeclaration([]byte(huge)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected too large error, got: %v", err)
+	}
+
+	for _, raw := range []string{`[]`, `1`, `"a"`, `null`, `{"Payload":null}`, `{"Type":"com.apple.activation.simple","Payload":{"StandardConfigurations":{}}}`} {
+		// should not panic
+		ParseDeclaration([]byte(raw))
+	}
+}
@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package ddm
+
+import (
+	"os"
+	"testing"
+)
+
+func FuzzParseDeclaration(f *testing.F) {
+	for _, seed := range []string{declTest1, declTest2, declActTest1, `{}`, `[]`, `{"Payload":[]}`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		d, err := ParseDeclaration(raw)
+		if err != nil {
+			return
+		}
+		if d.Valid() && d.Identifier == "" {
+			t.Error("valid declaration with empty identifier")
+		}
+	})
+}
+
+func FuzzParseStatus(f *testing.F) {
+	seed, err := os.ReadFile("testdata/A047820F-FC6B-4104-BED0-466876D82BB8.20220628-103225.001918.status.json")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(seed)
+	for _, seed := range []string{`{}`, `[]`, `{"StatusItems":{"management":{"declarations":{"activations":[1]}}}}`, `{"Errors":{}}`} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, raw []byte) {
+		ParseStatus(raw)
+	})
+}
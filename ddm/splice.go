@@ -0,0 +1,99 @@
+package ddm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// serverTokenSpans returns the byte spans of the top-level ServerToken
+// members of the JSON object raw and the offset of the end of its last
+// other member (or of its opening brace if it has none).
+// Each span includes the comma that separates the member from the
+// previous (or for the first member, the next) member.
+func serverTokenSpans(raw []byte) (spans [][2]int, lastEnd int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil {
+		return nil, 0, err
+	} else if t != json.Delim('{') {
+		return nil, 0, errors.New("not a JSON object")
+	}
+	prevEnd := int(dec.InputOffset())
+	lastEnd = prevEnd
+	for i := 0; dec.More(); i++ {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		var value json.RawMessage
+		if err = dec.Decode(&value); err != nil {
+			return nil, 0, err
+		}
+		end := int(dec.InputOffset())
+		if t != "ServerToken" {
+			lastEnd, prevEnd = end, end
+			continue
+		}
+		span := [2]int{prevEnd, end}
+		if i == 0 {
+			// no preceding comma so take the following one (if any)
+			if next := bytes.IndexByte(raw[end:], ','); next >= 0 && len(bytes.TrimSpace(raw[end:end+next])) == 0 {
+				span[1] = end + next + 1
+			}
+			// and the following member is now the first
+			i = -1
+		}
+		spans = append(spans, span)
+		prevEnd = span[1]
+	}
+	return spans, lastEnd, nil
+}
+
+// RemoveServerToken returns the declaration JSON raw without its
+// top-level ServerToken. Unlike re-marshaling the declaration the
+// remaining bytes of raw (the key order, number formatting, and
+// whitespace) are kept as-is.
+func RemoveServerToken(raw []byte) ([]byte, error) {
+	spans, _, err := serverTokenSpans(raw)
+	if err != nil {
+		return nil, fmt.Errorf("finding server token: %w", err)
+	}
+	if len(spans) < 1 {
+		return raw, nil
+	}
+	out := make([]byte, 0, len(raw))
+	var pos int
+	for _, span := range spans {
+		out = append(out, raw[pos:span[0]]...)
+		pos = span[1]
+	}
+	return append(out, raw[pos:]...), nil
+}
+
+// SpliceServerToken returns the declaration JSON raw with its
+// top-level ServerToken set to token. Any existing ServerToken is
+// removed and the new one is appended as the last member. The remaining
+// bytes of raw are kept as-is.
+func SpliceServerToken(raw []byte, token string) ([]byte, error) {
+	raw, err := RemoveServerToken(raw)
+	if err != nil {
+		return nil, err
+	}
+	_, lastEnd, err := serverTokenSpans(raw)
+	if err != nil {
+		return nil, fmt.Errorf("finding server token: %w", err)
+	}
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return nil, err
+	}
+	member := append([]byte(`"ServerToken":`), tokenJSON...)
+	if !bytes.HasSuffix(bytes.TrimSpace(raw[:lastEnd]), []byte{'{'}) {
+		member = append([]byte{','}, member...)
+	}
+	out := make([]byte, 0, len(raw)+len(member))
+	out = append(out, raw[:lastEnd]...)
+	out = append(out, member...)
+	return append(out, raw[lastEnd:]...), nil
+}
@@ -0,0 +1,71 @@
+package ddm
+
+import (
+	"testing"
+)
+
+func TestSpliceServerToken(t *testing.T) {
+	for _, test := range []struct {
+		raw     string
+		removed string
+		spliced string
+	}{
+		{
+			raw:     `{}`,
+			removed: `{}`,
+			spliced: `{"ServerToken":"tok"}`,
+		},
+		{
+			raw:     `{"Type":"a","Payload":{"b":1.50}}`,
+			removed: `{"Type":"a","Payload":{"b":1.50}}`,
+			spliced: `{"Type":"a","Payload":{"b":1.50},"ServerToken":"tok"}`,
+		},
+		{
+			raw:     `{"ServerToken":"old","Type":"a","Identifier":"b"}`,
+			removed: `{"Type":"a","Identifier":"b"}`,
+			spliced: `{"Type":"a","Identifier":"b","ServerToken":"tok"}`,
+		},
+		{
+			raw:     "{\n  \"Type\": \"a\",\n  \"ServerToken\": \"old\",\n  \"Identifier\": \"b\"\n}\n",
+			removed: "{\n  \"Type\": \"a\",\n  \"Identifier\": \"b\"\n}\n",
+			spliced: "{\n  \"Type\": \"a\",\n  \"Identifier\": \"b\",\"ServerToken\":\"tok\"\n}\n",
+		},
+		{
+			raw:     `{ "ServerToken" : "old" }`,
+			removed: `{ }`,
+			spliced: `{"ServerToken":"tok" }`,
+		},
+		{
+			raw:     `{"ServerToken":"a","ServerToken":"b","Type":"a"}`,
+			removed: `{"Type":"a"}`,
+			spliced: `{"Type":"a","ServerToken":"tok"}`,
+		},
+		{
+			raw:     `{"Type":"a","Payload":{"ServerToken":"nested"},"ServerToken":"old"}`,
+			removed: `{"Type":"a","Payload":{"ServerToken":"nested"}}`,
+			spliced: `{"Type":"a","Payload":{"ServerToken":"nested"},"ServerToken":"tok"}`,
+		},
+	} {
+		removed, err := RemoveServerToken([]byte(test.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := string(removed), test.removed; have != want {
+			t.Errorf("removed: have: %q, want: %q", have, want)
+		}
+		spliced, err := SpliceServerToken([]byte(test.raw), "tok")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := string(spliced), test.spliced; have != want {
+			t.Errorf("spliced: have: %q, want: %q", have, want)
+		}
+		if _, err = ParseDeclaration(spliced); err != nil {
+			t.Errorf("parsing spliced: %v", err)
+		}
+	}
+
+	if _, err := RemoveServerToken([]byte(`[]`)); err == nil {
+		t.Error("expected error for non-object")
+	}
+}
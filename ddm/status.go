@@ -1,6 +1,7 @@
 package ddm
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -13,8 +14,19 @@ const (
 	pathManagement   = ".StatusItems.management."
 	pathDevice       = ".StatusItems.device."
 	pathErrors       = ".Errors"
+	pathStatusItems  = ".StatusItems"
 )
 
+// Paths of the sections of status reports.
+const (
+	StatusPathDeclarations = pathDeclarations
+	StatusPathErrors       = pathErrors
+	StatusPathItems        = pathStatusItems
+)
+
+// MaxStatusReportSize is the maximum size in bytes of status report JSON.
+const MaxStatusReportSize = 16 << 20
+
 // DeclarationStatus is a representation of the status of declarations.
 // See https://developer.apple.com/documentation/devicemanagement/statusmanagementdeclarationsdeclarationobject
 type DeclarationStatus struct {
@@ -35,6 +47,10 @@ type DeclarationQueryStatus struct {
 	StatusReceived time.Time   `json:"status_received"`
 	StatusID       string      `json:"status_id,omitempty"`
 	Reasons        interface{} `json:"reasons,omitempty"`
+
+	// Unassigned is true if the enrollment reported status for a
+	// declaration that is no longer assigned to it by way of its sets.
+	Unassigned bool `json:"unassigned,omitempty"`
 }
 
 // StatusValue contains parsed status values. These are, essentially,
@@ -65,9 +81,40 @@ type StatusReport struct {
 
 	// the raw JSON bytes of the status report
 	Raw []byte
+
+	// Quarantined are the fragments of the status report that failed
+	// to parse or store. The rest of the status report is still used.
+	Quarantined []StatusFragment
+
+	// DroppedValues and DroppedErrors count the values and errors
+	// dropped by storage limits when the status report was stored.
+	DroppedValues int
+	DroppedErrors int
 }
 
-func parseStatusDeclarations(v *fastjson.Value) ([]DeclarationStatus, []StatusError, error) {
+// StatusFragment is a fragment of a status report that failed to parse
+// or store. It is quarantined for inspection rather than failing the
+// entire status report.
+type StatusFragment struct {
+	// Path is the path of the fragment in the status report.
+	Path  string
+	Error string
+	JSON  []byte
+}
+
+// quarantine appends the fragment v at path, which failed with err.
+func (s *StatusReport) quarantine(path string, v *fastjson.Value, err error) {
+	s.Quarantined = append(s.Quarantined, StatusFragment{
+		Path:  path,
+		Error: err.Error(),
+		JSON:  v.MarshalTo(nil),
+	})
+}
+
+// parseStatusDeclarations parses the declarations status object v at
+// path. The declarations of a manifest type that fail to parse are
+// quarantined in s.
+func parseStatusDeclarations(s *StatusReport, path string, v *fastjson.Value) ([]DeclarationStatus, []StatusError, error) {
 	o, err := v.Object()
 	if err != nil {
 		return nil, nil, err
@@ -75,9 +122,16 @@ func parseStatusDeclarations(v *fastjson.Value) ([]DeclarationStatus, []StatusEr
 	var decls []DeclarationStatus
 	var errs []StatusError
 	o.Visit(func(k []byte, v *fastjson.Value) {
-		var a []*fastjson.Value
-		a, err = v.Array()
+		a, err := v.Array()
+		if err != nil {
+			s.quarantine(path+"."+string(k), v, err)
+			return
+		}
 		for _, v := range a {
+			if v.Type() != fastjson.TypeObject {
+				s.quarantine(path+"."+string(k), v, errors.New("declaration status is not an object"))
+				continue
+			}
 			var reasonsJSON []byte
 			if rV := v.Get("reasons"); rV != nil {
 				reasonsJSON = rV.MarshalTo(nil)
@@ -101,7 +155,7 @@ func parseStatusDeclarations(v *fastjson.Value) ([]DeclarationStatus, []StatusEr
 			}
 		}
 	})
-	return decls, errs, err
+	return decls, errs, nil
 }
 
 func parseErrors(v *fastjson.Value) ([]StatusError, error) {
@@ -127,6 +181,9 @@ func parseStatusReportValue(v *fastjson.Value, values *[]StatusValue, path, cont
 			return err
 		}
 		o.Visit(func(k []byte, v *fastjson.Value) {
+			if err != nil {
+				return
+			}
 			newPath := path + "." + string(k)
 			err = parseStatusReportValue(v, values, newPath, "object")
 		})
@@ -178,24 +235,38 @@ func parseStatusReportValue(v *fastjson.Value, values *[]StatusValue, path, cont
 
 func valueHandler(s *StatusReport) jsonpath.HandlerFunc {
 	return func(path string, v *fastjson.Value) ([]string, error) {
-		return nil, parseStatusReportValue(v, &s.Values, path, "object")
+		n := len(s.Values)
+		if err := parseStatusReportValue(v, &s.Values, path, "object"); err != nil {
+			// discard any partially parsed values
+			s.Values = s.Values[:n]
+			s.quarantine(path, v, err)
+		}
+		return nil, nil
 	}
 }
 
 func declarationHandler(s *StatusReport) jsonpath.HandlerFunc {
 	return func(path string, v *fastjson.Value) ([]string, error) {
-		declarationStatus, declarationErrors, err := parseStatusDeclarations(v)
+		declarationStatus, declarationErrors, err := parseStatusDeclarations(s, path, v)
+		if err != nil {
+			s.quarantine(path, v, err)
+			return nil, nil
+		}
 		s.Declarations = declarationStatus
 		s.Errors = append(s.Errors, declarationErrors...)
-		return nil, err
+		return nil, nil
 	}
 }
 
 func errorHandler(s *StatusReport) jsonpath.HandlerFunc {
 	return func(path string, v *fastjson.Value) ([]string, error) {
 		statusErrors, err := parseErrors(v)
+		if err != nil {
+			s.quarantine(path, v, err)
+			return nil, nil
+		}
 		s.Errors = append(s.Errors, statusErrors...)
-		return nil, err
+		return nil, nil
 	}
 }
 
@@ -209,7 +280,13 @@ func newMux(s *StatusReport) *jsonpath.PathMux {
 }
 
 // ParseStatus parses the status report from a DDM client.
+// Status reports larger than MaxStatusReportSize are rejected.
+// Sections of the status report that fail to parse are quarantined in
+// the returned status report rather than returning an error.
 func ParseStatus(raw []byte) ([]string, *StatusReport, error) {
+	if len(raw) > MaxStatusReportSize {
+		return nil, nil, fmt.Errorf("status report %w: %d bytes", ErrTooLarge, len(raw))
+	}
 	v, err := fastjson.ParseBytes(raw)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing json: %w", err)
@@ -2,6 +2,7 @@ package ddm
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"strings"
 	"testing"
@@ -77,3 +78,52 @@ func TestStatusParse(t *testing.T) {
 		t.Errorf("invalid number of declarations: want %d, have %d", want, len(s.Declarations))
 	}
 }
+
+func TestParseStatusHostile(t *testing.T) {
+	deep := `{"StatusItems":{"device":` + strings.Repeat(`[`, 100000) + strings.Repeat(`]`, 100000) + `}}`
+	if _, _, err := ParseStatus([]byte(deep)); err == nil {
+		t.Error("expected error for deeply nested status report")
+	}
+
+	huge := `{"StatusItems":{"device":{"a":"` + strings.Repeat("a", MaxStatusReportSize) + `"}}}`
+	if _, _, err := ParseStatus([]byte(huge)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected too large error, got: %v", err)
+	}
+
+	// a non-array manifest type is quarantined while valid ones are parsed
+	bad := `{"StatusItems":{"management":{"declarations":{"activations":{},"configurations":[{"identifier":"a","active":true,"valid":"valid"}]}}}}`
+	_, s, err := ParseStatus([]byte(bad))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(s.Declarations), 1; have != want {
+		t.Errorf("declarations: have %d, want %d", have, want)
+	}
+	if have, want := len(s.Quarantined), 1; have != want {
+		t.Fatalf("quarantined: have %d, want %d", have, want)
+	}
+	if have, want := s.Quarantined[0].Path, ".StatusItems.management.declarations.activations"; have != want {
+		t.Errorf("quarantined path: have %s, want %s", have, want)
+	}
+}
+
+func TestParseStatusQuarantine(t *testing.T) {
+	partial := `{"StatusItems":{"device":{"model":{"family":"iPhone"}},"management":{"declarations":["x"]}},"Errors":{"not":"an array"}}`
+	_, s, err := ParseStatus([]byte(partial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(s.Values), 1; have != want {
+		t.Errorf("values: have %d, want %d", have, want)
+	}
+	var paths []string
+	for _, f := range s.Quarantined {
+		if f.Error == "" || len(f.JSON) < 1 {
+			t.Errorf("incomplete quarantined fragment: %v", f)
+		}
+		paths = append(paths, f.Path)
+	}
+	if have, want := strings.Join(paths, ","), StatusPathDeclarations+","+StatusPathErrors; have != want {
+		t.Errorf("quarantined paths: have %s, want %s", have, want)
+	}
+}
@@ -0,0 +1,251 @@
+// Package ddmindex is an in-memory read model of the DDM served to enrollments.
+//
+// The index caches the tokens, declaration items, and declarations
+// resolved for each enrollment by way of its sets so that frequently
+// synchronizing enrollments do not read from storage each time.
+// Entries are rebuilt from storage after they expire or when they are
+// invalidated by writes.
+package ddmindex
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// DefaultMaxEntries is the default maximum number of indexed enrollments.
+const DefaultMaxEntries = 100000
+
+// Storage is the DDM protocol storage that Index wraps.
+type Storage interface {
+	storage.DeclarationRetriever
+	storage.TokensDeclarationItemsRetriever
+}
+
+// entry is the indexed DDM of a single enrollment.
+type entry struct {
+	created time.Time
+	tokens  []byte
+	di      []byte
+
+	// declarations are the declaration JSON keyed by identifier and type.
+	declarations map[[2]string][]byte
+}
+
+// Index serves the DDM of enrollments from memory, reading from an
+// underlying Storage on a miss. It implements Storage itself.
+// It is safe for concurrent use.
+type Index struct {
+	store  Storage
+	ttl    time.Duration
+	max    int
+	logger log.Logger
+
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	// version increments with every invalidation. Entries read from
+	// storage are only indexed if no invalidation happened meanwhile.
+	version uint64
+}
+
+// Option configures an Index.
+type Option func(*Index)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(i *Index) {
+		i.logger = logger
+	}
+}
+
+// WithTTL rebuilds the indexed DDM of an enrollment after ttl.
+// A zero ttl never expires entries.
+func WithTTL(ttl time.Duration) Option {
+	return func(i *Index) {
+		i.ttl = ttl
+	}
+}
+
+// WithMaxEntries limits the number of indexed enrollments to max.
+// The index is emptied when it grows past max.
+func WithMaxEntries(max int) Option {
+	return func(i *Index) {
+		i.max = max
+	}
+}
+
+// New creates a new Index of the DDM served by store.
+func New(store Storage, opts ...Option) *Index {
+	if store == nil {
+		panic("nil store")
+	}
+	i := &Index{
+		store:   store,
+		max:     DefaultMaxEntries,
+		logger:  log.NopLogger,
+		entries: make(map[string]*entry),
+	}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// lookup returns the valid entry of enrollmentID (if any) and the
+// current version. The caller must hold the lock.
+func (i *Index) lookup(enrollmentID string) (*entry, uint64) {
+	e := i.entries[enrollmentID]
+	if e != nil && i.ttl > 0 && time.Since(e.created) > i.ttl {
+		e = nil
+	}
+	return e, i.version
+}
+
+// read returns the indexed value of enrollmentID selected by get.
+// On a miss the value is read with fill and indexed using set.
+func (i *Index) read(ctx context.Context, enrollmentID string, get func(*entry) []byte, set func(*entry, []byte), fill func() ([]byte, error)) ([]byte, error) {
+	i.mu.RLock()
+	e, version := i.lookup(enrollmentID)
+	var b []byte
+	if e != nil {
+		b = get(e)
+	}
+	i.mu.RUnlock()
+	if b != nil {
+		return b, nil
+	}
+
+	b, err := fill()
+	if err != nil {
+		return nil, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.version != version {
+		// invalidated while reading storage; don't index stale data
+		return b, nil
+	}
+	if e, _ = i.lookup(enrollmentID); e == nil {
+		if i.max > 0 && len(i.entries) >= i.max {
+			ctxlog.Logger(ctx, i.logger).Debug(logkeys.Message, "index full; emptying", logkeys.GenericCount, len(i.entries))
+			i.entries = make(map[string]*entry)
+		}
+		e = &entry{created: time.Now()}
+		i.entries[enrollmentID] = e
+	}
+	set(e, b)
+	return b, nil
+}
+
+// RetrieveEnrollmentDeclarationJSON retrieves the declaration JSON for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (i *Index) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	key := [2]string{declarationID, declarationType}
+	return i.read(
+		ctx,
+		enrollmentID,
+		func(e *entry) []byte { return e.declarations[key] },
+		func(e *entry, b []byte) {
+			if e.declarations == nil {
+				e.declarations = make(map[[2]string][]byte)
+			}
+			e.declarations[key] = b
+		},
+		func() ([]byte, error) {
+			return i.store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
+		},
+	)
+}
+
+// RetrieveDeclarationItemsJSON retrieves the Declaration Items for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (i *Index) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	return i.read(
+		ctx,
+		enrollmentID,
+		func(e *entry) []byte { return e.di },
+		func(e *entry, b []byte) { e.di = b },
+		func() ([]byte, error) { return i.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID) },
+	)
+}
+
+// RetrieveTokensJSON retrieves the Sync Tokens for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (i *Index) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	return i.read(
+		ctx,
+		enrollmentID,
+		func(e *entry) []byte { return e.tokens },
+		func(e *entry, b []byte) { e.tokens = b },
+		func() ([]byte, error) { return i.store.RetrieveTokensJSON(ctx, enrollmentID) },
+	)
+}
+
+// Flush empties the index.
+func (i *Index) Flush() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.entries = make(map[string]*entry)
+	i.version++
+}
+
+// Invalidate removes the indexed DDM affected by changes to
+// declarations, sets, and enrollment ids. As the index does not know
+// which enrollments changed declarations and sets affect the entire
+// index is emptied if any are given.
+func (i *Index) Invalidate(_ context.Context, declarations []string, sets []string, ids []string) {
+	if len(declarations) > 0 || len(sets) > 0 {
+		i.Flush()
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, id := range ids {
+		delete(i.entries, id)
+	}
+	i.version++
+}
+
+// Middleware empties the index after every API request that may have
+// changed storage (i.e. that is not a GET, HEAD, or OPTIONS request).
+// This invalidates changes made without notifying enrollments.
+func (i *Index) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			i.Flush()
+		}
+	})
+}
+
+// statusStorer invalidates the indexed DDM of enrollments that report status.
+type statusStorer struct {
+	storage.StatusStorer
+	index *Index
+}
+
+// StoreDeclarationStatus stores the status report and invalidates the
+// indexed DDM of the enrollment as its status values may change the
+// declarations it is served by way of set declaration conditions.
+func (s *statusStorer) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	err := s.StatusStorer.StoreDeclarationStatus(ctx, enrollmentID, status)
+	s.index.Invalidate(ctx, nil, nil, []string{enrollmentID})
+	return err
+}
+
+// StatusStorer wraps store to invalidate the indexed DDM of
+// enrollments when they report status.
+func (i *Index) StatusStorer(store storage.StatusStorer) storage.StatusStorer {
+	return &statusStorer{StatusStorer: store, index: i}
+}
@@ -0,0 +1,167 @@
+package ddmindex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+type testStore struct {
+	mu    sync.Mutex
+	reads int
+	value string
+	err   error
+}
+
+func (s *testStore) read() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reads++
+	return []byte(s.value), s.err
+}
+
+func (s *testStore) RetrieveEnrollmentDeclarationJSON(_ context.Context, _, _, _ string) ([]byte, error) {
+	return s.read()
+}
+
+func (s *testStore) RetrieveDeclarationItemsJSON(_ context.Context, _ string) ([]byte, error) {
+	return s.read()
+}
+
+func (s *testStore) RetrieveTokensJSON(_ context.Context, _ string) ([]byte, error) {
+	return s.read()
+}
+
+func (s *testStore) StoreDeclarationStatus(_ context.Context, _ string, _ *ddm.StatusReport) error {
+	return nil
+}
+
+func TestIndex(t *testing.T) {
+	ctx := context.Background()
+	s := &testStore{value: "a"}
+	i := New(s)
+
+	read := func(want string, wantReads int) {
+		t.Helper()
+		b, err := i.RetrieveTokensJSON(ctx, "id1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("have: %s, want: %s", string(b), want)
+		}
+		if s.reads != wantReads {
+			t.Errorf("reads: have: %d, want: %d", s.reads, wantReads)
+		}
+	}
+
+	read("a", 1)
+	read("a", 1)
+
+	// each document of an enrollment is indexed separately
+	if _, err := i.RetrieveDeclarationItemsJSON(ctx, "id1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.RetrieveEnrollmentDeclarationJSON(ctx, "decl1", "configuration", "id1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := i.RetrieveEnrollmentDeclarationJSON(ctx, "decl1", "configuration", "id1"); err != nil {
+		t.Fatal(err)
+	}
+	if s.reads != 3 {
+		t.Errorf("reads: have: %d, want: 3", s.reads)
+	}
+
+	s.value = "b"
+	read("a", 3)
+
+	// other enrollments are not invalidated
+	i.Invalidate(ctx, nil, nil, []string{"id2"})
+	read("a", 3)
+
+	i.Invalidate(ctx, nil, nil, []string{"id1"})
+	read("b", 4)
+
+	s.value = "c"
+	i.Invalidate(ctx, nil, []string{"set1"}, nil)
+	read("c", 5)
+
+	s.value = "d"
+	st := i.StatusStorer(s)
+	if err := st.StoreDeclarationStatus(ctx, "id1", nil); err != nil {
+		t.Fatal(err)
+	}
+	read("d", 6)
+}
+
+func TestIndexErrors(t *testing.T) {
+	s := &testStore{err: errors.New("test error")}
+	i := New(s)
+	for n := 1; n <= 2; n++ {
+		if _, err := i.RetrieveTokensJSON(context.Background(), "id1"); err == nil {
+			t.Fatal("expected error")
+		}
+		// errors are not indexed
+		if s.reads != n {
+			t.Errorf("reads: have: %d, want: %d", s.reads, n)
+		}
+	}
+}
+
+func TestIndexTTL(t *testing.T) {
+	s := &testStore{value: "a"}
+	i := New(s, WithTTL(time.Millisecond))
+	for n := 1; n <= 2; n++ {
+		if _, err := i.RetrieveTokensJSON(context.Background(), "id1"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	if s.reads != 2 {
+		t.Errorf("reads: have: %d, want: 2", s.reads)
+	}
+}
+
+func TestIndexMaxEntries(t *testing.T) {
+	s := &testStore{value: "a"}
+	i := New(s, WithMaxEntries(2))
+	for _, id := range []string{"id1", "id2", "id3"} {
+		if _, err := i.RetrieveTokensJSON(context.Background(), id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := len(i.entries); have != 1 {
+		t.Errorf("entries: have: %d, want: 1", have)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s := &testStore{value: "a"}
+	i := New(s)
+	h := i.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, test := range []struct {
+		method    string
+		wantReads int
+	}{
+		{http.MethodGet, 1},
+		{http.MethodPut, 2},
+		{http.MethodDelete, 3},
+	} {
+		if _, err := i.RetrieveTokensJSON(context.Background(), "id1"); err != nil {
+			t.Fatal(err)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, "/", nil))
+		if _, err := i.RetrieveTokensJSON(context.Background(), "id1"); err != nil {
+			t.Fatal(err)
+		}
+		if s.reads != test.wantReads {
+			t.Errorf("%s: reads: have: %d, want: %d", test.method, s.reads, test.wantReads)
+		}
+	}
+}
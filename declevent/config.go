@@ -0,0 +1,111 @@
+package declevent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Webhook is a URL that events are posted to as JSON.
+type Webhook struct {
+	// Name identifies the webhook in logs and the delivery log.
+	Name string `json:"name"`
+	URL  string `json:"url"`
+
+	// Events are the event types posted to the webhook.
+	// All events are posted if empty.
+	Events []string `json:"events,omitempty"`
+
+	types map[string]struct{}
+}
+
+// subscribed reports whether events of typ are posted to w.
+func (w *Webhook) subscribed(typ string) bool {
+	if w.types == nil {
+		return true
+	}
+	_, ok := w.types[typ]
+	return ok
+}
+
+// post posts e as JSON to w and returns the HTTP status code.
+func (w *Webhook) post(ctx context.Context, client *http.Client, e *Event) (int, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook: unexpected HTTP status: %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// Config is the declaration event webhooks configuration.
+type Config struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// ReadConfig reads and validates a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding declaration event config: %w", err)
+	}
+	known := make(map[string]struct{})
+	for _, t := range Types {
+		known[t] = struct{}{}
+	}
+	names := make(map[string]struct{})
+	for i, w := range c.Webhooks {
+		if w == nil || w.Name == "" {
+			return nil, fmt.Errorf("webhook %d: empty name", i)
+		}
+		if _, ok := names[w.Name]; ok {
+			return nil, fmt.Errorf("webhook %s: duplicate name", w.Name)
+		}
+		names[w.Name] = struct{}{}
+		if w.URL == "" {
+			return nil, fmt.Errorf("webhook %s: %w", w.Name, errors.New("empty URL"))
+		}
+		if len(w.Events) > 0 {
+			w.types = make(map[string]struct{})
+		}
+		for _, t := range w.Events {
+			if _, ok := known[t]; !ok {
+				return nil, fmt.Errorf("webhook %s: unknown event type: %q", w.Name, t)
+			}
+			w.types[t] = struct{}{}
+		}
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads and validates a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
@@ -0,0 +1,274 @@
+// Package declevent posts declaration lifecycle events to webhooks.
+// Events are fired when declarations are created, updated, or deleted
+// and when the membership of sets changes. Unlike the remediation
+// webhooks events are not about the status reported by enrollments.
+package declevent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Event types.
+const (
+	TypeDeclarationCreated    = "declaration.created"
+	TypeDeclarationUpdated    = "declaration.updated"
+	TypeDeclarationDeleted    = "declaration.deleted"
+	TypeSetDeclarationAdded   = "set.declaration.added"
+	TypeSetDeclarationRemoved = "set.declaration.removed"
+	TypeEnrollmentSetAdded    = "enrollment.set.added"
+	TypeEnrollmentSetRemoved  = "enrollment.set.removed"
+)
+
+// Types are all of the event types.
+var Types = []string{
+	TypeDeclarationCreated,
+	TypeDeclarationUpdated,
+	TypeDeclarationDeleted,
+	TypeSetDeclarationAdded,
+	TypeSetDeclarationRemoved,
+	TypeEnrollmentSetAdded,
+	TypeEnrollmentSetRemoved,
+}
+
+// DefaultDeliveryLogSize is the default number of deliveries kept in
+// the delivery log.
+const DefaultDeliveryLogSize = 1000
+
+// webhookTimeout limits how long posting to a webhook may take.
+const webhookTimeout = 30 * time.Second
+
+// Event is a declaration lifecycle event.
+type Event struct {
+	Type        string `json:"type"`
+	Declaration string `json:"declaration,omitempty"`
+	Set         string `json:"set,omitempty"`
+
+	// ServerToken is the server token of a created or updated declaration.
+	ServerToken string `json:"server_token,omitempty"`
+
+	EnrollmentID string    `json:"enrollment_id,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Delivery records posting an event to a webhook.
+type Delivery struct {
+	Webhook   string    `json:"webhook"`
+	Event     *Event    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+
+	// Status is the HTTP status code of the webhook response.
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Storage is the storage whose declaration and set changes fire events.
+type Storage interface {
+	storage.DeclarationStorer
+	storage.DeclarationDeleter
+	storage.DeclarationAPIRetriever
+	storage.Toucher
+	storage.SetDeclarationStorer
+	storage.SetDeclarationRemover
+	storage.EnrollmentSetStorer
+	storage.EnrollmentSetRemover
+}
+
+// Publisher wraps storage. After a declaration or set membership is
+// changed the change is posted as an event to the webhooks subscribed
+// to its type. Events are posted in the background so that storage is
+// not delayed by slow webhooks. Deliveries are kept in a log.
+type Publisher struct {
+	store    Storage
+	webhooks []*Webhook
+	client   *http.Client
+	logger   log.Logger
+	now      func() time.Time
+	size     int
+
+	mu         sync.RWMutex
+	deliveries []*Delivery
+}
+
+type Option func(*Publisher)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(p *Publisher) {
+		p.logger = logger
+	}
+}
+
+// WithClient sets the HTTP client used for webhooks.
+func WithClient(client *http.Client) Option {
+	return func(p *Publisher) {
+		p.client = client
+	}
+}
+
+// WithDeliveryLogSize sets the number of deliveries kept in the delivery log.
+func WithDeliveryLogSize(n int) Option {
+	return func(p *Publisher) {
+		p.size = n
+	}
+}
+
+// New creates a new Publisher that posts events to the webhooks of config.
+func New(store Storage, config *Config, opts ...Option) *Publisher {
+	if store == nil || config == nil {
+		panic("nil store or config")
+	}
+	p := &Publisher{
+		store:    store,
+		webhooks: config.Webhooks,
+		logger:   log.NopLogger,
+		now:      time.Now,
+		size:     DefaultDeliveryLogSize,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// publish posts e to the webhooks subscribed to its type in the background.
+func (p *Publisher) publish(ctx context.Context, e *Event) {
+	e.Timestamp = p.now()
+	logger := ctxlog.Logger(ctx, p.logger).With("event", e.Type)
+	for _, w := range p.webhooks {
+		if !w.subscribed(e.Type) {
+			continue
+		}
+		go func(w *Webhook) {
+			// the request context may be cancelled before we're done
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			d := &Delivery{Webhook: w.Name, Event: e}
+			var err error
+			d.Status, err = w.post(ctx, p.client, e)
+			if err != nil {
+				d.Error = err.Error()
+				logger.Info(logkeys.Message, "posting declaration event webhook", "webhook", w.Name, logkeys.Error, err)
+			}
+			d.Timestamp = p.now()
+			p.logDelivery(d)
+		}(w)
+	}
+}
+
+// logDelivery adds d to the delivery log removing the oldest
+// deliveries if the log is full.
+func (p *Publisher) logDelivery(d *Delivery) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deliveries = append(p.deliveries, d)
+	if over := len(p.deliveries) - p.size; over > 0 {
+		p.deliveries = append(p.deliveries[:0:0], p.deliveries[over:]...)
+	}
+}
+
+// Deliveries returns the logged deliveries to webhook (or to all
+// webhooks if empty), most recent first.
+func (p *Publisher) Deliveries(_ context.Context, webhook string) ([]*Delivery, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ret := []*Delivery{}
+	for i := len(p.deliveries) - 1; i >= 0; i-- {
+		if webhook == "" || p.deliveries[i].Webhook == webhook {
+			ret = append(ret, p.deliveries[i])
+		}
+	}
+	return ret, nil
+}
+
+// StoreDeclaration stores d and fires a created or updated event if it changed.
+func (p *Publisher) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	typ := TypeDeclarationUpdated
+	if _, err := p.store.RetrieveDeclaration(ctx, d.Identifier); errors.Is(err, storage.ErrDeclarationNotFound) {
+		typ = TypeDeclarationCreated
+	} else if err != nil {
+		return false, fmt.Errorf("retrieving declaration: %w", err)
+	}
+	changed, err := p.store.StoreDeclaration(ctx, d)
+	if err != nil || !changed {
+		return changed, err
+	}
+	e := &Event{Type: typ, Declaration: d.Identifier}
+	if stored, err := p.store.RetrieveDeclaration(ctx, d.Identifier); err != nil {
+		ctxlog.Logger(ctx, p.logger).Info(logkeys.Message, "retrieving declaration", logkeys.DeclarationID, d.Identifier, logkeys.Error, err)
+	} else {
+		e.ServerToken = stored.ServerToken
+	}
+	p.publish(ctx, e)
+	return changed, nil
+}
+
+// TouchDeclaration touches declarationID and fires an updated event.
+func (p *Publisher) TouchDeclaration(ctx context.Context, declarationID string) error {
+	if err := p.store.TouchDeclaration(ctx, declarationID); err != nil {
+		return err
+	}
+	e := &Event{Type: TypeDeclarationUpdated, Declaration: declarationID}
+	if stored, err := p.store.RetrieveDeclaration(ctx, declarationID); err != nil {
+		ctxlog.Logger(ctx, p.logger).Info(logkeys.Message, "retrieving declaration", logkeys.DeclarationID, declarationID, logkeys.Error, err)
+	} else {
+		e.ServerToken = stored.ServerToken
+	}
+	p.publish(ctx, e)
+	return nil
+}
+
+// DeleteDeclaration deletes declarationID and fires a deleted event if it changed.
+func (p *Publisher) DeleteDeclaration(ctx context.Context, declarationID string) (bool, error) {
+	changed, err := p.store.DeleteDeclaration(ctx, declarationID)
+	if err == nil && changed {
+		p.publish(ctx, &Event{Type: TypeDeclarationDeleted, Declaration: declarationID})
+	}
+	return changed, err
+}
+
+// StoreSetDeclaration adds declarationID to setName and fires an event if it changed.
+func (p *Publisher) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	changed, err := p.store.StoreSetDeclaration(ctx, setName, declarationID)
+	if err == nil && changed {
+		p.publish(ctx, &Event{Type: TypeSetDeclarationAdded, Set: setName, Declaration: declarationID})
+	}
+	return changed, err
+}
+
+// RemoveSetDeclaration removes declarationID from setName and fires an event if it changed.
+func (p *Publisher) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	changed, err := p.store.RemoveSetDeclaration(ctx, setName, declarationID)
+	if err == nil && changed {
+		p.publish(ctx, &Event{Type: TypeSetDeclarationRemoved, Set: setName, Declaration: declarationID})
+	}
+	return changed, err
+}
+
+// StoreEnrollmentSet adds enrollmentID to setName and fires an event if it changed.
+func (p *Publisher) StoreEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	changed, err := p.store.StoreEnrollmentSet(ctx, enrollmentID, setName)
+	if err == nil && changed {
+		p.publish(ctx, &Event{Type: TypeEnrollmentSetAdded, Set: setName, EnrollmentID: enrollmentID})
+	}
+	return changed, err
+}
+
+// RemoveEnrollmentSet removes enrollmentID from setName and fires an event if it changed.
+func (p *Publisher) RemoveEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	changed, err := p.store.RemoveEnrollmentSet(ctx, enrollmentID, setName)
+	if err == nil && changed {
+		p.publish(ctx, &Event{Type: TypeEnrollmentSetRemoved, Set: setName, EnrollmentID: enrollmentID})
+	}
+	return changed, err
+}
@@ -0,0 +1,127 @@
+package declevent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+const testConfig = `{
+	"webhooks": [
+		{
+			"name": "all",
+			"url": "%[1]s/all"
+		},
+		{
+			"name": "deletes",
+			"url": "%[1]s/deletes",
+			"events": ["declaration.deleted"]
+		}
+	]
+}`
+
+func TestReadConfig(t *testing.T) {
+	for _, config := range []string{
+		`{"webhooks":[{"url":"http://localhost/"}]}`,
+		`{"webhooks":[{"name":"a"}]}`,
+		`{"webhooks":[{"name":"a","url":"http://localhost/"},{"name":"a","url":"http://localhost/"}]}`,
+		`{"webhooks":[{"name":"a","url":"http://localhost/","events":["declaration.renamed"]}]}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(config)); err == nil {
+			t.Errorf("expected error for config: %s", config)
+		}
+	}
+}
+
+func TestPublisher(t *testing.T) {
+	ctx := context.Background()
+	events := make(chan string, 20)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := new(Event)
+		if err := json.NewDecoder(r.Body).Decode(e); err != nil {
+			t.Error(err)
+		}
+		if r.URL.Path == "/deletes" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		events <- r.URL.Path + " " + e.Type
+	}))
+	defer srv.Close()
+
+	config, err := ReadConfig(strings.NewReader(fmt.Sprintf(testConfig, srv.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(fs, config)
+
+	for _, payload := range []string{"hi", "hi", "hello"} {
+		d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"` + payload + `"}}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = p.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = p.StoreSetDeclaration(ctx, "test", "com.example.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.RemoveSetDeclaration(ctx, "test", "com.example.test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = p.DeleteDeclaration(ctx, "com.example.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the unchanged declaration fires no event
+	want := map[string]bool{
+		"/all " + TypeDeclarationCreated:     true,
+		"/all " + TypeDeclarationUpdated:     true,
+		"/all " + TypeSetDeclarationAdded:    true,
+		"/all " + TypeSetDeclarationRemoved:  true,
+		"/all " + TypeDeclarationDeleted:     true,
+		"/deletes " + TypeDeclarationDeleted: true,
+	}
+	for range want {
+		select {
+		case e := <-events:
+			if !want[e] {
+				t.Errorf("unexpected event: %s", e)
+			}
+			delete(want, e)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events: %v", want)
+		}
+	}
+
+	// deliveries are logged after the webhook responds
+	var deliveries []*Delivery
+	for i := 0; i < 100; i++ {
+		if deliveries, err = p.Deliveries(ctx, "deletes"); err != nil {
+			t.Fatal(err)
+		} else if len(deliveries) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(deliveries) != 1 {
+		t.Fatalf("deliveries: have: %d, want: 1", len(deliveries))
+	}
+	if d := deliveries[0]; d.Status != http.StatusInternalServerError || d.Error == "" || d.Event.Declaration != "com.example.test" {
+		t.Errorf("delivery: %+v", d)
+	}
+}
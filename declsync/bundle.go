@@ -0,0 +1,174 @@
+package declsync
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	// BundleContentType is the MIME type of set bundles.
+	BundleContentType = "application/gzip"
+
+	bundleDeclarationsDir = "declarations"
+
+	// maxBundleSize limits the uncompressed size of an imported bundle.
+	maxBundleSize = 64 << 20
+)
+
+var ErrEmptySet = errors.New("set has no declarations")
+
+// BundleStorage is the storage needed to export a set bundle.
+type BundleStorage interface {
+	storage.SetDeclarationsRetriever
+	storage.DeclarationAPIRetriever
+}
+
+// bundleDeclarationName returns the bundle file name for declarationID.
+func bundleDeclarationName(declarationID string) string {
+	return path.Join(bundleDeclarationsDir, url.PathEscape(declarationID)+".json")
+}
+
+// writeBundleFile writes a single file entry to tw.
+func writeBundleFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(data)
+	return err
+}
+
+// WriteBundle writes a gzipped tar archive of setName to w. The archive
+// contains the JSON of the declarations in the set (and the declarations
+// they reference) and a set file listing the declarations in the set.
+// This is the same layout ReadDir reads so that a bundle may be
+// extracted and synchronized to recreate the set.
+func WriteBundle(ctx context.Context, w io.Writer, store BundleStorage, setName string) error {
+	if strings.ContainsAny(setName, "/\\") {
+		return fmt.Errorf("invalid set name: %s", setName)
+	}
+	setIDs, err := store.RetrieveSetDeclarations(ctx, setName)
+	if err != nil {
+		return fmt.Errorf("retrieving set declarations: %w", err)
+	}
+	if len(setIDs) < 1 {
+		return fmt.Errorf("%w: %s", ErrEmptySet, setName)
+	}
+	sort.Strings(setIDs)
+
+	now := time.Now()
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	// walk the set declarations and their references
+	seen := make(map[string]bool)
+	queue := append([]string{}, setIDs...)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		d, err := store.RetrieveDeclaration(ctx, id)
+		if err != nil {
+			return fmt.Errorf("retrieving declaration %s: %w", id, err)
+		}
+		if err = writeBundleFile(tw, bundleDeclarationName(id), d.Raw, now); err != nil {
+			return fmt.Errorf("writing declaration %s: %w", id, err)
+		}
+		queue = append(queue, d.IdentifierRefs...)
+	}
+
+	manifest := fmt.Sprintf("# set %s exported %s\n%s\n", setName, now.UTC().Format(time.RFC3339), strings.Join(setIDs, "\n"))
+	if err = writeBundleFile(tw, "set."+setName+".txt", []byte(manifest), now); err != nil {
+		return fmt.Errorf("writing set file: %w", err)
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// ExtractBundle extracts the regular files of the gzipped tar archive
+// from r into dir. Entries that would be written outside of dir are an error.
+func ExtractBundle(r io.Reader, dir string) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("reading gzip: %w", err)
+	}
+	defer gr.Close()
+	tr := tar.NewReader(gr)
+	var total int64
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("reading tar: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("invalid bundle file name: %s", hdr.Name)
+		}
+		if total += hdr.Size; total > maxBundleSize {
+			return errors.New("bundle too large")
+		}
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, io.LimitReader(tr, hdr.Size))
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("extracting %s: %w", hdr.Name, err)
+		}
+	}
+	return nil
+}
+
+// ImportBundle extracts the bundle from r and synchronizes it to store.
+// The set files of the bundle are authoritative for their sets: any
+// declarations in those sets that are not in the bundle are dissociated.
+// Use WithNotifier and WithLogger in opts as needed.
+func ImportBundle(ctx context.Context, store Storage, r io.Reader, dryRun bool, opts ...Option) (*Report, error) {
+	dir, err := os.MkdirTemp("", "kmfddm-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if err = ExtractBundle(r, dir); err != nil {
+		return nil, err
+	}
+	s := New(store, dir, append(opts, WithPrune())...)
+	return s.Sync(ctx, dryRun)
+}
@@ -0,0 +1,53 @@
+package declsync
+
+import (
+	"bytes"
+	"context"
+	"hash"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestBundle(t *testing.T) {
+	dir := t.TempDir()
+	newHash := func() hash.Hash { return xxhash.New() }
+	ctx := context.Background()
+
+	src, err := file.New(dir+"/src", newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New(src, "testdata/repo").Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := new(bytes.Buffer)
+	if err = WriteBundle(ctx, buf, src, "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	dst, err := file.New(dir+"/dst", newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := ImportBundle(ctx, dst, buf, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(report.Declarations), 2; have != want {
+		t.Errorf("declarations: have %d, want %d", have, want)
+	}
+	ids, err := dst.RetrieveSetDeclarations(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(ids), 2; have != want {
+		t.Errorf("set declarations: have %d, want %d", have, want)
+	}
+
+	if err = WriteBundle(ctx, buf, src, "empty"); err == nil {
+		t.Error("expected error for empty set")
+	}
+}
@@ -0,0 +1,50 @@
+package declsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// git runs the git command with args and returns its combined output.
+func git(ctx context.Context, args ...string) (string, error) {
+	out, err := exec.CommandContext(ctx, "git", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// gitPull clones url (at branch, if provided) into dir if dir is not
+// yet a git repository. Otherwise the existing checkout is updated.
+// The current commit hash is returned.
+func gitPull(ctx context.Context, url, branch, dir string) (string, error) {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	if errors.Is(err, os.ErrNotExist) {
+		args := []string{"clone", "--quiet", "--depth", "1"}
+		if branch != "" {
+			args = append(args, "--branch", branch)
+		}
+		if _, err = git(ctx, append(args, "--", url, dir)...); err != nil {
+			return "", err
+		}
+	} else if err != nil {
+		return "", err
+	} else {
+		ref := "HEAD"
+		if branch != "" {
+			ref = branch
+		}
+		if _, err = git(ctx, "-C", dir, "fetch", "--quiet", "--depth", "1", "origin", ref); err != nil {
+			return "", err
+		}
+		if _, err = git(ctx, "-C", dir, "reset", "--quiet", "--hard", "FETCH_HEAD"); err != nil {
+			return "", err
+		}
+	}
+	return git(ctx, "-C", dir, "rev-parse", "HEAD")
+}
@@ -0,0 +1,168 @@
+// Package declsync synchronizes declarations and sets from a directory to storage.
+package declsync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+var setFilePattern = regexp.MustCompile(`^set\.(.+)\.txt$`)
+
+// SetManifest contains the declaration associations of a set.
+type SetManifest struct {
+	Name string
+
+	// Associate are declaration identifiers to associate with the set.
+	Associate []string
+
+	// Dissociate are declaration identifiers to dissociate from the set.
+	Dissociate []string
+
+	// Path is the file path of the set file relative to the directory.
+	Path string
+}
+
+// Source is the collection of declarations and sets read from a directory.
+type Source struct {
+	// Declarations are keyed by declaration identifier.
+	Declarations map[string]*ddm.Declaration
+
+	// Paths contains the file path (relative to the directory) of each
+	// declaration, keyed by identifier.
+	Paths map[string]string
+
+	// Sets are keyed by set name.
+	Sets map[string]*SetManifest
+
+	// Errors contains problems with individual files.
+	Errors []string
+}
+
+// DeclarationIDs returns the sorted declaration identifiers of src.
+func (src *Source) DeclarationIDs() []string {
+	ids := make([]string, 0, len(src.Declarations))
+	for id := range src.Declarations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SetNames returns the sorted set names of src.
+func (src *Source) SetNames() []string {
+	names := make([]string, 0, len(src.Sets))
+	for name := range src.Sets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseSetManifest parses a set file. Each line contains a declaration
+// identifier to associate with the set. A line starting with a minus
+// ("-") dissociates the declaration and a line starting with an
+// octothorpe ("#") is a comment.
+func parseSetManifest(name string, data []byte) *SetManifest {
+	sm := &SetManifest{Name: name}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		if line[0] == '-' {
+			if id := strings.TrimSpace(line[1:]); id != "" {
+				sm.Dissociate = append(sm.Dissociate, id)
+			}
+			continue
+		}
+		sm.Associate = append(sm.Associate, line)
+	}
+	return sm
+}
+
+// ReadDir walks dir to find declarations and set manifests. Any file
+// with a ".json" extension is assumed to be a declaration. Files named
+// "set.$SET.txt" are set manifests for the set named "$SET". Hidden
+// files and directories (e.g. ".git") are skipped.
+func ReadDir(dir string) (*Source, error) {
+	src := &Source{
+		Declarations: make(map[string]*ddm.Declaration),
+		Paths:        make(map[string]string),
+		Sets:         make(map[string]*SetManifest),
+	}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if strings.HasSuffix(d.Name(), ".json") {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			decl, err := ddm.ParseDeclaration(data)
+			if err != nil {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			if !decl.Valid() {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: %v", relPath, ddm.ErrInvalidDeclaration))
+				return nil
+			}
+			if err = storage.ValidateIdentifier("declaration identifier", decl.Identifier); err != nil {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			if prev, ok := src.Paths[decl.Identifier]; ok {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: duplicate declaration %s (also in %s)", relPath, decl.Identifier, prev))
+				return nil
+			}
+			src.Declarations[decl.Identifier] = decl
+			src.Paths[decl.Identifier] = relPath
+		} else if m := setFilePattern.FindStringSubmatch(d.Name()); m != nil {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			if err = storage.ValidateIdentifier("set name", m[1]); err != nil {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: %v", relPath, err))
+				return nil
+			}
+			if _, ok := src.Sets[m[1]]; ok {
+				src.Errors = append(src.Errors, fmt.Sprintf("%s: duplicate set %s", relPath, m[1]))
+				return nil
+			}
+			sm := parseSetManifest(m[1], data)
+			sm.Path = relPath
+			src.Sets[m[1]] = sm
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading directory: %w", err)
+	}
+	return src, nil
+}
@@ -0,0 +1,400 @@
+package declsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the storage needed to synchronize declarations and sets.
+type Storage interface {
+	storage.DeclarationStorer
+	storage.DeclarationDeleter
+	storage.DeclarationAPIRetriever
+	storage.SetDeclarationStorage
+}
+
+// Notifier notifies enrollments of changed declarations and sets.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// AdminEvents is notified of significant administrative events.
+type AdminEvents interface {
+	SetDeclarationRemoved(ctx context.Context, setName, declarationID string)
+}
+
+// ErrNotInSource is the error of set changes that associate a
+// declaration that is not in the source.
+var ErrNotInSource = errors.New("declaration not in source")
+
+const (
+	ActionCreate     = "create"
+	ActionUpdate     = "update"
+	ActionDelete     = "delete"
+	ActionAssociate  = "associate"
+	ActionDissociate = "dissociate"
+)
+
+// Change is a single change made (or to be made) to storage.
+type Change struct {
+	Action      string `json:"action"`
+	Declaration string `json:"declaration"`
+	Set         string `json:"set,omitempty"`
+	Path        string `json:"path,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// Report is the result of a synchronization.
+type Report struct {
+	DryRun bool `json:"dry_run"`
+
+	// Commit is the git commit hash synchronized, if any.
+	Commit string `json:"commit,omitempty"`
+
+	Declarations []Change `json:"declarations"`
+	Sets         []Change `json:"sets"`
+
+	UnchangedDeclarations int `json:"unchanged_declarations"`
+	UnchangedSets         int `json:"unchanged_sets"`
+
+	// Errors contains problems with source files.
+	Errors []string `json:"errors,omitempty"`
+
+	Notified bool `json:"notified"`
+}
+
+// Syncer synchronizes declarations and sets from a directory to storage.
+type Syncer struct {
+	mu       sync.Mutex
+	store    Storage
+	dir      string
+	notifier Notifier
+	events   AdminEvents
+	logger   log.Logger
+	prune    bool
+	delete   bool
+
+	// synced are the declarations read from the directory by the last
+	// successful sync. Used to find deleted declarations.
+	synced map[string]struct{}
+
+	gitURL    string
+	gitBranch string
+}
+
+type Option func(s *Syncer)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(s *Syncer) {
+		s.logger = logger
+	}
+}
+
+// WithNotifier notifies enrollments of changes after synchronizing.
+func WithNotifier(n Notifier) Option {
+	return func(s *Syncer) {
+		s.notifier = n
+	}
+}
+
+// WithAdminEvents reports declarations removed from sets to events.
+func WithAdminEvents(events AdminEvents) Option {
+	return func(s *Syncer) {
+		s.events = events
+	}
+}
+
+// WithGit clones or updates the directory from the git repository at
+// url (and optional branch) before synchronizing.
+func WithGit(url, branch string) Option {
+	return func(s *Syncer) {
+		s.gitURL = url
+		s.gitBranch = branch
+	}
+}
+
+// WithPrune dissociates declarations from sets with set manifests
+// when the declarations are not listed in those manifests. This makes
+// each set manifest authoritative for its set.
+func WithPrune() Option {
+	return func(s *Syncer) {
+		s.prune = true
+	}
+}
+
+// WithDelete deletes declarations from storage whose files were
+// removed from the directory since the previous sync. The declarations
+// are first dissociated from any sets. Only declarations seen by this
+// Syncer are deleted; declarations removed while the server was not
+// running are not.
+func WithDelete() Option {
+	return func(s *Syncer) {
+		s.delete = true
+	}
+}
+
+// New creates a new Syncer that synchronizes dir to store.
+func New(store Storage, dir string, opts ...Option) *Syncer {
+	if store == nil {
+		panic("nil store")
+	}
+	s := &Syncer{
+		store:  store,
+		dir:    dir,
+		logger: log.NopLogger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// payloadEqual reports whether the JSON payloads a and b are equivalent.
+func payloadEqual(a, b []byte) bool {
+	var aV, bV interface{}
+	if json.Unmarshal(a, &aV) != nil || json.Unmarshal(b, &bV) != nil {
+		return false
+	}
+	return reflect.DeepEqual(aV, bV)
+}
+
+// declarationChange determines the change needed to store d, if any.
+func (s *Syncer) declarationChange(ctx context.Context, d *ddm.Declaration) (string, error) {
+	stored, err := s.store.RetrieveDeclaration(ctx, d.Identifier)
+	if errors.Is(err, storage.ErrDeclarationNotFound) {
+		return ActionCreate, nil
+	} else if err != nil {
+		return "", err
+	}
+	if stored.Type != d.Type || !payloadEqual(stored.PayloadJSON, d.PayloadJSON) {
+		return ActionUpdate, nil
+	}
+	return "", nil
+}
+
+// setChanges determines the changes needed to make the set in storage match sm.
+// Declarations that are not in src are not associated: their changes
+// have the ErrNotInSource error instead.
+func (s *Syncer) setChanges(ctx context.Context, sm *SetManifest, src *Source) ([]Change, error) {
+	storedIDs, err := s.store.RetrieveSetDeclarations(ctx, sm.Name)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]bool, len(storedIDs))
+	for _, id := range storedIDs {
+		stored[id] = true
+	}
+	var changes []Change
+	listed := make(map[string]bool)
+	for _, id := range sm.Associate {
+		listed[id] = true
+		if stored[id] {
+			continue
+		}
+		change := Change{Action: ActionAssociate, Declaration: id, Set: sm.Name, Path: sm.Path}
+		if _, ok := src.Declarations[id]; !ok {
+			// the declaration may be deleted by this sync
+			change.Error = ErrNotInSource.Error()
+		} else {
+			stored[id] = true
+		}
+		changes = append(changes, change)
+	}
+	for _, id := range sm.Dissociate {
+		if stored[id] && !listed[id] {
+			changes = append(changes, Change{Action: ActionDissociate, Declaration: id, Set: sm.Name, Path: sm.Path})
+			delete(stored, id)
+		}
+	}
+	if s.prune {
+		for _, id := range storedIDs {
+			if stored[id] && !listed[id] {
+				changes = append(changes, Change{Action: ActionDissociate, Declaration: id, Set: sm.Name, Path: sm.Path})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// deletedIDs returns the sorted identifiers in synced that are not in src.
+func deletedIDs(synced map[string]struct{}, src *Source) []string {
+	var ids []string
+	for id := range synced {
+		if _, ok := src.Declarations[id]; ok {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// setDeclarationRemoved reports the removal of declarationID from setName.
+func (s *Syncer) setDeclarationRemoved(ctx context.Context, setName, declarationID string) {
+	if s.events != nil {
+		s.events.SetDeclarationRemoved(ctx, setName, declarationID)
+	}
+}
+
+// deleteDeclaration dissociates declarationID from its sets and then
+// deletes it. The sets the declaration was dissociated from are returned.
+func (s *Syncer) deleteDeclaration(ctx context.Context, declarationID string) ([]string, error) {
+	setNames, err := s.store.RetrieveDeclarationSets(ctx, declarationID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declaration sets: %w", err)
+	}
+	var changedSets []string
+	for _, setName := range setNames {
+		changed, err := s.store.RemoveSetDeclaration(ctx, setName, declarationID)
+		if err != nil {
+			return changedSets, fmt.Errorf("dissociating set %s: %w", setName, err)
+		}
+		if changed {
+			changedSets = append(changedSets, setName)
+			s.setDeclarationRemoved(ctx, setName, declarationID)
+		}
+	}
+	_, err = s.store.DeleteDeclaration(ctx, declarationID)
+	return changedSets, err
+}
+
+// Sync synchronizes the directory to storage and reports the changes.
+// If dryRun is true changes are reported but not made.
+// Problems with individual files or changes are included in the report
+// rather than stopping the synchronization.
+func (s *Syncer) Sync(ctx context.Context, dryRun bool) (*Report, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	logger := ctxlog.Logger(ctx, s.logger)
+
+	report := &Report{
+		DryRun:       dryRun,
+		Declarations: []Change{},
+		Sets:         []Change{},
+	}
+
+	if s.gitURL != "" {
+		var err error
+		if report.Commit, err = gitPull(ctx, s.gitURL, s.gitBranch, s.dir); err != nil {
+			return nil, fmt.Errorf("updating git repository: %w", err)
+		}
+		logger.Debug(logkeys.Message, "updated git repository", "commit", report.Commit)
+	}
+
+	src, err := ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	report.Errors = src.Errors
+
+	var changedDecls, changedSets []string
+
+	for _, id := range src.DeclarationIDs() {
+		d := src.Declarations[id]
+		change := Change{Declaration: id, Path: src.Paths[id]}
+		change.Action, err = s.declarationChange(ctx, d)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving declaration %s: %w", id, err)
+		}
+		if change.Action == "" {
+			report.UnchangedDeclarations++
+			continue
+		}
+		if !dryRun {
+			if changed, err := s.store.StoreDeclaration(ctx, d); err != nil {
+				change.Error = err.Error()
+			} else if changed {
+				changedDecls = append(changedDecls, id)
+			}
+		}
+		report.Declarations = append(report.Declarations, change)
+	}
+
+	for _, name := range src.SetNames() {
+		changes, err := s.setChanges(ctx, src.Sets[name], src)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving set %s: %w", name, err)
+		}
+		if len(changes) < 1 {
+			report.UnchangedSets++
+			continue
+		}
+		var setChanged bool
+		for i, change := range changes {
+			if dryRun || change.Error != "" {
+				continue
+			}
+			var changed bool
+			if change.Action == ActionAssociate {
+				changed, err = s.store.StoreSetDeclaration(ctx, name, change.Declaration)
+			} else {
+				changed, err = s.store.RemoveSetDeclaration(ctx, name, change.Declaration)
+				if err == nil && changed {
+					s.setDeclarationRemoved(ctx, name, change.Declaration)
+				}
+			}
+			if err != nil {
+				changes[i].Error = err.Error()
+			}
+			setChanged = setChanged || changed
+		}
+		if setChanged {
+			changedSets = append(changedSets, name)
+		}
+		report.Sets = append(report.Sets, changes...)
+	}
+
+	// a declaration file that fails to parse may be a declaration that
+	// is being edited, so we skip deletions if there are any errors.
+	if s.delete && s.synced != nil && len(src.Errors) < 1 {
+		for _, id := range deletedIDs(s.synced, src) {
+			change := Change{Action: ActionDelete, Declaration: id}
+			if !dryRun {
+				sets, err := s.deleteDeclaration(ctx, id)
+				if err != nil {
+					change.Error = err.Error()
+				}
+				changedSets = append(changedSets, sets...)
+			}
+			report.Declarations = append(report.Declarations, change)
+		}
+	}
+	if !dryRun {
+		if s.synced == nil || len(src.Errors) < 1 {
+			s.synced = make(map[string]struct{}, len(src.Declarations))
+		}
+		for id := range src.Declarations {
+			s.synced[id] = struct{}{}
+		}
+	}
+
+	logger.Debug(
+		logkeys.Message, "synchronized",
+		"dry_run", dryRun,
+		"declarations", len(report.Declarations),
+		"sets", len(report.Sets),
+		"errors", len(report.Errors),
+	)
+
+	if s.notifier != nil && (len(changedDecls) > 0 || len(changedSets) > 0) {
+		if err = s.notifier.Changed(ctx, changedDecls, changedSets, nil); err != nil {
+			logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+		} else {
+			report.Notified = true
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,190 @@
+package declsync
+
+import (
+	"context"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestReadDir(t *testing.T) {
+	src, err := ReadDir("testdata/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(src.Declarations), 2; have != want {
+		t.Errorf("declarations: have %d, want %d", have, want)
+	}
+	if have, want := len(src.Errors), 1; have != want {
+		t.Errorf("errors: have %d, want %d", have, want)
+	}
+	sm, ok := src.Sets["default"]
+	if !ok {
+		t.Fatal("missing default set")
+	}
+	if have, want := len(sm.Associate), 2; have != want {
+		t.Errorf("associate: have %d, want %d", have, want)
+	}
+	if have, want := len(sm.Dissociate), 1; have != want {
+		t.Errorf("dissociate: have %d, want %d", have, want)
+	}
+}
+
+type testNotifier struct {
+	declarations []string
+	sets         []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, declarations []string, sets []string, _ []string) error {
+	n.declarations = append(n.declarations, declarations...)
+	n.sets = append(n.sets, sets...)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	const testPath = "teststor"
+	defer os.RemoveAll(testPath)
+	store, err := file.New(testPath, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	n := new(testNotifier)
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.extra","Payload":{"Echo":"Bar"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	_, err = store.StoreSetDeclaration(ctx, "default", "com.example.extra")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(store, "testdata/repo", WithNotifier(n), WithPrune())
+
+	report, err := s.Sync(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(report.Declarations), 2; have != want {
+		t.Errorf("dry run declarations: have %d, want %d", have, want)
+	}
+	if ids, _ := store.RetrieveDeclarations(ctx); len(ids) != 1 {
+		t.Errorf("dry run stored declarations: %v", ids)
+	}
+	if report.Notified {
+		t.Error("dry run should not notify")
+	}
+
+	report, err = s.Sync(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range report.Declarations {
+		if c.Action != ActionCreate || c.Error != "" {
+			t.Errorf("unexpected declaration change: %v", c)
+		}
+	}
+	// two associations and the pruned dissociation
+	if have, want := len(report.Sets), 3; have != want {
+		t.Errorf("set changes: have %d, want %d", have, want)
+	}
+	if !report.Notified || len(n.declarations) != 2 || len(n.sets) != 1 {
+		t.Errorf("unexpected notification: %v %v", n.declarations, n.sets)
+	}
+	ids, err := store.RetrieveSetDeclarations(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(ids), 2; have != want {
+		t.Errorf("set declarations: have %d, want %d", have, want)
+	}
+
+	report, err = s.Sync(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Declarations) != 0 || len(report.Sets) != 0 {
+		t.Errorf("expected no changes: %v %v", report.Declarations, report.Sets)
+	}
+	if have, want := report.UnchangedDeclarations, 2; have != want {
+		t.Errorf("unchanged declarations: have %d, want %d", have, want)
+	}
+}
+
+func TestSyncDelete(t *testing.T) {
+	dir := t.TempDir()
+	store, err := file.New(dir+"/db", func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	src := dir + "/src"
+	if err = os.Mkdir(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"decls/com.example.test.json", "sets/set.default.txt"} {
+		data, err := os.ReadFile("testdata/repo/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = os.WriteFile(src+"/"+filepath.Base(name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := New(store, src, WithDelete())
+	if _, err = s.Sync(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	state, err := dirState(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.Remove(src + "/com.example.test.json"); err != nil {
+		t.Fatal(err)
+	}
+	if newState, _ := dirState(src); newState == state {
+		t.Error("directory state should have changed")
+	}
+
+	report, err := s.Sync(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the set file still lists the declaration, but it no longer exists
+	var deleted bool
+	for _, c := range report.Declarations {
+		if c.Action == ActionDelete && c.Declaration == "com.example.test" && c.Error == "" {
+			deleted = true
+		}
+	}
+	if !deleted {
+		t.Errorf("expected deletion: %v", report.Declarations)
+	}
+	// declarations not in the source are not associated
+	var skipped bool
+	for _, c := range report.Sets {
+		if c.Declaration == "com.example.act" && c.Action == ActionAssociate && c.Error == ErrNotInSource.Error() {
+			skipped = true
+		}
+	}
+	if !skipped {
+		t.Errorf("expected skipped association: %v", report.Sets)
+	}
+	if ids, _ := store.RetrieveSetDeclarations(ctx, "default"); len(ids) != 0 {
+		t.Errorf("expected no set declarations: %v", ids)
+	}
+	if ids, _ := store.RetrieveDeclarations(ctx); len(ids) != 0 {
+		t.Errorf("expected no declarations: %v", ids)
+	}
+}
@@ -0,0 +1,81 @@
+package declsync
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// dirState returns a hash of the names, sizes, and modification times
+// of the (non-hidden) files in dir.
+func dirState(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00", path, fi.Size(), fi.ModTime().UnixNano())
+		return nil
+	})
+	return fmt.Sprintf("%x", h.Sum(nil)), err
+}
+
+// Watch synchronizes the directory immediately and then every interval
+// until ctx is done. Without a git repository the directory is only
+// synchronized when its files have changed.
+func (s *Syncer) Watch(ctx context.Context, interval time.Duration) {
+	logger := ctxlog.Logger(ctx, s.logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastState string
+	for {
+		var state string
+		if s.gitURL == "" {
+			var err error
+			if state, err = dirState(s.dir); err != nil {
+				logger.Info(logkeys.Message, "checking directory", logkeys.Error, err)
+			}
+		}
+		if state == "" || state != lastState {
+			report, err := s.Sync(ctx, false)
+			if err != nil {
+				logger.Info(logkeys.Message, "synchronizing", logkeys.Error, err)
+			} else {
+				lastState = state
+				for _, e := range report.Errors {
+					logger.Info(logkeys.Message, "synchronizing", logkeys.Error, e)
+				}
+				for _, c := range append(report.Declarations, report.Sets...) {
+					if c.Error != "" {
+						logger.Info(logkeys.Message, "synchronizing", "action", c.Action, logkeys.DeclarationID, c.Declaration, logkeys.Error, c.Error)
+					}
+				}
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package enrollimport imports enrollment to set assignments in bulk from CSV.
+package enrollimport
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// MaxRows is the maximum number of assignment rows in a single import.
+const MaxRows = 50000
+
+// Row results.
+const (
+	ResultAssigned  = "assigned"
+	ResultUnchanged = "unchanged"
+	ResultValid     = "valid" // dry run only
+	ResultInvalid   = "invalid"
+	ResultFailed    = "failed"
+)
+
+var ErrTooManyRows = errors.New("too many rows")
+
+// Notifier notifies enrollments of changes.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// Row is the result of a single CSV row.
+type Row struct {
+	// Line is the line number of the row in the CSV (starting at 1).
+	Line         int    `json:"line"`
+	EnrollmentID string `json:"enrollment_id,omitempty"`
+	Set          string `json:"set,omitempty"`
+	Result       string `json:"result"`
+	Error        string `json:"error,omitempty"`
+}
+
+// Report is the result of an import.
+type Report struct {
+	DryRun bool  `json:"dry_run"`
+	Rows   []Row `json:"rows"`
+
+	Assigned  int `json:"assigned"`
+	Unchanged int `json:"unchanged"`
+	Invalid   int `json:"invalid"`
+	Failed    int `json:"failed"`
+
+	// Notified is the number of changed enrollments notified.
+	Notified int `json:"notified"`
+}
+
+// Importer imports enrollment to set assignments.
+type Importer struct {
+	store    storage.EnrollmentSetStorer
+	notifier Notifier
+	logger   log.Logger
+}
+
+// Option configures an Importer.
+type Option func(*Importer)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(i *Importer) {
+		i.logger = logger
+	}
+}
+
+// WithNotifier notifies the enrollments changed by an import.
+func WithNotifier(n Notifier) Option {
+	return func(i *Importer) {
+		i.notifier = n
+	}
+}
+
+// New creates a new Importer.
+func New(store storage.EnrollmentSetStorer, opts ...Option) *Importer {
+	if store == nil {
+		panic("nil store")
+	}
+	i := &Importer{store: store, logger: log.NopLogger}
+	for _, opt := range opts {
+		opt(i)
+	}
+	return i
+}
+
+// isHeader reports whether record is the optional CSV header.
+func isHeader(record []string) bool {
+	if len(record) != 2 {
+		return false
+	}
+	first := strings.ToLower(strings.TrimSpace(record[0]))
+	second := strings.ToLower(strings.TrimSpace(record[1]))
+	return (first == "enrollment_id" || first == "enrollment") && (second == "set" || second == "set_name")
+}
+
+// ReadRows reads and validates the rows of the CSV in r.
+// Each record has an enrollment ID and a set name. A header record
+// of "enrollment_id,set" is skipped. Lines starting with "#" are
+// comments. Invalid rows are returned with an invalid result.
+// An error is returned if r is not valid CSV or has more than MaxRows.
+func ReadRows(r io.Reader) ([]Row, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	cr.Comment = '#'
+	var rows []Row
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		if first && isHeader(record) {
+			continue
+		}
+		if len(rows) >= MaxRows {
+			return nil, fmt.Errorf("%w: more than %d", ErrTooManyRows, MaxRows)
+		}
+		line, _ := cr.FieldPos(0)
+		row := Row{Line: line}
+		if len(record) != 2 {
+			row.Result = ResultInvalid
+			row.Error = fmt.Sprintf("expected 2 fields, got %d", len(record))
+			rows = append(rows, row)
+			continue
+		}
+		row.EnrollmentID = strings.TrimSpace(record[0])
+		row.Set = strings.TrimSpace(record[1])
+		if err := storage.ValidateIdentifier("enrollment ID", row.EnrollmentID); err != nil {
+			row.Result = ResultInvalid
+			row.Error = err.Error()
+		} else if err = storage.ValidateIdentifier("set name", row.Set); err != nil {
+			row.Result = ResultInvalid
+			row.Error = err.Error()
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Import reads the CSV in r and assigns each enrollment to its set.
+// Valid rows are assigned even if other rows are invalid.
+// No changes are made if dryRun is set. The changed enrollments are
+// notified at once after all rows are assigned.
+func (i *Importer) Import(ctx context.Context, r io.Reader, dryRun bool) (*Report, error) {
+	rows, err := ReadRows(r)
+	if err != nil {
+		return nil, err
+	}
+	logger := ctxlog.Logger(ctx, i.logger)
+	report := &Report{DryRun: dryRun, Rows: rows}
+	changedMap := make(map[string]struct{})
+	var changed []string
+	for n := range report.Rows {
+		row := &report.Rows[n]
+		if row.Result == ResultInvalid {
+			report.Invalid++
+			continue
+		}
+		if dryRun {
+			row.Result = ResultValid
+			continue
+		}
+		setChanged, err := i.store.StoreEnrollmentSet(ctx, row.EnrollmentID, row.Set)
+		if err != nil {
+			row.Result = ResultFailed
+			row.Error = err.Error()
+			report.Failed++
+			continue
+		}
+		if !setChanged {
+			row.Result = ResultUnchanged
+			report.Unchanged++
+			continue
+		}
+		row.Result = ResultAssigned
+		report.Assigned++
+		if _, ok := changedMap[row.EnrollmentID]; !ok {
+			changedMap[row.EnrollmentID] = struct{}{}
+			changed = append(changed, row.EnrollmentID)
+		}
+	}
+
+	logger.Debug(
+		logkeys.Message, "imported enrollment sets",
+		"dry_run", dryRun,
+		"rows", len(report.Rows),
+		"assigned", report.Assigned,
+		"invalid", report.Invalid,
+		"failed", report.Failed,
+	)
+
+	if i.notifier != nil && len(changed) > 0 {
+		if err = i.notifier.Changed(ctx, nil, nil, changed); err != nil {
+			logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+		} else {
+			report.Notified = len(changed)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,84 @@
+package enrollimport
+
+import (
+	"context"
+	"hash"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+type testNotifier struct {
+	ids []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, _ []string, _ []string, ids []string) error {
+	n.ids = append(n.ids, ids...)
+	return nil
+}
+
+const testCSV = `enrollment_id,set
+# a comment
+ENR1,set1
+ENR2, set1
+ENR1,set1
+,set2
+ENR3
+ENR3,set2
+ENR4,set#1
+`
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	store, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := new(testNotifier)
+	i := New(store, WithNotifier(n))
+
+	report, err := i.Import(ctx, strings.NewReader(testCSV), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := report.Invalid, 3; have != want {
+		t.Errorf("invalid: have: %v, want: %v", have, want)
+	}
+	if sets, err := store.RetrieveEnrollmentSets(ctx, "ENR1"); err != nil {
+		t.Fatal(err)
+	} else if len(sets) > 0 {
+		t.Errorf("dry run assigned sets: %v", sets)
+	}
+
+	report, err = i.Import(ctx, strings.NewReader(testCSV), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Row{
+		{Line: 3, EnrollmentID: "ENR1", Set: "set1", Result: ResultAssigned},
+		{Line: 4, EnrollmentID: "ENR2", Set: "set1", Result: ResultAssigned},
+		{Line: 5, EnrollmentID: "ENR1", Set: "set1", Result: ResultUnchanged},
+		{Line: 6, Set: "set2", Result: ResultInvalid, Error: "empty enrollment ID"},
+		{Line: 7, Result: ResultInvalid, Error: "expected 2 fields, got 1"},
+		{Line: 8, EnrollmentID: "ENR3", Set: "set2", Result: ResultAssigned},
+		{Line: 9, EnrollmentID: "ENR4", Set: "set#1", Result: ResultInvalid, Error: `invalid character in set name: "set#1"`},
+	}
+	if !reflect.DeepEqual(report.Rows, want) {
+		t.Errorf("have: %v, want: %v", report.Rows, want)
+	}
+	if report.Assigned != 3 || report.Unchanged != 1 || report.Invalid != 3 || report.Notified != 3 {
+		t.Errorf("unexpected report counts: %+v", report)
+	}
+	if have, want := n.ids, []string{"ENR1", "ENR2", "ENR3"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified: have: %v, want: %v", have, want)
+	}
+}
+
+func TestReadRowsMalformed(t *testing.T) {
+	if _, err := ReadRows(strings.NewReader("ENR1,\"set1\n")); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,115 @@
+// Package freeze serves frozen enrollments the DDM they were frozen with.
+//
+// While an enrollment is frozen changes to its sets and their
+// declarations do not change the tokens, declaration items, or
+// declarations it is served. Unfreezing serves the accumulated changes
+// at once.
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the DDM protocol storage that Store wraps.
+type Storage interface {
+	storage.DeclarationRetriever
+	storage.TokensDeclarationItemsRetriever
+}
+
+// Store serves the frozen DDM of frozen enrollments and the DDM of an
+// underlying Storage otherwise. It implements Storage itself.
+type Store struct {
+	store   Storage
+	freezes storage.EnrollmentFreezeRetriever
+}
+
+// New creates a new Store that serves frozen enrollments from freezes
+// and all other enrollments from store. It panics if either are nil.
+func New(store Storage, freezes storage.EnrollmentFreezeRetriever) *Store {
+	if store == nil || freezes == nil {
+		panic("nil store or freezes")
+	}
+	return &Store{store: store, freezes: freezes}
+}
+
+// RetrieveEnrollmentDeclarationJSON retrieves the declaration JSON for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	f, err := s.freezes.RetrieveEnrollmentFreeze(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment freeze: %w", err)
+	} else if f == nil {
+		return s.store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
+	}
+	raw, ok := f.Declarations[declarationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: not in enrollment freeze: %s", storage.ErrDeclarationNotFound, declarationID)
+	}
+	return raw, nil
+}
+
+// RetrieveDeclarationItemsJSON retrieves the Declaration Items for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	f, err := s.freezes.RetrieveEnrollmentFreeze(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment freeze: %w", err)
+	} else if f == nil {
+		return s.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	}
+	return f.DeclarationItemsJSON, nil
+}
+
+// RetrieveTokensJSON retrieves the Sync Tokens for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	f, err := s.freezes.RetrieveEnrollmentFreeze(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment freeze: %w", err)
+	} else if f == nil {
+		return s.store.RetrieveTokensJSON(ctx, enrollmentID)
+	}
+	return f.TokensJSON, nil
+}
+
+// Snapshot retrieves the DDM that store serves enrollmentID so that it
+// can be frozen.
+func Snapshot(ctx context.Context, store Storage, enrollmentID string) (*storage.EnrollmentFreeze, error) {
+	var err error
+	f := &storage.EnrollmentFreeze{
+		EnrollmentID: enrollmentID,
+		Timestamp:    time.Now().UTC().Truncate(time.Second),
+		Declarations: make(map[string]json.RawMessage),
+	}
+	if f.TokensJSON, err = store.RetrieveTokensJSON(ctx, enrollmentID); err != nil {
+		return nil, fmt.Errorf("retrieving tokens: %w", err)
+	}
+	if f.DeclarationItemsJSON, err = store.RetrieveDeclarationItemsJSON(ctx, enrollmentID); err != nil {
+		return nil, fmt.Errorf("retrieving declaration items: %w", err)
+	}
+	di := new(ddm.DeclarationItems)
+	if err = json.Unmarshal(f.DeclarationItemsJSON, di); err != nil {
+		return nil, fmt.Errorf("decoding declaration items: %w", err)
+	}
+	for manifestType, mds := range map[string][]ddm.ManifestDeclaration{
+		"activation":    di.Declarations.Activations,
+		"asset":         di.Declarations.Assets,
+		"configuration": di.Declarations.Configurations,
+		"management":    di.Declarations.Management,
+	} {
+		for _, md := range mds {
+			raw, err := store.RetrieveEnrollmentDeclarationJSON(ctx, md.Identifier, manifestType, enrollmentID)
+			if err != nil {
+				return nil, fmt.Errorf("retrieving declaration %s: %w", md.Identifier, err)
+			}
+			f.Declarations[md.Identifier] = raw
+		}
+	}
+	return f, nil
+}
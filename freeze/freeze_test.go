@@ -0,0 +1,93 @@
+package freeze
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"hash"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	const enrollmentID = "0E0D5F0C-51B5-4D1B-9B0E-6E2A6C1F0F7A"
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	storeDecl := func(raw string) {
+		t.Helper()
+		d, err := ddm.ParseDeclaration([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fs.StoreSetDeclaration(ctx, "default", d.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	storeDecl(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.frozen","Payload":{"Echo":"before"}}`)
+	if _, err = fs.StoreEnrollmentSet(ctx, enrollmentID, "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(fs, fs)
+	f, err := Snapshot(ctx, s, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fs.StoreEnrollmentFreeze(ctx, f); err != nil {
+		t.Fatal(err)
+	}
+
+	// change the declaration and add another while frozen
+	storeDecl(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.frozen","Payload":{"Echo":"after"}}`)
+	storeDecl(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.added","Payload":{"Echo":"added"}}`)
+
+	tokens, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tokens, f.TokensJSON) {
+		t.Errorf("tokens: have: %s, want: %s", tokens, f.TokensJSON)
+	}
+	di, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(di, f.DeclarationItemsJSON) {
+		t.Errorf("declaration items: have: %s, want: %s", di, f.DeclarationItemsJSON)
+	}
+	raw, err := s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.frozen", "configuration", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(raw, []byte("before")) {
+		t.Errorf("frozen declaration changed: %s", raw)
+	}
+	_, err = s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.added", "configuration", enrollmentID)
+	if !errors.Is(err, storage.ErrDeclarationNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrDeclarationNotFound)
+	}
+
+	// unfreezing serves the accumulated changes
+	if _, err = fs.DeleteEnrollmentFreeze(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if tokens, err = s.RetrieveTokensJSON(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(tokens, f.TokensJSON) {
+		t.Error("tokens unchanged after unfreezing")
+	}
+	if _, err = s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.added", "configuration", enrollmentID); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,70 @@
+package groupsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Mapping maps a directory group to a set.
+type Mapping struct {
+	// Group is the directory identifier of the group.
+	Group string `json:"group"`
+
+	Set string `json:"set"`
+}
+
+// Config is the group sync configuration.
+type Config struct {
+	// SCIM configures a SCIM 2.0 directory.
+	SCIM *SCIMConfig `json:"scim,omitempty"`
+
+	Mappings []Mapping `json:"mappings"`
+}
+
+// Directory returns the configured directory.
+func (c *Config) Directory() (Directory, error) {
+	if c.SCIM == nil {
+		return nil, errors.New("no directory configured")
+	}
+	return NewSCIM(c.SCIM)
+}
+
+// ReadConfig reads and validates a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding group sync config: %w", err)
+	}
+	if len(c.Mappings) < 1 {
+		return nil, errors.New("no mappings")
+	}
+	for i, m := range c.Mappings {
+		if m.Group == "" {
+			return nil, fmt.Errorf("mapping %d: empty group", i)
+		}
+		if err := storage.ValidateIdentifier("set name", m.Set); err != nil {
+			return nil, fmt.Errorf("mapping %d: %w", i, err)
+		}
+	}
+	if _, err := c.Directory(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads and validates a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
@@ -0,0 +1,193 @@
+// Package groupsync reconciles the enrollments of sets with the
+// members of groups in an external directory or identity provider.
+package groupsync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Directory retrieves the members of directory groups.
+// Implementations map directory members to enrollment IDs.
+type Directory interface {
+	// GroupMembers retrieves the enrollment IDs of the members of group.
+	GroupMembers(ctx context.Context, group string) ([]string, error)
+}
+
+// Storage is the storage needed to sync groups.
+type Storage interface {
+	storage.EnrollmentIDRetriever
+	storage.EnrollmentSetStorer
+	storage.EnrollmentSetRemover
+}
+
+// Notifier notifies enrollments of changed sets.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// Syncer reconciles the enrollments of sets with directory groups.
+// The mapped sets are managed by the syncer: enrollments that are not
+// members of any of the groups mapped to a set are removed from it.
+type Syncer struct {
+	store    Storage
+	dir      Directory
+	mappings []Mapping
+	notifier Notifier
+	logger   log.Logger
+}
+
+type Option func(s *Syncer)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(s *Syncer) {
+		s.logger = logger
+	}
+}
+
+// WithNotifier notifies enrollments whose sets changed.
+func WithNotifier(n Notifier) Option {
+	return func(s *Syncer) {
+		s.notifier = n
+	}
+}
+
+// New creates a new syncer of the group to set mappings from dir.
+func New(store Storage, dir Directory, mappings []Mapping, opts ...Option) *Syncer {
+	s := &Syncer{
+		store:    store,
+		dir:      dir,
+		mappings: mappings,
+		logger:   log.NopLogger,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// groupsBySet returns the groups mapped to each set.
+func (s *Syncer) groupsBySet() map[string][]string {
+	groups := make(map[string][]string)
+	for _, m := range s.mappings {
+		groups[m.Set] = append(groups[m.Set], m.Group)
+	}
+	return groups
+}
+
+// members retrieves the enrollment IDs of the members of groups.
+// Invalid enrollment IDs are logged and skipped.
+func (s *Syncer) members(ctx context.Context, logger log.Logger, groups []string) (map[string]struct{}, error) {
+	members := make(map[string]struct{})
+	for _, group := range groups {
+		ids, err := s.dir.GroupMembers(ctx, group)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving members of group %s: %w", group, err)
+		}
+		for _, id := range ids {
+			if err = storage.ValidateIdentifier("enrollment ID", id); err != nil {
+				logger.Info(logkeys.Message, "skipping group member", "group", group, logkeys.Error, err)
+				continue
+			}
+			members[id] = struct{}{}
+		}
+	}
+	return members, nil
+}
+
+// syncSet reconciles the enrollments of setName with the members of
+// groups. Returns the enrollment IDs that were added or removed.
+func (s *Syncer) syncSet(ctx context.Context, logger log.Logger, setName string, groups []string) ([]string, error) {
+	members, err := s.members(ctx, logger, groups)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.store.RetrieveEnrollmentIDs(ctx, nil, []string{setName}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollments: %w", err)
+	}
+	var changedIDs []string
+	for _, id := range current {
+		if _, ok := members[id]; ok {
+			delete(members, id)
+			continue
+		}
+		changed, err := s.store.RemoveEnrollmentSet(ctx, id, setName)
+		if err != nil {
+			return changedIDs, fmt.Errorf("removing enrollment %s: %w", id, err)
+		}
+		if changed {
+			changedIDs = append(changedIDs, id)
+		}
+	}
+	// members now only has the enrollments to add
+	for id := range members {
+		changed, err := s.store.StoreEnrollmentSet(ctx, id, setName)
+		if err != nil {
+			return changedIDs, fmt.Errorf("adding enrollment %s: %w", id, err)
+		}
+		if changed {
+			changedIDs = append(changedIDs, id)
+		}
+	}
+	return changedIDs, nil
+}
+
+// Run reconciles the enrollments of each mapped set with the members
+// of its groups. A set is not changed if any of its groups could not be
+// retrieved. Errors with individual sets are logged and the remaining
+// sets are still synced. Changed enrollments are notified once.
+func (s *Syncer) Run(ctx context.Context) error {
+	logger := ctxlog.Logger(ctx, s.logger)
+	changedIDs := make(map[string]struct{})
+	groups := s.groupsBySet()
+	for setName, setGroups := range groups {
+		logger := logger.With("set", setName)
+		ids, err := s.syncSet(ctx, logger, setName, setGroups)
+		for _, id := range ids {
+			changedIDs[id] = struct{}{}
+		}
+		if err != nil {
+			logger.Info(logkeys.Message, "syncing set", logkeys.Error, err)
+			continue
+		}
+		logger.Debug(logkeys.Message, "synced set", "changed", len(ids))
+	}
+	if len(changedIDs) < 1 || s.notifier == nil {
+		return nil
+	}
+	ids := make([]string, 0, len(changedIDs))
+	for id := range changedIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	if err := s.notifier.Changed(ctx, nil, nil, ids); err != nil {
+		return fmt.Errorf("notifying enrollments: %w", err)
+	}
+	return nil
+}
+
+// Watch syncs immediately and then every interval until ctx is done.
+func (s *Syncer) Watch(ctx context.Context, interval time.Duration) {
+	logger := ctxlog.Logger(ctx, s.logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Run(ctx); err != nil {
+			logger.Info(logkeys.Message, "syncing groups", logkeys.Error, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package groupsync
+
+import (
+	"context"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+type testNotifier struct {
+	calls int
+	ids   []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, _ []string, _ []string, ids []string) error {
+	n.calls++
+	n.ids = append(n.ids, ids...)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	groups := map[string]string{
+		"g1": `{"members":[{"value":"E1","display":"one"},{"value":"E2","display":"two"}]}`,
+		"g2": `{"members":[{"value":"E3","display":"three"},{"value":"../bad","display":"bad"}]}`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		g, ok := groups[strings.TrimPrefix(r.URL.Path, "/scim/v2/Groups/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(g))
+	}))
+	defer srv.Close()
+
+	t.Setenv("GROUPSYNC_TEST_TOKEN", "s3cr3t")
+	config, err := ReadConfig(strings.NewReader(`{
+		"scim": {"url": "` + srv.URL + `/scim/v2/", "token_env": "GROUPSYNC_TEST_TOKEN"},
+		"mappings": [
+			{"group": "g1", "set": "staff"},
+			{"group": "g2", "set": "staff"},
+			{"group": "missing", "set": "other"}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := config.Directory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const testPath = "teststor"
+	defer os.RemoveAll(testPath)
+	store, err := file.New(testPath, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// E4 is not in a group and is removed; E5 is in a set whose group is missing
+	if _, err = store.StoreEnrollmentSet(ctx, "E4", "staff"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, "E5", "other"); err != nil {
+		t.Fatal(err)
+	}
+
+	n := new(testNotifier)
+	s := New(store, dir, config.Mappings, WithNotifier(n))
+
+	setIDs := func(setName string) []string {
+		t.Helper()
+		ids, err := store.RetrieveEnrollmentIDs(ctx, nil, []string{setName}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	if err = s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := setIDs("staff"), []string{"E1", "E2", "E3"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("staff: have: %v, want: %v", have, want)
+	}
+	if have, want := setIDs("other"), []string{"E5"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("other: have: %v, want: %v", have, want)
+	}
+	if have, want := n.ids, []string{"E1", "E2", "E3", "E4"}; n.calls != 1 || !reflect.DeepEqual(have, want) {
+		t.Errorf("notified: have: %d calls of %v, want: 1 call of %v", n.calls, have, want)
+	}
+
+	// syncing again changes nothing
+	groups["g1"] = `{"members":[{"value":"E1"}]}`
+	n = new(testNotifier)
+	s = New(store, dir, config.Mappings, WithNotifier(n))
+	if err = s.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := n.ids, []string{"E2"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified: have: %v, want: %v", have, want)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+	}{
+		{"no mappings", `{"scim":{"url":"https://example.com/scim/v2"},"mappings":[]}`},
+		{"no directory", `{"mappings":[{"group":"g","set":"s"}]}`},
+		{"invalid set", `{"scim":{"url":"https://example.com/scim/v2"},"mappings":[{"group":"g","set":"a/b"}]}`},
+		{"invalid attribute", `{"scim":{"url":"https://example.com/scim/v2","member_attribute":"x"},"mappings":[{"group":"g","set":"s"}]}`},
+		{"unknown field", `{"scim":{"url":"https://example.com/scim/v2"},"mappings":[{"group":"g","set":"s"}],"x":1}`},
+	} {
+		if _, err := ReadConfig(strings.NewReader(tc.config)); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package groupsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// SCIM member attributes.
+const (
+	// SCIMMemberValue uses the "value" (the resource ID) of group members.
+	SCIMMemberValue = "value"
+
+	// SCIMMemberDisplay uses the "display" name of group members.
+	SCIMMemberDisplay = "display"
+)
+
+// SCIMConfig configures a SCIM 2.0 (RFC 7644) directory.
+type SCIMConfig struct {
+	// URL is the base URL of the SCIM service (e.g. https://idp.example.com/scim/v2).
+	URL string `json:"url"`
+
+	// TokenEnv is the name of the environment variable containing the
+	// bearer token of the SCIM service.
+	TokenEnv string `json:"token_env,omitempty"`
+
+	// MemberAttribute is the attribute of group members that is the
+	// enrollment ID: "value" (the default) or "display".
+	MemberAttribute string `json:"member_attribute,omitempty"`
+}
+
+// SCIM retrieves group members from a SCIM 2.0 directory.
+type SCIM struct {
+	client    *http.Client
+	url       string
+	token     string
+	attribute string
+}
+
+// NewSCIM creates a new SCIM directory from config.
+func NewSCIM(config *SCIMConfig) (*SCIM, error) {
+	if config == nil {
+		return nil, errors.New("nil SCIM config")
+	}
+	u, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing SCIM URL: %w", err)
+	} else if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid SCIM URL: %q", config.URL)
+	}
+	s := &SCIM{
+		client:    http.DefaultClient,
+		url:       strings.TrimRight(config.URL, "/"),
+		attribute: config.MemberAttribute,
+	}
+	if config.TokenEnv != "" {
+		s.token = os.Getenv(config.TokenEnv)
+	}
+	switch s.attribute {
+	case "":
+		s.attribute = SCIMMemberValue
+	case SCIMMemberValue, SCIMMemberDisplay:
+	default:
+		return nil, fmt.Errorf("invalid SCIM member attribute: %q", s.attribute)
+	}
+	return s, nil
+}
+
+// scimGroup is the subset of a SCIM Group resource needed to sync.
+type scimGroup struct {
+	Members []struct {
+		Value   string `json:"value"`
+		Display string `json:"display"`
+	} `json:"members"`
+}
+
+// GroupMembers retrieves the member attribute of the members of group.
+func (s *SCIM) GroupMembers(ctx context.Context, group string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url+"/Groups/"+url.PathEscape(group)+"?attributes=members", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/scim+json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("SCIM: unexpected HTTP status: %s", resp.Status)
+	}
+	g := new(scimGroup)
+	if err = json.NewDecoder(resp.Body).Decode(g); err != nil {
+		return nil, fmt.Errorf("decoding SCIM group: %w", err)
+	}
+	members := make([]string, 0, len(g.Members))
+	for _, m := range g.Members {
+		if s.attribute == SCIMMemberDisplay {
+			members = append(members, m.Display)
+		} else {
+			members = append(members, m.Value)
+		}
+	}
+	return members, nil
+}
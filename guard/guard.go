@@ -0,0 +1,174 @@
+// Package guard checks the declarations and set assignments stored
+// through the API (e.g. against policies) before they are stored.
+// Checks are made in the storage path rather than per API route so
+// that every route that stores declarations or assigns them to sets
+// is checked alike.
+package guard
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// DeclarationChecker checks declarations before they are stored.
+type DeclarationChecker interface {
+	// CheckDeclaration checks storing d. Returning an error prevents
+	// storing d and is returned to the caller.
+	CheckDeclaration(ctx context.Context, d *ddm.Declaration) error
+}
+
+// SetDeclarationChecker checks set assignments before they are stored.
+type SetDeclarationChecker interface {
+	// CheckSetDeclaration checks assigning (or unassigning if assign
+	// is false) declarationID to setName. Returning an error prevents
+	// the change and is returned to the caller.
+	CheckSetDeclaration(ctx context.Context, setName, declarationID string, assign bool) error
+}
+
+// Storage is the storage that Guard checks changes to.
+type Storage interface {
+	storage.DeclarationStorer
+	storage.SetDeclarationStorer
+	storage.SetDeclarationRemover
+	storage.SetDeclarationsRetriever
+	storage.SetSnapshotStorage
+}
+
+// Guard wraps storage. Declarations and set assignments are checked
+// by the checkers before they are passed on to storage.
+type Guard struct {
+	store           Storage
+	declCheckers    []DeclarationChecker
+	setDeclCheckers []SetDeclarationChecker
+}
+
+type Option func(*Guard)
+
+// WithDeclarationChecker adds checker to the checkers of declarations.
+// Checkers are run in the order they are added.
+func WithDeclarationChecker(checker DeclarationChecker) Option {
+	return func(g *Guard) {
+		g.declCheckers = append(g.declCheckers, checker)
+	}
+}
+
+// WithSetDeclarationChecker adds checker to the checkers of set assignments.
+// Checkers are run in the order they are added.
+func WithSetDeclarationChecker(checker SetDeclarationChecker) Option {
+	return func(g *Guard) {
+		g.setDeclCheckers = append(g.setDeclCheckers, checker)
+	}
+}
+
+// New creates a new Guard of store.
+func New(store Storage, opts ...Option) *Guard {
+	if store == nil {
+		panic("nil store")
+	}
+	g := &Guard{store: store}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// checkSetDeclaration runs the set assignment checkers.
+func (g *Guard) checkSetDeclaration(ctx context.Context, setName, declarationID string, assign bool) error {
+	for _, c := range g.setDeclCheckers {
+		if err := c.CheckSetDeclaration(ctx, setName, declarationID, assign); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StoreDeclaration checks d then stores it.
+func (g *Guard) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	for _, c := range g.declCheckers {
+		if err := c.CheckDeclaration(ctx, d); err != nil {
+			return false, err
+		}
+	}
+	return g.store.StoreDeclaration(ctx, d)
+}
+
+// StoreSetDeclaration checks assigning declarationID to setName then assigns it.
+func (g *Guard) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := g.checkSetDeclaration(ctx, setName, declarationID, true); err != nil {
+		return false, err
+	}
+	return g.store.StoreSetDeclaration(ctx, setName, declarationID)
+}
+
+// RemoveSetDeclaration checks unassigning declarationID from setName then unassigns it.
+func (g *Guard) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := g.checkSetDeclaration(ctx, setName, declarationID, false); err != nil {
+		return false, err
+	}
+	return g.store.RemoveSetDeclaration(ctx, setName, declarationID)
+}
+
+// checkRestoreSetSnapshot runs the set assignment checkers for the
+// declarations that restoring the snapshot with snapshotID of setName
+// assigns and unassigns. A missing snapshot is not checked so that
+// storage may report it.
+func (g *Guard) checkRestoreSetSnapshot(ctx context.Context, setName, snapshotID string) error {
+	snapshots, err := g.store.RetrieveSetSnapshots(ctx, setName)
+	if err != nil {
+		return fmt.Errorf("retrieving set snapshots: %w", err)
+	}
+	var restored []string
+	var found bool
+	for _, snapshot := range snapshots {
+		if snapshot.ID == snapshotID {
+			restored, found = snapshot.Declarations, true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	current, err := g.store.RetrieveSetDeclarations(ctx, setName)
+	if err != nil {
+		return fmt.Errorf("retrieving set declarations: %w", err)
+	}
+	for _, id := range restored {
+		if !contains(current, id) {
+			if err = g.checkSetDeclaration(ctx, setName, id, true); err != nil {
+				return err
+			}
+		}
+	}
+	for _, id := range current {
+		if !contains(restored, id) {
+			if err = g.checkSetDeclaration(ctx, setName, id, false); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// contains reports whether s contains v.
+func contains(s []string, v string) bool {
+	for _, have := range s {
+		if have == v {
+			return true
+		}
+	}
+	return false
+}
+
+// RestoreSetSnapshot checks the set assignments that restoring the
+// snapshot with snapshotID of setName makes then restores it.
+func (g *Guard) RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error) {
+	if len(g.setDeclCheckers) > 0 {
+		if err := g.checkRestoreSetSnapshot(ctx, setName, snapshotID); err != nil {
+			return false, err
+		}
+	}
+	return g.store.RestoreSetSnapshot(ctx, setName, snapshotID)
+}
@@ -0,0 +1,232 @@
+package guard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/declsync"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	apihttp "github.com/jessepeterson/kmfddm/http/api"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/policy"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+// guardedFile is file storage guarded by a Guard.
+type guardedFile struct {
+	*file.File
+	guard *Guard
+}
+
+func (s *guardedFile) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	return s.guard.StoreDeclaration(ctx, d)
+}
+
+func (s *guardedFile) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.guard.StoreSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *guardedFile) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	return s.guard.RemoveSetDeclaration(ctx, setName, declarationID)
+}
+
+func (s *guardedFile) RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error) {
+	return s.guard.RestoreSetSnapshot(ctx, setName, snapshotID)
+}
+
+func newFile(t *testing.T) *file.File {
+	t.Helper()
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// noWifi denies storing and assigning Wi-Fi declarations.
+var noWifi = []*policy.Policy{{
+	Name:             "no-wifi",
+	Effect:           policy.EffectDeny,
+	DeclarationTypes: []string{"com.apple.configuration.wifi"},
+}}
+
+func newGuardedFile(t *testing.T, policies []*policy.Policy) *guardedFile {
+	t.Helper()
+	fs := newFile(t)
+	e := policy.New(policies, fs)
+	return &guardedFile{File: fs, guard: New(fs, WithDeclarationChecker(e), WithSetDeclarationChecker(e))}
+}
+
+func storeSet(t *testing.T, store storage.DeclarationStorer, setStore storage.SetDeclarationStorer, setName string, decls ...string) {
+	t.Helper()
+	ctx := context.Background()
+	for _, decl := range decls {
+		d, err := ddm.ParseDeclaration([]byte(decl))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = store.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = setStore.StoreSetDeclaration(ctx, setName, d.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+const (
+	testPasscode = `{"Type":"com.apple.configuration.passcode.settings","Identifier":"com.example.passcode","Payload":{}}`
+	testWifi     = `{"Type":"com.apple.configuration.wifi","Identifier":"com.example.wifi","Payload":{}}`
+)
+
+func TestSetBundle(t *testing.T) {
+	ctx := context.Background()
+
+	// export a bundle of a set with a denied declaration
+	src := newFile(t)
+	storeSet(t, src, src, "baseline", testPasscode, testWifi)
+	var bundle bytes.Buffer
+	if err := declsync.WriteBundle(ctx, &bundle, src, "baseline"); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newGuardedFile(t, noWifi)
+	h := httpddm.BasicAuthMiddleware(apihttp.PutSetBundleHandler(store, nil, log.NopLogger), "kmfddm", "secret", "test")
+	r := httptest.NewRequest(http.MethodPut, "/v1/set-bundle?nonotify=1", &bundle)
+	r.SetBasicAuth("kmfddm", "secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status: have: %d, want: %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	report := new(declsync.Report)
+	if err := json.Unmarshal(w.Body.Bytes(), report); err != nil {
+		t.Fatal(err)
+	}
+	var denied bool
+	for _, change := range report.Declarations {
+		if change.Declaration == "com.example.wifi" {
+			denied = change.Error != ""
+		}
+	}
+	if !denied {
+		t.Errorf("expected denied declaration change: %+v", report.Declarations)
+	}
+
+	if _, err := store.RetrieveDeclaration(ctx, "com.example.wifi"); err == nil {
+		t.Error("denied declaration stored")
+	}
+	ids, err := store.RetrieveSetDeclarations(ctx, "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "com.example.passcode" {
+		t.Errorf("set declarations: have: %v, want: [com.example.passcode]", ids)
+	}
+}
+
+func TestRestoreSetSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := newGuardedFile(t, nil)
+	// store the Wi-Fi declaration before the policy applied
+	storeSet(t, store, store, "baseline", testPasscode, testWifi)
+	if _, err := store.RemoveSetDeclaration(ctx, "baseline", "com.example.wifi"); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := store.RetrieveSetSnapshots(ctx, "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) < 2 {
+		t.Fatalf("snapshots: have: %d, want at least: 2", len(snapshots))
+	}
+	e := policy.New(noWifi, store.File)
+	store.guard = New(store.File, WithSetDeclarationChecker(e))
+
+	// restoring the snapshot with the Wi-Fi declaration assigns it
+	var withWifi string
+	for _, snapshot := range snapshots {
+		if len(snapshot.Declarations) == 2 {
+			withWifi = snapshot.ID
+		}
+	}
+	_, err = store.RestoreSetSnapshot(ctx, "baseline", withWifi)
+	if !errors.Is(err, storage.ErrForbidden) {
+		t.Errorf("restore: have: %v, want: %v", err, storage.ErrForbidden)
+	}
+	ids, err := store.RetrieveSetDeclarations(ctx, "baseline")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Errorf("set declarations: have: %v, want: [com.example.passcode]", ids)
+	}
+}
+
+func TestApprovedChange(t *testing.T) {
+	ctx := context.Background()
+	store := newGuardedFile(t, []*policy.Policy{{
+		Name:             "no-wifi-alice",
+		Effect:           policy.EffectDeny,
+		Principals:       []string{"alice"},
+		DeclarationTypes: []string{"com.apple.configuration.wifi"},
+	}})
+	principals := []httpddm.Principal{
+		{Name: "alice", Key: "secret", ApprovalRequired: true},
+		{Name: "bob", Key: "secret"},
+	}
+
+	mux := flow.New()
+	mux.Use(func(h http.Handler) http.Handler {
+		return httpddm.PrincipalBasicAuthMiddleware(h, principals, "test")
+	})
+	mux.Handle("/v1/pending-changes/:id/approve", apihttp.ApprovePendingChangeHandler(store, mux, log.NopLogger), "POST")
+	mux.Group(func(mux *flow.Mux) {
+		mux.Use(func(h http.Handler) http.Handler {
+			return httpddm.ApprovalMiddleware(h, store, nil, log.NopLogger)
+		})
+		mux.Handle("/v1/declarations", apihttp.PutDeclarationHandler(store, nil, log.NopLogger), "PUT")
+	})
+
+	serve := func(method, target, name string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, bytes.NewReader(body))
+		r.SetBasicAuth(name, "secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodPut, "/v1/declarations?nonotify=1", "alice", []byte(testWifi))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status: have: %d, want: %d: %s", w.Code, http.StatusAccepted, w.Body.String())
+	}
+	change := new(httpddm.PendingChangeJSON)
+	if err := json.Unmarshal(w.Body.Bytes(), change); err != nil {
+		t.Fatal(err)
+	}
+
+	// the change is checked as alice rather than the approving bob
+	w = serve(http.MethodPost, "/v1/pending-changes/"+change.ID+"/approve", "bob", nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status: have: %d, want: %d: %s", w.Code, http.StatusForbidden, w.Body.String())
+	}
+	if _, err := store.RetrieveDeclaration(ctx, "com.example.wifi"); err == nil {
+		t.Error("denied declaration stored")
+	}
+
+	// bob may store it
+	w = serve(http.MethodPut, "/v1/declarations?nonotify=1", "bob", []byte(testWifi))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status: have: %d, want: %d: %s", w.Code, http.StatusNoContent, w.Body.String())
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxAnnotationSize is the maximum size of an enrollment annotation request body.
+const maxAnnotationSize = 16384
+
+// GetEnrollmentAnnotationsHandler returns a handler that retrieves the
+// annotations of enrollment IDs keyed by enrollment ID. Like the status
+// handlers the resource ID may be a comma-separated list of enrollment
+// IDs so that annotations can be retrieved alongside status.
+func GetEnrollmentAnnotationsHandler(store storage.EnrollmentAnnotationRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveEnrollmentAnnotations(ctx, strings.Split(resource, ","))
+		},
+	)
+}
+
+// PutEnrollmentAnnotationHandler returns a handler that stores the
+// enrollment annotation in the JSON request body. Any existing
+// annotation of the enrollment is replaced. The stored annotation is
+// returned. The enrollment ID is the resource ID.
+func PutEnrollmentAnnotationHandler(store storage.EnrollmentAnnotationStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		enrollmentID := getResourceID(r)
+		if err := storage.ValidateIdentifier("enrollment ID", enrollmentID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With(logkeys.EnrollmentID, enrollmentID)
+		annotation := new(storage.EnrollmentAnnotation)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxAnnotationSize)).Decode(annotation); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding enrollment annotation", logger)
+			return
+		}
+		annotation.EnrollmentID = enrollmentID
+		annotation.Timestamp = time.Now().UTC().Truncate(time.Second)
+		if err := annotation.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		if err := store.StoreEnrollmentAnnotation(r.Context(), annotation); err != nil {
+			jsonErrorAndLog(w, 0, err, "storing enrollment annotation", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "stored enrollment annotation")
+		if err := jsonResponse(w, 0, annotation); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// DeleteEnrollmentAnnotationHandler returns a handler that deletes the
+// annotation of an enrollment. The enrollment ID is the resource ID.
+func DeleteEnrollmentAnnotationHandler(store storage.EnrollmentAnnotationStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			changed, err := store.DeleteEnrollmentAnnotation(ctx, resource)
+			return changed, "delete enrollment annotation", err
+		},
+	)
+}
@@ -12,15 +12,22 @@ import (
 	"strings"
 
 	"github.com/alexedwards/flow"
+	httpddm "github.com/jessepeterson/kmfddm/http"
 	"github.com/jessepeterson/kmfddm/log"
 	"github.com/jessepeterson/kmfddm/log/ctxlog"
 	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 type Notifier interface {
 	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
 }
 
+// AdminEvents is notified of significant administrative events.
+type AdminEvents interface {
+	SetDeclarationRemoved(ctx context.Context, setName, declarationID string)
+}
+
 const (
 	jsonContentType = "application/json"
 )
@@ -43,11 +50,12 @@ type jsonErrorStruct struct {
 }
 
 // jsonError encodes err to JSON and writes to w.
-// Status defaults to Internal Server Error if a positive HTTP status
+// Status defaults to that of the storage error category of err
+// (Internal Server Error if uncategorized) if a positive HTTP status
 // is not provided.
 func jsonError(w http.ResponseWriter, status int, err error) error {
 	if status < 1 {
-		status = http.StatusInternalServerError
+		status = httpddm.StorageErrorStatus(err)
 	}
 	return jsonResponse(w, status, &jsonErrorStruct{Err: err.Error()})
 }
@@ -76,6 +84,16 @@ func getResourceID(r *http.Request) string {
 	return flow.Param(r.Context(), "id")
 }
 
+// validateResourceIDs validates the comma-separated identifiers of resource.
+func validateResourceIDs(resource string) error {
+	for _, id := range strings.Split(resource, ",") {
+		if err := storage.ValidateIdentifier("resource identifier", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type dataFunc func(context.Context, string, *url.URL) (interface{}, error)
 
 func simpleJSONResourceHandler(logger log.Logger, dataFn dataFunc) http.HandlerFunc {
@@ -88,6 +106,10 @@ func simpleJSONResourceHandler(logger log.Logger, dataFn dataFunc) http.HandlerF
 			return
 		}
 		logger = logger.With("resource", resource)
+		if err = validateResourceIDs(resource); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
 		if dataFn == nil {
 			err = errors.New("no data retrieval function defined")
 			jsonErrorAndLog(w, http.StatusInternalServerError, err, "validating input", logger)
@@ -95,9 +117,15 @@ func simpleJSONResourceHandler(logger log.Logger, dataFn dataFunc) http.HandlerF
 		}
 		data, err := dataFn(r.Context(), resource, r.URL)
 		if err != nil {
-			jsonErrorAndLog(w, http.StatusInternalServerError, err, "retrieving data", logger)
+			jsonErrorAndLog(w, 0, err, "retrieving data", logger)
 			return
 		}
+		if fields := parseFields(r.URL.Query()); fields != nil && data != nil {
+			if data, err = selectFields(data, fields, false); err != nil {
+				jsonErrorAndLog(w, http.StatusInternalServerError, err, "selecting fields", logger)
+				return
+			}
+		}
 		if data == nil {
 			logger.Debug("msg", "no content")
 			w.WriteHeader(http.StatusNoContent)
@@ -124,6 +152,10 @@ func simpleChangeResourceHandler(logger log.Logger, chgFn changeFunc) http.Handl
 			return
 		}
 		logger = logger.With("resource", resource)
+		if err = storage.ValidateIdentifier("resource identifier", resource); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
 		if chgFn == nil {
 			err = errors.New("no data change function defined")
 			jsonErrorAndLog(w, http.StatusInternalServerError, err, "validating input", logger)
@@ -134,7 +166,7 @@ func simpleChangeResourceHandler(logger log.Logger, chgFn changeFunc) http.Handl
 		chFnLogger := logger.With("msg", dataName, "changed", changed, "notify", changed && notify)
 		if err != nil {
 			chFnLogger.Info("err", err)
-			err = jsonError(w, http.StatusInternalServerError, err)
+			err = jsonError(w, 0, err)
 			if err != nil {
 				logger.Info("msg", "writing response json", "err", err)
 			}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	// maxBatchIDs is the maximum number of enrollment IDs that may be
+	// resolved by a single batch request (before pagination).
+	maxBatchIDs = 50000
+
+	// defaultBatchLimit is the number of enrollment IDs returned per
+	// page of a batch request if no limit is given.
+	defaultBatchLimit = 1000
+
+	// batchChunkSize is the number of enrollment IDs we query the
+	// storage backend for at once.
+	batchChunkSize = 100
+
+	// NextOffsetHeader contains the offset of the next page of a batch request.
+	NextOffsetHeader = "X-Next-Offset"
+
+	// TotalCountHeader contains the total number of resolved enrollment IDs of a batch request.
+	TotalCountHeader = "X-Total-Count"
+)
+
+var ErrNoBatchIDs = errors.New("no enrollment ids or sets provided")
+
+// BatchRequest is the JSON request body of batch status requests.
+type BatchRequest struct {
+	// IDs are enrollment IDs.
+	IDs []string `json:"ids,omitempty"`
+
+	// Sets are set names. The enrollment IDs of these sets are combined with IDs.
+	Sets []string `json:"sets,omitempty"`
+
+	// Prefix limits status values to a path prefix.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Filter limits status values to those matching a typed comparison.
+	Filter *storage.StatusValueFilter `json:"filter,omitempty"`
+
+	// Sort orders status values by their typed value ("value" or "-value").
+	Sort string `json:"sort,omitempty"`
+
+	// Since and Until limit status errors to a time range.
+	Since time.Time `json:"since,omitempty"`
+	Until time.Time `json:"until,omitempty"`
+
+	// Offset and Limit page through the (sorted) resolved enrollment IDs.
+	Offset int `json:"offset,omitempty"`
+	Limit  int `json:"limit,omitempty"`
+
+	// Cursor pages through the resolved enrollment IDs starting after
+	// the previous page. It is used instead of Offset.
+	Cursor string `json:"cursor,omitempty"`
+
+	// Fields selects the fields of the results of each enrollment.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// batchFunc retrieves the data for a chunk of enrollment IDs. The
+// returned map is keyed by enrollment ID.
+type batchFunc func(ctx context.Context, ids []string, req *BatchRequest) (map[string]interface{}, error)
+
+// resolveBatchIDs returns the unique and sorted enrollment IDs of req.
+func resolveBatchIDs(ctx context.Context, store storage.EnrollmentIDRetriever, req *BatchRequest) ([]string, error) {
+	idMap := make(map[string]struct{})
+	for _, id := range req.IDs {
+		if id != "" {
+			idMap[id] = struct{}{}
+		}
+	}
+	if len(req.Sets) > 0 {
+		setIDs, err := store.RetrieveEnrollmentIDs(ctx, nil, req.Sets, nil)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving set enrollment ids: %w", err)
+		}
+		for _, id := range setIDs {
+			idMap[id] = struct{}{}
+		}
+	}
+	if len(idMap) > maxBatchIDs {
+		return nil, fmt.Errorf("too many enrollment ids: %d (max %d)", len(idMap), maxBatchIDs)
+	}
+	ids := make([]string, 0, len(idMap))
+	for id := range idMap {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// batchJSONHandler returns a handler that resolves enrollment IDs from
+// a JSON BatchRequest body and streams the results of dataFn as a JSON
+// object keyed by enrollment ID. The storage backend is queried in
+// chunks of enrollment IDs so that very large requests do not need to
+// be fully held in memory. Because the results are streamed, errors
+// that happen after output has begun truncate the response.
+func batchJSONHandler(store storage.EnrollmentIDRetriever, logger log.Logger, dataFn batchFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		req := new(BatchRequest)
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding request", logger)
+			return
+		}
+		if len(req.IDs) < 1 && len(req.Sets) < 1 {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrNoBatchIDs, "validating input", logger)
+			return
+		}
+		if req.Offset < 0 || req.Limit < 0 {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("invalid offset or limit"), "validating input", logger)
+			return
+		}
+		var after string
+		if req.Cursor != "" {
+			var err error
+			if after, err = decodeCursor(req.Cursor); err != nil {
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+			if req.Offset > 0 {
+				jsonErrorAndLog(w, http.StatusBadRequest, errors.New("cursor and offset both set"), "validating input", logger)
+				return
+			}
+		}
+		if err := validateStatusValueQuery(req.Filter, req.Sort); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		if !req.Since.IsZero() && !req.Until.IsZero() && req.Since.After(req.Until) {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("since after until"), "validating input", logger)
+			return
+		}
+		if req.Limit == 0 {
+			req.Limit = defaultBatchLimit
+		}
+		ids, err := resolveBatchIDs(r.Context(), store, req)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "resolving enrollment ids", logger)
+			return
+		}
+		total := len(ids)
+		if req.Cursor != "" {
+			ids = ids[sort.SearchStrings(ids, after):]
+			if len(ids) > 0 && ids[0] == after {
+				ids = ids[1:]
+			}
+		} else {
+			if req.Offset > len(ids) {
+				req.Offset = len(ids)
+			}
+			ids = ids[req.Offset:]
+		}
+		if len(ids) > req.Limit {
+			ids = ids[:req.Limit]
+			if req.Cursor == "" {
+				w.Header().Set(NextOffsetHeader, strconv.Itoa(req.Offset+req.Limit))
+			}
+			w.Header().Set(NextCursorHeader, encodeCursor(ids[len(ids)-1]))
+		}
+		w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+		logger = logger.With(logkeys.GenericCount, len(ids), "total", total, "offset", req.Offset)
+
+		fields := fieldSet(req.Fields)
+
+		w.Header().Set("Content-type", jsonContentType)
+		w.Write([]byte{'{'})
+		first := true
+		for _, chunk := range storage.ChunkStrings(ids, batchChunkSize) {
+			data, err := dataFn(r.Context(), chunk, req)
+			if err != nil {
+				logger.Info(logkeys.Message, "retrieving data", logkeys.Error, err)
+				return
+			}
+			for _, id := range chunk {
+				v, ok := data[id]
+				if !ok {
+					continue
+				}
+				idJSON, _ := json.Marshal(id)
+				if fields != nil {
+					if v, err = selectFields(v, fields, false); err != nil {
+						logger.Info(logkeys.Message, "selecting fields", logkeys.Error, err)
+						return
+					}
+				}
+				vJSON, err := json.Marshal(v)
+				if err != nil {
+					logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+					return
+				}
+				if !first {
+					w.Write([]byte{','})
+				}
+				first = false
+				w.Write(idJSON)
+				w.Write([]byte{':'})
+				w.Write(vJSON)
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		w.Write([]byte("}\n"))
+		logger.Debug(logkeys.Message, "batch retrieved")
+	}
+}
+
+// BatchStatusStorage is the storage needed for batch status queries.
+type BatchStatusStorage interface {
+	storage.EnrollmentIDRetriever
+	storage.StatusAPIStorage
+}
+
+// BatchDeclarationStatusHandler returns a handler that retrieves the
+// declaration status for many enrollment IDs (or sets) given in a JSON body.
+func BatchDeclarationStatusHandler(store BatchStatusStorage, logger log.Logger) http.HandlerFunc {
+	return batchJSONHandler(store, logger, func(ctx context.Context, ids []string, _ *BatchRequest) (map[string]interface{}, error) {
+		statuses, err := store.RetrieveDeclarationStatus(ctx, ids)
+		ret := make(map[string]interface{}, len(statuses))
+		for k, v := range statuses {
+			ret[k] = v
+		}
+		return ret, err
+	})
+}
+
+// batchStatusErrorsLimit is the number of errors retrieved per
+// enrollment by batch status error queries. It mirrors the limit of
+// the single-request handler.
+const batchStatusErrorsLimit = 10
+
+// BatchStatusErrorsHandler returns a handler that retrieves the
+// collected errors for many enrollment IDs (or sets) given in a JSON
+// body. At most batchStatusErrorsLimit errors are retrieved per enrollment.
+func BatchStatusErrorsHandler(store BatchStatusStorage, logger log.Logger) http.HandlerFunc {
+	return batchJSONHandler(store, logger, func(ctx context.Context, ids []string, req *BatchRequest) (map[string]interface{}, error) {
+		statusErrors, err := store.RetrieveStatusErrors(ctx, ids, req.Since, req.Until, 0, batchStatusErrorsLimit)
+		ret := make(map[string]interface{}, len(statusErrors))
+		for k, v := range statusErrors {
+			ret[k] = v
+		}
+		return ret, err
+	})
+}
+
+// BatchStatusValuesHandler returns a handler that retrieves the
+// collected values for many enrollment IDs (or sets) given in a JSON body.
+func BatchStatusValuesHandler(store BatchStatusStorage, logger log.Logger) http.HandlerFunc {
+	return batchJSONHandler(store, logger, func(ctx context.Context, ids []string, req *BatchRequest) (map[string]interface{}, error) {
+		values, err := store.RetrieveStatusValues(ctx, ids, req.Prefix)
+		values = filterAndSortStatusValues(values, req.Filter, req.Sort)
+		ret := make(map[string]interface{}, len(values))
+		for k, v := range values {
+			ret[k] = v
+		}
+		return ret, err
+	})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/builder"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// GetDeclarationBuildersHandler returns a handler that lists the names of the declaration builders.
+func GetDeclarationBuildersHandler(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		if err := jsonResponse(w, 0, builder.Names()); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PostDeclarationBuilderHandler returns a handler that builds a
+// declaration with the builder named by the resource ID from the
+// payload fields in the JSON body. The "identifier" query parameter is
+// the identifier of the declaration. The declaration is returned and
+// not stored.
+func PostDeclarationBuilderHandler(logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		name := getResourceID(r)
+		if name == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+			return
+		}
+		logger = logger.With("builder", name)
+		payload, ok := builder.New(name)
+		if !ok {
+			jsonErrorAndLog(w, http.StatusNotFound, fmt.Errorf("unknown builder: %s", name), "validating input", logger)
+			return
+		}
+		dec := json.NewDecoder(io.LimitReader(r.Body, ddm.MaxDeclarationSize+1))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(payload); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding payload", logger)
+			return
+		}
+		d, err := builder.Build(r.URL.Query().Get("identifier"), payload)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "building declaration", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "built declaration", logkeys.DeclarationID, d.Identifier)
+		w.Header().Set("Content-type", jsonContentType)
+		w.Write(d.Raw)
+	}
+}
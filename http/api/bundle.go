@@ -0,0 +1,78 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/declsync"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetSetBundleHandler returns a handler that exports a set as a bundle.
+// The bundle is a gzipped tar archive of the declarations and the set file.
+// Read-only principals may not export bundles as the declarations
+// would not be redacted.
+func GetSetBundleHandler(store declsync.BundleStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		if httpddm.IsReadOnly(r.Context()) {
+			jsonErrorAndLog(w, http.StatusForbidden, errors.New("read-only principal"), "exporting bundle", logger)
+			return
+		}
+		setName := getResourceID(r)
+		if err := storage.ValidateIdentifier("set name", setName); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("set", setName)
+		// buffer the bundle so that we can report errors
+		buf := new(bytes.Buffer)
+		if err := declsync.WriteBundle(r.Context(), buf, store, setName); err != nil {
+			status := 0
+			if errors.Is(err, declsync.ErrEmptySet) {
+				status = http.StatusNotFound
+			}
+			jsonErrorAndLog(w, status, err, "writing bundle", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "exported set bundle")
+		w.Header().Set("Content-Type", declsync.BundleContentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="set.`+setName+`.tar.gz"`)
+		w.Write(buf.Bytes())
+	}
+}
+
+// PutSetBundleHandler returns a handler that imports a set bundle.
+// The declarations in the bundle are stored and the bundle's set files
+// replace the declarations of their sets.
+// No changes are made if the "dryrun" query parameter is set.
+func PutSetBundleHandler(store declsync.Storage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		dryRun := boolish(r.URL.Query().Get("dryrun"))
+		opts := []declsync.Option{declsync.WithLogger(logger)}
+		if shouldNotify(r.URL) {
+			opts = append(opts, declsync.WithNotifier(notifier))
+		}
+		report, err := declsync.ImportBundle(r.Context(), store, r.Body, dryRun, opts...)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "importing bundle", logger)
+			return
+		}
+		logger.Debug(
+			logkeys.Message, "imported set bundle",
+			"dry_run", dryRun,
+			"declarations", len(report.Declarations),
+			"sets", len(report.Sets),
+			"notified", report.Notified,
+		)
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
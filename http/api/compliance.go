@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxComplianceRequirementSize is the maximum size of a compliance requirement request body.
+const maxComplianceRequirementSize = 65536
+
+// EnrollmentComplianceStorage is the storage needed to list the compliance of enrollments.
+type EnrollmentComplianceStorage interface {
+	storage.ComplianceStorage
+	storage.EnrollmentIDRetriever
+}
+
+// GetComplianceRequirementsHandler returns a handler that retrieves the compliance requirements of all sets.
+func GetComplianceRequirementsHandler(store storage.ComplianceStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		requirements, err := store.RetrieveComplianceRequirements(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving compliance requirements", logger)
+			return
+		}
+		if requirements == nil {
+			// encode as an empty JSON array
+			requirements = []storage.ComplianceRequirement{}
+		}
+		logger.Debug(logkeys.Message, "retrieved compliance requirements", logkeys.GenericCount, len(requirements))
+		if err = jsonResponse(w, 0, requirements); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PutComplianceRequirementHandler returns a handler that stores the
+// compliance requirement of a set from the JSON request body.
+// The compliance of enrollments is re-evaluated when they next report
+// status. The set name is the resource ID.
+func PutComplianceRequirementHandler(store storage.ComplianceStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		requirement := new(storage.ComplianceRequirement)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxComplianceRequirementSize)).Decode(requirement); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding compliance requirement", logger)
+			return
+		}
+		// the set is always the resource
+		requirement.Set = getResourceID(r)
+		logger = logger.With("set", requirement.Set)
+		if err := requirement.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		changed, err := store.StoreComplianceRequirement(r.Context(), requirement)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "storing compliance requirement", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "stored compliance requirement", logkeys.Changed, changed)
+		status := http.StatusNotModified
+		if changed {
+			status = http.StatusNoContent
+		}
+		// not actually an error, using as a helper
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+// DeleteComplianceRequirementHandler returns a handler that deletes
+// the compliance requirement of a set. The set name is the resource ID.
+func DeleteComplianceRequirementHandler(store storage.ComplianceStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			changed, err := store.DeleteComplianceRequirement(ctx, resource)
+			return changed, "delete compliance requirement", err
+		},
+	)
+}
+
+// GetEnrollmentComplianceHandler returns a handler that lists the
+// compliance state of enrollments. The "set" query parameters limit
+// the list to the enrollments of those sets and the "id" query
+// parameters to those enrollment IDs. The "compliant" query parameter,
+// if present, limits the list to compliant (true) or non-compliant
+// (false) enrollments.
+func GetEnrollmentComplianceHandler(store EnrollmentComplianceStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		q := r.URL.Query()
+		ids := q["id"]
+		for _, id := range ids {
+			if err := storage.ValidateIdentifier("enrollment ID", id); err != nil {
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+		}
+		for _, setName := range q["set"] {
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+		}
+		if sets := q["set"]; len(sets) > 0 {
+			setIDs, err := store.RetrieveEnrollmentIDs(r.Context(), nil, sets, nil)
+			if err != nil {
+				jsonErrorAndLog(w, 0, err, "retrieving enrollment IDs", logger)
+				return
+			}
+			if len(ids) > 0 {
+				inSet := make(map[string]bool)
+				for _, id := range setIDs {
+					inSet[id] = true
+				}
+				var both []string
+				for _, id := range ids {
+					if inSet[id] {
+						both = append(both, id)
+					}
+				}
+				setIDs = both
+			}
+			ids = setIDs
+			if len(ids) < 1 {
+				// no enrollments rather than all
+				if err = jsonResponse(w, 0, []*storage.EnrollmentCompliance{}); err != nil {
+					logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+				}
+				return
+			}
+		}
+		states, err := store.RetrieveEnrollmentCompliance(r.Context(), ids)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving enrollment compliance", logger)
+			return
+		}
+		ret := []*storage.EnrollmentCompliance{}
+		_, filter := q["compliant"]
+		compliant := boolish(q.Get("compliant"))
+		for _, c := range states {
+			if !filter || c.Compliant == compliant {
+				ret = append(ret, c)
+			}
+		}
+		logger.Debug(logkeys.Message, "retrieved enrollment compliance", logkeys.GenericCount, len(ret))
+		if err = jsonResponse(w, 0, ret); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxConditionSize is the maximum size of a declaration condition request body.
+const maxConditionSize = 4096
+
+// GetSetDeclarationConditionsHandler retrieves the declaration conditions of a set.
+// The returned object is keyed by declaration identifier.
+func GetSetDeclarationConditionsHandler(store storage.SetDeclarationConditionStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveSetDeclarationConditions(ctx, resource)
+		},
+	)
+}
+
+// setDeclarationConditionHandler stores the condition returned by
+// condFn for the declaration in the "declaration" query parameter of a set.
+func setDeclarationConditionHandler(store storage.SetDeclarationConditionStorage, notifier Notifier, logger log.Logger, condFn func(*http.Request) (*storage.DeclarationCondition, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setName := getResourceID(r)
+		if err := storage.ValidateIdentifier("set name", setName); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		declarationID := r.URL.Query().Get("declaration")
+		if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("resource", setName, logkeys.DeclarationID, declarationID)
+		cond, err := condFn(r)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		changed, err := store.StoreSetDeclarationCondition(r.Context(), setName, declarationID, cond)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "storing set declaration condition", logger)
+			return
+		}
+		// only notify if we have a change
+		notify := changed && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "stored set declaration condition",
+			logkeys.Changed, changed,
+			logkeys.Notify, notify,
+		)
+		if notify {
+			if err = notifier.Changed(r.Context(), nil, []string{setName}, nil); err != nil {
+				jsonErrorAndLog(w, 0, err, "notifying", logger)
+				return
+			}
+		}
+		status := http.StatusNotModified
+		if changed {
+			status = http.StatusNoContent
+		}
+		// not actually an error, using as a helper
+		http.Error(w, http.StatusText(status), status)
+	}
+}
+
+// PutSetDeclarationConditionHandler sets the condition of a declaration
+// in a set. The condition is the JSON request body and the declaration
+// is in the "declaration" query parameter. Enrollments of the set are
+// only served the declaration if their status values match.
+// The entire request URL path is assumed to contain the set name.
+// This implies the handler should have the path prefix stripped before use.
+func PutSetDeclarationConditionHandler(store storage.SetDeclarationConditionStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return setDeclarationConditionHandler(store, notifier, logger, func(r *http.Request) (*storage.DeclarationCondition, error) {
+		cond := new(storage.DeclarationCondition)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxConditionSize)).Decode(cond); err != nil {
+			return nil, err
+		}
+		return cond, cond.Validate()
+	})
+}
+
+// DeleteSetDeclarationConditionHandler removes the condition of the
+// declaration in the "declaration" query parameter from a set.
+// The entire request URL path is assumed to contain the set name.
+// This implies the handler should have the path prefix stripped before use.
+func DeleteSetDeclarationConditionHandler(store storage.SetDeclarationConditionStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return setDeclarationConditionHandler(store, notifier, logger, func(*http.Request) (*storage.DeclarationCondition, error) {
+		return nil, nil
+	})
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/builder"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// MaxCredentialSize is the maximum size in bytes of an uploaded credential.
+const MaxCredentialSize = 1 << 20
+
+// ErrCredentialHostingDisabled is returned when uploading a credential without a credential base URL.
+var ErrCredentialHostingDisabled = errors.New("credential hosting not configured")
+
+// CredentialStorage is the storage needed to manage and rotate credentials.
+type CredentialStorage interface {
+	storage.CredentialStorage
+	storage.DeclarationStorer
+	storage.DeclarationAPIRetriever
+	storage.DeclarationsRetriever
+}
+
+// credentialResponse is the JSON response of a stored credential.
+type credentialResponse struct {
+	*storage.Credential
+
+	// URL is the asset Reference DataURL of the credential.
+	URL string `json:"url"`
+
+	// Declarations are the identifiers of declarations whose asset
+	// Reference was rotated to URL.
+	Declarations []string `json:"declarations,omitempty"`
+}
+
+// credentialURL returns the URL of the credential data with sha256 under credentialBaseURL.
+func credentialURL(credentialBaseURL, sha256 string) string {
+	return strings.TrimSuffix(credentialBaseURL, "/") + "/" + sha256
+}
+
+// GetCredentialsHandler returns a handler that retrieves all credentials.
+// The SHA-256 hashes are omitted for read-only principals.
+func GetCredentialsHandler(store storage.CredentialStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		credentials, err := store.RetrieveCredentials(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving credentials", logger)
+			return
+		}
+		if credentials == nil {
+			credentials = []*storage.Credential{}
+		}
+		if httpddm.IsReadOnly(r.Context()) {
+			credentials = redactCredentials(credentials)
+		}
+		if err = jsonResponse(w, 0, credentials); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// redactCredentials returns copies of credentials without their SHA-256
+// hashes. The hash addresses the credential data so it is not shown
+// to read-only principals.
+func redactCredentials(credentials []*storage.Credential) []*storage.Credential {
+	redacted := make([]*storage.Credential, 0, len(credentials))
+	for _, c := range credentials {
+		rc := *c
+		rc.SHA256 = ""
+		redacted = append(redacted, &rc)
+	}
+	return redacted
+}
+
+// GetCredentialHandler returns a handler that retrieves a credential.
+// The SHA-256 hash is omitted for read-only principals.
+// The credential name is the resource ID.
+func GetCredentialHandler(store storage.CredentialStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			credential, err := store.RetrieveCredential(ctx, resource)
+			if err != nil || !httpddm.IsReadOnly(ctx) {
+				return credential, err
+			}
+			return redactCredentials([]*storage.Credential{credential})[0], nil
+		},
+	)
+}
+
+// PutCredentialHandler returns a handler that stores the certificate or
+// identity in the request body as a credential. The Content-Type of the
+// request must be a credential asset content type.
+//
+// The credential is hosted under credentialBaseURL at a URL derived
+// from its content. When an existing credential is rotated (i.e. its
+// content changes) the asset Reference of every declaration with the
+// URL of the previous content is rewritten to the new URL. Those
+// declarations get new ServerTokens and are notified.
+// The credential name is the resource ID.
+func PutCredentialHandler(store CredentialStorage, credentialBaseURL string, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		name := getResourceID(r)
+		if err := storage.ValidateIdentifier("credential name", name); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("credential", name)
+		if credentialBaseURL == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrCredentialHostingDisabled, "validating input", logger)
+			return
+		}
+		contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "parsing content type", logger)
+			return
+		}
+		switch contentType {
+		case builder.CertificateContentType, builder.PEMCertificateContentType, builder.PKCS12ContentType:
+		default:
+			jsonErrorAndLog(w, http.StatusUnsupportedMediaType, fmt.Errorf("invalid credential content type: %q", contentType), "validating input", logger)
+			return
+		}
+		data, err := io.ReadAll(io.LimitReader(r.Body, MaxCredentialSize+1))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "reading body", logger)
+			return
+		}
+		if len(data) > MaxCredentialSize {
+			jsonErrorAndLog(w, http.StatusRequestEntityTooLarge, fmt.Errorf("credential exceeds %d bytes", MaxCredentialSize), "reading body", logger)
+			return
+		}
+		sum := sha256.Sum256(data)
+		credential := &storage.Credential{
+			Name:        name,
+			ContentType: contentType,
+			SHA256:      hex.EncodeToString(sum[:]),
+			Size:        len(data),
+			Timestamp:   time.Now().UTC().Truncate(time.Second),
+		}
+		if err = credential.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+
+		prev, err := store.RetrieveCredential(r.Context(), name)
+		if err != nil && !errors.Is(err, storage.ErrCredentialNotFound) {
+			jsonErrorAndLog(w, 0, err, "retrieving credential", logger)
+			return
+		}
+		if err = store.StoreCredential(r.Context(), credential, data); err != nil {
+			jsonErrorAndLog(w, 0, err, "storing credential", logger)
+			return
+		}
+		resp := &credentialResponse{
+			Credential: credential,
+			URL:        credentialURL(credentialBaseURL, credential.SHA256),
+		}
+		if prev != nil && prev.SHA256 != credential.SHA256 {
+			ref := builder.AssetReference{
+				DataURL:     resp.URL,
+				ContentType: credential.ContentType,
+				Size:        credential.Size,
+				HashSHA256:  credential.SHA256,
+			}
+			resp.Declarations, err = rotateAssetReferences(r.Context(), store, credentialURL(credentialBaseURL, prev.SHA256), ref)
+			if err != nil {
+				jsonErrorAndLog(w, 0, err, "rotating declarations", logger)
+				return
+			}
+		}
+		logger.Debug(logkeys.Message, "stored credential", logkeys.GenericCount, len(resp.Declarations))
+		if len(resp.Declarations) > 0 && shouldNotify(r.URL) {
+			if err = notifier.Changed(r.Context(), resp.Declarations, nil, nil); err != nil {
+				logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+			}
+		}
+		if err = jsonResponse(w, 0, resp); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// rotateAssetReferences rewrites the asset Reference of all declarations
+// referencing oldURL to ref. The identifiers of changed declarations are returned.
+func rotateAssetReferences(ctx context.Context, store CredentialStorage, oldURL string, ref builder.AssetReference) ([]string, error) {
+	declarationIDs, err := store.RetrieveDeclarations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declarations: %w", err)
+	}
+	var changedIDs []string
+	for _, declarationID := range declarationIDs {
+		d, err := store.RetrieveDeclaration(ctx, declarationID)
+		if err != nil {
+			return changedIDs, fmt.Errorf("retrieving declaration %s: %w", declarationID, err)
+		}
+		if !strings.HasPrefix(d.Type, "com.apple.asset.") {
+			continue
+		}
+		if d, err = builder.RewriteAssetReference(d, oldURL, ref); err != nil {
+			return changedIDs, fmt.Errorf("rewriting declaration %s: %w", declarationID, err)
+		} else if d == nil {
+			continue
+		}
+		changed, err := store.StoreDeclaration(ctx, d)
+		if err != nil {
+			return changedIDs, fmt.Errorf("storing declaration %s: %w", declarationID, err)
+		}
+		if changed {
+			changedIDs = append(changedIDs, declarationID)
+		}
+	}
+	sort.Strings(changedIDs)
+	return changedIDs, nil
+}
+
+// DeleteCredentialHandler returns a handler that deletes a credential.
+// Declarations referencing the credential are not changed.
+// The credential name is the resource ID.
+func DeleteCredentialHandler(store storage.CredentialStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			changed, err := store.DeleteCredential(ctx, resource)
+			return changed, "delete credential", err
+		},
+	)
+}
+
+// CredentialDataStorage is the storage needed to serve credential data to entitled enrollments.
+type CredentialDataStorage interface {
+	storage.CredentialStorage
+	storage.EnrollmentDeclarationsRetriever
+	storage.DeclarationAPIRetriever
+}
+
+// ErrCredentialNotEntitled is returned when an enrollment requests the
+// data of a credential that none of its declarations reference.
+var ErrCredentialNotEntitled = errors.New("enrollment not entitled to credential")
+
+// entitledToCredential reports whether any asset declaration that
+// enrollmentID is entitled to references the credential with sha256.
+func entitledToCredential(ctx context.Context, store CredentialDataStorage, enrollmentID, sha256 string) (bool, error) {
+	declarations, err := store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	if err != nil {
+		return false, fmt.Errorf("retrieving enrollment declarations: %w", err)
+	}
+	for _, ed := range declarations {
+		if !strings.HasPrefix(ed.Type, "com.apple.asset.") {
+			continue
+		}
+		d, err := store.RetrieveDeclaration(ctx, ed.Identifier)
+		if err != nil {
+			return false, fmt.Errorf("retrieving declaration %s: %w", ed.Identifier, err)
+		}
+		var payload struct {
+			Reference builder.AssetReference `json:"Reference"`
+		}
+		if err = json.Unmarshal(d.PayloadJSON, &payload); err != nil {
+			// not an asset reference object
+			continue
+		}
+		if payload.Reference.HashSHA256 == sha256 || strings.HasSuffix(payload.Reference.DataURL, "/"+sha256) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetCredentialDataHandler returns a handler that serves the data of a
+// credential by its SHA-256 hash (the resource ID).
+// The request must have the enrollment ID header of an enrollment
+// entitled to an asset declaration that references the credential.
+func GetCredentialDataHandler(store CredentialDataStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		sha256 := getResourceID(r)
+		if sha256 == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+			return
+		}
+		enrollmentID := r.Header.Get(ddmhttp.EnrollmentIDHeader)
+		if enrollmentID == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ddmhttp.ErrEmptyEnrollmentID, "validating input", logger)
+			return
+		}
+		logger = logger.With("sha256", sha256, logkeys.EnrollmentID, enrollmentID)
+		entitled, err := entitledToCredential(r.Context(), store, enrollmentID, sha256)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "checking entitlement", logger)
+			return
+		} else if !entitled {
+			// do not reveal whether the credential exists
+			jsonErrorAndLog(w, http.StatusForbidden, ErrCredentialNotEntitled, "checking entitlement", logger)
+			return
+		}
+		data, err := store.RetrieveCredentialData(r.Context(), sha256)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving credential", logger)
+			return
+		}
+		w.Header().Set("Content-type", "application/octet-stream")
+		w.Write(data)
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/alexedwards/flow"
+	"github.com/jessepeterson/kmfddm/builder"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// CredentialServices generate SCEP and ACME credential asset
+// declarations and their per-enrollment credential data.
+type CredentialServices interface {
+	// Names returns the names of the services.
+	Names() []string
+
+	// Payload returns the credential asset declaration payload of the service name.
+	Payload(name string) (builder.Payload, error)
+
+	// Data returns the credential data JSON of the service name for
+	// enrollmentID authorized by token.
+	Data(ctx context.Context, name, enrollmentID, token string) ([]byte, error)
+}
+
+// GetCredentialServicesHandler returns a handler that lists the names of the credential services.
+func GetCredentialServicesHandler(services CredentialServices, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		if err := jsonResponse(w, 0, services.Names()); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PostCredentialServiceDeclarationHandler returns a handler that
+// generates the credential asset declaration of a credential service
+// and stores it. The declaration identifier is the required
+// "identifier" query parameter. The service name is the resource ID.
+func PostCredentialServiceDeclarationHandler(store storage.DeclarationStorer, services CredentialServices, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		name := getResourceID(r)
+		logger = logger.With("service", name)
+		payload, err := services.Payload(name)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "generating payload", logger)
+			return
+		}
+		identifier := r.URL.Query().Get("identifier")
+		if identifier == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("missing identifier"), "validating input", logger)
+			return
+		}
+		d, err := builder.Build(identifier, payload)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "building declaration", logger)
+			return
+		}
+		logger = logger.With(
+			logkeys.DeclarationID, d.Identifier,
+			logkeys.DeclarationType, d.Type,
+		)
+		changed, err := store.StoreDeclaration(r.Context(), d)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "storing declaration", logger)
+			return
+		}
+		// only notify if we have a change
+		notify := changed && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "stored credential service declaration",
+			logkeys.Changed, changed,
+			logkeys.Notify, notify,
+		)
+		w.Header().Set("Content-type", jsonContentType)
+		w.Write(d.Raw)
+		if notify {
+			err = notifier.Changed(r.Context(), []string{d.Identifier}, nil, nil)
+			if err != nil {
+				logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+				return
+			}
+		}
+	}
+}
+
+// GetCredentialServiceDataHandler returns a handler that serves the
+// credential data of a credential service for an enrollment. The
+// "name" and "token" route parameters are the service name and the
+// enrollment token. The enrollment ID is the resource ID.
+// It is intended to be reachable by enrollments without authentication.
+func GetCredentialServiceDataHandler(services CredentialServices, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		name := flow.Param(r.Context(), "name")
+		enrollmentID := getResourceID(r)
+		if enrollmentID == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+			return
+		}
+		logger = logger.With("service", name, logkeys.EnrollmentID, enrollmentID)
+		data, err := services.Data(r.Context(), name, enrollmentID, flow.Param(r.Context(), "token"))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving credential service data", logger)
+			return
+		}
+		w.Header().Set("Content-type", builder.JSONContentType)
+		w.Write(data)
+	}
+}
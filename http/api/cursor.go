@@ -0,0 +1,113 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+const (
+	// NextCursorHeader contains the opaque cursor of the next page of a paginated list.
+	NextCursorHeader = "X-Next-Cursor"
+
+	// DefaultPageLimit is the number of items in a page of a paginated
+	// list if a cursor but no limit is requested.
+	DefaultPageLimit = 1000
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor encodes key (the last key of a page) into an opaque cursor.
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+// decodeCursor decodes the key (the last key of the previous page) from cursor.
+func decodeCursor(cursor string) (string, error) {
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) < 1 {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
+
+// pageQuery parses the "cursor" and "limit" query parameters. The list
+// is paginated only if either is present. Then the page starts after
+// the key after and has at most limit items.
+func pageQuery(q url.Values) (after string, limit int, paginated bool, err error) {
+	cursor, limitStr := q.Get("cursor"), q.Get("limit")
+	if cursor == "" && limitStr == "" {
+		return "", 0, false, nil
+	}
+	if cursor != "" {
+		if after, err = decodeCursor(cursor); err != nil {
+			return
+		}
+	}
+	limit = DefaultPageLimit
+	if limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return "", 0, false, fmt.Errorf("invalid limit: %q", limitStr)
+		}
+	}
+	return after, limit, true, nil
+}
+
+// setNextPage sets the headers that reference the page of r after key
+// (the last key of the current page).
+func setNextPage(w http.ResponseWriter, r *http.Request, key string, limit int) {
+	cursor := encodeCursor(key)
+	w.Header().Set(NextCursorHeader, cursor)
+	u := *r.URL
+	q := u.Query()
+	q.Set("cursor", cursor)
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+}
+
+// pageFunc retrieves up to limit keys that sort after after.
+type pageFunc func(r *http.Request, after string, limit int) ([]string, error)
+
+// listHandler returns a handler that responds with the JSON list of
+// keys. The list is retrieved with all unless it is paginated (see
+// pageQuery) in which case a page is retrieved with page and the next
+// page is referenced in the response headers if there are more keys.
+func listHandler(logger log.Logger, msg string, all func(*http.Request) ([]string, error), page pageFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		after, limit, paginated, err := pageQuery(r.URL.Query())
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		var keys []string
+		if paginated {
+			// retrieve one more than the limit to know if there is a next page
+			keys, err = page(r, after, limit+1)
+			if err == nil && len(keys) > limit {
+				keys = keys[:limit]
+				setNextPage(w, r, keys[limit-1], limit)
+			} else if keys == nil {
+				// encode as an empty JSON array
+				keys = []string{}
+			}
+		} else {
+			keys, err = all(r)
+		}
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, msg, logger)
+			return
+		}
+		if err = jsonResponse(w, 0, keys); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -1,15 +1,18 @@
 package api
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 
 	"github.com/jessepeterson/kmfddm/ddm"
+	httpddm "github.com/jessepeterson/kmfddm/http"
 	"github.com/jessepeterson/kmfddm/log"
 	"github.com/jessepeterson/kmfddm/log/ctxlog"
 	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/redact"
 	"github.com/jessepeterson/kmfddm/storage"
 )
 
@@ -17,7 +20,7 @@ import (
 func PutDeclarationHandler(store storage.DeclarationStorer, notifier Notifier, logger log.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := ctxlog.Logger(r.Context(), logger)
-		bodyBytes, err := io.ReadAll(r.Body)
+		bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, ddm.MaxDeclarationSize+1))
 		if err != nil {
 			jsonErrorAndLog(w, 0, err, "reading body", logger)
 			return
@@ -31,6 +34,10 @@ func PutDeclarationHandler(store storage.DeclarationStorer, notifier Notifier, l
 			jsonErrorAndLog(w, http.StatusBadRequest, ddm.ErrInvalidDeclaration, "parsing declaration", logger)
 			return
 		}
+		if err = storage.ValidateIdentifier("declaration identifier", d.Identifier); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating declaration", logger)
+			return
+		}
 		logger = logger.With(
 			logkeys.DeclarationID, d.Identifier,
 			logkeys.DeclarationType, d.Type,
@@ -62,30 +69,95 @@ func PutDeclarationHandler(store storage.DeclarationStorer, notifier Notifier, l
 	}
 }
 
+// stripServerToken removes the ServerToken from the declaration JSON in b.
+func stripServerToken(b []byte) ([]byte, error) {
+	var declaration map[string]json.RawMessage
+	if err := json.Unmarshal(b, &declaration); err != nil {
+		return nil, err
+	}
+	delete(declaration, "ServerToken")
+	return json.Marshal(declaration)
+}
+
+// prettyJSON indents the JSON in b and terminates it with a newline.
+func prettyJSON(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
 // GetDeclarationHandler retrieves a declaration by its identifier.
 // The entire request URL path is assumed to contain the declaration identifier.
 // This implies the handler should have the path prefix stripped before use.
-func GetDeclarationHandler(store storage.DeclarationAPIRetriever, logger log.Logger) http.HandlerFunc {
+// Sensitive payload values are masked by redactor (if not nil) for
+// read-only principals. The "fields" query parameter selects the
+// top-level fields of the declaration (e.g. "Identifier,ServerToken").
+// The "notoken" query parameter removes the ServerToken and the
+// "pretty" query parameter indents the JSON. The "raw" query parameter
+// instead returns the exact bytes from storage; it can not be combined
+// with the other parameters and is not available to read-only
+// principals as the declaration would not be redacted.
+func GetDeclarationHandler(store storage.DeclarationAPIRetriever, redactor *redact.Redactor, logger log.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := ctxlog.Logger(r.Context(), logger)
 		declarationID := getResourceID(r)
-		if declarationID == "" {
-			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+		if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		q := r.URL.Query()
+		raw, pretty, noToken := boolish(q.Get("raw")), boolish(q.Get("pretty")), boolish(q.Get("notoken"))
+		fields := parseFields(q)
+		if raw && (pretty || noToken || fields != nil) {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("raw can not be combined with other modes"), "validating input", logger)
+			return
+		}
+		readOnly := httpddm.IsReadOnly(r.Context())
+		if raw && readOnly {
+			jsonErrorAndLog(w, http.StatusForbidden, errors.New("read-only principal"), "validating input", logger)
 			return
 		}
 		logger = logger.With(logkeys.DeclarationID, declarationID)
 		d, err := store.RetrieveDeclaration(r.Context(), declarationID)
 		if err != nil {
-			statusCode := 0
-			if errors.Is(err, storage.ErrDeclarationNotFound) {
-				statusCode = 404
-			}
-			jsonErrorAndLog(w, statusCode, err, "retrieving declaration", logger)
+			jsonErrorAndLog(w, 0, err, "retrieving declaration", logger)
 			return
 		}
-		logger.Debug(logkeys.Message, "retrieved declaration")
+		body := d.Raw
+		if readOnly {
+			if body, err = redactor.Redact(d); err != nil {
+				jsonErrorAndLog(w, 0, err, "redacting declaration", logger)
+				return
+			}
+		}
+		if fields != nil {
+			v, err := selectFields(json.RawMessage(body), fields, true)
+			if err == nil {
+				body, err = json.Marshal(v)
+			}
+			if err != nil {
+				jsonErrorAndLog(w, 0, err, "selecting fields", logger)
+				return
+			}
+		}
+		if noToken {
+			if body, err = stripServerToken(body); err != nil {
+				jsonErrorAndLog(w, 0, err, "removing server token", logger)
+				return
+			}
+		}
+		if pretty {
+			if body, err = prettyJSON(body); err != nil {
+				jsonErrorAndLog(w, 0, err, "indenting declaration", logger)
+				return
+			}
+		}
+		logger.Debug(logkeys.Message, "retrieved declaration", "raw", raw)
 		w.Header().Set("Content-Type", jsonContentType)
-		_, err = w.Write(d.Raw)
+		_, err = w.Write(body)
 		if err != nil {
 			logger.Info(logkeys.Message, "writing response body", logkeys.Error, err)
 			return
@@ -102,8 +174,8 @@ func DeleteDeclarationHandler(store storage.DeclarationDeleter, logger log.Logge
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := ctxlog.Logger(r.Context(), logger)
 		declarationID := getResourceID(r)
-		if declarationID == "" {
-			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+		if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
 			return
 		}
 		logger = logger.With(logkeys.DeclarationID, declarationID)
@@ -123,21 +195,25 @@ func DeleteDeclarationHandler(store storage.DeclarationDeleter, logger log.Logge
 	}
 }
 
+// DeclarationsListStorage lists declarations.
+type DeclarationsListStorage interface {
+	storage.DeclarationsRetriever
+	storage.DeclarationsPager
+}
+
 // GetDeclarationsHandler returns a handler that lists declarations.
-func GetDeclarationsHandler(store storage.DeclarationsRetriever, logger log.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		logger := ctxlog.Logger(r.Context(), logger)
-		ids, err := store.RetrieveDeclarations(r.Context())
-		if err != nil {
-			jsonErrorAndLog(w, 0, err, "retrieving declarations", logger)
-		}
-		w.Header().Set("Content-type", jsonContentType)
-		err = json.NewEncoder(w).Encode(&ids)
-		if err != nil {
-			logger.Info("msg", "encoding response body", "err", err)
-			return
-		}
-	}
+// The list is paginated with the "cursor" and "limit" query parameters.
+func GetDeclarationsHandler(store DeclarationsListStorage, logger log.Logger) http.HandlerFunc {
+	return listHandler(
+		logger,
+		"retrieving declarations",
+		func(r *http.Request) ([]string, error) {
+			return store.RetrieveDeclarations(r.Context())
+		},
+		func(r *http.Request, after string, limit int) ([]string, error) {
+			return store.RetrieveDeclarationsPage(r.Context(), after, limit)
+		},
+	)
 }
 
 // TouchDeclarationHandler modifies a declaration ServerToken specified by ID.
@@ -146,19 +222,14 @@ func TouchDeclarationHandler(store storage.Toucher, notifier Notifier, logger lo
 		logger := ctxlog.Logger(r.Context(), logger)
 		var err error
 		declarationID := getResourceID(r)
-		if declarationID == "" {
-			err = errors.New("empty declaration identifier")
+		if err = storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
 			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
 			return
 		}
 		logger = logger.With("declaration", declarationID)
 		err = store.TouchDeclaration(r.Context(), declarationID)
 		if err != nil {
-			statusCode := 0
-			if errors.Is(err, storage.ErrDeclarationNotFound) {
-				statusCode = 404
-			}
-			jsonErrorAndLog(w, statusCode, err, "touching declaration", logger)
+			jsonErrorAndLog(w, 0, err, "touching declaration", logger)
 			return
 		}
 		http.Error(w, http.StatusText(http.StatusNoContent), http.StatusNoContent)
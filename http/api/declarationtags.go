@@ -0,0 +1,180 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetDeclarationTagsHandler retrieves the tags of a declaration.
+// The declaration identifier is the resource ID.
+func GetDeclarationTagsHandler(store storage.DeclarationTagRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveDeclarationTags(ctx, resource)
+		},
+	)
+}
+
+// PutDeclarationTagHandler tags a declaration with the tag in the
+// "tag" query parameter. The declaration identifier is the resource ID.
+// Tags have no effect on the DDM an enrollment is served so nothing
+// is notified.
+func PutDeclarationTagHandler(store storage.DeclarationTagStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, _ bool) (bool, string, error) {
+			tag := u.Query().Get("tag")
+			if err := storage.ValidateIdentifier("tag", tag); err != nil {
+				return false, "", err
+			}
+			changed, err := store.StoreDeclarationTag(ctx, resource, tag)
+			return changed, "store declaration tag", err
+		},
+	)
+}
+
+// DeleteDeclarationTagHandler removes the tag in the "tag" query
+// parameter from a declaration. The declaration identifier is the
+// resource ID.
+func DeleteDeclarationTagHandler(store storage.DeclarationTagStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, _ bool) (bool, string, error) {
+			tag := u.Query().Get("tag")
+			if err := storage.ValidateIdentifier("tag", tag); err != nil {
+				return false, "", err
+			}
+			changed, err := store.RemoveDeclarationTag(ctx, resource, tag)
+			return changed, "remove declaration tag", err
+		},
+	)
+}
+
+// GetTagsHandler retrieves the sorted list of all tags.
+func GetTagsHandler(store storage.DeclarationTagRetriever, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		tags, err := store.RetrieveTags(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving tags", logger)
+			return
+		}
+		if tags == nil {
+			// encode as an empty JSON array
+			tags = []string{}
+		}
+		sort.Strings(tags)
+		if err = jsonResponse(w, 0, tags); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// GetTagDeclarationsHandler retrieves the declarations tagged with a
+// tag. The tag is the resource ID.
+func GetTagDeclarationsHandler(store storage.DeclarationTagRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveTagDeclarations(ctx, resource)
+		},
+	)
+}
+
+// TagSetDeclarationStorage assigns the declarations of a tag to sets.
+type TagSetDeclarationStorage interface {
+	storage.DeclarationTagRetriever
+	storage.SetDeclarationStorer
+}
+
+// TagSetDeclarationRemover unassigns the declarations of a tag from sets.
+type TagSetDeclarationRemover interface {
+	storage.DeclarationTagRetriever
+	storage.SetDeclarationRemover
+}
+
+// PutTagSetDeclarationsHandler associates all declarations tagged with
+// a tag to the set in the "set" query parameter. The tag is the
+// resource ID. Enrollments of the set are notified once if the set
+// changed.
+func PutTagSetDeclarationsHandler(store TagSetDeclarationStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			setName := u.Query().Get("set")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
+			}
+			declarationIDs, err := store.RetrieveTagDeclarations(ctx, resource)
+			if err != nil {
+				return false, "", fmt.Errorf("retrieving tag declarations: %w", err)
+			}
+			var changed bool
+			for _, declarationID := range declarationIDs {
+				declChanged, err := store.StoreSetDeclaration(ctx, setName, declarationID)
+				if err != nil {
+					// notify for the declarations already associated
+					err = fmt.Errorf("store set declaration %s: %w", declarationID, err)
+					return changed, "store tag set declarations", notifySetChanged(ctx, notifier, setName, changed && notify, err)
+				}
+				changed = changed || declChanged
+			}
+			return changed, "store tag set declarations", notifySetChanged(ctx, notifier, setName, changed && notify, nil)
+		},
+	)
+}
+
+// DeleteTagSetDeclarationsHandler dissociates all declarations tagged
+// with a tag from the set in the "set" query parameter. The tag is the
+// resource ID. Removals are reported to events. Enrollments of the set
+// are notified once if the set changed.
+func DeleteTagSetDeclarationsHandler(store TagSetDeclarationRemover, notifier Notifier, events AdminEvents, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			setName := u.Query().Get("set")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
+			}
+			declarationIDs, err := store.RetrieveTagDeclarations(ctx, resource)
+			if err != nil {
+				return false, "", fmt.Errorf("retrieving tag declarations: %w", err)
+			}
+			var changed bool
+			for _, declarationID := range declarationIDs {
+				declChanged, err := store.RemoveSetDeclaration(ctx, setName, declarationID)
+				if err != nil {
+					// notify for the declarations already dissociated
+					err = fmt.Errorf("remove set declaration %s: %w", declarationID, err)
+					return changed, "remove tag set declarations", notifySetChanged(ctx, notifier, setName, changed && notify, err)
+				}
+				if declChanged {
+					events.SetDeclarationRemoved(ctx, setName, declarationID)
+				}
+				changed = changed || declChanged
+			}
+			return changed, "remove tag set declarations", notifySetChanged(ctx, notifier, setName, changed && notify, nil)
+		},
+	)
+}
+
+// notifySetChanged notifies the enrollments of setName if notify is
+// true. The error err of the change is returned if it is not nil.
+func notifySetChanged(ctx context.Context, notifier Notifier, setName string, notify bool, err error) error {
+	if !notify {
+		return err
+	}
+	if nErr := notifier.Changed(ctx, nil, []string{setName}, nil); nErr != nil && err == nil {
+		err = fmt.Errorf("notify set: %w", nErr)
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/declevent"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// DeclarationEventDeliveries retrieves the delivery log of declaration event webhooks.
+type DeclarationEventDeliveries interface {
+	Deliveries(ctx context.Context, webhook string) ([]*declevent.Delivery, error)
+}
+
+// GetDeclarationEventDeliveriesHandler returns a handler that retrieves
+// the logged deliveries of declaration events to webhooks, most recent
+// first. The "webhook" query parameter limits the deliveries to the
+// webhook of that name.
+func GetDeclarationEventDeliveriesHandler(d DeclarationEventDeliveries, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		deliveries, err := d.Deliveries(r.Context(), r.URL.Query().Get("webhook"))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving declaration event deliveries", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "retrieved declaration event deliveries", logkeys.GenericCount, len(deliveries))
+		if err = jsonResponse(w, 0, deliveries); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
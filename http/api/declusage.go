@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetDeclarationUsageHandler returns a handler that reports the sets,
+// entitled enrollment count, and referencing declarations of
+// declarations. A declaration with no sets and no referencing
+// declarations is safe to delete. The usage of only the declaration
+// in the resource ID is reported if it is present. Otherwise the
+// usage of declarations in the "declaration" query parameter (which
+// may be repeated) is reported, or of all declarations if none.
+func GetDeclarationUsageHandler(store storage.DeclarationUsageRetriever, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		declarationIDs := r.URL.Query()["declaration"]
+		declarationID := getResourceID(r)
+		if declarationID != "" {
+			if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+			declarationIDs = []string{declarationID}
+			logger = logger.With(logkeys.DeclarationID, declarationID)
+		}
+		usage, err := store.RetrieveDeclarationUsage(r.Context(), declarationIDs)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving declaration usage", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "retrieved declaration usage", logkeys.DeclarationCount, len(usage))
+		if declarationID != "" && len(usage) == 1 {
+			err = jsonResponse(w, 0, usage[0])
+		} else {
+			if usage == nil {
+				// encode as an empty JSON array
+				usage = []storage.DeclarationUsage{}
+			}
+			err = jsonResponse(w, 0, usage)
+		}
+		if err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -2,15 +2,21 @@ package api
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 
+	"github.com/jessepeterson/kmfddm/enrollimport"
 	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
 	"github.com/jessepeterson/kmfddm/storage"
 )
 
+// maxEnrollmentSetsImportSize is the maximum size of an enrollment sets import request body.
+const maxEnrollmentSetsImportSize = 32 << 20
+
 // GetEnrollmentSetsHandler returns a handle that retrieves the list of sets for an enrollment ID.
 func GetEnrollmentSetsHandler(store storage.EnrollmentSetsRetriever, logger log.Logger) http.HandlerFunc {
 	return simpleJSONResourceHandler(
@@ -21,14 +27,24 @@ func GetEnrollmentSetsHandler(store storage.EnrollmentSetsRetriever, logger log.
 	)
 }
 
+// GetEnrollmentDeclarationsHandler returns a handler that retrieves the resolved list of declarations for an enrollment ID.
+func GetEnrollmentDeclarationsHandler(store storage.EnrollmentDeclarationsRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveEnrollmentDeclarations(ctx, resource)
+		},
+	)
+}
+
 // PutEnrollmentSetHandler returns a handle that associates a set to an enrollment.
 func PutEnrollmentSetHandler(store storage.EnrollmentSetStorer, notifier Notifier, logger log.Logger) http.HandlerFunc {
 	return simpleChangeResourceHandler(
 		logger,
 		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
 			setName := u.Query().Get("set")
-			if setName == "" {
-				return false, "", errors.New("empty set name")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
 			}
 			changed, err := store.StoreEnrollmentSet(ctx, resource, setName)
 			if err == nil && changed && notify {
@@ -48,8 +64,8 @@ func DeleteEnrollmentSetHandler(store storage.EnrollmentSetRemover, notifier Not
 		logger,
 		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
 			setName := u.Query().Get("set")
-			if setName == "" {
-				return false, "", errors.New("empty set name")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
 			}
 			changed, err := store.RemoveEnrollmentSet(ctx, resource, setName)
 			if err == nil && changed && notify {
@@ -62,3 +78,26 @@ func DeleteEnrollmentSetHandler(store storage.EnrollmentSetRemover, notifier Not
 		},
 	)
 }
+
+// PostEnrollmentSetsImportHandler returns a handler that imports
+// enrollment to set assignments from the CSV request body. Each CSV
+// record is an enrollment ID and a set name. A per-row result report is
+// returned. No changes are made if the "dryrun" query parameter is set.
+func PostEnrollmentSetsImportHandler(store storage.EnrollmentSetStorer, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		dryRun := boolish(r.URL.Query().Get("dryrun"))
+		opts := []enrollimport.Option{enrollimport.WithLogger(logger)}
+		if shouldNotify(r.URL) {
+			opts = append(opts, enrollimport.WithNotifier(notifier))
+		}
+		report, err := enrollimport.New(store, opts...).Import(r.Context(), io.LimitReader(r.Body, maxEnrollmentSetsImportSize), dryRun)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "importing enrollment sets", logger)
+			return
+		}
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ExplainStorage is the storage needed to explain declaration targeting.
+type ExplainStorage interface {
+	storage.DeclarationAPIRetriever
+	storage.EnrollmentSetsRetriever
+	storage.DeclarationSetRetriever
+	storage.TokensDeclarationItemsRetriever
+	storage.StatusDeclarationsRetriever
+}
+
+// Explanation details why an enrollment is or is not targeted by a declaration.
+type Explanation struct {
+	EnrollmentID  string `json:"enrollment_id"`
+	DeclarationID string `json:"declaration_id"`
+
+	// DeclarationExists reports whether the declaration is stored.
+	DeclarationExists bool `json:"declaration_exists"`
+
+	// ServerToken is the current server token of the declaration.
+	ServerToken string `json:"server_token,omitempty"`
+
+	EnrollmentSets  []string `json:"enrollment_sets"`
+	DeclarationSets []string `json:"declaration_sets"`
+
+	// MatchingSets are the sets both the enrollment and declaration are in.
+	MatchingSets []string `json:"matching_sets"`
+
+	// Entitled reports whether the enrollment should receive the declaration.
+	Entitled bool `json:"entitled"`
+
+	// Served reports whether the declaration is in the enrollment's declaration items.
+	Served bool `json:"served"`
+
+	// ServedServerToken is the server token in the enrollment's declaration items.
+	ServedServerToken string `json:"served_server_token,omitempty"`
+
+	// Status is the last reported status of the declaration by the enrollment.
+	Status *ddm.DeclarationQueryStatus `json:"status,omitempty"`
+
+	// Reasons are human-readable explanations of the above.
+	Reasons []string `json:"reasons"`
+}
+
+// findManifestDeclaration returns the manifest declaration for declarationID in di.
+func findManifestDeclaration(di *ddm.DeclarationItems, declarationID string) *ddm.ManifestDeclaration {
+	for _, mds := range [][]ddm.ManifestDeclaration{
+		di.Declarations.Activations,
+		di.Declarations.Assets,
+		di.Declarations.Configurations,
+		di.Declarations.Management,
+	} {
+		for i := range mds {
+			if mds[i].Identifier == declarationID {
+				return &mds[i]
+			}
+		}
+	}
+	return nil
+}
+
+// explain assembles the Explanation for enrollmentID and declarationID.
+func explain(ctx context.Context, store ExplainStorage, enrollmentID, declarationID string) (*Explanation, error) {
+	e := &Explanation{
+		EnrollmentID:  enrollmentID,
+		DeclarationID: declarationID,
+		MatchingSets:  []string{},
+	}
+
+	d, err := store.RetrieveDeclaration(ctx, declarationID)
+	if err != nil && !errors.Is(err, storage.ErrDeclarationNotFound) {
+		return nil, fmt.Errorf("retrieving declaration: %w", err)
+	}
+	if d != nil && err == nil {
+		e.DeclarationExists = true
+		e.ServerToken = d.ServerToken
+	} else {
+		e.Reasons = append(e.Reasons, "declaration does not exist")
+	}
+
+	if e.EnrollmentSets, err = store.RetrieveEnrollmentSets(ctx, enrollmentID); err != nil {
+		return nil, fmt.Errorf("retrieving enrollment sets: %w", err)
+	}
+	if len(e.EnrollmentSets) < 1 {
+		e.Reasons = append(e.Reasons, "enrollment is not in any sets")
+	}
+
+	if e.DeclarationSets, err = store.RetrieveDeclarationSets(ctx, declarationID); err != nil {
+		return nil, fmt.Errorf("retrieving declaration sets: %w", err)
+	}
+	if len(e.DeclarationSets) < 1 {
+		e.Reasons = append(e.Reasons, "declaration is not in any sets")
+	}
+
+	for _, setName := range e.EnrollmentSets {
+		for _, dSetName := range e.DeclarationSets {
+			if setName == dSetName {
+				e.MatchingSets = append(e.MatchingSets, setName)
+			}
+		}
+	}
+	e.Entitled = e.DeclarationExists && len(e.MatchingSets) > 0
+	if e.Entitled {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("enrollment is entitled to declaration by way of %d set(s)", len(e.MatchingSets)))
+	} else if len(e.EnrollmentSets) > 0 && len(e.DeclarationSets) > 0 {
+		e.Reasons = append(e.Reasons, "enrollment and declaration share no sets")
+	}
+
+	diJSON, err := store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("could not retrieve declaration items: %v", err))
+	} else {
+		di := new(ddm.DeclarationItems)
+		if err = json.Unmarshal(diJSON, di); err != nil {
+			return nil, fmt.Errorf("decoding declaration items: %w", err)
+		}
+		if md := findManifestDeclaration(di, declarationID); md != nil {
+			e.Served = true
+			e.ServedServerToken = md.ServerToken
+		}
+	}
+	if e.Entitled && !e.Served {
+		e.Reasons = append(e.Reasons, "declaration is not in the enrollment's declaration items")
+	} else if !e.Entitled && e.Served {
+		e.Reasons = append(e.Reasons, "declaration is in the enrollment's declaration items but enrollment is not entitled")
+	} else if e.Served && e.ServedServerToken != e.ServerToken {
+		e.Reasons = append(e.Reasons, "served server token differs from current server token")
+	}
+
+	statuses, err := store.RetrieveDeclarationStatus(ctx, []string{enrollmentID})
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declaration status: %w", err)
+	}
+	for i := range statuses[enrollmentID] {
+		if statuses[enrollmentID][i].Identifier == declarationID {
+			e.Status = &statuses[enrollmentID][i]
+			break
+		}
+	}
+	if e.Status == nil || e.Status.StatusReceived.IsZero() {
+		if e.Served {
+			e.Reasons = append(e.Reasons, "enrollment has not reported status for declaration")
+		}
+	} else if !e.Status.Current {
+		e.Reasons = append(e.Reasons, "enrollment has not reported status for the current server token")
+	} else if !e.Status.Active || e.Status.Valid != "valid" {
+		e.Reasons = append(e.Reasons, fmt.Sprintf("enrollment reports declaration as active=%t valid=%s", e.Status.Active, e.Status.Valid))
+	} else {
+		e.Reasons = append(e.Reasons, "enrollment reports declaration as active and valid")
+	}
+
+	return e, nil
+}
+
+// GetExplainHandler returns a handler that explains why an enrollment
+// is or is not targeted by a declaration. The enrollment ID is the
+// resource ID and the declaration is given in the "declaration" query
+// parameter.
+func GetExplainHandler(store ExplainStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		enrollmentID := getResourceID(r)
+		if err := storage.ValidateIdentifier("enrollment ID", enrollmentID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		declarationID := r.URL.Query().Get("declaration")
+		if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With(
+			logkeys.EnrollmentID, enrollmentID,
+			logkeys.DeclarationID, declarationID,
+		)
+		e, err := explain(r.Context(), store, enrollmentID, declarationID)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "explaining declaration", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "explained declaration", "entitled", e.Entitled, "served", e.Served)
+		if err = jsonResponse(w, 0, e); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// parseFields parses the comma-separated "fields" query parameter.
+// A nil map is returned if no fields were requested.
+func parseFields(q url.Values) map[string]bool {
+	return fieldSet(strings.Split(q.Get("fields"), ","))
+}
+
+// fieldSet returns the set of non-empty names or nil if there are none.
+func fieldSet(names []string) map[string]bool {
+	var fields map[string]bool
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if fields == nil {
+			fields = make(map[string]bool)
+		}
+		fields[name] = true
+	}
+	return fields
+}
+
+// selectFields reduces the JSON encoding of v to the named fields of
+// its records. Records are the JSON objects that are elements of arrays
+// (at any depth) and, if record is true, v itself. The other values are
+// returned unchanged. Only the top-level fields of records are selected.
+func selectFields(v interface{}, fields map[string]bool, record bool) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	// preserve numbers as encoded
+	dec.UseNumber()
+	var j interface{}
+	if err = dec.Decode(&j); err != nil {
+		return nil, err
+	}
+	return selectJSONFields(j, fields, record), nil
+}
+
+func selectJSONFields(v interface{}, fields map[string]bool, record bool) interface{} {
+	switch v := v.(type) {
+	case []interface{}:
+		for i := range v {
+			v[i] = selectJSONFields(v[i], fields, true)
+		}
+	case map[string]interface{}:
+		for k := range v {
+			if !record {
+				v[k] = selectJSONFields(v[k], fields, false)
+			} else if !fields[k] {
+				delete(v, k)
+			}
+		}
+	}
+	return v
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/freeze"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetEnrollmentFreezeHandler returns a handler that retrieves the
+// frozen DDM of an enrollment. No content is returned if the
+// enrollment is not frozen. The enrollment ID is the resource ID.
+func GetEnrollmentFreezeHandler(store storage.EnrollmentFreezeRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			f, err := store.RetrieveEnrollmentFreeze(ctx, resource)
+			if f == nil {
+				// avoid a typed nil
+				return nil, err
+			}
+			return f, err
+		},
+	)
+}
+
+// PutEnrollmentFreezeHandler returns a handler that freezes an
+// enrollment with the DDM that ddmStore currently serves it. While
+// frozen, changes to its sets and declarations do not change what it
+// is served and it is not notified. Freezing a frozen enrollment does
+// not change its freeze. The enrollment ID is the resource ID.
+func PutEnrollmentFreezeHandler(ddmStore freeze.Storage, store storage.EnrollmentFreezeStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			const op = "freeze enrollment"
+			if f, err := store.RetrieveEnrollmentFreeze(ctx, resource); err != nil {
+				return false, op, err
+			} else if f != nil {
+				return false, op, nil
+			}
+			f, err := freeze.Snapshot(ctx, ddmStore, resource)
+			if err != nil {
+				return false, op, err
+			}
+			return true, op, store.StoreEnrollmentFreeze(ctx, f)
+		},
+	)
+}
+
+// DeleteEnrollmentFreezeHandler returns a handler that unfreezes an
+// enrollment. The enrollment is notified so that any changes made
+// while it was frozen are applied at once.
+// The enrollment ID is the resource ID.
+func DeleteEnrollmentFreezeHandler(store storage.EnrollmentFreezeStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, notify bool) (bool, string, error) {
+			changed, err := store.DeleteEnrollmentFreeze(ctx, resource)
+			if err == nil && changed && notify {
+				err = notifier.Changed(ctx, nil, nil, []string{resource})
+				if err != nil {
+					err = fmt.Errorf("notify enrollment: %w", err)
+				}
+			}
+			return changed, "unfreeze enrollment", err
+		},
+	)
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/jobs"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// Jobs retrieves and cancels background jobs.
+type Jobs interface {
+	Jobs(ctx context.Context) ([]*jobs.Job, error)
+	Job(ctx context.Context, id string) (*jobs.Job, error)
+	Cancel(ctx context.Context, id string) (bool, error)
+}
+
+// GetJobsHandler returns a handler that retrieves the progress of all
+// jobs, most recent first.
+func GetJobsHandler(j Jobs, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		list, err := j.Jobs(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving jobs", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "retrieved jobs", logkeys.GenericCount, len(list))
+		if err = jsonResponse(w, 0, list); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// GetJobHandler returns a handler that retrieves the progress of a job.
+// The job ID is the resource ID.
+func GetJobHandler(j Jobs, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return j.Job(ctx, resource)
+		},
+	)
+}
+
+// DeleteJobHandler returns a handler that cancels a running job.
+// Work the job has already done is not undone.
+// The job ID is the resource ID.
+func DeleteJobHandler(j Jobs, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			canceled, err := j.Cancel(ctx, resource)
+			return canceled, "cancel job", err
+		},
+	)
+}
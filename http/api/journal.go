@@ -0,0 +1,51 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetJournalHandler returns a handler that lists the journal of storage mutations.
+// The "after" query parameter selects the entries with greater sequence numbers.
+// The "limit" query parameter limits the number of entries (DefaultPageLimit by default).
+func GetJournalHandler(store storage.JournalRetriever, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		q := r.URL.Query()
+		var after int64
+		var err error
+		if afterStr := q.Get("after"); afterStr != "" {
+			if after, err = strconv.ParseInt(afterStr, 10, 64); err != nil || after < 0 {
+				err = fmt.Errorf("invalid after: %q", afterStr)
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+		}
+		limit := DefaultPageLimit
+		if limitStr := q.Get("limit"); limitStr != "" {
+			if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+				err = fmt.Errorf("invalid limit: %q", limitStr)
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+		}
+		entries, err := store.RetrieveJournal(r.Context(), after, limit)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving journal", logger)
+			return
+		}
+		if entries == nil {
+			// encode as an empty JSON array
+			entries = []storage.JournalEntry{}
+		}
+		if err = jsonResponse(w, 0, entries); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
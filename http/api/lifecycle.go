@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetLifecycleStagesHandler returns a handler that retrieves all lifecycle stages.
+func GetLifecycleStagesHandler(store storage.LifecycleStageStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		stages, err := store.RetrieveLifecycleStages(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving lifecycle stages", logger)
+			return
+		}
+		if stages == nil {
+			// encode as an empty JSON array
+			stages = []storage.LifecycleStage{}
+		}
+		logger.Debug(logkeys.Message, "retrieved lifecycle stages", logkeys.GenericCount, len(stages))
+		if err = jsonResponse(w, 0, stages); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// lifecycleStageFromURL returns the lifecycle stage of name with the
+// sorted and de-duplicated sets in the "set" query parameters of u.
+func lifecycleStageFromURL(name string, u *url.URL) (*storage.LifecycleStage, error) {
+	sets := u.Query()["set"]
+	sort.Strings(sets)
+	stage := &storage.LifecycleStage{Name: name, Sets: []string{}}
+	for i, setName := range sets {
+		if i == 0 || sets[i-1] != setName {
+			stage.Sets = append(stage.Sets, setName)
+		}
+	}
+	return stage, stage.Validate()
+}
+
+// PutLifecycleStageHandler returns a handler that stores a lifecycle
+// stage with the sets in the "set" query parameters. No sets is a
+// valid stage: transitioning to it removes the sets of all other
+// stages. The stage name is the resource ID.
+// Enrollments already in the stage are not changed until they are
+// next transitioned so they are not notified.
+func PutLifecycleStageHandler(store storage.LifecycleStageStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, _ bool) (bool, string, error) {
+			const op = "store lifecycle stage"
+			stage, err := lifecycleStageFromURL(resource, u)
+			if err != nil {
+				return false, op, err
+			}
+			changed, err := store.StoreLifecycleStage(ctx, stage)
+			return changed, op, err
+		},
+	)
+}
+
+// DeleteLifecycleStageHandler returns a handler that deletes a
+// lifecycle stage. The stage name is the resource ID.
+func DeleteLifecycleStageHandler(store storage.LifecycleStageStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			changed, err := store.DeleteLifecycleStage(ctx, resource)
+			return changed, "delete lifecycle stage", err
+		},
+	)
+}
+
+// EnrollmentLifecycleStage is the lifecycle stage of an enrollment.
+type EnrollmentLifecycleStage struct {
+	Stage string `json:"stage"`
+}
+
+// GetEnrollmentLifecycleStageHandler returns a handler that retrieves
+// the lifecycle stage of an enrollment. No content is returned if the
+// enrollment has never been transitioned.
+func GetEnrollmentLifecycleStageHandler(store storage.LifecycleStageStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			stage, err := store.RetrieveEnrollmentLifecycleStage(ctx, resource)
+			if err != nil || stage == "" {
+				return nil, err
+			}
+			return &EnrollmentLifecycleStage{Stage: stage}, nil
+		},
+	)
+}
+
+// PutEnrollmentLifecycleStageHandler returns a handler that transitions
+// an enrollment to the lifecycle stage in the "stage" query parameter.
+// The sets of the enrollment are changed atomically and the enrollment
+// is notified once.
+func PutEnrollmentLifecycleStageHandler(store storage.LifecycleStageStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			const op = "transition enrollment lifecycle stage"
+			stage := u.Query().Get("stage")
+			if err := storage.ValidateIdentifier("lifecycle stage", stage); err != nil {
+				return false, op, err
+			}
+			changed, err := store.TransitionEnrollmentLifecycleStage(ctx, resource, stage)
+			if err == nil && changed && notify {
+				err = notifier.Changed(ctx, nil, nil, []string{resource})
+				if err != nil {
+					err = fmt.Errorf("notify enrollment: %w", err)
+				}
+			}
+			return changed, op, err
+		},
+	)
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/lint"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// LintStorage is the storage needed to lint declarations.
+type LintStorage interface {
+	storage.DeclarationsRetriever
+	storage.DeclarationAPIRetriever
+	storage.SetDeclarationsRetriever
+}
+
+// retrieveDeclarations retrieves the declarations in setName.
+// All declarations are retrieved if setName is empty.
+func retrieveDeclarations(ctx context.Context, store LintStorage, setName string) ([]*ddm.Declaration, error) {
+	var ids []string
+	var err error
+	if setName != "" {
+		ids, err = store.RetrieveSetDeclarations(ctx, setName)
+	} else {
+		ids, err = store.RetrieveDeclarations(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	decls := make([]*ddm.Declaration, 0, len(ids))
+	for _, id := range ids {
+		d, err := store.RetrieveDeclaration(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving declaration %s: %w", id, err)
+		}
+		decls = append(decls, d)
+	}
+	return decls, nil
+}
+
+// GetLintHandler returns a handler that lints stored declarations.
+// If the "set" query parameter is provided only the declarations in
+// that set are linted. Otherwise all declarations are linted.
+func GetLintHandler(store LintStorage, linter *lint.Linter, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setName := r.URL.Query().Get("set")
+		decls, err := retrieveDeclarations(r.Context(), store, setName)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving declarations", logger)
+			return
+		}
+		var known map[string]bool
+		if setName != "" {
+			// declarations outside of the set may still be referenced
+			ids, err := store.RetrieveDeclarations(r.Context())
+			if err != nil {
+				jsonErrorAndLog(w, 0, err, "retrieving declarations", logger)
+				return
+			}
+			known = make(map[string]bool, len(ids))
+			for _, id := range ids {
+				known[id] = true
+			}
+		}
+		report := linter.Lint(decls, known)
+		logger.Debug(logkeys.Message, "linted declarations", logkeys.GenericCount, len(decls), "findings", len(report.Findings))
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PostLintHandler returns a handler that lints the declaration in the
+// request body without storing it. References are resolved against
+// stored declarations.
+func PostLintHandler(store storage.DeclarationsRetriever, linter *lint.Linter, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, ddm.MaxDeclarationSize+1))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "reading body", logger)
+			return
+		}
+		d, err := ddm.ParseDeclaration(bodyBytes)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "parsing declaration", logger)
+			return
+		}
+		if !d.Valid() {
+			jsonErrorAndLog(w, http.StatusBadRequest, ddm.ErrInvalidDeclaration, "parsing declaration", logger)
+			return
+		}
+		logger = logger.With(logkeys.DeclarationID, d.Identifier)
+		ids, err := store.RetrieveDeclarations(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving declarations", logger)
+			return
+		}
+		known := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			known[id] = true
+		}
+		report := linter.LintDeclaration(d, known)
+		logger.Debug(logkeys.Message, "linted declaration", "findings", len(report.Findings))
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// LintWarningMiddleware lints the declaration in the request body
+// before next stores it. If next succeeds the warning and error
+// severity findings are added to the response as HTTP "Warning"
+// headers. Failures to lint are logged but otherwise ignored.
+func LintWarningMiddleware(next http.Handler, store storage.DeclarationsRetriever, linter *lint.Linter, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, ddm.MaxDeclarationSize+1))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "reading body", logger)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		d, err := ddm.ParseDeclaration(bodyBytes)
+		if err != nil {
+			// let next report the invalid declaration
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger = logger.With(logkeys.DeclarationID, d.Identifier)
+		ids, err := store.RetrieveDeclarations(r.Context())
+		if err != nil {
+			logger.Info(logkeys.Message, "retrieving declarations", logkeys.Error, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		known := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			known[id] = true
+		}
+		report := linter.LintDeclaration(d, known)
+		next.ServeHTTP(&sizeWarningWriter{
+			ResponseWriter: w,
+			warn: func(status int) {
+				if status >= 400 {
+					return
+				}
+				for _, f := range report.Findings {
+					if f.Severity == lint.SeverityInfo {
+						continue
+					}
+					w.Header().Add("Warning", fmt.Sprintf("199 kmfddm %q", fmt.Sprintf("%s: %s: %s", f.Severity, f.Rule, f.Message)))
+				}
+			},
+		}, r)
+	}
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// maxMaintenanceSize is the maximum size of a maintenance mode request body.
+const maxMaintenanceSize = 1024
+
+// Maintenance toggles the maintenance mode of the DDM protocol endpoints.
+type Maintenance interface {
+	Enable(retryAfter time.Duration)
+	Disable()
+	Status() *ddmhttp.MaintenanceStatus
+}
+
+// maintenanceRequest is the optional JSON body of a maintenance mode request.
+type maintenanceRequest struct {
+	// RetryAfter is the number of seconds after which enrollments are
+	// asked to retry their requests.
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// maintenanceResponse responds with the status of m.
+func maintenanceResponse(w http.ResponseWriter, m Maintenance, logger log.Logger) {
+	if err := jsonResponse(w, 0, m.Status()); err != nil {
+		logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+	}
+}
+
+// GetMaintenanceHandler returns a handler that responds with the
+// maintenance mode status of the DDM protocol endpoints.
+func GetMaintenanceHandler(m Maintenance, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		maintenanceResponse(w, m, ctxlog.Logger(r.Context(), logger))
+	}
+}
+
+// PutMaintenanceHandler returns a handler that enables the maintenance
+// mode of the DDM protocol endpoints. The optional JSON request body
+// may change the seconds after which enrollments are asked to retry.
+func PutMaintenanceHandler(m Maintenance, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		req := new(maintenanceRequest)
+		err := json.NewDecoder(io.LimitReader(r.Body, maxMaintenanceSize)).Decode(req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding maintenance request", logger)
+			return
+		}
+		if req.RetryAfter < 0 {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("negative retry_after"), "validating input", logger)
+			return
+		}
+		m.Enable(time.Duration(req.RetryAfter) * time.Second)
+		logger.Info(logkeys.Message, "enabled maintenance mode", "retry_after", m.Status().RetryAfter)
+		maintenanceResponse(w, m, logger)
+	}
+}
+
+// DeleteMaintenanceHandler returns a handler that disables the
+// maintenance mode of the DDM protocol endpoints.
+func DeleteMaintenanceHandler(m Maintenance, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		m.Disable()
+		logger.Info(logkeys.Message, "disabled maintenance mode")
+		maintenanceResponse(w, m, logger)
+	}
+}
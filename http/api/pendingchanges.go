@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetPendingChangesHandler returns a handler that lists the pending changes.
+func GetPendingChangesHandler(store storage.PendingChangeStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		changes, err := store.RetrievePendingChanges(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving pending changes", logger)
+			return
+		}
+		ret := make([]*httpddm.PendingChangeJSON, 0, len(changes))
+		for _, c := range changes {
+			ret = append(ret, httpddm.NewPendingChangeJSON(c))
+		}
+		if err = jsonResponse(w, 0, ret); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// retrievePendingChange retrieves the pending change of the resource ID.
+// An error response is written if it can not be retrieved.
+func retrievePendingChange(w http.ResponseWriter, r *http.Request, store storage.PendingChangeStorage, logger log.Logger) (*storage.PendingChange, log.Logger) {
+	id := getResourceID(r)
+	if id == "" {
+		jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+		return nil, logger
+	}
+	logger = logger.With("pending_change", id)
+	change, err := store.RetrievePendingChange(r.Context(), id)
+	if err != nil {
+		jsonErrorAndLog(w, 0, err, "retrieving pending change", logger)
+		return nil, logger
+	}
+	return change, logger.With("requester", change.Principal)
+}
+
+// deletePendingChange deletes change.
+// An error response is written if it can not be deleted.
+// Deleting serializes concurrent approvals and rejections.
+func deletePendingChange(w http.ResponseWriter, r *http.Request, store storage.PendingChangeStorage, change *storage.PendingChange, logger log.Logger) bool {
+	err := store.DeletePendingChange(r.Context(), change.ID)
+	if err != nil {
+		jsonErrorAndLog(w, 0, err, "deleting pending change", logger)
+		return false
+	}
+	return true
+}
+
+// ApprovePendingChangeHandler returns a handler that approves and
+// applies the pending change of the resource ID. The approving
+// principal must differ from the principal that made the change. The
+// change is applied by replaying its request to router and the
+// response of the replayed request is returned.
+func ApprovePendingChangeHandler(store storage.PendingChangeStorage, router http.Handler, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		change, logger := retrievePendingChange(w, r, store, logger)
+		if change == nil {
+			return
+		}
+		if principal, ok := httpddm.GetPrincipal(r.Context()); !ok || principal.Name == change.Principal {
+			jsonErrorAndLog(w, http.StatusForbidden, httpddm.ErrSamePrincipal, "approving pending change", logger)
+			return
+		}
+		if !deletePendingChange(w, r, store, change, logger) {
+			return
+		}
+		logger.Debug(logkeys.Message, "approved pending change")
+		if err := httpddm.ReplayPendingChange(w, r, router, change); err != nil {
+			jsonErrorAndLog(w, 0, err, "replaying pending change", logger)
+		}
+	}
+}
+
+// RejectPendingChangeHandler returns a handler that rejects (deletes)
+// the pending change of the resource ID without applying it.
+func RejectPendingChangeHandler(store storage.PendingChangeStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		change, logger := retrievePendingChange(w, r, store, logger)
+		if change == nil {
+			return
+		}
+		if !deletePendingChange(w, r, store, change, logger) {
+			return
+		}
+		logger.Debug(logkeys.Message, "rejected pending change")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
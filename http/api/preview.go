@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/freeze"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/redact"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// PreviewStorage is the storage needed to preview the DDM documents of
+// an enrollment. It should be the same storage used by the DDM protocol
+// handlers so that the preview matches what is served.
+type PreviewStorage interface {
+	storage.TokensDeclarationItemsRetriever
+	storage.DeclarationRetriever
+}
+
+// Preview contains the DDM documents an enrollment would be served.
+type Preview struct {
+	Tokens           json.RawMessage `json:"tokens"`
+	DeclarationItems json.RawMessage `json:"declaration_items"`
+
+	// Declarations are the declarations of the declaration items keyed by identifier.
+	Declarations map[string]json.RawMessage `json:"declarations"`
+}
+
+// preview retrieves the DDM documents of enrollmentID from store.
+func preview(ctx context.Context, store PreviewStorage, enrollmentID string) (*Preview, error) {
+	f, err := freeze.Snapshot(ctx, store, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	return &Preview{
+		Tokens:           f.TokensJSON,
+		DeclarationItems: f.DeclarationItemsJSON,
+		Declarations:     f.Declarations,
+	}, nil
+}
+
+// redactPreview masks the sensitive payload values of the declarations of p with redactor.
+func redactPreview(p *Preview, redactor *redact.Redactor) error {
+	for id, raw := range p.Declarations {
+		d, err := ddm.ParseDeclaration(raw)
+		if err != nil {
+			return fmt.Errorf("parsing declaration %s: %w", id, err)
+		}
+		if p.Declarations[id], err = redactor.Redact(d); err != nil {
+			return fmt.Errorf("redacting declaration %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// GetPreviewHandler returns a handler that previews the tokens,
+// declaration items, and declarations an enrollment would be served by
+// the DDM protocol endpoints. Nothing is counted as served.
+// Sensitive payload values are masked by redactor (if not nil) for
+// read-only principals. The enrollment ID is the resource ID.
+func GetPreviewHandler(store PreviewStorage, redactor *redact.Redactor, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		enrollmentID := getResourceID(r)
+		if err := storage.ValidateIdentifier("enrollment ID", enrollmentID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With(logkeys.EnrollmentID, enrollmentID)
+		p, err := preview(r.Context(), store, enrollmentID)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "previewing enrollment", logger)
+			return
+		}
+		if httpddm.IsReadOnly(r.Context()) {
+			if err = redactPreview(p, redactor); err != nil {
+				jsonErrorAndLog(w, 0, err, "redacting preview", logger)
+				return
+			}
+		}
+		logger.Debug(logkeys.Message, "previewed enrollment", logkeys.DeclarationCount, len(p.Declarations))
+		if err = jsonResponse(w, 0, p); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
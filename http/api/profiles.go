@@ -0,0 +1,147 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/builder"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// MaxProfileSize is the maximum size in bytes of an uploaded configuration profile.
+const MaxProfileSize = 4 << 20
+
+// ErrProfileHostingDisabled is returned when converting a profile to
+// a hosted legacy declaration without a profile base URL.
+var ErrProfileHostingDisabled = errors.New("profile hosting not configured; use inline profile data")
+
+// LegacyProfileStorage is the storage needed to convert configuration
+// profiles into legacy declarations.
+type LegacyProfileStorage interface {
+	storage.DeclarationStorer
+	storage.ProfileStorage
+}
+
+// PostLegacyProfileHandler returns a handler that converts the
+// configuration profile in the request body into a legacy profile
+// declaration and stores it.
+//
+// The profile is hosted by storing it and referencing its URL under
+// profileBaseURL. If the "inline" query parameter is set the profile
+// is instead embedded in the declaration as a data URL. The
+// "interactive" query parameter creates an interactive legacy
+// declaration. The "identifier" and "name" query parameters default to
+// the PayloadIdentifier and PayloadDisplayName of the profile.
+func PostLegacyProfileHandler(store LegacyProfileStorage, profileBaseURL string, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		raw, err := io.ReadAll(io.LimitReader(r.Body, MaxProfileSize+1))
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "reading body", logger)
+			return
+		}
+		if len(raw) > MaxProfileSize {
+			jsonErrorAndLog(w, http.StatusRequestEntityTooLarge, fmt.Errorf("profile exceeds %d bytes", MaxProfileSize), "reading body", logger)
+			return
+		}
+		profile, err := builder.ParseProfile(raw)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "parsing profile", logger)
+			return
+		}
+
+		q := r.URL.Query()
+		identifier := q.Get("identifier")
+		if identifier == "" {
+			identifier = profile.PayloadIdentifier
+		}
+		name := q.Get("name")
+		if name == "" {
+			name = profile.PayloadDisplayName
+		}
+
+		var profileURL string
+		if _, ok := q["inline"]; ok && boolish(q.Get("inline")) {
+			profileURL = builder.ProfileDataURL(raw)
+		} else {
+			if profileBaseURL == "" {
+				jsonErrorAndLog(w, http.StatusBadRequest, ErrProfileHostingDisabled, "validating input", logger)
+				return
+			}
+			// content-addressed so that the URL is not guessable
+			sum := sha256.Sum256(raw)
+			profileID := hex.EncodeToString(sum[:])
+			if err = store.StoreProfile(r.Context(), profileID, raw); err != nil {
+				jsonErrorAndLog(w, 0, err, "storing profile", logger)
+				return
+			}
+			profileURL = strings.TrimSuffix(profileBaseURL, "/") + "/" + profileID
+		}
+
+		var payload builder.Payload = &builder.LegacyProfile{ProfileURL: profileURL}
+		if _, ok := q["interactive"]; ok && boolish(q.Get("interactive")) {
+			payload = &builder.LegacyInteractiveProfile{ProfileURL: profileURL, VisibleName: name}
+		}
+		d, err := builder.Build(identifier, payload)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "building declaration", logger)
+			return
+		}
+		logger = logger.With(
+			logkeys.DeclarationID, d.Identifier,
+			logkeys.DeclarationType, d.Type,
+		)
+
+		changed, err := store.StoreDeclaration(r.Context(), d)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "storing declaration", logger)
+			return
+		}
+		// only notify if we have a change
+		notify := changed && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "stored legacy profile declaration",
+			logkeys.Changed, changed,
+			logkeys.Notify, notify,
+			"signed", profile.Signed,
+		)
+		w.Header().Set("Content-type", jsonContentType)
+		w.Write(d.Raw)
+		if notify {
+			err = notifier.Changed(r.Context(), []string{d.Identifier}, nil, nil)
+			if err != nil {
+				logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+				return
+			}
+		}
+	}
+}
+
+// GetProfileHandler returns a handler that serves a hosted configuration profile.
+// It is intended to be reachable by enrollments without authentication.
+func GetProfileHandler(store storage.ProfileStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		profileID := getResourceID(r)
+		if profileID == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+			return
+		}
+		logger = logger.With("profile_id", profileID)
+		raw, err := store.RetrieveProfile(r.Context(), profileID)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving profile", logger)
+			return
+		}
+		w.Header().Set("Content-type", builder.ProfileContentType)
+		w.Write(raw)
+	}
+}
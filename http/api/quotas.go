@@ -0,0 +1,33 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/quota"
+)
+
+// QuotaReporter reports the usage of API quotas.
+type QuotaReporter interface {
+	Report(ctx context.Context) (*quota.Report, error)
+}
+
+// GetQuotasHandler returns a handler that reports the limits and usage
+// of the API quotas of principals.
+func GetQuotasHandler(q QuotaReporter, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		report, err := q.Report(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "reporting quotas", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "reported quotas", logkeys.GenericCount, len(report.Principals))
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
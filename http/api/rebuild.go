@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetDDMRebuildsHandler returns a handler that lists the enrollments
+// whose derived DDM data failed to be rebuilt.
+func GetDDMRebuildsHandler(store storage.DDMRebuildStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		rebuilds, err := store.RetrieveDDMRebuilds(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving DDM rebuilds", logger)
+			return
+		}
+		if rebuilds == nil {
+			// encode as an empty JSON array
+			rebuilds = []storage.DDMRebuild{}
+		}
+		if err = jsonResponse(w, 0, rebuilds); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// RetryDDMRebuildsHandler returns a handler that retries the failed
+// rebuilds of the derived DDM data of enrollments and responds with
+// the rebuilt enrollment IDs. The rebuilt enrollments are notified.
+func RetryDDMRebuildsHandler(store storage.DDMRebuildStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		rebuilt, err := store.RetryDDMRebuilds(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrying DDM rebuilds", logger)
+			return
+		}
+		notify := len(rebuilt) > 0 && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "retried DDM rebuilds",
+			"rebuilt", len(rebuilt),
+			logkeys.Notify, notify,
+		)
+		if notify {
+			if err = notifier.Changed(r.Context(), nil, nil, rebuilt); err != nil {
+				jsonErrorAndLog(w, 0, err, "notifying", logger)
+				return
+			}
+		}
+		if rebuilt == nil {
+			// encode as an empty JSON array
+			rebuilt = []string{}
+		}
+		if err = jsonResponse(w, 0, rebuilt); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
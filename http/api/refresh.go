@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/notifier"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RefreshEnrollmentHandler returns a handler that regenerates the
+// derived DDM data of an enrollment and notifies it, even if it was
+// recently notified. This is useful when data outside of declarations
+// (e.g. that of declaration transformers) changed for the enrollment.
+// The enrollment ID is the resource ID.
+func RefreshEnrollmentHandler(store storage.EnrollmentDDMRefresher, n Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, notify bool) (bool, string, error) {
+			const op = "refresh enrollment"
+			if err := store.RefreshEnrollmentDDM(ctx, resource); err != nil {
+				return false, op, err
+			}
+			if notify {
+				if err := n.Changed(notifier.WithoutThrottle(ctx), nil, nil, []string{resource}); err != nil {
+					return true, op, fmt.Errorf("notify enrollment: %w", err)
+				}
+			}
+			return true, op, nil
+		},
+	)
+}
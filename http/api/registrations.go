@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxRegistrationSize is the maximum size of an enrollment registration request body.
+const maxRegistrationSize = 16384
+
+// EnrollmentRegistrationStorage is the storage needed to register enrollments.
+type EnrollmentRegistrationStorage interface {
+	storage.EnrollmentRegistrationStorage
+	storage.EnrollmentSetStorage
+}
+
+// registrationRequest is the JSON body of an enrollment registration request.
+type registrationRequest struct {
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Sets are pre-provisioned for the enrollment when registered.
+	Sets []string `json:"sets,omitempty"`
+}
+
+// GetEnrollmentRegistrationHandler returns a handler that retrieves the
+// registration of an enrollment. The enrollment ID is the resource ID.
+func GetEnrollmentRegistrationHandler(store storage.EnrollmentRegistrationStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveEnrollmentRegistration(ctx, resource)
+		},
+	)
+}
+
+// PutEnrollmentRegistrationHandler returns a handler that registers an
+// enrollment with the metadata in the JSON request body. Any existing
+// registration of the enrollment is replaced. The enrollment is also
+// associated with any sets in the request body so that its set
+// membership can be provisioned before its first DDM sync; it is
+// notified if its sets changed. The stored registration is returned.
+// The enrollment ID is the resource ID.
+func PutEnrollmentRegistrationHandler(store EnrollmentRegistrationStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		enrollmentID := getResourceID(r)
+		if err := storage.ValidateIdentifier("enrollment ID", enrollmentID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With(logkeys.EnrollmentID, enrollmentID)
+		req := new(registrationRequest)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxRegistrationSize)).Decode(req); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding enrollment registration", logger)
+			return
+		}
+		registration := &storage.EnrollmentRegistration{
+			EnrollmentID: enrollmentID,
+			Metadata:     req.Metadata,
+			Timestamp:    time.Now().UTC().Truncate(time.Second),
+		}
+		if err := registration.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		for _, setName := range req.Sets {
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+				return
+			}
+		}
+		if err := store.StoreEnrollmentRegistration(r.Context(), registration); err != nil {
+			jsonErrorAndLog(w, 0, err, "storing enrollment registration", logger)
+			return
+		}
+		var changed bool
+		for _, setName := range req.Sets {
+			setChanged, err := store.StoreEnrollmentSet(r.Context(), enrollmentID, setName)
+			if err != nil {
+				jsonErrorAndLog(w, 0, err, "storing enrollment set", logger.With("set", setName))
+				return
+			}
+			changed = changed || setChanged
+		}
+		if changed && shouldNotify(r.URL) {
+			if err := notifier.Changed(r.Context(), nil, nil, []string{enrollmentID}); err != nil {
+				jsonErrorAndLog(w, 0, fmt.Errorf("notify enrollment: %w", err), "notifying", logger)
+				return
+			}
+		}
+		logger.Debug(logkeys.Message, "stored enrollment registration", "sets", len(req.Sets), logkeys.Changed, changed)
+		if err := jsonResponse(w, 0, registration); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// DeleteEnrollmentRegistrationHandler returns a handler that unregisters
+// an enrollment. If the "sets" query parameter is true the enrollment
+// is also dissociated from all of its sets and notified.
+// The enrollment ID is the resource ID.
+func DeleteEnrollmentRegistrationHandler(store EnrollmentRegistrationStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			const op = "delete enrollment registration"
+			changed, err := store.DeleteEnrollmentRegistration(ctx, resource)
+			if err != nil || !boolish(u.Query().Get("sets")) {
+				return changed, op, err
+			}
+			setNames, err := store.RetrieveEnrollmentSets(ctx, resource)
+			if err != nil {
+				return changed, op, fmt.Errorf("retrieving enrollment sets: %w", err)
+			}
+			var setsChanged bool
+			for _, setName := range setNames {
+				setChanged, err := store.RemoveEnrollmentSet(ctx, resource, setName)
+				if err != nil {
+					return changed, op, fmt.Errorf("removing enrollment set %s: %w", setName, err)
+				}
+				setsChanged = setsChanged || setChanged
+			}
+			if setsChanged && notify {
+				if err = notifier.Changed(ctx, nil, nil, []string{resource}); err != nil {
+					err = fmt.Errorf("notify enrollment: %w", err)
+				}
+			}
+			return changed || setsChanged, op, err
+		},
+	)
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RepairDDMHandler returns a handler that recomputes the derived DDM
+// data of all enrollments and responds with the report of mismatches.
+// No changes are made if the "dryrun" query parameter is set.
+// Otherwise the repaired enrollments are notified.
+func RepairDDMHandler(store storage.DDMRepairer, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		dryRun := boolish(r.URL.Query().Get("dryrun"))
+		report, err := store.RepairEnrollmentDDM(r.Context(), dryRun)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "repairing enrollment DDM", logger)
+			return
+		}
+		ids := make([]string, 0, len(report.Mismatches))
+		for _, m := range report.Mismatches {
+			ids = append(ids, m.EnrollmentID)
+		}
+		notify := report.Repaired && len(ids) > 0 && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "repaired enrollment DDM",
+			"dry_run", dryRun,
+			"enrollments", report.Enrollments,
+			"mismatches", len(ids),
+			logkeys.Notify, notify,
+		)
+		if notify {
+			if err = notifier.Changed(r.Context(), nil, nil, ids); err != nil {
+				jsonErrorAndLog(w, 0, err, "notifying", logger)
+				return
+			}
+		}
+		if report.Mismatches == nil {
+			// encode as an empty JSON array
+			report.Mismatches = []storage.DDMMismatch{}
+		}
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
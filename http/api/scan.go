@@ -0,0 +1,33 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/scan"
+)
+
+// ScanMiddleware collects the annotations of the scanners of the
+// declarations that next stores (see scan.Chain.CheckDeclaration). The
+// annotations are logged and, if next succeeds, added to the response
+// as HTTP "Warning" headers.
+func ScanMiddleware(next http.Handler, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, annotations := scan.CollectAnnotations(r.Context())
+		logger := ctxlog.Logger(ctx, logger)
+		next.ServeHTTP(&sizeWarningWriter{
+			ResponseWriter: w,
+			warn: func(status int) {
+				for _, a := range annotations() {
+					logger.Info(logkeys.Message, "scanner annotation", "scanner", a.Scanner, "annotation", a.Message)
+					if status < 400 {
+						w.Header().Add("Warning", fmt.Sprintf("199 kmfddm %q", fmt.Sprintf("%s: %s", a.Scanner, a.Message)))
+					}
+				}
+			},
+		}, r.WithContext(ctx))
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxScheduleSize is the maximum size of an assignment schedule request body.
+const maxScheduleSize = 4096
+
+// GetAssignmentSchedulesHandler returns a handler that retrieves all assignment schedules.
+func GetAssignmentSchedulesHandler(store storage.AssignmentScheduleStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		schedules, err := store.RetrieveAssignmentSchedules(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving assignment schedules", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "retrieved assignment schedules", logkeys.GenericCount, len(schedules))
+		if schedules == nil {
+			// encode as an empty JSON array
+			schedules = []*storage.AssignmentSchedule{}
+		}
+		if err = jsonResponse(w, 0, schedules); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PutAssignmentScheduleHandler returns a handler that stores the
+// assignment schedule in the JSON request body. A new ID is generated
+// if the schedule has none. The stored schedule is returned.
+// The schedule is inactive until the scheduler next runs after its start.
+func PutAssignmentScheduleHandler(store storage.AssignmentScheduleStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		schedule := new(storage.AssignmentSchedule)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxScheduleSize)).Decode(schedule); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding assignment schedule", logger)
+			return
+		}
+		if err := schedule.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		if schedule.ID == "" {
+			schedule.ID = uuid.NewString()
+		}
+		schedule.Active = false
+		logger = logger.With("schedule", schedule.ID)
+		if err := store.StoreAssignmentSchedule(r.Context(), schedule); err != nil {
+			jsonErrorAndLog(w, 0, err, "storing assignment schedule", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "stored assignment schedule")
+		if err := jsonResponse(w, 0, schedule); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// DeleteAssignmentScheduleHandler returns a handler that deletes an
+// assignment schedule. An active association is left in place.
+// The schedule ID is the resource ID.
+func DeleteAssignmentScheduleHandler(store storage.AssignmentScheduleStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		id := getResourceID(r)
+		if id == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, ErrEmptyResourceID, "validating input", logger)
+			return
+		}
+		logger = logger.With("schedule", id)
+		err := store.DeleteAssignmentSchedule(r.Context(), id)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "deleting assignment schedule", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "deleted assignment schedule")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
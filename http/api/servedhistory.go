@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetServedHistoryHandler returns a handler that retrieves the history
+// of DDM documents served to an enrollment, most recent first. The
+// first document of each kind is when its served content last changed.
+// The "kind" query parameter limits the history to one kind of document.
+// The enrollment ID is the resource ID.
+func GetServedHistoryHandler(store storage.ServedHistoryStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
+			kind := u.Query().Get("kind")
+			switch kind {
+			case "", storage.ServedTokens, storage.ServedDeclarationItems:
+			default:
+				return nil, storage.Categorize(storage.ErrInvalid, fmt.Errorf("invalid served document kind: %q", kind))
+			}
+			history, err := store.RetrieveServedHistory(ctx, resource, kind)
+			if err == nil && history == nil {
+				history = []storage.ServedDocument{}
+			}
+			return history, err
+		},
+	)
+}
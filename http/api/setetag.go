@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// setETag returns the HTTP entity tag of a set with declarationIDs.
+// It is derived from the declarations in the set so it changes
+// whenever the set is modified regardless of storage backend.
+func setETag(declarationIDs []string) string {
+	ids := append([]string{}, declarationIDs...)
+	sort.Strings(ids)
+	return fmt.Sprintf(`"%x"`, sha256.Sum256([]byte(strings.Join(ids, "\n"))))
+}
+
+// etagMatches reports whether the If-Match or If-None-Match header
+// value matches etag. The weak comparison function is used.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPreconditions checks the "If-Match" header of requests that modify
+// a set against the current ETag of the set (as returned when its
+// declarations are retrieved). Requests whose set was modified since
+// are rejected with 412 Precondition Failed to avoid lost updates.
+// All set modifications are serialized (conditional or not) so that the
+// check and the modification are atomic within this server.
+type SetPreconditions struct {
+	mu     sync.Mutex
+	store  storage.SetDeclarationsRetriever
+	logger log.Logger
+}
+
+// NewSetPreconditions creates a new SetPreconditions that retrieves the
+// current declarations of sets from store.
+func NewSetPreconditions(store storage.SetDeclarationsRetriever, logger log.Logger) *SetPreconditions {
+	if store == nil {
+		panic("nil store")
+	}
+	return &SetPreconditions{store: store, logger: logger}
+}
+
+// Middleware checks the "If-Match" header of requests for the set
+// named by the resource ID before calling next. Requests without the
+// header are passed to next unchecked but are still serialized with
+// the other set modifications.
+func (p *SetPreconditions) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := ctxlog.Logger(r.Context(), p.logger)
+		setName := getResourceID(r)
+		declarationIDs, err := p.store.RetrieveSetDeclarations(r.Context(), setName)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving set declarations", logger)
+			return
+		}
+		if etag := setETag(declarationIDs); !etagMatches(ifMatch, etag) {
+			logger.Debug(logkeys.Message, "set precondition failed", "set", setName, "etag", etag)
+			w.Header().Set("ETag", etag)
+			jsonError(w, http.StatusPreconditionFailed, fmt.Errorf("set modified: %s", setName))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Serialize serializes requests to next with the set modifications of
+// Middleware. It is intended for other requests that modify sets (e.g.
+// in bulk) so that they do not interleave with conditional requests.
+func (p *SetPreconditions) Serialize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
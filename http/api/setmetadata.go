@@ -0,0 +1,105 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// maxSetMetadataSize is the maximum size of a set metadata request body.
+const maxSetMetadataSize = 16384
+
+// SetMetadataListStorage retrieves the metadata of all sets.
+type SetMetadataListStorage interface {
+	storage.SetRetreiver
+	storage.SetMetadataRetriever
+}
+
+// GetSetMetadataHandler returns a handler that retrieves the metadata
+// of sets keyed by set name. The resource ID may be a comma-separated
+// list of set names.
+func GetSetMetadataHandler(store storage.SetMetadataRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveSetMetadata(ctx, strings.Split(resource, ","))
+		},
+	)
+}
+
+// GetAllSetMetadataHandler returns a handler that retrieves the
+// metadata of all sets (see RetrieveSets) keyed by set name.
+func GetAllSetMetadataHandler(store SetMetadataListStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setNames, err := store.RetrieveSets(r.Context())
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving sets", logger)
+			return
+		}
+		metadata, err := store.RetrieveSetMetadata(r.Context(), setNames)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving set metadata", logger)
+			return
+		}
+		if err = jsonResponse(w, 0, metadata); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// PutSetMetadataHandler returns a handler that stores the set metadata
+// in the JSON request body. Any existing metadata of the set is
+// replaced. The stored metadata is returned. The set name is the
+// resource ID.
+func PutSetMetadataHandler(store storage.SetMetadataStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setName := getResourceID(r)
+		if err := storage.ValidateIdentifier("set name", setName); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("set", setName)
+		metadata := new(storage.SetMetadata)
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxSetMetadataSize)).Decode(metadata); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "decoding set metadata", logger)
+			return
+		}
+		metadata.SetName = setName
+		metadata.Timestamp = time.Now().UTC().Truncate(time.Second)
+		if err := metadata.Validate(); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		if err := store.StoreSetMetadata(r.Context(), metadata); err != nil {
+			jsonErrorAndLog(w, 0, err, "storing set metadata", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "stored set metadata")
+		if err := jsonResponse(w, 0, metadata); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// DeleteSetMetadataHandler returns a handler that deletes the metadata
+// of a set. The set name is the resource ID.
+func DeleteSetMetadataHandler(store storage.SetMetadataStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, _ bool) (bool, string, error) {
+			changed, err := store.DeleteSetMetadata(ctx, resource)
+			return changed, "delete set metadata", err
+		},
+	)
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetSetPatternsHandler returns a handler that retrieves the enrollment
+// ID patterns of a set. The set name is the resource ID.
+func GetSetPatternsHandler(store storage.SetPatternStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			patterns, err := store.RetrieveSetPatterns(ctx)
+			if err != nil {
+				return nil, err
+			}
+			// encode as an empty JSON array
+			ret := []storage.SetPattern{}
+			for _, p := range patterns {
+				if p.Set == resource {
+					ret = append(ret, p)
+				}
+			}
+			return ret, nil
+		},
+	)
+}
+
+// setPatternFromURL returns the set pattern of setName in the "kind"
+// and "pattern" query parameters of u.
+func setPatternFromURL(setName string, u *url.URL) (*storage.SetPattern, error) {
+	p := &storage.SetPattern{
+		Set:     setName,
+		Kind:    u.Query().Get("kind"),
+		Pattern: u.Query().Get("pattern"),
+	}
+	return p, p.Validate()
+}
+
+// PutSetPatternHandler returns a handler that adds an enrollment ID
+// pattern to a set. Enrollments whose IDs match the pattern are members
+// of the set in addition to its explicit enrollments. The set name is
+// the resource ID.
+func PutSetPatternHandler(store storage.SetPatternStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			const op = "store set pattern"
+			p, err := setPatternFromURL(resource, u)
+			if err != nil {
+				return false, op, err
+			}
+			changed, err := store.StoreSetPattern(ctx, p)
+			if err == nil && changed && notify {
+				err = notifier.Changed(ctx, nil, []string{resource}, nil)
+				if err != nil {
+					err = fmt.Errorf("notify set: %w", err)
+				}
+			}
+			return changed, op, err
+		},
+	)
+}
+
+// DeleteSetPatternHandler returns a handler that removes an enrollment
+// ID pattern from a set. The set name is the resource ID.
+func DeleteSetPatternHandler(store storage.SetPatternStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
+			const op = "remove set pattern"
+			p, err := setPatternFromURL(resource, u)
+			if err != nil {
+				return false, op, err
+			}
+			changed, err := store.RemoveSetPattern(ctx, p)
+			if err == nil && changed && notify {
+				err = notifier.Changed(ctx, nil, []string{resource}, nil)
+				if err != nil {
+					err = fmt.Errorf("notify set: %w", err)
+				}
+			}
+			return changed, op, err
+		},
+	)
+}
@@ -2,14 +2,13 @@ package api
 
 import (
 	"context"
-	"encoding/json"
-	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
 
 	"github.com/jessepeterson/kmfddm/log"
 	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
 	"github.com/jessepeterson/kmfddm/storage"
 )
 
@@ -26,15 +25,38 @@ func GetDeclarationSetsHandler(store storage.DeclarationSetRetriever, logger log
 }
 
 // GetSetDeclarationsHandler retrieves the list of declarations in a set.
+// The ETag header of the response identifies the current declarations
+// of the set for use with conditional modifications (see SetPreconditions).
 // The entire request URL path is assumed to contain the set name.
 // This implies the handler should have the path prefix stripped before use.
 func GetSetDeclarationsHandler(store storage.SetDeclarationsRetriever, logger log.Logger) http.HandlerFunc {
-	return simpleJSONResourceHandler(
-		logger,
-		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
-			return store.RetrieveSetDeclarations(ctx, resource)
-		},
-	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setName := getResourceID(r)
+		if err := storage.ValidateIdentifier("set name", setName); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("resource", setName)
+		declarationIDs, err := store.RetrieveSetDeclarations(r.Context(), setName)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving data", logger)
+			return
+		}
+		etag := setETag(declarationIDs)
+		w.Header().Set("ETag", etag)
+		if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if declarationIDs == nil {
+			// encode as an empty JSON array
+			declarationIDs = []string{}
+		}
+		if err = jsonResponse(w, 0, declarationIDs); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
 }
 
 // PutSetDeclarationHandler associates declarations to a set.
@@ -45,8 +67,8 @@ func PutSetDeclarationHandler(store storage.SetDeclarationStorer, notifier Notif
 		logger,
 		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
 			declarationID := u.Query().Get("declaration")
-			if declarationID == "" {
-				return false, "", errors.New("empty declaration")
+			if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+				return false, "", err
 			}
 			changed, err := store.StoreSetDeclaration(ctx, resource, declarationID)
 			if err == nil && changed && notify {
@@ -61,17 +83,21 @@ func PutSetDeclarationHandler(store storage.SetDeclarationStorer, notifier Notif
 }
 
 // DeleteSetDeclarationHandler dissociates declarations from a set.
+// The removal is reported to events.
 // The entire request URL path is assumed to contain the set name.
 // This implies the handler should have the path prefix stripped before use.
-func DeleteSetDeclarationHandler(store storage.SetDeclarationRemover, notifier Notifier, logger log.Logger) http.HandlerFunc {
+func DeleteSetDeclarationHandler(store storage.SetDeclarationRemover, notifier Notifier, events AdminEvents, logger log.Logger) http.HandlerFunc {
 	return simpleChangeResourceHandler(
 		logger,
 		func(ctx context.Context, resource string, u *url.URL, notify bool) (bool, string, error) {
 			declarationID := u.Query().Get("declaration")
-			if declarationID == "" {
-				return false, "", errors.New("empty declaration")
+			if err := storage.ValidateIdentifier("declaration identifier", declarationID); err != nil {
+				return false, "", err
 			}
 			changed, err := store.RemoveSetDeclaration(ctx, resource, declarationID)
+			if err == nil && changed {
+				events.SetDeclarationRemoved(ctx, resource, declarationID)
+			}
 			if err == nil && changed && notify {
 				err = notifier.Changed(ctx, nil, []string{resource}, nil)
 				if err != nil {
@@ -83,19 +109,23 @@ func DeleteSetDeclarationHandler(store storage.SetDeclarationRemover, notifier N
 	)
 }
 
+// SetsListStorage lists sets.
+type SetsListStorage interface {
+	storage.SetRetreiver
+	storage.SetsPager
+}
+
 // GetSetsHandler returns a handler that retrieves the list of sets.
-func GetSetsHandler(store storage.SetRetreiver, logger log.Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		logger := ctxlog.Logger(r.Context(), logger)
-		ids, err := store.RetrieveSets(r.Context())
-		if err != nil {
-			jsonErrorAndLog(w, 0, err, "retrieving sets", logger)
-		}
-		w.Header().Set("Content-type", jsonContentType)
-		err = json.NewEncoder(w).Encode(&ids)
-		if err != nil {
-			logger.Info("msg", "encoding response body", "err", err)
-			return
-		}
-	}
+// The list is paginated with the "cursor" and "limit" query parameters.
+func GetSetsHandler(store SetsListStorage, logger log.Logger) http.HandlerFunc {
+	return listHandler(
+		logger,
+		"retrieving sets",
+		func(r *http.Request) ([]string, error) {
+			return store.RetrieveSets(r.Context())
+		},
+		func(r *http.Request, after string, limit int) ([]string, error) {
+			return store.RetrieveSetsPage(r.Context(), after, limit)
+		},
+	)
 }
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetSetSnapshotsHandler retrieves the declaration snapshots of a set.
+func GetSetSnapshotsHandler(store storage.SetSnapshotStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveSetSnapshots(ctx, resource)
+		},
+	)
+}
+
+// PostSetSnapshotRestoreHandler restores the declarations of a set to
+// the snapshot in the "snapshot" query parameter. Enrollments of the
+// set are notified once if the set changed.
+func PostSetSnapshotRestoreHandler(store storage.SetSnapshotStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		setName := getResourceID(r)
+		if err := storage.ValidateIdentifier("set name", setName); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		snapshotID := r.URL.Query().Get("snapshot")
+		if snapshotID == "" {
+			jsonErrorAndLog(w, http.StatusBadRequest, errors.New("empty snapshot"), "validating input", logger)
+			return
+		}
+		logger = logger.With("resource", setName, "snapshot", snapshotID)
+		changed, err := store.RestoreSetSnapshot(r.Context(), setName, snapshotID)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "restoring set snapshot", logger)
+			return
+		}
+		// only notify if we have a change
+		notify := changed && shouldNotify(r.URL)
+		logger.Debug(
+			logkeys.Message, "restored set snapshot",
+			logkeys.Changed, changed,
+			logkeys.Notify, notify,
+		)
+		if notify {
+			if err = notifier.Changed(r.Context(), nil, []string{setName}, nil); err != nil {
+				jsonErrorAndLog(w, 0, err, "notifying", logger)
+				return
+			}
+		}
+		status := http.StatusNotModified
+		if changed {
+			status = http.StatusNoContent
+		}
+		// not actually an error, using as a helper
+		http.Error(w, http.StatusText(status), status)
+	}
+}
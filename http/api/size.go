@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// SizeLimits are the thresholds above which an enrollment's DDM
+// documents are considered too large. Zero values disable a threshold.
+// These are guardrails only: enrollments exceeding them are still
+// served their declarations.
+type SizeLimits struct {
+	// Declarations is the number of resolved declarations.
+	Declarations int
+
+	// DeclarationItemsSize is the size in bytes of the declaration-items JSON.
+	DeclarationItemsSize int
+}
+
+// countWarning returns a warning if count exceeds the declarations threshold.
+func (l SizeLimits) countWarning(count int) string {
+	if l.Declarations > 0 && count > l.Declarations {
+		return fmt.Sprintf("resolved declaration count %d exceeds threshold %d", count, l.Declarations)
+	}
+	return ""
+}
+
+// EnrollmentSize contains the sizes of an enrollment's DDM documents.
+type EnrollmentSize struct {
+	Declarations         int      `json:"declarations"`
+	DeclarationItemsSize int      `json:"declaration_items_size"`
+	TokensSize           int      `json:"tokens_size"`
+	Warnings             []string `json:"warnings,omitempty"`
+}
+
+// EnrollmentSizeStorage is the storage needed to report enrollment sizes.
+type EnrollmentSizeStorage interface {
+	storage.EnrollmentDeclarationsRetriever
+	storage.TokensDeclarationItemsRetriever
+}
+
+// enrollmentSize computes the EnrollmentSize of enrollmentID.
+func enrollmentSize(ctx context.Context, store EnrollmentSizeStorage, limits SizeLimits, enrollmentID string) (*EnrollmentSize, error) {
+	decls, err := store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment declarations: %w", err)
+	}
+	diJSON, err := store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declaration items: %w", err)
+	}
+	tokensJSON, err := store.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving tokens: %w", err)
+	}
+	size := &EnrollmentSize{
+		Declarations:         len(decls),
+		DeclarationItemsSize: len(diJSON),
+		TokensSize:           len(tokensJSON),
+	}
+	if warning := limits.countWarning(size.Declarations); warning != "" {
+		size.Warnings = append(size.Warnings, warning)
+	}
+	if limits.DeclarationItemsSize > 0 && size.DeclarationItemsSize > limits.DeclarationItemsSize {
+		size.Warnings = append(size.Warnings, fmt.Sprintf("declaration-items size %d bytes exceeds threshold %d bytes", size.DeclarationItemsSize, limits.DeclarationItemsSize))
+	}
+	return size, nil
+}
+
+// GetEnrollmentStatsHandler returns a handler that reports the sizes of
+// the DDM documents of an enrollment ID and any exceeded size limits.
+func GetEnrollmentStatsHandler(store EnrollmentSizeStorage, limits SizeLimits, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		enrollmentID := getResourceID(r)
+		if err := storage.ValidateIdentifier("enrollment ID", enrollmentID); err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("resource", enrollmentID)
+		size, err := enrollmentSize(r.Context(), store, limits, enrollmentID)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "computing enrollment size", logger)
+			return
+		}
+		logger.Debug(logkeys.Message, "retrieved enrollment stats", "declarations", size.Declarations)
+		if err = jsonResponse(w, 0, size); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// sizeWarningWriter adds warning headers before the header is written.
+type sizeWarningWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	warn        func(status int)
+}
+
+func (w *sizeWarningWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.warn(status)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sizeWarningWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// SizeWarningMiddleware checks the resolved declaration count of the
+// enrollment ID resource after next successfully changes it. If the
+// count exceeds limits then an HTTP "Warning" header is added to the
+// response and the warning is logged.
+func SizeWarningMiddleware(next http.Handler, store storage.EnrollmentDeclarationsRetriever, limits SizeLimits, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		enrollmentID := getResourceID(r)
+		if limits.Declarations < 1 || enrollmentID == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := ctxlog.Logger(r.Context(), logger).With("resource", enrollmentID)
+		next.ServeHTTP(&sizeWarningWriter{
+			ResponseWriter: w,
+			warn: func(status int) {
+				if status != http.StatusNoContent {
+					// no change made
+					return
+				}
+				decls, err := store.RetrieveEnrollmentDeclarations(r.Context(), enrollmentID)
+				if err != nil {
+					logger.Info(logkeys.Message, "retrieving enrollment declarations", logkeys.Error, err)
+					return
+				}
+				if warning := limits.countWarning(len(decls)); warning != "" {
+					logger.Info(logkeys.Message, "size warning", "warning", warning)
+					w.Header().Add("Warning", fmt.Sprintf("199 kmfddm %q", warning))
+				}
+			},
+		}, r)
+	}
+}
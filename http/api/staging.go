@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// GetEnrollmentStagedSetsHandler returns a handler that retrieves the staged sets of an enrollment.
+func GetEnrollmentStagedSetsHandler(store storage.EnrollmentStagedSetStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveEnrollmentStagedSets(ctx, resource)
+		},
+	)
+}
+
+// PutEnrollmentStagedSetHandler returns a handler that stages a set for an enrollment.
+// Staged sets are not served so the enrollment is not notified.
+func PutEnrollmentStagedSetHandler(store storage.EnrollmentStagedSetStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, _ bool) (bool, string, error) {
+			setName := u.Query().Get("set")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
+			}
+			changed, err := store.StoreEnrollmentStagedSet(ctx, resource, setName)
+			return changed, "store enrollment staged set", err
+		},
+	)
+}
+
+// DeleteEnrollmentStagedSetHandler returns a handler that removes a staged set of an enrollment.
+func DeleteEnrollmentStagedSetHandler(store storage.EnrollmentStagedSetStorage, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL, _ bool) (bool, string, error) {
+			setName := u.Query().Get("set")
+			if err := storage.ValidateIdentifier("set name", setName); err != nil {
+				return false, "", err
+			}
+			changed, err := store.RemoveEnrollmentStagedSet(ctx, resource, setName)
+			return changed, "remove enrollment staged set", err
+		},
+	)
+}
+
+// PostEnrollmentStagedSetsSwapHandler returns a handler that atomically
+// exchanges the sets and the staged sets of an enrollment. The
+// enrollment is then served the DDM of its previously staged sets.
+// Swapping again rolls back to the previous sets.
+func PostEnrollmentStagedSetsSwapHandler(store storage.EnrollmentStagedSetStorage, notifier Notifier, logger log.Logger) http.HandlerFunc {
+	return simpleChangeResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL, notify bool) (bool, string, error) {
+			changed, err := store.SwapEnrollmentStagedSets(ctx, resource)
+			if err == nil && changed && notify {
+				err = notifier.Changed(ctx, nil, nil, []string{resource})
+				if err != nil {
+					err = fmt.Errorf("notify enrollment: %w", err)
+				}
+			}
+			return changed, "swap enrollment staged sets", err
+		},
+	)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Stats contains counters keyed by counter name.
+type Stats struct {
+	Global       map[string]int64            `json:"global"`
+	Declarations map[string]map[string]int64 `json:"declarations,omitempty"`
+	Sets         map[string]map[string]int64 `json:"sets,omitempty"`
+}
+
+// GetStatsHandler returns a handler that retrieves counters.
+// The global counters are always returned. The counters of declarations
+// and sets are returned if given in the "declaration" and "set" query
+// parameters, respectively.
+func GetStatsHandler(store storage.CounterRetriever, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		stats := new(Stats)
+		var err error
+		if stats.Global, err = store.RetrieveCounters(r.Context(), storage.CounterScopeGlobal, ""); err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving global counters", logger)
+			return
+		}
+		for _, s := range []struct {
+			param string
+			scope string
+			dest  *map[string]map[string]int64
+		}{
+			{"declaration", storage.CounterScopeDeclaration, &stats.Declarations},
+			{"set", storage.CounterScopeSet, &stats.Sets},
+		} {
+			for _, id := range r.URL.Query()[s.param] {
+				counters, err := store.RetrieveCounters(r.Context(), s.scope, id)
+				if err != nil {
+					jsonErrorAndLog(w, 0, err, "retrieving "+s.param+" counters", logger)
+					return
+				}
+				if *s.dest == nil {
+					*s.dest = make(map[string]map[string]int64)
+				}
+				(*s.dest)[id] = counters
+			}
+		}
+		logger.Debug(logkeys.Message, "retrieved stats")
+		if err = jsonResponse(w, 0, stats); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
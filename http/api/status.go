@@ -3,10 +3,12 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jessepeterson/kmfddm/log"
 	"github.com/jessepeterson/kmfddm/log/ctxlog"
@@ -14,43 +16,290 @@ import (
 )
 
 // GetDeclarationStatusHandler returns a handler that retrives that last declaration status for an enrollment ID.
+// The "as_of" query parameter limits the statuses to those reported at
+// or before an RFC 3339 time (see storage.DeclarationStatusAsOf).
 func GetDeclarationStatusHandler(store storage.StatusDeclarationsRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
+			asOf, err := parseAsOf(u.Query())
+			if err != nil {
+				return nil, err
+			}
+			statuses, err := store.RetrieveDeclarationStatus(ctx, strings.Split(resource, ","))
+			if err != nil || asOf.IsZero() {
+				return statuses, err
+			}
+			return storage.DeclarationStatusAsOf(statuses, asOf), nil
+		},
+	)
+}
+
+// GetPendingRemovalsHandler returns a handler that retrieves the
+// declarations no longer assigned to enrollment IDs but which the
+// enrollments last reported as active.
+func GetPendingRemovalsHandler(store storage.PendingRemovalsRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrievePendingRemovals(ctx, strings.Split(resource, ","))
+		},
+	)
+}
+
+// GetStatusQuarantineHandler returns a handler that retrieves the
+// quarantined status report fragments of enrollment IDs. Fragments of
+// status reports are quarantined when they fail to parse or store.
+func GetStatusQuarantineHandler(store storage.StatusQuarantineRetriever, logger log.Logger) http.HandlerFunc {
 	return simpleJSONResourceHandler(
 		logger,
 		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
-			return store.RetrieveDeclarationStatus(ctx, strings.Split(resource, ","))
+			return store.RetrieveStatusQuarantine(ctx, strings.Split(resource, ","))
 		},
 	)
 }
 
+// parseTimeRange parses the optional RFC 3339 "since" and "until" query parameters.
+func parseTimeRange(q url.Values) (since, until time.Time, err error) {
+	if s := q.Get("since"); s != "" {
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			return since, until, fmt.Errorf("invalid since: %w", err)
+		}
+	}
+	if s := q.Get("until"); s != "" {
+		if until, err = time.Parse(time.RFC3339, s); err != nil {
+			return since, until, fmt.Errorf("invalid until: %w", err)
+		}
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		err = errors.New("since after until")
+	}
+	return
+}
+
+// parseAsOf parses the optional RFC 3339 "as_of" query parameter.
+func parseAsOf(q url.Values) (asOf time.Time, err error) {
+	if s := q.Get("as_of"); s != "" {
+		if asOf, err = time.Parse(time.RFC3339, s); err != nil {
+			err = fmt.Errorf("invalid as_of: %w", err)
+		}
+	}
+	return
+}
+
+// StatusValuesStorage retrieves status values and their recorded history.
+type StatusValuesStorage interface {
+	storage.StatusValuesRetriever
+	storage.StatusValueHistoryRetriever
+}
+
+// retrieveStatusValues retrieves the status values of enrollmentIDs
+// with prefix. If asOf is not zero the values are reconstructed as
+// they were at asOf from the recorded status value history.
+func retrieveStatusValues(ctx context.Context, store StatusValuesStorage, enrollmentIDs []string, prefix string, asOf time.Time) (map[string][]storage.StatusValue, error) {
+	values, err := store.RetrieveStatusValues(ctx, enrollmentIDs, prefix)
+	if err != nil || asOf.IsZero() {
+		return values, err
+	}
+	histories := make(map[string]map[string][]storage.StatusValue)
+	for id, enrollmentValues := range values {
+		for _, v := range enrollmentValues {
+			if !v.Timestamp.After(asOf) {
+				continue
+			}
+			if _, ok := histories[v.Path]; ok {
+				continue
+			}
+			if histories[v.Path], err = store.RetrieveStatusValueHistory(ctx, enrollmentIDs, v.Path); err != nil {
+				return nil, fmt.Errorf("retrieving status value history: %w", err)
+			}
+		}
+		history := make(map[string][]storage.StatusValue)
+		for path, pathHistory := range histories {
+			history[path] = pathHistory[id]
+		}
+		if enrollmentValues = storage.StatusValuesAsOf(enrollmentValues, history, asOf); len(enrollmentValues) > 0 {
+			values[id] = enrollmentValues
+		} else {
+			delete(values, id)
+		}
+	}
+	return values, nil
+}
+
 // GetStatusErrorsHandler returns a handler that retrieves the collected errors for an enrollment.
+// The "since" and "until" query parameters limit the errors to a time range.
 func GetStatusErrorsHandler(store storage.StatusErrorsRetriever, logger log.Logger) http.HandlerFunc {
 	return simpleJSONResourceHandler(
 		logger,
-		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
 			if store == nil {
 				return nil, errors.New("nil storage")
 			}
-			return store.RetrieveStatusErrors(ctx, strings.Split(resource, ","), 0, 10)
+			since, until, err := parseTimeRange(u.Query())
+			if err != nil {
+				return nil, err
+			}
+			return store.RetrieveStatusErrors(ctx, strings.Split(resource, ","), since, until, 0, 10)
 		},
 	)
 }
 
 // GetStatusValuesHandler returns a handler that retrieves the collected values for an enrollment.
-func GetStatusValuesHandler(store storage.StatusValuesRetriever, logger log.Logger) http.HandlerFunc {
+// The "as_of" query parameter reconstructs the values as they were at
+// an RFC 3339 time (see storage.StatusValuesAsOf).
+func GetStatusValuesHandler(store StatusValuesStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
+			if store == nil {
+				return nil, errors.New("nil storage")
+			}
+			q := u.Query()
+			var filter *storage.StatusValueFilter
+			if op := q.Get("op"); op != "" {
+				filter = &storage.StatusValueFilter{Op: op, Value: q.Get("value")}
+			}
+			if err := validateStatusValueQuery(filter, q.Get("sort")); err != nil {
+				return nil, err
+			}
+			asOf, err := parseAsOf(q)
+			if err != nil {
+				return nil, err
+			}
+			values, err := retrieveStatusValues(ctx, store, strings.Split(resource, ","), q.Get("prefix"), asOf)
+			if err != nil {
+				return nil, err
+			}
+			return filterAndSortStatusValues(values, filter, q.Get("sort")), nil
+		},
+	)
+}
+
+// GetDeviceStatusValuesHandler returns a handler that retrieves the
+// collected values of enrollments merged into one view per device.
+// The resource is a comma-separated list of enrollment IDs: any user
+// channel enrollment IDs (see storage.DeviceEnrollmentID) are merged
+// with their device channel enrollment ID which need not be listed.
+// The "prefix" and "as_of" query parameters work as with status values.
+func GetDeviceStatusValuesHandler(store StatusValuesStorage, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
+			asOf, err := parseAsOf(u.Query())
+			if err != nil {
+				return nil, err
+			}
+			var ids []string
+			seen := make(map[string]bool)
+			for _, id := range strings.Split(resource, ",") {
+				for _, id := range []string{storage.DeviceEnrollmentID(id), id} {
+					if !seen[id] {
+						seen[id] = true
+						ids = append(ids, id)
+					}
+				}
+			}
+			values, err := retrieveStatusValues(ctx, store, ids, u.Query().Get("prefix"), asOf)
+			if err != nil {
+				return nil, err
+			}
+			return storage.MergeDeviceStatusValues(values), nil
+		},
+	)
+}
+
+// GetStatusValueHistoryHandler returns a handler that retrieves the recorded history of a status path for an enrollment.
+func GetStatusValueHistoryHandler(store storage.StatusValueHistoryRetriever, logger log.Logger) http.HandlerFunc {
 	return simpleJSONResourceHandler(
 		logger,
 		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
 			if store == nil {
 				return nil, errors.New("nil storage")
 			}
-			pathPrefix := u.Query().Get("prefix")
-			return store.RetrieveStatusValues(ctx, strings.Split(resource, ","), pathPrefix)
+			path := u.Query().Get("path")
+			if path == "" {
+				return nil, errors.New("missing status path")
+			}
+			return store.RetrieveStatusValueHistory(ctx, strings.Split(resource, ","), path)
 		},
 	)
 }
 
-// GetStatusReportHandler returns a handler that retrieves a status report for en enrollment.
+// validateStatusValueQuery checks the status value filter and sort order.
+func validateStatusValueQuery(filter *storage.StatusValueFilter, sortOrder string) error {
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return err
+		}
+	}
+	switch sortOrder {
+	case "", "value", "-value":
+		return nil
+	}
+	return fmt.Errorf("invalid status value sort: %q", sortOrder)
+}
+
+// filterAndSortStatusValues filters and sorts the status values of each
+// enrollment. Enrollments without any matching values are removed.
+func filterAndSortStatusValues(values map[string][]storage.StatusValue, filter *storage.StatusValueFilter, sortOrder string) map[string][]storage.StatusValue {
+	for id, v := range values {
+		if filter != nil {
+			if v = storage.FilterStatusValues(v, filter); len(v) < 1 {
+				delete(values, id)
+				continue
+			}
+		}
+		if sortOrder != "" {
+			storage.SortStatusValues(v, sortOrder == "-value")
+		}
+		values[id] = v
+	}
+	return values
+}
+
+// GetSetStatusSummaryHandler returns a handler that summarizes the reported status of the declarations in a set.
+func GetSetStatusSummaryHandler(store storage.SetStatusSummaryRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, _ *url.URL) (interface{}, error) {
+			return store.RetrieveSetStatusSummary(ctx, resource)
+		},
+	)
+}
+
+// GetDeclarationStatusEnrollmentsHandler returns a handler that
+// retrieves the enrollments by their last reported status of a
+// declaration. The "valid" (e.g. "invalid") and "active" (true or
+// false) query parameters filter the enrollments by status.
+// The declaration identifier is the resource ID.
+func GetDeclarationStatusEnrollmentsHandler(store storage.DeclarationStatusEnrollmentsRetriever, logger log.Logger) http.HandlerFunc {
+	return simpleJSONResourceHandler(
+		logger,
+		func(ctx context.Context, resource string, u *url.URL) (interface{}, error) {
+			q := &storage.DeclarationStatusQuery{Identifier: resource, Valid: u.Query().Get("valid")}
+			if active := u.Query().Get("active"); active != "" {
+				b, err := strconv.ParseBool(active)
+				if err != nil {
+					return nil, fmt.Errorf("invalid active: %w", err)
+				}
+				q.Active = &b
+			}
+			ids, err := store.RetrieveDeclarationStatusEnrollments(ctx, q)
+			if ids == nil {
+				// encode as an empty JSON array
+				ids = []string{}
+			}
+			return ids, err
+		},
+	)
+}
+
+// GetStatusReportHandler returns a handler that retrieves the raw JSON
+// of a status report exactly as the enrollment sent it. The "index"
+// query parameter selects a report by its reverse-chronological index
+// and the "status_id" query parameter by its status ID. Without either
+// the last status report is retrieved. The enrollment ID is the resource ID.
 func GetStatusReportHandler(store storage.StatusReportRetriever, logger log.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		logger := ctxlog.Logger(r.Context(), logger)
@@ -67,14 +316,17 @@ func GetStatusReportHandler(store storage.StatusReportRetriever, logger log.Logg
 		if statusID := r.URL.Query().Get("status_id"); statusID != "" {
 			q.StatusID = &statusID
 		}
+		if q.Index == nil && q.StatusID == nil {
+			// default to the last status report
+			index := 0
+			q.Index = &index
+		}
 		report, err := store.RetrieveStatusReport(r.Context(), q)
-		statusCode := 0
 		if err == nil && report == nil {
-			err = errors.New("status report not found")
-			statusCode = 404
+			err = storage.ErrStatusReportNotFound
 		}
 		if err != nil {
-			jsonErrorAndLog(w, statusCode, err, "retrieving status report", logger)
+			jsonErrorAndLog(w, 0, err, "retrieving status report", logger)
 			return
 		}
 		w.Header().Set("Content-type", jsonContentType)
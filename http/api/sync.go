@@ -0,0 +1,40 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/declsync"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// Syncer synchronizes declarations and sets to storage.
+type Syncer interface {
+	Sync(ctx context.Context, dryRun bool) (*declsync.Report, error)
+}
+
+// SyncHandler returns a handler that synchronizes declarations and sets
+// and responds with the report. No changes are made if the "dryrun"
+// query parameter is set.
+func SyncHandler(syncer Syncer, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		dryRun := boolish(r.URL.Query().Get("dryrun"))
+		report, err := syncer.Sync(r.Context(), dryRun)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "synchronizing", logger)
+			return
+		}
+		logger.Debug(
+			logkeys.Message, "synchronized",
+			"dry_run", dryRun,
+			"commit", report.Commit,
+			"notified", report.Notified,
+		)
+		if err = jsonResponse(w, 0, report); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
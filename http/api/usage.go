@@ -0,0 +1,108 @@
+package api
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// DefaultUsageDays is the number of days of usage exported if no days are requested.
+const DefaultUsageDays = 30
+
+// usageDays returns the requested days of usage. The "to" query
+// parameter defaults to today (in UTC) and the "from" query parameter
+// defaults to DefaultUsageDays ending at "to".
+func usageDays(r *http.Request) (string, string, error) {
+	q := r.URL.Query()
+	to := time.Now().UTC()
+	if s := q.Get("to"); s != "" {
+		var err error
+		if to, err = time.Parse(storage.UsageDayFormat, s); err != nil {
+			return "", "", fmt.Errorf("invalid to day: %w", err)
+		}
+	}
+	from := to.AddDate(0, 0, 1-DefaultUsageDays)
+	if s := q.Get("from"); s != "" {
+		var err error
+		if from, err = time.Parse(storage.UsageDayFormat, s); err != nil {
+			return "", "", fmt.Errorf("invalid from day: %w", err)
+		}
+	}
+	fromDay, toDay := from.Format(storage.UsageDayFormat), to.Format(storage.UsageDayFormat)
+	if fromDay > toDay {
+		return "", "", errors.New("from day after to day")
+	}
+	return fromDay, toDay, nil
+}
+
+// writeUsageCSV writes records as CSV with a header row.
+func writeUsageCSV(w http.ResponseWriter, records []storage.UsageRecord) error {
+	w.Header().Set("Content-Type", "text/csv")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"day", "set", "syncs", "status_reports", "declarations"})
+	for _, u := range records {
+		cw.Write([]string{
+			u.Day,
+			u.Set,
+			strconv.FormatInt(u.Syncs, 10),
+			strconv.FormatInt(u.StatusReports, 10),
+			strconv.FormatInt(u.Declarations, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GetUsageHandler returns a handler that exports the daily usage of sets.
+// The "from" and "to" query parameters are the inclusive days (in
+// YYYY-MM-DD format) to export. The "set" query parameter (which may
+// be repeated) limits the export to those sets. The usage is exported
+// as CSV if the "format" query parameter is "csv" and JSON otherwise.
+func GetUsageHandler(store storage.UsageStorage, logger log.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger := ctxlog.Logger(r.Context(), logger)
+		from, to, err := usageDays(r)
+		if err != nil {
+			jsonErrorAndLog(w, http.StatusBadRequest, err, "validating input", logger)
+			return
+		}
+		logger = logger.With("from", from, "to", to)
+		records, err := store.RetrieveUsage(r.Context(), from, to)
+		if err != nil {
+			jsonErrorAndLog(w, 0, err, "retrieving usage", logger)
+			return
+		}
+		if setNames := r.URL.Query()["set"]; len(setNames) > 0 {
+			filtered := records[:0]
+			for _, u := range records {
+				for _, setName := range setNames {
+					if u.Set == setName {
+						filtered = append(filtered, u)
+						break
+					}
+				}
+			}
+			records = filtered
+		}
+		if records == nil {
+			// encode as an empty JSON array
+			records = []storage.UsageRecord{}
+		}
+		if r.URL.Query().Get("format") == "csv" {
+			err = writeUsageCSV(w, records)
+		} else {
+			err = jsonResponse(w, 0, records)
+		}
+		if err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ErrSamePrincipal is returned when a principal approves its own change.
+var ErrSamePrincipal = errors.New("change must be approved by a different principal")
+
+type ctxKeyApprovedChange struct{}
+
+// approvedChange returns the approved change the request context is replaying.
+func approvedChange(ctx context.Context) *storage.PendingChange {
+	c, _ := ctx.Value(ctxKeyApprovedChange{}).(*storage.PendingChange)
+	return c
+}
+
+// ApprovalMiddleware holds the mutating requests of principals that
+// require approval (see Principal) as pending changes instead of
+// passing them to next. The request is answered with a 202 Accepted
+// status and the pending change. Requests for which exempt returns true
+// are always passed to next; it is intended for requests that use
+// mutating methods but do not change anything.
+func ApprovalMiddleware(next http.Handler, store storage.PendingChangeStorage, exempt func(*http.Request) bool, logger log.Logger) http.HandlerFunc {
+	if store == nil || logger == nil {
+		panic("nil store or logger")
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		principal, ok := GetPrincipal(r.Context())
+		if !ok || !principal.ApprovalRequired || approvedChange(r.Context()) != nil || (exempt != nil && exempt(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := ctxlog.Logger(r.Context(), logger)
+		body, err := ReadAllAndReplaceBody(r)
+		if err != nil {
+			logger.Info(logkeys.Message, "reading body", logkeys.Error, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		change := &storage.PendingChange{
+			ID:          uuid.NewString(),
+			Principal:   principal.Name,
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			RawQuery:    r.URL.RawQuery,
+			ContentType: r.Header.Get("Content-Type"),
+			Body:        body,
+			IfMatch:     r.Header.Get("If-Match"),
+			Timestamp:   time.Now(),
+		}
+		logger = logger.With("pending_change", change.ID)
+		if err = store.StorePendingChange(r.Context(), change); err != nil {
+			logger.Info(logkeys.Message, "storing pending change", logkeys.Error, err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		logger.Debug(logkeys.Message, "stored pending change")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		if err = json.NewEncoder(w).Encode(NewPendingChangeJSON(change)); err != nil {
+			logger.Info(logkeys.Message, "encoding response body", logkeys.Error, err)
+		}
+	}
+}
+
+// ReplayPendingChange applies an approved change by replaying its
+// request to next, which should be the router of the API. The
+// If-Match header of the change is replayed so that preconditions are
+// checked against the current state. The response of the replayed
+// request is written to w. The replayed
+// request is not authenticated nor held for approval again. It is
+// made as the principal of the change rather than the approving
+// principal so that it is checked (e.g. by policies and quotas) as if
+// it had not been held for approval.
+func ReplayPendingChange(w http.ResponseWriter, r *http.Request, next http.Handler, change *storage.PendingChange) error {
+	ctx := context.WithValue(r.Context(), ctxKeyPrincipal{}, Principal{Name: change.Principal})
+	ctx = withPrincipalName(ctx, change.Principal)
+	ctx = context.WithValue(ctx, ctxKeyApprovedChange{}, change)
+	u := change.Path
+	if change.RawQuery != "" {
+		u += "?" + change.RawQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, change.Method, u, bytes.NewReader(change.Body))
+	if err != nil {
+		return err
+	}
+	if change.ContentType != "" {
+		req.Header.Set("Content-Type", change.ContentType)
+	}
+	if change.IfMatch != "" {
+		req.Header.Set("If-Match", change.IfMatch)
+	}
+	next.ServeHTTP(w, req)
+	return nil
+}
+
+// PendingChangeJSON is the API representation of a pending change.
+// The body is included as a string (if it is valid UTF-8) so that it
+// can be reviewed.
+type PendingChangeJSON struct {
+	ID          string    `json:"id"`
+	Principal   string    `json:"principal"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	RawQuery    string    `json:"raw_query,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	IfMatch     string    `json:"if_match,omitempty"`
+	Body        string    `json:"body,omitempty"`
+	BodySize    int       `json:"body_size"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// NewPendingChangeJSON creates the API representation of c.
+func NewPendingChangeJSON(c *storage.PendingChange) *PendingChangeJSON {
+	j := &PendingChangeJSON{
+		ID:          c.ID,
+		Principal:   c.Principal,
+		Method:      c.Method,
+		Path:        c.Path,
+		RawQuery:    c.RawQuery,
+		ContentType: c.ContentType,
+		IfMatch:     c.IfMatch,
+		BodySize:    len(c.Body),
+		Timestamp:   c.Timestamp,
+	}
+	if utf8.Valid(c.Body) {
+		j.Body = string(c.Body)
+	}
+	return j
+}
@@ -0,0 +1,67 @@
+package http
+
+import "net/http"
+
+// Middleware wraps an HTTP handler with additional behavior.
+type Middleware func(http.Handler) http.Handler
+
+// Chain is an ordered list of middleware. The first middleware in the
+// chain is the outermost and sees each request first.
+// Chains are composable: Append and Prepend return new chains so that
+// a chain shared by route groups is never modified.
+type Chain []Middleware
+
+// NewChain creates a new chain of mw.
+func NewChain(mw ...Middleware) Chain {
+	return Chain(nil).Append(mw...)
+}
+
+// Append returns a new chain with mw after (inside of) the middleware of c.
+// Nil middleware are skipped.
+func (c Chain) Append(mw ...Middleware) Chain {
+	ret := make(Chain, 0, len(c)+len(mw))
+	ret = append(ret, c...)
+	for _, m := range mw {
+		if m != nil {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// Prepend returns a new chain with mw before (outside of) the middleware of c.
+func (c Chain) Prepend(mw ...Middleware) Chain {
+	return NewChain(mw...).Append(c...)
+}
+
+// Then wraps h with the middleware of c.
+func (c Chain) Then(h http.Handler) http.Handler {
+	for i := len(c) - 1; i >= 0; i-- {
+		h = c[i](h)
+	}
+	return h
+}
+
+// Use registers the middleware of c, in order, with mux.
+// Routes subsequently registered with mux are wrapped by c.
+func (c Chain) Use(mux interface {
+	Use(...func(http.Handler) http.Handler)
+}) {
+	for _, m := range c {
+		mux.Use(m)
+	}
+}
+
+// RouteGroupChains are the middleware chains of the route groups of a
+// KMFDDM server. Embedders may insert their own middleware (e.g. rate
+// limiting, metrics, or compression) into either group.
+type RouteGroupChains struct {
+	// Protocol wraps the DDM protocol routes that enrollments use
+	// (e.g. the tokens, declaration-items, declaration, and status
+	// routes). Embedders may authorize enrollments' requests here
+	// (see the AuthorizeMiddleware of the http/ddm package).
+	Protocol Chain
+
+	// API wraps the API routes. Authentication is first in the chain.
+	API Chain
+}
@@ -0,0 +1,96 @@
+package ddm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// DDM protocol endpoints. The endpoint of a request is the first
+// element of its URL path.
+const (
+	EndpointTokens           = "tokens"
+	EndpointDeclarationItems = "declaration-items"
+	EndpointDeclaration      = "declaration"
+	EndpointStatus           = "status"
+)
+
+// Authorizer authorizes DDM protocol requests.
+type Authorizer interface {
+	// AuthorizeDDM authorizes the request of enrollmentID for endpoint.
+	// A nil error allows the request. A DenyError denies the request
+	// with its HTTP status. Any other error fails the request with an
+	// Internal Server Error. The enrollment ID is empty for requests
+	// made without one (e.g. for profile data).
+	AuthorizeDDM(ctx context.Context, enrollmentID, endpoint string) error
+}
+
+// AuthorizerFunc is an Authorizer function.
+type AuthorizerFunc func(ctx context.Context, enrollmentID, endpoint string) error
+
+// AuthorizeDDM calls f(ctx, enrollmentID, endpoint).
+func (f AuthorizerFunc) AuthorizeDDM(ctx context.Context, enrollmentID, endpoint string) error {
+	return f(ctx, enrollmentID, endpoint)
+}
+
+// DenyError denies a DDM protocol request.
+type DenyError struct {
+	// StatusCode is the HTTP status of the denied request.
+	// It defaults to 403 Forbidden.
+	StatusCode int
+
+	// Reason is logged but is not sent to the enrollment.
+	Reason string
+}
+
+func (e *DenyError) Error() string {
+	return fmt.Sprintf("DDM request denied: %s", e.Reason)
+}
+
+// status returns the HTTP status of the denied request.
+func (e *DenyError) status() int {
+	if e.StatusCode < 400 {
+		return http.StatusForbidden
+	}
+	return e.StatusCode
+}
+
+// Deny returns a DenyError denying the request with 403 Forbidden for reason.
+func Deny(reason string) error {
+	return &DenyError{Reason: reason}
+}
+
+// AuthorizeMiddleware authorizes DDM protocol requests with authz before
+// calling next. Denied requests are answered with the HTTP status of
+// the DenyError. For MDM servers that relay the HTTP status of DDM
+// responses this is the status seen by the enrollment.
+func AuthorizeMiddleware(next http.Handler, authz Authorizer, logger log.Logger) http.HandlerFunc {
+	if authz == nil || logger == nil {
+		panic("nil authorizer or logger")
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		enrollmentID := r.Header.Get(EnrollmentIDHeader)
+		endpoint := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)[0]
+		err := authz.AuthorizeDDM(r.Context(), enrollmentID, endpoint)
+		if err == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := ctxlog.Logger(r.Context(), logger).With(
+			logkeys.EnrollmentID, enrollmentID,
+			"endpoint", endpoint,
+		)
+		var deny *DenyError
+		if errors.As(err, &deny) {
+			ErrorAndLog(w, deny.status(), logger, "denied DDM request", err)
+			return
+		}
+		ErrorAndLog(w, http.StatusInternalServerError, logger, "authorizing DDM request", err)
+	}
+}
@@ -37,6 +37,9 @@ func contextEnrollmentID(r *http.Request, logger log.Logger) (context.Context, l
 	if id == "" {
 		return r.Context(), logger, id, ErrEmptyEnrollmentID
 	}
+	if err := storage.ValidateIdentifier("enrollment ID", id); err != nil {
+		return r.Context(), logger, id, err
+	}
 	// add it to our context
 	ctx := context.WithValue(r.Context(), enrollmentIDContextKey{}, id)
 	// setup a new context logger KV func to
@@ -45,10 +48,22 @@ func contextEnrollmentID(r *http.Request, logger log.Logger) (context.Context, l
 	return ctx, ctxlog.Logger(ctx, logger), id, nil
 }
 
+// count increments counters using counter (if not nil).
+// Errors are logged but otherwise ignored.
+func count(ctx context.Context, counter storage.CounterIncrementer, logger log.Logger, incs ...storage.CounterIncrement) {
+	if counter == nil {
+		return
+	}
+	if err := counter.IncrementCounters(ctx, incs); err != nil {
+		logger.Info(logkeys.Message, "incrementing counters", logkeys.Error, err)
+	}
+}
+
 // DeclarationHandler creates a handler that fetches and returns a single declaration.
 // The request URL path is assumed to contain the declaration type and identifier.
 // This probably requires the handler to have the path prefix stripped before use.
-func DeclarationHandler(store storage.DeclarationRetriever, hLogger log.Logger) http.HandlerFunc {
+// Served declarations are counted using counter (if not nil).
+func DeclarationHandler(store storage.DeclarationRetriever, counter storage.CounterIncrementer, hLogger log.Logger) http.HandlerFunc {
 	if store == nil || hLogger == nil {
 		panic("nil store or logger")
 	}
@@ -69,18 +84,23 @@ func DeclarationHandler(store storage.DeclarationRetriever, hLogger log.Logger)
 		)
 		rawDecl, err := store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
 		if err != nil {
-			ErrorAndLog(w, http.StatusInternalServerError, logger, "retrieving declaration", err)
+			ErrorAndLog(w, httpddm.StorageErrorStatus(err), logger, "retrieving declaration", err)
 			return
 		}
 		logger.Debug(logkeys.Message, "retrieved declaration")
 		w.Header().Set("Content-Type", jsonContentType)
 		w.Write(rawDecl)
+		count(ctx, counter, logger,
+			storage.CounterIncrement{Scope: storage.CounterScopeGlobal, Name: storage.CounterDeclarationsServed, Delta: 1},
+			storage.CounterIncrement{Scope: storage.CounterScopeDeclaration, ID: declarationID, Name: storage.CounterDeclarationsServed, Delta: 1},
+		)
 	}
 }
 
 // TokensOrDeclarationItemsHandler creates a handler that fetchs and returns either
 // the tokens or declaration items JSON for an erollment ID depending on tokens.
-func TokensOrDeclarationItemsHandler(store storage.TokensDeclarationItemsRetriever, tokens bool, hLogger log.Logger) http.HandlerFunc {
+// Served documents are counted using counter (if not nil).
+func TokensOrDeclarationItemsHandler(store storage.TokensDeclarationItemsRetriever, tokens bool, counter storage.CounterIncrementer, hLogger log.Logger) http.HandlerFunc {
 	if store == nil || hLogger == nil {
 		panic("nil store or logger")
 	}
@@ -90,27 +110,31 @@ func TokensOrDeclarationItemsHandler(store storage.TokensDeclarationItemsRetriev
 			ErrorAndLog(w, http.StatusBadRequest, logger, "getting enrollment id", err)
 			return
 		}
-		var op string
+		var op, counterName string
 		var rawJSON []byte
 		if tokens {
 			op = "tokens"
+			counterName = storage.CounterTokensServed
 			rawJSON, err = store.RetrieveTokensJSON(ctx, enrollmentID)
 		} else {
 			op = "declaration items"
+			counterName = storage.CounterDeclarationItemsServed
 			rawJSON, err = store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
 		}
 		if err != nil {
-			ErrorAndLog(w, http.StatusInternalServerError, logger, "retrieving "+op, err)
+			ErrorAndLog(w, httpddm.StorageErrorStatus(err), logger, "retrieving "+op, err)
 			return
 		}
 		logger.Debug("msg", "retrieved "+op)
 		w.Header().Set("Content-Type", jsonContentType)
 		w.Write(rawJSON)
+		count(ctx, counter, logger, storage.CounterIncrement{Scope: storage.CounterScopeGlobal, Name: counterName, Delta: 1})
 	}
 }
 
 // StatusReportHandler creates a handler that stores the DDM status report.
-func StatusReportHandler(store storage.StatusStorer, hLogger log.Logger) http.HandlerFunc {
+// Received status reports are counted using counter (if not nil).
+func StatusReportHandler(store storage.StatusStorer, counter storage.CounterIncrementer, hLogger log.Logger) http.HandlerFunc {
 	if store == nil || hLogger == nil {
 		panic("nil store or logger")
 	}
@@ -120,7 +144,7 @@ func StatusReportHandler(store storage.StatusStorer, hLogger log.Logger) http.Ha
 			ErrorAndLog(w, http.StatusBadRequest, logger, "getting enrollment id", err)
 			return
 		}
-		bodyBytes, err := io.ReadAll(r.Body)
+		bodyBytes, err := io.ReadAll(io.LimitReader(r.Body, ddm.MaxStatusReportSize+1))
 		if err != nil {
 			ErrorAndLog(w, http.StatusInternalServerError, logger, "reading body", err)
 			return
@@ -142,9 +166,29 @@ func StatusReportHandler(store storage.StatusStorer, hLogger log.Logger) http.Ha
 		}
 		err = store.StoreDeclarationStatus(ctx, enrollmentID, status)
 		if err != nil {
-			ErrorAndLog(w, http.StatusInternalServerError, logger, "storing declaration status", err)
+			ErrorAndLog(w, httpddm.StorageErrorStatus(err), logger, "storing declaration status", err)
 			return
 		}
 		logger.Debug(logkeys.Message, "stored declaration status")
+		for _, f := range status.Quarantined {
+			// the status report is still accepted
+			logger.Info(logkeys.Message, "quarantined status report fragment", "path", f.Path, logkeys.Error, f.Error)
+		}
+		incs := []storage.CounterIncrement{{Scope: storage.CounterScopeGlobal, Name: storage.CounterStatusReports, Delta: 1}}
+		if status.DroppedValues > 0 || status.DroppedErrors > 0 {
+			logger.Debug(
+				logkeys.Message, "dropped status records",
+				"dropped_values", status.DroppedValues,
+				"dropped_errors", status.DroppedErrors,
+			)
+			incs = append(incs,
+				storage.CounterIncrement{Scope: storage.CounterScopeGlobal, Name: storage.CounterStatusValuesDropped, Delta: int64(status.DroppedValues)},
+				storage.CounterIncrement{Scope: storage.CounterScopeGlobal, Name: storage.CounterStatusErrorsDropped, Delta: int64(status.DroppedErrors)},
+			)
+		}
+		for _, d := range status.Declarations {
+			incs = append(incs, storage.CounterIncrement{Scope: storage.CounterScopeDeclaration, ID: d.Identifier, Name: storage.CounterStatusReports, Delta: 1})
+		}
+		count(ctx, counter, logger, incs...)
 	}
 }
@@ -0,0 +1,98 @@
+package ddm
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaintenanceRetryAfter is the default duration after which
+// enrollments are asked to retry requests made during maintenance.
+const DefaultMaintenanceRetryAfter = 5 * time.Minute
+
+// MaintenanceStatus is the maintenance mode of the DDM protocol endpoints.
+type MaintenanceStatus struct {
+	Enabled bool `json:"enabled"`
+
+	// RetryAfter is the number of seconds after which enrollments are
+	// asked to retry their requests.
+	RetryAfter int `json:"retry_after"`
+
+	// Since is when maintenance mode was enabled.
+	Since *time.Time `json:"since,omitempty"`
+}
+
+// Maintenance toggles the maintenance mode of the DDM protocol endpoints.
+// In maintenance mode requests are answered with a 503 Service
+// Unavailable status and a Retry-After header so that enrollments back
+// off (e.g. during a storage migration) rather than record failures.
+type Maintenance struct {
+	mu         sync.RWMutex
+	retryAfter time.Duration
+	since      *time.Time
+}
+
+// NewMaintenance creates a new maintenance mode that is disabled.
+// Enrollments are asked to retry after retryAfter when enabled.
+// DefaultMaintenanceRetryAfter is used if retryAfter is not positive.
+func NewMaintenance(retryAfter time.Duration) *Maintenance {
+	if retryAfter <= 0 {
+		retryAfter = DefaultMaintenanceRetryAfter
+	}
+	return &Maintenance{retryAfter: retryAfter}
+}
+
+// Enable enables maintenance mode. If retryAfter is positive it
+// changes the duration after which enrollments are asked to retry.
+func (m *Maintenance) Enable(retryAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if retryAfter > 0 {
+		m.retryAfter = retryAfter
+	}
+	if m.since == nil {
+		now := time.Now().UTC().Truncate(time.Second)
+		m.since = &now
+	}
+}
+
+// Disable disables maintenance mode.
+func (m *Maintenance) Disable() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.since = nil
+}
+
+// retryAfterSeconds returns the Retry-After seconds of d rounded up.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Status returns the maintenance mode status.
+func (m *Maintenance) Status() *MaintenanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return &MaintenanceStatus{
+		Enabled:    m.since != nil,
+		RetryAfter: retryAfterSeconds(m.retryAfter),
+		Since:      m.since,
+	}
+}
+
+// Middleware answers requests with a 503 Service Unavailable status
+// and a Retry-After header while in maintenance mode.
+func (m *Maintenance) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.mu.RLock()
+		enabled, retryAfter := m.since != nil, m.retryAfter
+		m.mu.RUnlock()
+		if enabled {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,253 @@
+// Package test contains a DDM protocol endpoint contract test suite
+// that storage backends can run to verify their protocol behavior.
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	contractSet          = "test_golang_contract_set"
+	contractOtherSet     = "test_golang_contract_other_set"
+	contractEnrollmentID = "test_golang_contract_enrollment"
+	contractUnknownID    = "test_golang_contract_unknown_enrollment"
+
+	configurationID = "test_golang_contract_configuration"
+	activationID    = "test_golang_contract_activation"
+	unentitledID    = "test_golang_contract_unentitled"
+)
+
+type contractStorage interface {
+	storage.DeclarationStorer
+	storage.SetDeclarationStorer
+	storage.EnrollmentSetStorer
+	storage.TokensDeclarationItemsRetriever
+	storage.DeclarationRetriever
+	storage.StatusStorer
+	storage.StatusDeclarationsRetriever
+}
+
+// normalize replaces the values that vary between storage backends
+// and runs (tokens and timestamps) with placeholders.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			switch k {
+			case "DeclarationsToken", "ServerToken":
+				if s, ok := val.(string); ok && s != "" {
+					v[k] = "<token>"
+					continue
+				}
+			case "Timestamp":
+				if s, ok := val.(string); ok {
+					if _, err := time.Parse(time.RFC3339, s); err == nil {
+						v[k] = "<timestamp>"
+						continue
+					}
+				}
+			}
+			v[k] = normalize(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	}
+	return v
+}
+
+// assertGolden compares the normalized JSON in body to the golden file.
+func assertGolden(t *testing.T, testdata, golden string, body []byte) {
+	t.Helper()
+	goldenBytes, err := os.ReadFile(filepath.Join(testdata, golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, have interface{}
+	if err = json.Unmarshal(goldenBytes, &want); err != nil {
+		t.Fatalf("parsing golden file %s: %v", golden, err)
+	}
+	if err = json.Unmarshal(body, &have); err != nil {
+		t.Fatalf("parsing response for %s: %v: %s", golden, err, body)
+	}
+	if have = normalize(have); !reflect.DeepEqual(have, want) {
+		haveBytes, _ := json.Marshal(have)
+		wantBytes, _ := json.Marshal(want)
+		t.Errorf("response does not match golden file %s:\nhave: %s\nwant: %s", golden, haveBytes, wantBytes)
+	}
+}
+
+// serve makes a request to h for enrollmentID and returns the response.
+func serve(h http.Handler, method, path, enrollmentID string, body []byte) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if enrollmentID != "" {
+		r.Header.Set(ddmhttp.EnrollmentIDHeader, enrollmentID)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func storeContractDeclaration(t *testing.T, testdata, name string, store contractStorage, ctx context.Context, setName string) *ddm.Declaration {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(testdata, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ddm.ParseDeclaration(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// TestContract validates the DDM protocol endpoints backed by store
+// against the golden request and response fixtures in the testdata
+// directory of pathToThisDir.
+func TestContract(t *testing.T, pathToThisDir string, store contractStorage, ctx context.Context) {
+	testdata := filepath.Join(pathToThisDir, "testdata")
+
+	// the unentitled declaration is in a set the enrollment is not in
+	storeContractDeclaration(t, testdata, "declaration.unentitled.json", store, ctx, contractOtherSet)
+	storeContractDeclaration(t, testdata, "declaration.configuration.json", store, ctx, contractSet)
+	storeContractDeclaration(t, testdata, "declaration.activation.json", store, ctx, contractSet)
+	if _, err := store.StoreEnrollmentSet(ctx, contractEnrollmentID, contractSet); err != nil {
+		t.Fatal(err)
+	}
+
+	tokensHandler := ddmhttp.TokensOrDeclarationItemsHandler(store, true, nil, log.NopLogger)
+	diHandler := ddmhttp.TokensOrDeclarationItemsHandler(store, false, nil, log.NopLogger)
+	declHandler := http.StripPrefix("/declaration/", ddmhttp.DeclarationHandler(store, nil, log.NopLogger))
+	statusHandler := ddmhttp.StatusReportHandler(store, nil, log.NopLogger)
+
+	var declarationsToken string
+	serverTokens := make(map[string]string)
+
+	t.Run("tokens", func(t *testing.T) {
+		w := serve(tokensHandler, http.MethodGet, "/tokens", contractEnrollmentID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: have %d, want %d", w.Code, http.StatusOK)
+		}
+		assertGolden(t, testdata, "tokens.golden.json", w.Body.Bytes())
+		tokens := new(ddm.TokensResponse)
+		if err := json.Unmarshal(w.Body.Bytes(), tokens); err != nil {
+			t.Fatal(err)
+		}
+		declarationsToken = tokens.SyncTokens.DeclarationsToken
+	})
+
+	t.Run("declaration-items", func(t *testing.T) {
+		w := serve(diHandler, http.MethodGet, "/declaration-items", contractEnrollmentID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: have %d, want %d", w.Code, http.StatusOK)
+		}
+		assertGolden(t, testdata, "declaration-items.golden.json", w.Body.Bytes())
+		di := new(ddm.DeclarationItems)
+		if err := json.Unmarshal(w.Body.Bytes(), di); err != nil {
+			t.Fatal(err)
+		}
+		if di.DeclarationsToken != declarationsToken {
+			t.Errorf("declarations token: declaration-items has %q, tokens has %q", di.DeclarationsToken, declarationsToken)
+		}
+		for _, items := range [][]ddm.ManifestDeclaration{di.Declarations.Activations, di.Declarations.Configurations} {
+			for _, item := range items {
+				serverTokens[item.Identifier] = item.ServerToken
+			}
+		}
+	})
+
+	t.Run("declaration", func(t *testing.T) {
+		w := serve(declHandler, http.MethodGet, "/declaration/configuration/"+configurationID, contractEnrollmentID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: have %d, want %d", w.Code, http.StatusOK)
+		}
+		assertGolden(t, testdata, "declaration.configuration.golden.json", w.Body.Bytes())
+		d, err := ddm.ParseDeclaration(w.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := d.ServerToken, serverTokens[configurationID]; have != want {
+			t.Errorf("server token: declaration has %q, declaration-items has %q", have, want)
+		}
+	})
+
+	t.Run("declaration-not-entitled", func(t *testing.T) {
+		w := serve(declHandler, http.MethodGet, "/declaration/configuration/"+unentitledID, contractEnrollmentID, nil)
+		if w.Code == http.StatusOK {
+			t.Errorf("enrollment retrieved declaration it is not entitled to: %s", w.Body.Bytes())
+		}
+		w = serve(declHandler, http.MethodGet, "/declaration/configuration/"+configurationID, contractUnknownID, nil)
+		if w.Code == http.StatusOK {
+			t.Errorf("unknown enrollment retrieved declaration: %s", w.Body.Bytes())
+		}
+	})
+
+	t.Run("unknown-enrollment", func(t *testing.T) {
+		w := serve(diHandler, http.MethodGet, "/declaration-items", contractUnknownID, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: have %d, want %d", w.Code, http.StatusOK)
+		}
+		assertGolden(t, testdata, "declaration-items.empty.golden.json", w.Body.Bytes())
+	})
+
+	t.Run("missing-enrollment-id", func(t *testing.T) {
+		for name, h := range map[string]http.Handler{"tokens": tokensHandler, "declaration-items": diHandler} {
+			if w := serve(h, http.MethodGet, "/"+name, "", nil); w.Code != http.StatusBadRequest {
+				t.Errorf("%s status: have %d, want %d", name, w.Code, http.StatusBadRequest)
+			}
+		}
+	})
+
+	t.Run("status", func(t *testing.T) {
+		body, err := os.ReadFile(filepath.Join(testdata, "status.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := serve(statusHandler, http.MethodPut, "/status", contractEnrollmentID, body)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status: have %d, want %d: %s", w.Code, http.StatusOK, w.Body.Bytes())
+		}
+		statuses, err := store.RetrieveDeclarationStatus(ctx, []string{contractEnrollmentID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := make(map[string]ddm.DeclarationQueryStatus)
+		for _, s := range statuses[contractEnrollmentID] {
+			found[s.Identifier] = s
+		}
+		for id, token := range map[string]string{
+			activationID:    "contract-activation-token",
+			configurationID: "contract-configuration-token",
+		} {
+			s, ok := found[id]
+			if !ok {
+				t.Errorf("status not stored for declaration: %s", id)
+				continue
+			}
+			if !s.Active || s.Valid != "valid" || s.ServerToken != token {
+				t.Errorf("status mismatch for %s: %+v", id, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StorageErrorStatus returns the HTTP status code for the storage
+// error category of err. Uncategorized errors are Internal Server Errors.
+func StorageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, storage.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, storage.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, storage.ErrRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, storage.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
@@ -26,6 +26,8 @@ func ReadAllAndReplaceBody(r *http.Request) ([]byte, error) {
 }
 
 // BasicAuthMiddleware is a simple HTTP plain authentication middleware.
+// The username is set as the principal name of the request context
+// (see PrincipalName).
 func BasicAuthMiddleware(next http.Handler, username, password, realm string) http.HandlerFunc {
 	uBytes := []byte(username)
 	pBytes := []byte(password)
@@ -36,7 +38,49 @@ func BasicAuthMiddleware(next http.Handler, username, password, realm string) ht
 			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
 			return
 		}
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(withPrincipalName(r.Context(), username)))
+	}
+}
+
+type ctxKeyReadOnly struct{}
+
+// IsReadOnly reports whether the request context was authenticated
+// by a read-only principal.
+func IsReadOnly(ctx context.Context) bool {
+	v, _ := ctx.Value(ctxKeyReadOnly{}).(bool)
+	return v
+}
+
+// ReadOnlyBasicAuthMiddleware is like BasicAuthMiddleware but also
+// accepts readOnlyPassword for a read-only principal. Read-only
+// principals may only make GET, HEAD, and OPTIONS requests and their
+// request contexts are marked (see IsReadOnly). The username is set as
+// the principal name of the request context (see PrincipalName).
+func ReadOnlyBasicAuthMiddleware(next http.Handler, username, password, readOnlyPassword, realm string) http.HandlerFunc {
+	uBytes := []byte(username)
+	pBytes := []byte(password)
+	roBytes := []byte(readOnlyPassword)
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), uBytes) != 1 {
+			ok = false
+		} else if subtle.ConstantTimeCompare([]byte(p), pBytes) == 1 {
+			next.ServeHTTP(w, r.WithContext(withPrincipalName(r.Context(), username)))
+			return
+		} else if len(roBytes) < 1 || subtle.ConstantTimeCompare([]byte(p), roBytes) != 1 {
+			ok = false
+		}
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if !safeMethod(r.Method) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		ctx := withPrincipalName(r.Context(), username)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, ctxKeyReadOnly{}, true)))
 	}
 }
 
@@ -95,7 +139,7 @@ func CORSMiddleware(next http.Handler, origin string) http.HandlerFunc {
 		if origin != "" {
 			h.Add("Access-Control-Allow-Origin", origin)
 		}
-		h.Add("Access-Control-Allow-Headers", "Authorization")
+		h.Add("Access-Control-Allow-Headers", "Authorization, "+IdempotencyKeyHeader)
 		h.Add("Access-Control-Allow-Credentials", "true")
 		h.Add("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PUT")
 		next.ServeHTTP(w, r)
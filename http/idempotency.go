@@ -0,0 +1,217 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	// IdempotencyKeyHeader is the HTTP header clients use to supply an idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotencyReplayedHeader is set on responses that were replayed from
+	// a previously recorded response.
+	IdempotencyReplayedHeader = "Idempotency-Replayed"
+
+	// DefaultIdempotencyTTL is how long recorded responses are honored.
+	DefaultIdempotencyTTL = 24 * time.Hour
+
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyPruneInterval is how often expired responses are deleted.
+	idempotencyPruneInterval = time.Hour
+)
+
+// requestFingerprint hashes the parts of r that make it unique.
+// The request body is read and replaced.
+func requestFingerprint(r *http.Request) (string, error) {
+	body, err := ReadAllAndReplaceBody(r)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.RawQuery))
+	h.Write([]byte{0})
+	h.Write(body)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// scopedIdempotencyKey scopes key to the principal that authenticated
+// r and the method and path of r. This keeps different principals (and
+// endpoints) from replaying or colliding with each other's responses.
+func scopedIdempotencyKey(r *http.Request, key string) string {
+	h := sha256.New()
+	for _, s := range []string{PrincipalName(r.Context()), r.Method, r.URL.Path, key} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// recordingResponseWriter captures the status and body written to it.
+type recordingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// inFlight tracks idempotency keys of requests currently being handled.
+type inFlight struct {
+	sync.Mutex
+	keys map[string]struct{}
+}
+
+func (f *inFlight) acquire(key string) bool {
+	f.Lock()
+	defer f.Unlock()
+	if _, ok := f.keys[key]; ok {
+		return false
+	}
+	f.keys[key] = struct{}{}
+	return true
+}
+
+func (f *inFlight) release(key string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.keys, key)
+}
+
+// pruneSchedule reports when expired responses are due to be deleted.
+type pruneSchedule struct {
+	sync.Mutex
+	last time.Time
+}
+
+// due reports whether interval passed since it was last due.
+func (p *pruneSchedule) due(now time.Time, interval time.Duration) bool {
+	p.Lock()
+	defer p.Unlock()
+	if now.Sub(p.last) < interval {
+		return false
+	}
+	p.last = now
+	return true
+}
+
+func idempotencyError(w http.ResponseWriter, status int, logger log.Logger, msg string, err error) {
+	logger.Info(logkeys.Message, msg, logkeys.Error, err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// IdempotencyMiddleware records responses to mutating requests that
+// include an Idempotency-Key header and replays them when a request with
+// the same key is retried. Replayed requests are not passed to next so
+// changes are not re-applied and enrollments are not re-notified.
+// Keys are scoped to the authenticated principal and the method and
+// path of the request: the same key used by a different principal or
+// for a different endpoint is a different key.
+// Reusing a key for a different request is rejected. Responses with
+// server errors are not recorded so that they may be retried.
+// Recorded responses older than ttl are ignored and are deleted from
+// store after recording a response at most once an hour.
+func IdempotencyMiddleware(next http.Handler, store storage.IdempotencyStorage, ttl time.Duration, logger log.Logger) http.HandlerFunc {
+	if store == nil || logger == nil {
+		panic("nil store or logger")
+	}
+	flight := &inFlight{keys: make(map[string]struct{})}
+	prune := new(pruneSchedule)
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			key = ""
+		}
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		logger := ctxlog.Logger(r.Context(), logger).With("idempotency_key", key)
+		if len(key) > maxIdempotencyKeyLength {
+			idempotencyError(w, http.StatusBadRequest, logger, "validating idempotency key", errors.New("idempotency key too long"))
+			return
+		}
+		fingerprint, err := requestFingerprint(r)
+		if err != nil {
+			idempotencyError(w, http.StatusInternalServerError, logger, "reading body", err)
+			return
+		}
+		key = scopedIdempotencyKey(r, key)
+		if !flight.acquire(key) {
+			idempotencyError(w, http.StatusConflict, logger, "checking idempotency key", errors.New("request with idempotency key in progress"))
+			return
+		}
+		defer flight.release(key)
+		resp, err := store.RetrieveIdempotentResponse(r.Context(), key)
+		if err != nil {
+			idempotencyError(w, http.StatusInternalServerError, logger, "retrieving idempotent response", err)
+			return
+		}
+		if resp != nil && (ttl <= 0 || time.Since(resp.Timestamp) < ttl) {
+			if resp.Fingerprint != fingerprint {
+				idempotencyError(w, http.StatusUnprocessableEntity, logger, "checking idempotency key", errors.New("idempotency key reused for different request"))
+				return
+			}
+			logger.Debug(logkeys.Message, "replaying idempotent response", "status", resp.StatusCode)
+			if resp.ContentType != "" {
+				w.Header().Set("Content-Type", resp.ContentType)
+			}
+			w.Header().Set(IdempotencyReplayedHeader, "true")
+			w.WriteHeader(resp.StatusCode)
+			w.Write(resp.Body)
+			return
+		}
+		rec := &recordingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= 500 {
+			return
+		}
+		err = store.StoreIdempotentResponse(r.Context(), key, &storage.IdempotentResponse{
+			Fingerprint: fingerprint,
+			StatusCode:  rec.status,
+			ContentType: rec.Header().Get("Content-Type"),
+			Body:        rec.body.Bytes(),
+			Timestamp:   time.Now(),
+		})
+		if err != nil {
+			logger.Info(logkeys.Message, "storing idempotent response", logkeys.Error, err)
+		}
+		if now := time.Now(); ttl > 0 && prune.due(now, idempotencyPruneInterval) {
+			if err = store.DeleteIdempotentResponses(r.Context(), now.Add(-ttl)); err != nil {
+				logger.Info(logkeys.Message, "deleting expired idempotent responses", logkeys.Error, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+)
+
+// Principal is a named API user authenticated with HTTP Basic
+// authentication using its name and key.
+type Principal struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+
+	// ReadOnly principals are limited like the read-only API key.
+	ReadOnly bool `json:"read_only,omitempty"`
+
+	// ApprovalRequired holds the mutating requests of the principal
+	// until approved by a different principal (see ApprovalMiddleware).
+	ApprovalRequired bool `json:"approval_required,omitempty"`
+}
+
+// ReadPrincipals reads a JSON array of principals from r.
+func ReadPrincipals(r io.Reader) ([]Principal, error) {
+	var principals []Principal
+	if err := json.NewDecoder(r).Decode(&principals); err != nil {
+		return nil, fmt.Errorf("decoding principals: %w", err)
+	}
+	for i, p := range principals {
+		if p.Name == "" || p.Key == "" {
+			return nil, fmt.Errorf("principal %d: empty name or key", i)
+		}
+	}
+	return principals, nil
+}
+
+// ReadPrincipalsFile reads a JSON array of principals from the file at path.
+func ReadPrincipalsFile(path string) ([]Principal, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPrincipals(f)
+}
+
+type ctxKeyPrincipal struct{}
+
+type ctxKeyPrincipalName struct{}
+
+// GetPrincipal returns the principal that authenticated the request context.
+func GetPrincipal(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKeyPrincipal{}).(Principal)
+	return p, ok
+}
+
+// PrincipalName returns the name of the principal that authenticated
+// the request context. For the API key this is the HTTP Basic
+// authentication username. Empty if the context was not authenticated.
+func PrincipalName(ctx context.Context) string {
+	name, _ := ctx.Value(ctxKeyPrincipalName{}).(string)
+	return name
+}
+
+// withPrincipalName returns ctx with the principal name set to name.
+func withPrincipalName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, ctxKeyPrincipalName{}, name)
+}
+
+// PrincipalBasicAuthMiddleware authenticates requests using HTTP Basic
+// authentication against principals. The authenticated principal is
+// stored in the request context (see GetPrincipal) and logged.
+// Requests replaying an approved change (see ApprovalMiddleware) were
+// already authenticated and are passed to next.
+func PrincipalBasicAuthMiddleware(next http.Handler, principals []Principal, realm string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if approvedChange(r.Context()) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		u, p, ok := r.BasicAuth()
+		var principal *Principal
+		if ok {
+			// compare against every principal to not leak which matched
+			for i := range principals {
+				if subtle.ConstantTimeCompare([]byte(u), []byte(principals[i].Name)) == 1 &&
+					subtle.ConstantTimeCompare([]byte(p), []byte(principals[i].Key)) == 1 {
+					principal = &principals[i]
+				}
+			}
+		}
+		if principal == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+realm+`"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), ctxKeyPrincipal{}, *principal)
+		ctx = withPrincipalName(ctx, principal.Name)
+		ctx = ctxlog.AddFunc(ctx, ctxlog.SimpleStringFunc("principal", ctxKeyPrincipalName{}))
+		if principal.ReadOnly {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			ctx = context.WithValue(ctx, ctxKeyReadOnly{}, true)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
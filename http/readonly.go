@@ -0,0 +1,27 @@
+package http
+
+import "net/http"
+
+// safeMethod reports whether method only retrieves data.
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// ReadOnlyServerMiddleware rejects requests that may change data with
+// a 423 Locked status for servers in read-only mode (e.g. disaster
+// recovery replicas or during change freezes). GET, HEAD, and OPTIONS
+// requests are served as are requests for which query returns true
+// (e.g. POSTed batch queries). Query may be nil.
+func ReadOnlyServerMiddleware(next http.Handler, query func(*http.Request) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !safeMethod(r.Method) && (query == nil || !query(r)) {
+			http.Error(w, "server is read-only", http.StatusLocked)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
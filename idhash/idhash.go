@@ -0,0 +1,420 @@
+// Package idhash stores the declaration status, status errors, and raw
+// status reports of enrollments under salted hashes of their enrollment
+// IDs so that these records do not identify devices by themselves.
+package idhash
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Hasher hashes enrollment IDs.
+type Hasher interface {
+	// HashID returns the hash of enrollmentID. The hash must be a
+	// valid identifier (see storage.ValidateIdentifier).
+	HashID(enrollmentID string) string
+}
+
+// HasherFunc is a Hasher function.
+type HasherFunc func(enrollmentID string) string
+
+// HashID calls f(enrollmentID).
+func (f HasherFunc) HashID(enrollmentID string) string {
+	return f(enrollmentID)
+}
+
+// NewSaltedHasher creates a Hasher of the hex HMAC-SHA256 of enrollment
+// IDs keyed with salt. The salt should be kept secret: the enrollment
+// IDs of devices are often guessable (e.g. UDIDs or serial numbers).
+func NewSaltedHasher(salt []byte) Hasher {
+	return HasherFunc(func(enrollmentID string) string {
+		mac := hmac.New(sha256.New, salt)
+		mac.Write([]byte(enrollmentID))
+		return hex.EncodeToString(mac.Sum(nil))
+	})
+}
+
+// Lookup maps hashed enrollment IDs back to enrollment IDs.
+// It is kept apart from the hashed records (e.g. encrypted or in an
+// external service).
+type Lookup interface {
+	// StoreIDHash records that hash is the hash of enrollmentID.
+	StoreIDHash(ctx context.Context, hash, enrollmentID string) error
+
+	// ResolveIDHash returns the enrollment ID of hash.
+	// An empty enrollment ID is returned if hash is unknown.
+	ResolveIDHash(ctx context.Context, hash string) (string, error)
+}
+
+// Config configures the hashing of enrollment IDs.
+type Config struct {
+	// SaltEnv is the name of the environment variable containing the salt.
+	SaltEnv string `json:"salt_env"`
+
+	// Lookup configures an encrypted file lookup of hashed enrollment
+	// IDs. Without one the enrollment IDs of hashed records can not
+	// be resolved.
+	Lookup *FileLookupConfig `json:"lookup,omitempty"`
+}
+
+// FileLookupConfig configures an encrypted file lookup.
+type FileLookupConfig struct {
+	Path string `json:"path"`
+
+	// KeyEnv is the name of the environment variable containing the
+	// hex encoded 32 byte AES-256 key of the file.
+	KeyEnv string `json:"key_env"`
+}
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding ID hashing config: %w", err)
+	}
+	if c.SaltEnv == "" {
+		return nil, errors.New("missing salt environment variable")
+	}
+	if c.Lookup != nil && (c.Lookup.Path == "" || c.Lookup.KeyEnv == "") {
+		return nil, errors.New("lookup requires path and key environment variable")
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Hasher creates the salted Hasher of c.
+func (c *Config) Hasher() (Hasher, error) {
+	salt := os.Getenv(c.SaltEnv)
+	if salt == "" {
+		return nil, fmt.Errorf("empty salt environment variable: %s", c.SaltEnv)
+	}
+	return NewSaltedHasher([]byte(salt)), nil
+}
+
+// NewLookup creates the Lookup of c. A nil Lookup is returned if none
+// is configured.
+func (c *Config) NewLookup() (Lookup, error) {
+	if c.Lookup == nil {
+		return nil, nil
+	}
+	key, err := hex.DecodeString(os.Getenv(c.Lookup.KeyEnv))
+	if err != nil {
+		return nil, fmt.Errorf("decoding lookup key: %w", err)
+	}
+	return NewFileLookup(c.Lookup.Path, key)
+}
+
+// Storage is the storage needed to hash enrollment IDs.
+type Storage interface {
+	storage.StatusStorer
+	storage.StatusAPIStorage
+	storage.PendingRemovalsRetriever
+	storage.StatusQuarantineRetriever
+	storage.DeclarationStatusEnrollmentsRetriever
+	storage.EnrollmentDeclarationsRetriever
+	storage.EnrollmentIDRetriever
+	storage.SetDeclarationsRetriever
+}
+
+// Store stores the declaration status, status errors, quarantined
+// fragments, and raw status reports of enrollments under the hashes of
+// their enrollment IDs. Status values are stored under the enrollment
+// IDs as they are needed to serve enrollments (e.g. for set declaration
+// conditions) and are retrieved unchanged.
+//
+// As the hashed records are not associated with the sets of their
+// enrollments the assignment-dependent parts of declaration status
+// (whether a status is current or unassigned) and set status summaries
+// are computed by Store rather than by the storage backend.
+type Store struct {
+	Storage
+	hasher Hasher
+	lookup Lookup
+
+	// stored are the hashes known to be in lookup
+	mu     sync.RWMutex
+	stored map[string]struct{}
+}
+
+type Option func(*Store)
+
+// WithLookup records the hashes of enrollment IDs in lookup to resolve
+// the hashed enrollment IDs of retrieved records.
+func WithLookup(lookup Lookup) Option {
+	return func(s *Store) {
+		s.lookup = lookup
+	}
+}
+
+// New creates a new Store wrapping store.
+func New(store Storage, hasher Hasher, opts ...Option) *Store {
+	if hasher == nil {
+		panic("nil hasher")
+	}
+	s := &Store{
+		Storage: store,
+		hasher:  hasher,
+		stored:  make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// hash returns the hashed enrollment ID of enrollmentID. Hashes are
+// prefixed so that storage backends do not treat them as enrollments.
+func (s *Store) hash(enrollmentID string) string {
+	return storage.HashedEnrollmentIDPrefix + s.hasher.HashID(enrollmentID)
+}
+
+// hashIDs returns the hashes of enrollmentIDs and a map of the hashes
+// back to the enrollment IDs.
+func (s *Store) hashIDs(enrollmentIDs []string) ([]string, map[string]string) {
+	hashes := make([]string, len(enrollmentIDs))
+	ids := make(map[string]string, len(enrollmentIDs))
+	for i, id := range enrollmentIDs {
+		hashes[i] = s.hash(id)
+		ids[hashes[i]] = id
+	}
+	return hashes, ids
+}
+
+// storeIDHash records hash of enrollmentID in the lookup if not already.
+func (s *Store) storeIDHash(ctx context.Context, hash, enrollmentID string) error {
+	if s.lookup == nil {
+		return nil
+	}
+	s.mu.RLock()
+	_, ok := s.stored[hash]
+	s.mu.RUnlock()
+	if ok {
+		return nil
+	}
+	if err := s.lookup.StoreIDHash(ctx, hash, enrollmentID); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.stored[hash] = struct{}{}
+	s.mu.Unlock()
+	return nil
+}
+
+// StoreDeclarationStatus stores the status values of status under
+// enrollmentID and the rest of status under the hash of enrollmentID.
+// The raw status report is only stored under the hash.
+func (s *Store) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	hash := s.hash(enrollmentID)
+	if err := s.storeIDHash(ctx, hash, enrollmentID); err != nil {
+		return fmt.Errorf("storing ID hash: %w", err)
+	}
+	values := &ddm.StatusReport{
+		ID:     status.ID,
+		Values: status.Values,
+		Raw:    []byte("{}"),
+	}
+	hashed := &ddm.StatusReport{
+		ID:           status.ID,
+		Declarations: status.Declarations,
+		Errors:       status.Errors,
+		Raw:          status.Raw,
+		Quarantined:  status.Quarantined,
+	}
+	err := s.Storage.StoreDeclarationStatus(ctx, enrollmentID, values)
+	if err == nil {
+		err = s.Storage.StoreDeclarationStatus(ctx, hash, hashed)
+	}
+	// report what the storage backend quarantined and dropped
+	status.Quarantined = append(hashed.Quarantined, values.Quarantined...)
+	status.DroppedValues += values.DroppedValues
+	status.DroppedErrors += hashed.DroppedErrors
+	return err
+}
+
+// RetrieveDeclarationStatus retrieves the status of declarations for
+// enrollmentIDs. Statuses are current if their server token matches
+// that of the declaration assigned to the enrollment and are
+// unassigned if the declaration is no longer assigned. Assigned
+// declarations without a reported status are included.
+func (s *Store) RetrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	if len(enrollmentIDs) < 1 {
+		return s.Storage.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+	}
+	hashes, ids := s.hashIDs(enrollmentIDs)
+	hashed, err := s.Storage.RetrieveDeclarationStatus(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]ddm.DeclarationQueryStatus)
+	for _, hash := range hashes {
+		id := ids[hash]
+		declarations, err := s.Storage.RetrieveEnrollmentDeclarations(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving enrollment declarations for %s: %w", id, err)
+		}
+		statuses := make(map[string]ddm.DeclarationQueryStatus, len(declarations))
+		tokens := make(map[string]string, len(declarations))
+		for _, d := range declarations {
+			tokens[d.Identifier] = d.ServerToken
+			statuses[d.Identifier] = ddm.DeclarationQueryStatus{
+				DeclarationStatus: ddm.DeclarationStatus{
+					Identifier:  d.Identifier,
+					ServerToken: d.ServerToken,
+				},
+			}
+		}
+		for _, status := range hashed[hash] {
+			token, assigned := tokens[status.Identifier]
+			status.Current = assigned && status.ServerToken == token
+			status.Unassigned = !assigned
+			statuses[status.Identifier] = status
+		}
+		if len(statuses) < 1 {
+			continue
+		}
+		list := make([]ddm.DeclarationQueryStatus, 0, len(statuses))
+		for _, status := range statuses {
+			list = append(list, status)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Identifier < list[j].Identifier })
+		ret[id] = list
+	}
+	return ret, nil
+}
+
+// RetrievePendingRemovals retrieves the unassigned declarations last
+// reported as active for enrollmentIDs.
+func (s *Store) RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	statuses, err := s.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PendingRemovals(statuses), nil
+}
+
+// RetrieveStatusErrors retrieves the status errors of enrollmentIDs.
+func (s *Store) RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]storage.StatusError, error) {
+	hashes, ids := s.hashIDs(enrollmentIDs)
+	hashed, err := s.Storage.RetrieveStatusErrors(ctx, hashes, since, until, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]storage.StatusError, len(hashed))
+	for hash, errs := range hashed {
+		ret[ids[hash]] = errs
+	}
+	return ret, nil
+}
+
+// RetrieveStatusQuarantine retrieves the quarantined status report
+// fragments of enrollmentIDs.
+func (s *Store) RetrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string) (map[string][]storage.QuarantinedStatus, error) {
+	hashes, ids := s.hashIDs(enrollmentIDs)
+	hashed, err := s.Storage.RetrieveStatusQuarantine(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string][]storage.QuarantinedStatus, len(hashed))
+	for hash, fragments := range hashed {
+		ret[ids[hash]] = fragments
+	}
+	return ret, nil
+}
+
+// RetrieveStatusReport retrieves the raw status report of q.
+func (s *Store) RetrieveStatusReport(ctx context.Context, q storage.StatusReportQuery) (*storage.StoredStatusReport, error) {
+	q.EnrollmentID = s.hash(q.EnrollmentID)
+	return s.Storage.RetrieveStatusReport(ctx, q)
+}
+
+// RetrieveDeclarationStatusEnrollments retrieves the sorted IDs of the
+// enrollments whose last reported status of the declaration matches q.
+// Hashed enrollment IDs that can not be resolved (e.g. without a
+// lookup) are returned as their hashes.
+func (s *Store) RetrieveDeclarationStatusEnrollments(ctx context.Context, q *storage.DeclarationStatusQuery) ([]string, error) {
+	hashes, err := s.Storage.RetrieveDeclarationStatusEnrollments(ctx, q)
+	if err != nil || s.lookup == nil {
+		return hashes, err
+	}
+	ret := make([]string, len(hashes))
+	for i, hash := range hashes {
+		if ret[i], err = s.lookup.ResolveIDHash(ctx, hash); err != nil {
+			return nil, fmt.Errorf("resolving ID hash: %w", err)
+		} else if ret[i] == "" {
+			ret[i] = hash
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// RetrieveSetStatusSummary summarizes the reported status of the
+// declarations in setName across the enrollments in setName.
+func (s *Store) RetrieveSetStatusSummary(ctx context.Context, setName string) ([]storage.DeclarationStatusSummary, error) {
+	declarationIDs, err := s.Storage.RetrieveSetDeclarations(ctx, setName)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving set declarations: %w", err)
+	}
+	enrollmentIDs, err := s.Storage.RetrieveEnrollmentIDs(ctx, nil, []string{setName}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving set enrollments: %w", err)
+	}
+	summaryMap := make(map[string]*storage.DeclarationStatusSummary, len(declarationIDs))
+	ret := make([]storage.DeclarationStatusSummary, len(declarationIDs))
+	for i, declarationID := range declarationIDs {
+		ret[i] = storage.DeclarationStatusSummary{
+			Identifier:  declarationID,
+			Enrollments: len(enrollmentIDs),
+			Unknown:     len(enrollmentIDs),
+		}
+		summaryMap[declarationID] = &ret[i]
+	}
+	if len(enrollmentIDs) < 1 {
+		return ret, nil
+	}
+	hashes, _ := s.hashIDs(enrollmentIDs)
+	statuses, err := s.Storage.RetrieveDeclarationStatus(ctx, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declaration status: %w", err)
+	}
+	for _, enrollmentStatuses := range statuses {
+		for _, status := range enrollmentStatuses {
+			summary, ok := summaryMap[status.Identifier]
+			if !ok || status.Valid == "" {
+				// not in the set or not reported
+				continue
+			}
+			summary.Unknown--
+			if status.Active && status.Valid == "valid" {
+				summary.ActiveValid++
+			} else {
+				summary.Failing++
+			}
+		}
+	}
+	return ret, nil
+}
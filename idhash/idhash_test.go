@@ -0,0 +1,178 @@
+package idhash
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestSaltedHasher(t *testing.T) {
+	h1 := NewSaltedHasher([]byte("salt1"))
+	h2 := NewSaltedHasher([]byte("salt2"))
+	const id = "EB9DE86C-2E95-4F73-80A3-34F1D8111FA2"
+	if h1.HashID(id) != h1.HashID(id) {
+		t.Error("hash not stable")
+	}
+	if h1.HashID(id) == h2.HashID(id) {
+		t.Error("hash not salted")
+	}
+	if err := storage.ValidateIdentifier("hash", h1.HashID(id)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	const enrollmentID = "EB9DE86C-2E95-4F73-80A3-34F1D8111FA2"
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"hi"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if d, err = fs.RetrieveDeclaration(ctx, d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreSetDeclaration(ctx, "finance", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreEnrollmentSet(ctx, enrollmentID, "finance"); err != nil {
+		t.Fatal(err)
+	}
+
+	lookup, err := NewFileLookup(filepath.Join(t.TempDir(), "lookup"), make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hasher := NewSaltedHasher([]byte("salt"))
+	s := New(fs, hasher, WithLookup(lookup))
+	hashedID := storage.HashedEnrollmentIDPrefix + hasher.HashID(enrollmentID)
+
+	raw := fmt.Sprintf(`{"StatusItems":{"device":{"operating-system":{"version":"17.1"}},"management":{"declarations":{"configurations":[{"identifier":%q,"active":true,"valid":"valid","server-token":%q},{"identifier":"com.example.old","active":true,"valid":"valid","server-token":"x"}]}}},"Errors":[{"ErrorCode":1}]}`, d.Identifier, d.ServerToken)
+	_, status, err := ddm.ParseStatus([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		t.Fatal(err)
+	}
+
+	// status values are stored under the enrollment ID
+	values, err := fs.RetrieveStatusValues(ctx, []string{enrollmentID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values[enrollmentID]) < 1 {
+		t.Error("expected status values of enrollment ID")
+	}
+
+	// errors and declaration status are stored under the hash only
+	errs, err := fs.RetrieveStatusErrors(ctx, []string{enrollmentID, hashedID}, time.Time{}, time.Time{}, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs[enrollmentID]) != 0 || len(errs[hashedID]) != 1 {
+		t.Errorf("stored errors: %v", errs)
+	}
+	if errs, err = s.RetrieveStatusErrors(ctx, []string{enrollmentID}, time.Time{}, time.Time{}, 0, 100); err != nil {
+		t.Fatal(err)
+	} else if len(errs[enrollmentID]) != 1 {
+		t.Errorf("retrieved errors: %v", errs)
+	}
+
+	statuses, err := s.RetrieveDeclarationStatus(ctx, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(statuses[enrollmentID]), 2; have != want {
+		t.Fatalf("statuses: have: %v, want: %v", have, want)
+	}
+	for _, status := range statuses[enrollmentID] {
+		switch status.Identifier {
+		case d.Identifier:
+			if !status.Current || status.Unassigned {
+				t.Errorf("expected current and assigned: %+v", status)
+			}
+		case "com.example.old":
+			if status.Current || !status.Unassigned {
+				t.Errorf("expected unassigned: %+v", status)
+			}
+		}
+	}
+	removals, err := s.RetrievePendingRemovals(ctx, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals[enrollmentID]) != 1 || removals[enrollmentID][0].Identifier != "com.example.old" {
+		t.Errorf("pending removals: %v", removals)
+	}
+
+	summary, err := s.RetrieveSetStatusSummary(ctx, "finance")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary) != 1 || summary[0].ActiveValid != 1 || summary[0].Unknown != 0 {
+		t.Errorf("summary: %+v", summary)
+	}
+
+	index := 0
+	report, err := s.RetrieveStatusReport(ctx, storage.StatusReportQuery{EnrollmentID: enrollmentID, Index: &index})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(report.Raw) != raw {
+		t.Errorf("status report: have: %s, want: %s", report.Raw, raw)
+	}
+
+	ids, err := s.RetrieveDeclarationStatusEnrollments(ctx, &storage.DeclarationStatusQuery{Identifier: d.Identifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != enrollmentID {
+		t.Errorf("declaration status enrollments: have: %v, want: [%s]", ids, enrollmentID)
+	}
+}
+
+func TestFileLookup(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "lookup")
+	key := make([]byte, 32)
+	l, err := NewFileLookup(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = l.StoreIDHash(ctx, "h1", "E1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// reopened lookups are decrypted
+	if l, err = NewFileLookup(path, key); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := l.ResolveIDHash(ctx, "h1"); err != nil {
+		t.Fatal(err)
+	} else if id != "E1" {
+		t.Errorf("resolved: have: %q, want: %q", id, "E1")
+	}
+	if id, _ := l.ResolveIDHash(ctx, "h2"); id != "" {
+		t.Errorf("resolved unknown: %q", id)
+	}
+
+	key[0] = 1
+	if _, err = NewFileLookup(path, key); err == nil {
+		t.Error("expected error with wrong key")
+	}
+}
@@ -0,0 +1,88 @@
+package idhash
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// FileLookup is a Lookup kept in an AES-256-GCM encrypted JSON file.
+// The whole file is rewritten when a hash is stored so it suits a
+// single server with a modest number of enrollments.
+type FileLookup struct {
+	mu   sync.RWMutex
+	path string
+	aead cipher.AEAD
+	ids  map[string]string
+}
+
+// NewFileLookup creates a new FileLookup at path encrypted with the
+// 32 byte AES-256 key. The file is created when a hash is first stored.
+func NewFileLookup(path string, key []byte) (*FileLookup, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid key length: %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	l := &FileLookup{path: path, aead: aead, ids: make(map[string]string)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return l, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading lookup: %w", err)
+	}
+	nonceSize := aead.NonceSize()
+	if len(b) < nonceSize {
+		return nil, errors.New("lookup too short")
+	}
+	if b, err = aead.Open(nil, b[:nonceSize], b[nonceSize:], nil); err != nil {
+		return nil, fmt.Errorf("decrypting lookup: %w", err)
+	}
+	if err = json.Unmarshal(b, &l.ids); err != nil {
+		return nil, fmt.Errorf("unmarshal lookup: %w", err)
+	}
+	return l, nil
+}
+
+// StoreIDHash records that hash is the hash of enrollmentID.
+func (l *FileLookup) StoreIDHash(_ context.Context, hash, enrollmentID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.ids[hash] == enrollmentID {
+		return nil
+	}
+	l.ids[hash] = enrollmentID
+	b, err := json.Marshal(l.ids)
+	if err != nil {
+		return fmt.Errorf("marshal lookup: %w", err)
+	}
+	nonce := make([]byte, l.aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+	if err = os.WriteFile(l.path, l.aead.Seal(nonce, nonce, b, nil), 0600); err != nil {
+		delete(l.ids, hash)
+		return fmt.Errorf("writing lookup: %w", err)
+	}
+	return nil
+}
+
+// ResolveIDHash returns the enrollment ID of hash.
+func (l *FileLookup) ResolveIDHash(_ context.Context, hash string) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.ids[hash], nil
+}
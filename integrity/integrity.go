@@ -0,0 +1,126 @@
+// Package integrity verifies the referential integrity of storage.
+//
+// Verification is intended to be run after restoring storage from a
+// backup (e.g. a point-in-time database restore or a copy of a file
+// storage directory) which may leave associations pointing at missing
+// declarations and derived DDM data out of date.
+package integrity
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the storage that is verified.
+type Storage interface {
+	storage.DeclarationsRetriever
+	storage.DeclarationSetRetriever
+	storage.SetRetreiver
+	storage.SetDeclarationsRetriever
+	storage.DDMRepairer
+}
+
+// SetDeclaration is an association between a set and a declaration.
+type SetDeclaration struct {
+	Set         string `json:"set"`
+	Declaration string `json:"declaration"`
+}
+
+// Report is the result of a verification.
+type Report struct {
+	// Declarations and Sets are the number of declarations and sets verified.
+	Declarations int `json:"declarations"`
+	Sets         int `json:"sets"`
+
+	// MissingDeclarations are the associations of sets with
+	// declarations that do not exist.
+	MissingDeclarations []SetDeclaration `json:"missing_declarations"`
+
+	// Unreciprocated are the associations of declarations with sets
+	// that are not also associations of the sets with the declarations.
+	Unreciprocated []SetDeclaration `json:"unreciprocated"`
+
+	// DDM is the report of checking (and repairing) the derived DDM
+	// data of enrollments. It is nil if declarations are missing as the
+	// derived DDM data cannot be built until they are resolved.
+	DDM *storage.DDMRepairReport `json:"ddm"`
+}
+
+// OK reports whether no problems were found. Repaired derived DDM
+// data is still reported as a problem as it was found to be stale.
+func (r *Report) OK() bool {
+	return len(r.MissingDeclarations) < 1 &&
+		len(r.Unreciprocated) < 1 &&
+		r.DDM != nil && len(r.DDM.Mismatches) < 1
+}
+
+// Verify checks that the declarations associated with sets exist and
+// that the associations agree in both directions. Then the derived DDM
+// data of all enrollments is checked and rewritten (unless dryRun is
+// true) if it does not match. Associations are never changed: they
+// are reported for an operator to resolve. The derived DDM data is not
+// checked if any declarations are missing.
+func Verify(ctx context.Context, store Storage, dryRun bool) (*Report, error) {
+	report := &Report{
+		MissingDeclarations: []SetDeclaration{},
+		Unreciprocated:      []SetDeclaration{},
+	}
+
+	declarations, err := store.RetrieveDeclarations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declarations: %w", err)
+	}
+	report.Declarations = len(declarations)
+	exists := make(map[string]bool, len(declarations))
+	for _, declarationID := range declarations {
+		exists[declarationID] = true
+	}
+
+	sets, err := store.RetrieveSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving sets: %w", err)
+	}
+	report.Sets = len(sets)
+	associated := make(map[SetDeclaration]bool)
+	for _, setName := range sets {
+		declarationIDs, err := store.RetrieveSetDeclarations(ctx, setName)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving declarations of set %s: %w", setName, err)
+		}
+		for _, declarationID := range declarationIDs {
+			sd := SetDeclaration{Set: setName, Declaration: declarationID}
+			associated[sd] = true
+			if !exists[declarationID] {
+				report.MissingDeclarations = append(report.MissingDeclarations, sd)
+			}
+		}
+	}
+
+	for _, declarationID := range declarations {
+		setNames, err := store.RetrieveDeclarationSets(ctx, declarationID)
+		if err != nil {
+			return nil, fmt.Errorf("retrieving sets of declaration %s: %w", declarationID, err)
+		}
+		for _, setName := range setNames {
+			sd := SetDeclaration{Set: setName, Declaration: declarationID}
+			if !associated[sd] {
+				report.Unreciprocated = append(report.Unreciprocated, sd)
+			}
+		}
+	}
+
+	if len(report.MissingDeclarations) > 0 {
+		return report, nil
+	}
+
+	if report.DDM, err = store.RepairEnrollmentDDM(ctx, dryRun); err != nil {
+		return nil, fmt.Errorf("repairing enrollment DDM: %w", err)
+	}
+	if report.DDM.Mismatches == nil {
+		// encode as an empty JSON array
+		report.DDM.Mismatches = []storage.DDMMismatch{}
+	}
+	return report, nil
+}
@@ -0,0 +1,103 @@
+package integrity
+
+import (
+	"context"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+const testDecl = `{
+    "Type": "com.apple.configuration.management.test",
+    "Payload": {"Echo": "Foo"},
+    "Identifier": "test_integrity_decl"
+}`
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := file.New(dir, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	decl, err := ddm.ParseDeclaration([]byte(testDecl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, "set1", decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, "ENR1", "set1"); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Verify(ctx, store, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("expected OK: %+v", report)
+	}
+	if have, want := report.Declarations, 1; have != want {
+		t.Errorf("declarations: have: %v, want: %v", have, want)
+	}
+	if have, want := report.Sets, 1; have != want {
+		t.Errorf("sets: have: %v, want: %v", have, want)
+	}
+
+	// simulate a restore with stale derived data
+	if err = os.WriteFile(filepath.Join(dir, "ENR1", "declaration-items.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = Verify(ctx, store, true); err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() {
+		t.Error("expected not OK")
+	}
+	if have, want := len(report.DDM.Mismatches), 1; have != want {
+		t.Errorf("DDM mismatches: have: %v, want: %v", have, want)
+	}
+
+	// repair
+	if report, err = Verify(ctx, store, false); err != nil {
+		t.Fatal(err)
+	}
+	if !report.DDM.Repaired {
+		t.Error("expected repaired")
+	}
+	if report, err = Verify(ctx, store, true); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("expected OK after repair: %+v", report)
+	}
+
+	// simulate a restore with a missing declaration
+	if err = os.Remove(filepath.Join(dir, "declaration."+decl.Identifier+".json")); err != nil {
+		t.Fatal(err)
+	}
+	if report, err = Verify(ctx, store, false); err != nil {
+		t.Fatal(err)
+	}
+	if report.OK() {
+		t.Error("expected not OK")
+	}
+	if have, want := len(report.MissingDeclarations), 1; have != want {
+		t.Fatalf("missing declarations: have: %v, want: %v", have, want)
+	}
+	if have, want := report.MissingDeclarations[0], (SetDeclaration{Set: "set1", Declaration: decl.Identifier}); have != want {
+		t.Errorf("missing declaration: have: %v, want: %v", have, want)
+	}
+	if report.DDM != nil {
+		t.Error("expected no DDM report with missing declarations")
+	}
+}
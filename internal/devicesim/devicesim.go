@@ -0,0 +1,270 @@
+// Package devicesim simulates DDM-capable devices against a running KMFDDM server.
+// It fetches tokens, synchronizes declaration items, fetches declarations,
+// and sends synthetic status reports just as a device would. It is
+// intended for end-to-end tests and load generation.
+package devicesim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+)
+
+// EnrollmentIDHeader is the HTTP header the enrollment ID is sent in.
+const EnrollmentIDHeader = "X-Enrollment-ID"
+
+// Doer executes an HTTP request.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Device is a simulated DDM client for a single enrollment ID.
+// A Device is not safe for concurrent use.
+type Device struct {
+	logger       log.Logger
+	client       Doer
+	baseURL      *url.URL
+	enrollmentID string
+
+	// token is the declarations token of the last synchronization.
+	token string
+
+	// declarations are the synchronized declarations keyed by identifier.
+	declarations map[string]*ddm.Declaration
+
+	// manifestTypes are the manifest types of the declarations keyed by identifier.
+	manifestTypes map[string]string
+
+	statusFn StatusFunc
+}
+
+type Option func(*Device)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(d *Device) {
+		d.logger = logger
+	}
+}
+
+// WithStatusFunc sets the function that determines the reported
+// status of each declaration. By default all declarations are
+// reported as active and valid.
+func WithStatusFunc(fn StatusFunc) Option {
+	return func(d *Device) {
+		d.statusFn = fn
+	}
+}
+
+// WithClient sets the HTTP client used to make requests.
+func WithClient(client Doer) Option {
+	return func(d *Device) {
+		d.client = client
+	}
+}
+
+// New creates a new simulated device for enrollmentID. The device
+// talks to the DDM endpoints of the KMFDDM server at baseURL.
+func New(baseURL, enrollmentID string, opts ...Option) (*Device, error) {
+	if enrollmentID == "" {
+		return nil, fmt.Errorf("empty enrollment id")
+	}
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("parsing base URL: %w", err)
+	}
+	d := &Device{
+		logger:        log.NopLogger,
+		client:        http.DefaultClient,
+		baseURL:       u,
+		enrollmentID:  enrollmentID,
+		declarations:  make(map[string]*ddm.Declaration),
+		manifestTypes: make(map[string]string),
+		statusFn:      ValidStatus,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+// EnrollmentID returns the enrollment ID of d.
+func (d *Device) EnrollmentID() string {
+	return d.enrollmentID
+}
+
+// Declarations returns the declarations synchronized by d keyed by identifier.
+func (d *Device) Declarations() map[string]*ddm.Declaration {
+	return d.declarations
+}
+
+// do sends a request to the server at path and decodes any JSON response into v.
+func (d *Device) do(ctx context.Context, method, path string, body []byte, v interface{}) error {
+	u := *d.baseURL
+	u.Path += path
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(EnrollmentIDHeader, d.enrollmentID)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: HTTP status %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+	if v == nil {
+		return nil
+	}
+	switch v := v.(type) {
+	case *[]byte:
+		*v = respBody
+		return nil
+	default:
+		return json.Unmarshal(respBody, v)
+	}
+}
+
+// Tokens fetches the synchronization tokens.
+func (d *Device) Tokens(ctx context.Context) (*ddm.TokensResponse, error) {
+	tokens := new(ddm.TokensResponse)
+	return tokens, d.do(ctx, http.MethodGet, "/tokens", nil, tokens)
+}
+
+// DeclarationItems fetches the declaration items.
+func (d *Device) DeclarationItems(ctx context.Context) (*ddm.DeclarationItems, error) {
+	di := new(ddm.DeclarationItems)
+	return di, d.do(ctx, http.MethodGet, "/declaration-items", nil, di)
+}
+
+// Declaration fetches a single declaration of manifestType (e.g. "configuration").
+func (d *Device) Declaration(ctx context.Context, manifestType, declarationID string) (*ddm.Declaration, error) {
+	var raw []byte
+	if err := d.do(ctx, http.MethodGet, "/declaration/"+manifestType+"/"+url.PathEscape(declarationID), nil, &raw); err != nil {
+		return nil, err
+	}
+	return ddm.ParseDeclaration(raw)
+}
+
+// SendStatus sends the raw status report JSON.
+func (d *Device) SendStatus(ctx context.Context, report []byte) error {
+	return d.do(ctx, http.MethodPut, "/status", report, nil)
+}
+
+// SyncResult is the result of a single synchronization by a device.
+type SyncResult struct {
+	// Changed is true if the declarations token changed.
+	Changed bool
+
+	Token string
+
+	// Fetched are the identifiers of declarations fetched.
+	Fetched []string
+
+	// Removed are the identifiers of declarations no longer present.
+	Removed []string
+
+	// StatusSent is true if a status report was sent.
+	StatusSent bool
+}
+
+// manifestTypes maps the manifest declaration items to the singular
+// manifest types used in declaration paths.
+func manifestTypes(items *ddm.ManifestDeclarationItems) map[string][]ddm.ManifestDeclaration {
+	return map[string][]ddm.ManifestDeclaration{
+		"activation":    items.Activations,
+		"asset":         items.Assets,
+		"configuration": items.Configurations,
+		"management":    items.Management,
+	}
+}
+
+// Sync performs a DDM synchronization like a device does. The tokens
+// are fetched and if the declarations token changed then the declaration
+// items are fetched. Any new or changed declarations are then fetched
+// and a status report of all declarations is sent.
+func (d *Device) Sync(ctx context.Context) (*SyncResult, error) {
+	logger := ctxlog.Logger(ctx, d.logger).With("enrollment_id", d.enrollmentID)
+	tokens, err := d.Tokens(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching tokens: %w", err)
+	}
+	result := &SyncResult{Token: tokens.SyncTokens.DeclarationsToken}
+	if result.Token == d.token {
+		logger.Debug(logkeys.Message, "tokens unchanged", "token", result.Token)
+		return result, nil
+	}
+	result.Changed = true
+
+	di, err := d.DeclarationItems(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching declaration items: %w", err)
+	}
+
+	present := make(map[string]bool)
+	for manifestType, items := range manifestTypes(&di.Declarations) {
+		for _, item := range items {
+			present[item.Identifier] = true
+			if cur, ok := d.declarations[item.Identifier]; ok && cur.ServerToken == item.ServerToken {
+				continue
+			}
+			decl, err := d.Declaration(ctx, manifestType, item.Identifier)
+			if err != nil {
+				return nil, fmt.Errorf("fetching declaration %s: %w", item.Identifier, err)
+			}
+			if decl.ServerToken == "" {
+				decl.ServerToken = item.ServerToken
+			}
+			d.declarations[item.Identifier] = decl
+			d.manifestTypes[item.Identifier] = manifestType
+			result.Fetched = append(result.Fetched, item.Identifier)
+		}
+	}
+	for id := range d.declarations {
+		if !present[id] {
+			delete(d.declarations, id)
+			delete(d.manifestTypes, id)
+			result.Removed = append(result.Removed, id)
+		}
+	}
+
+	sort.Strings(result.Fetched)
+	sort.Strings(result.Removed)
+
+	report, err := d.StatusReport()
+	if err != nil {
+		return nil, fmt.Errorf("creating status report: %w", err)
+	}
+	if err = d.SendStatus(ctx, report); err != nil {
+		return nil, fmt.Errorf("sending status report: %w", err)
+	}
+	result.StatusSent = true
+	d.token = di.DeclarationsToken
+	logger.Debug(
+		logkeys.Message, "synchronized",
+		"token", d.token,
+		"fetched", len(result.Fetched),
+		"removed", len(result.Removed),
+	)
+	return result, nil
+}
@@ -0,0 +1,112 @@
+package devicesim
+
+import (
+	"context"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/alexedwards/flow"
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func newTestServer(t *testing.T, store *file.File) *httptest.Server {
+	mux := flow.New()
+	mux.Handle("/tokens", ddmhttp.TokensOrDeclarationItemsHandler(store, true, nil, log.NopLogger), "GET")
+	mux.Handle("/declaration-items", ddmhttp.TokensOrDeclarationItemsHandler(store, false, nil, log.NopLogger), "GET")
+	mux.Handle(
+		"/declaration/:type/:id",
+		http.StripPrefix("/declaration/", ddmhttp.DeclarationHandler(store, nil, log.NopLogger)),
+		"GET",
+	)
+	mux.Handle("/status", ddmhttp.StatusReportHandler(store, nil, log.NopLogger), "PUT")
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestDeviceSync(t *testing.T) {
+	store, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newTestServer(t, store)
+	ctx := context.Background()
+	const enrollmentID = "devicesim-test-device"
+
+	for _, raw := range []string{
+		`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"Foo"}}`,
+		`{"Type":"com.apple.activation.simple","Identifier":"com.example.act","Payload":{"StandardConfigurations":["com.example.test"]}}`,
+	} {
+		d, err := ddm.ParseDeclaration([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = store.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = store.StoreSetDeclaration(ctx, "devicesim", d.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, enrollmentID, "devicesim"); err != nil {
+		t.Fatal(err)
+	}
+
+	device, err := New(srv.URL, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := device.Sync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Changed || !result.StatusSent {
+		t.Errorf("expected changed sync with status: %+v", result)
+	}
+	if want := []string{"com.example.act", "com.example.test"}; !reflect.DeepEqual(result.Fetched, want) {
+		t.Errorf("fetched: have %v, want %v", result.Fetched, want)
+	}
+
+	// the server should have the status of our declarations
+	statuses, err := store.RetrieveDeclarationStatus(ctx, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := len(statuses[enrollmentID]); have != 2 {
+		t.Errorf("declaration statuses: have %d, want 2", have)
+	}
+	for _, s := range statuses[enrollmentID] {
+		if !s.Current {
+			t.Errorf("declaration status not current: %s", s.Identifier)
+		}
+	}
+
+	// nothing changed: no declaration items fetched
+	if result, err = device.Sync(ctx); err != nil {
+		t.Fatal(err)
+	} else if result.Changed {
+		t.Error("expected unchanged sync")
+	}
+
+	// remove a declaration from the set
+	if _, err = store.RemoveSetDeclaration(ctx, "devicesim", "com.example.act"); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = device.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"com.example.act"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Errorf("removed: have %v, want %v", result.Removed, want)
+	}
+	if len(result.Fetched) != 0 {
+		t.Errorf("fetched: have %v, want none", result.Fetched)
+	}
+}
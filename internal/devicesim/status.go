@@ -0,0 +1,88 @@
+package devicesim
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// Reason is a reason given for the status of a declaration.
+type Reason struct {
+	Code        string            `json:"code"`
+	Description string            `json:"description,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// Status is the synthetic status of a single declaration.
+type Status struct {
+	Active  bool
+	Valid   string
+	Reasons []Reason
+}
+
+// StatusFunc returns the status to report for declaration d.
+type StatusFunc func(d *ddm.Declaration) Status
+
+// ValidStatus reports all declarations as active and valid.
+func ValidStatus(_ *ddm.Declaration) Status {
+	return Status{Active: true, Valid: "valid"}
+}
+
+// declarationStatus is a declaration in a status report.
+type declarationStatus struct {
+	Active      bool     `json:"active"`
+	Identifier  string   `json:"identifier"`
+	Valid       string   `json:"valid"`
+	ServerToken string   `json:"server-token"`
+	Reasons     []Reason `json:"reasons,omitempty"`
+}
+
+// statusReport is the JSON structure of a full DDM status report.
+// See https://developer.apple.com/documentation/devicemanagement/statusreport
+type statusReport struct {
+	StatusItems struct {
+		Management struct {
+			Declarations map[string][]declarationStatus `json:"declarations"`
+		} `json:"management"`
+	}
+	Errors []interface{}
+}
+
+// StatusReport creates a full status report of the synchronized
+// declarations of d using its status function.
+func (d *Device) StatusReport() ([]byte, error) {
+	report := new(statusReport)
+	report.Errors = []interface{}{}
+	report.StatusItems.Management.Declarations = map[string][]declarationStatus{
+		"activations":    {},
+		"assets":         {},
+		"configurations": {},
+		"management":     {},
+	}
+	ids := make([]string, 0, len(d.declarations))
+	for id := range d.declarations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		decl := d.declarations[id]
+		status := d.statusFn(decl)
+		// status reports use the plural manifest type except for management
+		key := d.manifestTypes[id]
+		if key != "management" {
+			key += "s"
+		}
+		report.StatusItems.Management.Declarations[key] = append(
+			report.StatusItems.Management.Declarations[key],
+			declarationStatus{
+				Active:      status.Active,
+				Identifier:  id,
+				Valid:       status.Valid,
+				ServerToken: decl.ServerToken,
+				Reasons:     status.Reasons,
+			},
+		)
+	}
+	return json.Marshal(report)
+}
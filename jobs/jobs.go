@@ -0,0 +1,257 @@
+// Package jobs tracks the progress of long-running background work.
+//
+// Changes that notify many enrollments are notified in a background
+// job rather than within the API request. The job reports how many
+// enrollments have been notified and which failed and may be canceled.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// DefaultMaxJobs is the default number of finished jobs kept.
+const DefaultMaxJobs = 100
+
+// maxJobErrors is the maximum number of errors kept per job.
+const maxJobErrors = 20
+
+// ErrJobNotFound is returned when a job does not exist.
+var ErrJobNotFound = storage.Categorize(storage.ErrNotFound, errors.New("job not found"))
+
+// Job statuses.
+const (
+	StatusRunning  = "running"
+	StatusDone     = "done"
+	StatusCanceled = "canceled"
+)
+
+// Job is the progress of a background job.
+type Job struct {
+	ID          string `json:"id"`
+	Description string `json:"description,omitempty"`
+	Status      string `json:"status"`
+
+	// Total is the number of items the job processes.
+	Total int `json:"total"`
+
+	// Done is the number of items processed so far including failures.
+	Done int `json:"done"`
+
+	// Failed is the number of items that failed.
+	Failed int `json:"failed"`
+
+	// Errors are the first errors of failed items.
+	Errors []string `json:"errors,omitempty"`
+
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// job is a tracked job and the means to cancel it.
+type job struct {
+	Job
+	cancel context.CancelFunc
+}
+
+// Manager runs and tracks jobs. It is safe for concurrent use.
+type Manager struct {
+	logger log.Logger
+	max    int
+	chunk  int
+	now    func() time.Time
+
+	mu   sync.RWMutex
+	jobs map[string]*job
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(m *Manager) {
+		m.logger = logger
+	}
+}
+
+// WithMaxJobs keeps at most max finished jobs.
+// The oldest finished jobs are removed first.
+func WithMaxJobs(max int) Option {
+	return func(m *Manager) {
+		m.max = max
+	}
+}
+
+// WithChunkSize sets the number of items a job processes at once.
+func WithChunkSize(size int) Option {
+	return func(m *Manager) {
+		m.chunk = size
+	}
+}
+
+// New creates a new Manager.
+func New(opts ...Option) *Manager {
+	m := &Manager{
+		logger: log.NopLogger,
+		max:    DefaultMaxJobs,
+		chunk:  DefaultChunkSize,
+		now:    time.Now,
+		jobs:   make(map[string]*job),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// newJobID generates a random job ID.
+func newJobID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// ProcessFunc processes a chunk of items of a job.
+type ProcessFunc func(ctx context.Context, items []string) error
+
+// Start starts a new job that processes items in chunks with fn in the
+// background and returns its ID. The job stops early if canceled.
+func (m *Manager) Start(description string, items []string, fn ProcessFunc) string {
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		Job: Job{
+			ID:          newJobID(),
+			Description: description,
+			Status:      StatusRunning,
+			Total:       len(items),
+			Created:     m.now().UTC(),
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.prune()
+	m.jobs[j.ID] = j
+	m.mu.Unlock()
+
+	go m.run(ctx, j, items, fn)
+	return j.ID
+}
+
+// run processes the items of j in chunks.
+func (m *Manager) run(ctx context.Context, j *job, items []string, fn ProcessFunc) {
+	logger := m.logger.With("job", j.ID)
+	logger.Debug(logkeys.Message, "job started", "total", len(items))
+	defer j.cancel()
+	for i := 0; i < len(items); i += m.chunk {
+		if ctx.Err() != nil {
+			break
+		}
+		end := i + m.chunk
+		if end > len(items) {
+			end = len(items)
+		}
+		err := fn(ctx, items[i:end])
+		m.mu.Lock()
+		j.Done += end - i
+		if err != nil {
+			j.Failed += end - i
+			if len(j.Errors) < maxJobErrors {
+				j.Errors = append(j.Errors, err.Error())
+			}
+		}
+		m.mu.Unlock()
+		if err != nil {
+			logger.Info(logkeys.Message, "processing job chunk", logkeys.Error, err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if ctx.Err() != nil && j.Done < j.Total {
+		j.Status = StatusCanceled
+	} else {
+		j.Status = StatusDone
+	}
+	finished := m.now().UTC()
+	j.Finished = &finished
+	logger.Debug(logkeys.Message, "job finished", "status", j.Status, "done", j.Done, "failed", j.Failed)
+}
+
+// prune removes the oldest finished jobs past the maximum.
+// The caller must hold the lock.
+func (m *Manager) prune() {
+	if m.max < 1 {
+		return
+	}
+	var finished []*job
+	for _, j := range m.jobs {
+		if j.Finished != nil {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) < m.max {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].Finished.Before(*finished[j].Finished) })
+	for _, j := range finished[:len(finished)-m.max+1] {
+		delete(m.jobs, j.ID)
+	}
+}
+
+// copyJob returns a copy of the progress of j.
+// The caller must hold the lock.
+func copyJob(j *job) *Job {
+	c := j.Job
+	c.Errors = append([]string(nil), j.Errors...)
+	return &c
+}
+
+// Job retrieves the progress of the job id.
+func (m *Manager) Job(_ context.Context, id string) (*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, ErrJobNotFound
+	}
+	return copyJob(j), nil
+}
+
+// Jobs retrieves the progress of all jobs, most recent first.
+func (m *Manager) Jobs(_ context.Context) ([]*Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ret := make([]*Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		ret = append(ret, copyJob(j))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Created.After(ret[j].Created) })
+	return ret, nil
+}
+
+// Cancel cancels the job id. Items already processed are not undone.
+// Returns true if the job was running.
+func (m *Manager) Cancel(_ context.Context, id string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return false, ErrJobNotFound
+	}
+	if j.Status != StatusRunning {
+		return false, nil
+	}
+	j.cancel()
+	return true, nil
+}
@@ -0,0 +1,130 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testStore struct {
+	ids []string
+}
+
+func (s *testStore) RetrieveEnrollmentIDs(_ context.Context, _ []string, _ []string, ids []string) ([]string, error) {
+	return append(ids, s.ids...), nil
+}
+
+type testNotifier struct {
+	mu    sync.Mutex
+	calls [][]string
+	err   error
+
+	// entered is signaled and block is waited on before notifying
+	entered chan struct{}
+	block   chan struct{}
+}
+
+func (n *testNotifier) Changed(_ context.Context, _ []string, _ []string, ids []string) error {
+	if n.block != nil {
+		n.entered <- struct{}{}
+		<-n.block
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.calls = append(n.calls, ids)
+	return n.err
+}
+
+// wait waits for job id to finish.
+func wait(t *testing.T, m *Manager, id string) *Job {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		j, err := m.Job(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Finished != nil {
+			return j
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job did not finish")
+	return nil
+}
+
+func TestNotifier(t *testing.T) {
+	m := New(WithChunkSize(2))
+	store := &testStore{ids: []string{"E1", "E2", "E3", "E4", "E5"}}
+	next := &testNotifier{err: errors.New("test error")}
+	n := m.Notifier(next, store, 3)
+
+	// below the threshold notifies directly
+	if err := n.Changed(context.Background(), nil, nil, []string{"E6"}); err == nil {
+		t.Fatal("expected error")
+	}
+	next.err = nil
+
+	var id string
+	h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := n.Changed(r.Context(), nil, []string{"set"}, nil); err != nil {
+			t.Fatal(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/", nil))
+	if id = rec.Header().Get(JobIDHeader); id == "" {
+		t.Fatal("no job ID header")
+	}
+
+	j := wait(t, m, id)
+	if j.Status != StatusDone || j.Total != 5 || j.Done != 5 || j.Failed != 0 {
+		t.Errorf("unexpected job: %+v", j)
+	}
+	// the direct call and three chunks
+	if have, want := len(next.calls), 4; have != want {
+		t.Errorf("calls: have: %d, want: %d", have, want)
+	}
+
+	if _, err := m.Job(context.Background(), "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("expected not found error, have: %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	m := New(WithChunkSize(1))
+	next := &testNotifier{
+		entered: make(chan struct{}, 1),
+		block:   make(chan struct{}),
+		err:     errors.New("test error"),
+	}
+	id := m.Start("test", []string{"E1", "E2", "E3"}, func(ctx context.Context, ids []string) error {
+		return next.Changed(ctx, nil, nil, ids)
+	})
+
+	// cancel while the first chunk is in progress
+	<-next.entered
+	canceled, err := m.Cancel(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canceled {
+		t.Error("expected job to be canceled")
+	}
+	close(next.block)
+
+	j := wait(t, m, id)
+	if j.Status != StatusCanceled || j.Done != 1 || j.Failed != 1 || len(j.Errors) != 1 {
+		t.Errorf("unexpected job: %+v", j)
+	}
+
+	if canceled, err = m.Cancel(context.Background(), id); err != nil {
+		t.Fatal(err)
+	} else if canceled {
+		t.Error("expected finished job to not be canceled")
+	}
+}
@@ -0,0 +1,134 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/notifier"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// DefaultChunkSize is the default number of enrollments notified at once by a job.
+const DefaultChunkSize = 500
+
+// JobIDHeader is the HTTP response header of the ID of a job started by the request.
+const JobIDHeader = "X-Job-Id"
+
+// Notifier notifies enrollments of changes.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// jobNotifier notifies large changes in background jobs.
+type jobNotifier struct {
+	next      Notifier
+	store     storage.EnrollmentIDRetriever
+	jobs      *Manager
+	threshold int
+}
+
+// Changed notifies the enrollments affected by the changes. If at least
+// the threshold of enrollments are affected they are notified in a new
+// background job instead and the job ID is recorded in ctx.
+func (n *jobNotifier) Changed(ctx context.Context, declarations []string, sets []string, ids []string) error {
+	if len(declarations) < 1 && len(sets) < 1 && len(ids) < n.threshold {
+		return n.next.Changed(ctx, declarations, sets, ids)
+	}
+	resolved, err := n.store.RetrieveEnrollmentIDs(ctx, declarations, sets, ids)
+	if err != nil {
+		return err
+	}
+	if len(resolved) < n.threshold {
+		return n.next.Changed(ctx, declarations, sets, ids)
+	}
+	// the job notifies with the priority and urgency of the change
+	priority := notifier.PriorityFromContext(ctx)
+	nonUrgent := notifier.NonUrgentFromContext(ctx)
+	desc := fmt.Sprintf("notify %d enrollments", len(resolved))
+	// note the chunks are notified by enrollment ID only so the
+	// declaration and set notification counters are not incremented
+	id := n.jobs.Start(desc, resolved, func(ctx context.Context, ids []string) error {
+		ctx = notifier.WithPriority(ctx, priority)
+		if nonUrgent {
+			ctx = notifier.WithNonUrgent(ctx)
+		}
+		return n.next.Changed(ctx, nil, nil, ids)
+	})
+	ctxlog.Logger(ctx, n.jobs.logger).Debug(
+		logkeys.Message, "started notification job",
+		"job", id,
+		logkeys.GenericCount, len(resolved),
+	)
+	if s, ok := ctx.Value(startedKey{}).(*started); ok {
+		s.add(id)
+	}
+	return nil
+}
+
+// Notifier wraps next to notify changes affecting at least threshold
+// enrollments in background jobs. The enrollments are resolved using
+// store and notified with next in chunks of enrollment IDs.
+func (m *Manager) Notifier(next Notifier, store storage.EnrollmentIDRetriever, threshold int) Notifier {
+	if next == nil || store == nil {
+		panic("nil notifier or store")
+	}
+	return &jobNotifier{next: next, store: store, jobs: m, threshold: threshold}
+}
+
+type startedKey struct{}
+
+// started collects the IDs of the jobs started during a request.
+type started struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+func (s *started) add(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = append(s.ids, id)
+}
+
+func (s *started) get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ids
+}
+
+// jobResponseWriter sets the job ID header before the response is written.
+type jobResponseWriter struct {
+	http.ResponseWriter
+	started     *started
+	wroteHeader bool
+}
+
+func (w *jobResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, id := range w.started.get() {
+			w.Header().Add(JobIDHeader, id)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *jobResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Middleware sets the JobIDHeader response header to the ID of any
+// jobs started by the Notifier while handling the request.
+func (m *Manager) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := new(started)
+		ctx := context.WithValue(r.Context(), startedKey{}, s)
+		next.ServeHTTP(&jobResponseWriter{ResponseWriter: w, started: s}, r.WithContext(ctx))
+	})
+}
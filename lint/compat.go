@@ -0,0 +1,144 @@
+package lint
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Requirements are the minimum OS versions by platform (e.g. "macOS").
+// Platforms without a version are not checked.
+type Requirements map[string]string
+
+// Compatibility is a matrix of the minimum OS versions of declaration
+// types and of the top-level payload keys of declaration types.
+type Compatibility struct {
+	Types map[string]Requirements `json:"types,omitempty"`
+
+	// Keys are the requirements of payload keys by declaration type.
+	Keys map[string]map[string]Requirements `json:"keys,omitempty"`
+}
+
+//go:embed compat.json
+var bundledCompatJSON []byte
+
+// BundledCompatibility returns the bundled compatibility matrix.
+// It is not exhaustive: payload keys in particular are sparse.
+func BundledCompatibility() *Compatibility {
+	c := new(Compatibility)
+	if err := json.Unmarshal(bundledCompatJSON, c); err != nil {
+		panic(fmt.Sprintf("bundled compatibility: %v", err))
+	}
+	return c
+}
+
+// merge adds the requirements of o to c, replacing those of the same
+// declaration type or payload key.
+func (c *Compatibility) merge(o *Compatibility) {
+	if o == nil {
+		return
+	}
+	if c.Types == nil {
+		c.Types = make(map[string]Requirements)
+	}
+	for declType, reqs := range o.Types {
+		c.Types[declType] = reqs
+	}
+	if c.Keys == nil {
+		c.Keys = make(map[string]map[string]Requirements)
+	}
+	for declType, keys := range o.Keys {
+		if c.Keys[declType] == nil {
+			c.Keys[declType] = make(map[string]Requirements)
+		}
+		for key, reqs := range keys {
+			c.Keys[declType][key] = reqs
+		}
+	}
+}
+
+// validate checks that the versions of c parse.
+func (c *Compatibility) validate() error {
+	for declType, reqs := range c.Types {
+		if err := reqs.validate(); err != nil {
+			return fmt.Errorf("type %s: %w", declType, err)
+		}
+	}
+	for declType, keys := range c.Keys {
+		for key, reqs := range keys {
+			if err := reqs.validate(); err != nil {
+				return fmt.Errorf("type %s key %s: %w", declType, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r Requirements) validate() error {
+	for platform, version := range r {
+		if _, err := parseVersion(version); err != nil {
+			return fmt.Errorf("platform %s: %w", platform, err)
+		}
+	}
+	return nil
+}
+
+// unmet returns a message for each platform of targets whose version
+// is older than required by r.
+func (r Requirements) unmet(targets map[string]string) []string {
+	var msgs []string
+	for platform, target := range targets {
+		required, ok := r[platform]
+		if !ok {
+			continue
+		}
+		if compareVersions(target, required) < 0 {
+			msgs = append(msgs, fmt.Sprintf("requires %s %s (targets %s %s)", platform, required, platform, target))
+		}
+	}
+	return msgs
+}
+
+// parseVersion parses a dotted OS version (e.g. "14.2.1").
+func parseVersion(v string) ([]int, error) {
+	parts := strings.Split(v, ".")
+	ret := make([]int, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid version: %q", v)
+		}
+		ret[i] = n
+	}
+	return ret, nil
+}
+
+// compareVersions compares dotted OS versions a and b, returning -1,
+// 0, or 1. Missing components are zero. Unparseable versions are equal.
+func compareVersions(a, b string) int {
+	av, err := parseVersion(a)
+	if err != nil {
+		return 0
+	}
+	bv, err := parseVersion(b)
+	if err != nil {
+		return 0
+	}
+	for i := 0; i < len(av) || i < len(bv); i++ {
+		var x, y int
+		if i < len(av) {
+			x = av[i]
+		}
+		if i < len(bv) {
+			y = bv[i]
+		}
+		if x < y {
+			return -1
+		} else if x > y {
+			return 1
+		}
+	}
+	return 0
+}
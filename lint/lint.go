@@ -0,0 +1,208 @@
+// Package lint checks declarations against best-practice rules.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// Severity is the importance of a finding.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Valid reports whether s is a known severity.
+func (s Severity) Valid() bool {
+	switch s {
+	case SeverityError, SeverityWarning, SeverityInfo:
+		return true
+	}
+	return false
+}
+
+// Finding is a single rule violation.
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+
+	// Declaration is empty for findings that apply to all of the declarations linted.
+	Declaration string `json:"declaration,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// Report is the result of linting declarations.
+type Report struct {
+	Findings []Finding        `json:"findings"`
+	Counts   map[Severity]int `json:"counts"`
+}
+
+// HasErrors reports whether r contains error severity findings.
+func (r *Report) HasErrors() bool {
+	return r.Counts[SeverityError] > 0
+}
+
+// Config configures the rules of a Linter.
+type Config struct {
+	// Disabled are the names of rules that are not run.
+	Disabled []string `json:"disabled,omitempty"`
+
+	// Severity overrides the default severity of rules by rule name.
+	Severity map[string]Severity `json:"severity,omitempty"`
+
+	// DeprecatedKeys are the deprecated top-level payload keys by declaration type.
+	DeprecatedKeys map[string][]string `json:"deprecated_keys,omitempty"`
+
+	// MaxDeclarationSize is the size in bytes of declaration JSON
+	// above which declarations are reported by the declaration-size
+	// rule. DefaultMaxDeclarationSize is used if zero.
+	MaxDeclarationSize int `json:"max_declaration_size,omitempty"`
+
+	// Targets are the oldest OS versions by platform (e.g. "macOS")
+	// that declarations should be compatible with.
+	Targets map[string]string `json:"targets,omitempty"`
+
+	// Compatibility adds to or replaces the requirements of the
+	// bundled compatibility matrix.
+	Compatibility *Compatibility `json:"compatibility,omitempty"`
+}
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding lint config: %w", err)
+	}
+	for name, sev := range c.Severity {
+		if !sev.Valid() {
+			return nil, fmt.Errorf("invalid severity for rule %s: %s", name, sev)
+		}
+		if _, ok := ruleByName(name); !ok {
+			return nil, fmt.Errorf("unknown rule: %s", name)
+		}
+	}
+	for _, name := range c.Disabled {
+		if _, ok := ruleByName(name); !ok {
+			return nil, fmt.Errorf("unknown rule: %s", name)
+		}
+	}
+	if c.MaxDeclarationSize < 0 {
+		return nil, fmt.Errorf("invalid max declaration size: %d", c.MaxDeclarationSize)
+	}
+	if err := Requirements(c.Targets).validate(); err != nil {
+		return nil, fmt.Errorf("invalid targets: %w", err)
+	}
+	if c.Compatibility != nil {
+		if err := c.Compatibility.validate(); err != nil {
+			return nil, fmt.Errorf("invalid compatibility: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Linter checks declarations against a configured set of rules.
+type Linter struct {
+	config *Config
+	compat *Compatibility
+	rules  []*Rule
+}
+
+// New creates a new Linter using config.
+// A nil config uses all rules with their default severities.
+func New(config *Config) *Linter {
+	if config == nil {
+		config = new(Config)
+	}
+	l := &Linter{config: config, compat: BundledCompatibility()}
+	l.compat.merge(config.Compatibility)
+	disabled := make(map[string]bool)
+	for _, name := range config.Disabled {
+		disabled[name] = true
+	}
+	for _, r := range rules {
+		if !disabled[r.Name] {
+			l.rules = append(l.rules, r)
+		}
+	}
+	return l
+}
+
+// severity returns the configured severity of r.
+func (l *Linter) severity(r *Rule) Severity {
+	if sev, ok := l.config.Severity[r.Name]; ok {
+		return sev
+	}
+	return r.Severity
+}
+
+// Lint checks decls against the rules of l. References to declarations
+// are resolved against decls as well as known, if provided.
+func (l *Linter) Lint(decls []*ddm.Declaration, known map[string]bool) *Report {
+	return l.lint(decls, known, true)
+}
+
+// LintDeclaration checks d against the rules of l that apply to single
+// declarations. References to declarations are resolved against known.
+func (l *Linter) LintDeclaration(d *ddm.Declaration, known map[string]bool) *Report {
+	return l.lint([]*ddm.Declaration{d}, known, false)
+}
+
+func (l *Linter) lint(decls []*ddm.Declaration, known map[string]bool, collection bool) *Report {
+	c := &checkContext{
+		config:       l.config,
+		compat:       l.compat,
+		declarations: make(map[string]*ddm.Declaration, len(decls)),
+		known:        known,
+	}
+	for _, d := range decls {
+		c.declarations[d.Identifier] = d
+	}
+	decls = append([]*ddm.Declaration{}, decls...)
+	sort.Slice(decls, func(i, j int) bool { return decls[i].Identifier < decls[j].Identifier })
+
+	report := &Report{Findings: []Finding{}, Counts: make(map[Severity]int)}
+	add := func(r *Rule, declarationID, msg string) {
+		f := Finding{
+			Rule:        r.Name,
+			Severity:    l.severity(r),
+			Declaration: declarationID,
+			Message:     msg,
+		}
+		report.Findings = append(report.Findings, f)
+		report.Counts[f.Severity]++
+	}
+	for _, r := range l.rules {
+		if r.declaration != nil {
+			for _, d := range decls {
+				for _, msg := range r.declaration(c, d) {
+					add(r, d.Identifier, msg)
+				}
+			}
+		}
+		if collection && r.collection != nil {
+			for _, msg := range r.collection(c, decls) {
+				add(r, "", msg)
+			}
+		}
+	}
+	return report
+}
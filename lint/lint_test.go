@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+func parse(t *testing.T, s string) *ddm.Declaration {
+	t.Helper()
+	d, err := ddm.ParseDeclaration([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func rulesFound(r *Report) map[string]Severity {
+	found := make(map[string]Severity)
+	for _, f := range r.Findings {
+		found[f.Rule] = f.Severity
+	}
+	return found
+}
+
+func TestLint(t *testing.T) {
+	decls := []*ddm.Declaration{
+		parse(t, `{"Type":"com.apple.activation.simple","Identifier":"bad id","Payload":{"StandardConfigurations":["com.example.missing"]}}`),
+		parse(t, `{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"Foo","OldKey":true}}`),
+	}
+
+	config, err := ReadConfig(strings.NewReader(`{"deprecated_keys":{"com.apple.configuration.management.test":["OldKey"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := New(config).Lint(decls, nil)
+	found := rulesFound(report)
+	for _, rule := range []string{"identifier-naming", "deprecated-keys", "activation-predicate", "missing-reference", "status-subscriptions"} {
+		if _, ok := found[rule]; !ok {
+			t.Errorf("expected finding for rule %s", rule)
+		}
+	}
+	if _, ok := found["activation-empty"]; ok {
+		t.Error("unexpected activation-empty finding")
+	}
+	if !report.HasErrors() {
+		t.Error("expected errors")
+	}
+
+	// known references and disabled rules
+	config, err = ReadConfig(strings.NewReader(`{"disabled":["identifier-naming"],"severity":{"activation-predicate":"error"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report = New(config).LintDeclaration(decls[0], map[string]bool{"com.example.missing": true})
+	found = rulesFound(report)
+	if len(found) != 1 || found["activation-predicate"] != SeverityError {
+		t.Errorf("unexpected findings: %v", report.Findings)
+	}
+
+	if _, err = ReadConfig(strings.NewReader(`{"disabled":["no-such-rule"]}`)); err == nil {
+		t.Error("expected error for unknown rule")
+	}
+	if _, err = ReadConfig(strings.NewReader(`{"severity":{"identifier-naming":"fatal"}}`)); err == nil {
+		t.Error("expected error for invalid severity")
+	}
+}
+
+func TestCompatibility(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`{
+		"targets": {"macOS": "13.5", "iOS": "17.0"},
+		"compatibility": {"keys": {"com.apple.configuration.management.test": {"NewKey": {"iOS": "17.1"}}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(config)
+
+	for _, tc := range []struct {
+		decl string
+		want int
+	}{
+		{`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"Foo"}}`, 0},
+		{`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"NewKey":"Foo"}}`, 1},
+		{`{"Type":"com.apple.configuration.math.settings","Identifier":"com.example.math","Payload":{}}`, 2},
+		{`{"Type":"com.apple.configuration.screensharing.connection","Identifier":"com.example.ss","Payload":{}}`, 1},
+	} {
+		var have int
+		for _, f := range l.LintDeclaration(parse(t, tc.decl), nil).Findings {
+			if f.Rule == "os-compatibility" {
+				have++
+			}
+		}
+		if have != tc.want {
+			t.Errorf("%s: os-compatibility findings: have: %d, want: %d", tc.decl, have, tc.want)
+		}
+	}
+
+	if _, err = ReadConfig(strings.NewReader(`{"targets":{"macOS":"fourteen"}}`)); err == nil {
+		t.Error("expected error for invalid target version")
+	}
+}
+
+func TestDeclarationSize(t *testing.T) {
+	d := parse(t, `{"Type":"com.apple.configuration.management.test","Identifier":"com.example.test","Payload":{"Echo":"`+strings.Repeat("a", 100)+`"}}`)
+	if _, ok := rulesFound(New(nil).LintDeclaration(d, nil))["declaration-size"]; ok {
+		t.Error("unexpected declaration-size finding")
+	}
+	if _, ok := rulesFound(New(&Config{MaxDeclarationSize: 100}).LintDeclaration(d, nil))["declaration-size"]; !ok {
+		t.Error("expected declaration-size finding")
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"14.0", "14", 0},
+		{"13.5", "14.0", -1},
+		{"14.2.1", "14.2", 1},
+		{"10.15", "10.9", 1},
+	} {
+		if have := compareVersions(tc.a, tc.b); have != tc.want {
+			t.Errorf("compare %s %s: have: %d, want: %d", tc.a, tc.b, have, tc.want)
+		}
+	}
+}
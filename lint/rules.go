@@ -0,0 +1,219 @@
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+const (
+	activationSimpleType    = "com.apple.activation.simple"
+	statusSubscriptionsType = "com.apple.configuration.management.status-subscriptions"
+)
+
+// DefaultMaxDeclarationSize is the default size in bytes of declaration
+// JSON above which the declaration-size rule reports declarations.
+// Declarations this large are accepted but are impractical to sync to
+// devices, especially when many are served to an enrollment.
+const DefaultMaxDeclarationSize = 64 << 10
+
+// checkContext is the context available to rules.
+type checkContext struct {
+	config *Config
+	compat *Compatibility
+
+	// declarations are all of the declarations being linted.
+	declarations map[string]*ddm.Declaration
+
+	// known are identifiers of declarations that exist elsewhere (e.g. storage).
+	known map[string]bool
+}
+
+func (c *checkContext) exists(declarationID string) bool {
+	_, ok := c.declarations[declarationID]
+	return ok || c.known[declarationID]
+}
+
+// Rule is a named lint check.
+type Rule struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Severity    Severity `json:"severity"`
+
+	// declaration checks a single declaration.
+	declaration func(c *checkContext, d *ddm.Declaration) []string
+
+	// collection checks all declarations together.
+	collection func(c *checkContext, decls []*ddm.Declaration) []string
+}
+
+// reverseDNS matches reverse-DNS style identifiers (e.g. "com.example.test").
+var reverseDNS = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*(\.[A-Za-z0-9_-]+)+$`)
+
+// uuidIdentifier matches UUID identifiers.
+var uuidIdentifier = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+
+// payload decodes the top-level payload object of d.
+func payload(d *ddm.Declaration) map[string]interface{} {
+	var p map[string]interface{}
+	json.Unmarshal(d.PayloadJSON, &p)
+	return p
+}
+
+var rules = []*Rule{
+	{
+		Name:        "identifier-naming",
+		Description: "Declaration identifiers should be reverse-DNS style or UUIDs.",
+		Severity:    SeverityWarning,
+		declaration: func(_ *checkContext, d *ddm.Declaration) []string {
+			if reverseDNS.MatchString(d.Identifier) || uuidIdentifier.MatchString(d.Identifier) {
+				return nil
+			}
+			return []string{fmt.Sprintf("identifier %q is not reverse-DNS style or a UUID", d.Identifier)}
+		},
+	},
+	{
+		Name:        "deprecated-keys",
+		Description: "Payloads should not use keys configured as deprecated for the declaration type.",
+		Severity:    SeverityWarning,
+		declaration: func(c *checkContext, d *ddm.Declaration) []string {
+			keys := c.config.DeprecatedKeys[d.Type]
+			if len(keys) < 1 {
+				return nil
+			}
+			p := payload(d)
+			var msgs []string
+			for _, key := range keys {
+				if _, ok := p[key]; ok {
+					msgs = append(msgs, fmt.Sprintf("payload key %q is deprecated for type %s", key, d.Type))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		Name:        "activation-predicate",
+		Description: "Activations without a predicate apply unconditionally to every device they are assigned to.",
+		Severity:    SeverityInfo,
+		declaration: func(_ *checkContext, d *ddm.Declaration) []string {
+			if d.Type != activationSimpleType {
+				return nil
+			}
+			if pred, ok := payload(d)["Predicate"].(string); ok && strings.TrimSpace(pred) != "" {
+				return nil
+			}
+			return []string{"activation has no predicate"}
+		},
+	},
+	{
+		Name:        "activation-empty",
+		Description: "Activations should activate at least one configuration.",
+		Severity:    SeverityWarning,
+		declaration: func(_ *checkContext, d *ddm.Declaration) []string {
+			if d.Type != activationSimpleType {
+				return nil
+			}
+			if configs, ok := payload(d)["StandardConfigurations"].([]interface{}); ok && len(configs) > 0 {
+				return nil
+			}
+			return []string{"activation has no standard configurations"}
+		},
+	},
+	{
+		Name:        "missing-reference",
+		Description: "Declarations should only reference declarations that exist.",
+		Severity:    SeverityError,
+		declaration: func(c *checkContext, d *ddm.Declaration) []string {
+			var msgs []string
+			for _, ref := range d.IdentifierRefs {
+				if !c.exists(ref) {
+					msgs = append(msgs, fmt.Sprintf("referenced declaration %q does not exist", ref))
+				}
+			}
+			return msgs
+		},
+	},
+	{
+		Name:        "declaration-size",
+		Description: "Declarations should not exceed a practical size for devices.",
+		Severity:    SeverityWarning,
+		declaration: func(c *checkContext, d *ddm.Declaration) []string {
+			max := c.config.MaxDeclarationSize
+			if max < 1 {
+				max = DefaultMaxDeclarationSize
+			}
+			if len(d.Raw) <= max {
+				return nil
+			}
+			return []string{fmt.Sprintf("declaration is %d bytes which exceeds %d bytes", len(d.Raw), max)}
+		},
+	},
+	{
+		Name:        "os-compatibility",
+		Description: "Declaration types and payload keys should be supported by the targeted OS versions.",
+		Severity:    SeverityWarning,
+		declaration: func(c *checkContext, d *ddm.Declaration) []string {
+			if len(c.config.Targets) < 1 {
+				return nil
+			}
+			var msgs []string
+			for _, msg := range c.compat.Types[d.Type].unmet(c.config.Targets) {
+				msgs = append(msgs, fmt.Sprintf("type %s %s", d.Type, msg))
+			}
+			keys := c.compat.Keys[d.Type]
+			if len(keys) < 1 {
+				sort.Strings(msgs)
+				return msgs
+			}
+			for key := range payload(d) {
+				for _, msg := range keys[key].unmet(c.config.Targets) {
+					msgs = append(msgs, fmt.Sprintf("payload key %q %s", key, msg))
+				}
+			}
+			sort.Strings(msgs)
+			return msgs
+		},
+	},
+	{
+		Name:        "status-subscriptions",
+		Description: "Configurations should be accompanied by a status subscriptions configuration so that devices report status.",
+		Severity:    SeverityInfo,
+		collection: func(_ *checkContext, decls []*ddm.Declaration) []string {
+			var configs bool
+			for _, d := range decls {
+				if d.Type == statusSubscriptionsType {
+					return nil
+				}
+				if ddm.ManifestType(d.Type) == "configuration" {
+					configs = true
+				}
+			}
+			if !configs {
+				return nil
+			}
+			return []string{"no status subscriptions configuration found"}
+		},
+	},
+}
+
+func ruleByName(name string) (*Rule, bool) {
+	for _, r := range rules {
+		if r.Name == name {
+			return r, true
+		}
+	}
+	return nil, false
+}
+
+// Rules returns the available rules.
+func Rules() []Rule {
+	ret := make([]Rule, len(rules))
+	for i, r := range rules {
+		ret[i] = *r
+	}
+	return ret
+}
@@ -0,0 +1,156 @@
+// Package metering tallies the daily usage of sets by enrollments for
+// chargeback and multi-tenant reporting.
+package metering
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the storage used by a Meter.
+type Storage interface {
+	storage.EnrollmentSetsRetriever
+	storage.SetDeclarationsRetriever
+	storage.UsageStorage
+}
+
+// Metric is a metered usage.
+type Metric int
+
+const (
+	// Syncs are declaration items documents served to enrollments.
+	Syncs Metric = iota
+
+	// StatusReports are status reports received from enrollments.
+	StatusReports
+)
+
+// Meter tallies the usage of the sets of enrollments per day (in UTC).
+// The number of declarations of a set is recorded the first time the
+// set is used each day.
+type Meter struct {
+	store  Storage
+	logger log.Logger
+	now    func() time.Time
+
+	mu sync.Mutex
+	// sampled is the last day the declarations were recorded by set name.
+	sampled map[string]string
+}
+
+type Option func(*Meter)
+
+func WithLogger(logger log.Logger) Option {
+	return func(m *Meter) {
+		m.logger = logger
+	}
+}
+
+// New creates a new Meter.
+func New(store Storage, opts ...Option) *Meter {
+	if store == nil {
+		panic("nil store")
+	}
+	m := &Meter{
+		store:   store,
+		logger:  log.NopLogger,
+		now:     time.Now,
+		sampled: make(map[string]string),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// unsampled returns the setNames whose declarations have not yet been
+// recorded on day and marks them as recorded.
+func (m *Meter) unsampled(day string, setNames []string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ret []string
+	for _, setName := range setNames {
+		if m.sampled[setName] != day {
+			m.sampled[setName] = day
+			ret = append(ret, setName)
+		}
+	}
+	return ret
+}
+
+// Record tallies metric for the sets of enrollmentID.
+func (m *Meter) Record(ctx context.Context, enrollmentID string, metric Metric) error {
+	setNames, err := m.store.RetrieveEnrollmentSets(ctx, enrollmentID)
+	if err != nil || len(setNames) < 1 {
+		return err
+	}
+	day := m.now().UTC().Format(storage.UsageDayFormat)
+	var syncs, statusReports int64
+	switch metric {
+	case Syncs:
+		syncs = 1
+	case StatusReports:
+		statusReports = 1
+	}
+	if err = m.store.IncrementUsage(ctx, day, setNames, syncs, statusReports); err != nil {
+		return err
+	}
+	for _, setName := range m.unsampled(day, setNames) {
+		declarationIDs, err := m.store.RetrieveSetDeclarations(ctx, setName)
+		if err != nil {
+			return err
+		}
+		if err = m.store.StoreUsageDeclarations(ctx, day, setName, int64(len(declarationIDs))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statusResponseWriter captures the status written to it.
+type statusResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Handler tallies metric for the enrollments of the successful DDM
+// requests handled by next. Errors are logged but otherwise ignored.
+func (m *Meter) Handler(next http.Handler, metric Metric) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		enrollmentID := r.Header.Get(ddmhttp.EnrollmentIDHeader)
+		if enrollmentID == "" || sw.status >= 300 {
+			return
+		}
+		if err := m.Record(r.Context(), enrollmentID, metric); err != nil {
+			ctxlog.Logger(r.Context(), m.logger).Info(
+				logkeys.Message, "recording usage",
+				logkeys.EnrollmentID, enrollmentID,
+				logkeys.Error, err,
+			)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package metering
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	ddmhttp "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type testStore struct {
+	usage          map[[2]string]storage.UsageRecord
+	declRetrievals int
+}
+
+func (s *testStore) RetrieveEnrollmentSets(_ context.Context, enrollmentID string) ([]string, error) {
+	if enrollmentID == "none" {
+		return nil, nil
+	}
+	return []string{"set1", "set2"}, nil
+}
+
+func (s *testStore) RetrieveSetDeclarations(_ context.Context, setName string) ([]string, error) {
+	s.declRetrievals++
+	return []string{"decl1", "decl2"}, nil
+}
+
+func (s *testStore) IncrementUsage(_ context.Context, day string, setNames []string, syncs, statusReports int64) error {
+	for _, setName := range setNames {
+		u := s.usage[[2]string{day, setName}]
+		u.Syncs += syncs
+		u.StatusReports += statusReports
+		s.usage[[2]string{day, setName}] = u
+	}
+	return nil
+}
+
+func (s *testStore) StoreUsageDeclarations(_ context.Context, day, setName string, declarations int64) error {
+	u := s.usage[[2]string{day, setName}]
+	u.Declarations = declarations
+	s.usage[[2]string{day, setName}] = u
+	return nil
+}
+
+func (s *testStore) RetrieveUsage(_ context.Context, from, to string) ([]storage.UsageRecord, error) {
+	return nil, nil
+}
+
+func TestMeter(t *testing.T) {
+	store := &testStore{usage: make(map[[2]string]storage.UsageRecord)}
+	m := New(store)
+	now := time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC)
+	m.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for _, metric := range []Metric{Syncs, Syncs, StatusReports} {
+		if err := m.Record(ctx, "enrollment1", metric); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Record(ctx, "none", Syncs); err != nil {
+		t.Fatal(err)
+	}
+	want := storage.UsageRecord{Syncs: 2, StatusReports: 1, Declarations: 2}
+	for _, setName := range []string{"set1", "set2"} {
+		if have := store.usage[[2]string{"2024-01-02", setName}]; have != want {
+			t.Errorf("%s: have: %v, want: %v", setName, have, want)
+		}
+	}
+	// declarations are sampled once per set per day
+	if have, want := store.declRetrievals, 2; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if err := m.Record(ctx, "enrollment1", Syncs); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := store.usage[[2]string{"2024-01-03", "set1"}], (storage.UsageRecord{Syncs: 1, Declarations: 2}); have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := store.declRetrievals, 4; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	store := &testStore{usage: make(map[[2]string]storage.UsageRecord)}
+	m := New(store)
+	day := m.now().UTC().Format(storage.UsageDayFormat)
+
+	status := http.StatusOK
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}), StatusReports)
+
+	for _, s := range []int{http.StatusOK, http.StatusInternalServerError} {
+		status = s
+		r := httptest.NewRequest("PUT", "/status", nil)
+		r.Header.Set(ddmhttp.EnrollmentIDHeader, "enrollment1")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if have, want := store.usage[[2]string{day, "set1"}].StatusReports, int64(1); have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+}
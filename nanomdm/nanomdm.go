@@ -0,0 +1,116 @@
+// Package nanomdm adapts KMFDDM for in-process use by a co-deployed
+// NanoMDM server. Declarative Management check-in messages are handled
+// by calling the KMFDDM DDM protocol handlers directly and DM commands
+// are enqueued by calling into NanoMDM directly, instead of each making
+// HTTP requests to the other.
+//
+// The adapter is written against plain Go types so that KMFDDM does not
+// depend on NanoMDM. A NanoMDM service.DeclarativeManagement would be
+// implemented by something like:
+//
+//	func (d *DM) DeclarativeManagement(r *mdm.Request, m *mdm.DeclarativeManagement) ([]byte, error) {
+//		return d.svc.DeclarativeManagement(r.Context, r.ID, m.Endpoint, m.Data)
+//	}
+package nanomdm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/google/uuid"
+	httpddm "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/notifier"
+)
+
+// ErrEmptyEnrollmentID is returned when the enrollment ID is empty.
+var ErrEmptyEnrollmentID = errors.New("empty enrollment ID")
+
+// HTTPError is returned when the DDM protocol handler responds with a
+// non-successful HTTP status.
+type HTTPError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("DDM endpoint HTTP status: %d", e.StatusCode)
+}
+
+// Service handles Declarative Management check-in messages in-process.
+type Service struct {
+	handler http.Handler
+}
+
+// New creates a new Service that dispatches Declarative Management
+// endpoints to handler. Handler should route the DDM protocol paths
+// (i.e. "/tokens", "/declaration-items", "/declaration/", and
+// "/status") as the KMFDDM server does so that the same middleware
+// (e.g. metering and serve-time transformations) apply.
+func New(handler http.Handler) *Service {
+	if handler == nil {
+		panic("nil handler")
+	}
+	return &Service{handler: handler}
+}
+
+// DeclarativeManagement handles the Declarative Management check-in
+// message of enrollmentID for endpoint with data and returns the
+// response body. The "status" endpoint is sent data; all other
+// endpoints are retrieved.
+func (s *Service) DeclarativeManagement(ctx context.Context, enrollmentID, endpoint string, data []byte) ([]byte, error) {
+	if enrollmentID == "" {
+		return nil, ErrEmptyEnrollmentID
+	}
+	method := http.MethodGet
+	var body io.Reader = http.NoBody
+	if endpoint == "status" {
+		method = http.MethodPut
+		body = bytes.NewReader(data)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, "/"+strings.TrimPrefix(endpoint, "/"), body)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	r.Header.Set(httpddm.EnrollmentIDHeader, enrollmentID)
+	rec := httptest.NewRecorder()
+	s.handler.ServeHTTP(rec, r)
+	if rec.Code < 200 || rec.Code > 299 {
+		return nil, &HTTPError{StatusCode: rec.Code, Body: rec.Body.Bytes()}
+	}
+	return rec.Body.Bytes(), nil
+}
+
+// EnqueueFunc enqueues the raw plist MDM command with commandUUID to
+// ids and sends them APNs pushes. For NanoMDM this would typically
+// decode the command and call its storage and pusher directly.
+type EnqueueFunc func(ctx context.Context, ids []string, commandUUID string, rawCommand []byte) error
+
+// Enqueuer enqueues DeclarativeManagement commands in-process.
+// It implements the notifier Enqueuer interface.
+type Enqueuer struct {
+	enqueue EnqueueFunc
+}
+
+// NewEnqueuer creates a new Enqueuer that enqueues commands using enqueue.
+func NewEnqueuer(enqueue EnqueueFunc) *Enqueuer {
+	if enqueue == nil {
+		panic("nil enqueue function")
+	}
+	return &Enqueuer{enqueue: enqueue}
+}
+
+// EnqueueDMCommand enqueues a DeclarativeManagement command to ids optionally using tokensJSON.
+func (e *Enqueuer) EnqueueDMCommand(ctx context.Context, ids []string, tokensJSON []byte) error {
+	commandUUID := uuid.NewString()
+	cmdBytes, err := notifier.MakeCommand(commandUUID, tokensJSON)
+	if err != nil {
+		return fmt.Errorf("making command: %w", err)
+	}
+	return e.enqueue(ctx, ids, commandUUID, cmdBytes)
+}
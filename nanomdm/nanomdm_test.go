@@ -0,0 +1,92 @@
+package nanomdm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/groob/plist"
+	httpddm "github.com/jessepeterson/kmfddm/http/ddm"
+	"github.com/jessepeterson/kmfddm/notifier"
+)
+
+func TestDeclarativeManagement(t *testing.T) {
+	svc := New(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/status" {
+			if r.Method != http.MethodPut {
+				t.Errorf("have: %v, want: %v", r.Method, http.MethodPut)
+			}
+			b, _ := io.ReadAll(r.Body)
+			if have, want := string(b), "{}"; have != want {
+				t.Errorf("have: %v, want: %v", have, want)
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if r.URL.Path == "/declaration/configuration/missing" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(r.Header.Get(httpddm.EnrollmentIDHeader) + " " + r.Method + " " + r.URL.Path))
+	}))
+	ctx := context.Background()
+
+	for _, test := range []struct {
+		endpoint string
+		want     string
+	}{
+		{"tokens", "ABC GET /tokens"},
+		{"declaration-items", "ABC GET /declaration-items"},
+		{"declaration/configuration/com.example.test", "ABC GET /declaration/configuration/com.example.test"},
+		{"status", ""},
+	} {
+		var data []byte
+		if test.endpoint == "status" {
+			data = []byte("{}")
+		}
+		b, err := svc.DeclarativeManagement(ctx, "ABC", test.endpoint, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := string(b); have != test.want {
+			t.Errorf("have: %v, want: %v", have, test.want)
+		}
+	}
+
+	_, err := svc.DeclarativeManagement(ctx, "ABC", "declaration/configuration/missing", nil)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+		t.Errorf("have: %v, want: HTTP status %d", err, http.StatusNotFound)
+	}
+
+	if _, err = svc.DeclarativeManagement(ctx, "", "tokens", nil); !errors.Is(err, ErrEmptyEnrollmentID) {
+		t.Errorf("have: %v, want: %v", err, ErrEmptyEnrollmentID)
+	}
+}
+
+func TestEnqueuer(t *testing.T) {
+	var haveIDs []string
+	var haveCmd notifier.DeclarativeManagementCommand
+	e := NewEnqueuer(func(_ context.Context, ids []string, commandUUID string, rawCommand []byte) error {
+		haveIDs = ids
+		if err := plist.Unmarshal(rawCommand, &haveCmd); err != nil {
+			return err
+		}
+		if haveCmd.CommandUUID != commandUUID {
+			t.Errorf("have: %v, want: %v", haveCmd.CommandUUID, commandUUID)
+		}
+		return nil
+	})
+	if err := e.EnqueueDMCommand(context.Background(), []string{"ABC", "DEF"}, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ABC", "DEF"}; !reflect.DeepEqual(haveIDs, want) {
+		t.Errorf("have: %v, want: %v", haveIDs, want)
+	}
+	if haveCmd.Command.Data == nil || string(*haveCmd.Command.Data) != "{}" {
+		t.Errorf("missing tokens in command data")
+	}
+}
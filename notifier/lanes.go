@@ -0,0 +1,379 @@
+package notifier
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Priority is the delivery priority of a notification.
+type Priority int
+
+// Notification priorities.
+const (
+	PriorityNormal Priority = iota
+	PriorityHigh
+
+	numPriorities
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// ParsePriority parses the priority named s.
+// An empty s is the normal priority.
+func ParsePriority(s string) (Priority, error) {
+	switch s {
+	case "", "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return PriorityNormal, fmt.Errorf("invalid priority: %q", s)
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx with the notification priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the notification priority of ctx.
+// The normal priority is returned if ctx does not have one.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// PriorityMiddleware sets the notification priority of requests from
+// the "priority" query parameter (i.e. "high" or "normal").
+func PriorityMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := r.URL.Query().Get("priority"); s != "" {
+			p, err := ParsePriority(s)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(WithPriority(r.Context(), p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DefaultLaneBatchSize is the default maximum number of enrollments
+// in each queued command.
+const DefaultLaneBatchSize = 100
+
+// DefaultLaneMax is the default maximum number of queued commands per lane.
+const DefaultLaneMax = 10000
+
+// ErrLaneFull is returned when a lane has too many queued commands.
+var ErrLaneFull = errors.New("notification lane full")
+
+// laneCommand is a queued DM command.
+type laneCommand struct {
+	// id is the ID of the stored notification batch (if persisted).
+	id         string
+	ids        []string
+	tokensJSON []byte
+}
+
+// lane is the queue of commands of a priority.
+type lane struct {
+	queue []laneCommand
+
+	// rate is the maximum enrollments per second (0 for unlimited).
+	rate float64
+
+	// next is when the rate next allows sending.
+	next time.Time
+}
+
+// Lanes queues DM commands in lanes by the priority of their context
+// and enqueues them with an underlying Enqueuer. Queued commands of
+// higher priority lanes are enqueued before those of lower priority
+// lanes. Each lane may have its own rate limit.
+// Lanes implements Enqueuer itself and is safe for concurrent use.
+type Lanes struct {
+	next   Enqueuer
+	store  storage.NotificationQueueStorage
+	logger log.Logger
+	batch  int
+	max    int
+	now    func() time.Time
+
+	mu    sync.Mutex
+	lanes [numPriorities]lane
+	wake  chan struct{}
+}
+
+// LanesOption configures Lanes.
+type LanesOption func(*Lanes)
+
+// WithLanesLogger sets the logger.
+func WithLanesLogger(logger log.Logger) LanesOption {
+	return func(l *Lanes) {
+		l.logger = logger
+	}
+}
+
+// WithLanesStorage persists queued commands in store so that they
+// can be resumed after a restart with Resume.
+func WithLanesStorage(store storage.NotificationQueueStorage) LanesOption {
+	return func(l *Lanes) {
+		l.store = store
+	}
+}
+
+// WithLaneRate limits the lane of priority p to rate enrollments per second.
+// A zero rate is unlimited.
+func WithLaneRate(p Priority, rate float64) LanesOption {
+	return func(l *Lanes) {
+		if p >= 0 && p < numPriorities {
+			l.lanes[p].rate = rate
+		}
+	}
+}
+
+// WithLaneBatchSize splits queued commands into at most size enrollments.
+func WithLaneBatchSize(size int) LanesOption {
+	return func(l *Lanes) {
+		l.batch = size
+	}
+}
+
+// NewLanes creates new Lanes that enqueue commands using next.
+// Run must be called to enqueue the queued commands.
+func NewLanes(next Enqueuer, opts ...LanesOption) *Lanes {
+	if next == nil {
+		panic("nil enqueuer")
+	}
+	l := &Lanes{
+		next:   next,
+		logger: log.NopLogger,
+		batch:  DefaultLaneBatchSize,
+		max:    DefaultLaneMax,
+		now:    time.Now,
+		wake:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// newBatchID generates a random notification batch ID.
+func newBatchID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x", b)
+}
+
+// EnqueueDMCommand queues the DM command to ids in the lane of the
+// priority of ctx. Large numbers of ids are split into batches.
+// If configured the batches are persisted before they are queued.
+func (l *Lanes) EnqueueDMCommand(ctx context.Context, ids []string, tokensJSON []byte) error {
+	p := PriorityFromContext(ctx)
+	if p < 0 || p >= numPriorities {
+		return fmt.Errorf("invalid priority: %v", p)
+	}
+	var cmds []laneCommand
+	for i := 0; i < len(ids); i += l.batch {
+		end := i + l.batch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		cmds = append(cmds, laneCommand{ids: ids[i:end], tokensJSON: tokensJSON})
+	}
+
+	l.mu.Lock()
+	if l.max > 0 && len(l.lanes[p].queue)+len(cmds) > l.max {
+		l.mu.Unlock()
+		return fmt.Errorf("%w: %s: %d enrollments not queued", ErrLaneFull, p, len(ids))
+	}
+	l.mu.Unlock()
+
+	if l.store != nil {
+		now := l.now().UTC()
+		for i := range cmds {
+			cmds[i].id = newBatchID()
+			err := l.store.StoreNotificationBatch(ctx, &storage.NotificationBatch{
+				ID:            cmds[i].id,
+				Priority:      p.String(),
+				EnrollmentIDs: cmds[i].ids,
+				Timestamp:     now,
+			})
+			if err != nil {
+				return fmt.Errorf("storing notification batch: %w", err)
+			}
+		}
+	}
+
+	l.mu.Lock()
+	l.lanes[p].queue = append(l.lanes[p].queue, cmds...)
+	queued := len(l.lanes[p].queue)
+	l.mu.Unlock()
+
+	ctxlog.Logger(ctx, l.logger).Debug(
+		logkeys.Message, "queued command",
+		"priority", p.String(),
+		logkeys.GenericCount, len(ids),
+		"queued", queued,
+	)
+	l.signal()
+	return nil
+}
+
+// signal wakes Run to enqueue newly queued commands.
+func (l *Lanes) signal() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Resume queues the persisted notification batches of a previous run.
+// Batches deferred to a notification window are left to Windows.
+// Resumed batches are enqueued without tokens: the enrollments
+// retrieve their current tokens instead. Resume should be called
+// before any commands are queued.
+func (l *Lanes) Resume(ctx context.Context) (int, error) {
+	if l.store == nil {
+		return 0, nil
+	}
+	batches, err := l.store.RetrieveNotificationBatches(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving notification batches: %w", err)
+	}
+	var n int
+	l.mu.Lock()
+	for _, b := range batches {
+		if !b.NotBefore.IsZero() {
+			// deferred batches are resumed by Windows
+			continue
+		}
+		p, err := ParsePriority(b.Priority)
+		if err != nil {
+			ctxlog.Logger(ctx, l.logger).Info(
+				logkeys.Message, "resuming notification batch",
+				"batch", b.ID,
+				logkeys.Error, err,
+			)
+		}
+		l.lanes[p].queue = append(l.lanes[p].queue, laneCommand{id: b.ID, ids: b.EnrollmentIDs})
+		n++
+	}
+	l.mu.Unlock()
+	if n > 0 {
+		l.signal()
+	}
+	return n, nil
+}
+
+// dequeue returns the next command to enqueue from the highest
+// priority lane whose rate allows it. Otherwise it returns how long
+// to wait for a rate limited lane or zero if all lanes are empty.
+func (l *Lanes) dequeue() (*laneCommand, Priority, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var wait time.Duration
+	for p := numPriorities - 1; p >= 0; p-- {
+		lane := &l.lanes[p]
+		if len(lane.queue) < 1 {
+			continue
+		}
+		if d := lane.next.Sub(now); d > 0 {
+			if wait == 0 || d < wait {
+				wait = d
+			}
+			continue
+		}
+		cmd := lane.queue[0]
+		lane.queue = lane.queue[1:]
+		if lane.rate > 0 {
+			lane.next = now.Add(time.Duration(float64(len(cmd.ids)) / lane.rate * float64(time.Second)))
+		}
+		return &cmd, p, 0
+	}
+	return nil, 0, wait
+}
+
+// Queued returns the number of queued commands of priority p.
+func (l *Lanes) Queued(p Priority) int {
+	if p < 0 || p >= numPriorities {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.lanes[p].queue)
+}
+
+// Run enqueues queued commands until ctx is done.
+func (l *Lanes) Run(ctx context.Context) {
+	for {
+		cmd, p, wait := l.dequeue()
+		if cmd != nil {
+			if err := l.next.EnqueueDMCommand(ctx, cmd.ids, cmd.tokensJSON); err != nil {
+				ctxlog.Logger(ctx, l.logger).Info(
+					logkeys.Message, "enqueueing queued command",
+					"priority", p.String(),
+					logkeys.GenericCount, len(cmd.ids),
+					logkeys.FirstEnrollmentID, cmd.ids[0],
+					logkeys.Error, err,
+				)
+			}
+			if ctx.Err() != nil {
+				// keep the batch to resume it next run
+				return
+			}
+			// failed commands are not retried so the batch is done either way
+			if l.store != nil && cmd.id != "" {
+				if err := l.store.DeleteNotificationBatch(ctx, cmd.id); err != nil {
+					ctxlog.Logger(ctx, l.logger).Info(
+						logkeys.Message, "deleting notification batch",
+						"batch", cmd.id,
+						logkeys.Error, err,
+					)
+				}
+			}
+			continue
+		}
+		// wait for a newly queued command or a rate limited lane
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-l.wake:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
@@ -3,6 +3,8 @@ package notifier
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/groob/plist"
 	"github.com/jessepeterson/kmfddm/log"
@@ -29,6 +31,18 @@ type Notifier struct {
 	store      EnrollmentIDFinder
 	logger     log.Logger
 	sendTokens bool
+	counter    storage.CounterIncrementer
+	frozen     storage.FrozenEnrollmentsRetriever
+	inv        Invalidator
+	now        func() time.Time
+
+	throttleStore  storage.EnrollmentNotificationStorage
+	throttleWindow time.Duration
+}
+
+// Invalidator invalidates cached DDM affected by changes.
+type Invalidator interface {
+	Invalidate(ctx context.Context, declarations []string, sets []string, ids []string)
 }
 
 type Option func(n *Notifier)
@@ -39,6 +53,28 @@ func WithLogger(logger log.Logger) Option {
 	}
 }
 
+// WithCounters counts the enrollments notified using counter.
+func WithCounters(counter storage.CounterIncrementer) Option {
+	return func(n *Notifier) {
+		n.counter = counter
+	}
+}
+
+// WithFrozen skips notifying the enrollments that frozen reports are frozen.
+func WithFrozen(frozen storage.FrozenEnrollmentsRetriever) Option {
+	return func(n *Notifier) {
+		n.frozen = frozen
+	}
+}
+
+// WithInvalidator invalidates the changes with inv before notifying
+// so that notified enrollments are not served stale cached DDM.
+func WithInvalidator(inv Invalidator) Option {
+	return func(n *Notifier) {
+		n.inv = inv
+	}
+}
+
 func New(enqueuer Enqueuer, store EnrollmentIDFinder, opts ...Option) (*Notifier, error) {
 	if enqueuer == nil || store == nil {
 		panic("enqueuer nor store can be nil")
@@ -48,6 +84,7 @@ func New(enqueuer Enqueuer, store EnrollmentIDFinder, opts ...Option) (*Notifier
 		store:      store,
 		logger:     log.NopLogger,
 		sendTokens: true,
+		now:        time.Now,
 	}
 	for _, opt := range opts {
 		opt(n)
@@ -57,10 +94,24 @@ func New(enqueuer Enqueuer, store EnrollmentIDFinder, opts ...Option) (*Notifier
 
 // Change notifies (enqueues the DM command to) enrollments for which the changes apply to.
 func (n *Notifier) Changed(ctx context.Context, declarations []string, sets []string, idsIn []string) error {
+	if n.inv != nil {
+		n.inv.Invalidate(ctx, declarations, sets, idsIn)
+	}
 	ids, err := n.store.RetrieveEnrollmentIDs(ctx, declarations, sets, idsIn)
 	if err != nil {
 		return err
 	}
+	if n.frozen != nil && len(ids) > 0 {
+		if ids, err = n.unfrozen(ctx, ids); err != nil {
+			return err
+		}
+	}
+	var notifications []*storage.EnrollmentNotification
+	if n.throttleStore != nil && len(ids) > 0 {
+		if ids, notifications, err = n.throttle(ctx, ids); err != nil {
+			return err
+		}
+	}
 	if len(ids) < 1 {
 		ctxlog.Logger(ctx, n.logger).Debug(logkeys.Message, "no enrollments to notify")
 		return nil
@@ -88,7 +139,75 @@ func (n *Notifier) Changed(ctx context.Context, declarations []string, sets []st
 
 	// TODO: consider checking enqueuer for SupportsMultiCommands and
 	// sending individual EnqueueDMCommands (i.e.) n.sendTokens
-	return n.enqueuer.EnqueueDMCommand(ctx, ids, tokensJSON)
+	if err = n.enqueuer.EnqueueDMCommand(ctx, ids, tokensJSON); err != nil {
+		return err
+	}
+
+	if len(notifications) > 0 {
+		if err = n.throttleStore.StoreEnrollmentNotifications(ctx, notifications); err != nil {
+			ctxlog.Logger(ctx, n.logger).Info(logkeys.Message, "storing enrollment notifications", logkeys.Error, err)
+		}
+	}
+
+	if n.counter != nil {
+		n.count(ctx, declarations, sets, int64(len(ids)))
+	}
+	return nil
+}
+
+// unfrozen returns the enrollments of ids that are not frozen.
+func (n *Notifier) unfrozen(ctx context.Context, ids []string) ([]string, error) {
+	frozen, err := n.frozen.RetrieveFrozenEnrollmentIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving frozen enrollments: %w", err)
+	}
+	if len(frozen) < 1 {
+		return ids, nil
+	}
+	ctxlog.Logger(ctx, n.logger).Debug(
+		logkeys.Message, "skipping frozen enrollments",
+		logkeys.GenericCount, len(frozen),
+	)
+	skip := make(map[string]struct{}, len(frozen))
+	for _, id := range frozen {
+		skip[id] = struct{}{}
+	}
+	var ret []string
+	for _, id := range ids {
+		if _, ok := skip[id]; !ok {
+			ret = append(ret, id)
+		}
+	}
+	return ret, nil
+}
+
+// count increments the notification counters by delta.
+// Errors are logged but otherwise ignored.
+func (n *Notifier) count(ctx context.Context, declarations []string, sets []string, delta int64) {
+	incs := []storage.CounterIncrement{{
+		Scope: storage.CounterScopeGlobal,
+		Name:  storage.CounterNotifications,
+		Delta: delta,
+	}}
+	for _, d := range declarations {
+		incs = append(incs, storage.CounterIncrement{
+			Scope: storage.CounterScopeDeclaration,
+			ID:    d,
+			Name:  storage.CounterNotifications,
+			Delta: delta,
+		})
+	}
+	for _, s := range sets {
+		incs = append(incs, storage.CounterIncrement{
+			Scope: storage.CounterScopeSet,
+			ID:    s,
+			Name:  storage.CounterNotifications,
+			Delta: delta,
+		})
+	}
+	if err := n.counter.IncrementCounters(ctx, incs); err != nil {
+		ctxlog.Logger(ctx, n.logger).Info(logkeys.Message, "incrementing counters", logkeys.Error, err)
+	}
 }
 
 // MakeCommand returns a raw MDM command in plist form using uuid and optionally tokensJSON.
@@ -2,8 +2,13 @@ package notifier
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 type testEnqueuer struct {
@@ -57,3 +62,421 @@ func TestNotifier(t *testing.T) {
 		t.Error("tokens should not be present")
 	}
 }
+
+type testFrozen []string
+
+func (f testFrozen) RetrieveFrozenEnrollmentIDs(ctx context.Context, enrollmentIDs []string) ([]string, error) {
+	return f, nil
+}
+
+func TestNotifierFrozen(t *testing.T) {
+	e := new(testEnqueuer)
+	n, err := New(e, new(testStore), WithFrozen(testFrozen{"id2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Changed(context.Background(), nil, nil, []string{"id1", "id2", "id3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"id1", "id3"}, e.lastIDs) {
+		t.Errorf("have: %v, want: %v", e.lastIDs, []string{"id1", "id3"})
+	}
+	e.lastIDs = nil
+	err = n.Changed(context.Background(), nil, nil, []string{"id2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.lastIDs != nil {
+		t.Errorf("frozen enrollment notified: %v", e.lastIDs)
+	}
+}
+
+type testInvalidator struct {
+	sets []string
+}
+
+func (i *testInvalidator) Invalidate(_ context.Context, _ []string, sets []string, _ []string) {
+	i.sets = append(i.sets, sets...)
+}
+
+func TestNotifierInvalidator(t *testing.T) {
+	inv := new(testInvalidator)
+	n, err := New(new(testEnqueuer), new(testStore), WithInvalidator(inv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.Changed(context.Background(), nil, []string{"set1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"set1"}, inv.sets) {
+		t.Errorf("have: %v, want: %v", inv.sets, []string{"set1"})
+	}
+}
+
+type recordingEnqueuer struct {
+	mu   sync.Mutex
+	sent [][]string
+}
+
+func (e *recordingEnqueuer) EnqueueDMCommand(_ context.Context, ids []string, _ []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sent = append(e.sent, ids)
+	return nil
+}
+
+func TestLanes(t *testing.T) {
+	e := new(recordingEnqueuer)
+	l := NewLanes(e, WithLaneBatchSize(2), WithLaneRate(PriorityNormal, 1))
+	ctx := context.Background()
+
+	if err := l.EnqueueDMCommand(ctx, []string{"n1", "n2", "n3"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.EnqueueDMCommand(WithPriority(ctx, PriorityHigh), []string{"h1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := l.Queued(PriorityNormal), 2; have != want {
+		t.Errorf("queued: have: %d, want: %d", have, want)
+	}
+
+	// the high priority command jumps ahead and the rate limit of the
+	// normal lane holds back its second batch
+	cmd, p, _ := l.dequeue()
+	if cmd == nil || p != PriorityHigh || !reflect.DeepEqual(cmd.ids, []string{"h1"}) {
+		t.Fatalf("unexpected first command: %v %v", cmd, p)
+	}
+	cmd, p, _ = l.dequeue()
+	if cmd == nil || p != PriorityNormal || !reflect.DeepEqual(cmd.ids, []string{"n1", "n2"}) {
+		t.Fatalf("unexpected second command: %v %v", cmd, p)
+	}
+	cmd, _, wait := l.dequeue()
+	if cmd != nil || wait <= 0 {
+		t.Errorf("expected to wait for rate limit: %v %v", cmd, wait)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	l.now = func() time.Time { return time.Now().Add(time.Hour) }
+	go l.Run(runCtx)
+	var sent [][]string
+	for i := 0; i < 100 && len(sent) < 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+		e.mu.Lock()
+		sent = e.sent
+		e.mu.Unlock()
+	}
+	if !reflect.DeepEqual(sent, [][]string{{"n3"}}) {
+		t.Errorf("sent: have: %v", sent)
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for s, want := range map[string]Priority{"": PriorityNormal, "normal": PriorityNormal, "high": PriorityHigh} {
+		if have, err := ParsePriority(s); err != nil || have != want {
+			t.Errorf("%q: have: %v (%v), want: %v", s, have, err, want)
+		}
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type testQueueStore struct {
+	mu      sync.Mutex
+	batches []*storage.NotificationBatch
+}
+
+func (s *testQueueStore) StoreNotificationBatch(_ context.Context, batch *storage.NotificationBatch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *testQueueStore) RetrieveNotificationBatches(_ context.Context) ([]*storage.NotificationBatch, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*storage.NotificationBatch(nil), s.batches...), nil
+}
+
+func (s *testQueueStore) DeleteNotificationBatch(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.batches {
+		if b.ID == id {
+			s.batches = append(s.batches[:i], s.batches[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func TestLanesResume(t *testing.T) {
+	store := new(testQueueStore)
+	ctx := context.Background()
+
+	// queued but never run
+	l := NewLanes(new(recordingEnqueuer), WithLanesStorage(store), WithLaneBatchSize(2))
+	if err := l.EnqueueDMCommand(ctx, []string{"n1", "n2", "n3"}, []byte("tokens")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.EnqueueDMCommand(WithPriority(ctx, PriorityHigh), []string{"h1"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(store.batches), 3; have != want {
+		t.Fatalf("stored batches: have: %d, want: %d", have, want)
+	}
+
+	// a new run resumes the stored batches
+	e := new(recordingEnqueuer)
+	l = NewLanes(e, WithLanesStorage(store))
+	n, err := l.Resume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("resumed: have: %d, want: 3", n)
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go l.Run(runCtx)
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if batches, _ := store.RetrieveNotificationBatches(ctx); len(batches) == 0 {
+			break
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if want := [][]string{{"h1"}, {"n1", "n2"}, {"n3"}}; !reflect.DeepEqual(e.sent, want) {
+		t.Errorf("sent: have: %v, want: %v", e.sent, want)
+	}
+}
+
+type testNotificationStore map[string]*storage.EnrollmentNotification
+
+func (s testNotificationStore) RetrieveEnrollmentNotifications(_ context.Context, ids []string) (map[string]*storage.EnrollmentNotification, error) {
+	ret := make(map[string]*storage.EnrollmentNotification)
+	for _, id := range ids {
+		if n, ok := s[id]; ok {
+			ret[id] = n
+		}
+	}
+	return ret, nil
+}
+
+func (s testNotificationStore) StoreEnrollmentNotifications(_ context.Context, notifications []*storage.EnrollmentNotification) error {
+	for _, n := range notifications {
+		s[n.EnrollmentID] = n
+	}
+	return nil
+}
+
+func TestNotifierThrottle(t *testing.T) {
+	e := new(testEnqueuer)
+	s := &testStore{tokens: []byte("a")}
+	n, err := New(e, s, WithThrottle(make(testNotificationStore), time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	n.now = func() time.Time { return now }
+
+	notify := func(want []string) {
+		t.Helper()
+		e.lastIDs = nil
+		if err := n.Changed(context.Background(), nil, nil, []string{"id1", "id2"}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.lastIDs, want) {
+			t.Errorf("have: %v, want: %v", e.lastIDs, want)
+		}
+	}
+
+	notify([]string{"id1", "id2"})
+
+	// unchanged tokens within the window are skipped
+	notify(nil)
+
+	// changed tokens are notified
+	s.tokens = []byte("b")
+	notify([]string{"id1", "id2"})
+
+	// unchanged tokens after the window are notified
+	now = now.Add(2 * time.Minute)
+	notify([]string{"id1", "id2"})
+
+	// unthrottled notifications are always sent
+	e.lastIDs = nil
+	if err := n.Changed(WithoutThrottle(context.Background()), nil, nil, []string{"id1"}); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := e.lastIDs, []string{"id1"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("unthrottled: have: %v, want: %v", have, want)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	for _, s := range []string{"", "1:00-5:00", "01:00-01:00", "25:00-05:00", "01:00+05:00"} {
+		if _, err := ParseWindow(s); err == nil {
+			t.Errorf("expected error for window: %q", s)
+		}
+	}
+	w, err := ParseWindow("22:00-06:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := w.String(), "22:00-06:00"; have != want {
+		t.Errorf("string: have: %s, want: %s", have, want)
+	}
+	day := func(h, m int) time.Time { return time.Date(2024, 1, 2, h, m, 0, 0, time.UTC) }
+	for _, tc := range []struct {
+		t    time.Time
+		next time.Time
+	}{
+		{day(23, 0), day(23, 0)},
+		{day(5, 59), day(5, 59)},
+		{day(6, 0), day(22, 0)},
+		{day(12, 0), day(22, 0)},
+	} {
+		if have := w.Next(tc.t); !have.Equal(tc.next) {
+			t.Errorf("next of %v: have: %v, want: %v", tc.t, have, tc.next)
+		}
+	}
+	if w, err = ParseWindow("01:00-05:00"); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := w.Next(day(12, 0)), time.Date(2024, 1, 3, 1, 0, 0, 0, time.UTC); !have.Equal(want) {
+		t.Errorf("next: have: %v, want: %v", have, want)
+	}
+}
+
+type testRegistrations map[string]string
+
+func (r testRegistrations) RetrieveEnrollmentRegistration(_ context.Context, enrollmentID string) (*storage.EnrollmentRegistration, error) {
+	zone, ok := r[enrollmentID]
+	if !ok {
+		return nil, storage.ErrEnrollmentRegistrationNotFound
+	}
+	return &storage.EnrollmentRegistration{
+		EnrollmentID: enrollmentID,
+		Metadata:     map[string]string{DefaultZoneKey: zone},
+	}, nil
+}
+
+func TestWindows(t *testing.T) {
+	window, err := ParseWindow("01:00-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := new(recordingEnqueuer)
+	regs := testRegistrations{"utc": "UTC", "invalid": "Not/A_Zone"}
+	// unregistered enrollments are 14 hours ahead: 02:00 local time
+	w := NewWindows(e, regs, window, WithDefaultLocation(time.FixedZone("+14", 14*60*60)))
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// urgent notifications are never deferred
+	if err = w.EnqueueDMCommand(ctx, []string{"utc"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.EnqueueDMCommand(WithNonUrgent(ctx), []string{"utc", "unregistered", "invalid"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"utc"}, {"unregistered", "invalid"}}; !reflect.DeepEqual(e.sent, want) {
+		t.Errorf("sent: have: %v, want: %v", e.sent, want)
+	}
+	if have, want := w.Deferred(), 1; have != want {
+		t.Fatalf("deferred: have: %d, want: %d", have, want)
+	}
+
+	if due, wait := w.due(); len(due) > 0 || wait != 13*time.Hour {
+		t.Errorf("due: have: %v %v, want: none 13h", due, wait)
+	}
+	now = now.Add(13 * time.Hour)
+	if due, _ := w.due(); len(due) != 1 || due["utc"] == nil {
+		t.Errorf("due: have: %v, want: utc", due)
+	}
+	if have, want := w.Deferred(), 0; have != want {
+		t.Errorf("deferred: have: %d, want: %d", have, want)
+	}
+}
+
+type priorityEnqueuer struct {
+	err        error
+	sent       [][]string
+	priorities []Priority
+}
+
+func (e *priorityEnqueuer) EnqueueDMCommand(ctx context.Context, ids []string, _ []byte) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.sent = append(e.sent, ids)
+	e.priorities = append(e.priorities, PriorityFromContext(ctx))
+	return nil
+}
+
+func TestWindowsResume(t *testing.T) {
+	window, err := ParseWindow("01:00-05:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	regs := testRegistrations{"utc": "UTC"}
+	now := time.Date(2024, 1, 2, 12, 0, 0, 0, time.UTC)
+	ctx := context.Background()
+
+	// a batch queued in lanes is not resumed by windows
+	store := &testQueueStore{batches: []*storage.NotificationBatch{
+		{ID: "lanes", EnrollmentIDs: []string{"other"}, Timestamp: now},
+	}}
+
+	// deferred but never run
+	w := NewWindows(new(priorityEnqueuer), regs, window, WithWindowsStorage(store))
+	w.now = func() time.Time { return now }
+	if err = w.EnqueueDMCommand(WithPriority(WithNonUrgent(ctx), PriorityHigh), []string{"utc"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(store.batches), 2; have != want {
+		t.Fatalf("stored batches: have: %d, want: %d", have, want)
+	}
+
+	// a new run resumes the deferred batch
+	e := &priorityEnqueuer{err: errors.New("enqueue failed")}
+	w = NewWindows(e, regs, window, WithWindowsStorage(store))
+	w.now = func() time.Time { return now }
+	n, err := w.Resume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || w.Deferred() != 1 {
+		t.Fatalf("resumed: have: %d %d, want: 1 1", n, w.Deferred())
+	}
+
+	// failed enqueues are retried
+	now = now.Add(13 * time.Hour)
+	due, _ := w.due()
+	w.enqueueDue(ctx, due)
+	if have, want := w.Deferred(), 1; have != want {
+		t.Fatalf("deferred after failure: have: %d, want: %d", have, want)
+	}
+	if have, want := len(store.batches), 2; have != want {
+		t.Errorf("stored batches after failure: have: %d, want: %d", have, want)
+	}
+	e.err = nil
+	now = now.Add(windowsRetryInterval)
+	due, _ = w.due()
+	w.enqueueDue(ctx, due)
+	if want := [][]string{{"utc"}}; !reflect.DeepEqual(e.sent, want) {
+		t.Errorf("sent: have: %v, want: %v", e.sent, want)
+	}
+	if want := []Priority{PriorityHigh}; !reflect.DeepEqual(e.priorities, want) {
+		t.Errorf("priorities: have: %v, want: %v", e.priorities, want)
+	}
+	if len(store.batches) != 1 || store.batches[0].ID != "lanes" {
+		t.Errorf("stored batches: have: %v, want: lanes", store.batches)
+	}
+}
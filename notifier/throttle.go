@@ -0,0 +1,76 @@
+package notifier
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// WithThrottle skips notifying enrollments that were already notified
+// within window if their tokens have not changed since. This avoids
+// redundant pushes to enrollments during rapid successive changes.
+// When enrollments were notified is stored in store.
+func WithThrottle(store storage.EnrollmentNotificationStorage, window time.Duration) Option {
+	return func(n *Notifier) {
+		n.throttleStore = store
+		n.throttleWindow = window
+	}
+}
+
+type unthrottledKey struct{}
+
+// WithoutThrottle returns a copy of ctx whose notifications are sent
+// to enrollments even if they would be skipped by WithThrottle.
+// When the enrollments were notified is still stored.
+func WithoutThrottle(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unthrottledKey{}, true)
+}
+
+// tokensHash returns the hash of tokensJSON.
+func tokensHash(tokensJSON []byte) string {
+	sum := sha256.Sum256(tokensJSON)
+	return hex.EncodeToString(sum[:])
+}
+
+// throttle returns the enrollments of ids to notify and when they
+// are notified. Enrollments notified within the throttle window whose
+// tokens have not changed since are skipped.
+func (n *Notifier) throttle(ctx context.Context, ids []string) ([]string, []*storage.EnrollmentNotification, error) {
+	last, err := n.throttleStore.RetrieveEnrollmentNotifications(ctx, ids)
+	if err != nil {
+		return nil, nil, fmt.Errorf("retrieving enrollment notifications: %w", err)
+	}
+	now := n.now().UTC()
+	unthrottled, _ := ctx.Value(unthrottledKey{}).(bool)
+	var ret []string
+	var notifications []*storage.EnrollmentNotification
+	for _, id := range ids {
+		tokensJSON, err := n.store.RetrieveTokensJSON(ctx, id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("retrieving tokens of %s: %w", id, err)
+		}
+		hash := tokensHash(tokensJSON)
+		if l, ok := last[id]; ok && !unthrottled && l.TokensHash == hash && now.Sub(l.Timestamp) < n.throttleWindow {
+			continue
+		}
+		ret = append(ret, id)
+		notifications = append(notifications, &storage.EnrollmentNotification{
+			EnrollmentID: id,
+			TokensHash:   hash,
+			Timestamp:    now,
+		})
+	}
+	if skipped := len(ids) - len(ret); skipped > 0 {
+		ctxlog.Logger(ctx, n.logger).Debug(
+			logkeys.Message, "skipping recently notified enrollments",
+			logkeys.GenericCount, skipped,
+		)
+	}
+	return ret, notifications, nil
+}
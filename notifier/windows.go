@@ -0,0 +1,551 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type nonUrgentKey struct{}
+
+// WithNonUrgent returns a copy of ctx whose notifications are not
+// urgent. Non-urgent notifications may be deferred by Windows.
+func WithNonUrgent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, nonUrgentKey{}, true)
+}
+
+// NonUrgentFromContext reports whether the notifications of ctx are not urgent.
+func NonUrgentFromContext(ctx context.Context) bool {
+	nonUrgent, _ := ctx.Value(nonUrgentKey{}).(bool)
+	return nonUrgent
+}
+
+// UrgencyMiddleware marks the notifications of requests with an
+// "urgent" query parameter of false as not urgent.
+func UrgencyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := r.URL.Query().Get("urgent"); s != "" {
+			urgent, err := strconv.ParseBool(s)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("invalid urgent: %q", s), http.StatusBadRequest)
+				return
+			}
+			if !urgent {
+				r = r.WithContext(WithNonUrgent(r.Context()))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Window is a daily window of the time of day. A window whose end is
+// before its start spans midnight.
+type Window struct {
+	// start and end are minutes since midnight.
+	start, end int
+}
+
+// parseTimeOfDay parses s ("HH:MM") into minutes since midnight.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWindow parses a window of the form "HH:MM-HH:MM" (e.g. "01:00-05:00").
+func ParseWindow(s string) (*Window, error) {
+	if len(s) != 11 || s[5] != '-' {
+		return nil, fmt.Errorf("invalid window: %q", s)
+	}
+	start, err := parseTimeOfDay(s[:5])
+	if err != nil {
+		return nil, fmt.Errorf("invalid window start: %w", err)
+	}
+	end, err := parseTimeOfDay(s[6:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid window end: %w", err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("empty window: %q", s)
+	}
+	return &Window{start: start, end: end}, nil
+}
+
+func (w *Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.start/60, w.start%60, w.end/60, w.end%60)
+}
+
+// Contains reports whether the time of day of t (in its location) is within w.
+func (w *Window) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return m >= w.start && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// Next returns when w next opens at or after t in the location of t.
+// If t is within w then t is returned.
+func (w *Window) Next(t time.Time) time.Time {
+	if w.Contains(t) {
+		return t
+	}
+	y, m, d := t.Date()
+	open := time.Date(y, m, d, w.start/60, w.start%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = time.Date(y, m, d+1, w.start/60, w.start%60, 0, 0, t.Location())
+	}
+	return open
+}
+
+// DefaultZoneKey is the default enrollment registration metadata key
+// of the IANA time zone (e.g. "America/New_York") of an enrollment.
+const DefaultZoneKey = "time_zone"
+
+// RegistrationRetriever retrieves the registrations of enrollments.
+type RegistrationRetriever interface {
+	RetrieveEnrollmentRegistration(ctx context.Context, enrollmentID string) (*storage.EnrollmentRegistration, error)
+}
+
+// windowsRetryInterval is how long Windows waits to retry enqueueing
+// deferred commands that failed.
+const windowsRetryInterval = time.Minute
+
+// deferral is a deferred enrollment.
+type deferral struct {
+	// open is when the window of the enrollment opens.
+	open time.Time
+
+	// priority is the highest notification priority the enrollment was deferred with.
+	priority Priority
+
+	// batches are the IDs of the stored notification batches of the enrollment (if persisted).
+	batches []string
+}
+
+// merge merges o into d keeping the earliest open and highest priority.
+func (d *deferral) merge(o *deferral) {
+	if o.open.Before(d.open) {
+		d.open = o.open
+	}
+	if o.priority > d.priority {
+		d.priority = o.priority
+	}
+	d.batches = append(d.batches, o.batches...)
+}
+
+// Windows defers non-urgent DM commands to enrollments until a daily
+// window opens in the local time zone of each enrollment. The time
+// zone of an enrollment is the value of a metadata key of its
+// registration. Urgent DM commands and those to enrollments already
+// within the window are enqueued immediately. Enrollments deferred
+// more than once are notified once when their window opens with the
+// highest priority they were deferred with. If configured the deferred
+// enrollments are persisted so that they can be resumed after a restart.
+// Windows implements Enqueuer itself and is safe for concurrent use.
+type Windows struct {
+	next   Enqueuer
+	store  RegistrationRetriever
+	queue  storage.NotificationQueueStorage
+	window *Window
+	key    string
+	loc    *time.Location
+	logger log.Logger
+	now    func() time.Time
+
+	mu       sync.Mutex
+	deferred map[string]*deferral
+	// batches counts the deferred enrollments of stored notification batches.
+	batches map[string]int
+	locs    map[string]*time.Location
+	wake    chan struct{}
+}
+
+// WindowsOption configures Windows.
+type WindowsOption func(*Windows)
+
+// WithWindowsLogger sets the logger.
+func WithWindowsLogger(logger log.Logger) WindowsOption {
+	return func(w *Windows) {
+		w.logger = logger
+	}
+}
+
+// WithWindowsStorage persists deferred enrollments in store as
+// notification batches so that they can be resumed after a restart
+// with Resume.
+func WithWindowsStorage(store storage.NotificationQueueStorage) WindowsOption {
+	return func(w *Windows) {
+		w.queue = store
+	}
+}
+
+// WithZoneKey sets the enrollment registration metadata key of the
+// time zone of enrollments. The default is DefaultZoneKey.
+func WithZoneKey(key string) WindowsOption {
+	return func(w *Windows) {
+		w.key = key
+	}
+}
+
+// WithDefaultLocation sets the time zone of enrollments without a
+// (valid) time zone. By default these enrollments are not deferred.
+func WithDefaultLocation(loc *time.Location) WindowsOption {
+	return func(w *Windows) {
+		w.loc = loc
+	}
+}
+
+// NewWindows creates new Windows that enqueue commands using next
+// within window. Store retrieves the registrations of enrollments.
+// Run must be called to enqueue the deferred commands.
+func NewWindows(next Enqueuer, store RegistrationRetriever, window *Window, opts ...WindowsOption) *Windows {
+	if next == nil || store == nil || window == nil {
+		panic("nil enqueuer, store, or window")
+	}
+	w := &Windows{
+		next:     next,
+		store:    store,
+		window:   window,
+		key:      DefaultZoneKey,
+		logger:   log.NopLogger,
+		now:      time.Now,
+		deferred: make(map[string]*deferral),
+		batches:  make(map[string]int),
+		locs:     make(map[string]*time.Location),
+		wake:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// location returns the time zone of enrollmentID.
+// A nil location is returned if the time zone is not known.
+func (w *Windows) location(ctx context.Context, enrollmentID string) (*time.Location, error) {
+	reg, err := w.store.RetrieveEnrollmentRegistration(ctx, enrollmentID)
+	if errors.Is(err, storage.ErrEnrollmentRegistrationNotFound) {
+		return w.loc, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("retrieving registration of %s: %w", enrollmentID, err)
+	}
+	name := reg.Metadata[w.key]
+	if name == "" {
+		return w.loc, nil
+	}
+	w.mu.Lock()
+	loc, ok := w.locs[name]
+	w.mu.Unlock()
+	if ok {
+		return loc, nil
+	}
+	if loc, err = time.LoadLocation(name); err != nil {
+		ctxlog.Logger(ctx, w.logger).Info(
+			logkeys.Message, "loading time zone",
+			logkeys.EnrollmentID, enrollmentID,
+			logkeys.Error, err,
+		)
+		loc = w.loc
+	}
+	w.mu.Lock()
+	w.locs[name] = loc
+	w.mu.Unlock()
+	return loc, nil
+}
+
+// EnqueueDMCommand enqueues the DM command to ids. If the
+// notification of ctx is not urgent then the enrollments of ids
+// outside of the window in their time zone are deferred until it opens.
+// If configured the deferred enrollments are persisted before they are deferred.
+func (w *Windows) EnqueueDMCommand(ctx context.Context, ids []string, tokensJSON []byte) error {
+	if !NonUrgentFromContext(ctx) {
+		return w.enqueue(ctx, ids, tokensJSON)
+	}
+	now := w.now()
+	var send []string
+	// group the deferred enrollments by when their window opens
+	opens := make(map[time.Time][]string)
+	for _, id := range ids {
+		loc, err := w.location(ctx, id)
+		if err != nil {
+			return err
+		}
+		if loc == nil || w.window.Contains(now.In(loc)) {
+			send = append(send, id)
+			continue
+		}
+		open := w.window.Next(now.In(loc)).UTC()
+		opens[open] = append(opens[open], id)
+	}
+	if len(opens) > 0 {
+		p := PriorityFromContext(ctx)
+		deferred := make(map[string]*deferral)
+		for open, openIDs := range opens {
+			var batches []string
+			if w.queue != nil {
+				batch := &storage.NotificationBatch{
+					ID:            newBatchID(),
+					Priority:      p.String(),
+					EnrollmentIDs: openIDs,
+					Timestamp:     now.UTC(),
+					NotBefore:     open,
+				}
+				if err := w.queue.StoreNotificationBatch(ctx, batch); err != nil {
+					return fmt.Errorf("storing notification batch: %w", err)
+				}
+				batches = []string{batch.ID}
+			}
+			for _, id := range openIDs {
+				deferred[id] = &deferral{open: open, priority: p, batches: batches}
+			}
+		}
+		w.mu.Lock()
+		w.deferLocked(deferred)
+		w.mu.Unlock()
+		ctxlog.Logger(ctx, w.logger).Debug(
+			logkeys.Message, "deferred command",
+			"priority", p.String(),
+			logkeys.GenericCount, len(deferred),
+			"window", w.window.String(),
+		)
+		w.signal()
+	}
+	if len(send) < 1 {
+		return nil
+	}
+	return w.enqueue(ctx, send, tokensJSON)
+}
+
+// enqueue enqueues the DM command to ids using next. Notified
+// enrollments retrieve their current tokens so they are no longer
+// deferred when the command is enqueued.
+func (w *Windows) enqueue(ctx context.Context, ids []string, tokensJSON []byte) error {
+	if err := w.next.EnqueueDMCommand(ctx, ids, tokensJSON); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	var done []*deferral
+	for _, id := range ids {
+		if d, ok := w.deferred[id]; ok {
+			done = append(done, d)
+			delete(w.deferred, id)
+		}
+	}
+	w.mu.Unlock()
+	w.release(ctx, done)
+	return nil
+}
+
+// deferLocked merges deferred into the deferred enrollments.
+// The caller must hold the lock.
+func (w *Windows) deferLocked(deferred map[string]*deferral) {
+	for id, d := range deferred {
+		for _, batch := range d.batches {
+			w.batches[batch]++
+		}
+		if prev, ok := w.deferred[id]; ok {
+			prev.merge(d)
+			continue
+		}
+		w.deferred[id] = d
+	}
+}
+
+// release releases the stored notification batches of the deferrals
+// that are done. Batches without deferred enrollments are deleted.
+func (w *Windows) release(ctx context.Context, done []*deferral) {
+	var empty []string
+	w.mu.Lock()
+	for _, d := range done {
+		for _, batch := range d.batches {
+			if w.batches[batch]--; w.batches[batch] < 1 {
+				delete(w.batches, batch)
+				empty = append(empty, batch)
+			}
+		}
+	}
+	w.mu.Unlock()
+	if w.queue == nil {
+		return
+	}
+	for _, batch := range empty {
+		if err := w.queue.DeleteNotificationBatch(ctx, batch); err != nil {
+			ctxlog.Logger(ctx, w.logger).Info(
+				logkeys.Message, "deleting notification batch",
+				"batch", batch,
+				logkeys.Error, err,
+			)
+		}
+	}
+}
+
+// signal wakes Run to reconsider the deferred enrollments.
+func (w *Windows) signal() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Resume defers the enrollments of the persisted notification batches
+// of a previous run that have a NotBefore. Batches without one are
+// resumed by Lanes. Resume should be called before any commands are enqueued.
+func (w *Windows) Resume(ctx context.Context) (int, error) {
+	if w.queue == nil {
+		return 0, nil
+	}
+	batches, err := w.queue.RetrieveNotificationBatches(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving notification batches: %w", err)
+	}
+	var n int
+	w.mu.Lock()
+	for _, b := range batches {
+		if b.NotBefore.IsZero() {
+			continue
+		}
+		p, err := ParsePriority(b.Priority)
+		if err != nil {
+			ctxlog.Logger(ctx, w.logger).Info(
+				logkeys.Message, "resuming notification batch",
+				"batch", b.ID,
+				logkeys.Error, err,
+			)
+		}
+		deferred := make(map[string]*deferral)
+		for _, id := range b.EnrollmentIDs {
+			deferred[id] = &deferral{open: b.NotBefore, priority: p, batches: []string{b.ID}}
+		}
+		w.deferLocked(deferred)
+		n++
+	}
+	w.mu.Unlock()
+	if n > 0 {
+		w.signal()
+	}
+	return n, nil
+}
+
+// Deferred returns the number of deferred enrollments.
+func (w *Windows) Deferred() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.deferred)
+}
+
+// due removes and returns the deferred enrollments whose window has
+// opened. Otherwise it returns how long until the next window opens
+// or zero if no enrollments are deferred.
+func (w *Windows) due() (map[string]*deferral, time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	var due map[string]*deferral
+	var wait time.Duration
+	for id, d := range w.deferred {
+		if dur := d.open.Sub(now); dur > 0 {
+			if wait == 0 || dur < wait {
+				wait = dur
+			}
+			continue
+		}
+		if due == nil {
+			due = make(map[string]*deferral)
+		}
+		due[id] = d
+		delete(w.deferred, id)
+	}
+	return due, wait
+}
+
+// enqueueDue enqueues the DM command to the due enrollments in the
+// order of their priority. Enrollments that fail to be enqueued are
+// deferred again to be retried.
+func (w *Windows) enqueueDue(ctx context.Context, due map[string]*deferral) {
+	byPriority := make([][]string, numPriorities)
+	for id, d := range due {
+		byPriority[d.priority] = append(byPriority[d.priority], id)
+	}
+	for p := numPriorities - 1; p >= 0; p-- {
+		ids := byPriority[p]
+		if len(ids) < 1 {
+			continue
+		}
+		sort.Strings(ids)
+		if err := w.next.EnqueueDMCommand(WithPriority(ctx, p), ids, nil); err != nil {
+			ctxlog.Logger(ctx, w.logger).Info(
+				logkeys.Message, "enqueueing deferred command",
+				"priority", p.String(),
+				logkeys.GenericCount, len(ids),
+				logkeys.FirstEnrollmentID, ids[0],
+				logkeys.Error, err,
+			)
+			retry := w.now().Add(windowsRetryInterval)
+			w.mu.Lock()
+			for _, id := range ids {
+				d := due[id]
+				d.open = retry
+				// the batches of d are still counted
+				if prev, ok := w.deferred[id]; ok {
+					prev.merge(d)
+				} else {
+					w.deferred[id] = d
+				}
+			}
+			w.mu.Unlock()
+			continue
+		}
+		done := make([]*deferral, 0, len(ids))
+		for _, id := range ids {
+			done = append(done, due[id])
+		}
+		w.release(ctx, done)
+	}
+}
+
+// Run enqueues deferred commands when their windows open until ctx is
+// done. Deferred commands are enqueued without tokens: the enrollments
+// retrieve their current tokens instead.
+func (w *Windows) Run(ctx context.Context) {
+	for {
+		due, wait := w.due()
+		if len(due) > 0 {
+			w.enqueueDue(ctx, due)
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		// wait for a newly deferred enrollment or an opened window
+		var timer *time.Timer
+		var timerC <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timerC = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-w.wake:
+		case <-timerC:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
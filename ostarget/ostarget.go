@@ -0,0 +1,284 @@
+// Package ostarget excludes declarations from the DDM served to
+// enrollments whose reported OS version is older than the minimum OS
+// versions required of the declarations or of their sets.
+package ostarget
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Status value paths of the reported OS of enrollments.
+const (
+	FamilyPath  = ".StatusItems.device.operating-system.family"
+	VersionPath = ".StatusItems.device.operating-system.version"
+)
+
+// Requirements are the minimum OS versions by OS family (as reported
+// in the operating-system family status item, e.g. "macOS").
+// Families without a version are not restricted.
+type Requirements map[string]string
+
+// met reports whether an enrollment of family running version meets r.
+func (r Requirements) met(family, version string) bool {
+	required, ok := r[family]
+	if !ok {
+		return true
+	}
+	c, _ := storage.CompareStatusValue(storage.StatusValueTypeString, version, required)
+	return c >= 0
+}
+
+// Config configures the minimum OS versions of declarations and sets.
+type Config struct {
+	// Declarations are the requirements by declaration identifier.
+	Declarations map[string]Requirements `json:"declarations,omitempty"`
+
+	// Sets are the requirements by set name. A declaration is served
+	// if any of the enrollment's sets it is in meets its requirements
+	// (or has none).
+	Sets map[string]Requirements `json:"sets,omitempty"`
+}
+
+// validVersion reports whether v is a dotted version number.
+func validVersion(v string) bool {
+	for _, part := range strings.Split(v, ".") {
+		if _, err := strconv.ParseUint(part, 10, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding OS requirements config: %w", err)
+	}
+	for kind, reqs := range map[string]map[string]Requirements{"declaration": c.Declarations, "set": c.Sets} {
+		for name, req := range reqs {
+			for family, version := range req {
+				if !validVersion(version) {
+					return nil, fmt.Errorf("%s %s: invalid %s version: %q", kind, name, family, version)
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Storage is the DDM protocol storage that Store wraps.
+type Storage interface {
+	storage.DeclarationRetriever
+	storage.TokensDeclarationItemsRetriever
+}
+
+// EnrollmentStorage retrieves the declarations and the reported OS of enrollments.
+type EnrollmentStorage interface {
+	storage.EnrollmentDeclarationsRetriever
+	storage.StatusValuesRetriever
+}
+
+// Store excludes the declarations whose requirements are not met by
+// the reported OS of enrollments from the DDM served from an underlying
+// Storage. It implements Storage itself.
+//
+// Enrollments that have not reported their OS family and version (nor
+// has their device channel, for user channel enrollments) are served
+// all of their declarations.
+type Store struct {
+	store  Storage
+	data   EnrollmentStorage
+	config *Config
+	hash   ddm.NewHash
+}
+
+// New creates a new Store serving from store using config.
+// It panics if store, data, config, or newHash are nil.
+func New(store Storage, data EnrollmentStorage, config *Config, newHash ddm.NewHash) *Store {
+	if store == nil || data == nil || config == nil || newHash == nil {
+		panic("nil store, config, or hasher")
+	}
+	return &Store{store: store, data: data, config: config, hash: newHash}
+}
+
+// enrollmentOS returns the most recently reported OS family and version
+// of enrollmentID, falling back to those of its device channel.
+func (s *Store) enrollmentOS(ctx context.Context, enrollmentID string) (string, string, error) {
+	ids := []string{enrollmentID}
+	deviceID := storage.DeviceEnrollmentID(enrollmentID)
+	if deviceID != enrollmentID {
+		ids = append(ids, deviceID)
+	}
+	values, err := s.data.RetrieveStatusValues(ctx, ids, ".StatusItems.device.operating-system.%")
+	if err != nil {
+		return "", "", fmt.Errorf("retrieving status values: %w", err)
+	}
+	for _, id := range ids {
+		family := latest(values[id], FamilyPath)
+		version := latest(values[id], VersionPath)
+		if family != "" && version != "" {
+			return family, version, nil
+		}
+	}
+	return "", "", nil
+}
+
+// latest returns the most recently reported of values at path.
+func latest(values []storage.StatusValue, path string) string {
+	var ret *storage.StatusValue
+	for i := range values {
+		if values[i].Path != path {
+			continue
+		}
+		if ret == nil || !values[i].Timestamp.Before(ret.Timestamp) {
+			ret = &values[i]
+		}
+	}
+	if ret == nil {
+		return ""
+	}
+	return ret.Value
+}
+
+// excluded returns the declarations of enrollmentID whose requirements
+// are not met by its reported OS.
+func (s *Store) excluded(ctx context.Context, enrollmentID string) (map[string]bool, error) {
+	if len(s.config.Declarations) < 1 && len(s.config.Sets) < 1 {
+		return nil, nil
+	}
+	family, version, err := s.enrollmentOS(ctx, enrollmentID)
+	if err != nil || family == "" {
+		return nil, err
+	}
+	decls, err := s.data.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving enrollment declarations: %w", err)
+	}
+	ret := make(map[string]bool)
+	for _, d := range decls {
+		if !s.config.Declarations[d.Identifier].met(family, version) {
+			ret[d.Identifier] = true
+			continue
+		}
+		served := len(d.Sets) < 1
+		for _, setName := range d.Sets {
+			if s.config.Sets[setName].met(family, version) {
+				served = true
+				break
+			}
+		}
+		if !served {
+			ret[d.Identifier] = true
+		}
+	}
+	return ret, nil
+}
+
+// RetrieveEnrollmentDeclarationJSON retrieves the declaration JSON for
+// enrollmentID unless its requirements are not met.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	excluded, err := s.excluded(ctx, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	if excluded[declarationID] {
+		return nil, storage.Categorize(storage.ErrNotFound, fmt.Errorf("declaration %s not compatible with enrollment OS", declarationID))
+	}
+	return s.store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
+}
+
+// build adds the declarations in the declaration items diJSON that
+// are not excluded to b.
+func build(b interface{ Add(*ddm.Declaration) }, diJSON []byte, excluded map[string]bool) error {
+	di := new(ddm.DeclarationItems)
+	if err := json.Unmarshal(diJSON, di); err != nil {
+		return fmt.Errorf("unmarshaling declaration items: %w", err)
+	}
+	for _, m := range []struct {
+		manifestType string
+		items        []ddm.ManifestDeclaration
+	}{
+		{"activation", di.Declarations.Activations},
+		{"asset", di.Declarations.Assets},
+		{"configuration", di.Declarations.Configurations},
+		{"management", di.Declarations.Management},
+	} {
+		for _, item := range m.items {
+			if excluded[item.Identifier] {
+				continue
+			}
+			b.Add(&ddm.Declaration{
+				Identifier:  item.Identifier,
+				Type:        "com.apple." + m.manifestType,
+				ServerToken: item.ServerToken,
+			})
+		}
+	}
+	return nil
+}
+
+// RetrieveDeclarationItemsJSON retrieves the Declaration Items of
+// enrollmentID without the declarations whose requirements are not met.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	diJSON, err := s.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		return diJSON, err
+	}
+	excluded, err := s.excluded(ctx, enrollmentID)
+	if err != nil || len(excluded) < 1 {
+		return diJSON, err
+	}
+	b := ddm.NewDIBuilder(s.hash)
+	if err = build(b, diJSON, excluded); err != nil {
+		return nil, err
+	}
+	b.Finalize()
+	return json.Marshal(&b.DeclarationItems)
+}
+
+// RetrieveTokensJSON retrieves the Sync Tokens of enrollmentID without
+// the declarations whose requirements are not met.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	excluded, err := s.excluded(ctx, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(excluded) < 1 {
+		return s.store.RetrieveTokensJSON(ctx, enrollmentID)
+	}
+	diJSON, err := s.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	b := ddm.NewTokensBuilder(s.hash)
+	if err = build(b, diJSON, excluded); err != nil {
+		return nil, err
+	}
+	b.Finalize()
+	return json.Marshal(&b.TokensResponse)
+}
@@ -0,0 +1,133 @@
+package ostarget
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"hash"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type testStore struct {
+	values map[string][]storage.StatusValue
+}
+
+func (s *testStore) RetrieveEnrollmentDeclarationJSON(_ context.Context, declarationID, _, _ string) ([]byte, error) {
+	return []byte(`{"Identifier":"` + declarationID + `"}`), nil
+}
+
+func (s *testStore) RetrieveDeclarationItemsJSON(_ context.Context, _ string) ([]byte, error) {
+	return []byte(`{"Declarations":{"Activations":[{"Identifier":"act","ServerToken":"a"}],"Assets":[],"Configurations":[{"Identifier":"new","ServerToken":"b"},{"Identifier":"old","ServerToken":"c"}],"Management":[]},"DeclarationsToken":"x"}`), nil
+}
+
+func (s *testStore) RetrieveTokensJSON(_ context.Context, _ string) ([]byte, error) {
+	return []byte(`{"SyncTokens":{"DeclarationsToken":"x"}}`), nil
+}
+
+func (s *testStore) RetrieveEnrollmentDeclarations(_ context.Context, _ string) ([]storage.EnrollmentDeclaration, error) {
+	return []storage.EnrollmentDeclaration{
+		{Identifier: "act", Sets: []string{"default"}},
+		{Identifier: "new", Sets: []string{"default"}},
+		{Identifier: "old", Sets: []string{"sonoma", "other"}},
+	}, nil
+}
+
+func (s *testStore) RetrieveStatusValues(_ context.Context, ids []string, _ string) (map[string][]storage.StatusValue, error) {
+	ret := make(map[string][]storage.StatusValue)
+	for _, id := range ids {
+		ret[id] = s.values[id]
+	}
+	return ret, nil
+}
+
+func osValues(family, version string) []storage.StatusValue {
+	ts := time.Now()
+	return []storage.StatusValue{
+		{Path: FamilyPath, Value: family, Timestamp: ts},
+		{Path: VersionPath, Value: version, Timestamp: ts},
+	}
+}
+
+func newHash() hash.Hash { return xxhash.New() }
+
+func configIDs(t *testing.T, s *Store, enrollmentID string) []string {
+	t.Helper()
+	diJSON, err := s.RetrieveDeclarationItemsJSON(context.Background(), enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	di := new(ddm.DeclarationItems)
+	if err = json.Unmarshal(diJSON, di); err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, md := range di.Declarations.Configurations {
+		ids = append(ids, md.Identifier)
+	}
+	return ids
+}
+
+func TestStore(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`{
+		"declarations": {"new": {"macOS": "14.0"}},
+		"sets": {"sonoma": {"macOS": "14"}, "other": {"macOS": "14.2"}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := &testStore{values: map[string][]storage.StatusValue{
+		"ventura": osValues("macOS", "13.6.1"),
+		"sonoma":  osValues("macOS", "14.1"),
+		"ios":     osValues("iOS", "16.0"),
+		"dev1":    osValues("macOS", "13.0"),
+	}}
+	s := New(data, data, config, newHash)
+
+	for _, tc := range []struct {
+		enrollmentID string
+		want         []string
+	}{
+		{"ventura", nil},
+		{"sonoma", []string{"new", "old"}},
+		{"ios", []string{"new", "old"}},
+		{"unreported", []string{"new", "old"}},
+		// user channels fall back to the OS of their device
+		{"dev1:user1", nil},
+	} {
+		if have := configIDs(t, s, tc.enrollmentID); !reflect.DeepEqual(have, tc.want) {
+			t.Errorf("%s: have: %v, want: %v", tc.enrollmentID, have, tc.want)
+		}
+	}
+
+	if _, err = s.RetrieveEnrollmentDeclarationJSON(context.Background(), "old", "configuration", "ventura"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected not found error, have: %v", err)
+	}
+	if _, err = s.RetrieveEnrollmentDeclarationJSON(context.Background(), "act", "activation", "ventura"); err != nil {
+		t.Error(err)
+	}
+
+	// unchanged tokens are passed through
+	tokensJSON, err := s.RetrieveTokensJSON(context.Background(), "sonoma")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(tokensJSON), `{"SyncTokens":{"DeclarationsToken":"x"}}`; have != want {
+		t.Errorf("tokens: have: %s, want: %s", have, want)
+	}
+	if tokensJSON, err = s.RetrieveTokensJSON(context.Background(), "ventura"); err != nil {
+		t.Fatal(err)
+	} else if strings.Contains(string(tokensJSON), `"x"`) {
+		t.Errorf("expected rebuilt tokens: %s", tokensJSON)
+	}
+
+	if _, err = ReadConfig(strings.NewReader(`{"sets":{"a":{"macOS":"fourteen"}}}`)); err == nil {
+		t.Error("expected error for invalid version")
+	}
+}
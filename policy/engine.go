@@ -0,0 +1,98 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Engine evaluates API mutations against policies before they are
+// stored (see the guard package). Every decision is logged.
+type Engine struct {
+	policies []*Policy
+	store    storage.DeclarationAPIRetriever
+	logger   log.Logger
+}
+
+type Option func(*Engine)
+
+// WithLogger sets the logger that decisions are logged to.
+func WithLogger(logger log.Logger) Option {
+	return func(e *Engine) {
+		e.logger = logger
+	}
+}
+
+// New creates a new Engine. Store is used to retrieve the types of
+// declarations assigned to sets.
+func New(policies []*Policy, store storage.DeclarationAPIRetriever, opts ...Option) *Engine {
+	if store == nil {
+		panic("nil store")
+	}
+	e := &Engine{policies: policies, store: store, logger: log.NopLogger}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// decide evaluates in and logs the decision. Denied mutations return
+// an error in the storage.ErrForbidden category.
+func (e *Engine) decide(ctx context.Context, in *Input) error {
+	d := Evaluate(e.policies, in)
+	ctxlog.Logger(ctx, e.logger).Info(
+		logkeys.Message, "policy decision",
+		"principal", in.Principal,
+		"action", in.Action,
+		"set", in.Set,
+		logkeys.DeclarationID, in.Declaration,
+		logkeys.DeclarationType, in.DeclarationType,
+		"allow", d.Allow,
+		"policy", d.Policy,
+		"reason", d.Reason,
+	)
+	if d.Allow {
+		return nil
+	}
+	return storage.Categorize(storage.ErrForbidden, fmt.Errorf("denied by policy %s: %s", d.Policy, d.Reason))
+}
+
+// CheckDeclaration evaluates storing d by the principal of ctx.
+func (e *Engine) CheckDeclaration(ctx context.Context, d *ddm.Declaration) error {
+	return e.decide(ctx, &Input{
+		Principal:       httpddm.PrincipalName(ctx),
+		Action:          ActionStoreDeclaration,
+		Declaration:     d.Identifier,
+		DeclarationType: d.Type,
+	})
+}
+
+// CheckSetDeclaration evaluates assigning (or unassigning if assign
+// is false) declarationID to setName by the principal of ctx.
+// Missing declarations are not evaluated so that storage may report them.
+func (e *Engine) CheckSetDeclaration(ctx context.Context, setName, declarationID string, assign bool) error {
+	in := &Input{
+		Principal:   httpddm.PrincipalName(ctx),
+		Action:      ActionAssign,
+		Set:         setName,
+		Declaration: declarationID,
+	}
+	if !assign {
+		in.Action = ActionUnassign
+	}
+	d, err := e.store.RetrieveDeclaration(ctx, declarationID)
+	if errors.Is(err, storage.ErrDeclarationNotFound) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("retrieving declaration: %w", err)
+	}
+	in.DeclarationType = d.Type
+	return e.decide(ctx, in)
+}
@@ -0,0 +1,203 @@
+// Package policy evaluates API mutations against declarative policies
+// of who may store declarations of which types and assign them to
+// which sets.
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+// Actions of API mutations that policies are evaluated for.
+const (
+	ActionStoreDeclaration = "declaration.store"
+	ActionAssign           = "set.declaration.assign"
+	ActionUnassign         = "set.declaration.unassign"
+)
+
+// Effects of policies.
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Policy matches API mutations. Its match fields are lists of glob
+// patterns (see path.Match); an empty list matches anything.
+type Policy struct {
+	Name   string `json:"name"`
+	Effect string `json:"effect"`
+
+	// Principals match the name of the API principal.
+	Principals []string `json:"principals,omitempty"`
+
+	// Actions match the action of the mutation (e.g. "set.declaration.assign").
+	Actions []string `json:"actions,omitempty"`
+
+	// Sets match the set name. Mutations without a set (e.g. storing
+	// a declaration) only match policies without Sets.
+	Sets []string `json:"sets,omitempty"`
+
+	// DeclarationTypes match the type of the declaration.
+	DeclarationTypes []string `json:"declaration_types,omitempty"`
+}
+
+// validate checks p for errors.
+func (p *Policy) validate() error {
+	if p.Name == "" {
+		return errors.New("empty name")
+	}
+	if p.Effect != EffectAllow && p.Effect != EffectDeny {
+		return fmt.Errorf("invalid effect: %q", p.Effect)
+	}
+	for _, patterns := range [][]string{p.Principals, p.Actions, p.Sets, p.DeclarationTypes} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// match reports whether value matches any of patterns.
+// Empty patterns match any value.
+func match(patterns []string, value string) bool {
+	if len(patterns) < 1 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Input is an API mutation to evaluate.
+type Input struct {
+	Principal       string `json:"principal"`
+	Action          string `json:"action"`
+	Set             string `json:"set,omitempty"`
+	Declaration     string `json:"declaration"`
+	DeclarationType string `json:"declaration_type"`
+}
+
+// matches reports whether p matches in.
+func (p *Policy) matches(in *Input) bool {
+	if in.Set == "" && len(p.Sets) > 0 {
+		return false
+	}
+	return match(p.Principals, in.Principal) &&
+		match(p.Actions, in.Action) &&
+		match(p.Sets, in.Set) &&
+		match(p.DeclarationTypes, in.DeclarationType)
+}
+
+// Decision is the result of evaluating an Input.
+type Decision struct {
+	Allow bool `json:"allow"`
+
+	// Policy is the name of the policy that decided. Empty if no
+	// policy matched.
+	Policy string `json:"policy,omitempty"`
+
+	Reason string `json:"reason"`
+}
+
+// Evaluate decides whether the mutation in is allowed by policies.
+// A mutation is denied if it matches any deny policy. Otherwise if
+// there are allow policies for its action it must match one of them.
+// Mutations that no policy applies to are allowed.
+func Evaluate(policies []*Policy, in *Input) *Decision {
+	var allow *Policy
+	var restricted bool
+	for _, p := range policies {
+		if p.Effect == EffectAllow && match(p.Actions, in.Action) && (in.Set != "" || len(p.Sets) < 1) {
+			restricted = true
+		}
+		if !p.matches(in) {
+			continue
+		}
+		if p.Effect == EffectDeny {
+			return &Decision{Policy: p.Name, Reason: "denied by policy"}
+		}
+		if allow == nil {
+			allow = p
+		}
+	}
+	if allow != nil {
+		return &Decision{Allow: true, Policy: allow.Name, Reason: "allowed by policy"}
+	}
+	if restricted {
+		return &Decision{Reason: "not allowed by any policy"}
+	}
+	return &Decision{Allow: true, Reason: "no applicable policy"}
+}
+
+// readPolicies reads a JSON policy or array of policies from the file at path.
+func readPolicies(path string) ([]*Policy, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	var policies []*Policy
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		err = dec.Decode(&policies)
+	} else {
+		p := new(Policy)
+		err = dec.Decode(p)
+		policies = append(policies, p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding policy: %w", err)
+	}
+	return policies, nil
+}
+
+// Load loads policies from path. Path is either a bundle (a JSON file
+// of an array of policies) or a directory of JSON files (ending in
+// ".json") of policies or arrays of policies which are loaded in
+// lexical order.
+func Load(path string) ([]*Policy, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{path}
+	if fi.IsDir() {
+		if paths, err = filepath.Glob(filepath.Join(path, "*.json")); err != nil {
+			return nil, err
+		}
+		sort.Strings(paths)
+	}
+	var policies []*Policy
+	names := make(map[string]struct{})
+	for _, path := range paths {
+		filePolicies, err := readPolicies(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for i, p := range filePolicies {
+			if p == nil {
+				return nil, fmt.Errorf("%s: policy %d: nil policy", path, i)
+			}
+			if err = p.validate(); err != nil {
+				return nil, fmt.Errorf("%s: policy %d: %w", path, i, err)
+			}
+			if _, ok := names[p.Name]; ok {
+				return nil, fmt.Errorf("%s: policy %s: duplicate name", path, p.Name)
+			}
+			names[p.Name] = struct{}{}
+		}
+		policies = append(policies, filePolicies...)
+	}
+	return policies, nil
+}
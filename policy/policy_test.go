@@ -0,0 +1,171 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+const testPolicies = `[
+	{
+		"name": "no-wifi-in-finance",
+		"effect": "deny",
+		"actions": ["set.declaration.assign"],
+		"sets": ["finance*"],
+		"declaration_types": ["com.apple.configuration.wifi"]
+	},
+	{
+		"name": "admins-assign",
+		"effect": "allow",
+		"principals": ["admin", "ci-*"],
+		"actions": ["set.declaration.*"]
+	}
+]`
+
+func TestEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "10-policies.json"), []byte(testPolicies), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "20-single.json"), []byte(`{"name":"no-test-uploads","effect":"deny","principals":["bob"],"actions":["declaration.store"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 3 {
+		t.Fatalf("policies: have: %d, want: 3", len(policies))
+	}
+
+	for _, tc := range []struct {
+		in     Input
+		allow  bool
+		policy string
+	}{
+		{Input{Principal: "admin", Action: ActionAssign, Set: "finance", DeclarationType: "com.apple.configuration.wifi"}, false, "no-wifi-in-finance"},
+		{Input{Principal: "ci-deploy", Action: ActionAssign, Set: "finance", DeclarationType: "com.apple.configuration.passcode.settings"}, true, "admins-assign"},
+		{Input{Principal: "ci-deploy", Action: ActionUnassign, Set: "finance", DeclarationType: "com.apple.configuration.wifi"}, true, "admins-assign"},
+		{Input{Principal: "alice", Action: ActionAssign, Set: "it", DeclarationType: "com.apple.configuration.wifi"}, false, ""},
+		{Input{Principal: "alice", Action: ActionStoreDeclaration, DeclarationType: "com.apple.configuration.wifi"}, true, ""},
+		{Input{Principal: "bob", Action: ActionStoreDeclaration, DeclarationType: "com.apple.configuration.wifi"}, false, "no-test-uploads"},
+	} {
+		d := Evaluate(policies, &tc.in)
+		if d.Allow != tc.allow || d.Policy != tc.policy {
+			t.Errorf("%+v: have: %+v, want: allow %v by %q", tc.in, d, tc.allow, tc.policy)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"effect.json":    `{"name":"a","effect":"maybe"}`,
+		"unknown.json":   `{"name":"a","effect":"deny","set":["x"]}`,
+		"duplicate.json": `[{"name":"a","effect":"deny"},{"name":"a","effect":"allow"}]`,
+		"pattern.json":   `{"name":"a","effect":"deny","sets":["["]}`,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
+
+// principalContext returns a context authenticated by name.
+func principalContext(t *testing.T, name string) context.Context {
+	t.Helper()
+	var ctx context.Context
+	h := httpddm.BasicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}), name, "secret", "test")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetBasicAuth(name, "secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	if ctx == nil {
+		t.Fatal("not authenticated")
+	}
+	return ctx
+}
+
+func TestCheckSetDeclaration(t *testing.T) {
+	ctx := context.Background()
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.wifi","Identifier":"com.example.wifi","Payload":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err = os.WriteFile(path, []byte(testPolicies), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policies, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(policies, fs)
+	ctx = principalContext(t, "admin")
+	for _, tc := range []struct {
+		set         string
+		declaration string
+		assign      bool
+		forbidden   bool
+	}{
+		{"finance", "com.example.wifi", true, true},
+		{"it", "com.example.wifi", true, false},
+		{"finance", "com.example.wifi", false, false},
+		// missing declarations are left to storage to report
+		{"finance", "com.example.missing", true, false},
+	} {
+		err = e.CheckSetDeclaration(ctx, tc.set, tc.declaration, tc.assign)
+		if have, want := errors.Is(err, storage.ErrForbidden), tc.forbidden; have != want {
+			t.Errorf("%s %s assign %v: have: %v, want forbidden: %v", tc.set, tc.declaration, tc.assign, err, want)
+		}
+	}
+}
+
+func TestCheckDeclaration(t *testing.T) {
+	fs, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New([]*Policy{{
+		Name:       "no-test-uploads",
+		Effect:     EffectDeny,
+		Principals: []string{"bob"},
+		Actions:    []string{ActionStoreDeclaration},
+	}}, fs)
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.wifi","Identifier":"com.example.wifi","Payload":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for principal, forbidden := range map[string]bool{
+		"bob":   true,
+		"alice": false,
+	} {
+		err = e.CheckDeclaration(principalContext(t, principal), d)
+		if have, want := errors.Is(err, storage.ErrForbidden), forbidden; have != want {
+			t.Errorf("%s: have: %v, want forbidden: %v", principal, err, want)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package quota enforces soft per-principal API quotas for shared
+// deployments.
+package quota
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Limits are the quotas of a principal. Zero values are unlimited.
+type Limits struct {
+	// RequestsPerMinute limits the API requests of the principal.
+	RequestsPerMinute int `json:"requests_per_minute,omitempty"`
+
+	// MaxDeclarations limits the number of declarations owned by the
+	// principal, i.e. the existing declarations it created. Beyond it
+	// the principal may not create new declarations. Existing
+	// declarations (of any owner) may still be changed.
+	MaxDeclarations int `json:"max_declarations,omitempty"`
+
+	// MaxSets limits the number of sets owned by the principal like
+	// MaxDeclarations. A set is created by assigning its first declaration.
+	MaxSets int `json:"max_sets,omitempty"`
+}
+
+// Config configures the quotas of principals.
+type Config struct {
+	// Default are the limits of principals not in Principals.
+	Default Limits `json:"default"`
+
+	// Principals are the limits by principal name.
+	Principals map[string]Limits `json:"principals,omitempty"`
+}
+
+// ReadConfig reads a JSON quota config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	config := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(config); err != nil {
+		return nil, fmt.Errorf("decoding quota config: %w", err)
+	}
+	for name, limits := range config.Principals {
+		if name == "" {
+			return nil, errors.New("empty principal name")
+		}
+		if limits.RequestsPerMinute < 0 || limits.MaxDeclarations < 0 || limits.MaxSets < 0 {
+			return nil, fmt.Errorf("negative limit for principal: %s", name)
+		}
+	}
+	if config.Default.RequestsPerMinute < 0 || config.Default.MaxDeclarations < 0 || config.Default.MaxSets < 0 {
+		return nil, errors.New("negative default limit")
+	}
+	return config, nil
+}
+
+// ReadConfigFile reads a JSON quota config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Storage is the storage used to count declarations and sets and to
+// record their owners.
+type Storage interface {
+	storage.DeclarationsRetriever
+	storage.SetRetreiver
+	storage.OwnerStorage
+}
+
+// Usage is the quota usage of a principal.
+type Usage struct {
+	Principal string `json:"principal"`
+	Limits    Limits `json:"limits"`
+
+	// Requests is the number of requests in the current minute.
+	Requests int `json:"requests"`
+
+	// Rejected counts the requests rejected for exceeding a quota.
+	Rejected int64 `json:"rejected"`
+
+	// Declarations and Sets are the numbers owned by the principal.
+	Declarations int `json:"declarations"`
+	Sets         int `json:"sets"`
+}
+
+// Report is the quota usage of all principals seen.
+type Report struct {
+	Declarations int     `json:"declarations"`
+	Sets         int     `json:"sets"`
+	Principals   []Usage `json:"principals"`
+}
+
+// usage tracks the requests of a principal.
+type usage struct {
+	window   time.Time
+	requests int
+	rejected int64
+}
+
+// Quotas enforces the quotas of principals.
+// Usage is tracked in memory: it is per server and reset on restart.
+type Quotas struct {
+	config *Config
+	store  Storage
+	logger log.Logger
+	now    func() time.Time
+
+	mu    sync.Mutex
+	usage map[string]*usage
+}
+
+// Option configures Quotas.
+type Option func(*Quotas)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(q *Quotas) {
+		q.logger = logger
+	}
+}
+
+// New creates new Quotas of config counting declarations and sets in store.
+func New(config *Config, store Storage, opts ...Option) *Quotas {
+	if config == nil {
+		panic("nil config")
+	}
+	if store == nil {
+		panic("nil store")
+	}
+	q := &Quotas{
+		config: config,
+		store:  store,
+		logger: log.NopLogger,
+		now:    time.Now,
+		usage:  make(map[string]*usage),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	return q
+}
+
+// limits returns the limits of principal.
+func (q *Quotas) limits(principal string) Limits {
+	if limits, ok := q.config.Principals[principal]; ok {
+		return limits
+	}
+	return q.config.Default
+}
+
+// usageOf returns the usage of principal. q.mu must be held.
+func (q *Quotas) usageOf(principal string) *usage {
+	u, ok := q.usage[principal]
+	if !ok {
+		u = new(usage)
+		q.usage[principal] = u
+	}
+	return u
+}
+
+// allow counts a request of principal and reports whether it is
+// within its rate. Otherwise the time until the rate allows requests
+// again is returned.
+func (q *Quotas) allow(principal string) (bool, time.Duration) {
+	limit := q.limits(principal).RequestsPerMinute
+	now := q.now()
+	window := now.Truncate(time.Minute)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	u := q.usageOf(principal)
+	if !u.window.Equal(window) {
+		u.window = window
+		u.requests = 0
+	}
+	if limit > 0 && u.requests >= limit {
+		u.rejected++
+		return false, window.Add(time.Minute).Sub(now)
+	}
+	u.requests++
+	return true, 0
+}
+
+// reject counts a rejected request of principal.
+func (q *Quotas) reject(principal string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.usageOf(principal).rejected++
+}
+
+// exceeded writes a quota exceeded JSON error response.
+func exceeded(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(&struct {
+		Error string `json:"error"`
+	}{Error: "quota exceeded: " + msg})
+}
+
+// RateMiddleware rejects requests of principals that exceed their
+// requests per minute with 429 Too Many Requests.
+func (q *Quotas) RateMiddleware(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := httpddm.PrincipalName(r.Context())
+		if ok, retry := q.allow(name); !ok {
+			ctxlog.Logger(r.Context(), q.logger).Info(logkeys.Message, "request rate quota exceeded", "principal", name)
+			w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+			exceeded(w, "requests per minute")
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// contains reports whether s contains v.
+func contains(s []string, v string) bool {
+	for _, have := range s {
+		if have == v {
+			return true
+		}
+	}
+	return false
+}
+
+// owned returns the number of the objects of kind owned by principal
+// that exist (i.e. are in ids).
+func (q *Quotas) owned(ctx context.Context, kind, principal string, ids []string) (int, error) {
+	owned, err := q.store.RetrieveOwned(ctx, kind, principal)
+	if err != nil {
+		return 0, fmt.Errorf("retrieving owned: %w", err)
+	}
+	exists := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		exists[id] = true
+	}
+	var n int
+	for _, id := range owned {
+		if exists[id] {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// checkCreate checks creating the object of kind with id by the
+// principal of ctx against the max it may own and then records the
+// principal as its owner. Objects that exist (i.e. are in ids) are
+// not created and not checked.
+func (q *Quotas) checkCreate(ctx context.Context, kind, id string, ids []string, max int) error {
+	if contains(ids, id) {
+		return nil
+	}
+	name := httpddm.PrincipalName(ctx)
+	if max > 0 {
+		n, err := q.owned(ctx, kind, name, ids)
+		if err != nil {
+			return err
+		}
+		if n >= max {
+			ctxlog.Logger(ctx, q.logger).Info(logkeys.Message, kind+"s quota exceeded", "principal", name, "id", id)
+			q.reject(name)
+			return storage.Categorize(storage.ErrQuotaExceeded, fmt.Errorf("quota exceeded: %ss", kind))
+		}
+	}
+	if err := q.store.StoreOwner(ctx, kind, id, name); err != nil {
+		return fmt.Errorf("storing owner: %w", err)
+	}
+	return nil
+}
+
+// CheckDeclaration checks creating d against the declarations quota
+// of the principal of ctx (see the guard package). A declaration over
+// the quota returns an error in the storage.ErrQuotaExceeded category.
+// Otherwise the principal becomes the owner of a new declaration.
+func (q *Quotas) CheckDeclaration(ctx context.Context, d *ddm.Declaration) error {
+	ids, err := q.store.RetrieveDeclarations(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving declarations: %w", err)
+	}
+	max := q.limits(httpddm.PrincipalName(ctx)).MaxDeclarations
+	return q.checkCreate(ctx, storage.OwnedDeclaration, d.Identifier, ids, max)
+}
+
+// CheckSetDeclaration checks creating setName (by assigning its first
+// declaration) against the sets quota of the principal of ctx like
+// CheckDeclaration. Unassigning is not checked.
+func (q *Quotas) CheckSetDeclaration(ctx context.Context, setName, _ string, assign bool) error {
+	if !assign {
+		return nil
+	}
+	setNames, err := q.store.RetrieveSets(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving sets: %w", err)
+	}
+	max := q.limits(httpddm.PrincipalName(ctx)).MaxSets
+	return q.checkCreate(ctx, storage.OwnedSet, setName, setNames, max)
+}
+
+// Report reports the quota usage of the principals seen since startup
+// and the number of stored declarations and sets (in total and owned
+// by each principal).
+func (q *Quotas) Report(ctx context.Context) (*Report, error) {
+	ids, err := q.store.RetrieveDeclarations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving declarations: %w", err)
+	}
+	setNames, err := q.store.RetrieveSets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving sets: %w", err)
+	}
+	report := &Report{
+		Declarations: len(ids),
+		Sets:         len(setNames),
+		Principals:   []Usage{},
+	}
+	window := q.now().Truncate(time.Minute)
+	q.mu.Lock()
+	for name, u := range q.usage {
+		usage := Usage{
+			Principal: name,
+			Limits:    q.limits(name),
+			Rejected:  u.rejected,
+		}
+		if u.window.Equal(window) {
+			usage.Requests = u.requests
+		}
+		report.Principals = append(report.Principals, usage)
+	}
+	q.mu.Unlock()
+	for i := range report.Principals {
+		u := &report.Principals[i]
+		if u.Declarations, err = q.owned(ctx, storage.OwnedDeclaration, u.Principal, ids); err != nil {
+			return nil, err
+		}
+		if u.Sets, err = q.owned(ctx, storage.OwnedSet, u.Principal, setNames); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(report.Principals, func(i, j int) bool {
+		return report.Principals[i].Principal < report.Principals[j].Principal
+	})
+	return report, nil
+}
@@ -0,0 +1,205 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	httpddm "github.com/jessepeterson/kmfddm/http"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type testStore struct {
+	declarations []string
+	sets         []string
+
+	// owners are keyed by kind and id separated by a slash
+	owners map[string]string
+}
+
+func (s *testStore) RetrieveDeclarations(_ context.Context) ([]string, error) {
+	return s.declarations, nil
+}
+
+func (s *testStore) RetrieveSets(_ context.Context) ([]string, error) {
+	return s.sets, nil
+}
+
+func (s *testStore) StoreOwner(_ context.Context, kind, id, principal string) error {
+	if s.owners == nil {
+		s.owners = make(map[string]string)
+	}
+	s.owners[kind+"/"+id] = principal
+	return nil
+}
+
+func (s *testStore) RetrieveOwned(_ context.Context, kind, principal string) ([]string, error) {
+	var ids []string
+	for key, owner := range s.owners {
+		if strings.HasPrefix(key, kind+"/") && owner == principal {
+			ids = append(ids, strings.TrimPrefix(key, kind+"/"))
+		}
+	}
+	return ids, nil
+}
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+})
+
+func request(user, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(body))
+	r.SetBasicAuth(user, "secret")
+	return r
+}
+
+func TestRate(t *testing.T) {
+	config, err := ReadConfig(strings.NewReader(`{
+		"default": {"requests_per_minute": 2},
+		"principals": {"alice": {}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := New(config, &testStore{})
+	now := time.Date(2024, 1, 1, 0, 0, 30, 0, time.UTC)
+	q.now = func() time.Time { return now }
+	rate := q.RateMiddleware(okHandler)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, _, _ := r.BasicAuth()
+		httpddm.BasicAuthMiddleware(rate, name, "secret", "test").ServeHTTP(w, r)
+	})
+
+	for i, want := range []int{204, 204, 429} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, request("bob", ""))
+		if rec.Code != want {
+			t.Errorf("bob request %d: have: %d, want: %d", i, rec.Code, want)
+		}
+		if want == 429 && rec.Header().Get("Retry-After") != "30" {
+			t.Errorf("Retry-After: have: %q, want: %q", rec.Header().Get("Retry-After"), "30")
+		}
+	}
+	// alice is unlimited
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, request("alice", ""))
+		if rec.Code != 204 {
+			t.Errorf("alice request %d: have: %d, want: %d", i, rec.Code, 204)
+		}
+	}
+	// the next minute allows requests again
+	now = now.Add(time.Minute)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, request("bob", ""))
+	if rec.Code != 204 {
+		t.Errorf("bob next minute: have: %d, want: %d", rec.Code, 204)
+	}
+
+	report, err := q.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(report.Principals), 2; have != want {
+		t.Fatalf("principals: have: %d, want: %d", have, want)
+	}
+	if u := report.Principals[1]; u.Principal != "bob" || u.Requests != 1 || u.Rejected != 1 {
+		t.Errorf("bob usage: have: %+v", u)
+	}
+}
+
+// principalContext returns the context of a request authenticated as name.
+func principalContext(t *testing.T, name string) context.Context {
+	t.Helper()
+	var ctx context.Context
+	h := httpddm.BasicAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}), name, "secret", "test")
+	h.ServeHTTP(httptest.NewRecorder(), request(name, ""))
+	if ctx == nil {
+		t.Fatal("not authenticated")
+	}
+	return ctx
+}
+
+func TestCounts(t *testing.T) {
+	config := &Config{Default: Limits{MaxDeclarations: 2, MaxSets: 1}}
+	store := &testStore{
+		declarations: []string{"d1", "d2", "d3"},
+		sets:         []string{"s1"},
+		owners: map[string]string{
+			"declaration/d1": "bob",
+			"declaration/d2": "bob",
+			"declaration/d9": "bob", // deleted
+			"set/s1":         "alice",
+		},
+	}
+	q := New(config, store)
+	bob := principalContext(t, "bob")
+
+	for _, tc := range []struct {
+		id       string
+		exceeded bool
+	}{
+		{"d1", false},
+		{"d3", false}, // owned by nobody but exists
+		{"d4", true},
+	} {
+		d := &ddm.Declaration{Identifier: tc.id, Type: "com.apple.configuration.management.test"}
+		if err := q.CheckDeclaration(bob, d); errors.Is(err, storage.ErrQuotaExceeded) != tc.exceeded {
+			t.Errorf("declaration %s: have: %v, want exceeded: %v", tc.id, err, tc.exceeded)
+		}
+	}
+
+	for _, tc := range []struct {
+		set      string
+		assign   bool
+		exceeded bool
+	}{
+		{"s1", true, false}, // owned by alice but exists
+		{"s2", false, false},
+		{"s2", true, false},
+		{"s3", true, true},
+	} {
+		err := q.CheckSetDeclaration(bob, tc.set, "d1", tc.assign)
+		if errors.Is(err, storage.ErrQuotaExceeded) != tc.exceeded {
+			t.Errorf("set %s: have: %v, want exceeded: %v", tc.set, err, tc.exceeded)
+		}
+		if err == nil && tc.assign {
+			// the set is created by assigning
+			store.sets = append(store.sets, tc.set)
+		}
+	}
+	if have, want := store.owners["set/s2"], "bob"; have != want {
+		t.Errorf("owner of created set: have: %q, want: %q", have, want)
+	}
+
+	// alice may still create declarations
+	d := &ddm.Declaration{Identifier: "d4", Type: "com.apple.configuration.management.test"}
+	if err := q.CheckDeclaration(principalContext(t, "alice"), d); err != nil {
+		t.Error(err)
+	}
+	if have, want := store.owners["declaration/d4"], "alice"; have != want {
+		t.Errorf("owner of created declaration: have: %q, want: %q", have, want)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+	}{
+		{"negative", `{"default":{"max_sets":-1}}`},
+		{"negative principal", `{"principals":{"a":{"requests_per_minute":-1}}}`},
+		{"unknown field", `{"default":{},"x":1}`},
+	} {
+		if _, err := ReadConfig(strings.NewReader(tc.config)); err == nil {
+			t.Errorf("%s: expected error", tc.name)
+		}
+	}
+}
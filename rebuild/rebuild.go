@@ -0,0 +1,89 @@
+// Package rebuild retries the failed rebuilds of the derived DDM data
+// of enrollments.
+package rebuild
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Notifier notifies enrollments of changed declarations and sets.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// Retrier retries failed enrollment DDM rebuilds.
+type Retrier struct {
+	store    storage.DDMRebuildStorage
+	notifier Notifier
+	logger   log.Logger
+}
+
+type Option func(r *Retrier)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(r *Retrier) {
+		r.logger = logger
+	}
+}
+
+// WithNotifier notifies enrollments that are rebuilt.
+func WithNotifier(n Notifier) Option {
+	return func(r *Retrier) {
+		r.notifier = n
+	}
+}
+
+// New creates a new retrier.
+func New(store storage.DDMRebuildStorage, opts ...Option) *Retrier {
+	r := &Retrier{
+		store:  store,
+		logger: log.NopLogger,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run retries the failed enrollment DDM rebuilds and notifies the
+// rebuilt enrollments. The rebuilt enrollment IDs are returned.
+func (r *Retrier) Run(ctx context.Context) ([]string, error) {
+	rebuilt, err := r.store.RetryDDMRebuilds(ctx)
+	if err != nil {
+		return rebuilt, fmt.Errorf("retrying DDM rebuilds: %w", err)
+	}
+	if len(rebuilt) > 0 && r.notifier != nil {
+		if err = r.notifier.Changed(ctx, nil, nil, rebuilt); err != nil {
+			return rebuilt, fmt.Errorf("notifying: %w", err)
+		}
+	}
+	return rebuilt, nil
+}
+
+// Watch retries the failed enrollment DDM rebuilds immediately and
+// then every interval until ctx is done.
+func (r *Retrier) Watch(ctx context.Context, interval time.Duration) {
+	logger := ctxlog.Logger(ctx, r.logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if rebuilt, err := r.Run(ctx); err != nil {
+			logger.Info(logkeys.Message, "retrying DDM rebuilds", logkeys.Error, err)
+		} else if len(rebuilt) > 0 {
+			logger.Debug(logkeys.Message, "retried DDM rebuilds", "rebuilt", len(rebuilt))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
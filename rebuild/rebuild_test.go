@@ -0,0 +1,84 @@
+package rebuild
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type testNotifier struct {
+	calls int
+	ids   []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, _ []string, _ []string, ids []string) error {
+	n.calls++
+	n.ids = append(n.ids, ids...)
+	return nil
+}
+
+// testStore rebuilds the pending enrollments not in failing.
+type testStore struct {
+	pending []string
+	failing map[string]bool
+}
+
+func (s *testStore) RetrieveDDMRebuilds(_ context.Context) ([]storage.DDMRebuild, error) {
+	var ret []storage.DDMRebuild
+	for _, id := range s.pending {
+		ret = append(ret, storage.DDMRebuild{EnrollmentID: id})
+	}
+	return ret, nil
+}
+
+func (s *testStore) RetryDDMRebuilds(_ context.Context) ([]string, error) {
+	var rebuilt, pending []string
+	for _, id := range s.pending {
+		if s.failing[id] {
+			pending = append(pending, id)
+		} else {
+			rebuilt = append(rebuilt, id)
+		}
+	}
+	s.pending = pending
+	return rebuilt, nil
+}
+
+func TestRun(t *testing.T) {
+	store := &testStore{
+		pending: []string{"E1", "E2"},
+		failing: map[string]bool{"E2": true},
+	}
+	n := new(testNotifier)
+	r := New(store, WithNotifier(n))
+	ctx := context.Background()
+
+	rebuilt, err := r.Run(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := rebuilt, []string{"E1"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("rebuilt: have: %v, want: %v", have, want)
+	}
+	if have, want := n.ids, []string{"E1"}; n.calls != 1 || !reflect.DeepEqual(have, want) {
+		t.Errorf("notified: have: %d calls of %v, want: 1 call of %v", n.calls, have, want)
+	}
+
+	// nothing rebuilt is not notified
+	if _, err = r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n.calls != 1 {
+		t.Errorf("notified: have: %d calls, want: 1", n.calls)
+	}
+
+	delete(store.failing, "E2")
+	if rebuilt, err = r.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := rebuilt, []string{"E2"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("rebuilt: have: %v, want: %v", have, want)
+	}
+}
@@ -0,0 +1,118 @@
+// Package redact masks sensitive values in declaration payloads.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// Mask replaces redacted values.
+const Mask = "********"
+
+// Any matches all declarations in a Config.
+const Any = "*"
+
+// Config maps declaration types or identifiers to the payload key
+// paths that are sensitive. Key paths are separated by periods
+// (e.g. "IncomingServer.Password"). The Any key applies to all declarations.
+type Config map[string][]string
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (Config, error) {
+	c := make(Config)
+	if err := json.NewDecoder(r).Decode(&c); err != nil {
+		return nil, fmt.Errorf("decoding redaction config: %w", err)
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Redactor masks sensitive payload values of declarations.
+type Redactor struct {
+	paths map[string][][]string
+}
+
+// New creates a new Redactor from c.
+func New(c Config) *Redactor {
+	r := &Redactor{paths: make(map[string][][]string)}
+	for k, paths := range c {
+		for _, p := range paths {
+			if p == "" {
+				continue
+			}
+			r.paths[k] = append(r.paths[k], strings.Split(p, "."))
+		}
+	}
+	return r
+}
+
+// pathsFor returns the sensitive key paths for d.
+func (r *Redactor) pathsFor(d *ddm.Declaration) [][]string {
+	var paths [][]string
+	for _, k := range []string{Any, d.Type, d.Identifier} {
+		paths = append(paths, r.paths[k]...)
+	}
+	return paths
+}
+
+// mask replaces the value at path in v (if it exists) with Mask.
+// Arrays are traversed so that each of their elements is masked.
+func mask(v interface{}, path []string) bool {
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		child, ok := tv[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			tv[path[0]] = Mask
+			return true
+		}
+		return mask(child, path[1:])
+	case []interface{}:
+		var masked bool
+		for _, elem := range tv {
+			masked = mask(elem, path) || masked
+		}
+		return masked
+	}
+	return false
+}
+
+// Redact returns the JSON of d with the sensitive payload values masked.
+// The raw JSON of d is returned unchanged if there is nothing to redact.
+func (r *Redactor) Redact(d *ddm.Declaration) ([]byte, error) {
+	if r == nil {
+		return d.Raw, nil
+	}
+	paths := r.pathsFor(d)
+	if len(paths) < 1 {
+		return d.Raw, nil
+	}
+	var decl map[string]interface{}
+	if err := json.Unmarshal(d.Raw, &decl); err != nil {
+		return nil, fmt.Errorf("decoding declaration: %w", err)
+	}
+	var masked bool
+	for _, p := range paths {
+		masked = mask(decl["Payload"], p) || masked
+	}
+	if !masked {
+		return d.Raw, nil
+	}
+	return json.Marshal(decl)
+}
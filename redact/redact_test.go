@@ -0,0 +1,41 @@
+package redact
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+func TestRedact(t *testing.T) {
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.example.type","Identifier":"com.example.id","Payload":{"Secret":"abc","Servers":[{"Password":"def"},{"Host":"x"}],"Public":"ghi"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadConfig(strings.NewReader(`{"com.example.type":["Secret"],"com.example.id":["Servers.Password"],"*":["Missing.Key"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := New(c).Redact(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"abc", "def"} {
+		if bytes.Contains(out, []byte(s)) {
+			t.Errorf("value %q not redacted: %s", s, out)
+		}
+	}
+	if !bytes.Contains(out, []byte("ghi")) {
+		t.Errorf("public value redacted: %s", out)
+	}
+
+	// nothing to redact returns the raw JSON
+	out, err = New(Config{"other": {"Secret"}}).Redact(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, d.Raw) {
+		t.Error("expected unchanged raw JSON")
+	}
+}
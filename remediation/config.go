@@ -0,0 +1,91 @@
+package remediation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// Rule matches the status of declarations reported by enrollments and
+// configures the actions taken when a reported status matches.
+// Empty match fields match any status.
+type Rule struct {
+	Name string `json:"name"`
+
+	// Declaration matches the declaration identifier.
+	Declaration string `json:"declaration,omitempty"`
+
+	// Valid matches the reported validity (e.g. "invalid").
+	Valid string `json:"valid,omitempty"`
+
+	// Active matches the reported active state.
+	Active *bool `json:"active,omitempty"`
+
+	// Reason matches the code of any of the reported reasons.
+	Reason string `json:"reason,omitempty"`
+
+	// Cooldown is the minimum duration (e.g. "1h") between actions of
+	// the rule for the same enrollment and declaration.
+	Cooldown string `json:"cooldown,omitempty"`
+
+	// Touch re-touches the declaration (giving it a new server token)
+	// and notifies its enrollments.
+	Touch bool `json:"touch,omitempty"`
+
+	// Set adds the enrollment to this set and notifies it.
+	Set string `json:"set,omitempty"`
+
+	// Webhook is a URL to post the matched status to.
+	Webhook string `json:"webhook,omitempty"`
+
+	cooldown time.Duration
+}
+
+// Config is the remediation rules configuration.
+type Config struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// ReadConfig reads and validates a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding remediation config: %w", err)
+	}
+	names := make(map[string]struct{})
+	for i, rule := range c.Rules {
+		if rule == nil || rule.Name == "" {
+			return nil, fmt.Errorf("rule %d: empty name", i)
+		}
+		if _, ok := names[rule.Name]; ok {
+			return nil, fmt.Errorf("rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+		if !rule.Touch && rule.Set == "" && rule.Webhook == "" {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, errors.New("no actions"))
+		}
+		if rule.Cooldown != "" {
+			d, err := time.ParseDuration(rule.Cooldown)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: parsing cooldown: %w", rule.Name, err)
+			}
+			rule.cooldown = d
+		}
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads and validates a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
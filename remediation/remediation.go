@@ -0,0 +1,210 @@
+// Package remediation reacts to the declaration status reported by
+// enrollments by taking the actions of matching rules.
+package remediation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// webhookTimeout limits how long posting to a webhook may take.
+const webhookTimeout = 30 * time.Second
+
+// Storage is the storage needed to store status and take actions.
+type Storage interface {
+	storage.StatusStorer
+	storage.Toucher
+	storage.EnrollmentSetStorer
+}
+
+// Notifier notifies enrollments of changed declarations and sets.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// Remediator wraps status storage. After a status report is stored the
+// actions of the rules matching its declaration status are taken.
+// Failed actions are logged and do not fail storing the status report.
+type Remediator struct {
+	store    Storage
+	rules    []*Rule
+	notifier Notifier
+	client   *http.Client
+	logger   log.Logger
+	now      func() time.Time
+
+	mu sync.Mutex
+	// last is when actions were last taken keyed by rule, enrollment, and declaration.
+	last map[string]time.Time
+}
+
+type Option func(*Remediator)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(r *Remediator) {
+		r.logger = logger
+	}
+}
+
+// WithNotifier notifies enrollments of touched declarations and changed sets.
+func WithNotifier(n Notifier) Option {
+	return func(r *Remediator) {
+		r.notifier = n
+	}
+}
+
+// WithClient sets the HTTP client used for webhooks.
+func WithClient(client *http.Client) Option {
+	return func(r *Remediator) {
+		r.client = client
+	}
+}
+
+// New creates a new Remediator that takes the actions of config.
+func New(store Storage, config *Config, opts ...Option) *Remediator {
+	if store == nil || config == nil {
+		panic("nil store or config")
+	}
+	r := &Remediator{
+		store:  store,
+		rules:  config.Rules,
+		logger: log.NopLogger,
+		now:    time.Now,
+		last:   make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// hasReason reports whether the JSON reasons contain a reason with code.
+func hasReason(reasonsJSON []byte, code string) bool {
+	var reasons []struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(reasonsJSON, &reasons); err != nil {
+		return false
+	}
+	for _, r := range reasons {
+		if r.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the declaration status d matches rule.
+func (rule *Rule) matches(d *ddm.DeclarationStatus) bool {
+	if rule.Declaration != "" && rule.Declaration != d.Identifier {
+		return false
+	}
+	if rule.Valid != "" && rule.Valid != d.Valid {
+		return false
+	}
+	if rule.Active != nil && *rule.Active != d.Active {
+		return false
+	}
+	if rule.Reason != "" && !hasReason(d.ReasonsJSON, rule.Reason) {
+		return false
+	}
+	return true
+}
+
+// cooledDown reports whether the cooldown of rule has passed for
+// enrollmentID and declarationID. If so the cooldown is restarted.
+func (r *Remediator) cooledDown(rule *Rule, enrollmentID, declarationID string, now time.Time) bool {
+	key := rule.Name + "\x00" + enrollmentID + "\x00" + declarationID
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if last, ok := r.last[key]; ok && now.Before(last.Add(rule.cooldown)) {
+		return false
+	}
+	r.last[key] = now
+	return true
+}
+
+// StoreDeclarationStatus stores status and then takes the actions of
+// the rules matching its declaration status.
+func (r *Remediator) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	if err := r.store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		return err
+	}
+	now := r.now()
+	for _, rule := range r.rules {
+		for i := range status.Declarations {
+			d := &status.Declarations[i]
+			if !rule.matches(d) || !r.cooledDown(rule, enrollmentID, d.Identifier, now) {
+				continue
+			}
+			logger := ctxlog.Logger(ctx, r.logger).With(
+				"rule", rule.Name,
+				logkeys.EnrollmentID, enrollmentID,
+				logkeys.DeclarationID, d.Identifier,
+			)
+			logger.Debug(logkeys.Message, "remediating")
+			if err := r.remediate(ctx, rule, enrollmentID, d, now); err != nil {
+				logger.Info(logkeys.Message, "remediating", logkeys.Error, err)
+			}
+		}
+	}
+	return nil
+}
+
+// remediate takes the actions of rule for the declaration status d.
+func (r *Remediator) remediate(ctx context.Context, rule *Rule, enrollmentID string, d *ddm.DeclarationStatus, now time.Time) error {
+	if rule.Webhook != "" {
+		e := &Event{
+			Rule:         rule.Name,
+			EnrollmentID: enrollmentID,
+			Declaration:  d.Identifier,
+			Active:       d.Active,
+			Valid:        d.Valid,
+			ServerToken:  d.ServerToken,
+			Reasons:      d.ReasonsJSON,
+			Timestamp:    now,
+		}
+		logger := ctxlog.Logger(ctx, r.logger).With("rule", rule.Name)
+		go func() {
+			// the request context may be cancelled before we're done
+			ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+			defer cancel()
+			if err := postWebhook(ctx, r.client, rule.Webhook, e); err != nil {
+				logger.Info(logkeys.Message, "posting remediation webhook", logkeys.Error, err)
+			}
+		}()
+	}
+	if rule.Touch {
+		if err := r.store.TouchDeclaration(ctx, d.Identifier); err != nil {
+			return fmt.Errorf("touching declaration: %w", err)
+		}
+		if r.notifier != nil {
+			if err := r.notifier.Changed(ctx, []string{d.Identifier}, nil, nil); err != nil {
+				return fmt.Errorf("notifying declaration: %w", err)
+			}
+		}
+	}
+	if rule.Set != "" {
+		changed, err := r.store.StoreEnrollmentSet(ctx, enrollmentID, rule.Set)
+		if err != nil {
+			return fmt.Errorf("storing enrollment set: %w", err)
+		}
+		if changed && r.notifier != nil {
+			if err = r.notifier.Changed(ctx, nil, nil, []string{enrollmentID}); err != nil {
+				return fmt.Errorf("notifying enrollment: %w", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package remediation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+type testStore struct {
+	stored  int
+	touched []string
+	sets    []string
+}
+
+func (s *testStore) StoreDeclarationStatus(_ context.Context, _ string, _ *ddm.StatusReport) error {
+	s.stored++
+	return nil
+}
+
+func (s *testStore) TouchDeclaration(_ context.Context, declarationID string) error {
+	s.touched = append(s.touched, declarationID)
+	return nil
+}
+
+func (s *testStore) StoreEnrollmentSet(_ context.Context, enrollmentID, setName string) (bool, error) {
+	s.sets = append(s.sets, enrollmentID+":"+setName)
+	return true, nil
+}
+
+type testNotifier struct {
+	declarations []string
+	ids          []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, declarations []string, _ []string, ids []string) error {
+	n.declarations = append(n.declarations, declarations...)
+	n.ids = append(n.ids, ids...)
+	return nil
+}
+
+const testConfig = `{
+	"rules": [
+		{
+			"name": "retouch",
+			"declaration": "com.example.wifi",
+			"valid": "invalid",
+			"reason": "Error.ConfigurationCannotBeApplied",
+			"cooldown": "1h",
+			"touch": true
+		},
+		{
+			"name": "quarantine",
+			"active": false,
+			"set": "remediation",
+			"webhook": "%s"
+		}
+	]
+}`
+
+func TestRemediator(t *testing.T) {
+	events := make(chan *Event, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e := new(Event)
+		if err := json.NewDecoder(r.Body).Decode(e); err != nil {
+			t.Error(err)
+		}
+		events <- e
+	}))
+	defer srv.Close()
+
+	config, err := ReadConfig(strings.NewReader(strings.Replace(testConfig, "%s", srv.URL, 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := new(testStore)
+	n := new(testNotifier)
+	r := New(store, config, WithNotifier(n))
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.now = func() time.Time { return now }
+
+	status := &ddm.StatusReport{Declarations: []ddm.DeclarationStatus{
+		{Identifier: "com.example.wifi", Active: true, Valid: "invalid", ReasonsJSON: []byte(`[{"code":"Error.ConfigurationCannotBeApplied"}]`)},
+		{Identifier: "com.example.passcode", Active: false, Valid: "valid"},
+		{Identifier: "com.example.other", Active: true, Valid: "valid"},
+	}}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		// the second report is within the cooldown of the first rule
+		if err = r.StoreDeclarationStatus(ctx, "E1", status); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have, want := store.stored, 2; have != want {
+		t.Errorf("stored: have %v, want %v", have, want)
+	}
+	if have, want := store.touched, []string{"com.example.wifi"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("touched: have %v, want %v", have, want)
+	}
+	if have, want := n.declarations, []string{"com.example.wifi"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified declarations: have %v, want %v", have, want)
+	}
+	// the quarantine rule has no cooldown
+	if have, want := store.sets, []string{"E1:remediation", "E1:remediation"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("sets: have %v, want %v", have, want)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-events:
+			if e.Rule != "quarantine" || e.EnrollmentID != "E1" || e.Declaration != "com.example.passcode" {
+				t.Errorf("unexpected event: %v", e)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for webhook")
+		}
+	}
+
+	// after the cooldown the declaration is touched again
+	now = now.Add(time.Hour)
+	if err = r.StoreDeclarationStatus(ctx, "E1", status); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(store.touched), 2; have != want {
+		t.Errorf("touched: have %v, want %v", have, want)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	for _, c := range []string{
+		`{"rules":[{"touch":true}]}`,
+		`{"rules":[{"name":"a"}]}`,
+		`{"rules":[{"name":"a","touch":true,"cooldown":"soon"}]}`,
+		`{"rules":[{"name":"a","touch":true},{"name":"a","touch":true}]}`,
+		`{"rules":[{"name":"a","touch":true,"unknown":1}]}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(c)); err == nil {
+			t.Errorf("expected error: %s", c)
+		}
+	}
+}
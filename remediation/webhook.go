@@ -0,0 +1,47 @@
+package remediation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Event is posted to the webhook of a rule when a reported status matches it.
+type Event struct {
+	Rule         string          `json:"rule"`
+	EnrollmentID string          `json:"enrollment_id"`
+	Declaration  string          `json:"declaration"`
+	Active       bool            `json:"active"`
+	Valid        string          `json:"valid"`
+	ServerToken  string          `json:"server_token"`
+	Reasons      json.RawMessage `json:"reasons,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+}
+
+// postWebhook posts e as JSON to url.
+func postWebhook(ctx context.Context, client *http.Client, url string, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: unexpected HTTP status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+// Package reqcache memoizes storage lookups for the duration of a request.
+//
+// Handlers that work across many enrollments (or many declarations)
+// tend to look up the same sets and declarations over and over. Within
+// a request memoized lookups collapse those duplicates into a single
+// storage read. Lookups are only memoized in contexts created by
+// NewContext so that storage is otherwise read through as usual.
+package reqcache
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type ctxKeyCache struct{}
+
+// key identifies a lookup by storage method and argument.
+type key struct {
+	method string
+	arg    string
+}
+
+// result is a memoized lookup.
+type result struct {
+	once  sync.Once
+	value interface{}
+	err   error
+}
+
+// cache is the memoized lookups of a single request.
+type cache struct {
+	mu      sync.Mutex
+	results map[key]*result
+}
+
+// NewContext returns a copy of ctx in which storage lookups are memoized.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyCache{}, &cache{results: make(map[key]*result)})
+}
+
+// Middleware memoizes storage lookups for the duration of safe (GET
+// and HEAD) requests. Other requests may change storage and so
+// read through to storage.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			r = r.WithContext(NewContext(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// memo returns the result of fn for method and arg. If ctx is from
+// NewContext then fn is called at most once per method and arg.
+// Concurrent lookups of the same method and arg wait for the first.
+func memo(ctx context.Context, method, arg string, fn func() (interface{}, error)) (interface{}, error) {
+	c, ok := ctx.Value(ctxKeyCache{}).(*cache)
+	if !ok {
+		return fn()
+	}
+	k := key{method: method, arg: arg}
+	c.mu.Lock()
+	res := c.results[k]
+	if res == nil {
+		res = new(result)
+		c.results[k] = res
+	}
+	c.mu.Unlock()
+	res.once.Do(func() {
+		res.value, res.err = fn()
+	})
+	return res.value, res.err
+}
+
+// Storage is the storage that Cache memoizes lookups of.
+type Storage interface {
+	storage.DeclarationAPIRetriever
+	storage.DeclarationSetRetriever
+	storage.SetDeclarationsRetriever
+	storage.EnrollmentSetsRetriever
+	storage.EnrollmentDeclarationsRetriever
+}
+
+// Cache memoizes the lookups of an underlying Storage within requests.
+// It implements Storage itself. Memoized values are shared by the
+// callers within a request: slices are copied but declarations must
+// not be modified.
+type Cache struct {
+	store Storage
+}
+
+// New creates a new Cache of store lookups.
+func New(store Storage) *Cache {
+	if store == nil {
+		panic("nil store")
+	}
+	return &Cache{store: store}
+}
+
+// memoStrings memoizes a lookup that returns a string slice.
+func memoStrings(ctx context.Context, method, arg string, fn func() ([]string, error)) ([]string, error) {
+	v, err := memo(ctx, method, arg, func() (interface{}, error) { return fn() })
+	s, _ := v.([]string)
+	if s == nil {
+		return nil, err
+	}
+	return append([]string{}, s...), err
+}
+
+// RetrieveDeclaration retrieves a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveDeclaration(ctx context.Context, declarationID string) (*ddm.Declaration, error) {
+	v, err := memo(ctx, "RetrieveDeclaration", declarationID, func() (interface{}, error) {
+		return c.store.RetrieveDeclaration(ctx, declarationID)
+	})
+	d, _ := v.(*ddm.Declaration)
+	return d, err
+}
+
+// RetrieveDeclarationModTime retrieves the last modification time of the declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveDeclarationModTime(ctx context.Context, declarationID string) (time.Time, error) {
+	v, err := memo(ctx, "RetrieveDeclarationModTime", declarationID, func() (interface{}, error) {
+		return c.store.RetrieveDeclarationModTime(ctx, declarationID)
+	})
+	t, _ := v.(time.Time)
+	return t, err
+}
+
+// RetrieveDeclarationSets retrieves the list of set names for declarationID.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveDeclarationSets(ctx context.Context, declarationID string) ([]string, error) {
+	return memoStrings(ctx, "RetrieveDeclarationSets", declarationID, func() ([]string, error) {
+		return c.store.RetrieveDeclarationSets(ctx, declarationID)
+	})
+}
+
+// RetrieveSetDeclarations retrieves the list of declarations IDs for setName.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveSetDeclarations(ctx context.Context, setName string) ([]string, error) {
+	return memoStrings(ctx, "RetrieveSetDeclarations", setName, func() ([]string, error) {
+		return c.store.RetrieveSetDeclarations(ctx, setName)
+	})
+}
+
+// RetrieveEnrollmentSets retrieves the sets that are associated with enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveEnrollmentSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	return memoStrings(ctx, "RetrieveEnrollmentSets", enrollmentID, func() ([]string, error) {
+		return c.store.RetrieveEnrollmentSets(ctx, enrollmentID)
+	})
+}
+
+// RetrieveEnrollmentDeclarations retrieves the declarations that enrollmentID is entitled to.
+// See also the storage package for documentation on the storage interfaces.
+func (c *Cache) RetrieveEnrollmentDeclarations(ctx context.Context, enrollmentID string) ([]storage.EnrollmentDeclaration, error) {
+	v, err := memo(ctx, "RetrieveEnrollmentDeclarations", enrollmentID, func() (interface{}, error) {
+		return c.store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	})
+	decls, _ := v.([]storage.EnrollmentDeclaration)
+	if decls == nil {
+		return nil, err
+	}
+	return append([]storage.EnrollmentDeclaration{}, decls...), err
+}
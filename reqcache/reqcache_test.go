@@ -0,0 +1,125 @@
+package reqcache
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type testStore struct {
+	reads int
+}
+
+func (s *testStore) RetrieveDeclaration(_ context.Context, declarationID string) (*ddm.Declaration, error) {
+	s.reads++
+	if declarationID == "missing" {
+		return nil, storage.ErrDeclarationNotFound
+	}
+	return &ddm.Declaration{Identifier: declarationID}, nil
+}
+
+func (s *testStore) RetrieveDeclarationModTime(_ context.Context, _ string) (time.Time, error) {
+	s.reads++
+	return time.Time{}, nil
+}
+
+func (s *testStore) RetrieveDeclarationSets(_ context.Context, _ string) ([]string, error) {
+	s.reads++
+	return []string{"set1"}, nil
+}
+
+func (s *testStore) RetrieveSetDeclarations(_ context.Context, setName string) ([]string, error) {
+	s.reads++
+	return []string{setName + ".decl1", setName + ".decl2"}, nil
+}
+
+func (s *testStore) RetrieveEnrollmentSets(_ context.Context, _ string) ([]string, error) {
+	s.reads++
+	return nil, nil
+}
+
+func (s *testStore) RetrieveEnrollmentDeclarations(_ context.Context, _ string) ([]storage.EnrollmentDeclaration, error) {
+	s.reads++
+	return nil, nil
+}
+
+func TestCache(t *testing.T) {
+	s := new(testStore)
+	c := New(s)
+
+	// without a request cache every lookup reads storage
+	for i := 0; i < 2; i++ {
+		if _, err := c.RetrieveSetDeclarations(context.Background(), "set1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have, want := s.reads, 2; have != want {
+		t.Errorf("reads: have: %d, want: %d", have, want)
+	}
+
+	s.reads = 0
+	ctx := NewContext(context.Background())
+	for i := 0; i < 3; i++ {
+		ids, err := c.RetrieveSetDeclarations(ctx, "set1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []string{"set1.decl1", "set1.decl2"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("have: %v, want: %v", ids, want)
+		}
+		// memoized slices are copies
+		ids[0] = "modified"
+
+		if _, err = c.RetrieveSetDeclarations(ctx, "set2"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = c.RetrieveDeclaration(ctx, "missing"); err != storage.ErrDeclarationNotFound {
+			t.Errorf("have: %v, want: %v", err, storage.ErrDeclarationNotFound)
+		}
+		d, err := c.RetrieveDeclaration(ctx, "decl1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d == nil || d.Identifier != "decl1" {
+			t.Errorf("have: %v, want: decl1", d)
+		}
+		if sets, err := c.RetrieveEnrollmentSets(ctx, "enr1"); err != nil || sets != nil {
+			t.Errorf("have: %v (err: %v), want: nil", sets, err)
+		}
+	}
+	if have, want := s.reads, 5; have != want {
+		t.Errorf("reads: have: %d, want: %d", have, want)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	s := new(testStore)
+	c := New(s)
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 2; i++ {
+			if _, err := c.RetrieveDeclarationSets(r.Context(), "decl1"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}))
+
+	for _, test := range []struct {
+		method string
+		reads  int
+	}{
+		{http.MethodGet, 1},
+		{http.MethodPut, 2},
+	} {
+		s.reads = 0
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, "/", nil))
+		if have, want := s.reads, test.reads; have != want {
+			t.Errorf("%s: reads: have: %d, want: %d", test.method, have, want)
+		}
+	}
+}
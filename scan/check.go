@@ -0,0 +1,50 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type ctxKeyAnnotations struct{}
+
+// annotations are the annotations collected within a context.
+type annotations struct {
+	sync.Mutex
+	list []Annotation
+}
+
+// CollectAnnotations returns a context in which the annotations of
+// declarations checked with CheckDeclaration are collected and a
+// function that returns the annotations collected so far.
+func CollectAnnotations(ctx context.Context) (context.Context, func() []Annotation) {
+	a := new(annotations)
+	return context.WithValue(ctx, ctxKeyAnnotations{}, a), func() []Annotation {
+		a.Lock()
+		defer a.Unlock()
+		return append([]Annotation(nil), a.list...)
+	}
+}
+
+// CheckDeclaration scans d before it is stored (see the guard package).
+// A rejected declaration returns an error in the storage.ErrRejected
+// category. The annotations of the scanners are collected if ctx
+// collects them (see CollectAnnotations).
+func (c *Chain) CheckDeclaration(ctx context.Context, d *ddm.Declaration) error {
+	report, err := c.Scan(ctx, d)
+	if err != nil {
+		return fmt.Errorf("scanning declaration: %w", err)
+	}
+	if a, ok := ctx.Value(ctxKeyAnnotations{}).(*annotations); ok && len(report.Annotations) > 0 {
+		a.Lock()
+		a.list = append(a.list, report.Annotations...)
+		a.Unlock()
+	}
+	if report.Rejected != "" {
+		return storage.Categorize(storage.ErrRejected, fmt.Errorf("declaration rejected by scanner %s: %s", report.Rejected, report.Reason))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package scan
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// ScannerConfig configures a scanner. Exactly one of URL (an
+// HTTPScanner) or Patterns (a PatternScanner) is required.
+type ScannerConfig struct {
+	Name string `json:"name"`
+
+	URL string `json:"url,omitempty"`
+
+	Patterns []string `json:"patterns,omitempty"`
+
+	// Reject rejects declarations matching Patterns.
+	Reject bool `json:"reject,omitempty"`
+
+	// FailOpen annotates instead of rejecting declarations when the
+	// scanner fails.
+	FailOpen bool `json:"fail_open,omitempty"`
+}
+
+// Config configures the scanners of a Chain.
+type Config struct {
+	Scanners []ScannerConfig `json:"scanners"`
+}
+
+// ReadConfig reads a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding scan config: %w", err)
+	}
+	return c, nil
+}
+
+// ReadConfigFile reads a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Options returns the Chain options configured by c.
+func (c *Config) Options() ([]Option, error) {
+	var opts []Option
+	names := make(map[string]struct{})
+	for i, s := range c.Scanners {
+		if s.Name == "" {
+			return nil, fmt.Errorf("scanner %d: empty name", i)
+		}
+		if _, ok := names[s.Name]; ok {
+			return nil, fmt.Errorf("scanner %s: duplicate name", s.Name)
+		}
+		names[s.Name] = struct{}{}
+		if (s.URL == "") == (len(s.Patterns) < 1) {
+			return nil, fmt.Errorf("scanner %s: %w", s.Name, errors.New("exactly one of url or patterns is required"))
+		}
+		if s.URL != "" {
+			if s.Reject {
+				return nil, fmt.Errorf("scanner %s: %w", s.Name, errors.New("reject requires patterns"))
+			}
+			opts = append(opts, WithScanner(s.Name, &HTTPScanner{URL: s.URL}, s.FailOpen))
+			continue
+		}
+		ps := &PatternScanner{Reject: s.Reject}
+		for _, pattern := range s.Patterns {
+			p, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("scanner %s: %w", s.Name, err)
+			}
+			ps.Patterns = append(ps.Patterns, p)
+		}
+		opts = append(opts, WithScanner(s.Name, ps, s.FailOpen))
+	}
+	return opts, nil
+}
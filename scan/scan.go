@@ -0,0 +1,115 @@
+// Package scan runs declarations through pluggable scanners before
+// they are stored. Scanners may reject a declaration (e.g. one that
+// contains a secret or violates a policy) or annotate it with notes
+// that are returned to whoever uploaded it.
+package scan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// Result is the result of scanning a declaration.
+type Result struct {
+	// Reject prevents the declaration from being stored.
+	Reject bool `json:"reject,omitempty"`
+
+	// Reason is why the declaration was rejected.
+	Reason string `json:"reason,omitempty"`
+
+	// Annotations are notes about the declaration.
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// Scanner scans declarations before they are stored.
+type Scanner interface {
+	// Scan scans d. A nil Result is the same as an empty Result.
+	Scan(ctx context.Context, d *ddm.Declaration) (*Result, error)
+}
+
+// ScannerFunc adapts a function to a Scanner.
+type ScannerFunc func(ctx context.Context, d *ddm.Declaration) (*Result, error)
+
+// Scan calls f.
+func (f ScannerFunc) Scan(ctx context.Context, d *ddm.Declaration) (*Result, error) {
+	return f(ctx, d)
+}
+
+// Annotation is a note about a declaration by a scanner.
+type Annotation struct {
+	Scanner string `json:"scanner"`
+	Message string `json:"message"`
+}
+
+// Report is the result of scanning a declaration with all scanners.
+type Report struct {
+	// Rejected is the name of the scanner that rejected the
+	// declaration. Empty if the declaration was not rejected.
+	Rejected string `json:"rejected,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+
+	Annotations []Annotation `json:"annotations,omitempty"`
+}
+
+type namedScanner struct {
+	name     string
+	scanner  Scanner
+	failOpen bool
+}
+
+// Chain scans declarations with scanners in the order they were added.
+// Scanning stops at the first scanner that rejects the declaration.
+type Chain struct {
+	scanners []namedScanner
+}
+
+type Option func(*Chain)
+
+// WithScanner adds scanner to the chain. If failOpen is true then the
+// errors of scanner are added as annotations instead of failing the scan.
+func WithScanner(name string, scanner Scanner, failOpen bool) Option {
+	return func(c *Chain) {
+		c.scanners = append(c.scanners, namedScanner{name: name, scanner: scanner, failOpen: failOpen})
+	}
+}
+
+// New creates a new Chain.
+func New(opts ...Option) *Chain {
+	c := new(Chain)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Scan scans d with the scanners of c.
+func (c *Chain) Scan(ctx context.Context, d *ddm.Declaration) (*Report, error) {
+	report := new(Report)
+	for _, s := range c.scanners {
+		result, err := s.scanner.Scan(ctx, d)
+		if err != nil {
+			if !s.failOpen {
+				return nil, fmt.Errorf("scanner %s: %w", s.name, err)
+			}
+			report.Annotations = append(report.Annotations, Annotation{
+				Scanner: s.name,
+				Message: fmt.Sprintf("scan failed: %v", err),
+			})
+			continue
+		}
+		if result == nil {
+			continue
+		}
+		for _, a := range result.Annotations {
+			report.Annotations = append(report.Annotations, Annotation{Scanner: s.name, Message: a})
+		}
+		if result.Reject {
+			report.Rejected = s.name
+			report.Reason = result.Reason
+			break
+		}
+	}
+	return report, nil
+}
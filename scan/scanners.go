@@ -0,0 +1,76 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// maxResultSize is the maximum size of the JSON result of an HTTP scanner.
+const maxResultSize = 65536
+
+// HTTPScanner posts the declaration JSON to an external scanner at URL.
+// The scanner responds with a JSON Result. This is how scanners
+// written in other languages or policy engines (e.g. OPA behind a
+// small adapter) are plugged in.
+type HTTPScanner struct {
+	URL    string
+	Client *http.Client
+}
+
+// Scan posts d to the scanner and decodes its Result.
+func (s *HTTPScanner) Scan(ctx context.Context, d *ddm.Declaration) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(d.Raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("scanner: unexpected HTTP status: %s", resp.Status)
+	}
+	result := new(Result)
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxResultSize)).Decode(result); err != nil {
+		return nil, fmt.Errorf("decoding scanner result: %w", err)
+	}
+	return result, nil
+}
+
+// PatternScanner matches regular expressions against the payload JSON
+// of declarations. It is a simple built-in secret detector.
+type PatternScanner struct {
+	Patterns []*regexp.Regexp
+
+	// Reject rejects declarations whose payloads match. Otherwise
+	// matching declarations are only annotated.
+	Reject bool
+}
+
+// Scan matches the patterns of s against the payload of d.
+func (s *PatternScanner) Scan(_ context.Context, d *ddm.Declaration) (*Result, error) {
+	result := new(Result)
+	for _, p := range s.Patterns {
+		if p.Match(d.PayloadJSON) {
+			result.Annotations = append(result.Annotations, fmt.Sprintf("payload matches pattern %q", p.String()))
+		}
+	}
+	if s.Reject && len(result.Annotations) > 0 {
+		result.Reject = true
+		result.Reason = "payload matches secret patterns"
+	}
+	return result, nil
+}
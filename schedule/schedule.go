@@ -0,0 +1,158 @@
+// Package schedule activates and deactivates time-bounded set and
+// enrollment assignments.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Storage is the storage needed to run assignment schedules.
+type Storage interface {
+	storage.AssignmentScheduleStorage
+	storage.SetDeclarationStorer
+	storage.SetDeclarationRemover
+	storage.EnrollmentSetStorer
+	storage.EnrollmentSetRemover
+}
+
+// Notifier notifies enrollments of changed declarations and sets.
+type Notifier interface {
+	Changed(ctx context.Context, declarations []string, sets []string, ids []string) error
+}
+
+// Scheduler activates and deactivates assignment schedules.
+type Scheduler struct {
+	store    Storage
+	notifier Notifier
+	logger   log.Logger
+	now      func() time.Time
+}
+
+type Option func(s *Scheduler)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(s *Scheduler) {
+		s.logger = logger
+	}
+}
+
+// WithNotifier notifies enrollments of activated and deactivated assignments.
+func WithNotifier(n Notifier) Option {
+	return func(s *Scheduler) {
+		s.notifier = n
+	}
+}
+
+// New creates a new scheduler.
+func New(store Storage, opts ...Option) *Scheduler {
+	s := &Scheduler{
+		store:  store,
+		logger: log.NopLogger,
+		now:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// associate makes (or, if remove is true, removes) the association of a.
+func (s *Scheduler) associate(ctx context.Context, a *storage.AssignmentSchedule, remove bool) (changed bool, err error) {
+	switch a.Kind {
+	case storage.AssignmentSetDeclaration:
+		if remove {
+			return s.store.RemoveSetDeclaration(ctx, a.Set, a.Declaration)
+		}
+		return s.store.StoreSetDeclaration(ctx, a.Set, a.Declaration)
+	case storage.AssignmentEnrollmentSet:
+		if remove {
+			return s.store.RemoveEnrollmentSet(ctx, a.Enrollment, a.Set)
+		}
+		return s.store.StoreEnrollmentSet(ctx, a.Enrollment, a.Set)
+	}
+	return false, fmt.Errorf("unknown assignment schedule kind: %q", a.Kind)
+}
+
+// notify notifies the enrollments affected by the association of a.
+func (s *Scheduler) notify(ctx context.Context, a *storage.AssignmentSchedule) error {
+	if s.notifier == nil {
+		return nil
+	}
+	if a.Kind == storage.AssignmentEnrollmentSet {
+		return s.notifier.Changed(ctx, nil, nil, []string{a.Enrollment})
+	}
+	return s.notifier.Changed(ctx, nil, []string{a.Set}, nil)
+}
+
+// Run activates schedules whose start has passed and deactivates (and
+// deletes) schedules whose end has passed. Enrollments are notified of
+// changed associations. Errors with individual schedules are logged and
+// the remaining schedules are still run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	logger := ctxlog.Logger(ctx, s.logger)
+	schedules, err := s.store.RetrieveAssignmentSchedules(ctx)
+	if err != nil {
+		return fmt.Errorf("retrieving assignment schedules: %w", err)
+	}
+	now := s.now()
+	for _, a := range schedules {
+		logger := logger.With("schedule", a.ID)
+		var changed bool
+		if a.End != nil && !now.Before(*a.End) {
+			if a.Active {
+				if changed, err = s.associate(ctx, a, true); err != nil {
+					logger.Info(logkeys.Message, "deactivating assignment schedule", logkeys.Error, err)
+					continue
+				}
+			}
+			if err = s.store.DeleteAssignmentSchedule(ctx, a.ID); err != nil {
+				logger.Info(logkeys.Message, "deleting assignment schedule", logkeys.Error, err)
+				continue
+			}
+			logger.Debug(logkeys.Message, "deactivated assignment schedule", "changed", changed)
+		} else if !a.Active && (a.Start == nil || !now.Before(*a.Start)) {
+			if changed, err = s.associate(ctx, a, false); err != nil {
+				logger.Info(logkeys.Message, "activating assignment schedule", logkeys.Error, err)
+				continue
+			}
+			a.Active = true
+			if err = s.store.StoreAssignmentSchedule(ctx, a); err != nil {
+				logger.Info(logkeys.Message, "storing assignment schedule", logkeys.Error, err)
+				continue
+			}
+			logger.Debug(logkeys.Message, "activated assignment schedule", "changed", changed)
+		}
+		if changed {
+			if err = s.notify(ctx, a); err != nil {
+				logger.Info(logkeys.Message, "notifying", logkeys.Error, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Watch runs the schedules immediately and then every interval until
+// ctx is done.
+func (s *Scheduler) Watch(ctx context.Context, interval time.Duration) {
+	logger := ctxlog.Logger(ctx, s.logger)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Run(ctx); err != nil {
+			logger.Info(logkeys.Message, "running assignment schedules", logkeys.Error, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
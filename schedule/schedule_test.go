@@ -0,0 +1,121 @@
+package schedule
+
+import (
+	"context"
+	"hash"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+type testNotifier struct {
+	sets []string
+	ids  []string
+}
+
+func (n *testNotifier) Changed(_ context.Context, _ []string, sets []string, ids []string) error {
+	n.sets = append(n.sets, sets...)
+	n.ids = append(n.ids, ids...)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	const testPath = "teststor"
+	defer os.RemoveAll(testPath)
+	store, err := file.New(testPath, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.wifi","Payload":{"Echo":"Bar"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	end := start.Add(8 * time.Hour)
+	for _, a := range []*storage.AssignmentSchedule{
+		{ID: "event", Kind: storage.AssignmentSetDeclaration, Set: "event", Declaration: d.Identifier, Start: &start, End: &end},
+		{ID: "loaner", Kind: storage.AssignmentEnrollmentSet, Set: "event", Enrollment: "E1", End: &end},
+	} {
+		if err = store.StoreAssignmentSchedule(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n := new(testNotifier)
+	s := New(store, WithNotifier(n))
+
+	run := func(now time.Time) {
+		t.Helper()
+		s.now = func() time.Time { return now }
+		if err := s.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// before the start only the unbounded start schedule is active
+	run(start.Add(-time.Hour))
+	if have, want := n.ids, []string{"E1"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified ids: have %v, want %v", have, want)
+	}
+	if have, err := store.RetrieveSetDeclarations(ctx, "event"); err != nil {
+		t.Fatal(err)
+	} else if len(have) != 0 {
+		t.Errorf("set declarations: have %v, want none", have)
+	}
+
+	run(start)
+	if have, want := n.sets, []string{"event"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified sets: have %v, want %v", have, want)
+	}
+	if have, err := store.RetrieveSetDeclarations(ctx, "event"); err != nil {
+		t.Fatal(err)
+	} else if want := []string{d.Identifier}; !reflect.DeepEqual(have, want) {
+		t.Errorf("set declarations: have %v, want %v", have, want)
+	}
+	schedules, err := store.RetrieveAssignmentSchedules(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range schedules {
+		if !a.Active {
+			t.Errorf("schedule not active: %s", a.ID)
+		}
+	}
+
+	// running again changes nothing
+	run(start.Add(time.Hour))
+	if have, want := len(n.sets)+len(n.ids), 2; have != want {
+		t.Errorf("notifications: have %d, want %d", have, want)
+	}
+
+	// after the end both are dissociated and the schedules deleted
+	run(end)
+	if have, want := n.sets, []string{"event", "event"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified sets: have %v, want %v", have, want)
+	}
+	if have, want := n.ids, []string{"E1", "E1"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("notified ids: have %v, want %v", have, want)
+	}
+	if have, err := store.RetrieveEnrollmentSets(ctx, "E1"); err != nil {
+		t.Fatal(err)
+	} else if len(have) != 0 {
+		t.Errorf("enrollment sets: have %v, want none", have)
+	}
+	if schedules, err = store.RetrieveAssignmentSchedules(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(schedules) != 0 {
+		t.Errorf("schedules: have %d, want none", len(schedules))
+	}
+}
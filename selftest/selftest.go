@@ -0,0 +1,129 @@
+// Package selftest checks the configured dependencies of the server at startup.
+//
+// A failed check is reported with a diagnostic rather than erroring at
+// first use (e.g. when a device first synchronizes).
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Check is a named self-test check.
+type Check struct {
+	Name string
+
+	// Run performs the check and returns a diagnostic error on failure.
+	Run func(ctx context.Context) error
+}
+
+// Result is the result of a check.
+type Result struct {
+	Name     string        `json:"name"`
+	OK       bool          `json:"ok"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Run runs checks in order and returns their results.
+// Returns true if all checks passed.
+func Run(ctx context.Context, checks ...Check) ([]Result, bool) {
+	ok := true
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		start := time.Now()
+		err := c.Run(ctx)
+		r := Result{Name: c.Name, OK: err == nil, Duration: time.Since(start)}
+		if err != nil {
+			r.Error = err.Error()
+			ok = false
+		}
+		results = append(results, r)
+	}
+	return results, ok
+}
+
+// Storage is the storage that is checked.
+type Storage interface {
+	storage.DeclarationStorer
+	storage.DeclarationAPIRetriever
+	storage.DeclarationDeleter
+}
+
+// TestDeclarationPrefix prefixes the identifier of the throwaway declaration.
+const TestDeclarationPrefix = "com.github.jessepeterson.kmfddm.self-test."
+
+// StorageCheck returns a check that stores, retrieves, and deletes a
+// throwaway declaration in store.
+func StorageCheck(store Storage) Check {
+	return Check{
+		Name: "storage",
+		Run: func(ctx context.Context) error {
+			b := make([]byte, 8)
+			rand.Read(b)
+			echo := fmt.Sprintf("%x", b)
+			d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"` + TestDeclarationPrefix + echo + `","Payload":{"Echo":"` + echo + `"}}`))
+			if err != nil {
+				return fmt.Errorf("parsing test declaration: %w", err)
+			}
+			if _, err = store.StoreDeclaration(ctx, d); err != nil {
+				return fmt.Errorf("writing test declaration (check storage permissions and connectivity): %w", err)
+			}
+			// always try to clean up
+			deleted := false
+			defer func() {
+				if !deleted {
+					store.DeleteDeclaration(ctx, d.Identifier)
+				}
+			}()
+			d2, err := store.RetrieveDeclaration(ctx, d.Identifier)
+			if err != nil {
+				return fmt.Errorf("reading test declaration: %w", err)
+			} else if d2 == nil || !bytes.Contains(d2.PayloadJSON, []byte(echo)) {
+				return errors.New("reading test declaration: read declaration does not match written declaration")
+			}
+			if deleted, err = store.DeleteDeclaration(ctx, d.Identifier); err != nil {
+				return fmt.Errorf("deleting test declaration: %w", err)
+			} else if !deleted {
+				return errors.New("deleting test declaration: not deleted")
+			}
+			return nil
+		},
+	}
+}
+
+// Doer executes an HTTP request.
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ReachableCheck returns a check named name that the server at url
+// can be reached with client. Any HTTP response means the server is
+// reachable: the check does not authenticate.
+func ReachableCheck(name, url string, client Doer) Check {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+			if err != nil {
+				return fmt.Errorf("creating request (check the URL): %w", err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				return fmt.Errorf("connecting to %s (check the URL and that the server is running): %w", url, err)
+			}
+			return resp.Body.Close()
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package selftest
+
+import (
+	"context"
+	"hash"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func TestRun(t *testing.T) {
+	const testPath = "teststor"
+	defer os.RemoveAll(testPath)
+	store, err := file.New(testPath, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	url := srv.URL
+
+	ctx := context.Background()
+	results, ok := Run(ctx, StorageCheck(store), ReachableCheck("notifier", url, nil))
+	if !ok {
+		t.Fatalf("expected checks to pass: %v", results)
+	}
+	if have, want := len(results), 2; have != want {
+		t.Fatalf("results: have: %d, want: %d", have, want)
+	}
+
+	ids, err := store.RetrieveDeclarations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("expected test declaration to be deleted: %v", ids)
+	}
+
+	srv.Close()
+	results, ok = Run(ctx, ReachableCheck("notifier", url, nil))
+	if ok || results[0].OK || results[0].Error == "" {
+		t.Errorf("expected unreachable notifier to fail: %v", results)
+	}
+}
@@ -0,0 +1,112 @@
+// Package servedhistory records the DDM documents served to enrollments.
+//
+// The hashes (and optionally the bodies) of the tokens and declaration
+// items served to each enrollment are recorded when they change. The
+// recorded history shows when the content served to an enrollment
+// last changed to help correlate device problems with configuration
+// changes.
+package servedhistory
+
+import (
+	"context"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/log/ctxlog"
+	"github.com/jessepeterson/kmfddm/log/logkeys"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// Recorder records the tokens and declaration items served by an
+// underlying retriever. It implements storage.TokensDeclarationItemsRetriever.
+type Recorder struct {
+	store   storage.TokensDeclarationItemsRetriever
+	history storage.ServedHistoryStorage
+	bodies  bool
+	max     int
+	logger  log.Logger
+	now     func() time.Time
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithLogger sets the logger.
+func WithLogger(logger log.Logger) Option {
+	return func(r *Recorder) {
+		r.logger = logger
+	}
+}
+
+// WithBodies records the bodies of served documents as well as their hashes.
+func WithBodies() Option {
+	return func(r *Recorder) {
+		r.bodies = true
+	}
+}
+
+// WithMax keeps at most max served documents of each kind per enrollment.
+// A max of zero keeps all served documents.
+func WithMax(max int) Option {
+	return func(r *Recorder) {
+		r.max = max
+	}
+}
+
+// New creates a new Recorder that records the documents served by
+// store in history. It panics if either are nil.
+func New(store storage.TokensDeclarationItemsRetriever, history storage.ServedHistoryStorage, opts ...Option) *Recorder {
+	if store == nil || history == nil {
+		panic("nil store or history")
+	}
+	r := &Recorder{
+		store:   store,
+		history: history,
+		max:     storage.DefaultServedHistoryMax,
+		logger:  log.NopLogger,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// record records body as served to enrollmentID.
+// Errors are logged rather than failing the DDM request.
+func (r *Recorder) record(ctx context.Context, enrollmentID, kind string, body []byte) {
+	logger := ctxlog.Logger(ctx, r.logger).With(logkeys.EnrollmentID, enrollmentID, "kind", kind)
+	doc, err := storage.NewServedDocument(kind, body, r.bodies, r.now().UTC().Truncate(time.Second))
+	if err != nil {
+		logger.Info(logkeys.Message, "creating served document", logkeys.Error, err)
+		return
+	}
+	changed, err := r.history.StoreServedDocument(ctx, enrollmentID, doc, r.max)
+	if err != nil {
+		logger.Info(logkeys.Message, "storing served document", logkeys.Error, err)
+		return
+	}
+	if changed {
+		logger.Debug(logkeys.Message, "served document changed", "hash", doc.Hash)
+	}
+}
+
+// RetrieveDeclarationItemsJSON retrieves and records the Declaration Items for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (r *Recorder) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	b, err := r.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err == nil {
+		r.record(ctx, enrollmentID, storage.ServedDeclarationItems, b)
+	}
+	return b, err
+}
+
+// RetrieveTokensJSON retrieves and records the Sync Tokens for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (r *Recorder) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	b, err := r.store.RetrieveTokensJSON(ctx, enrollmentID)
+	if err == nil {
+		r.record(ctx, enrollmentID, storage.ServedTokens, b)
+	}
+	return b, err
+}
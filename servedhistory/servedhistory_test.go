@@ -0,0 +1,77 @@
+package servedhistory
+
+import (
+	"context"
+	"errors"
+	"hash"
+	"os"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+type testStore struct {
+	tokens string
+	err    error
+}
+
+func (s *testStore) RetrieveDeclarationItemsJSON(_ context.Context, _ string) ([]byte, error) {
+	return []byte(`{"DeclarationsToken":"` + s.tokens + `"}`), s.err
+}
+
+func (s *testStore) RetrieveTokensJSON(_ context.Context, _ string) ([]byte, error) {
+	return []byte(`{"SyncTokens":{"DeclarationsToken":"` + s.tokens + `"}}`), s.err
+}
+
+func TestRecorder(t *testing.T) {
+	const testPath = "teststor"
+	defer os.RemoveAll(testPath)
+	history, err := file.New(testPath, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	s := &testStore{tokens: "a"}
+	r := New(s, history, WithBodies(), WithMax(2))
+
+	for _, tokens := range []string{"a", "a", "b", "c", "c"} {
+		s.tokens = tokens
+		if _, err = r.RetrieveTokensJSON(ctx, "E1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = r.RetrieveDeclarationItemsJSON(ctx, "E1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// failed retrievals are not recorded
+	s.err = errors.New("test error")
+	s.tokens = "d"
+	if _, err = r.RetrieveTokensJSON(ctx, "E1"); err == nil {
+		t.Fatal("expected error")
+	}
+
+	docs, err := history.RetrieveServedHistory(ctx, "E1", storage.ServedTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("history length: have: %d, want: 2", len(docs))
+	}
+	for i, want := range []string{"c", "b"} {
+		if have, want := string(docs[i].Body), `{"SyncTokens":{"DeclarationsToken":"`+want+`"}}`; have != want {
+			t.Errorf("history[%d]: have: %s, want: %s", i, have, want)
+		}
+	}
+
+	docs, err = history.RetrieveServedHistory(ctx, "E1", storage.ServedDeclarationItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 1 {
+		t.Errorf("history length: have: %d, want: 1", len(docs))
+	}
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// EnrollmentAnnotation is free-form helpdesk context attached to an
+// enrollment. It has no effect on the DDM an enrollment is served.
+type EnrollmentAnnotation struct {
+	EnrollmentID string    `json:"enrollment_id"`
+	Notes        string    `json:"notes,omitempty"`
+	Owner        string    `json:"owner,omitempty"`
+	Contact      string    `json:"contact,omitempty"`
+	AssetTags    []string  `json:"asset_tags,omitempty"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Validate checks the annotation for errors.
+func (a *EnrollmentAnnotation) Validate() error {
+	return Categorize(ErrInvalid, a.validate())
+}
+
+func (a *EnrollmentAnnotation) validate() error {
+	if a == nil {
+		return errors.New("nil annotation")
+	} else if a.EnrollmentID == "" {
+		return errors.New("missing enrollment ID")
+	} else if err := ValidateIdentifier("enrollment ID", a.EnrollmentID); err != nil {
+		return err
+	}
+	for _, tag := range a.AssetTags {
+		if tag == "" {
+			return errors.New("empty asset tag")
+		}
+	}
+	return nil
+}
+
+// EnrollmentAnnotationRetriever retrieves the annotations of enrollments.
+type EnrollmentAnnotationRetriever interface {
+	// RetrieveEnrollmentAnnotations retrieves the annotations of
+	// enrollmentIDs keyed by enrollment ID. Enrollments without an
+	// annotation are not included.
+	RetrieveEnrollmentAnnotations(ctx context.Context, enrollmentIDs []string) (map[string]*EnrollmentAnnotation, error)
+}
+
+// EnrollmentAnnotationStorage stores the annotations of enrollments.
+type EnrollmentAnnotationStorage interface {
+	EnrollmentAnnotationRetriever
+
+	// StoreEnrollmentAnnotation stores the annotation of its enrollment.
+	// Any existing annotation of the enrollment is replaced.
+	StoreEnrollmentAnnotation(ctx context.Context, annotation *EnrollmentAnnotation) error
+
+	// DeleteEnrollmentAnnotation deletes the annotation of enrollmentID.
+	// Returns true if the enrollment had an annotation.
+	DeleteEnrollmentAnnotation(ctx context.Context, enrollmentID string) (bool, error)
+}
@@ -0,0 +1,220 @@
+// Package chaos is a storage decorator that injects latency and errors.
+// It is intended for tests and staging environments to exercise
+// retry logic and error handling.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ErrInjected is the error returned by injected faults.
+var ErrInjected = storage.Categorize(storage.ErrUnavailable, errors.New("chaos: injected storage error"))
+
+// Storage is the storage that is wrapped.
+type Storage interface {
+	storage.DeclarationAPIStorage
+	storage.EnrollmentIDRetriever
+	storage.EnrollmentDeclarationStorage
+	storage.StatusStorer
+	storage.SetDeclarationStorage
+	storage.SetRetreiver
+	storage.EnrollmentSetStorage
+	storage.StatusAPIStorage
+	storage.IdempotencyStorage
+	storage.SetStatusSummaryRetriever
+	storage.EnrollmentDeclarationsRetriever
+	storage.CounterStorage
+	storage.StatusValueHistoryRetriever
+	storage.ProfileStorage
+	storage.SetSnapshotStorage
+	storage.PendingChangeStorage
+	storage.UsageStorage
+	storage.DDMRepairer
+	storage.EnrollmentDDMRefresher
+	storage.DDMRebuildStorage
+	storage.PendingRemovalsRetriever
+	storage.DeclarationsPager
+	storage.SetsPager
+	storage.SetDeclarationConditionStorage
+	storage.DeclarationUsageRetriever
+	storage.HashMigrator
+	storage.EnrollmentFreezeStorage
+	storage.AssignmentScheduleStorage
+	storage.StatusQuarantineRetriever
+	storage.EnrollmentAnnotationStorage
+	storage.SetPatternStorage
+	storage.JournalRetriever
+	storage.EnrollmentStagedSetStorage
+	storage.LifecycleStageStorage
+	storage.EnrollmentRegistrationStorage
+	storage.CredentialStorage
+	storage.ComplianceStorage
+	storage.ServedHistoryStorage
+	storage.NotificationQueueStorage
+	storage.EnrollmentNotificationStorage
+	storage.DeclarationStatusEnrollmentsRetriever
+	storage.SetMetadataStorage
+	storage.DeclarationTagStorage
+	storage.OwnerStorage
+}
+
+// Duration is a time.Duration that is a string (e.g. "10ms") in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	dur, err := time.ParseDuration(s)
+	*d = Duration(dur)
+	return err
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Fault configures the faults injected into a storage method.
+type Fault struct {
+	// Latency is added before each call.
+	Latency Duration `json:"latency,omitempty"`
+
+	// Jitter is the maximum random latency added on top of Latency.
+	Jitter Duration `json:"jitter,omitempty"`
+
+	// ErrorRate is the probability (from 0 to 1) that a call returns
+	// ErrInjected instead of calling the wrapped storage.
+	ErrorRate float64 `json:"error_rate,omitempty"`
+}
+
+// Config configures the injected faults.
+type Config struct {
+	// Seed seeds the random number generator. Using the same seed
+	// (and the same sequence of calls) injects the same faults.
+	Seed int64 `json:"seed"`
+
+	// Default is the fault of methods not in Methods.
+	Default Fault `json:"default"`
+
+	// Methods are the faults keyed by storage method name (e.g. "StoreDeclaration").
+	Methods map[string]Fault `json:"methods,omitempty"`
+}
+
+// Validate checks c for unknown method names and invalid values.
+func (c *Config) Validate() error {
+	if err := c.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	storageType := reflect.TypeOf((*Storage)(nil)).Elem()
+	for name, f := range c.Methods {
+		if _, ok := storageType.MethodByName(name); !ok {
+			return fmt.Errorf("unknown storage method: %s", name)
+		}
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("method %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (f Fault) validate() error {
+	if f.ErrorRate < 0 || f.ErrorRate > 1 {
+		return fmt.Errorf("error rate out of range: %v", f.ErrorRate)
+	}
+	if f.Latency < 0 || f.Jitter < 0 {
+		return errors.New("negative latency or jitter")
+	}
+	return nil
+}
+
+// ReadConfig reads and validates a JSON Config from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	c := new(Config)
+	if err := json.NewDecoder(r).Decode(c); err != nil {
+		return nil, fmt.Errorf("decoding chaos config: %w", err)
+	}
+	return c, c.Validate()
+}
+
+// ReadConfigFile reads and validates a JSON Config from the file at path.
+func ReadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// Chaos wraps storage and injects faults into its methods.
+type Chaos struct {
+	store  Storage
+	config *Config
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// New creates a new Chaos storage wrapping store using config.
+// A nil config injects no faults.
+func New(store Storage, config *Config) *Chaos {
+	if store == nil {
+		panic("nil store")
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	return &Chaos{
+		store:  store,
+		config: config,
+		rand:   rand.New(rand.NewSource(config.Seed)),
+	}
+}
+
+// fault returns the fault configured for method.
+func (c *Chaos) fault(method string) Fault {
+	if f, ok := c.config.Methods[method]; ok {
+		return f
+	}
+	return c.config.Default
+}
+
+// inject delays and returns an error according to the fault for method.
+func (c *Chaos) inject(ctx context.Context, method string) error {
+	f := c.fault(method)
+	delay := time.Duration(f.Latency)
+	var fail bool
+	c.mu.Lock()
+	if f.Jitter > 0 {
+		delay += time.Duration(c.rand.Int63n(int64(f.Jitter)))
+	}
+	if f.ErrorRate > 0 {
+		fail = c.rand.Float64() < f.ErrorRate
+	}
+	c.mu.Unlock()
+	if delay > 0 {
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	if fail {
+		return fmt.Errorf("%s: %w", method, ErrInjected)
+	}
+	return nil
+}
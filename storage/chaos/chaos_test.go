@@ -0,0 +1,107 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"hash"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/storage/file"
+	"github.com/jessepeterson/kmfddm/storage/test"
+)
+
+func newFileStorage(t *testing.T) *file.File {
+	s, err := file.New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestChaosNoFaults(t *testing.T) {
+	s := New(newFileStorage(t), nil)
+	test.TestBasic(t, s, context.Background())
+}
+
+func TestChaosErrors(t *testing.T) {
+	s := New(newFileStorage(t), &Config{
+		Methods: map[string]Fault{"RetrieveSets": {ErrorRate: 1}},
+	})
+	ctx := context.Background()
+	if _, err := s.RetrieveSets(ctx); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected injected error, got: %v", err)
+	}
+	if _, err := s.RetrieveDeclarations(ctx); err != nil {
+		t.Errorf("expected no error, got: %v", err)
+	}
+}
+
+func TestChaosDeterministic(t *testing.T) {
+	config := &Config{Seed: 42, Default: Fault{ErrorRate: 0.5}}
+	run := func() []bool {
+		s := New(newFileStorage(t), config)
+		var ret []bool
+		for i := 0; i < 32; i++ {
+			_, err := s.RetrieveSets(context.Background())
+			ret = append(ret, err != nil)
+		}
+		return ret
+	}
+	a, b := run(), run()
+	var failed int
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("fault %d differs between runs with the same seed", i)
+		}
+		if a[i] {
+			failed++
+		}
+	}
+	if failed == 0 || failed == len(a) {
+		t.Errorf("expected some but not all calls to fail: %d of %d", failed, len(a))
+	}
+}
+
+func TestChaosLatency(t *testing.T) {
+	s := New(newFileStorage(t), &Config{Default: Fault{Latency: Duration(20 * time.Millisecond)}})
+	start := time.Now()
+	if _, err := s.RetrieveSets(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected latency of at least 20ms, got %v", elapsed)
+	}
+
+	// latency respects context cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.RetrieveSets(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled error, got: %v", err)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	c, err := ReadConfig(strings.NewReader(`{"seed":1,"default":{"latency":"5ms","jitter":"1ms"},"methods":{"StoreDeclaration":{"error_rate":0.25}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := time.Duration(c.Default.Latency), 5*time.Millisecond; have != want {
+		t.Errorf("latency: have %v, want %v", have, want)
+	}
+	if have, want := c.Methods["StoreDeclaration"].ErrorRate, 0.25; have != want {
+		t.Errorf("error rate: have %v, want %v", have, want)
+	}
+
+	for _, bad := range []string{
+		`{"methods":{"NoSuchMethod":{"error_rate":0.5}}}`,
+		`{"default":{"error_rate":2}}`,
+		`{"default":{"latency":"soon"}}`,
+	} {
+		if _, err = ReadConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for config: %s", bad)
+		}
+	}
+}
@@ -0,0 +1,765 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func (c *Chaos) TouchDeclaration(ctx context.Context, declarationID string) error {
+	if err := c.inject(ctx, "TouchDeclaration"); err != nil {
+		return err
+	}
+	return c.store.TouchDeclaration(ctx, declarationID)
+}
+
+func (c *Chaos) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	if err := c.inject(ctx, "StoreDeclaration"); err != nil {
+		return false, err
+	}
+	return c.store.StoreDeclaration(ctx, d)
+}
+
+func (c *Chaos) DeleteDeclaration(ctx context.Context, declarationID string) (bool, error) {
+	if err := c.inject(ctx, "DeleteDeclaration"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteDeclaration(ctx, declarationID)
+}
+
+func (c *Chaos) RetrieveDeclaration(ctx context.Context, declarationID string) (*ddm.Declaration, error) {
+	if err := c.inject(ctx, "RetrieveDeclaration"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclaration(ctx, declarationID)
+}
+
+func (c *Chaos) RetrieveDeclarationModTime(ctx context.Context, declarationID string) (time.Time, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationModTime"); err != nil {
+		return time.Time{}, err
+	}
+	return c.store.RetrieveDeclarationModTime(ctx, declarationID)
+}
+
+func (c *Chaos) RetrieveDeclarations(ctx context.Context) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveDeclarations"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarations(ctx)
+}
+
+func (c *Chaos) RetrieveEnrollmentIDs(ctx context.Context, declarations []string, sets []string, ids []string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentIDs"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentIDs(ctx, declarations, sets, ids)
+}
+
+func (c *Chaos) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	if err := c.inject(ctx, "RetrieveTokensJSON"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveTokensJSON(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationItemsJSON"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentDeclarationJSON"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
+}
+
+func (c *Chaos) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
+	if err := c.inject(ctx, "StoreDeclarationStatus"); err != nil {
+		return err
+	}
+	return c.store.StoreDeclarationStatus(ctx, enrollmentID, status)
+}
+
+func (c *Chaos) RetrieveDeclarationSets(ctx context.Context, declarationID string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationSets"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationSets(ctx, declarationID)
+}
+
+func (c *Chaos) RetrieveSetDeclarations(ctx context.Context, setName string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveSetDeclarations"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetDeclarations(ctx, setName)
+}
+
+func (c *Chaos) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := c.inject(ctx, "StoreSetDeclaration"); err != nil {
+		return false, err
+	}
+	return c.store.StoreSetDeclaration(ctx, setName, declarationID)
+}
+
+func (c *Chaos) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := c.inject(ctx, "RemoveSetDeclaration"); err != nil {
+		return false, err
+	}
+	return c.store.RemoveSetDeclaration(ctx, setName, declarationID)
+}
+
+func (c *Chaos) RetrieveSets(ctx context.Context) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveSets"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSets(ctx)
+}
+
+func (c *Chaos) RetrieveEnrollmentSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentSets"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentSets(ctx, enrollmentID)
+}
+
+func (c *Chaos) StoreEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := c.inject(ctx, "StoreEnrollmentSet"); err != nil {
+		return false, err
+	}
+	return c.store.StoreEnrollmentSet(ctx, enrollmentID, setName)
+}
+
+func (c *Chaos) RemoveEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := c.inject(ctx, "RemoveEnrollmentSet"); err != nil {
+		return false, err
+	}
+	return c.store.RemoveEnrollmentSet(ctx, enrollmentID, setName)
+}
+
+func (c *Chaos) RetrieveEnrollmentDeclarations(ctx context.Context, enrollmentID string) ([]storage.EnrollmentDeclaration, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentDeclarations"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationStatus"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]storage.StatusError, error) {
+	if err := c.inject(ctx, "RetrieveStatusErrors"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveStatusErrors(ctx, enrollmentIDs, since, until, offset, limit)
+}
+
+func (c *Chaos) RetrieveStatusValues(ctx context.Context, enrollmentIDs []string, pathPrefix string) (map[string][]storage.StatusValue, error) {
+	if err := c.inject(ctx, "RetrieveStatusValues"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveStatusValues(ctx, enrollmentIDs, pathPrefix)
+}
+
+func (c *Chaos) RetrieveStatusValueHistory(ctx context.Context, enrollmentIDs []string, path string) (map[string][]storage.StatusValue, error) {
+	if err := c.inject(ctx, "RetrieveStatusValueHistory"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveStatusValueHistory(ctx, enrollmentIDs, path)
+}
+
+func (c *Chaos) RetrieveStatusReport(ctx context.Context, q storage.StatusReportQuery) (*storage.StoredStatusReport, error) {
+	if err := c.inject(ctx, "RetrieveStatusReport"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveStatusReport(ctx, q)
+}
+
+func (c *Chaos) RetrieveSetStatusSummary(ctx context.Context, setName string) ([]storage.DeclarationStatusSummary, error) {
+	if err := c.inject(ctx, "RetrieveSetStatusSummary"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetStatusSummary(ctx, setName)
+}
+
+func (c *Chaos) RetrieveIdempotentResponse(ctx context.Context, key string) (*storage.IdempotentResponse, error) {
+	if err := c.inject(ctx, "RetrieveIdempotentResponse"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveIdempotentResponse(ctx, key)
+}
+
+func (c *Chaos) StoreIdempotentResponse(ctx context.Context, key string, resp *storage.IdempotentResponse) error {
+	if err := c.inject(ctx, "StoreIdempotentResponse"); err != nil {
+		return err
+	}
+	return c.store.StoreIdempotentResponse(ctx, key, resp)
+}
+
+func (c *Chaos) DeleteIdempotentResponses(ctx context.Context, before time.Time) error {
+	if err := c.inject(ctx, "DeleteIdempotentResponses"); err != nil {
+		return err
+	}
+	return c.store.DeleteIdempotentResponses(ctx, before)
+}
+
+func (c *Chaos) IncrementCounters(ctx context.Context, increments []storage.CounterIncrement) error {
+	if err := c.inject(ctx, "IncrementCounters"); err != nil {
+		return err
+	}
+	return c.store.IncrementCounters(ctx, increments)
+}
+
+func (c *Chaos) RetrieveCounters(ctx context.Context, scope, id string) (map[string]int64, error) {
+	if err := c.inject(ctx, "RetrieveCounters"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveCounters(ctx, scope, id)
+}
+
+func (c *Chaos) StoreProfile(ctx context.Context, profileID string, raw []byte) error {
+	if err := c.inject(ctx, "StoreProfile"); err != nil {
+		return err
+	}
+	return c.store.StoreProfile(ctx, profileID, raw)
+}
+
+func (c *Chaos) RetrieveProfile(ctx context.Context, profileID string) ([]byte, error) {
+	if err := c.inject(ctx, "RetrieveProfile"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveProfile(ctx, profileID)
+}
+
+func (c *Chaos) RetrieveSetSnapshots(ctx context.Context, setName string) ([]storage.SetSnapshot, error) {
+	if err := c.inject(ctx, "RetrieveSetSnapshots"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetSnapshots(ctx, setName)
+}
+
+func (c *Chaos) RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error) {
+	if err := c.inject(ctx, "RestoreSetSnapshot"); err != nil {
+		return false, err
+	}
+	return c.store.RestoreSetSnapshot(ctx, setName, snapshotID)
+}
+
+func (c *Chaos) StorePendingChange(ctx context.Context, change *storage.PendingChange) error {
+	if err := c.inject(ctx, "StorePendingChange"); err != nil {
+		return err
+	}
+	return c.store.StorePendingChange(ctx, change)
+}
+
+func (c *Chaos) RetrievePendingChanges(ctx context.Context) ([]*storage.PendingChange, error) {
+	if err := c.inject(ctx, "RetrievePendingChanges"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrievePendingChanges(ctx)
+}
+
+func (c *Chaos) RetrievePendingChange(ctx context.Context, id string) (*storage.PendingChange, error) {
+	if err := c.inject(ctx, "RetrievePendingChange"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrievePendingChange(ctx, id)
+}
+
+func (c *Chaos) DeletePendingChange(ctx context.Context, id string) error {
+	if err := c.inject(ctx, "DeletePendingChange"); err != nil {
+		return err
+	}
+	return c.store.DeletePendingChange(ctx, id)
+}
+
+func (c *Chaos) IncrementUsage(ctx context.Context, day string, setNames []string, syncs, statusReports int64) error {
+	if err := c.inject(ctx, "IncrementUsage"); err != nil {
+		return err
+	}
+	return c.store.IncrementUsage(ctx, day, setNames, syncs, statusReports)
+}
+
+func (c *Chaos) StoreUsageDeclarations(ctx context.Context, day, setName string, declarations int64) error {
+	if err := c.inject(ctx, "StoreUsageDeclarations"); err != nil {
+		return err
+	}
+	return c.store.StoreUsageDeclarations(ctx, day, setName, declarations)
+}
+
+func (c *Chaos) RetrieveUsage(ctx context.Context, from, to string) ([]storage.UsageRecord, error) {
+	if err := c.inject(ctx, "RetrieveUsage"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveUsage(ctx, from, to)
+}
+
+func (c *Chaos) RepairEnrollmentDDM(ctx context.Context, dryRun bool) (*storage.DDMRepairReport, error) {
+	if err := c.inject(ctx, "RepairEnrollmentDDM"); err != nil {
+		return nil, err
+	}
+	return c.store.RepairEnrollmentDDM(ctx, dryRun)
+}
+
+func (c *Chaos) RefreshEnrollmentDDM(ctx context.Context, enrollmentID string) error {
+	if err := c.inject(ctx, "RefreshEnrollmentDDM"); err != nil {
+		return err
+	}
+	return c.store.RefreshEnrollmentDDM(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveDDMRebuilds(ctx context.Context) ([]storage.DDMRebuild, error) {
+	if err := c.inject(ctx, "RetrieveDDMRebuilds"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDDMRebuilds(ctx)
+}
+
+func (c *Chaos) RetryDDMRebuilds(ctx context.Context) ([]string, error) {
+	if err := c.inject(ctx, "RetryDDMRebuilds"); err != nil {
+		return nil, err
+	}
+	return c.store.RetryDDMRebuilds(ctx)
+}
+
+func (c *Chaos) RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	if err := c.inject(ctx, "RetrievePendingRemovals"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrievePendingRemovals(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) RetrieveDeclarationsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationsPage"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationsPage(ctx, after, limit)
+}
+
+func (c *Chaos) RetrieveSetsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveSetsPage"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetsPage(ctx, after, limit)
+}
+
+func (c *Chaos) StoreSetDeclarationCondition(ctx context.Context, setName, declarationID string, cond *storage.DeclarationCondition) (bool, error) {
+	if err := c.inject(ctx, "StoreSetDeclarationCondition"); err != nil {
+		return false, err
+	}
+	return c.store.StoreSetDeclarationCondition(ctx, setName, declarationID, cond)
+}
+
+func (c *Chaos) RetrieveSetDeclarationConditions(ctx context.Context, setName string) (map[string]*storage.DeclarationCondition, error) {
+	if err := c.inject(ctx, "RetrieveSetDeclarationConditions"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetDeclarationConditions(ctx, setName)
+}
+
+func (c *Chaos) RetrieveDeclarationUsage(ctx context.Context, declarationIDs []string) ([]storage.DeclarationUsage, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationUsage"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationUsage(ctx, declarationIDs)
+}
+
+func (c *Chaos) MigrateHash(ctx context.Context, algorithm string) (bool, error) {
+	if err := c.inject(ctx, "MigrateHash"); err != nil {
+		return false, err
+	}
+	return c.store.MigrateHash(ctx, algorithm)
+}
+
+func (c *Chaos) StoreEnrollmentFreeze(ctx context.Context, freeze *storage.EnrollmentFreeze) error {
+	if err := c.inject(ctx, "StoreEnrollmentFreeze"); err != nil {
+		return err
+	}
+	return c.store.StoreEnrollmentFreeze(ctx, freeze)
+}
+
+func (c *Chaos) RetrieveEnrollmentFreeze(ctx context.Context, enrollmentID string) (*storage.EnrollmentFreeze, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentFreeze"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentFreeze(ctx, enrollmentID)
+}
+
+func (c *Chaos) DeleteEnrollmentFreeze(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := c.inject(ctx, "DeleteEnrollmentFreeze"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteEnrollmentFreeze(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveFrozenEnrollmentIDs(ctx context.Context, enrollmentIDs []string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveFrozenEnrollmentIDs"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveFrozenEnrollmentIDs(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) StoreAssignmentSchedule(ctx context.Context, schedule *storage.AssignmentSchedule) error {
+	if err := c.inject(ctx, "StoreAssignmentSchedule"); err != nil {
+		return err
+	}
+	return c.store.StoreAssignmentSchedule(ctx, schedule)
+}
+
+func (c *Chaos) RetrieveAssignmentSchedules(ctx context.Context) ([]*storage.AssignmentSchedule, error) {
+	if err := c.inject(ctx, "RetrieveAssignmentSchedules"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveAssignmentSchedules(ctx)
+}
+
+func (c *Chaos) DeleteAssignmentSchedule(ctx context.Context, id string) error {
+	if err := c.inject(ctx, "DeleteAssignmentSchedule"); err != nil {
+		return err
+	}
+	return c.store.DeleteAssignmentSchedule(ctx, id)
+}
+
+func (c *Chaos) RetrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string) (map[string][]storage.QuarantinedStatus, error) {
+	if err := c.inject(ctx, "RetrieveStatusQuarantine"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveStatusQuarantine(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) StoreEnrollmentAnnotation(ctx context.Context, annotation *storage.EnrollmentAnnotation) error {
+	if err := c.inject(ctx, "StoreEnrollmentAnnotation"); err != nil {
+		return err
+	}
+	return c.store.StoreEnrollmentAnnotation(ctx, annotation)
+}
+
+func (c *Chaos) RetrieveEnrollmentAnnotations(ctx context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentAnnotation, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentAnnotations"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentAnnotations(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) DeleteEnrollmentAnnotation(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := c.inject(ctx, "DeleteEnrollmentAnnotation"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteEnrollmentAnnotation(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveSetPatterns(ctx context.Context) ([]storage.SetPattern, error) {
+	if err := c.inject(ctx, "RetrieveSetPatterns"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetPatterns(ctx)
+}
+
+func (c *Chaos) StoreSetPattern(ctx context.Context, pattern *storage.SetPattern) (bool, error) {
+	if err := c.inject(ctx, "StoreSetPattern"); err != nil {
+		return false, err
+	}
+	return c.store.StoreSetPattern(ctx, pattern)
+}
+
+func (c *Chaos) RemoveSetPattern(ctx context.Context, pattern *storage.SetPattern) (bool, error) {
+	if err := c.inject(ctx, "RemoveSetPattern"); err != nil {
+		return false, err
+	}
+	return c.store.RemoveSetPattern(ctx, pattern)
+}
+
+func (c *Chaos) RetrieveJournal(ctx context.Context, after int64, limit int) ([]storage.JournalEntry, error) {
+	if err := c.inject(ctx, "RetrieveJournal"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveJournal(ctx, after, limit)
+}
+
+func (c *Chaos) RetrieveEnrollmentStagedSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentStagedSets"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentStagedSets(ctx, enrollmentID)
+}
+
+func (c *Chaos) StoreEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := c.inject(ctx, "StoreEnrollmentStagedSet"); err != nil {
+		return false, err
+	}
+	return c.store.StoreEnrollmentStagedSet(ctx, enrollmentID, setName)
+}
+
+func (c *Chaos) RemoveEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := c.inject(ctx, "RemoveEnrollmentStagedSet"); err != nil {
+		return false, err
+	}
+	return c.store.RemoveEnrollmentStagedSet(ctx, enrollmentID, setName)
+}
+
+func (c *Chaos) SwapEnrollmentStagedSets(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := c.inject(ctx, "SwapEnrollmentStagedSets"); err != nil {
+		return false, err
+	}
+	return c.store.SwapEnrollmentStagedSets(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveLifecycleStages(ctx context.Context) ([]storage.LifecycleStage, error) {
+	if err := c.inject(ctx, "RetrieveLifecycleStages"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveLifecycleStages(ctx)
+}
+
+func (c *Chaos) StoreLifecycleStage(ctx context.Context, stage *storage.LifecycleStage) (bool, error) {
+	if err := c.inject(ctx, "StoreLifecycleStage"); err != nil {
+		return false, err
+	}
+	return c.store.StoreLifecycleStage(ctx, stage)
+}
+
+func (c *Chaos) DeleteLifecycleStage(ctx context.Context, name string) (bool, error) {
+	if err := c.inject(ctx, "DeleteLifecycleStage"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteLifecycleStage(ctx, name)
+}
+
+func (c *Chaos) RetrieveEnrollmentLifecycleStage(ctx context.Context, enrollmentID string) (string, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentLifecycleStage"); err != nil {
+		return "", err
+	}
+	return c.store.RetrieveEnrollmentLifecycleStage(ctx, enrollmentID)
+}
+
+func (c *Chaos) TransitionEnrollmentLifecycleStage(ctx context.Context, enrollmentID, name string) (bool, error) {
+	if err := c.inject(ctx, "TransitionEnrollmentLifecycleStage"); err != nil {
+		return false, err
+	}
+	return c.store.TransitionEnrollmentLifecycleStage(ctx, enrollmentID, name)
+}
+
+func (c *Chaos) RetrieveEnrollmentRegistration(ctx context.Context, enrollmentID string) (*storage.EnrollmentRegistration, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentRegistration"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentRegistration(ctx, enrollmentID)
+}
+
+func (c *Chaos) StoreEnrollmentRegistration(ctx context.Context, registration *storage.EnrollmentRegistration) error {
+	if err := c.inject(ctx, "StoreEnrollmentRegistration"); err != nil {
+		return err
+	}
+	return c.store.StoreEnrollmentRegistration(ctx, registration)
+}
+
+func (c *Chaos) DeleteEnrollmentRegistration(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := c.inject(ctx, "DeleteEnrollmentRegistration"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteEnrollmentRegistration(ctx, enrollmentID)
+}
+
+func (c *Chaos) RetrieveCredentials(ctx context.Context) ([]*storage.Credential, error) {
+	if err := c.inject(ctx, "RetrieveCredentials"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveCredentials(ctx)
+}
+
+func (c *Chaos) RetrieveCredential(ctx context.Context, name string) (*storage.Credential, error) {
+	if err := c.inject(ctx, "RetrieveCredential"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveCredential(ctx, name)
+}
+
+func (c *Chaos) RetrieveCredentialData(ctx context.Context, sha256 string) ([]byte, error) {
+	if err := c.inject(ctx, "RetrieveCredentialData"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveCredentialData(ctx, sha256)
+}
+
+func (c *Chaos) StoreCredential(ctx context.Context, credential *storage.Credential, data []byte) error {
+	if err := c.inject(ctx, "StoreCredential"); err != nil {
+		return err
+	}
+	return c.store.StoreCredential(ctx, credential, data)
+}
+
+func (c *Chaos) DeleteCredential(ctx context.Context, name string) (bool, error) {
+	if err := c.inject(ctx, "DeleteCredential"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteCredential(ctx, name)
+}
+
+func (c *Chaos) RetrieveComplianceRequirements(ctx context.Context) ([]storage.ComplianceRequirement, error) {
+	if err := c.inject(ctx, "RetrieveComplianceRequirements"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveComplianceRequirements(ctx)
+}
+
+func (c *Chaos) StoreComplianceRequirement(ctx context.Context, requirement *storage.ComplianceRequirement) (bool, error) {
+	if err := c.inject(ctx, "StoreComplianceRequirement"); err != nil {
+		return false, err
+	}
+	return c.store.StoreComplianceRequirement(ctx, requirement)
+}
+
+func (c *Chaos) DeleteComplianceRequirement(ctx context.Context, setName string) (bool, error) {
+	if err := c.inject(ctx, "DeleteComplianceRequirement"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteComplianceRequirement(ctx, setName)
+}
+
+func (c *Chaos) StoreEnrollmentCompliance(ctx context.Context, compliance *storage.EnrollmentCompliance) error {
+	if err := c.inject(ctx, "StoreEnrollmentCompliance"); err != nil {
+		return err
+	}
+	return c.store.StoreEnrollmentCompliance(ctx, compliance)
+}
+
+func (c *Chaos) RetrieveEnrollmentCompliance(ctx context.Context, enrollmentIDs []string) ([]*storage.EnrollmentCompliance, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentCompliance"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentCompliance(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) StoreServedDocument(ctx context.Context, enrollmentID string, doc *storage.ServedDocument, max int) (bool, error) {
+	if err := c.inject(ctx, "StoreServedDocument"); err != nil {
+		return false, err
+	}
+	return c.store.StoreServedDocument(ctx, enrollmentID, doc, max)
+}
+
+func (c *Chaos) RetrieveServedHistory(ctx context.Context, enrollmentID, kind string) ([]storage.ServedDocument, error) {
+	if err := c.inject(ctx, "RetrieveServedHistory"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveServedHistory(ctx, enrollmentID, kind)
+}
+
+func (c *Chaos) StoreNotificationBatch(ctx context.Context, batch *storage.NotificationBatch) error {
+	if err := c.inject(ctx, "StoreNotificationBatch"); err != nil {
+		return err
+	}
+	return c.store.StoreNotificationBatch(ctx, batch)
+}
+
+func (c *Chaos) RetrieveNotificationBatches(ctx context.Context) ([]*storage.NotificationBatch, error) {
+	if err := c.inject(ctx, "RetrieveNotificationBatches"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveNotificationBatches(ctx)
+}
+
+func (c *Chaos) DeleteNotificationBatch(ctx context.Context, id string) error {
+	if err := c.inject(ctx, "DeleteNotificationBatch"); err != nil {
+		return err
+	}
+	return c.store.DeleteNotificationBatch(ctx, id)
+}
+
+func (c *Chaos) RetrieveEnrollmentNotifications(ctx context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentNotification, error) {
+	if err := c.inject(ctx, "RetrieveEnrollmentNotifications"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveEnrollmentNotifications(ctx, enrollmentIDs)
+}
+
+func (c *Chaos) StoreEnrollmentNotifications(ctx context.Context, notifications []*storage.EnrollmentNotification) error {
+	if err := c.inject(ctx, "StoreEnrollmentNotifications"); err != nil {
+		return err
+	}
+	return c.store.StoreEnrollmentNotifications(ctx, notifications)
+}
+
+func (c *Chaos) RetrieveDeclarationStatusEnrollments(ctx context.Context, q *storage.DeclarationStatusQuery) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationStatusEnrollments"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationStatusEnrollments(ctx, q)
+}
+
+func (c *Chaos) StoreSetMetadata(ctx context.Context, metadata *storage.SetMetadata) error {
+	if err := c.inject(ctx, "StoreSetMetadata"); err != nil {
+		return err
+	}
+	return c.store.StoreSetMetadata(ctx, metadata)
+}
+
+func (c *Chaos) RetrieveSetMetadata(ctx context.Context, setNames []string) (map[string]*storage.SetMetadata, error) {
+	if err := c.inject(ctx, "RetrieveSetMetadata"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveSetMetadata(ctx, setNames)
+}
+
+func (c *Chaos) DeleteSetMetadata(ctx context.Context, setName string) (bool, error) {
+	if err := c.inject(ctx, "DeleteSetMetadata"); err != nil {
+		return false, err
+	}
+	return c.store.DeleteSetMetadata(ctx, setName)
+}
+
+func (c *Chaos) RetrieveDeclarationTags(ctx context.Context, declarationID string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveDeclarationTags"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveDeclarationTags(ctx, declarationID)
+}
+
+func (c *Chaos) RetrieveTagDeclarations(ctx context.Context, tag string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveTagDeclarations"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveTagDeclarations(ctx, tag)
+}
+
+func (c *Chaos) RetrieveTags(ctx context.Context) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveTags"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveTags(ctx)
+}
+
+func (c *Chaos) StoreDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error) {
+	if err := c.inject(ctx, "StoreDeclarationTag"); err != nil {
+		return false, err
+	}
+	return c.store.StoreDeclarationTag(ctx, declarationID, tag)
+}
+
+func (c *Chaos) RemoveDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error) {
+	if err := c.inject(ctx, "RemoveDeclarationTag"); err != nil {
+		return false, err
+	}
+	return c.store.RemoveDeclarationTag(ctx, declarationID, tag)
+}
+
+func (c *Chaos) StoreOwner(ctx context.Context, kind, id, principal string) error {
+	if err := c.inject(ctx, "StoreOwner"); err != nil {
+		return err
+	}
+	return c.store.StoreOwner(ctx, kind, id, principal)
+}
+
+func (c *Chaos) RetrieveOwned(ctx context.Context, kind, principal string) ([]string, error) {
+	if err := c.inject(ctx, "RetrieveOwned"); err != nil {
+		return nil, err
+	}
+	return c.store.RetrieveOwned(ctx, kind, principal)
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "time"
+
+// Clock tells the current time.
+// Storage backends use it to timestamp what they store so that tests
+// can be deterministic and imported historical status data can keep its
+// original timestamps.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is an adapter to allow the use of ordinary functions as a Clock.
+type ClockFunc func() time.Time
+
+// Now calls f.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock of the system (wall) time.
+var SystemClock Clock = ClockFunc(time.Now)
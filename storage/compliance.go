@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// ErrComplianceRequirementNotFound is returned when a set has no compliance requirement.
+var ErrComplianceRequirementNotFound = Categorize(ErrNotFound, errors.New("compliance requirement not found"))
+
+// ComplianceRequirement is what the enrollments of a set require to be compliant.
+type ComplianceRequirement struct {
+	Set string `json:"set"`
+
+	// Declarations must be reported active, valid, and current.
+	Declarations []string `json:"declarations,omitempty"`
+
+	// Conditions must match the status values of the enrollment.
+	Conditions []DeclarationCondition `json:"conditions,omitempty"`
+}
+
+// Validate checks the requirement for errors.
+func (r *ComplianceRequirement) Validate() error {
+	return Categorize(ErrInvalid, r.validate())
+}
+
+func (r *ComplianceRequirement) validate() error {
+	if r == nil {
+		return errors.New("nil requirement")
+	} else if err := ValidateIdentifier("set name", r.Set); err != nil {
+		return err
+	} else if len(r.Declarations) < 1 && len(r.Conditions) < 1 {
+		return errors.New("no required declarations or conditions")
+	}
+	for _, declarationID := range r.Declarations {
+		if err := ValidateIdentifier("declaration identifier", declarationID); err != nil {
+			return err
+		}
+	}
+	for i := range r.Conditions {
+		if err := r.Conditions[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ComplianceFailure is an unmet compliance requirement.
+type ComplianceFailure struct {
+	Set string `json:"set"`
+
+	// Declaration is the identifier of an unmet required declaration.
+	Declaration string `json:"declaration,omitempty"`
+
+	// Condition is an unmet required condition.
+	Condition *DeclarationCondition `json:"condition,omitempty"`
+
+	Reason string `json:"reason"`
+}
+
+// EnrollmentCompliance is the compliance state of an enrollment with
+// the requirements of its sets.
+type EnrollmentCompliance struct {
+	EnrollmentID string `json:"enrollment_id"`
+	Compliant    bool   `json:"compliant"`
+
+	// Met is the count of the Total requirements that are met.
+	Met   int `json:"met"`
+	Total int `json:"total"`
+
+	// Score is the fraction of requirements met, from 0 to 1.
+	// An enrollment without requirements scores 1.
+	Score float64 `json:"score"`
+
+	Failures []ComplianceFailure `json:"failures,omitempty"`
+
+	// Timestamp is when the compliance was evaluated.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// declarationFailure returns why status does not meet a required declaration.
+func declarationFailure(status *ddm.DeclarationQueryStatus) string {
+	switch {
+	case status == nil:
+		return "no status reported"
+	case status.Unassigned:
+		return "not assigned"
+	case !status.Current:
+		return "status not current"
+	case !status.Active:
+		return "not active"
+	case status.Valid != "valid":
+		return fmt.Sprintf("not valid: %s", status.Valid)
+	}
+	return ""
+}
+
+// EvaluateCompliance evaluates the compliance of enrollmentID with
+// requirements given its declaration statuses and status values.
+func EvaluateCompliance(enrollmentID string, requirements []ComplianceRequirement, statuses []ddm.DeclarationQueryStatus, values []StatusValue) *EnrollmentCompliance {
+	byID := make(map[string]*ddm.DeclarationQueryStatus)
+	for i := range statuses {
+		byID[statuses[i].Identifier] = &statuses[i]
+	}
+	c := &EnrollmentCompliance{EnrollmentID: enrollmentID}
+	for _, r := range requirements {
+		for _, declarationID := range r.Declarations {
+			c.Total++
+			if reason := declarationFailure(byID[declarationID]); reason != "" {
+				c.Failures = append(c.Failures, ComplianceFailure{Set: r.Set, Declaration: declarationID, Reason: reason})
+			} else {
+				c.Met++
+			}
+		}
+		for i := range r.Conditions {
+			c.Total++
+			if !r.Conditions[i].Match(values) {
+				cond := r.Conditions[i]
+				c.Failures = append(c.Failures, ComplianceFailure{Set: r.Set, Condition: &cond, Reason: "condition not met"})
+			} else {
+				c.Met++
+			}
+		}
+	}
+	c.Compliant = c.Met == c.Total
+	c.Score = 1
+	if c.Total > 0 {
+		c.Score = float64(c.Met) / float64(c.Total)
+	}
+	return c
+}
+
+// ComplianceStorage stores compliance requirements and the compliance state of enrollments.
+type ComplianceStorage interface {
+	// RetrieveComplianceRequirements retrieves the compliance requirements of all sets.
+	RetrieveComplianceRequirements(ctx context.Context) ([]ComplianceRequirement, error)
+
+	// StoreComplianceRequirement stores the compliance requirement of its set.
+	// Any existing requirement of the set is replaced.
+	StoreComplianceRequirement(ctx context.Context, requirement *ComplianceRequirement) (bool, error)
+
+	// DeleteComplianceRequirement deletes the compliance requirement of setName.
+	// Returns true if the set had a requirement.
+	DeleteComplianceRequirement(ctx context.Context, setName string) (bool, error)
+
+	// StoreEnrollmentCompliance stores the compliance state of its enrollment.
+	StoreEnrollmentCompliance(ctx context.Context, compliance *EnrollmentCompliance) error
+
+	// RetrieveEnrollmentCompliance retrieves the compliance state of
+	// enrollmentIDs sorted by enrollment ID. Enrollments without a
+	// stored compliance state are omitted. If enrollmentIDs is empty
+	// the compliance state of all enrollments is retrieved.
+	RetrieveEnrollmentCompliance(ctx context.Context, enrollmentIDs []string) ([]*EnrollmentCompliance, error)
+}
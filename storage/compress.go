@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// gzipMagic starts gzip compressed data. JSON never starts with it so
+// compressed and uncompressed JSON can be told apart.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// CompressJSON gzip compresses the JSON b.
+func CompressJSON(b []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, fmt.Errorf("compressing: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("compressing: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// DecompressJSON decompresses b if it was compressed by CompressJSON.
+// Otherwise b is returned unchanged.
+func DecompressJSON(b []byte) ([]byte, error) {
+	if !bytes.HasPrefix(b, gzipMagic) {
+		return b, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+	if b, err = io.ReadAll(r); err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+	return b, nil
+}
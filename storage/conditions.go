@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrSetDeclarationNotFound is returned when a declaration is not associated with a set.
+var ErrSetDeclarationNotFound = Categorize(ErrNotFound, errors.New("set declaration not found"))
+
+// DeclarationCondition restricts serving the declaration of a
+// set-declaration association to the enrollments whose status value at
+// Path matches the filter. For example an operating system version
+// condition may look like:
+//
+//	{"path": ".StatusItems.device.operating-system.version", "op": "ge", "value": "17"}
+type DeclarationCondition struct {
+	Path string `json:"path"`
+	StatusValueFilter
+}
+
+// Validate checks c for a missing path or an unknown operator.
+func (c *DeclarationCondition) Validate() error {
+	return Categorize(ErrInvalid, c.validate())
+}
+
+func (c *DeclarationCondition) validate() error {
+	if c == nil {
+		return errors.New("nil condition")
+	}
+	if c.Path == "" {
+		return errors.New("empty condition path")
+	}
+	return c.StatusValueFilter.validate()
+}
+
+// Match reports whether the most recently reported of values at
+// c.Path matches c. The most recent value is the one with the latest
+// Timestamp with later values winning ties. Enrollments without a
+// status value at c.Path never match.
+func (c *DeclarationCondition) Match(values []StatusValue) bool {
+	var latest *StatusValue
+	for i := range values {
+		if values[i].Path != c.Path {
+			continue
+		}
+		if latest == nil || !values[i].Timestamp.Before(latest.Timestamp) {
+			latest = &values[i]
+		}
+	}
+	return latest != nil && c.StatusValueFilter.Match(*latest)
+}
+
+// SetDeclarationConditionStorage stores and retrieves conditions of
+// set-declaration associations. A declaration in a set that has a
+// condition is only served to the enrollments of the set whose status
+// values match the condition. Conditions are evaluated when the
+// enrollment DDM (declaration items, tokens, and declarations) is
+// built. A declaration in multiple sets of an enrollment is served if
+// any of those associations is unconditional or matches.
+type SetDeclarationConditionStorage interface {
+	// StoreSetDeclarationCondition sets the condition of the association
+	// between setName and declarationID. A nil cond removes the condition.
+	// ErrSetDeclarationNotFound is returned if the declaration is not in the set.
+	StoreSetDeclarationCondition(ctx context.Context, setName, declarationID string, cond *DeclarationCondition) (bool, error)
+
+	// RetrieveSetDeclarationConditions retrieves the conditions of the
+	// declarations in setName keyed by declaration identifier.
+	// Unconditional declarations are not included.
+	RetrieveSetDeclarationConditions(ctx context.Context, setName string) (map[string]*DeclarationCondition, error)
+}
+
+// ServedDeclarations filters the conditions of the sets that a
+// declaration is in for an enrollment with values. A declaration is
+// kept if any of its conditions is nil (unconditional) or matches.
+func ServedDeclarations(conds map[string][]*DeclarationCondition, values []StatusValue) map[string]bool {
+	ret := make(map[string]bool)
+	for declarationID, cs := range conds {
+		for _, c := range cs {
+			if c == nil || c.Match(values) {
+				ret[declarationID] = true
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "context"
+
+// Counter scopes.
+const (
+	CounterScopeGlobal      = "global"
+	CounterScopeDeclaration = "declaration"
+	CounterScopeSet         = "set"
+)
+
+// Counter names.
+const (
+	// CounterNotifications counts enrollments notified.
+	CounterNotifications = "notifications"
+
+	// CounterTokensServed counts DDM tokens documents served to enrollments.
+	CounterTokensServed = "tokens_served"
+
+	// CounterDeclarationItemsServed counts DDM declaration items documents served to enrollments.
+	CounterDeclarationItemsServed = "declaration_items_served"
+
+	// CounterDeclarationsServed counts declarations served to enrollments.
+	CounterDeclarationsServed = "declarations_served"
+
+	// CounterStatusReports counts status reports received from enrollments.
+	// For the declaration scope this counts reported status of the declaration.
+	CounterStatusReports = "status_reports"
+
+	// CounterStatusValuesDropped counts status values dropped by storage limits.
+	CounterStatusValuesDropped = "status_values_dropped"
+
+	// CounterStatusErrorsDropped counts status errors dropped by storage limits.
+	CounterStatusErrorsDropped = "status_errors_dropped"
+)
+
+// CounterIncrement increments a single counter.
+type CounterIncrement struct {
+	Scope string
+	// ID is the declaration identifier or set name. Empty for the global scope.
+	ID    string
+	Name  string
+	Delta int64
+}
+
+type CounterIncrementer interface {
+	// IncrementCounters atomically adds the deltas to the counters.
+	// Counters that do not exist are created.
+	IncrementCounters(ctx context.Context, increments []CounterIncrement) error
+}
+
+type CounterRetriever interface {
+	// RetrieveCounters retrieves the counters for scope and id keyed by counter name.
+	// An empty (not nil) map should be returned if there are no counters.
+	RetrieveCounters(ctx context.Context, scope, id string) (map[string]int64, error)
+}
+
+// CounterStorage are storage interfaces related to counters.
+type CounterStorage interface {
+	CounterIncrementer
+	CounterRetriever
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrCredentialNotFound is returned when a credential does not exist.
+var ErrCredentialNotFound = Categorize(ErrNotFound, errors.New("credential not found"))
+
+// Credential is the metadata of managed certificate or identity (key)
+// material referenced by credential asset declarations. The data of a
+// credential is addressed by its SHA-256 hash so that rotating a
+// credential changes the URL it is served at.
+type Credential struct {
+	Name        string `json:"name"`
+	ContentType string `json:"content_type"`
+
+	// SHA256 is the hex-encoded SHA-256 hash of the credential data.
+	// It is omitted from API responses to read-only principals.
+	SHA256 string `json:"sha256,omitempty"`
+
+	Size      int       `json:"size"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Validate checks the credential for errors.
+func (c *Credential) Validate() error {
+	return Categorize(ErrInvalid, c.validate())
+}
+
+func (c *Credential) validate() error {
+	if c == nil {
+		return errors.New("nil credential")
+	} else if err := ValidateIdentifier("credential name", c.Name); err != nil {
+		return err
+	} else if c.ContentType == "" {
+		return errors.New("missing content type")
+	} else if len(c.SHA256) != 64 {
+		return fmt.Errorf("invalid SHA-256 hash: %q", c.SHA256)
+	} else if c.Size < 1 {
+		return errors.New("empty credential")
+	}
+	return nil
+}
+
+// CredentialStorage stores managed credentials and their data.
+type CredentialStorage interface {
+	// RetrieveCredentials retrieves all credentials.
+	RetrieveCredentials(ctx context.Context) ([]*Credential, error)
+
+	// RetrieveCredential retrieves the credential with name.
+	// ErrCredentialNotFound is returned if the credential does not exist.
+	RetrieveCredential(ctx context.Context, name string) (*Credential, error)
+
+	// RetrieveCredentialData retrieves the data of the credential with
+	// the hex-encoded SHA-256 hash sha256.
+	// ErrCredentialNotFound is returned if no credential has the hash.
+	RetrieveCredentialData(ctx context.Context, sha256 string) ([]byte, error)
+
+	// StoreCredential stores the credential and its data.
+	// Any existing credential with the same name (and its data) is replaced.
+	StoreCredential(ctx context.Context, credential *Credential, data []byte) error
+
+	// DeleteCredential deletes the credential with name and its data.
+	// Returns true if the credential existed.
+	DeleteCredential(ctx context.Context, name string) (bool, error)
+}
@@ -0,0 +1,33 @@
+package storage
+
+import "context"
+
+// DeclarationTagRetriever retrieves the tags of declarations.
+type DeclarationTagRetriever interface {
+	// RetrieveDeclarationTags retrieves the tags of declarationID.
+	RetrieveDeclarationTags(ctx context.Context, declarationID string) ([]string, error)
+
+	// RetrieveTagDeclarations retrieves the declarations tagged with tag.
+	RetrieveTagDeclarations(ctx context.Context, tag string) ([]string, error)
+
+	// RetrieveTags retrieves all tags that are on any declaration.
+	RetrieveTags(ctx context.Context) ([]string, error)
+}
+
+// DeclarationTagStorage tags declarations. Tags are arbitrary labels
+// (e.g. "baseline") that group declarations for tag-based operations.
+// Tags of a declaration are removed when the declaration is deleted.
+type DeclarationTagStorage interface {
+	DeclarationTagRetriever
+
+	// StoreDeclarationTag tags declarationID with tag.
+	// If the tag is added true should be returned.
+	// ErrDeclarationNotFound should be returned if the declaration
+	// does not exist.
+	StoreDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error)
+
+	// RemoveDeclarationTag removes tag from declarationID.
+	// If the tag is removed true should be returned.
+	// It should not be an error if the declaration is not tagged.
+	RemoveDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error)
+}
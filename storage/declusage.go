@@ -0,0 +1,30 @@
+package storage
+
+import "context"
+
+// DeclarationUsage reports what uses a declaration. A declaration that
+// is in no sets and is referenced by no other declarations can be
+// safely deleted.
+type DeclarationUsage struct {
+	Identifier string `json:"identifier"`
+
+	// Sets are the sets that contain the declaration.
+	Sets []string `json:"sets"`
+
+	// Enrollments is the count of enrollments entitled to the
+	// declaration by way of its sets. Set declaration conditions are
+	// not considered.
+	Enrollments int `json:"enrollments"`
+
+	// ReferencedBy are the declarations whose payloads reference the declaration.
+	ReferencedBy []string `json:"referenced_by"`
+}
+
+// DeclarationUsageRetriever retrieves declaration usage.
+type DeclarationUsageRetriever interface {
+	// RetrieveDeclarationUsage retrieves the usage of declarationIDs
+	// sorted by identifier. The usage of all declarations is retrieved
+	// if declarationIDs is empty. ErrDeclarationNotFound is returned if
+	// any of declarationIDs do not exist.
+	RetrieveDeclarationUsage(ctx context.Context, declarationIDs []string) ([]DeclarationUsage, error)
+}
@@ -0,0 +1,13 @@
+package storage
+
+// EnrollmentDeclaration is a declaration that an enrollment is entitled
+// to by way of its sets.
+type EnrollmentDeclaration struct {
+	Identifier  string `json:"identifier"`
+	Type        string `json:"type"`
+	ServerToken string `json:"server_token"`
+
+	// Sets are the enrollment's sets that the declaration is in.
+	// This includes the sets with a pattern matching the enrollment.
+	Sets []string `json:"sets"`
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "errors"
+
+// Storage error categories. Errors returned by storage backends that
+// fall into a category match it with errors.Is so that callers (e.g.
+// the HTTP API) can handle them without knowledge of the backend.
+var (
+	// ErrNotFound categorizes errors of missing resources.
+	ErrNotFound = errors.New("not found")
+
+	// ErrConflict categorizes errors of changes that conflict with
+	// the current state of storage.
+	ErrConflict = errors.New("conflict")
+
+	// ErrInvalid categorizes errors of invalid input.
+	ErrInvalid = errors.New("invalid")
+
+	// ErrUnavailable categorizes errors of storage that is
+	// (presumably temporarily) unavailable.
+	ErrUnavailable = errors.New("unavailable")
+
+	// ErrForbidden categorizes errors of changes that are not allowed
+	// (e.g. denied by policy).
+	ErrForbidden = errors.New("forbidden")
+
+	// ErrRejected categorizes errors of well-formed input that was
+	// rejected because of its content (e.g. by a scanner).
+	ErrRejected = errors.New("rejected")
+
+	// ErrQuotaExceeded categorizes errors of changes that exceed a
+	// quota (e.g. of the principal making them).
+	ErrQuotaExceeded = errors.New("quota exceeded")
+)
+
+// categoryError is an error in a category.
+type categoryError struct {
+	category error
+	err      error
+}
+
+func (e *categoryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *categoryError) Unwrap() error {
+	return e.err
+}
+
+func (e *categoryError) Is(target error) bool {
+	return target == e.category
+}
+
+// Categorize returns err in category such that errors.Is reports true
+// for both err and category. The message of err is unchanged.
+// Nil is returned if err is nil.
+func Categorize(category, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &categoryError{category: category, err: err}
+}
@@ -0,0 +1,67 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixAnnotation = "annotation."
+
+// annotationFilename returns the path to the annotation JSON file of enrollmentID.
+func (s *File) annotationFilename(enrollmentID string) string {
+	return path.Join(s.path, prefixAnnotation+enrollmentID+suffixJSON)
+}
+
+// StoreEnrollmentAnnotation stores the annotation of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentAnnotation(_ context.Context, annotation *storage.EnrollmentAnnotation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(annotation)
+	if err != nil {
+		return fmt.Errorf("marshal enrollment annotation: %w", err)
+	}
+	return os.WriteFile(s.annotationFilename(annotation.EnrollmentID), b, 0644)
+}
+
+// RetrieveEnrollmentAnnotations retrieves the annotations of enrollments.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentAnnotations(_ context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentAnnotation, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string]*storage.EnrollmentAnnotation)
+	for _, enrollmentID := range enrollmentIDs {
+		b, err := os.ReadFile(s.annotationFilename(enrollmentID))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading enrollment annotation: %w", err)
+		}
+		annotation := new(storage.EnrollmentAnnotation)
+		if err = json.Unmarshal(b, annotation); err != nil {
+			return nil, fmt.Errorf("unmarshal enrollment annotation: %w", err)
+		}
+		ret[enrollmentID] = annotation
+	}
+	return ret, nil
+}
+
+// DeleteEnrollmentAnnotation deletes the annotation of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteEnrollmentAnnotation(_ context.Context, enrollmentID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.annotationFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("deleting enrollment annotation: %w", err)
+	}
+	return true, nil
+}
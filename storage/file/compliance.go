@@ -0,0 +1,141 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const complianceRequirementsFilename = "compliance.requirements.json"
+
+// complianceFilename returns the path to the enrollment's compliance JSON file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) complianceFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "compliance.json")
+}
+
+// readComplianceRequirements reads the compliance requirements.
+// The caller must hold the lock.
+func (s *File) readComplianceRequirements() ([]storage.ComplianceRequirement, error) {
+	b, err := os.ReadFile(path.Join(s.path, complianceRequirementsFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading compliance requirements: %w", err)
+	}
+	var requirements []storage.ComplianceRequirement
+	if err = json.Unmarshal(b, &requirements); err != nil {
+		return nil, fmt.Errorf("unmarshal compliance requirements: %w", err)
+	}
+	return requirements, nil
+}
+
+// writeComplianceRequirements sorts and writes the compliance requirements.
+// The caller must hold the lock.
+func (s *File) writeComplianceRequirements(requirements []storage.ComplianceRequirement) error {
+	sort.Slice(requirements, func(i, j int) bool { return requirements[i].Set < requirements[j].Set })
+	b, err := json.Marshal(requirements)
+	if err != nil {
+		return fmt.Errorf("marshal compliance requirements: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, complianceRequirementsFilename), b, 0644)
+}
+
+// RetrieveComplianceRequirements retrieves the compliance requirements of all sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveComplianceRequirements(_ context.Context) ([]storage.ComplianceRequirement, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readComplianceRequirements()
+}
+
+// StoreComplianceRequirement stores the compliance requirement of its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreComplianceRequirement(_ context.Context, requirement *storage.ComplianceRequirement) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requirements, err := s.readComplianceRequirements()
+	if err != nil {
+		return false, err
+	}
+	for i := range requirements {
+		if requirements[i].Set != requirement.Set {
+			continue
+		}
+		if reflect.DeepEqual(requirements[i], *requirement) {
+			return false, nil
+		}
+		requirements[i] = *requirement
+		return true, s.writeComplianceRequirements(requirements)
+	}
+	return true, s.writeComplianceRequirements(append(requirements, *requirement))
+}
+
+// DeleteComplianceRequirement deletes the compliance requirement of setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteComplianceRequirement(_ context.Context, setName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	requirements, err := s.readComplianceRequirements()
+	if err != nil {
+		return false, err
+	}
+	for i := range requirements {
+		if requirements[i].Set == setName {
+			return true, s.writeComplianceRequirements(append(requirements[:i], requirements[i+1:]...))
+		}
+	}
+	return false, nil
+}
+
+// StoreEnrollmentCompliance stores the compliance state of its enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentCompliance(_ context.Context, compliance *storage.EnrollmentCompliance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(compliance)
+	if err != nil {
+		return fmt.Errorf("marshal enrollment compliance: %w", err)
+	}
+	if err = s.assureEnrollmentDirExists(compliance.EnrollmentID); err != nil {
+		return fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	return os.WriteFile(s.complianceFilename(compliance.EnrollmentID), b, 0644)
+}
+
+// RetrieveEnrollmentCompliance retrieves the compliance state of enrollments.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentCompliance(_ context.Context, enrollmentIDs []string) ([]*storage.EnrollmentCompliance, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(enrollmentIDs) < 1 {
+		// each known enrollment has a directory
+		var err error
+		if enrollmentIDs, err = s.knownEnrollmentIDs(); err != nil {
+			return nil, err
+		}
+	}
+	var ret []*storage.EnrollmentCompliance
+	for _, enrollmentID := range enrollmentIDs {
+		b, err := os.ReadFile(s.complianceFilename(enrollmentID))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading enrollment compliance: %w", err)
+		}
+		c := new(storage.EnrollmentCompliance)
+		if err = json.Unmarshal(b, c); err != nil {
+			return nil, fmt.Errorf("unmarshal enrollment compliance: %w", err)
+		}
+		ret = append(ret, c)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].EnrollmentID < ret[j].EnrollmentID })
+	return ret, nil
+}
@@ -0,0 +1,34 @@
+package file
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// writeJSONFile writes the JSON b to filename.
+// It is gzip compressed if compression is enabled.
+func (s *File) writeJSONFile(filename string, b []byte) error {
+	if s.compress {
+		var err error
+		if b, err = storage.CompressJSON(b); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(filename, b, 0644)
+}
+
+// readJSONFile reads the JSON in filename.
+// It is decompressed if compressed whether or not compression is
+// enabled so that compression can be enabled or disabled at any time.
+func readJSONFile(filename string) ([]byte, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return b, err
+	}
+	if b, err = storage.DecompressJSON(b); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", filename, err)
+	}
+	return b, nil
+}
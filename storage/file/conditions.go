@@ -0,0 +1,169 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// readSetConditions reads the declaration conditions of setName.
+// Conditions of declarations no longer in the set may be included.
+func (s *File) readSetConditions(setName string) (map[string]*storage.DeclarationCondition, error) {
+	b, err := os.ReadFile(s.setConditionsFilename(setName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var conds map[string]*storage.DeclarationCondition
+	if err = json.Unmarshal(b, &conds); err != nil {
+		return nil, err
+	}
+	return conds, nil
+}
+
+// writeSetConditions writes the declaration conditions of setName.
+// The file is removed if there are no conditions.
+func (s *File) writeSetConditions(setName string, conds map[string]*storage.DeclarationCondition) error {
+	if len(conds) < 1 {
+		err := os.Remove(s.setConditionsFilename(setName))
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		return err
+	}
+	b, err := json.Marshal(conds)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.setConditionsFilename(setName), b, 0644)
+}
+
+// pruneSetConditions removes the conditions of the declarations of
+// setName that are not in declarationIDs.
+func (s *File) pruneSetConditions(setName string, declarationIDs []string) error {
+	conds, err := s.readSetConditions(setName)
+	if err != nil || len(conds) < 1 {
+		return err
+	}
+	var pruned bool
+	for declarationID := range conds {
+		if contains(declarationIDs, declarationID) < 0 {
+			delete(conds, declarationID)
+			pruned = true
+		}
+	}
+	if !pruned {
+		return nil
+	}
+	return s.writeSetConditions(setName, conds)
+}
+
+// StoreSetDeclarationCondition sets the condition of the association between setName and declarationID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreSetDeclarationCondition(_ context.Context, setName, declarationID string, cond *storage.DeclarationCondition) (bool, error) {
+	if cond != nil {
+		if err := cond.Validate(); err != nil {
+			return false, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreSetDeclarationCondition, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
+	declarationIDs, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return false, fmt.Errorf("reading set file: %w", err)
+	}
+	if contains(declarationIDs, declarationID) < 0 {
+		return false, storage.ErrSetDeclarationNotFound
+	}
+	conds, err := s.readSetConditions(setName)
+	if err != nil {
+		return false, fmt.Errorf("reading set conditions: %w", err)
+	}
+	prev := conds[declarationID]
+	if (prev == nil && cond == nil) || (prev != nil && cond != nil && *prev == *cond) {
+		return false, nil
+	}
+	if conds == nil {
+		conds = make(map[string]*storage.DeclarationCondition)
+	}
+	if cond == nil {
+		delete(conds, declarationID)
+	} else {
+		conds[declarationID] = cond
+	}
+	if err = s.writeSetConditions(setName, conds); err != nil {
+		return false, fmt.Errorf("writing set conditions: %w", err)
+	}
+
+	// update (all of) the enrollment ID DDM files
+	if err = s.writeSetDDM(setName); err != nil {
+		return false, fmt.Errorf("writing set DDM: %w", err)
+	}
+	return true, nil
+}
+
+// RetrieveSetDeclarationConditions retrieves the conditions of the declarations in setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetDeclarationConditions(_ context.Context, setName string) (map[string]*storage.DeclarationCondition, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	declarationIDs, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return nil, fmt.Errorf("reading set file: %w", err)
+	}
+	conds, err := s.readSetConditions(setName)
+	if err != nil {
+		return nil, fmt.Errorf("reading set conditions: %w", err)
+	}
+	ret := make(map[string]*storage.DeclarationCondition)
+	for declarationID, cond := range conds {
+		if contains(declarationIDs, declarationID) >= 0 {
+			ret[declarationID] = cond
+		}
+	}
+	return ret, nil
+}
+
+// enrollmentStatusValues reads the last reported status values of enrollmentID.
+func (s *File) enrollmentStatusValues(enrollmentID string) ([]storage.StatusValue, error) {
+	values, err := s.readStatusValues(enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]storage.StatusValue, len(values))
+	for i, v := range values {
+		ret[i] = storage.StatusValue{
+			Path:  v.Path,
+			Type:  v.ValueType,
+			Value: string(v.Value),
+		}
+	}
+	return ret, nil
+}
+
+// enrollmentHasConditions reports whether any of the sets of
+// enrollmentID has declaration conditions.
+func (s *File) enrollmentHasConditions(enrollmentID string) (bool, error) {
+	enrollmentSets, err := s.enrollmentSets(enrollmentID)
+	if err != nil {
+		return false, err
+	}
+	for _, setName := range enrollmentSets {
+		conds, err := s.readSetConditions(setName)
+		if err != nil {
+			return false, fmt.Errorf("reading set conditions for %s: %w", setName, err)
+		}
+		if len(conds) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const countersFilename = "counters.json"
+
+// fileCounters are counters keyed by scope, then ID, then name.
+type fileCounters map[string]map[string]map[string]int64
+
+func (s *File) readCounters() (fileCounters, error) {
+	counters := make(fileCounters)
+	b, err := os.ReadFile(path.Join(s.path, countersFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return counters, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading counters: %w", err)
+	}
+	if err = json.Unmarshal(b, &counters); err != nil {
+		return nil, fmt.Errorf("unmarshal counters: %w", err)
+	}
+	return counters, nil
+}
+
+// DefaultCountersFlushInterval is the default interval at which
+// counter increments kept in memory are written to disk.
+const DefaultCountersFlushInterval = 10 * time.Second
+
+// add adds the counters of other to c.
+func (c fileCounters) add(other fileCounters) {
+	for scope, ids := range other {
+		if c[scope] == nil {
+			c[scope] = make(map[string]map[string]int64)
+		}
+		for id, names := range ids {
+			if c[scope][id] == nil {
+				c[scope][id] = make(map[string]int64)
+			}
+			for name, v := range names {
+				c[scope][id][name] += v
+			}
+		}
+	}
+}
+
+// IncrementCounters atomically adds the deltas to the counters.
+// Increments are kept in memory and written to disk at most once per
+// flush interval so that counting does not rewrite the counters file
+// on every request. See also FlushCounters.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) IncrementCounters(_ context.Context, increments []storage.CounterIncrement) error {
+	if len(increments) < 1 {
+		return nil
+	}
+	s.countersMu.Lock()
+	if s.counters == nil {
+		s.counters = make(fileCounters)
+	}
+	for _, inc := range increments {
+		s.counters.add(fileCounters{inc.Scope: {inc.ID: {inc.Name: inc.Delta}}})
+	}
+	due := s.clock.Now().Sub(s.countersFlushed) >= s.countersFlush
+	s.countersMu.Unlock()
+	if !due {
+		return nil
+	}
+	return s.FlushCounters()
+}
+
+// CountersFlushInterval returns how often counter increments kept in
+// memory are written to disk (see WithCountersFlushInterval).
+func (s *File) CountersFlushInterval() time.Duration {
+	return s.countersFlush
+}
+
+// FlushCounters writes the counter increments kept in memory to disk.
+// Increments are also written by IncrementCounters once the flush
+// interval passed. Callers should flush periodically (so that
+// increments are written without further increments) and when
+// stopping (so that they are not lost).
+func (s *File) FlushCounters() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	s.countersFlushed = s.clock.Now()
+	if len(s.counters) < 1 {
+		return nil
+	}
+	counters, err := s.readCounters()
+	if err != nil {
+		return err
+	}
+	counters.add(s.counters)
+	b, err := json.Marshal(counters)
+	if err != nil {
+		return fmt.Errorf("marshal counters: %w", err)
+	}
+	if err = os.WriteFile(path.Join(s.path, countersFilename), b, 0644); err != nil {
+		// keep the increments in memory to retry with the next flush
+		return err
+	}
+	s.counters = nil
+	return nil
+}
+
+// RetrieveCounters retrieves the counters for scope and id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveCounters(_ context.Context, scope, id string) (map[string]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counters, err := s.readCounters()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]int64)
+	for k, v := range counters[scope][id] {
+		ret[k] = v
+	}
+	// include the increments not yet written
+	s.countersMu.Lock()
+	defer s.countersMu.Unlock()
+	for k, v := range s.counters[scope][id] {
+		ret[k] += v
+	}
+	return ret, nil
+}
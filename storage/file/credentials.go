@@ -0,0 +1,144 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	credentialsFilename = "credentials.json"
+	prefixCredential    = "credential."
+	suffixCredential    = ".dat"
+)
+
+// credentialFilename returns the path to the credential data.
+func (s *File) credentialFilename(name string) string {
+	return path.Join(s.path, prefixCredential+name+suffixCredential)
+}
+
+// readCredentials reads the credential metadata.
+// The caller must hold the lock.
+func (s *File) readCredentials() ([]*storage.Credential, error) {
+	b, err := os.ReadFile(path.Join(s.path, credentialsFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading credentials: %w", err)
+	}
+	var credentials []*storage.Credential
+	if err = json.Unmarshal(b, &credentials); err != nil {
+		return nil, fmt.Errorf("unmarshal credentials: %w", err)
+	}
+	return credentials, nil
+}
+
+// writeCredentials sorts and writes the credential metadata.
+// The caller must hold the lock.
+func (s *File) writeCredentials(credentials []*storage.Credential) error {
+	sort.Slice(credentials, func(i, j int) bool { return credentials[i].Name < credentials[j].Name })
+	b, err := json.Marshal(credentials)
+	if err != nil {
+		return fmt.Errorf("marshal credentials: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, credentialsFilename), b, 0644)
+}
+
+// RetrieveCredentials retrieves all credentials.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveCredentials(_ context.Context) ([]*storage.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readCredentials()
+}
+
+// RetrieveCredential retrieves the credential with name.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveCredential(_ context.Context, name string) (*storage.Credential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	credentials, err := s.readCredentials()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range credentials {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return nil, storage.ErrCredentialNotFound
+}
+
+// RetrieveCredentialData retrieves the data of the credential with the SHA-256 hash.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveCredentialData(_ context.Context, sha256 string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	credentials, err := s.readCredentials()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range credentials {
+		if c.SHA256 != sha256 {
+			continue
+		}
+		data, err := os.ReadFile(s.credentialFilename(c.Name))
+		if errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		return data, err
+	}
+	return nil, storage.ErrCredentialNotFound
+}
+
+// StoreCredential stores the credential and its data.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreCredential(_ context.Context, credential *storage.Credential, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, err := s.readCredentials()
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(s.credentialFilename(credential.Name), data, 0600); err != nil {
+		return fmt.Errorf("writing credential data: %w", err)
+	}
+	c := *credential
+	for i := range credentials {
+		if credentials[i].Name == c.Name {
+			credentials[i] = &c
+			return s.writeCredentials(credentials)
+		}
+	}
+	return s.writeCredentials(append(credentials, &c))
+}
+
+// DeleteCredential deletes the credential with name and its data.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteCredential(_ context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	credentials, err := s.readCredentials()
+	if err != nil {
+		return false, err
+	}
+	for i, c := range credentials {
+		if c.Name != name {
+			continue
+		}
+		if err = s.writeCredentials(append(credentials[:i], credentials[i+1:]...)); err != nil {
+			return false, err
+		}
+		if err = os.Remove(s.credentialFilename(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return true, fmt.Errorf("deleting credential data: %w", err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -3,160 +3,259 @@ package file
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 
 	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
+// readEnrollmentDDMFile reads the enrollment DDM file at filename.
+// If the file does not exist (e.g. from a partial migration or a failed
+// rebuild) then the enrollment DDM files are built first, back-filling
+// them for future requests.
+func (s *File) readEnrollmentDDMFile(enrollmentID, filename string) ([]byte, error) {
+	s.mu.RLock()
+	b, err := readJSONFile(filename)
+	s.mu.RUnlock()
+	if !errors.Is(err, os.ErrNotExist) {
+		return b, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the files may have been built while we waited for the lock
+	if b, err = readJSONFile(filename); !errors.Is(err, os.ErrNotExist) {
+		return b, err
+	}
+	if err = s.writeEnrollmentDDM(enrollmentID); err != nil {
+		return nil, fmt.Errorf("building enrollment DDM: %w", err)
+	}
+	return readJSONFile(filename)
+}
+
 // RetrieveEnrollmentDeclarationJSON retrieves the DDM declaration JSON for an enrollment ID.
 func (s *File) RetrieveEnrollmentDeclarationJSON(_ context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return os.ReadFile(s.enrollmentDeclarationFilename(declarationID, declarationType, enrollmentID))
+	return s.readEnrollmentDDMFile(enrollmentID, s.enrollmentDeclarationFilename(declarationID, declarationType, enrollmentID))
 }
 
 // RetrieveDeclarationItemsJSON retrieves the DDM declaration-items JSON for an enrollment ID.
 func (s *File) RetrieveDeclarationItemsJSON(_ context.Context, enrollmentID string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return os.ReadFile(s.declarationItemsFilename(enrollmentID))
+	return s.readEnrollmentDDMFile(enrollmentID, s.declarationItemsFilename(enrollmentID))
 }
 
 // RetrieveDeclarationItemsJSON retrieves the DDM token JSON for an enrollment ID.
 func (s *File) RetrieveTokensJSON(_ context.Context, enrollmentID string) ([]byte, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return os.ReadFile(path.Join(s.path, enrollmentID, tokensFilename))
+	return s.readEnrollmentDDMFile(enrollmentID, s.tokensFilename(enrollmentID))
 }
 
 // writeDeclarationDDM looks up the enrollments associated with a declaration and writes the DDM files for each.
+// Enrollments whose DDM files fail to be written are recorded to be retried.
 func (s *File) writeDeclarationDDM(declarationID string) error {
 	// first find all enrollment IDs mapped to this declaration.
 	declarationIDs, err := s.retrieveEnrollmentIDs([]string{declarationID}, nil, nil)
 	if err != nil {
 		return err
 	}
-	for _, id := range declarationIDs {
-		// write the enrollment DDM files
-		if err = s.writeEnrollmentDDM(id); err != nil {
-			return err
-		}
-	}
-	return nil
+	// write the enrollment DDM files
+	_, err = s.writeEnrollmentsDDM(declarationIDs)
+	return err
 }
 
 // writeSetDDM writes the DDM files for all enrollments belonging to a set.
+// Enrollments whose DDM files fail to be written are recorded to be retried.
 func (s *File) writeSetDDM(setName string) error {
 	// get all the enrollment ids for a this set
-	setEnrIDs, err := getSlice(s.setEnrollmentsFilename(setName))
+	setEnrIDs, err := s.setEnrollmentIDs(setName)
 	if err != nil {
 		return err
 	}
-	for _, setEnrID := range setEnrIDs {
-		// write the enrollment DDM files
-		if err = s.writeEnrollmentDDM(setEnrID); err != nil {
-			return err
-		}
-	}
-	return nil
+	// write the enrollment DDM files
+	_, err = s.writeEnrollmentsDDM(setEnrIDs)
+	return err
 }
 
-// writeEnrollmentDDM generates all enrollment ID-specific DDM declarations.
-func (s *File) writeEnrollmentDDM(enrollmentID string) error {
-	// get all the sets this id is enrolled in
-	enrollmentSets, err := getSlice(s.enrollmentSetsFilename(enrollmentID))
+// enrollmentDeclarationSets resolves the declaration IDs enrollmentID
+// is entitled to by way of its sets. The returned map is keyed by
+// declaration ID with the sets the declaration is in as the values.
+// Declarations whose set conditions do not match the status values of
+// enrollmentID are excluded.
+func (s *File) enrollmentDeclarationSets(enrollmentID string) (map[string][]string, error) {
+	// get all the sets this id is enrolled in (or matches a pattern of)
+	enrollmentSets, err := s.enrollmentSets(enrollmentID)
 	if err != nil {
-		return fmt.Errorf("getting sets for enrollment: %w", err)
+		return nil, err
 	}
 
-	enrollmentDeclarations := make(map[string]struct{})
+	enrollmentDeclarations := make(map[string][]string)
+	declarationConds := make(map[string][]*storage.DeclarationCondition)
+	var conditional bool
 	for _, setName := range enrollmentSets {
 		// get all the declarations for this set
 		setDeclarations, err := getSlice(s.setFilename(setName))
 		if err != nil {
-			return fmt.Errorf("getting declarations from set for %s: %w", setName, err)
+			return nil, fmt.Errorf("getting declarations from set for %s: %w", setName, err)
+		}
+		conds, err := s.readSetConditions(setName)
+		if err != nil {
+			return nil, fmt.Errorf("reading set conditions for %s: %w", setName, err)
 		}
 		for _, declarationID := range setDeclarations {
 			// collect declaration IDs in our map
-			enrollmentDeclarations[declarationID] = struct{}{}
+			enrollmentDeclarations[declarationID] = append(enrollmentDeclarations[declarationID], setName)
+			declarationConds[declarationID] = append(declarationConds[declarationID], conds[declarationID])
+			if conds[declarationID] != nil {
+				conditional = true
+			}
 		}
 	}
+	if !conditional {
+		return enrollmentDeclarations, nil
+	}
 
-	if err = s.assureEnrollmentDirExists(enrollmentID); err != nil {
-		return fmt.Errorf("assuring enrollment directory exists: %w", err)
+	values, err := s.enrollmentStatusValues(enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("reading status values: %w", err)
+	}
+	served := storage.ServedDeclarations(declarationConds, values)
+	for declarationID := range enrollmentDeclarations {
+		if !served[declarationID] {
+			delete(enrollmentDeclarations, declarationID)
+		}
 	}
+	return enrollmentDeclarations, nil
+}
 
-	// create our token and declaration-items builders
-	di := ddm.NewDIBuilder(s.newHash)
-	ti := ddm.NewTokensBuilder(s.newHash)
+// RetrieveEnrollmentDeclarations retrieves the declarations enrollmentID is entitled to.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentDeclarations(_ context.Context, enrollmentID string) ([]storage.EnrollmentDeclaration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	enrollmentDeclarations, err := s.enrollmentDeclarationSets(enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]storage.EnrollmentDeclaration, 0, len(enrollmentDeclarations))
+	for declarationID, setNames := range enrollmentDeclarations {
+		d, err := s.readDeclarationFile(declarationID)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, storage.EnrollmentDeclaration{
+			Identifier:  d.Identifier,
+			Type:        d.Type,
+			ServerToken: d.ServerToken,
+			Sets:        setNames,
+		})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Identifier < ret[j].Identifier })
+	return ret, nil
+}
 
-	// find any existing declaration symlinks
-	matches, err := filepath.Glob(path.Join(s.path, enrollmentID, "declaration.*.json"))
+// enrollmentDDM is the derived DDM data of an enrollment.
+type enrollmentDDM struct {
+	diJSON []byte
+	tiJSON []byte
+
+	// symlinks maps declaration symlink filenames to their targets.
+	symlinks map[string]string
+}
+
+// buildEnrollmentDDM computes the derived DDM data of enrollmentID
+// from its sets and their declarations.
+func (s *File) buildEnrollmentDDM(enrollmentID string) (*enrollmentDDM, error) {
+	enrollmentDeclarations, err := s.enrollmentDeclarationSets(enrollmentID)
 	if err != nil {
-		return fmt.Errorf("finding declaration symlinks: %w", err)
+		return nil, err
 	}
 
+	// create our token and declaration-items builders
+	di := ddm.NewDIBuilder(s.newHash)
+	ti := ddm.NewTokensBuilder(s.newHash)
+
+	e := &enrollmentDDM{symlinks: make(map[string]string)}
 	for declarationID := range enrollmentDeclarations {
 		// read and parse declaration
-		dBytes, err := os.ReadFile(s.declarationFilename(declarationID))
+		dBytes, err := readJSONFile(s.declarationFilename(declarationID))
 		if err != nil {
-			return fmt.Errorf("reading declaration: %w", err)
+			return nil, fmt.Errorf("reading declaration: %w", err)
 		}
 		d, err := ddm.ParseDeclaration(dBytes)
 		if err != nil {
-			return fmt.Errorf("parsing declaration: %w", err)
+			return nil, fmt.Errorf("parsing declaration: %w", err)
 		}
 
 		// add to our DI and tokens builders
 		di.Add(d)
 		ti.Add(d)
 
-		// create declaration symlink if not exists
 		symlinkName := s.enrollmentDeclarationFilename(d.Identifier, ddm.ManifestType(d.Type), enrollmentID)
-		if pos := contains(matches, symlinkName); pos >= 0 {
-			matches = append(matches[:pos], matches[pos+1:]...)
-		} else {
-			err = os.Symlink(
-				path.Join("..", relativeDeclarationFilename(d.Identifier)),
-				symlinkName,
-			)
-			if err != nil {
-				return fmt.Errorf("creating declaration symlink: %w", err)
-			}
-		}
-	}
-
-	// remove any symlinks for previous declarations
-	for _, oldSymlink := range matches {
-		if err = os.Remove(oldSymlink); err != nil {
-			return fmt.Errorf("removing declaration symlink: %w", err)
-		}
+		e.symlinks[symlinkName] = path.Join("..", relativeDeclarationFilename(d.Identifier))
 	}
 
 	// finalize the builders
 	di.Finalize()
 	ti.Finalize()
 
-	// marshal and write the declarations-items JSON
-	diJSON, err := json.Marshal(&di.DeclarationItems)
+	if e.diJSON, err = json.Marshal(&di.DeclarationItems); err != nil {
+		return nil, err
+	}
+	if e.tiJSON, err = json.Marshal(&ti.TokensResponse); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// enrollmentDeclarationSymlinks finds the existing declaration symlinks of enrollmentID.
+func (s *File) enrollmentDeclarationSymlinks(enrollmentID string) ([]string, error) {
+	matches, err := filepath.Glob(path.Join(s.path, enrollmentID, "declaration.*.json"))
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("finding declaration symlinks: %w", err)
 	}
-	if err = os.WriteFile(s.declarationItemsFilename(enrollmentID), diJSON, 0644); err != nil {
+	return matches, nil
+}
+
+// writeEnrollmentDDM generates all enrollment ID-specific DDM declarations.
+func (s *File) writeEnrollmentDDM(enrollmentID string) error {
+	e, err := s.buildEnrollmentDDM(enrollmentID)
+	if err != nil {
 		return err
 	}
 
-	// marshal and write the tokens JSON
-	tiJSON, err := json.Marshal(&ti.TokensResponse)
+	if err = s.assureEnrollmentDirExists(enrollmentID); err != nil {
+		return fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+
+	// find any existing declaration symlinks
+	matches, err := s.enrollmentDeclarationSymlinks(enrollmentID)
 	if err != nil {
 		return err
 	}
-	if err = os.WriteFile(s.tokensFilename(enrollmentID), tiJSON, 0644); err != nil {
+
+	for symlinkName, target := range e.symlinks {
+		// create declaration symlink if not exists
+		if pos := contains(matches, symlinkName); pos >= 0 {
+			matches = append(matches[:pos], matches[pos+1:]...)
+		} else if err = os.Symlink(target, symlinkName); err != nil {
+			return fmt.Errorf("creating declaration symlink: %w", err)
+		}
+	}
+
+	// remove any symlinks for previous declarations
+	for _, oldSymlink := range matches {
+		if err = os.Remove(oldSymlink); err != nil {
+			return fmt.Errorf("removing declaration symlink: %w", err)
+		}
+	}
+
+	// write the declarations-items JSON
+	if err = s.writeJSONFile(s.declarationItemsFilename(enrollmentID), e.diJSON); err != nil {
 		return err
 	}
 
-	return nil
+	// write the tokens JSON
+	return s.writeJSONFile(s.tokensFilename(enrollmentID), e.tiJSON)
 }
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"math/rand"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/jessepeterson/kmfddm/ddm"
@@ -27,6 +29,9 @@ func (s *File) StoreDeclaration(_ context.Context, d *ddm.Declaration) (bool, er
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.appendJournal(storage.JournalStoreDeclaration, []string{d.Identifier}, nil, nil); err != nil {
+		return false, err
+	}
 	return s.writeDeclarationFiles(d, false)
 }
 
@@ -60,28 +65,32 @@ func (s *File) writeDeclarationFiles(d *ddm.Declaration, forceNewSalt bool) (boo
 		}
 	}
 
-	// unmarshal the raw declaration
+	// remove the servertoken to make the marshaling idempotent
 	var declaration map[string]interface{}
-	if err = json.Unmarshal(d.Raw, &declaration); err != nil {
-		return false, err
-	}
+	var dBytes []byte
+	if s.preserve {
+		if dBytes, err = ddm.RemoveServerToken(d.Raw); err != nil {
+			return false, err
+		}
+	} else {
+		// unmarshal the raw declaration
+		if err = json.Unmarshal(d.Raw, &declaration); err != nil {
+			return false, err
+		}
 
-	// remove the servertoken to make the marshaling idempotent
-	delete(declaration, "ServerToken")
+		delete(declaration, "ServerToken")
 
-	// re-marshal (without a servertoken)
-	dBytes, err := json.Marshal(&declaration)
-	if err != nil {
-		return false, fmt.Errorf("marshaling no-token declaration: %w", err)
+		// re-marshal (without a servertoken)
+		if dBytes, err = json.Marshal(&declaration); err != nil {
+			return false, fmt.Errorf("marshaling no-token declaration: %w", err)
+		}
 	}
 
 	// hash the marshaled declaration (again without token but with creation salt)
-	hasher := s.newHash()
-	_, err = hasher.Write(append(dBytes, creationSalt...))
+	dHash, err := hashDeclaration(s.newHash, dBytes, creationSalt)
 	if err != nil {
-		return false, fmt.Errorf("hashing marshaled no-token declaration: %w", err)
+		return false, err
 	}
-	dHash := fmt.Sprintf("%x", hasher.Sum(nil))
 
 	if !tokenMissing && dHash == token {
 		// the hashed version of our profile is the same
@@ -89,17 +98,39 @@ func (s *File) writeDeclarationFiles(d *ddm.Declaration, forceNewSalt bool) (boo
 		// were no changes.
 		return false, nil
 	}
+
+	// a token computed with a previous hash of the same declaration
+	// is unchanged but is rewritten with the current hash
+	var rehashed bool
+	if !tokenMissing && !forceNewSalt {
+		for _, newHash := range s.previousHashes {
+			prevHash, err := hashDeclaration(newHash, dBytes, creationSalt)
+			if err != nil {
+				return false, err
+			}
+			if prevHash == token {
+				rehashed = true
+				break
+			}
+		}
+	}
 	token = dHash
 
-	declaration["ServerToken"] = token
+	if s.preserve {
+		// splice the new token into the declaration bytes
+		if dBytes, err = ddm.SpliceServerToken(dBytes, token); err != nil {
+			return false, fmt.Errorf("splicing declaration token: %w", err)
+		}
+	} else {
+		declaration["ServerToken"] = token
 
-	// marshal the declaration (with the new token)
-	dBytes, err = json.Marshal(&declaration)
-	if err != nil {
-		return false, fmt.Errorf("marshaling declaration: %w", err)
+		// marshal the declaration (with the new token)
+		if dBytes, err = json.Marshal(&declaration); err != nil {
+			return false, fmt.Errorf("marshaling declaration: %w", err)
+		}
 	}
 
-	if err = os.WriteFile(s.declarationFilename(d.Identifier), dBytes, 0644); err != nil {
+	if err = s.writeJSONFile(s.declarationFilename(d.Identifier), dBytes); err != nil {
 		return false, fmt.Errorf("writing declaration: %w", err)
 	}
 
@@ -120,7 +151,17 @@ func (s *File) writeDeclarationFiles(d *ddm.Declaration, forceNewSalt bool) (boo
 		return false, err
 	}
 
-	return true, nil
+	return !rehashed, nil
+}
+
+// hashDeclaration returns the hex token of the marshaled no-token
+// declaration dBytes and its creation salt using newHash.
+func hashDeclaration(newHash func() hash.Hash, dBytes, salt []byte) (string, error) {
+	hasher := newHash()
+	if _, err := hasher.Write(append(dBytes, salt...)); err != nil {
+		return "", fmt.Errorf("hashing marshaled no-token declaration: %w", err)
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
 
 // RetrieveDeclaration retrieves a declaration by its ID.
@@ -133,7 +174,7 @@ func (s *File) RetrieveDeclaration(_ context.Context, declarationID string) (*dd
 }
 
 func (s *File) readDeclarationFile(declarationID string) (*ddm.Declaration, error) {
-	dBytes, err := os.ReadFile(s.declarationFilename(declarationID))
+	dBytes, err := readJSONFile(s.declarationFilename(declarationID))
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			err = fmt.Errorf("%w: %v", storage.ErrDeclarationNotFound, err)
@@ -162,6 +203,9 @@ func (s *File) RetrieveDeclarationModTime(ctx context.Context, declarationID str
 func (s *File) DeleteDeclaration(_ context.Context, identifier string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalDeleteDeclaration, []string{identifier}, nil, nil); err != nil {
+		return false, err
+	}
 	// fetch all sets this declaration belongs to
 	sets, err := getSlice(s.declarationSetsFilename(identifier))
 	if err != nil {
@@ -170,7 +214,17 @@ func (s *File) DeleteDeclaration(_ context.Context, identifier string) (bool, er
 	if len(sets) > 0 {
 		// try to maintain some semblance of referential integrity by
 		// not preventing deletion if we're with sets.
-		return false, fmt.Errorf("declaration %s contained in %d set(s)", identifier, len(sets))
+		return false, storage.Categorize(storage.ErrConflict, fmt.Errorf("declaration %s contained in %d set(s)", identifier, len(sets)))
+	}
+	// tags do not outlive the declaration
+	tags, err := getSlice(s.declarationTagsFilename(identifier))
+	if err != nil {
+		return false, fmt.Errorf("getting tags from declaration: %w", err)
+	}
+	for _, tag := range tags {
+		if _, err = s.removeDeclarationTag(identifier, tag); err != nil {
+			return false, err
+		}
 	}
 	rmFiles := []string{
 		s.declarationFilename(identifier),
@@ -194,6 +248,11 @@ func (s *File) DeleteDeclaration(_ context.Context, identifier string) (bool, er
 func (s *File) RetrieveDeclarations(_ context.Context) ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.declarationIDs()
+}
+
+// declarationIDs returns the identifiers of all stored declarations.
+func (s *File) declarationIDs() ([]string, error) {
 	pathPrefix := path.Join(s.path, prefixDeclararion)
 	matches, err := filepath.Glob(pathPrefix + "*" + suffixJSON)
 	if err != nil {
@@ -206,12 +265,27 @@ func (s *File) RetrieveDeclarations(_ context.Context) ([]string, error) {
 	return truncated, nil
 }
 
+// RetrieveDeclarationsPage retrieves a page of the sorted list of declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDeclarationsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	declarations, err := s.RetrieveDeclarations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the file names sort differently than the identifiers
+	sort.Strings(declarations)
+	return storage.PageStrings(declarations, after, limit), nil
+}
+
 // TouchDeclaration rewrites a declaration with a new ServerToken.
 // See also the storage package for documentation on the storage interfaces.
 func (s *File) TouchDeclaration(ctx context.Context, declarationID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.appendJournal(storage.JournalTouchDeclaration, []string{declarationID}, nil, nil); err != nil {
+		return err
+	}
 	d, err := s.readDeclarationFile(declarationID)
 	if err != nil {
 		return err
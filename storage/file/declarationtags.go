@@ -0,0 +1,107 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixTag = "tag.declarations."
+
+// tagFilename returns the path to the tag-to-declaration mapping text file.
+func (s *File) tagFilename(tag string) string {
+	return path.Join(s.path, prefixTag+tag+suffixTXT)
+}
+
+// declarationTagsFilename returns the path to the declaration-to-tag mapping text file.
+func (s *File) declarationTagsFilename(declarationID string) string {
+	return path.Join(s.path, prefixDeclararion+declarationID+".tags.txt")
+}
+
+// RetrieveDeclarationTags retrieves the tags of a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDeclarationTags(_ context.Context, declarationID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getSlice(s.declarationTagsFilename(declarationID))
+}
+
+// RetrieveTagDeclarations retrieves the declarations tagged with a tag.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveTagDeclarations(_ context.Context, tag string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getSlice(s.tagFilename(tag))
+}
+
+// RetrieveTags retrieves all tags.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveTags(_ context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	pathPrefix := path.Join(s.path, prefixTag)
+	matches, err := filepath.Glob(pathPrefix + "*" + suffixTXT)
+	if err != nil {
+		return nil, fmt.Errorf("getting tag file list: %w", err)
+	}
+	truncated := make([]string, len(matches))
+	for i, match := range matches {
+		truncated[i] = match[len(pathPrefix) : len(match)-len(suffixTXT)]
+	}
+	return truncated, nil
+}
+
+// StoreDeclarationTag tags a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreDeclarationTag(_ context.Context, declarationID, tag string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := os.Stat(s.declarationFilename(declarationID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("%w: %v", storage.ErrDeclarationNotFound, err)
+	} else if err != nil {
+		return false, fmt.Errorf("checking declaration: %w", err)
+	}
+	// set the forward reference
+	changed, err := setOrRemoveIn(s.tagFilename(tag), declarationID, true)
+	if err != nil {
+		return false, fmt.Errorf("setting declaration in tag file: %w", err)
+	}
+	if changed {
+		// update the back-reference
+		if _, err = setOrRemoveIn(s.declarationTagsFilename(declarationID), tag, true); err != nil {
+			return false, fmt.Errorf("setting tag in declaration file: %w", err)
+		}
+	}
+	return changed, nil
+}
+
+// RemoveDeclarationTag removes a tag from a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RemoveDeclarationTag(_ context.Context, declarationID, tag string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.removeDeclarationTag(declarationID, tag)
+}
+
+// removeDeclarationTag removes both references of tag and declarationID.
+// The caller is responsible for locking.
+func (s *File) removeDeclarationTag(declarationID, tag string) (bool, error) {
+	// remove the forward reference
+	changed, err := setOrRemoveIn(s.tagFilename(tag), declarationID, false)
+	if err != nil {
+		return false, fmt.Errorf("removing declaration in tag file: %w", err)
+	}
+	if changed {
+		// update the back-reference
+		if _, err = setOrRemoveIn(s.declarationTagsFilename(declarationID), tag, false); err != nil {
+			return false, fmt.Errorf("removing tag in declaration file: %w", err)
+		}
+	}
+	return changed, nil
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveDeclarationUsage retrieves the usage of declarations.
+// References are found by parsing all declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDeclarationUsage(_ context.Context, declarationIDs []string) ([]storage.DeclarationUsage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	allIDs, err := s.declarationIDs()
+	if err != nil {
+		return nil, err
+	}
+	if len(declarationIDs) < 1 {
+		declarationIDs = allIDs
+	}
+
+	// find which declarations reference which
+	referencedBy := make(map[string][]string)
+	for _, id := range allIDs {
+		d, err := s.readDeclarationFile(id)
+		if errors.Is(err, storage.ErrDeclarationNotFound) {
+			// deleted while we were reading
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for _, ref := range d.IdentifierRefs {
+			if contains(referencedBy[ref], d.Identifier) < 0 {
+				referencedBy[ref] = append(referencedBy[ref], d.Identifier)
+			}
+		}
+	}
+
+	ret := make([]storage.DeclarationUsage, 0, len(declarationIDs))
+	for _, id := range declarationIDs {
+		if _, err = os.Stat(s.declarationFilename(id)); errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", storage.ErrDeclarationNotFound, id)
+		} else if err != nil {
+			return nil, fmt.Errorf("checking declaration: %w", err)
+		}
+		sets, err := getSlice(s.declarationSetsFilename(id))
+		if err != nil {
+			return nil, fmt.Errorf("getting sets from declaration: %w", err)
+		}
+		u := storage.DeclarationUsage{
+			Identifier:   id,
+			Sets:         append([]string{}, sets...),
+			ReferencedBy: append([]string{}, referencedBy[id]...),
+		}
+		enrollmentIDs := make(map[string]struct{})
+		for _, setName := range u.Sets {
+			setEnrollmentIDs, err := getSlice(s.setEnrollmentsFilename(setName))
+			if err != nil {
+				return nil, fmt.Errorf("getting enrollments from set: %w", err)
+			}
+			for _, enrollmentID := range setEnrollmentIDs {
+				enrollmentIDs[enrollmentID] = struct{}{}
+			}
+		}
+		u.Enrollments = len(enrollmentIDs)
+		sort.Strings(u.Sets)
+		sort.Strings(u.ReferencedBy)
+		ret = append(ret, u)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Identifier < ret[j].Identifier })
+	return ret, nil
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // RetrieveEnrollmentSets returns the slice of sets associated with an enrollment ID.
@@ -20,6 +22,9 @@ func (s *File) RetrieveEnrollmentSets(_ context.Context, enrollmentID string) ([
 func (s *File) StoreEnrollmentSet(_ context.Context, enrollmentID, setName string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreEnrollmentSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
 	err := s.assureEnrollmentDirExists(enrollmentID)
 	if err != nil {
 		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
@@ -50,6 +55,9 @@ func (s *File) StoreEnrollmentSet(_ context.Context, enrollmentID, setName strin
 func (s *File) RemoveEnrollmentSet(_ context.Context, enrollmentID, setName string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalRemoveEnrollmentSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
 	err := s.assureEnrollmentDirExists(enrollmentID)
 	if err != nil {
 		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
@@ -98,7 +106,7 @@ func (s *File) retrieveEnrollmentIDs(declarations []string, sets []string, ids [
 				continue
 			}
 			// find all ids associated with these sets
-			setIDs, err := getSlice(s.setEnrollmentsFilename(setName))
+			setIDs, err := s.setEnrollmentIDs(setName)
 			if err != nil {
 				return nil, fmt.Errorf("getting enrollments for set %s: %w", setName, err)
 			}
@@ -114,7 +122,7 @@ func (s *File) retrieveEnrollmentIDs(declarations []string, sets []string, ids [
 			continue
 		}
 		// find all ids associated with these sets
-		setIDs, err := getSlice(s.setEnrollmentsFilename(setName))
+		setIDs, err := s.setEnrollmentIDs(setName)
 		if err != nil {
 			return nil, fmt.Errorf("getting enrollments for set %s: %w", setName, err)
 		}
@@ -131,11 +139,15 @@ func (s *File) retrieveEnrollmentIDs(declarations []string, sets []string, ids [
 		}
 		// check if we've previously seen this id before
 		// (by checking if we've written a tokens file for it before)
-		_, err := os.Stat(s.tokensFilename(id))
-		if err == nil {
-			retIDs[id] = struct{}{}
-		} else if !errors.Is(err, os.ErrNotExist) {
-			return nil, fmt.Errorf("getting enrollments sets for id %s: %w", id, err)
+		// or if it has been registered
+		for _, filename := range []string{s.tokensFilename(id), s.registrationFilename(id)} {
+			_, err := os.Stat(filename)
+			if err == nil {
+				retIDs[id] = struct{}{}
+				break
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("getting enrollments sets for id %s: %w", id, err)
+			}
 		}
 	}
 
@@ -9,6 +9,9 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // File is a filesystem-based storage backend.
@@ -16,20 +19,115 @@ type File struct {
 	mu      sync.RWMutex
 	path    string
 	newHash func() hash.Hash
+	history *storage.StatusValueHistory
+	limits  *storage.StatusLimits
+	clock   storage.Clock
+
+	// preserve keeps the bytes of stored declarations as uploaded
+	preserve bool
+
+	// compress gzip compresses large JSON files
+	compress bool
+
+	// previousHashes may have computed existing declaration tokens
+	previousHashes []func() hash.Hash
+
+	// journalSeq is the last journal sequence number (if read)
+	journalSeq int64
+
+	// countersMu protects the counter increments not yet written
+	countersMu      sync.Mutex
+	counters        fileCounters
+	countersFlushed time.Time
+	countersFlush   time.Duration
+}
+
+type Option func(*File)
+
+// WithValueHistory records the changes of the status values of paths.
+// At most max values are kept per enrollment and path.
+func WithValueHistory(paths []string, max int) Option {
+	return func(s *File) {
+		s.history = &storage.StatusValueHistory{Paths: paths, Max: max}
+	}
+}
+
+// WithStatusLimits limits the number of status values and errors stored.
+func WithStatusLimits(limits *storage.StatusLimits) Option {
+	return func(s *File) {
+		s.limits = limits
+	}
+}
+
+// WithClock sets the clock used to timestamp stored data.
+// The default is the system clock.
+func WithClock(clock storage.Clock) Option {
+	return func(s *File) {
+		s.clock = clock
+	}
+}
+
+// WithPreservedDeclarations stores declarations byte-for-byte as
+// uploaded with the ServerToken spliced in rather than re-marshaling
+// them (which sorts their keys and reformats their numbers). This keeps
+// stored declarations byte-comparable with their sources. Note that
+// whitespace changes to an uploaded declaration then change its token.
+func WithPreservedDeclarations() Option {
+	return func(s *File) {
+		s.preserve = true
+	}
+}
+
+// WithPreviousHashes recognizes declaration tokens computed with any of
+// newHashes as unchanged when storing declarations. Such tokens are
+// rewritten with the configured hash as their declarations are stored
+// and MigrateHash no longer recomputes all tokens when the hash
+// algorithm changes. This avoids changing the tokens of every
+// enrollment at once when migrating between hash algorithms.
+func WithPreviousHashes(newHashes ...func() hash.Hash) Option {
+	return func(s *File) {
+		s.previousHashes = newHashes
+	}
+}
+
+// WithCompression gzip compresses the stored declarations, the
+// declaration-items and tokens JSON of enrollments, and the raw status
+// reports. Compressed files are decompressed when read whether or not
+// compression is enabled.
+func WithCompression() Option {
+	return func(s *File) {
+		s.compress = true
+	}
+}
+
+// WithCountersFlushInterval sets how often counter increments kept in
+// memory are written to disk. Zero writes every increment.
+// The default is DefaultCountersFlushInterval.
+func WithCountersFlushInterval(interval time.Duration) Option {
+	return func(s *File) {
+		s.countersFlush = interval
+	}
 }
 
 // New creates and initializes a new filesystem-based storage backend.
-func New(path string, newHash func() hash.Hash) (*File, error) {
+func New(path string, newHash func() hash.Hash, opts ...Option) (*File, error) {
 	if newHash == nil {
 		panic("newHash must not be nil")
 	}
 	if err := os.Mkdir(path, 0755); err != nil && !errors.Is(err, os.ErrExist) {
 		return nil, err
 	}
-	return &File{
+	s := &File{
 		path:    path,
 		newHash: newHash,
-	}, nil
+		clock:   storage.SystemClock,
+
+		countersFlush: DefaultCountersFlushInterval,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s, nil
 }
 
 const (
@@ -38,6 +136,7 @@ const (
 	suffixTXT            = ".txt"
 	prefixSet            = "set.declarations."
 	prefixSetEnrollments = "set.enrollments."
+	prefixSetConditions  = "set.conditions."
 
 	declarationItemsFilename = "declaration-items.json"
 	tokensFilename           = "tokens.json"
@@ -48,6 +147,11 @@ func (s *File) setFilename(setName string) string {
 	return path.Join(s.path, prefixSet+setName+suffixTXT)
 }
 
+// setConditionsFilename returns the path to the set declaration conditions JSON file.
+func (s *File) setConditionsFilename(setName string) string {
+	return path.Join(s.path, prefixSetConditions+setName+suffixJSON)
+}
+
 // declarationSetsFilename returns the path to the declaration-to-set mapping text file.
 func (s *File) declarationSetsFilename(declarationID string) string {
 	return path.Join(s.path, prefixDeclararion+declarationID+".sets.txt")
@@ -76,6 +180,24 @@ func (s *File) declarationSaltFilename(identifier string) string {
 	return path.Join(s.path, prefixDeclararion+identifier+".salt.dat")
 }
 
+// knownEnrollmentIDs returns the IDs of the enrollments that have a
+// directory, sorted by name. The directories of hashed enrollment IDs
+// (see storage.HashedEnrollmentIDPrefix) are not enrollments and are
+// skipped. The caller must hold the lock.
+func (s *File) knownEnrollmentIDs() ([]string, error) {
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading enrollments: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), storage.HashedEnrollmentIDPrefix) {
+			ids = append(ids, entry.Name())
+		}
+	}
+	return ids, nil
+}
+
 // enrollmentSetsFilename returns the path to the enrollment ID-to-set mapping file.
 // Note it is contained within the enrollment ID directory.
 func (s *File) enrollmentSetsFilename(enrollmentID string) string {
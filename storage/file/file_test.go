@@ -1,13 +1,21 @@
 package file
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"hash"
 	"os"
+	"path"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	ddmtest "github.com/jessepeterson/kmfddm/http/ddm/test"
+	"github.com/jessepeterson/kmfddm/idhash"
+	"github.com/jessepeterson/kmfddm/storage"
 	"github.com/jessepeterson/kmfddm/storage/test"
 )
 
@@ -21,10 +29,72 @@ func TestFile(t *testing.T) {
 
 	test.TestBasic(t, s, context.Background())
 	test.TestBasicStatus(t, "../test", s, context.Background())
+	ddmtest.TestContract(t, "../../http/ddm/test", s, context.Background())
 
 	os.RemoveAll(testPath)
 }
 
+func TestFileValueHistory(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithValueHistory([]string{test.StatusValueHistoryPath}, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestStatusValueHistory(t, s, context.Background(), 3)
+}
+
+func TestFileStatusLimits(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithStatusLimits(&storage.StatusLimits{
+		ReportValues: 3,
+		ReportErrors: 2,
+		Values:       4,
+		Errors:       3,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestStatusLimits(t, s, context.Background())
+}
+
+func TestFileClock(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithClock(storage.ClockFunc(func() time.Time { return test.ClockTime })))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestStatusClock(t, s, context.Background())
+}
+
+func TestFilePreservedDeclarations(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithPreservedDeclarations())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestBasic(t, s, context.Background())
+
+	const raw = `{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_preserved","Payload":{"Echo":"a","Number":1.50},"ServerToken":"old"}`
+	d, err := ddm.ParseDeclaration([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.RetrieveDeclaration(context.Background(), d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ddm.SpliceServerToken([]byte(raw), stored.ServerToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stored.Raw, want) {
+		t.Errorf("have: %s, want: %s", stored.Raw, want)
+	}
+}
+
 func TestSliceOps(t *testing.T) {
 	a := []string{"a", "b", "c"}
 	if contains(a, "b") < 0 {
@@ -50,3 +120,578 @@ func TestSliceOps(t *testing.T) {
 		t.Errorf("not equal")
 	}
 }
+
+func TestEnrollmentDDMFallback(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID = "4F1E5B3A-9E0A-4F2B-8D0C-FALLBACK0001"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_fallback","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, "test_golang_fallback_set", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreEnrollmentSet(ctx, enrollmentID, "test_golang_fallback_set"); err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate missing precomputed DDM files
+	for _, filename := range []string{
+		s.declarationItemsFilename(enrollmentID),
+		s.tokensFilename(enrollmentID),
+		s.enrollmentDeclarationFilename(d.Identifier, "configuration", enrollmentID),
+	} {
+		if err = os.Remove(filename); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diJSON, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(diJSON, []byte(d.Identifier)) {
+		t.Errorf("declaration items missing declaration: %s", diJSON)
+	}
+	if _, err = os.Stat(s.tokensFilename(enrollmentID)); err != nil {
+		t.Errorf("tokens not back-filled: %v", err)
+	}
+	if _, err = s.RetrieveEnrollmentDeclarationJSON(ctx, d.Identifier, "configuration", enrollmentID); err != nil {
+		t.Error(err)
+	}
+
+	// an unknown enrollment gets an empty document rather than an error
+	if _, err = s.RetrieveTokensJSON(ctx, "test_golang_fallback_unknown"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRepairEnrollmentDDM(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID = "4F1E5B3A-9E0A-4F2B-8D0C-REPAIR000001"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_repair","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, "test_golang_repair_set", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreEnrollmentSet(ctx, enrollmentID, "test_golang_repair_set"); err != nil {
+		t.Fatal(err)
+	}
+	diJSON, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate a partial write of the derived DDM files
+	if err = os.WriteFile(s.declarationItemsFilename(enrollmentID), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Remove(s.enrollmentDeclarationFilename(d.Identifier, "configuration", enrollmentID)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"declaration-items", "declarations"}
+	for _, dryRun := range []bool{true, false} {
+		report, err := s.RepairEnrollmentDDM(ctx, dryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(report.Mismatches), 1; have != want {
+			t.Fatalf("mismatches: have: %v, want: %v", have, want)
+		}
+		if have := report.Mismatches[0].Mismatched; !reflect.DeepEqual(have, want) {
+			t.Errorf("mismatched: have: %v, want: %v", have, want)
+		}
+	}
+
+	repaired, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(repaired, diJSON) {
+		t.Errorf("declaration items not repaired: have: %s, want: %s", repaired, diJSON)
+	}
+	if _, err = s.RetrieveEnrollmentDeclarationJSON(ctx, d.Identifier, "configuration", enrollmentID); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshEnrollmentDDM(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID = "4F1E5B3A-9E0A-4F2B-8D0C-REFRESH00001"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_refresh","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, "test_golang_refresh_set", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreEnrollmentSet(ctx, enrollmentID, "test_golang_refresh_set"); err != nil {
+		t.Fatal(err)
+	}
+	tokensJSON, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// simulate stale derived DDM files
+	if err = os.WriteFile(s.tokensFilename(enrollmentID), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.RefreshEnrollmentDDM(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	refreshed, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(refreshed, tokensJSON) {
+		t.Errorf("tokens not refreshed: have: %s, want: %s", refreshed, tokensJSON)
+	}
+}
+
+func TestDDMRebuilds(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID1 = "4F1E5B3A-9E0A-4F2B-8D0C-REBUILD00001"
+	const enrollmentID2 = "4F1E5B3A-9E0A-4F2B-8D0C-REBUILD00002"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_rebuild","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{enrollmentID1, enrollmentID2} {
+		if _, err = s.StoreEnrollmentSet(ctx, id, "test_golang_rebuild_set"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// simulate a failure to write the DDM files of the first enrollment
+	blocker := s.tokensFilename(enrollmentID1)
+	if err = os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Mkdir(blocker, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, "test_golang_rebuild_set", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second enrollment is rebuilt despite the first failing
+	diJSON, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(diJSON, []byte(d.Identifier)) {
+		t.Errorf("declaration items not rebuilt: %s", diJSON)
+	}
+
+	rebuilds, err := s.RetrieveDDMRebuilds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(rebuilds), 1; have != want {
+		t.Fatalf("rebuilds: have: %v, want: %v", have, want)
+	}
+	if have, want := rebuilds[0].EnrollmentID, enrollmentID1; have != want {
+		t.Errorf("rebuild enrollment ID: have: %v, want: %v", have, want)
+	}
+	if rebuilds[0].Attempts != 1 || rebuilds[0].Error == "" {
+		t.Errorf("rebuild: have: %d attempts with error %q, want: 1 attempt with error", rebuilds[0].Attempts, rebuilds[0].Error)
+	}
+
+	// retrying while still failing counts the attempt
+	rebuilt, err := s.RetryDDMRebuilds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rebuilt) != 0 {
+		t.Errorf("rebuilt: have: %v, want: none", rebuilt)
+	}
+	if rebuilds, err = s.RetrieveDDMRebuilds(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(rebuilds) != 1 || rebuilds[0].Attempts != 2 {
+		t.Errorf("rebuilds: have: %v, want: 1 with 2 attempts", rebuilds)
+	}
+
+	if err = os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt, err = s.RetryDDMRebuilds(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := rebuilt, []string{enrollmentID1}; !reflect.DeepEqual(have, want) {
+		t.Errorf("rebuilt: have: %v, want: %v", have, want)
+	}
+	if rebuilds, err = s.RetrieveDDMRebuilds(ctx); err != nil {
+		t.Fatal(err)
+	} else if len(rebuilds) != 0 {
+		t.Errorf("rebuilds after retry: have: %v, want: none", rebuilds)
+	}
+	tokensJSON, err := s.RetrieveTokensJSON(ctx, enrollmentID1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := s.RetrieveTokensJSON(ctx, enrollmentID2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tokensJSON, want) {
+		t.Errorf("tokens not rebuilt: have: %s, want: %s", tokensJSON, want)
+	}
+}
+
+func TestMigrateHash(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID = "4F1E5B3A-9E0A-4F2B-8D0C-MIGRATE00001"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_migrate","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, "test_golang_migrate_set", d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreEnrollmentSet(ctx, enrollmentID, "test_golang_migrate_set"); err != nil {
+		t.Fatal(err)
+	}
+	before, err := s.RetrieveDeclaration(ctx, d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokensJSON, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// unrecorded tokens are assumed to be the default
+	if migrated, err := s.MigrateHash(ctx, "xxhash"); err != nil {
+		t.Fatal(err)
+	} else if migrated {
+		t.Error("migrated with unchanged hash algorithm")
+	}
+
+	s, err = New(dir, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{true, false} {
+		migrated, err := s.MigrateHash(ctx, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrated != want {
+			t.Errorf("migrated: have: %v, want: %v", migrated, want)
+		}
+	}
+
+	after, err := s.RetrieveDeclaration(ctx, d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(after.ServerToken), sha256.Size*2; have != want {
+		t.Errorf("server token length: have: %v, want: %v", have, want)
+	}
+	if after.ServerToken == before.ServerToken {
+		t.Error("server token not recomputed")
+	}
+	migratedJSON, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(migratedJSON, tokensJSON) {
+		t.Error("tokens not recomputed")
+	}
+	if report, err := s.RepairEnrollmentDDM(ctx, true); err != nil {
+		t.Fatal(err)
+	} else if len(report.Mismatches) > 0 {
+		t.Errorf("mismatches after migration: %v", report.Mismatches)
+	}
+}
+
+func TestMigrateHashLazy(t *testing.T) {
+	dir := t.TempDir()
+	s, err := New(dir, func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_migrate_lazy","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	before, err := s.RetrieveDeclaration(ctx, d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a declaration that is not stored again during the lazy migration
+	other, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_migrate_lazy_other","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = New(dir, sha256.New, WithPreviousHashes(func() hash.Hash { return xxhash.New() }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := s.MigrateHash(ctx, "sha256"); err != nil {
+		t.Fatal(err)
+	} else if migrated {
+		t.Error("migrated with previous hashes")
+	}
+	if unmigrated, err := s.RetrieveDeclaration(ctx, d.Identifier); err != nil {
+		t.Fatal(err)
+	} else if unmigrated.ServerToken != before.ServerToken {
+		t.Error("server token recomputed before declaration stored")
+	}
+
+	// the unchanged declaration is rewritten with the new hash
+	if changed, err := s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Error("declaration with previous hash token changed")
+	}
+	after, err := s.RetrieveDeclaration(ctx, d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(after.ServerToken), sha256.Size*2; have != want {
+		t.Errorf("server token length: have: %v, want: %v", have, want)
+	}
+
+	// changes are still detected
+	d, err = ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_migrate_lazy","Payload":{"Echo":"Bar"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	} else if !changed {
+		t.Error("changed declaration not changed")
+	}
+
+	// a migration without previous hashes finishes the lazy migration
+	s, err = New(dir, sha256.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{true, false} {
+		migrated, err := s.MigrateHash(ctx, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if migrated != want {
+			t.Errorf("migrated: have: %v, want: %v", migrated, want)
+		}
+	}
+	if migrated, err := s.RetrieveDeclaration(ctx, other.Identifier); err != nil {
+		t.Fatal(err)
+	} else if have, want := len(migrated.ServerToken), sha256.Size*2; have != want {
+		t.Errorf("unstored server token length: have: %v, want: %v", have, want)
+	}
+}
+
+func TestFileCompression(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithCompression())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestBasic(t, s, context.Background())
+	test.TestBasicStatus(t, "../test", s, context.Background())
+	ddmtest.TestContract(t, "../../http/ddm/test", s, context.Background())
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_compressed","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(s.declarationFilename(d.Identifier))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(b, []byte("{")) {
+		t.Error("declaration not compressed")
+	}
+
+	// compressed files are read without compression enabled
+	s.compress = false
+	d2, err := s.RetrieveDeclaration(context.Background(), d.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := d2.Identifier, d.Identifier; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+}
+
+func TestHashedEnrollmentIDs(t *testing.T) {
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	const enrollmentID = "4F1E5B3A-9E0A-4F2B-8D0C-HASHED000001"
+	const setName = "test_golang_hashed_set"
+
+	d, err := ddm.ParseDeclaration([]byte(`{"Type":"com.apple.configuration.management.test","Identifier":"test_golang_hashed","Payload":{"Echo":"Foo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreDeclaration(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetDeclaration(ctx, setName, d.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.StoreSetPattern(ctx, &storage.SetPattern{Set: setName, Kind: storage.SetPatternGlob, Pattern: "*"}); err != nil {
+		t.Fatal(err)
+	}
+
+	hasher := idhash.NewSaltedHasher([]byte("salt"))
+	h := idhash.New(s, hasher)
+	_, status, err := ddm.ParseStatus([]byte(`{"StatusItems":{"device":{"operating-system":{"version":"17.1"}}},"Errors":[{"ErrorCode":1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = h.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		t.Fatal(err)
+	}
+	hashedID := storage.HashedEnrollmentIDPrefix + hasher.HashID(enrollmentID)
+	if _, err = os.Stat(path.Join(s.path, hashedID)); err != nil {
+		t.Fatalf("expected directory of hashed enrollment ID: %v", err)
+	}
+
+	// the hashed enrollment ID is not matched by set patterns
+	ids, err := s.RetrieveEnrollmentIDs(ctx, nil, []string{setName}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{enrollmentID}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("set enrollments: have: %v, want: %v", ids, want)
+	}
+
+	// nor repaired as an enrollment
+	report, err := s.RepairEnrollmentDDM(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := report.Enrollments, 1; have != want {
+		t.Errorf("repaired enrollments: have: %v, want: %v", have, want)
+	}
+	for _, m := range report.Mismatches {
+		if m.EnrollmentID == hashedID {
+			t.Errorf("hashed enrollment ID repaired: %v", m)
+		}
+	}
+}
+
+func TestCountersFlush(t *testing.T) {
+	now := test.ClockTime
+	clock := storage.ClockFunc(func() time.Time { return now })
+	s, err := New(t.TempDir(), func() hash.Hash { return xxhash.New() }, WithClock(clock), WithCountersFlushInterval(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	inc := []storage.CounterIncrement{{Scope: storage.CounterScopeGlobal, Name: "test", Delta: 1}}
+
+	written := func() int64 {
+		t.Helper()
+		counters, err := s.readCounters()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counters[storage.CounterScopeGlobal][""]["test"]
+	}
+	retrieved := func() int64 {
+		t.Helper()
+		counters, err := s.RetrieveCounters(ctx, storage.CounterScopeGlobal, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return counters["test"]
+	}
+
+	// the first increment is written
+	if err = s.IncrementCounters(ctx, inc); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := written(), int64(1); have != want {
+		t.Errorf("written: have: %v, want: %v", have, want)
+	}
+
+	// increments within the flush interval are kept in memory
+	now = now.Add(30 * time.Second)
+	if err = s.IncrementCounters(ctx, inc); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := written(), int64(1); have != want {
+		t.Errorf("written: have: %v, want: %v", have, want)
+	}
+	if have, want := retrieved(), int64(2); have != want {
+		t.Errorf("retrieved: have: %v, want: %v", have, want)
+	}
+
+	// and written together once it passes
+	now = now.Add(30 * time.Second)
+	if err = s.IncrementCounters(ctx, inc); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := written(), int64(3); have != want {
+		t.Errorf("written: have: %v, want: %v", have, want)
+	}
+	if have, want := retrieved(), int64(3); have != want {
+		t.Errorf("retrieved: have: %v, want: %v", have, want)
+	}
+}
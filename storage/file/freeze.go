@@ -0,0 +1,86 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixFreeze = "freeze."
+
+// freezeFilename returns the path to the freeze JSON file of enrollmentID.
+func (s *File) freezeFilename(enrollmentID string) string {
+	return path.Join(s.path, prefixFreeze+enrollmentID+suffixJSON)
+}
+
+// StoreEnrollmentFreeze freezes an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentFreeze(_ context.Context, freeze *storage.EnrollmentFreeze) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreEnrollmentFreeze, nil, nil, []string{freeze.EnrollmentID}); err != nil {
+		return err
+	}
+	b, err := json.Marshal(freeze)
+	if err != nil {
+		return fmt.Errorf("marshal enrollment freeze: %w", err)
+	}
+	return os.WriteFile(s.freezeFilename(freeze.EnrollmentID), b, 0644)
+}
+
+// RetrieveEnrollmentFreeze retrieves the freeze of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentFreeze(_ context.Context, enrollmentID string) (*storage.EnrollmentFreeze, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, err := os.ReadFile(s.freezeFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading enrollment freeze: %w", err)
+	}
+	freeze := new(storage.EnrollmentFreeze)
+	if err = json.Unmarshal(b, freeze); err != nil {
+		return nil, fmt.Errorf("unmarshal enrollment freeze: %w", err)
+	}
+	return freeze, nil
+}
+
+// DeleteEnrollmentFreeze unfreezes an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteEnrollmentFreeze(_ context.Context, enrollmentID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalDeleteEnrollmentFreeze, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	err := os.Remove(s.freezeFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("deleting enrollment freeze: %w", err)
+	}
+	return true, nil
+}
+
+// RetrieveFrozenEnrollmentIDs retrieves the frozen enrollments of enrollmentIDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveFrozenEnrollmentIDs(_ context.Context, enrollmentIDs []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var frozen []string
+	for _, enrollmentID := range enrollmentIDs {
+		_, err := os.Stat(s.freezeFilename(enrollmentID))
+		if err == nil {
+			frozen = append(frozen, enrollmentID)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("checking enrollment freeze: %w", err)
+		}
+	}
+	return frozen, nil
+}
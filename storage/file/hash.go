@@ -0,0 +1,63 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const hashAlgorithmFilename = "hash.txt"
+
+// MigrateHash recomputes the declaration server tokens and the derived
+// DDM data of all enrollments if the hash algorithm changed.
+// With previous hashes (see WithPreviousHashes) the tokens are instead
+// recomputed as declarations are stored. The previous algorithm then
+// stays recorded as the algorithm being migrated from so that a later
+// migration without previous hashes recomputes the remaining tokens.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) MigrateHash(_ context.Context, algorithm string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	filename := path.Join(s.path, hashAlgorithmFilename)
+	previous := storage.DefaultHashAlgorithm
+	if b, err := os.ReadFile(filename); err == nil {
+		previous = strings.TrimSpace(string(b))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("reading hash algorithm: %w", err)
+	}
+	if previous != algorithm && len(s.previousHashes) > 0 {
+		// declarations not stored since may have tokens of the previous algorithm
+		return false, nil
+	}
+	migrate := previous != algorithm
+	if migrate {
+		declarationIDs, err := s.declarationIDs()
+		if err != nil {
+			return false, err
+		}
+		for _, declarationID := range declarationIDs {
+			d, err := s.readDeclarationFile(declarationID)
+			if err != nil {
+				return false, err
+			}
+			// the token is re-hashed with the existing salt
+			if _, err = s.writeDeclarationFiles(d, false); err != nil {
+				return false, fmt.Errorf("writing declaration %s: %w", declarationID, err)
+			}
+		}
+		if _, err = s.repairEnrollmentDDM(false); err != nil {
+			return false, err
+		}
+	}
+	// record the algorithm only after the tokens are migrated so that
+	// an interrupted migration is retried
+	if err := os.WriteFile(filename, []byte(algorithm+"\n"), 0644); err != nil {
+		return false, fmt.Errorf("writing hash algorithm: %w", err)
+	}
+	return migrate, nil
+}
@@ -0,0 +1,157 @@
+package file
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const csvFilenameValueHistory = "status.values.history"
+
+func (s *File) readStatusValueHistory(enrollmentID string) ([]storage.StatusValue, error) {
+	csvFile, err := os.Open(s.csvFilename(csvFilenameValueHistory, enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		// no status value history (yet)
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("opening status value history CSV: %w", err)
+	}
+	defer csvFile.Close()
+	reader := csv.NewReader(csvFile)
+
+	var ret []storage.StatusValue
+	for {
+		// read a record
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("reading CSV record: %w", err)
+		}
+
+		// record is a set length
+		if len(record) != 5 {
+			return nil, fmt.Errorf("record fields: %d", len(record))
+		}
+
+		// decode the timestamp
+		var ts time.Time
+		if err = ts.UnmarshalText([]byte(record[0])); err != nil {
+			return nil, fmt.Errorf("unmarshal time: %w", err)
+		}
+
+		ret = append(ret, storage.StatusValue{
+			Timestamp: ts,
+			Path:      record[1],
+			Type:      record[2],
+			Value:     record[3],
+			StatusID:  record[4],
+		})
+	}
+	return ret, nil
+}
+
+// storeStatusValueHistory appends the values that changed since they
+// were last recorded and then trims the history of each path.
+func (s *File) storeStatusValueHistory(enrollmentID, statusID string, values []ddm.StatusValue) error {
+	if len(values) < 1 {
+		return nil
+	}
+
+	history, err := s.readStatusValueHistory(enrollmentID)
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+
+	// find the most recently recorded value of each path
+	last := make(map[string]storage.StatusValue)
+	for _, v := range history {
+		last[v.Path] = v
+	}
+
+	var changed bool
+	now := s.clock.Now()
+	for _, v := range values {
+		if l, ok := last[v.Path]; ok && l.Type == v.ValueType && l.Value == string(v.Value) {
+			continue
+		}
+		sv := storage.StatusValue{
+			Timestamp: now,
+			Path:      v.Path,
+			Type:      v.ValueType,
+			Value:     string(v.Value),
+			StatusID:  statusID,
+		}
+		history = append(history, sv)
+		last[v.Path] = sv
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	// count the values of each path so we can keep only the newest
+	counts := make(map[string]int)
+	for _, v := range history {
+		counts[v.Path]++
+	}
+
+	csvFile, err := os.OpenFile(s.csvFilename(csvFilenameValueHistory, enrollmentID), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("opening status value history CSV: %w", err)
+	}
+	defer csvFile.Close()
+	writer := csv.NewWriter(csvFile)
+
+	var records [][]string
+	for _, v := range history {
+		if counts[v.Path] > s.history.Max {
+			counts[v.Path]--
+			continue
+		}
+		tsText, err := v.Timestamp.MarshalText()
+		if err != nil {
+			return fmt.Errorf("marshal time to text: %w", err)
+		}
+		records = append(records, []string{
+			string(tsText),
+			v.Path,
+			v.Type,
+			v.Value,
+			v.StatusID,
+		})
+	}
+
+	if err = writer.WriteAll(records); err != nil {
+		return fmt.Errorf("writing records: %w", err)
+	}
+
+	return nil
+}
+
+// RetrieveStatusValueHistory retrieves the recorded values of path for enrollmentIDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveStatusValueHistory(_ context.Context, enrollmentIDs []string, path string) (map[string][]storage.StatusValue, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string][]storage.StatusValue)
+	for _, enrollmentID := range enrollmentIDs {
+		history, err := s.readStatusValueHistory(enrollmentID)
+		if err != nil {
+			return nil, fmt.Errorf("reading status value history: %w", err)
+		}
+		for _, v := range history {
+			if v.Path == path {
+				ret[enrollmentID] = append(ret[enrollmentID], v)
+			}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,87 @@
+package file
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixIdempotency = "idempotency."
+
+// idempotencyFilename returns the path to the recorded response for key.
+// The key is hashed so that arbitrary client-supplied keys are safe to
+// use as filenames.
+func (s *File) idempotencyFilename(key string) string {
+	return path.Join(s.path, fmt.Sprintf("%s%x%s", prefixIdempotency, sha256.Sum256([]byte(key)), suffixJSON))
+}
+
+// RetrieveIdempotentResponse retrieves the recorded response for key.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveIdempotentResponse(_ context.Context, key string) (*storage.IdempotentResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, err := os.ReadFile(s.idempotencyFilename(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading idempotent response: %w", err)
+	}
+	resp := new(storage.IdempotentResponse)
+	if err = json.Unmarshal(b, resp); err != nil {
+		return nil, fmt.Errorf("unmarshal idempotent response: %w", err)
+	}
+	return resp, nil
+}
+
+// StoreIdempotentResponse records resp for key.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreIdempotentResponse(_ context.Context, key string, resp *storage.IdempotentResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("marshal idempotent response: %w", err)
+	}
+	return os.WriteFile(s.idempotencyFilename(key), b, 0644)
+}
+
+// DeleteIdempotentResponses deletes the responses recorded before a time.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteIdempotentResponses(_ context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return fmt.Errorf("reading idempotent responses: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefixIdempotency) || !strings.HasSuffix(name, suffixJSON) {
+			continue
+		}
+		filename := path.Join(s.path, name)
+		b, err := os.ReadFile(filename)
+		if err != nil {
+			return fmt.Errorf("reading idempotent response: %w", err)
+		}
+		resp := new(storage.IdempotentResponse)
+		if err = json.Unmarshal(b, resp); err != nil {
+			return fmt.Errorf("unmarshal idempotent response: %w", err)
+		}
+		if !resp.Timestamp.Before(before) {
+			continue
+		}
+		if err = os.Remove(filename); err != nil {
+			return fmt.Errorf("deleting idempotent response: %w", err)
+		}
+	}
+	return nil
+}
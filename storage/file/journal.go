@@ -0,0 +1,91 @@
+package file
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const journalFilename = "journal.jsonl"
+
+// scanJournal calls fn with each journal entry in order until fn returns false.
+// The caller must hold the lock.
+func (s *File) scanJournal(fn func(*storage.JournalEntry) bool) error {
+	f, err := os.Open(path.Join(s.path, journalFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		entry := new(storage.JournalEntry)
+		if err = json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("unmarshal journal entry: %w", err)
+		}
+		if !fn(entry) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// appendJournal appends an entry for op to the journal.
+// The caller must hold the (write) lock.
+func (s *File) appendJournal(op string, declarations, sets, enrollments []string) error {
+	if s.journalSeq < 1 {
+		// find the last sequence number
+		err := s.scanJournal(func(e *storage.JournalEntry) bool {
+			s.journalSeq = e.Seq
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	entry := &storage.JournalEntry{
+		Seq:          s.journalSeq + 1,
+		Timestamp:    s.clock.Now().UTC(),
+		Op:           op,
+		Declarations: declarations,
+		Sets:         sets,
+		Enrollments:  enrollments,
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshal journal entry: %w", err)
+	}
+	f, err := os.OpenFile(path.Join(s.path, journalFilename), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	defer f.Close()
+	if _, err = f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	s.journalSeq = entry.Seq
+	return nil
+}
+
+// RetrieveJournal retrieves the journal of storage mutations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveJournal(_ context.Context, after int64, limit int) ([]storage.JournalEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var entries []storage.JournalEntry
+	err := s.scanJournal(func(e *storage.JournalEntry) bool {
+		if e.Seq > after {
+			entries = append(entries, *e)
+		}
+		return limit < 1 || len(entries) < limit
+	})
+	return entries, err
+}
@@ -0,0 +1,167 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const lifecycleStagesFilename = "lifecycle.stages.json"
+
+// enrollmentLifecycleStageFilename returns the path to the enrollment ID lifecycle stage file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) enrollmentLifecycleStageFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "lifecycle-stage.txt")
+}
+
+// readLifecycleStages reads the lifecycle stages.
+// The caller must hold the lock.
+func (s *File) readLifecycleStages() ([]storage.LifecycleStage, error) {
+	b, err := os.ReadFile(path.Join(s.path, lifecycleStagesFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading lifecycle stages: %w", err)
+	}
+	var stages []storage.LifecycleStage
+	if err = json.Unmarshal(b, &stages); err != nil {
+		return nil, fmt.Errorf("unmarshal lifecycle stages: %w", err)
+	}
+	return stages, nil
+}
+
+// writeLifecycleStages sorts and writes the lifecycle stages.
+// The caller must hold the lock.
+func (s *File) writeLifecycleStages(stages []storage.LifecycleStage) error {
+	sort.Slice(stages, func(i, j int) bool { return stages[i].Name < stages[j].Name })
+	b, err := json.Marshal(stages)
+	if err != nil {
+		return fmt.Errorf("marshal lifecycle stages: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, lifecycleStagesFilename), b, 0644)
+}
+
+// RetrieveLifecycleStages retrieves all lifecycle stages.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveLifecycleStages(_ context.Context) ([]storage.LifecycleStage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readLifecycleStages()
+}
+
+// StoreLifecycleStage stores a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreLifecycleStage(_ context.Context, stage *storage.LifecycleStage) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stages, err := s.readLifecycleStages()
+	if err != nil {
+		return false, err
+	}
+	for i := range stages {
+		if stages[i].Name != stage.Name {
+			continue
+		}
+		if reflect.DeepEqual(stages[i], *stage) {
+			return false, nil
+		}
+		stages[i] = *stage
+		return true, s.writeLifecycleStages(stages)
+	}
+	return true, s.writeLifecycleStages(append(stages, *stage))
+}
+
+// DeleteLifecycleStage deletes a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteLifecycleStage(_ context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stages, err := s.readLifecycleStages()
+	if err != nil {
+		return false, err
+	}
+	for i := range stages {
+		if stages[i].Name == name {
+			return true, s.writeLifecycleStages(append(stages[:i], stages[i+1:]...))
+		}
+	}
+	return false, nil
+}
+
+// RetrieveEnrollmentLifecycleStage retrieves the lifecycle stage of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentLifecycleStage(_ context.Context, enrollmentID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stage, err := getSlice(s.enrollmentLifecycleStageFilename(enrollmentID))
+	if err != nil || len(stage) < 1 {
+		return "", err
+	}
+	return stage[0], nil
+}
+
+// TransitionEnrollmentLifecycleStage transitions an enrollment to a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) TransitionEnrollmentLifecycleStage(_ context.Context, enrollmentID, name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalTransitionEnrollmentLifecycleStage, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	stages, err := s.readLifecycleStages()
+	if err != nil {
+		return false, err
+	}
+	current, err := getSlice(s.enrollmentSetsFilename(enrollmentID))
+	if err != nil {
+		return false, fmt.Errorf("getting enrollment sets: %w", err)
+	}
+	add, remove, err := storage.LifecycleTransition(stages, current, name)
+	if err != nil {
+		return false, err
+	}
+	if err = s.assureEnrollmentDirExists(enrollmentID); err != nil {
+		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	for _, setName := range remove {
+		if _, err = setOrRemoveIn(s.enrollmentSetsFilename(enrollmentID), setName, false); err != nil {
+			return false, fmt.Errorf("removing set in enrollment file: %w", err)
+		}
+		if _, err = setOrRemoveIn(s.setEnrollmentsFilename(setName), enrollmentID, false); err != nil {
+			return false, fmt.Errorf("removing enrollment in set file: %w", err)
+		}
+	}
+	for _, setName := range add {
+		if _, err = setOrRemoveIn(s.enrollmentSetsFilename(enrollmentID), setName, true); err != nil {
+			return false, fmt.Errorf("setting set in enrollment file: %w", err)
+		}
+		if _, err = setOrRemoveIn(s.setEnrollmentsFilename(setName), enrollmentID, true); err != nil {
+			return false, fmt.Errorf("setting enrollment in set file: %w", err)
+		}
+	}
+	changed := len(add) > 0 || len(remove) > 0
+	if changed {
+		// update (all of) the enrollment ID DDM files
+		if err = s.writeEnrollmentDDM(enrollmentID); err != nil {
+			return false, fmt.Errorf("writing enrollment DDM: %w", err)
+		}
+	}
+	prev, err := getSlice(s.enrollmentLifecycleStageFilename(enrollmentID))
+	if err != nil {
+		return false, fmt.Errorf("getting enrollment lifecycle stage: %w", err)
+	}
+	if len(prev) < 1 || prev[0] != name {
+		changed = true
+		if err = putSlice(s.enrollmentLifecycleStageFilename(enrollmentID), []string{name}); err != nil {
+			return false, fmt.Errorf("writing enrollment lifecycle stage: %w", err)
+		}
+	}
+	return changed, nil
+}
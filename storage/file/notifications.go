@@ -0,0 +1,60 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// notificationFilename returns the path to the enrollment's last notification JSON file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) notificationFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "notified.json")
+}
+
+// RetrieveEnrollmentNotifications retrieves when enrollments were last notified.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentNotifications(_ context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentNotification, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string]*storage.EnrollmentNotification)
+	for _, enrollmentID := range enrollmentIDs {
+		b, err := os.ReadFile(s.notificationFilename(enrollmentID))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading enrollment notification: %w", err)
+		}
+		n := new(storage.EnrollmentNotification)
+		if err = json.Unmarshal(b, n); err != nil {
+			return nil, fmt.Errorf("unmarshal enrollment notification: %w", err)
+		}
+		ret[enrollmentID] = n
+	}
+	return ret, nil
+}
+
+// StoreEnrollmentNotifications stores when enrollments were last notified.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentNotifications(_ context.Context, notifications []*storage.EnrollmentNotification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, n := range notifications {
+		b, err := json.Marshal(n)
+		if err != nil {
+			return fmt.Errorf("marshal enrollment notification: %w", err)
+		}
+		if err = s.assureEnrollmentDirExists(n.EnrollmentID); err != nil {
+			return fmt.Errorf("assuring enrollment directory exists: %w", err)
+		}
+		if err = os.WriteFile(s.notificationFilename(n.EnrollmentID), b, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const notificationQueueFilename = "notification.queue.json"
+
+// readNotificationBatches reads the queued notification batches.
+// The caller must hold the lock.
+func (s *File) readNotificationBatches() ([]*storage.NotificationBatch, error) {
+	b, err := os.ReadFile(path.Join(s.path, notificationQueueFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading notification queue: %w", err)
+	}
+	var batches []*storage.NotificationBatch
+	if err = json.Unmarshal(b, &batches); err != nil {
+		return nil, fmt.Errorf("unmarshal notification queue: %w", err)
+	}
+	return batches, nil
+}
+
+// writeNotificationBatches writes the queued notification batches.
+// The caller must hold the lock.
+func (s *File) writeNotificationBatches(batches []*storage.NotificationBatch) error {
+	b, err := json.Marshal(batches)
+	if err != nil {
+		return fmt.Errorf("marshal notification queue: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, notificationQueueFilename), b, 0644)
+}
+
+// StoreNotificationBatch stores a queued notification batch.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreNotificationBatch(_ context.Context, batch *storage.NotificationBatch) error {
+	if err := batch.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batches, err := s.readNotificationBatches()
+	if err != nil {
+		return err
+	}
+	for i, b := range batches {
+		if b.ID == batch.ID {
+			batches[i] = batch
+			return s.writeNotificationBatches(batches)
+		}
+	}
+	return s.writeNotificationBatches(append(batches, batch))
+}
+
+// RetrieveNotificationBatches retrieves the queued notification batches.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveNotificationBatches(_ context.Context) ([]*storage.NotificationBatch, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readNotificationBatches()
+}
+
+// DeleteNotificationBatch deletes a queued notification batch.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteNotificationBatch(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batches, err := s.readNotificationBatches()
+	if err != nil {
+		return err
+	}
+	for i, b := range batches {
+		if b.ID == id {
+			return s.writeNotificationBatches(append(batches[:i], batches[i+1:]...))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const prefixOwner = "owner."
+
+// ownerFilename returns the path to the owner text file of the object of kind with id.
+func (s *File) ownerFilename(kind, id string) string {
+	return path.Join(s.path, prefixOwner+kind+"."+id+suffixTXT)
+}
+
+// StoreOwner records the owner of an object.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreOwner(_ context.Context, kind, id, principal string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.WriteFile(s.ownerFilename(kind, id), []byte(principal), 0644)
+}
+
+// RetrieveOwned retrieves the identifiers of the objects owned by a principal.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveOwned(_ context.Context, kind, principal string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries, err := os.ReadDir(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading owners: %w", err)
+	}
+	prefix := prefixOwner + kind + "."
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffixTXT) {
+			continue
+		}
+		b, err := os.ReadFile(path.Join(s.path, name))
+		if err != nil {
+			return nil, fmt.Errorf("reading owner: %w", err)
+		}
+		if string(b) == principal {
+			ids = append(ids, strings.TrimSuffix(strings.TrimPrefix(name, prefix), suffixTXT))
+		}
+	}
+	return ids, nil
+}
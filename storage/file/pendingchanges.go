@@ -0,0 +1,100 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const pendingChangesFilename = "pending.changes.json"
+
+// readPendingChanges reads the pending changes, oldest first.
+// The caller must hold the lock.
+func (s *File) readPendingChanges() ([]*storage.PendingChange, error) {
+	b, err := os.ReadFile(path.Join(s.path, pendingChangesFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading pending changes: %w", err)
+	}
+	var changes []*storage.PendingChange
+	if err = json.Unmarshal(b, &changes); err != nil {
+		return nil, fmt.Errorf("unmarshal pending changes: %w", err)
+	}
+	return changes, nil
+}
+
+// writePendingChanges writes the pending changes.
+// The caller must hold the lock.
+func (s *File) writePendingChanges(changes []*storage.PendingChange) error {
+	b, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("marshal pending changes: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, pendingChangesFilename), b, 0644)
+}
+
+// StorePendingChange stores change using its ID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StorePendingChange(_ context.Context, change *storage.PendingChange) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes, err := s.readPendingChanges()
+	if err != nil {
+		return err
+	}
+	for i, c := range changes {
+		if c.ID == change.ID {
+			changes[i] = change
+			return s.writePendingChanges(changes)
+		}
+	}
+	return s.writePendingChanges(append(changes, change))
+}
+
+// RetrievePendingChanges retrieves all pending changes.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrievePendingChanges(_ context.Context) ([]*storage.PendingChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readPendingChanges()
+}
+
+// RetrievePendingChange retrieves the pending change with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrievePendingChange(_ context.Context, id string) (*storage.PendingChange, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	changes, err := s.readPendingChanges()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return nil, storage.ErrPendingChangeNotFound
+}
+
+// DeletePendingChange deletes the pending change with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeletePendingChange(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes, err := s.readPendingChanges()
+	if err != nil {
+		return err
+	}
+	for i, c := range changes {
+		if c.ID == id {
+			return s.writePendingChanges(append(changes[:i], changes[i+1:]...))
+		}
+	}
+	return storage.ErrPendingChangeNotFound
+}
@@ -0,0 +1,40 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const (
+	prefixProfile = "profile."
+	suffixProfile = ".mobileconfig"
+)
+
+// profileFilename returns the path to the hosted profile.
+func (s *File) profileFilename(profileID string) string {
+	return path.Join(s.path, prefixProfile+profileID+suffixProfile)
+}
+
+// StoreProfile stores the raw configuration profile with profileID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreProfile(_ context.Context, profileID string, raw []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return os.WriteFile(s.profileFilename(profileID), raw, 0644)
+}
+
+// RetrieveProfile retrieves the raw configuration profile with profileID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveProfile(_ context.Context, profileID string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	raw, err := os.ReadFile(s.profileFilename(profileID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, storage.ErrProfileNotFound
+	}
+	return raw, err
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const statusQuarantineFilename = "status.quarantine.json"
+
+func (s *File) statusQuarantineFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, statusQuarantineFilename)
+}
+
+// readStatusQuarantine reads the quarantined status fragments of enrollmentID.
+func (s *File) readStatusQuarantine(enrollmentID string) ([]storage.QuarantinedStatus, error) {
+	b, err := os.ReadFile(s.statusQuarantineFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading status quarantine: %w", err)
+	}
+	var quarantined []storage.QuarantinedStatus
+	if err = json.Unmarshal(b, &quarantined); err != nil {
+		return nil, fmt.Errorf("unmarshal status quarantine: %w", err)
+	}
+	return quarantined, nil
+}
+
+// storeStatusQuarantine appends fragments to the quarantined status
+// fragments of enrollmentID keeping only the newest.
+func (s *File) storeStatusQuarantine(enrollmentID, statusID string, fragments []ddm.StatusFragment) error {
+	if len(fragments) < 1 {
+		return nil
+	}
+	quarantined, err := s.readStatusQuarantine(enrollmentID)
+	if err != nil {
+		return err
+	}
+	now := s.clock.Now()
+	for _, f := range fragments {
+		quarantined = append(quarantined, storage.QuarantinedStatus{
+			Path:      f.Path,
+			Error:     f.Error,
+			JSON:      f.JSON,
+			Timestamp: now,
+			StatusID:  statusID,
+		})
+	}
+	if len(quarantined) > storage.StatusQuarantineMax {
+		quarantined = quarantined[len(quarantined)-storage.StatusQuarantineMax:]
+	}
+	b, err := json.Marshal(quarantined)
+	if err != nil {
+		return fmt.Errorf("marshal status quarantine: %w", err)
+	}
+	if err = os.WriteFile(s.statusQuarantineFilename(enrollmentID), b, 0644); err != nil {
+		return fmt.Errorf("writing status quarantine: %w", err)
+	}
+	return nil
+}
+
+// RetrieveStatusQuarantine retrieves the quarantined status report fragments of enrollmentIDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveStatusQuarantine(_ context.Context, enrollmentIDs []string) (map[string][]storage.QuarantinedStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string][]storage.QuarantinedStatus)
+	for _, enrollmentID := range enrollmentIDs {
+		quarantined, err := s.readStatusQuarantine(enrollmentID)
+		if err != nil {
+			return nil, err
+		}
+		if len(quarantined) > 0 {
+			ret[enrollmentID] = quarantined
+		}
+	}
+	return ret, nil
+}
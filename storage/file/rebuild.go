@@ -0,0 +1,121 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const rebuildsFilename = "ddm.rebuilds.json"
+
+// fileRebuilds are the failed enrollment DDM rebuilds keyed by enrollment ID.
+type fileRebuilds map[string]*storage.DDMRebuild
+
+func (s *File) readRebuilds() (fileRebuilds, error) {
+	rebuilds := make(fileRebuilds)
+	b, err := os.ReadFile(path.Join(s.path, rebuildsFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return rebuilds, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading rebuilds: %w", err)
+	}
+	if err = json.Unmarshal(b, &rebuilds); err != nil {
+		return nil, fmt.Errorf("unmarshal rebuilds: %w", err)
+	}
+	return rebuilds, nil
+}
+
+func (s *File) writeRebuilds(rebuilds fileRebuilds) error {
+	if len(rebuilds) < 1 {
+		err := os.Remove(path.Join(s.path, rebuildsFilename))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	b, err := json.Marshal(rebuilds)
+	if err != nil {
+		return fmt.Errorf("marshal rebuilds: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, rebuildsFilename), b, 0644)
+}
+
+// writeEnrollmentsDDM writes the DDM files of each of enrollmentIDs.
+// A failure to write the DDM files of an enrollment is recorded as a
+// failed rebuild to be retried rather than leaving the remaining
+// enrollments stale. Previously failed rebuilds of enrollments that
+// are written are removed. The written enrollment IDs are returned.
+// The caller must hold the lock.
+func (s *File) writeEnrollmentsDDM(enrollmentIDs []string) ([]string, error) {
+	rebuilds, err := s.readRebuilds()
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	var changed bool
+	for _, id := range enrollmentIDs {
+		if err = s.writeEnrollmentDDM(id); err != nil {
+			r := rebuilds[id]
+			if r == nil {
+				r = &storage.DDMRebuild{EnrollmentID: id}
+				rebuilds[id] = r
+			}
+			r.Error = err.Error()
+			r.Attempts++
+			r.FailedAt = s.clock.Now().UTC()
+			changed = true
+			continue
+		}
+		written = append(written, id)
+		if _, ok := rebuilds[id]; ok {
+			delete(rebuilds, id)
+			changed = true
+		}
+	}
+	if changed {
+		if err = s.writeRebuilds(rebuilds); err != nil {
+			return written, fmt.Errorf("writing rebuilds: %w", err)
+		}
+	}
+	return written, nil
+}
+
+// RetrieveDDMRebuilds retrieves the failed enrollment DDM rebuilds.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDDMRebuilds(_ context.Context) ([]storage.DDMRebuild, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rebuilds, err := s.readRebuilds()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]storage.DDMRebuild, 0, len(rebuilds))
+	for _, r := range rebuilds {
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].EnrollmentID < ret[j].EnrollmentID })
+	return ret, nil
+}
+
+// RetryDDMRebuilds rewrites the DDM files of the failed enrollment DDM rebuilds.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetryDDMRebuilds(_ context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rebuilds, err := s.readRebuilds()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(rebuilds))
+	for id := range rebuilds {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return s.writeEnrollmentsDDM(ids)
+}
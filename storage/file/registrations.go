@@ -0,0 +1,66 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// registrationFilename returns the path to the enrollment's registration JSON file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) registrationFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "registration.json")
+}
+
+// RetrieveEnrollmentRegistration retrieves the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentRegistration(_ context.Context, enrollmentID string) (*storage.EnrollmentRegistration, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, err := os.ReadFile(s.registrationFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, storage.ErrEnrollmentRegistrationNotFound
+	} else if err != nil {
+		return nil, fmt.Errorf("reading enrollment registration: %w", err)
+	}
+	registration := new(storage.EnrollmentRegistration)
+	if err = json.Unmarshal(b, registration); err != nil {
+		return nil, fmt.Errorf("unmarshal enrollment registration: %w", err)
+	}
+	return registration, nil
+}
+
+// StoreEnrollmentRegistration stores the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentRegistration(_ context.Context, registration *storage.EnrollmentRegistration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(registration)
+	if err != nil {
+		return fmt.Errorf("marshal enrollment registration: %w", err)
+	}
+	// the enrollment directory makes the enrollment known
+	if err = s.assureEnrollmentDirExists(registration.EnrollmentID); err != nil {
+		return fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	return os.WriteFile(s.registrationFilename(registration.EnrollmentID), b, 0644)
+}
+
+// DeleteEnrollmentRegistration deletes the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteEnrollmentRegistration(_ context.Context, enrollmentID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.registrationFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("deleting enrollment registration: %w", err)
+	}
+	return true, nil
+}
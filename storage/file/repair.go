@@ -0,0 +1,112 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// fileMatches reports whether filename exists with contents b.
+func fileMatches(filename string, b []byte) (bool, error) {
+	have, err := readJSONFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return bytes.Equal(have, b), nil
+}
+
+// enrollmentDDMMismatches compares the derived DDM data on disk of
+// enrollmentID to e and returns the names of the mismatched data.
+func (s *File) enrollmentDDMMismatches(enrollmentID string, e *enrollmentDDM) ([]string, error) {
+	var mismatched []string
+	ok, err := fileMatches(s.declarationItemsFilename(enrollmentID), e.diJSON)
+	if err != nil {
+		return nil, fmt.Errorf("reading declaration-items: %w", err)
+	} else if !ok {
+		mismatched = append(mismatched, storage.DDMDeclarationItems)
+	}
+	if ok, err = fileMatches(s.tokensFilename(enrollmentID), e.tiJSON); err != nil {
+		return nil, fmt.Errorf("reading tokens: %w", err)
+	} else if !ok {
+		mismatched = append(mismatched, storage.DDMTokens)
+	}
+	matches, err := s.enrollmentDeclarationSymlinks(enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	ok = len(matches) == len(e.symlinks)
+	for _, symlinkName := range matches {
+		if !ok {
+			break
+		}
+		target, err := os.Readlink(symlinkName)
+		ok = err == nil && target == e.symlinks[symlinkName]
+	}
+	if !ok {
+		mismatched = append(mismatched, storage.DDMDeclarations)
+	}
+	return mismatched, nil
+}
+
+// RepairEnrollmentDDM recomputes the derived DDM data of all enrollments and reports mismatches.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RepairEnrollmentDDM(_ context.Context, dryRun bool) (*storage.DDMRepairReport, error) {
+	if dryRun {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	} else {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+	return s.repairEnrollmentDDM(dryRun)
+}
+
+// repairEnrollmentDDM recomputes the derived DDM data of all enrollments
+// and reports mismatches. The caller must hold the lock.
+func (s *File) repairEnrollmentDDM(dryRun bool) (*storage.DDMRepairReport, error) {
+	// each enrollment has a directory of its derived DDM data
+	enrollmentIDs, err := s.knownEnrollmentIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &storage.DDMRepairReport{Repaired: !dryRun}
+	for _, enrollmentID := range enrollmentIDs {
+		report.Enrollments++
+		e, err := s.buildEnrollmentDDM(enrollmentID)
+		if err != nil {
+			return nil, fmt.Errorf("building enrollment DDM for %s: %w", enrollmentID, err)
+		}
+		mismatched, err := s.enrollmentDDMMismatches(enrollmentID, e)
+		if err != nil {
+			return nil, fmt.Errorf("checking enrollment DDM for %s: %w", enrollmentID, err)
+		}
+		if len(mismatched) < 1 {
+			continue
+		}
+		report.Mismatches = append(report.Mismatches, storage.DDMMismatch{
+			EnrollmentID: enrollmentID,
+			Mismatched:   mismatched,
+		})
+		if !dryRun {
+			if err = s.writeEnrollmentDDM(enrollmentID); err != nil {
+				return nil, fmt.Errorf("writing enrollment DDM for %s: %w", enrollmentID, err)
+			}
+		}
+	}
+	return report, nil
+}
+
+// RefreshEnrollmentDDM rewrites the derived DDM data of enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RefreshEnrollmentDDM(_ context.Context, enrollmentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writeEnrollmentDDM(enrollmentID)
+}
@@ -0,0 +1,85 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const assignmentSchedulesFilename = "assignment.schedules.json"
+
+// readAssignmentSchedules reads the assignment schedules ordered by ID.
+// The caller must hold the lock.
+func (s *File) readAssignmentSchedules() ([]*storage.AssignmentSchedule, error) {
+	b, err := os.ReadFile(path.Join(s.path, assignmentSchedulesFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading assignment schedules: %w", err)
+	}
+	var schedules []*storage.AssignmentSchedule
+	if err = json.Unmarshal(b, &schedules); err != nil {
+		return nil, fmt.Errorf("unmarshal assignment schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+// writeAssignmentSchedules sorts and writes the assignment schedules.
+// The caller must hold the lock.
+func (s *File) writeAssignmentSchedules(schedules []*storage.AssignmentSchedule) error {
+	sort.Slice(schedules, func(i, j int) bool { return schedules[i].ID < schedules[j].ID })
+	b, err := json.Marshal(schedules)
+	if err != nil {
+		return fmt.Errorf("marshal assignment schedules: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, assignmentSchedulesFilename), b, 0644)
+}
+
+// StoreAssignmentSchedule stores schedule using its ID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreAssignmentSchedule(_ context.Context, schedule *storage.AssignmentSchedule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules, err := s.readAssignmentSchedules()
+	if err != nil {
+		return err
+	}
+	for i, a := range schedules {
+		if a.ID == schedule.ID {
+			schedules[i] = schedule
+			return s.writeAssignmentSchedules(schedules)
+		}
+	}
+	return s.writeAssignmentSchedules(append(schedules, schedule))
+}
+
+// RetrieveAssignmentSchedules retrieves all assignment schedules.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveAssignmentSchedules(_ context.Context) ([]*storage.AssignmentSchedule, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readAssignmentSchedules()
+}
+
+// DeleteAssignmentSchedule deletes the assignment schedule with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteAssignmentSchedule(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schedules, err := s.readAssignmentSchedules()
+	if err != nil {
+		return err
+	}
+	for i, a := range schedules {
+		if a.ID == id {
+			return s.writeAssignmentSchedules(append(schedules[:i], schedules[i+1:]...))
+		}
+	}
+	return storage.ErrAssignmentScheduleNotFound
+}
@@ -0,0 +1,94 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// servedHistoryFilename returns the path to the enrollment's served history JSON file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) servedHistoryFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "served.history.json")
+}
+
+// readServedHistory reads the served history of enrollmentID, most recent first.
+// The caller must hold the lock.
+func (s *File) readServedHistory(enrollmentID string) ([]storage.ServedDocument, error) {
+	b, err := os.ReadFile(s.servedHistoryFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var history []storage.ServedDocument
+	if err = json.Unmarshal(b, &history); err != nil {
+		return nil, fmt.Errorf("unmarshal served history: %w", err)
+	}
+	return history, nil
+}
+
+// StoreServedDocument records doc as served to enrollmentID if it changed.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreServedDocument(_ context.Context, enrollmentID string, doc *storage.ServedDocument, max int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	history, err := s.readServedHistory(enrollmentID)
+	if err != nil {
+		return false, fmt.Errorf("reading served history: %w", err)
+	}
+	for _, d := range history {
+		if d.Kind == doc.Kind {
+			if d.Hash == doc.Hash {
+				return false, nil
+			}
+			break
+		}
+	}
+	// prepend the document and keep at most max documents of its kind
+	trimmed := []storage.ServedDocument{*doc}
+	count := 1
+	for _, d := range history {
+		if d.Kind == doc.Kind {
+			if max > 0 && count >= max {
+				continue
+			}
+			count++
+		}
+		trimmed = append(trimmed, d)
+	}
+	b, err := json.Marshal(trimmed)
+	if err != nil {
+		return false, fmt.Errorf("marshal served history: %w", err)
+	}
+	if err = s.assureEnrollmentDirExists(enrollmentID); err != nil {
+		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	return true, os.WriteFile(s.servedHistoryFilename(enrollmentID), b, 0644)
+}
+
+// RetrieveServedHistory retrieves the documents served to enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveServedHistory(_ context.Context, enrollmentID, kind string) ([]storage.ServedDocument, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	history, err := s.readServedHistory(enrollmentID)
+	if err != nil {
+		return nil, fmt.Errorf("reading served history: %w", err)
+	}
+	if kind == "" {
+		return history, nil
+	}
+	var ret []storage.ServedDocument
+	for _, d := range history {
+		if d.Kind == kind {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
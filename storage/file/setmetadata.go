@@ -0,0 +1,67 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixSetMetadata = "set.metadata."
+
+// setMetadataFilename returns the path to the metadata JSON file of setName.
+func (s *File) setMetadataFilename(setName string) string {
+	return path.Join(s.path, prefixSetMetadata+setName+suffixJSON)
+}
+
+// StoreSetMetadata stores the metadata of a set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreSetMetadata(_ context.Context, metadata *storage.SetMetadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("marshal set metadata: %w", err)
+	}
+	return os.WriteFile(s.setMetadataFilename(metadata.SetName), b, 0644)
+}
+
+// RetrieveSetMetadata retrieves the metadata of sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetMetadata(_ context.Context, setNames []string) (map[string]*storage.SetMetadata, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string]*storage.SetMetadata)
+	for _, setName := range setNames {
+		b, err := os.ReadFile(s.setMetadataFilename(setName))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading set metadata: %w", err)
+		}
+		metadata := new(storage.SetMetadata)
+		if err = json.Unmarshal(b, metadata); err != nil {
+			return nil, fmt.Errorf("unmarshal set metadata: %w", err)
+		}
+		ret[setName] = metadata
+	}
+	return ret, nil
+}
+
+// DeleteSetMetadata deletes the metadata of a set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) DeleteSetMetadata(_ context.Context, setName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.setMetadataFilename(setName))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("deleting set metadata: %w", err)
+	}
+	return true, nil
+}
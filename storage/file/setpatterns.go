@@ -0,0 +1,173 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const setPatternsFilename = "set.patterns.json"
+
+// readSetPatterns reads the set patterns.
+// The caller must hold the lock.
+func (s *File) readSetPatterns() ([]storage.SetPattern, error) {
+	b, err := os.ReadFile(path.Join(s.path, setPatternsFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading set patterns: %w", err)
+	}
+	var patterns []storage.SetPattern
+	if err = json.Unmarshal(b, &patterns); err != nil {
+		return nil, fmt.Errorf("unmarshal set patterns: %w", err)
+	}
+	return patterns, nil
+}
+
+// writeSetPatterns sorts and writes the set patterns.
+// The caller must hold the lock.
+func (s *File) writeSetPatterns(patterns []storage.SetPattern) error {
+	sort.Slice(patterns, func(i, j int) bool {
+		a, b := patterns[i], patterns[j]
+		if a.Set != b.Set {
+			return a.Set < b.Set
+		} else if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Pattern < b.Pattern
+	})
+	b, err := json.Marshal(patterns)
+	if err != nil {
+		return fmt.Errorf("marshal set patterns: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, setPatternsFilename), b, 0644)
+}
+
+// compiledSetPatterns reads and compiles the set patterns.
+// The caller must hold the lock.
+func (s *File) compiledSetPatterns() (storage.SetPatterns, error) {
+	patterns, err := s.readSetPatterns()
+	if err != nil {
+		return nil, err
+	}
+	return storage.CompileSetPatterns(patterns)
+}
+
+// enrollmentSets returns the explicit sets of enrollmentID and the
+// sets with a pattern matching enrollmentID.
+// The caller must hold the lock.
+func (s *File) enrollmentSets(enrollmentID string) ([]string, error) {
+	sets, err := getSlice(s.enrollmentSetsFilename(enrollmentID))
+	if err != nil {
+		return nil, fmt.Errorf("getting sets for enrollment: %w", err)
+	}
+	patterns, err := s.compiledSetPatterns()
+	if err != nil {
+		return nil, err
+	}
+	for _, setName := range patterns.Sets(enrollmentID) {
+		if contains(sets, setName) < 0 {
+			sets = append(sets, setName)
+		}
+	}
+	return sets, nil
+}
+
+// setEnrollmentIDs returns the explicit enrollments of setName and the
+// known enrollments (those with DDM files) matching a pattern of setName.
+// The caller must hold the lock.
+func (s *File) setEnrollmentIDs(setName string) ([]string, error) {
+	ids, err := getSlice(s.setEnrollmentsFilename(setName))
+	if err != nil {
+		return nil, err
+	}
+	patterns, err := s.compiledSetPatterns()
+	if err != nil {
+		return nil, err
+	}
+	if !patterns.HasSet(setName) {
+		return ids, nil
+	}
+	// each known enrollment has a directory of its DDM files
+	known, err := s.knownEnrollmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range known {
+		if patterns.Matches(setName, id) && contains(ids, id) < 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// RetrieveSetPatterns retrieves the patterns of all sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetPatterns(_ context.Context) ([]storage.SetPattern, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readSetPatterns()
+}
+
+// StoreSetPattern adds a pattern to its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreSetPattern(_ context.Context, pattern *storage.SetPattern) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreSetPattern, nil, []string{pattern.Set}, nil); err != nil {
+		return false, err
+	}
+	patterns, err := s.readSetPatterns()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range patterns {
+		if p == *pattern {
+			return false, nil
+		}
+	}
+	if err = s.writeSetPatterns(append(patterns, *pattern)); err != nil {
+		return false, err
+	}
+	return true, s.writeSetDDM(pattern.Set)
+}
+
+// RemoveSetPattern removes a pattern from its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RemoveSetPattern(_ context.Context, pattern *storage.SetPattern) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalRemoveSetPattern, nil, []string{pattern.Set}, nil); err != nil {
+		return false, err
+	}
+	patterns, err := s.readSetPatterns()
+	if err != nil {
+		return false, err
+	}
+	// the enrollments matched before the removal need their DDM rewritten
+	ids, err := s.setEnrollmentIDs(pattern.Set)
+	if err != nil {
+		return false, err
+	}
+	for i, p := range patterns {
+		if p != *pattern {
+			continue
+		}
+		if err = s.writeSetPatterns(append(patterns[:i], patterns[i+1:]...)); err != nil {
+			return false, err
+		}
+		for _, id := range ids {
+			if err = s.writeEnrollmentDDM(id); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
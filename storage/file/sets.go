@@ -2,10 +2,14 @@ package file
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // RetrieveSetDeclarations returns a slice of declaration IDs that are associated with setName.
@@ -19,10 +23,19 @@ func (s *File) RetrieveSetDeclarations(_ context.Context, setName string) ([]str
 func (s *File) StoreSetDeclaration(_ context.Context, setName, declarationID string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreSetDeclaration, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
 	_, err := os.Stat(s.declarationFilename(declarationID))
-	if err != nil {
+	if errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("%w: %v", storage.ErrDeclarationNotFound, err)
+	} else if err != nil {
 		return false, fmt.Errorf("checking declaration: %w", err)
 	}
+	before, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return false, fmt.Errorf("reading set file: %w", err)
+	}
 	// set the forward reference
 	changed, err := setOrRemoveIn(s.setFilename(setName), declarationID, true)
 	if err != nil {
@@ -39,6 +52,10 @@ func (s *File) StoreSetDeclaration(_ context.Context, setName, declarationID str
 		if err = s.writeSetDDM(setName); err != nil {
 			return false, fmt.Errorf("writing set DDM: %w", err)
 		}
+
+		if err = s.recordSetSnapshot(setName, before); err != nil {
+			return false, fmt.Errorf("recording set snapshot: %w", err)
+		}
 	}
 	return changed, nil
 }
@@ -47,6 +64,13 @@ func (s *File) StoreSetDeclaration(_ context.Context, setName, declarationID str
 func (s *File) RemoveSetDeclaration(_ context.Context, setName, declarationID string) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalRemoveSetDeclaration, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
+	before, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return false, fmt.Errorf("reading set file: %w", err)
+	}
 	// set the forward reference
 	changed, err := setOrRemoveIn(s.setFilename(setName), declarationID, false)
 	if err != nil {
@@ -59,10 +83,23 @@ func (s *File) RemoveSetDeclaration(_ context.Context, setName, declarationID st
 			return false, fmt.Errorf("removing set in declaration file: %w", err)
 		}
 
+		// conditions do not outlive the association
+		after, err := getSlice(s.setFilename(setName))
+		if err != nil {
+			return false, fmt.Errorf("reading set file: %w", err)
+		}
+		if err = s.pruneSetConditions(setName, after); err != nil {
+			return false, fmt.Errorf("pruning set conditions: %w", err)
+		}
+
 		// update (all of) the enrollment ID DDM files
 		if err = s.writeSetDDM(setName); err != nil {
 			return false, fmt.Errorf("writing set DDM: %w", err)
 		}
+
+		if err = s.recordSetSnapshot(setName, before); err != nil {
+			return false, fmt.Errorf("recording set snapshot: %w", err)
+		}
 	}
 	return changed, nil
 }
@@ -84,6 +121,18 @@ func (s *File) RetrieveSets(_ context.Context) ([]string, error) {
 	return truncated, nil
 }
 
+// RetrieveSetsPage retrieves a page of the sorted list of sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	sets, err := s.RetrieveSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// the file names sort differently than the set names
+	sort.Strings(sets)
+	return storage.PageStrings(sets, after, limit), nil
+}
+
 // RetrieveDeclarationSets returns the list of sets associated with a declaration.
 // See also the storage package for documentation on the storage interfaces.
 func (s *File) RetrieveDeclarationSets(_ context.Context, declarationID string) ([]string, error) {
@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const prefixSetSnapshots = "set.snapshots."
+
+// setSnapshotsFilename returns the path to the set snapshots JSON file.
+func (s *File) setSnapshotsFilename(setName string) string {
+	return path.Join(s.path, prefixSetSnapshots+setName+suffixJSON)
+}
+
+// readSetSnapshots reads the snapshots of setName ordered from oldest to newest.
+func (s *File) readSetSnapshots(setName string) ([]storage.SetSnapshot, error) {
+	var snapshots []storage.SetSnapshot
+	b, err := os.ReadFile(s.setSnapshotsFilename(setName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return snapshots, json.Unmarshal(b, &snapshots)
+}
+
+// recordSetSnapshot records the current declarations of setName.
+// If the set has no snapshots yet then the declarations before the
+// change (if any) are recorded first so that the change can be undone.
+func (s *File) recordSetSnapshot(setName string, before []string) error {
+	snapshots, err := s.readSetSnapshots(setName)
+	if err != nil {
+		return fmt.Errorf("reading set snapshots: %w", err)
+	}
+	current, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return fmt.Errorf("reading set: %w", err)
+	}
+	if current == nil {
+		// encode as an empty JSON array
+		current = []string{}
+	}
+	var nextID int
+	if len(snapshots) > 0 {
+		if nextID, err = strconv.Atoi(snapshots[len(snapshots)-1].ID); err != nil {
+			return fmt.Errorf("parsing snapshot ID: %w", err)
+		}
+	} else if len(before) > 0 {
+		snapshots = append(snapshots, storage.SetSnapshot{
+			ID:           "1",
+			Timestamp:    s.clock.Now(),
+			Declarations: before,
+		})
+		nextID = 1
+	}
+	snapshots = append(snapshots, storage.SetSnapshot{
+		ID:           strconv.Itoa(nextID + 1),
+		Timestamp:    s.clock.Now(),
+		Declarations: current,
+	})
+	if len(snapshots) > storage.MaxSetSnapshots {
+		snapshots = snapshots[len(snapshots)-storage.MaxSetSnapshots:]
+	}
+	b, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.setSnapshotsFilename(setName), b, 0644)
+}
+
+// RetrieveSetSnapshots retrieves the snapshots of setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetSnapshots(_ context.Context, setName string) ([]storage.SetSnapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshots, err := s.readSetSnapshots(setName)
+	if err != nil {
+		return nil, err
+	}
+	// newest first
+	for i, j := 0, len(snapshots)-1; i < j; i, j = i+1, j-1 {
+		snapshots[i], snapshots[j] = snapshots[j], snapshots[i]
+	}
+	return snapshots, nil
+}
+
+// RestoreSetSnapshot replaces the declarations of setName with those of a snapshot.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RestoreSetSnapshot(_ context.Context, setName, snapshotID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalRestoreSetSnapshot, nil, []string{setName}, nil); err != nil {
+		return false, err
+	}
+	snapshots, err := s.readSetSnapshots(setName)
+	if err != nil {
+		return false, fmt.Errorf("reading set snapshots: %w", err)
+	}
+	var snapshot *storage.SetSnapshot
+	for i := range snapshots {
+		if snapshots[i].ID == snapshotID {
+			snapshot = &snapshots[i]
+			break
+		}
+	}
+	if snapshot == nil {
+		return false, storage.ErrSetSnapshotNotFound
+	}
+	current, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return false, fmt.Errorf("reading set: %w", err)
+	}
+
+	// check all declarations exist before changing anything
+	for _, declarationID := range snapshot.Declarations {
+		if _, err = os.Stat(s.declarationFilename(declarationID)); err != nil {
+			return false, fmt.Errorf("checking declaration: %w", err)
+		}
+	}
+
+	var changed bool
+	for _, declarationID := range current {
+		if contains(snapshot.Declarations, declarationID) >= 0 {
+			continue
+		}
+		if _, err = setOrRemoveIn(s.declarationSetsFilename(declarationID), setName, false); err != nil {
+			return false, fmt.Errorf("removing set in declaration file: %w", err)
+		}
+		changed = true
+	}
+	for _, declarationID := range snapshot.Declarations {
+		if contains(current, declarationID) >= 0 {
+			continue
+		}
+		if _, err = setOrRemoveIn(s.declarationSetsFilename(declarationID), setName, true); err != nil {
+			return false, fmt.Errorf("setting set in declaration file: %w", err)
+		}
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	if err = putSlice(s.setFilename(setName), snapshot.Declarations); err != nil {
+		return false, fmt.Errorf("writing set file: %w", err)
+	}
+	if err = s.pruneSetConditions(setName, snapshot.Declarations); err != nil {
+		return false, fmt.Errorf("pruning set conditions: %w", err)
+	}
+
+	// update (all of) the enrollment ID DDM files once
+	if err = s.writeSetDDM(setName); err != nil {
+		return false, fmt.Errorf("writing set DDM: %w", err)
+	}
+
+	if err = s.recordSetSnapshot(setName, current); err != nil {
+		return true, fmt.Errorf("recording set snapshot: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,118 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// enrollmentStagedSetsFilename returns the path to the enrollment ID-to-staged set mapping file.
+// Note it is contained within the enrollment ID directory.
+func (s *File) enrollmentStagedSetsFilename(enrollmentID string) string {
+	return path.Join(s.path, enrollmentID, "staged-sets.txt")
+}
+
+// RetrieveEnrollmentStagedSets retrieves the staged sets of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveEnrollmentStagedSets(_ context.Context, enrollmentID string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return getSlice(s.enrollmentStagedSetsFilename(enrollmentID))
+}
+
+// StoreEnrollmentStagedSet stages a set for an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreEnrollmentStagedSet(_ context.Context, enrollmentID, setName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalStoreEnrollmentStagedSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	if err := s.assureEnrollmentDirExists(enrollmentID); err != nil {
+		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	return setOrRemoveIn(s.enrollmentStagedSetsFilename(enrollmentID), setName, true)
+}
+
+// RemoveEnrollmentStagedSet removes a staged set of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RemoveEnrollmentStagedSet(_ context.Context, enrollmentID, setName string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalRemoveEnrollmentStagedSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	return setOrRemoveIn(s.enrollmentStagedSetsFilename(enrollmentID), setName, false)
+}
+
+// putOrRemoveSlice writes slice to filename or removes filename if slice is empty.
+func putOrRemoveSlice(filename string, slice []string) error {
+	if len(slice) > 0 {
+		return putSlice(filename, slice)
+	}
+	err := os.Remove(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SwapEnrollmentStagedSets exchanges the sets and staged sets of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) SwapEnrollmentStagedSets(_ context.Context, enrollmentID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.appendJournal(storage.JournalSwapEnrollmentStagedSets, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	current, err := getSlice(s.enrollmentSetsFilename(enrollmentID))
+	if err != nil {
+		return false, fmt.Errorf("getting enrollment sets: %w", err)
+	}
+	staged, err := getSlice(s.enrollmentStagedSetsFilename(enrollmentID))
+	if err != nil {
+		return false, fmt.Errorf("getting enrollment staged sets: %w", err)
+	}
+	var changed bool
+	for _, setName := range current {
+		if contains(staged, setName) >= 0 {
+			continue
+		}
+		changed = true
+		if _, err = setOrRemoveIn(s.setEnrollmentsFilename(setName), enrollmentID, false); err != nil {
+			return false, fmt.Errorf("removing enrollment in set file: %w", err)
+		}
+	}
+	for _, setName := range staged {
+		if contains(current, setName) >= 0 {
+			continue
+		}
+		changed = true
+		if _, err = setOrRemoveIn(s.setEnrollmentsFilename(setName), enrollmentID, true); err != nil {
+			return false, fmt.Errorf("setting enrollment in set file: %w", err)
+		}
+	}
+	if len(current) < 1 && len(staged) < 1 {
+		return false, nil
+	}
+	if err = s.assureEnrollmentDirExists(enrollmentID); err != nil {
+		return false, fmt.Errorf("assuring enrollment directory exists: %w", err)
+	}
+	if err = putOrRemoveSlice(s.enrollmentSetsFilename(enrollmentID), staged); err != nil {
+		return false, fmt.Errorf("writing enrollment sets: %w", err)
+	}
+	if err = putOrRemoveSlice(s.enrollmentStagedSetsFilename(enrollmentID), current); err != nil {
+		return false, fmt.Errorf("writing enrollment staged sets: %w", err)
+	}
+	if changed {
+		// update (all of) the enrollment ID DDM files
+		if err = s.writeEnrollmentDDM(enrollmentID); err != nil {
+			return false, fmt.Errorf("writing enrollment DDM: %w", err)
+		}
+	}
+	return changed, nil
+}
@@ -12,6 +12,7 @@ import (
 	"io/fs"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,7 +26,12 @@ func (s *File) storeStatusDeclarations(enrollmentID string, declarations []ddm.D
 		return nil
 	}
 
-	now := time.Now()
+	previous, err := s.readStatusDeclarations(enrollmentID)
+	if err != nil {
+		return fmt.Errorf("reading declaration status: %w", err)
+	}
+
+	now := s.clock.Now()
 	nowText, err := now.MarshalText()
 	if err != nil {
 		return fmt.Errorf("marshal time to text: %w", err)
@@ -56,7 +62,7 @@ func (s *File) storeStatusDeclarations(enrollmentID string, declarations []ddm.D
 		return fmt.Errorf("writing records: %w", err)
 	}
 
-	return nil
+	return s.updateStatusIndex(enrollmentID, previous, declarations)
 }
 
 func (s *File) readStatusValues(enrollmentID string) ([]ddm.StatusValue, error) {
@@ -108,21 +114,26 @@ func containsValue(values []ddm.StatusValue, v ddm.StatusValue) int {
 func mergeStatusValues(dst, src []ddm.StatusValue) (ret []ddm.StatusValue) {
 	ret = append([]ddm.StatusValue{}, dst...)
 	for _, srcValue := range src {
-		if containsValue(ret, srcValue) < 0 {
-			ret = append(ret, srcValue)
+		if i := containsValue(ret, srcValue); i >= 0 {
+			// move re-reported values to the end so that the
+			// values stay in the order they were last reported
+			ret = append(ret[:i], ret[i+1:]...)
 		}
+		ret = append(ret, srcValue)
 	}
 	return
 }
 
-func (s *File) storeStatusValues(enrollmentID string, values []ddm.StatusValue) error {
+// storeStatusValues merges values into the values of enrollmentID.
+// The number of the oldest values dropped by the limits is returned.
+func (s *File) storeStatusValues(enrollmentID string, values []ddm.StatusValue) (int, error) {
 	if len(values) < 1 {
-		return nil
+		return 0, nil
 	}
 
 	curValues, err := s.readStatusValues(enrollmentID)
 	if err != nil {
-		return fmt.Errorf("reading values: %w", err)
+		return 0, fmt.Errorf("reading values: %w", err)
 	}
 
 	// merge in the new values
@@ -130,12 +141,16 @@ func (s *File) storeStatusValues(enrollmentID string, values []ddm.StatusValue)
 
 	if len(values) < 1 {
 		// nothing to save
-		return nil
+		return 0, nil
 	}
 
+	// merged values are ordered oldest first
+	dropped := storage.Excess(len(values), s.limits.MaxValues())
+	values = values[dropped:]
+
 	csvFile, err := os.OpenFile(s.csvFilename(csvFilenameValues, enrollmentID), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
 	if err != nil {
-		return fmt.Errorf("opening declaration CSV: %w", err)
+		return 0, fmt.Errorf("opening declaration CSV: %w", err)
 	}
 	defer csvFile.Close()
 	writer := csv.NewWriter(csvFile)
@@ -151,10 +166,10 @@ func (s *File) storeStatusValues(enrollmentID string, values []ddm.StatusValue)
 	}
 
 	if err = writer.WriteAll(records); err != nil {
-		return fmt.Errorf("writing records: %w", err)
+		return 0, fmt.Errorf("writing records: %w", err)
 	}
 
-	return nil
+	return dropped, nil
 }
 
 const (
@@ -171,39 +186,99 @@ func (s *File) errorsCSVFilename(enrollmentID string) string {
 	return s.csvFilename(csvFilenameErrors, enrollmentID)
 }
 
-func (s *File) storeStatusErrors(enrollmentID string, ddmErrors []ddm.StatusError) error {
+// readCSVRecords reads all of the records of the CSV file filename.
+// No records are returned if the file does not exist.
+func readCSVRecords(filename string) ([][]string, error) {
+	csvFile, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
+	return csv.NewReader(csvFile).ReadAll()
+}
+
+// storeStatusErrors stores ddmErrors to the errors of enrollmentID.
+// Errors already stored (with the same path and error) are moved to
+// the end with their last seen time and count updated.
+// The number of the oldest errors dropped by the limits is returned.
+func (s *File) storeStatusErrors(enrollmentID string, ddmErrors []ddm.StatusError) (int, error) {
 	if len(ddmErrors) < 1 {
-		return nil
+		return 0, nil
 	}
 
-	csvFile, err := os.OpenFile(s.errorsCSVFilename(enrollmentID), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	now := s.clock.Now()
+	nowText, err := now.MarshalText()
 	if err != nil {
-		return fmt.Errorf("opening error CSV: %w", err)
+		return 0, fmt.Errorf("marshal time to text: %w", err)
 	}
-	defer csvFile.Close()
-	writer := csv.NewWriter(csvFile)
 
-	now := time.Now()
-	nowText, err := now.MarshalText()
+	existing, err := readCSVRecords(s.errorsCSVFilename(enrollmentID))
 	if err != nil {
-		return fmt.Errorf("marshal time to text: %w", err)
+		return 0, fmt.Errorf("reading error CSV: %w", err)
+	}
+
+	// the existing errors by path and base64 JSON
+	seen := make(map[string][]string)
+	for _, record := range existing {
+		if len(record) == 3 {
+			// records without a first seen time and count were seen once
+			record = append(record, record[0], "1")
+		}
+		if len(record) != 5 {
+			return 0, fmt.Errorf("record fields: %d", len(record))
+		}
+		seen[record[1]+"\x00"+record[2]] = record
 	}
 
 	var records [][]string
+	moved := make(map[string]bool)
 	for _, ddmError := range ddmErrors {
-		records = append(records, []string{
-			string(nowText),
-			ddmError.Path,
-			base64.StdEncoding.EncodeToString(ddmError.ErrorJSON),
-		})
+		errorB64 := base64.StdEncoding.EncodeToString(ddmError.ErrorJSON)
+		key := ddmError.Path + "\x00" + errorB64
+		record, ok := seen[key]
+		if !ok {
+			record = []string{"", ddmError.Path, errorB64, string(nowText), "0"}
+			seen[key] = record
+		}
+		count, _ := strconv.Atoi(record[4])
+		record[0] = string(nowText)
+		record[4] = strconv.Itoa(count + 1)
+		if !moved[key] {
+			moved[key] = true
+			records = append(records, record)
+		}
+	}
 
+	// keep the order of the errors not seen again
+	var kept [][]string
+	for _, record := range existing {
+		if !moved[record[1]+"\x00"+record[2]] {
+			kept = append(kept, seen[record[1]+"\x00"+record[2]])
+		}
 	}
+	records = append(kept, records...)
+
+	var dropped int
+	if max := s.limits.MaxErrors(); max > 0 {
+		// keep only the newest errors
+		dropped = storage.Excess(len(records), max)
+		records = records[dropped:]
+	}
+
+	csvFile, err := os.OpenFile(s.errorsCSVFilename(enrollmentID), os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("opening error CSV: %w", err)
+	}
+	defer csvFile.Close()
+	writer := csv.NewWriter(csvFile)
 
 	if err = writer.WriteAll(records); err != nil {
-		return fmt.Errorf("writing records: %w", err)
+		return 0, fmt.Errorf("writing records: %w", err)
 	}
 
-	return nil
+	return dropped, nil
 }
 
 // StoreDeclarationStatus stores a status report from enrollmentID.
@@ -216,154 +291,220 @@ func (s *File) StoreDeclarationStatus(_ context.Context, enrollmentID string, st
 		return fmt.Errorf("assuring enrollment directory exists: %w", err)
 	}
 
+	s.limits.TruncateReport(status)
+
 	// save a copy of the last complete status report, independent of our status updates.
-	if err = os.WriteFile(path.Join(s.path, enrollmentID, "status.last.json"), status.Raw, 0644); err != nil {
+	if err = s.writeJSONFile(path.Join(s.path, enrollmentID, "status.last.json"), status.Raw); err != nil {
 		return fmt.Errorf("writing last status: %w", err)
 	}
 
+	// the parsed sections are stored independently so that a failure
+	// of one quarantines it rather than failing the status report.
 	if err = s.storeStatusDeclarations(enrollmentID, status.Declarations); err != nil {
-		return fmt.Errorf("storing declaration status: %w", err)
+		storage.QuarantineStatusSection(status, ddm.StatusPathDeclarations, status.Declarations, fmt.Errorf("storing declaration status: %w", err))
+	}
+
+	dropped, err := s.storeStatusValues(enrollmentID, status.Values)
+	status.DroppedValues += dropped
+	if err != nil {
+		storage.QuarantineStatusSection(status, ddm.StatusPathItems, status.Values, fmt.Errorf("storing status values: %w", err))
+	} else if len(status.Values) > 0 {
+		// declaration conditions may match differently with new values
+		conditional, err := s.enrollmentHasConditions(enrollmentID)
+		if err != nil {
+			return err
+		}
+		if conditional {
+			if err = s.writeEnrollmentDDM(enrollmentID); err != nil {
+				return fmt.Errorf("writing enrollment DDM: %w", err)
+			}
+		}
 	}
 
-	if err = s.storeStatusValues(enrollmentID, status.Values); err != nil {
-		return fmt.Errorf("storing status values: %w", err)
+	dropped, err = s.storeStatusErrors(enrollmentID, status.Errors)
+	status.DroppedErrors += dropped
+	if err != nil {
+		storage.QuarantineStatusSection(status, ddm.StatusPathErrors, status.Errors, fmt.Errorf("storing status errors: %w", err))
+	}
+
+	historyValues := s.history.Values(status)
+	if err = s.storeStatusValueHistory(enrollmentID, status.ID, historyValues); err != nil {
+		storage.QuarantineStatusSection(status, ddm.StatusPathItems, historyValues, fmt.Errorf("storing status value history: %w", err))
 	}
 
-	if err = s.storeStatusErrors(enrollmentID, status.Errors); err != nil {
-		return fmt.Errorf("storing status errors: %w", err)
+	if err = s.storeStatusQuarantine(enrollmentID, status.ID, status.Quarantined); err != nil {
+		return fmt.Errorf("storing status quarantine: %w", err)
 	}
 
 	return nil
 }
 
-// RetrieveDeclarationStatus retrieves the current status of declarations for the enrollment IDs.
-// See also the storage package for documentation on the storage interfaces.
-func (s *File) RetrieveDeclarationStatus(_ context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	ret := make(map[string][]ddm.DeclarationQueryStatus)
-	for _, enrollmentID := range enrollmentIDs {
-		di := new(ddm.DeclarationItems)
+// readStatusDeclarations reads the last reported declaration status for enrollmentID.
+// The Current field of the returned statuses is not populated.
+func (s *File) readStatusDeclarations(enrollmentID string) ([]ddm.DeclarationQueryStatus, error) {
+	csvFile, err := os.Open(s.csvFilename(csvFilenameDeclarations, enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		// no declaration status for this enrollment (yet)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer csvFile.Close()
+	reader := csv.NewReader(csvFile)
 
-		f, err := os.Open(s.declarationItemsFilename(enrollmentID))
-		if errors.Is(err, os.ErrNotExist) {
-			// no declaration items for this enrollment (yet)
-			continue
+	var ret []ddm.DeclarationQueryStatus
+	for {
+		// read a record
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
 		} else if err != nil {
-			return nil, fmt.Errorf("opening declaration items: %w", err)
+			return nil, fmt.Errorf("reading CSV record: %w", err)
 		}
-		defer f.Close()
 
-		if err = json.NewDecoder(f).Decode(di); err != nil {
-			return nil, fmt.Errorf("decoding declaration items json: %w", err)
+		// record is a set length
+		if len(record) != 7 {
+			return nil, fmt.Errorf("record fields: %d", len(record))
 		}
 
-		if di == nil || (len(di.Declarations.Activations) < 1 && len(di.Declarations.Assets) < 1 && len(di.Declarations.Configurations) < 1 && len(di.Declarations.Management) < 1) {
-			// no declarations or empty response; move on.
-			continue
+		// attempt to decode the b64 JSON
+		jsonBytes, err := base64.StdEncoding.DecodeString(record[6])
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64: %w", err)
 		}
-
-		// deconstruct the declaration items into a slice of configured declarations
-		var manifestDeclarations []ddm.ManifestDeclaration
-		manifestDeclarations = append(manifestDeclarations, di.Declarations.Activations...)
-		manifestDeclarations = append(manifestDeclarations, di.Declarations.Assets...)
-		manifestDeclarations = append(manifestDeclarations, di.Declarations.Configurations...)
-		manifestDeclarations = append(manifestDeclarations, di.Declarations.Management...)
-
-		// generate the "placeholder" output for all of our declaration items
-		manifestMap := make(map[string]ddm.DeclarationQueryStatus)
-		for _, manifestDeclaration := range manifestDeclarations {
-			manifestMap[manifestDeclaration.Identifier] = ddm.DeclarationQueryStatus{
-				DeclarationStatus: ddm.DeclarationStatus{
-					Identifier:  manifestDeclaration.Identifier,
-					ServerToken: manifestDeclaration.ServerToken,
-				},
+		var ddmError interface{}
+		if len(jsonBytes) > 0 {
+			if err = json.Unmarshal(jsonBytes, &ddmError); err != nil {
+				return nil, fmt.Errorf("unmarshal reason json: %w", err)
 			}
 		}
 
-		csvFile, err := os.Open(s.csvFilename(csvFilenameDeclarations, enrollmentID))
-		if err != nil && !errors.Is(err, os.ErrNotExist) {
-			return nil, err
+		// decode the timestamp
+		var ts time.Time
+		if err = ts.UnmarshalText([]byte(record[0])); err != nil {
+			return nil, fmt.Errorf("unmarshal time: %w", err)
 		}
-		defer csvFile.Close()
-		reader := csv.NewReader(csvFile)
 
-		for {
-			// read a record
-			record, err := reader.Read()
-			if errors.Is(err, io.EOF) {
-				break
-			} else if err != nil {
-				return nil, fmt.Errorf("reading CSV record: %w", err)
-			}
+		active, err := strconv.ParseBool(record[2])
+		if err != nil {
+			return nil, fmt.Errorf("parse bool: %w", err)
+		}
 
-			// record is a set length
-			if len(record) != 7 {
-				return nil, fmt.Errorf("record fields: %d", len(record))
-			}
+		ret = append(ret, ddm.DeclarationQueryStatus{
+			DeclarationStatus: ddm.DeclarationStatus{
+				Identifier:   record[1],
+				Active:       active,
+				Valid:        record[3],
+				ServerToken:  record[4],
+				ManifestType: record[5],
+			},
+			Reasons:        ddmError,
+			StatusReceived: ts,
+		})
+	}
+	return ret, nil
+}
 
-			// attempt to decode the b64 JSON
-			jsonBytes, err := base64.StdEncoding.DecodeString(record[6])
-			if err != nil {
-				return nil, fmt.Errorf("decoding base64: %w", err)
-			}
-			var ddmError interface{}
-			if len(jsonBytes) > 0 {
-				if err = json.Unmarshal(jsonBytes, &ddmError); err != nil {
-					return nil, fmt.Errorf("unmarshal reason json: %w", err)
-				}
-			}
+// readDeclarationItems reads the declaration items of enrollmentID.
+// A nil DeclarationItems is returned if there are none (yet).
+func (s *File) readDeclarationItems(enrollmentID string) (*ddm.DeclarationItems, error) {
+	b, err := readJSONFile(s.declarationItemsFilename(enrollmentID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading declaration items: %w", err)
+	}
+	di := new(ddm.DeclarationItems)
+	if err = json.Unmarshal(b, di); err != nil {
+		return nil, fmt.Errorf("decoding declaration items json: %w", err)
+	}
+	return di, nil
+}
 
-			// decode the timestamp
-			var ts time.Time
-			if err = ts.UnmarshalText([]byte(record[0])); err != nil {
-				return nil, fmt.Errorf("unmarshal time: %w", err)
-			}
+// RetrieveDeclarationStatus retrieves the status of declarations for the enrollment IDs.
+// Reported statuses of declarations no longer assigned to an enrollment are marked unassigned.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDeclarationStatus(_ context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string][]ddm.DeclarationQueryStatus)
+	for _, enrollmentID := range enrollmentIDs {
+		di, err := s.readDeclarationItems(enrollmentID)
+		if err != nil {
+			return nil, err
+		}
 
-			active, err := strconv.ParseBool(record[2])
-			if err != nil {
-				return nil, fmt.Errorf("parse bool: %w", err)
+		// generate the "placeholder" output for all of our declaration items
+		manifestMap := make(map[string]ddm.DeclarationQueryStatus)
+		if di != nil {
+			// deconstruct the declaration items into a slice of configured declarations
+			var manifestDeclarations []ddm.ManifestDeclaration
+			manifestDeclarations = append(manifestDeclarations, di.Declarations.Activations...)
+			manifestDeclarations = append(manifestDeclarations, di.Declarations.Assets...)
+			manifestDeclarations = append(manifestDeclarations, di.Declarations.Configurations...)
+			manifestDeclarations = append(manifestDeclarations, di.Declarations.Management...)
+
+			for _, manifestDeclaration := range manifestDeclarations {
+				manifestMap[manifestDeclaration.Identifier] = ddm.DeclarationQueryStatus{
+					DeclarationStatus: ddm.DeclarationStatus{
+						Identifier:  manifestDeclaration.Identifier,
+						ServerToken: manifestDeclaration.ServerToken,
+					},
+				}
 			}
+		}
 
-			_, ok := manifestMap[record[1]]
-			if !ok {
-				// we only want to report on those declarations that are configured
-				// i.e. set in our declartion-items
-				continue
-			}
+		statuses, err := s.readStatusDeclarations(enrollmentID)
+		if err != nil {
+			return nil, err
+		}
 
-			// replace placeholder with a "full" declaration query status
-			manifestMap[record[1]] = ddm.DeclarationQueryStatus{
-				DeclarationStatus: ddm.DeclarationStatus{
-					Identifier:   record[1],
-					Active:       active,
-					Valid:        record[3],
-					ServerToken:  record[4],
-					ManifestType: record[5],
-				},
-				Reasons:        ddmError,
-				StatusReceived: ts,
-				Current:        record[4] == manifestMap[record[1]].ServerToken,
+		for _, status := range statuses {
+			if placeholder, ok := manifestMap[status.Identifier]; ok {
+				// replace placeholder with a "full" declaration query status
+				status.Current = status.ServerToken == placeholder.ServerToken
+			} else {
+				// the enrollment still reports a declaration that is
+				// not set in our declaration-items
+				status.Unassigned = true
 			}
+			manifestMap[status.Identifier] = status
 		}
-		// turn back into a list
-		ddmStatuses := make([]ddm.DeclarationQueryStatus, 0, len(manifestMap))
-		for k := range manifestMap {
-			ddmStatuses = append(ddmStatuses, manifestMap[k])
+		if len(manifestMap) < 1 {
+			// no declarations or statuses; move on.
+			continue
 		}
-		ret[enrollmentID] = ddmStatuses
+		ret[enrollmentID] = queryStatusesFromMap(manifestMap)
 	}
 	return ret, nil
 }
 
+// RetrievePendingRemovals retrieves the unassigned declarations last reported as active for the enrollment IDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	statuses, err := s.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PendingRemovals(statuses), nil
+}
+
+// queryStatusesFromMap turns the map of declaration query statuses back into a list.
+func queryStatusesFromMap(m map[string]ddm.DeclarationQueryStatus) []ddm.DeclarationQueryStatus {
+	ddmStatuses := make([]ddm.DeclarationQueryStatus, 0, len(m))
+	for k := range m {
+		ddmStatuses = append(ddmStatuses, m[k])
+	}
+	sort.Slice(ddmStatuses, func(i, j int) bool { return ddmStatuses[i].Identifier < ddmStatuses[j].Identifier })
+	return ddmStatuses
+}
+
 // RetrieveStatusErrors reads DDM errors from CSV file.
 // See also the storage package for documentation on the storage interfaces.
-func (s *File) RetrieveStatusErrors(_ context.Context, enrollmentIDs []string, offset, limit int) (map[string][]storage.StatusError, error) {
+func (s *File) RetrieveStatusErrors(_ context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]storage.StatusError, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	// TODO: implement offset/limit?
-
 	ret := make(map[string][]storage.StatusError)
 	for _, enrollmentID := range enrollmentIDs {
 		csvFile, err := os.Open(s.errorsCSVFilename(enrollmentID))
@@ -387,8 +528,10 @@ func (s *File) RetrieveStatusErrors(_ context.Context, enrollmentIDs []string, o
 				return nil, fmt.Errorf("reading CSV record: %w", err)
 			}
 
-			// must be 3 columns wide
-			if len(record) != 3 {
+			// must be 3 columns wide (or 5 with the first seen time and count)
+			if len(record) == 3 {
+				record = append(record, record[0], "1")
+			} else if len(record) != 5 {
 				return nil, fmt.Errorf("record fields: %d", len(record))
 			}
 
@@ -407,14 +550,36 @@ func (s *File) RetrieveStatusErrors(_ context.Context, enrollmentIDs []string, o
 			if err = ts.UnmarshalText([]byte(record[0])); err != nil {
 				return nil, fmt.Errorf("unmarshal time: %w", err)
 			}
+			if (!since.IsZero() && ts.Before(since)) || (!until.IsZero() && ts.After(until)) {
+				continue
+			}
+
+			var firstSeen time.Time
+			if err = firstSeen.UnmarshalText([]byte(record[3])); err != nil {
+				return nil, fmt.Errorf("unmarshal first seen time: %w", err)
+			}
+			count, err := strconv.Atoi(record[4])
+			if err != nil {
+				return nil, fmt.Errorf("parsing count: %w", err)
+			}
 
 			// assemble and append the record
 			ddmErrors = append(ddmErrors, storage.StatusError{
 				Path:      record[1],
 				Error:     ddmError,
 				Timestamp: ts,
+				FirstSeen: firstSeen,
+				Count:     count,
 			})
 		}
+		if offset < len(ddmErrors) {
+			ddmErrors = ddmErrors[offset:]
+		} else {
+			ddmErrors = nil
+		}
+		if len(ddmErrors) > limit {
+			ddmErrors = ddmErrors[:limit]
+		}
 		ret[enrollmentID] = ddmErrors
 	}
 
@@ -459,6 +624,7 @@ func (s *File) RetrieveStatusValues(_ context.Context, enrollmentIDs []string, p
 		for _, v := range values {
 			sValues = append(sValues, storage.StatusValue{
 				Path:  v.Path,
+				Type:  v.ValueType,
 				Value: string(v.Value),
 			})
 		}
@@ -485,8 +651,10 @@ func (s *File) RetrieveStatusReport(ctx context.Context, q storage.StatusReportQ
 	}
 	report := new(storage.StoredStatusReport)
 	statusFilename := path.Join(s.path, q.EnrollmentID, "status.last.json")
-	report.Raw, err = os.ReadFile(statusFilename)
-	if err == nil {
+	report.Raw, err = readJSONFile(statusFilename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, storage.ErrStatusReportNotFound
+	} else if err == nil {
 		var fi fs.FileInfo
 		fi, err = os.Stat(statusFilename)
 		if fi != nil {
@@ -495,3 +663,47 @@ func (s *File) RetrieveStatusReport(ctx context.Context, q storage.StatusReportQ
 	}
 	return report, err
 }
+
+// RetrieveSetStatusSummary summarizes the reported status of the declarations in setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveSetStatusSummary(_ context.Context, setName string) ([]storage.DeclarationStatusSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	declarationIDs, err := getSlice(s.setFilename(setName))
+	if err != nil {
+		return nil, fmt.Errorf("getting set declarations: %w", err)
+	}
+	enrollmentIDs, err := getSlice(s.setEnrollmentsFilename(setName))
+	if err != nil {
+		return nil, fmt.Errorf("getting set enrollments: %w", err)
+	}
+	summaryMap := make(map[string]*storage.DeclarationStatusSummary, len(declarationIDs))
+	ret := make([]storage.DeclarationStatusSummary, len(declarationIDs))
+	for i, declarationID := range declarationIDs {
+		ret[i] = storage.DeclarationStatusSummary{
+			Identifier:  declarationID,
+			Enrollments: len(enrollmentIDs),
+			Unknown:     len(enrollmentIDs),
+		}
+		summaryMap[declarationID] = &ret[i]
+	}
+	for _, enrollmentID := range enrollmentIDs {
+		statuses, err := s.readStatusDeclarations(enrollmentID)
+		if err != nil {
+			return nil, fmt.Errorf("reading status for %s: %w", enrollmentID, err)
+		}
+		for _, status := range statuses {
+			summary, ok := summaryMap[status.Identifier]
+			if !ok {
+				continue
+			}
+			summary.Unknown--
+			if status.Active && status.Valid == "valid" {
+				summary.ActiveValid++
+			} else {
+				summary.Failing++
+			}
+		}
+	}
+	return ret, nil
+}
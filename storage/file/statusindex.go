@@ -0,0 +1,113 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// statusIndexEntry is the status an enrollment last reported for a declaration.
+type statusIndexEntry struct {
+	Active bool   `json:"active"`
+	Valid  string `json:"valid"`
+}
+
+// statusIndexFilename returns the path to the declaration status index
+// JSON file of declarationID. It maps enrollment IDs to their last
+// reported status of the declaration.
+func (s *File) statusIndexFilename(declarationID string) string {
+	return path.Join(s.path, "status.index."+declarationID+suffixJSON)
+}
+
+func (s *File) readStatusIndex(declarationID string) (map[string]statusIndexEntry, error) {
+	index := make(map[string]statusIndexEntry)
+	b, err := os.ReadFile(s.statusIndexFilename(declarationID))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading declaration status index: %w", err)
+	}
+	if err = json.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("unmarshal declaration status index: %w", err)
+	}
+	return index, nil
+}
+
+// updateStatusIndex updates the declaration status indexes with the
+// declaration status of enrollmentID changing from previous to current.
+func (s *File) updateStatusIndex(enrollmentID string, previous []ddm.DeclarationQueryStatus, current []ddm.DeclarationStatus) error {
+	changes := make(map[string]*statusIndexEntry)
+	for _, status := range previous {
+		// removed unless reported again
+		changes[status.Identifier] = nil
+	}
+	for _, status := range current {
+		changes[status.Identifier] = &statusIndexEntry{Active: status.Active, Valid: status.Valid}
+	}
+	for _, status := range previous {
+		if entry := changes[status.Identifier]; entry != nil && entry.Active == status.Active && entry.Valid == status.Valid {
+			// unchanged
+			delete(changes, status.Identifier)
+		}
+	}
+	for declarationID, entry := range changes {
+		if storage.ValidateIdentifier("declaration identifier", declarationID) != nil {
+			// reported identifiers that are unsafe to index
+			continue
+		}
+		index, err := s.readStatusIndex(declarationID)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			delete(index, enrollmentID)
+		} else {
+			index[enrollmentID] = *entry
+		}
+		if len(index) < 1 {
+			err = os.Remove(s.statusIndexFilename(declarationID))
+			if errors.Is(err, os.ErrNotExist) {
+				err = nil
+			}
+		} else {
+			var b []byte
+			if b, err = json.Marshal(index); err != nil {
+				return fmt.Errorf("marshal declaration status index: %w", err)
+			}
+			err = os.WriteFile(s.statusIndexFilename(declarationID), b, 0644)
+		}
+		if err != nil {
+			return fmt.Errorf("writing declaration status index: %w", err)
+		}
+	}
+	return nil
+}
+
+// RetrieveDeclarationStatusEnrollments retrieves enrollments by their reported status of a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveDeclarationStatusEnrollments(_ context.Context, q *storage.DeclarationStatusQuery) ([]string, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	index, err := s.readStatusIndex(q.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	var ret []string
+	for enrollmentID, entry := range index {
+		if (q.Valid == "" || entry.Valid == q.Valid) && (q.Active == nil || entry.Active == *q.Active) {
+			ret = append(ret, enrollmentID)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
@@ -0,0 +1,108 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const usageFilename = "usage.json"
+
+// fileUsage is usage keyed by day and then set name.
+type fileUsage map[string]map[string]*storage.UsageRecord
+
+// readUsage reads the usage. The caller must hold the lock.
+func (s *File) readUsage() (fileUsage, error) {
+	usage := make(fileUsage)
+	b, err := os.ReadFile(path.Join(s.path, usageFilename))
+	if errors.Is(err, os.ErrNotExist) {
+		return usage, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading usage: %w", err)
+	}
+	if err = json.Unmarshal(b, &usage); err != nil {
+		return nil, fmt.Errorf("unmarshal usage: %w", err)
+	}
+	return usage, nil
+}
+
+// updateUsage calls fn with the usage of each of setNames on day and writes
+// the updated usage. The caller must hold the lock.
+func (s *File) updateUsage(day string, setNames []string, fn func(*storage.UsageRecord)) error {
+	usage, err := s.readUsage()
+	if err != nil {
+		return err
+	}
+	if usage[day] == nil {
+		usage[day] = make(map[string]*storage.UsageRecord)
+	}
+	for _, setName := range setNames {
+		if usage[day][setName] == nil {
+			usage[day][setName] = &storage.UsageRecord{Day: day, Set: setName}
+		}
+		fn(usage[day][setName])
+	}
+	b, err := json.Marshal(usage)
+	if err != nil {
+		return fmt.Errorf("marshal usage: %w", err)
+	}
+	return os.WriteFile(path.Join(s.path, usageFilename), b, 0644)
+}
+
+// IncrementUsage adds to the usage of setNames on day.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) IncrementUsage(_ context.Context, day string, setNames []string, syncs, statusReports int64) error {
+	if len(setNames) < 1 {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUsage(day, setNames, func(u *storage.UsageRecord) {
+		u.Syncs += syncs
+		u.StatusReports += statusReports
+	})
+}
+
+// StoreUsageDeclarations sets the number of declarations in the usage of setName on day.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) StoreUsageDeclarations(_ context.Context, day, setName string, declarations int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.updateUsage(day, []string{setName}, func(u *storage.UsageRecord) {
+		u.Declarations = declarations
+	})
+}
+
+// RetrieveUsage retrieves the usage of the days from through to.
+// See also the storage package for documentation on the storage interfaces.
+func (s *File) RetrieveUsage(_ context.Context, from, to string) ([]storage.UsageRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	usage, err := s.readUsage()
+	if err != nil {
+		return nil, err
+	}
+	var records []storage.UsageRecord
+	for day, sets := range usage {
+		// days sort lexically
+		if day < from || day > to {
+			continue
+		}
+		for _, u := range sets {
+			records = append(records, *u)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Day != records[j].Day {
+			return records[i].Day < records[j].Day
+		}
+		return records[i].Set < records[j].Set
+	})
+	return records, nil
+}
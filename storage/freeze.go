@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// EnrollmentFreeze is the DDM served to a frozen enrollment. While an
+// enrollment is frozen it is served these documents regardless of
+// changes to its sets or their declarations.
+type EnrollmentFreeze struct {
+	EnrollmentID string    `json:"enrollment_id"`
+	Timestamp    time.Time `json:"timestamp"`
+
+	TokensJSON           json.RawMessage `json:"tokens"`
+	DeclarationItemsJSON json.RawMessage `json:"declaration_items"`
+
+	// Declarations are the declarations of the declaration items keyed by identifier.
+	Declarations map[string]json.RawMessage `json:"declarations"`
+}
+
+// FrozenEnrollmentsRetriever retrieves which enrollments are frozen.
+type FrozenEnrollmentsRetriever interface {
+	// RetrieveFrozenEnrollmentIDs retrieves those of enrollmentIDs that are frozen.
+	RetrieveFrozenEnrollmentIDs(ctx context.Context, enrollmentIDs []string) ([]string, error)
+}
+
+// EnrollmentFreezeRetriever retrieves the frozen DDM of enrollments.
+type EnrollmentFreezeRetriever interface {
+	// RetrieveEnrollmentFreeze retrieves the freeze of enrollmentID.
+	// If the enrollment is not frozen then a nil freeze and nil error
+	// should be returned.
+	RetrieveEnrollmentFreeze(ctx context.Context, enrollmentID string) (*EnrollmentFreeze, error)
+}
+
+// EnrollmentFreezeStorage stores the frozen DDM of enrollments.
+type EnrollmentFreezeStorage interface {
+	FrozenEnrollmentsRetriever
+	EnrollmentFreezeRetriever
+
+	// StoreEnrollmentFreeze freezes the enrollment of freeze.
+	// Any existing freeze of the enrollment is replaced.
+	StoreEnrollmentFreeze(ctx context.Context, freeze *EnrollmentFreeze) error
+
+	// DeleteEnrollmentFreeze unfreezes enrollmentID.
+	// Returns true if the enrollment was frozen.
+	DeleteEnrollmentFreeze(ctx context.Context, enrollmentID string) (bool, error)
+}
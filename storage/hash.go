@@ -0,0 +1,19 @@
+package storage
+
+import "context"
+
+// DefaultHashAlgorithm names the hash algorithm that tokens are
+// assumed to have been computed with if no algorithm was recorded.
+const DefaultHashAlgorithm = "xxhash"
+
+// HashMigrator migrates stored tokens between hash algorithms.
+// Tokens computed with different hash algorithms should not be mixed
+// as devices compare them to decide what to fetch.
+type HashMigrator interface {
+	// MigrateHash recomputes the stored tokens with the configured
+	// hash if algorithm differs from the name of the hash algorithm
+	// the tokens were last computed with and then records algorithm.
+	// If no algorithm was recorded DefaultHashAlgorithm is assumed.
+	// Returns true if tokens were recomputed.
+	MigrateHash(ctx context.Context, algorithm string) (bool, error)
+}
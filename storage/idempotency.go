@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// IdempotentResponse is a recorded response to a request that was made
+// with an idempotency key.
+type IdempotentResponse struct {
+	// Fingerprint uniquely identifies the request that generated the response.
+	Fingerprint string `json:"fingerprint"`
+
+	StatusCode  int       `json:"status_code"`
+	ContentType string    `json:"content_type,omitempty"`
+	Body        []byte    `json:"body,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+type IdempotencyStorage interface {
+	// RetrieveIdempotentResponse retrieves the recorded response for key.
+	// If no response has been recorded then a nil response and nil
+	// error should be returned.
+	RetrieveIdempotentResponse(ctx context.Context, key string) (*IdempotentResponse, error)
+
+	// StoreIdempotentResponse records resp for key.
+	// Any previously recorded response for key should be overwritten.
+	StoreIdempotentResponse(ctx context.Context, key string, resp *IdempotentResponse) error
+
+	// DeleteIdempotentResponses deletes the responses recorded before
+	// before (e.g. because they expired).
+	DeleteIdempotentResponses(ctx context.Context, before time.Time) error
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxIdentifierLength is the maximum length in bytes of declaration
+// identifiers, set names, and enrollment IDs.
+const MaxIdentifierLength = 255
+
+// HashedEnrollmentIDPrefix prefixes the hashed enrollment IDs that the
+// idhash package stores records under. Storage backends that enumerate
+// known enrollments skip the enrollment IDs with this prefix.
+const HashedEnrollmentIDPrefix = "~idhash."
+
+// identifierChar reports whether c is valid in an identifier.
+func identifierChar(c byte) bool {
+	switch {
+	case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		return true
+	}
+	switch c {
+	case '.', '-', '_', ':', '@', '+', '=', '~':
+		return true
+	}
+	return false
+}
+
+// ValidateIdentifier checks id for use as a declaration identifier,
+// set name, or enrollment ID (named by kind in errors). As these are
+// embedded in storage keys and file paths they must be non-empty, at
+// most MaxIdentifierLength bytes, not start with a period, and only
+// contain ASCII letters, digits, and the characters ".-_:@+=~".
+// Errors are categorized as ErrInvalid.
+func ValidateIdentifier(kind, id string) error {
+	if id == "" {
+		return Categorize(ErrInvalid, fmt.Errorf("empty %s", kind))
+	} else if len(id) > MaxIdentifierLength {
+		return Categorize(ErrInvalid, fmt.Errorf("%s longer than %d bytes", kind, MaxIdentifierLength))
+	} else if id[0] == '.' {
+		return Categorize(ErrInvalid, fmt.Errorf("%s starts with a period: %q", kind, id))
+	}
+	for i := 0; i < len(id); i++ {
+		if !identifierChar(id[i]) {
+			return Categorize(ErrInvalid, fmt.Errorf("invalid character in %s: %q", kind, id))
+		}
+	}
+	return nil
+}
+
+// UserChannelSeparator separates the device and user parts of user
+// channel enrollment IDs (e.g. "<device>:<user>" as used by NanoMDM).
+const UserChannelSeparator = ":"
+
+// DeviceEnrollmentID maps enrollmentID to the enrollment ID of its
+// device channel. User channel enrollment IDs map to the device part
+// before UserChannelSeparator; other enrollment IDs map to themselves.
+func DeviceEnrollmentID(enrollmentID string) string {
+	if i := strings.Index(enrollmentID, UserChannelSeparator); i > 0 {
+		return enrollmentID[:i]
+	}
+	return enrollmentID
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Journal operations. Each names the storage method that was called.
+const (
+	JournalStoreDeclaration                   = "StoreDeclaration"
+	JournalDeleteDeclaration                  = "DeleteDeclaration"
+	JournalTouchDeclaration                   = "TouchDeclaration"
+	JournalStoreSetDeclaration                = "StoreSetDeclaration"
+	JournalRemoveSetDeclaration               = "RemoveSetDeclaration"
+	JournalStoreSetDeclarationCondition       = "StoreSetDeclarationCondition"
+	JournalRestoreSetSnapshot                 = "RestoreSetSnapshot"
+	JournalStoreSetPattern                    = "StoreSetPattern"
+	JournalRemoveSetPattern                   = "RemoveSetPattern"
+	JournalStoreEnrollmentSet                 = "StoreEnrollmentSet"
+	JournalRemoveEnrollmentSet                = "RemoveEnrollmentSet"
+	JournalStoreEnrollmentFreeze              = "StoreEnrollmentFreeze"
+	JournalDeleteEnrollmentFreeze             = "DeleteEnrollmentFreeze"
+	JournalStoreEnrollmentStagedSet           = "StoreEnrollmentStagedSet"
+	JournalRemoveEnrollmentStagedSet          = "RemoveEnrollmentStagedSet"
+	JournalSwapEnrollmentStagedSets           = "SwapEnrollmentStagedSets"
+	JournalTransitionEnrollmentLifecycleStage = "TransitionEnrollmentLifecycleStage"
+)
+
+// JournalEntry records a single declaration, set, or enrollment mutation.
+// Entries are written ahead of the mutation they record: an entry
+// exists even if the mutation then failed or changed nothing.
+// Consumers should treat an entry as a hint to re-read the current
+// state of the declarations, sets, and enrollments it names.
+type JournalEntry struct {
+	// Seq is the sequence number of the entry. Sequence numbers
+	// increase with each entry but may have gaps.
+	Seq       int64     `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Op        string    `json:"op"`
+
+	Declarations []string `json:"declarations,omitempty"`
+	Sets         []string `json:"sets,omitempty"`
+	Enrollments  []string `json:"enrollments,omitempty"`
+}
+
+// JournalRetriever retrieves the journal of storage mutations.
+type JournalRetriever interface {
+	// RetrieveJournal retrieves at most limit entries with sequence
+	// numbers greater than after ordered by sequence number.
+	RetrieveJournal(ctx context.Context, after int64, limit int) ([]JournalEntry, error)
+}
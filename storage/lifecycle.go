@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrLifecycleStageNotFound is returned when a lifecycle stage does not exist.
+var ErrLifecycleStageNotFound = Categorize(ErrNotFound, errors.New("lifecycle stage not found"))
+
+// LifecycleStage maps a device lifecycle stage (for example
+// "provisioning", "production", or "offboarding") to a bundle of sets.
+// An enrollment transitioned to a stage is made a member of exactly the
+// sets of that stage out of all of the sets of all stages. Sets not in
+// any stage are unaffected by transitions.
+type LifecycleStage struct {
+	Name string   `json:"name"`
+	Sets []string `json:"sets"`
+}
+
+// Validate checks the lifecycle stage for errors.
+func (s *LifecycleStage) Validate() error {
+	return Categorize(ErrInvalid, s.validate())
+}
+
+func (s *LifecycleStage) validate() error {
+	if s == nil {
+		return errors.New("nil lifecycle stage")
+	}
+	if err := ValidateIdentifier("lifecycle stage", s.Name); err != nil {
+		return err
+	}
+	for _, setName := range s.Sets {
+		if err := ValidateIdentifier("set name", setName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LifecycleTransition computes the sets to add to and remove from an
+// enrollment with the current sets to transition it to the stage named
+// to. ErrLifecycleStageNotFound is returned if to is not in stages.
+// The returned sets are sorted.
+func LifecycleTransition(stages []LifecycleStage, current []string, to string) (add []string, remove []string, err error) {
+	var target *LifecycleStage
+	for i := range stages {
+		if stages[i].Name == to {
+			target = &stages[i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, nil, fmt.Errorf("%w: %s", ErrLifecycleStageNotFound, to)
+	}
+	for _, setName := range target.Sets {
+		if !contains(current, setName) && !contains(add, setName) {
+			add = append(add, setName)
+		}
+	}
+	for _, stage := range stages {
+		for _, setName := range stage.Sets {
+			if contains(current, setName) && !contains(target.Sets, setName) && !contains(remove, setName) {
+				remove = append(remove, setName)
+			}
+		}
+	}
+	sort.Strings(add)
+	sort.Strings(remove)
+	return add, remove, nil
+}
+
+// LifecycleStageStorage stores lifecycle stages and transitions
+// enrollments between them.
+type LifecycleStageStorage interface {
+	// RetrieveLifecycleStages retrieves all lifecycle stages ordered by name.
+	RetrieveLifecycleStages(ctx context.Context) ([]LifecycleStage, error)
+
+	// StoreLifecycleStage stores stage, replacing any stage of the same name.
+	// Enrollments already in the stage are not changed until they are
+	// next transitioned. Returns true if the stage changed.
+	StoreLifecycleStage(ctx context.Context, stage *LifecycleStage) (bool, error)
+
+	// DeleteLifecycleStage deletes the lifecycle stage named name.
+	// Returns true if the stage existed.
+	DeleteLifecycleStage(ctx context.Context, name string) (bool, error)
+
+	// RetrieveEnrollmentLifecycleStage retrieves the name of the lifecycle
+	// stage of enrollmentID. An empty name is returned if the enrollment
+	// has never been transitioned.
+	RetrieveEnrollmentLifecycleStage(ctx context.Context, enrollmentID string) (string, error)
+
+	// TransitionEnrollmentLifecycleStage atomically transitions
+	// enrollmentID to the lifecycle stage named name and changes its
+	// sets accordingly. See also LifecycleTransition. Transitioning to
+	// the current stage re-applies its sets.
+	// ErrLifecycleStageNotFound is returned if the stage does not exist.
+	// Returns true if the stage or sets of the enrollment changed.
+	TransitionEnrollmentLifecycleStage(ctx context.Context, enrollmentID, name string) (bool, error)
+}
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreEnrollmentAnnotation stores the annotation of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentAnnotation(ctx context.Context, annotation *storage.EnrollmentAnnotation) error {
+	assetTagsJSON, err := json.Marshal(annotation.AssetTags)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO enrollment_annotations
+    (enrollment_id, notes, owner, contact, asset_tags, annotated_at)
+VALUES
+    (?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    notes = new.notes,
+    owner = new.owner,
+    contact = new.contact,
+    asset_tags = new.asset_tags,
+    annotated_at = new.annotated_at;`,
+		annotation.EnrollmentID,
+		annotation.Notes,
+		annotation.Owner,
+		annotation.Contact,
+		assetTagsJSON,
+		annotation.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// RetrieveEnrollmentAnnotations retrieves the annotations of enrollments.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentAnnotations(ctx context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentAnnotation, error) {
+	ret := make(map[string]*storage.EnrollmentAnnotation)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveEnrollmentAnnotations(ctx, chunk, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (s *MySQLStorage) retrieveEnrollmentAnnotations(ctx context.Context, enrollmentIDs []string, ret map[string]*storage.EnrollmentAnnotation) error {
+	inSQL, args := inIDs("enrollment_id", enrollmentIDs)
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    enrollment_id,
+    notes,
+    owner,
+    contact,
+    asset_tags,
+    annotated_at
+FROM
+    enrollment_annotations
+WHERE
+    `+inSQL+`;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		a := new(storage.EnrollmentAnnotation)
+		var assetTagsJSON []byte
+		var annotatedAt string
+		if err = rows.Scan(&a.EnrollmentID, &a.Notes, &a.Owner, &a.Contact, &assetTagsJSON, &annotatedAt); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(assetTagsJSON, &a.AssetTags); err != nil {
+			return err
+		}
+		if a.Timestamp, err = time.Parse(mysqlTimeFormat, annotatedAt); err != nil {
+			return err
+		}
+		ret[a.EnrollmentID] = a
+	}
+	return rows.Err()
+}
+
+// DeleteEnrollmentAnnotation deletes the annotation of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteEnrollmentAnnotation(ctx context.Context, enrollmentID string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM enrollment_annotations WHERE enrollment_id = ?;`,
+		enrollmentID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
@@ -0,0 +1,168 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveComplianceRequirements retrieves the compliance requirements of all sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveComplianceRequirements(ctx context.Context) ([]storage.ComplianceRequirement, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT set_name, declarations, conditions FROM compliance_requirements ORDER BY set_name;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var requirements []storage.ComplianceRequirement
+	for rows.Next() {
+		var r storage.ComplianceRequirement
+		var declarationsJSON, conditionsJSON []byte
+		if err = rows.Scan(&r.Set, &declarationsJSON, &conditionsJSON); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(declarationsJSON, &r.Declarations); err != nil {
+			return nil, fmt.Errorf("unmarshal declarations of compliance requirement %s: %w", r.Set, err)
+		}
+		if err = json.Unmarshal(conditionsJSON, &r.Conditions); err != nil {
+			return nil, fmt.Errorf("unmarshal conditions of compliance requirement %s: %w", r.Set, err)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, rows.Err()
+}
+
+// StoreComplianceRequirement stores the compliance requirement of its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreComplianceRequirement(ctx context.Context, requirement *storage.ComplianceRequirement) (bool, error) {
+	declarationsJSON, err := json.Marshal(requirement.Declarations)
+	if err != nil {
+		return false, err
+	}
+	conditionsJSON, err := json.Marshal(requirement.Conditions)
+	if err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO compliance_requirements
+    (set_name, declarations, conditions)
+VALUES
+    (?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    declarations = new.declarations,
+    conditions = new.conditions;`,
+		requirement.Set,
+		declarationsJSON,
+		conditionsJSON,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// DeleteComplianceRequirement deletes the compliance requirement of setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteComplianceRequirement(ctx context.Context, setName string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM compliance_requirements WHERE set_name = ?;`,
+		setName,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// StoreEnrollmentCompliance stores the compliance state of its enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentCompliance(ctx context.Context, compliance *storage.EnrollmentCompliance) error {
+	failuresJSON, err := json.Marshal(compliance.Failures)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO enrollment_compliance
+    (enrollment_id, compliant, met, total, score, failures, evaluated_at)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    compliant = new.compliant,
+    met = new.met,
+    total = new.total,
+    score = new.score,
+    failures = new.failures,
+    evaluated_at = new.evaluated_at;`,
+		compliance.EnrollmentID,
+		compliance.Compliant,
+		compliance.Met,
+		compliance.Total,
+		compliance.Score,
+		failuresJSON,
+		compliance.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// retrieveEnrollmentCompliance retrieves the compliance state of enrollmentIDs (or all if empty).
+func (s *MySQLStorage) retrieveEnrollmentCompliance(ctx context.Context, enrollmentIDs []string) ([]*storage.EnrollmentCompliance, error) {
+	cond, args := inIDs("enrollment_id", enrollmentIDs)
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT enrollment_id, compliant, met, total, score, failures, evaluated_at FROM enrollment_compliance WHERE `+cond+`;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []*storage.EnrollmentCompliance
+	for rows.Next() {
+		c := new(storage.EnrollmentCompliance)
+		var failuresJSON []byte
+		var evaluatedAt string
+		if err = rows.Scan(&c.EnrollmentID, &c.Compliant, &c.Met, &c.Total, &c.Score, &failuresJSON, &evaluatedAt); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(failuresJSON, &c.Failures); err != nil {
+			return nil, fmt.Errorf("unmarshal compliance failures of %s: %w", c.EnrollmentID, err)
+		}
+		if c.Timestamp, err = time.Parse(mysqlTimeFormat, evaluatedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, c)
+	}
+	return ret, rows.Err()
+}
+
+// RetrieveEnrollmentCompliance retrieves the compliance state of enrollments.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentCompliance(ctx context.Context, enrollmentIDs []string) ([]*storage.EnrollmentCompliance, error) {
+	chunks := storage.ChunkStrings(enrollmentIDs, maxInParams)
+	if len(chunks) < 1 {
+		// an empty chunk retrieves all enrollments
+		chunks = [][]string{nil}
+	}
+	var ret []*storage.EnrollmentCompliance
+	for _, chunk := range chunks {
+		compliance, err := s.retrieveEnrollmentCompliance(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, compliance...)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].EnrollmentID < ret[j].EnrollmentID })
+	return ret, nil
+}
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// nullCondition assembles a declaration condition from nullable columns.
+// Nil is returned for unconditional associations.
+func nullCondition(path, op, value sql.NullString) *storage.DeclarationCondition {
+	if !path.Valid {
+		return nil
+	}
+	return &storage.DeclarationCondition{
+		Path:              path.String,
+		StatusValueFilter: storage.StatusValueFilter{Op: op.String, Value: value.String},
+	}
+}
+
+// StoreSetDeclarationCondition sets the condition of the association between setName and declarationID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreSetDeclarationCondition(ctx context.Context, setName, declarationID string, cond *storage.DeclarationCondition) (bool, error) {
+	var path, op, value sql.NullString
+	if cond != nil {
+		if err := cond.Validate(); err != nil {
+			return false, err
+		}
+		path = sql.NullString{String: cond.Path, Valid: true}
+		op = sql.NullString{String: cond.Op, Valid: true}
+		value = sql.NullString{String: cond.Value, Valid: true}
+	}
+	if err := s.appendJournal(ctx, storage.JournalStoreSetDeclarationCondition, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+UPDATE set_declarations
+SET
+    condition_path = ?,
+    condition_op = ?,
+    condition_value = ?
+WHERE
+    set_name = ? AND
+    declaration_identifier = ?;`,
+		path,
+		op,
+		value,
+		setName,
+		declarationID,
+	)
+	if err != nil {
+		return false, err
+	}
+	changed, err := resultChangedRows(result)
+	if err != nil || changed {
+		return changed, err
+	}
+	// unchanged rows are not counted so check the association exists
+	var exists bool
+	err = s.db.QueryRowContext(
+		ctx,
+		`SELECT EXISTS(SELECT 1 FROM set_declarations WHERE set_name = ? AND declaration_identifier = ?);`,
+		setName,
+		declarationID,
+	).Scan(&exists)
+	if err == nil && !exists {
+		err = storage.ErrSetDeclarationNotFound
+	}
+	return false, err
+}
+
+// RetrieveSetDeclarationConditions retrieves the conditions of the declarations in setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetDeclarationConditions(ctx context.Context, setName string) (map[string]*storage.DeclarationCondition, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    declaration_identifier,
+    condition_path,
+    condition_op,
+    condition_value
+FROM
+    set_declarations
+WHERE
+    set_name = ? AND
+    condition_path IS NOT NULL;`,
+		setName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make(map[string]*storage.DeclarationCondition)
+	for rows.Next() {
+		var declarationID string
+		var path, op, value sql.NullString
+		if err = rows.Scan(&declarationID, &path, &op, &value); err != nil {
+			break
+		}
+		ret[declarationID] = nullCondition(path, op, value)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return ret, err
+}
+
+// servedDeclarations evaluates the conditions of the declarations of
+// enrollmentID against its status values. The status values are only
+// retrieved if there are any conditions.
+func (s *MySQLStorage) servedDeclarations(ctx context.Context, enrollmentID string, conds map[string][]*storage.DeclarationCondition) (map[string]bool, error) {
+	var values []storage.StatusValue
+conditions:
+	for _, cs := range conds {
+		for _, c := range cs {
+			if c != nil {
+				enrollmentValues, err := s.RetrieveStatusValues(ctx, []string{enrollmentID}, "")
+				if err != nil {
+					return nil, err
+				}
+				values = enrollmentValues[enrollmentID]
+				break conditions
+			}
+		}
+	}
+	return storage.ServedDeclarations(conds, values), nil
+}
@@ -0,0 +1,64 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// IncrementCounters atomically adds the deltas to the counters.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) IncrementCounters(ctx context.Context, increments []storage.CounterIncrement) error {
+	if len(increments) < 1 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(increments)*4)
+	for _, inc := range increments {
+		args = append(args, inc.Scope, inc.ID, inc.Name, inc.Delta)
+	}
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO counters
+    (counter_scope, counter_id, counter_name, counter_value)
+VALUES
+    `+strings.Repeat("(?, ?, ?, ?), ", len(increments)-1)+`(?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    counter_value = counters.counter_value + new.counter_value;`,
+		args...,
+	)
+	return err
+}
+
+// RetrieveCounters retrieves the counters for scope and id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveCounters(ctx context.Context, scope, id string) (map[string]int64, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    counter_name,
+    counter_value
+FROM
+    counters
+WHERE
+    counter_scope = ? AND
+    counter_id = ?;`,
+		scope,
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ret := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var value int64
+		if err = rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		ret[name] = value
+	}
+	return ret, rows.Err()
+}
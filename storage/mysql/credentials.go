@@ -0,0 +1,115 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// scanCredential scans a credential row of name, content type, hash, size and time.
+func scanCredential(scan func(...interface{}) error) (*storage.Credential, error) {
+	c := new(storage.Credential)
+	var storedAt string
+	err := scan(&c.Name, &c.ContentType, &c.SHA256, &c.Size, &storedAt)
+	if err != nil {
+		return nil, err
+	}
+	if c.Timestamp, err = time.Parse(mysqlTimeFormat, storedAt); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// RetrieveCredentials retrieves all credentials.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveCredentials(ctx context.Context) ([]*storage.Credential, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT credential_name, content_type, sha256, size, stored_at FROM credentials ORDER BY credential_name;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var credentials []*storage.Credential
+	for rows.Next() {
+		c, err := scanCredential(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, rows.Err()
+}
+
+// RetrieveCredential retrieves the credential with name.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveCredential(ctx context.Context, name string) (*storage.Credential, error) {
+	c, err := scanCredential(s.db.QueryRowContext(
+		ctx,
+		`SELECT credential_name, content_type, sha256, size, stored_at FROM credentials WHERE credential_name = ?;`,
+		name,
+	).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrCredentialNotFound
+	}
+	return c, err
+}
+
+// RetrieveCredentialData retrieves the data of the credential with the SHA-256 hash.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveCredentialData(ctx context.Context, sha256 string) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT data FROM credentials WHERE sha256 = ? LIMIT 1;`,
+		sha256,
+	).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrCredentialNotFound
+	}
+	return data, err
+}
+
+// StoreCredential stores the credential and its data.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreCredential(ctx context.Context, credential *storage.Credential, data []byte) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO credentials
+    (credential_name, content_type, sha256, size, data, stored_at)
+VALUES
+    (?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    content_type = new.content_type,
+    sha256 = new.sha256,
+    size = new.size,
+    data = new.data,
+    stored_at = new.stored_at;`,
+		credential.Name,
+		credential.ContentType,
+		credential.SHA256,
+		credential.Size,
+		data,
+		credential.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// DeleteCredential deletes the credential with name and its data.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteCredential(ctx context.Context, name string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM credentials WHERE credential_name = ?;`,
+		name,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
@@ -2,9 +2,12 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"fmt"
 
 	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // RetrieveEnrollmentDeclarationJSON retreives a declaration intended for a
@@ -12,11 +15,17 @@ import (
 // delivery to a specific enrollment it queries to make sure that enrollment
 // should have access and that it is of the correct type.
 // See also the storage package for documentation on the storage interfaces.
-func (s *MySQLStorage) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) (raw []byte, err error) {
-	// we JOIN against the enrollments table to make sure only those
+func (s *MySQLStorage) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	// we limit to the sets of the enrollment to make sure only those
 	// declarations that are transitively related are able to be
 	// accessed. kinda-sorta like an ACL. almost.
-	err = s.db.QueryRowContext(
+	setsSQL, setsArgs, err := s.enrollmentSetsSQL(ctx, "sd.set_name", enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	args := append([]interface{}{declarationID}, setsArgs...)
+	args = append(args, "com.apple."+declarationType+".%")
+	rows, err := s.db.QueryContext(
 		ctx, `
 SELECT
     JSON_OBJECT(
@@ -24,22 +33,48 @@ SELECT
         "Type",        d.type,
         "Payload",     d.payload,
         "ServerToken", d.server_token
-    ) AS declaration
+    ) AS declaration,
+    sd.condition_path,
+    sd.condition_op,
+    sd.condition_value
 FROM
     declarations d
     INNER JOIN set_declarations sd
         ON d.identifier = sd.declaration_identifier
-    INNER JOIN enrollment_sets es
-        ON sd.set_name = es.set_name
 WHERE
     d.identifier = ? AND
-    es.enrollment_id = ? AND
+    `+setsSQL+` AND
     d.type LIKE ?;`,
-		declarationID,
-		enrollmentID,
-		"com.apple."+declarationType+".%",
-	).Scan(&raw)
-	return
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	// one row for each set of the enrollment the declaration is in
+	var raw []byte
+	conds := make(map[string][]*storage.DeclarationCondition)
+	for rows.Next() {
+		var path, op, value sql.NullString
+		if err = rows.Scan(&raw, &path, &op, &value); err != nil {
+			return nil, err
+		}
+		conds[declarationID] = append(conds[declarationID], nullCondition(path, op, value))
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, sql.ErrNoRows
+	}
+	served, err := s.servedDeclarations(ctx, enrollmentID, conds)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating declaration conditions: %w", err)
+	}
+	if !served[declarationID] {
+		return nil, sql.ErrNoRows
+	}
+	return raw, nil
 }
 
 type builder interface {
@@ -48,41 +83,57 @@ type builder interface {
 }
 
 func (s *MySQLStorage) build(ctx context.Context, b builder, enrollmentID string) error {
+	setsSQL, setsArgs, err := s.enrollmentSetsSQL(ctx, "sd.set_name", enrollmentID)
+	if err != nil {
+		return err
+	}
 	rows, err := s.db.QueryContext(
 		ctx, `
-SELECT DISTINCT
+SELECT
     d.identifier,
     d.type,
-    d.server_token
+    d.server_token,
+    sd.condition_path,
+    sd.condition_op,
+    sd.condition_value
 FROM
     declarations d
     INNER JOIN set_declarations sd
         ON d.identifier = sd.declaration_identifier
-    INNER JOIN enrollment_sets es
-        ON sd.set_name = es.set_name
 WHERE
-    es.enrollment_id = ?;`,
-		enrollmentID,
+    `+setsSQL+`;`,
+		setsArgs...,
 	)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
+	// a declaration may be in multiple sets of the enrollment, each
+	// with its own condition.
+	var declarations []*ddm.Declaration
+	conds := make(map[string][]*storage.DeclarationCondition)
 	for rows.Next() {
 		// note that we're selecting and assembling a very minimal Declaration
 		// here. just enough to work with the builder interface. check the
 		// builder implementation to make sure it doesn't need anything more
 		// than what we're giving it.
 		d := new(ddm.Declaration)
+		var path, op, value sql.NullString
 		err = rows.Scan(
 			&d.Identifier,
 			&d.Type,
 			&d.ServerToken,
+			&path,
+			&op,
+			&value,
 		)
 		if err != nil {
 			break
 		}
-		b.Add(d)
+		if _, ok := conds[d.Identifier]; !ok {
+			declarations = append(declarations, d)
+		}
+		conds[d.Identifier] = append(conds[d.Identifier], nullCondition(path, op, value))
 	}
 	if err != nil {
 		return err
@@ -90,6 +141,15 @@ WHERE
 	if err = rows.Err(); err != nil {
 		return err
 	}
+	served, err := s.servedDeclarations(ctx, enrollmentID, conds)
+	if err != nil {
+		return fmt.Errorf("evaluating declaration conditions: %w", err)
+	}
+	for _, d := range declarations {
+		if served[d.Identifier] {
+			b.Add(d)
+		}
+	}
 	b.Finalize()
 	return nil
 }
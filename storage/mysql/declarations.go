@@ -15,6 +15,9 @@ import (
 // StoreDeclaration stores a declaration and returns whether it changed or not.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) StoreDeclaration(ctx context.Context, d *ddm.Declaration) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalStoreDeclaration, []string{d.Identifier}, nil, nil); err != nil {
+		return false, err
+	}
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return false, err
@@ -64,7 +67,7 @@ UPDATE
 		if rbErr := tx.Rollback(); rbErr != nil {
 			return false, fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
 		}
-		return false, err
+		return false, categorizeError(err)
 	}
 	return changed, tx.Commit()
 }
@@ -150,13 +153,16 @@ func (s *MySQLStorage) RetrieveDeclarationModTime(ctx context.Context, declarati
 // DeleteDeclaration deletes a declaration and returns whether it was deleted or already existed.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) DeleteDeclaration(ctx context.Context, declarationID string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalDeleteDeclaration, []string{declarationID}, nil, nil); err != nil {
+		return false, err
+	}
 	result, err := s.db.ExecContext(
 		ctx,
 		`DELETE FROM declarations WHERE identifier = ?;`,
 		declarationID,
 	)
 	if err != nil {
-		return false, err
+		return false, categorizeError(err)
 	}
 	return resultChangedRows(result)
 }
@@ -183,6 +189,9 @@ func (s *MySQLStorage) RetrieveDeclarations(ctx context.Context) ([]string, erro
 // TouchDeclaration updates a declaration's "touch count" which makes a new server token.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) TouchDeclaration(ctx context.Context, declarationID string) error {
+	if err := s.appendJournal(ctx, storage.JournalTouchDeclaration, []string{declarationID}, nil, nil); err != nil {
+		return err
+	}
 	result, err := s.db.ExecContext(
 		ctx,
 		`
@@ -207,3 +216,14 @@ WHERE
 	}
 	return nil
 }
+
+// RetrieveDeclarationsPage retrieves a page of the sorted list of declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveDeclarationsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT identifier FROM declarations WHERE identifier > ? ORDER BY identifier LIMIT ?;`,
+		after,
+		limit,
+	)
+}
@@ -0,0 +1,73 @@
+package mysql
+
+import (
+	"context"
+)
+
+// RetrieveDeclarationTags retrieves the tags of a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveDeclarationTags(ctx context.Context, declarationID string) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT tag FROM declaration_tags WHERE declaration_identifier = ?;`,
+		declarationID,
+	)
+}
+
+// RetrieveTagDeclarations retrieves the declarations tagged with a tag.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveTagDeclarations(ctx context.Context, tag string) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT declaration_identifier FROM declaration_tags WHERE tag = ?;`,
+		tag,
+	)
+}
+
+// RetrieveTags retrieves all tags.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveTags(ctx context.Context) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT DISTINCT tag FROM declaration_tags;`,
+	)
+}
+
+// StoreDeclarationTag tags a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO declaration_tags
+    (declaration_identifier, tag)
+VALUES
+    (?, ?)
+ON DUPLICATE KEY
+UPDATE
+    tag = tag;`,
+		declarationID,
+		tag,
+	)
+	if err != nil {
+		return false, categorizeError(err)
+	}
+	return resultChangedRows(result)
+}
+
+// RemoveDeclarationTag removes a tag from a declaration.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RemoveDeclarationTag(ctx context.Context, declarationID, tag string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx, `
+DELETE FROM declaration_tags
+WHERE
+    declaration_identifier = ? AND
+    tag = ?;`,
+		declarationID,
+		tag,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
@@ -0,0 +1,134 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveDeclarationUsage retrieves the usage of declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveDeclarationUsage(ctx context.Context, declarationIDs []string) ([]storage.DeclarationUsage, error) {
+	if len(declarationIDs) < 1 {
+		return s.retrieveDeclarationUsage(ctx, nil)
+	}
+	var ret []storage.DeclarationUsage
+	for _, chunk := range storage.ChunkStrings(declarationIDs, maxInParams) {
+		usage, err := s.retrieveDeclarationUsage(ctx, chunk)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, usage...)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Identifier < ret[j].Identifier })
+	return ret, nil
+}
+
+// inIDs returns an SQL condition that column is one of ids and its
+// arguments. An always true condition is returned if ids is empty.
+func inIDs(column string, ids []string) (string, []interface{}) {
+	if len(ids) < 1 {
+		return "TRUE", nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return column + ` IN (` + strings.Repeat(", ?", len(ids))[2:] + `)`, args
+}
+
+func (s *MySQLStorage) retrieveDeclarationUsage(ctx context.Context, declarationIDs []string) ([]storage.DeclarationUsage, error) {
+	cond, args := inIDs("d.identifier", declarationIDs)
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    d.identifier,
+    (
+        SELECT
+            COUNT(DISTINCT es.enrollment_id)
+        FROM
+            set_declarations sd
+            INNER JOIN enrollment_sets es
+                ON sd.set_name = es.set_name
+        WHERE
+            sd.declaration_identifier = d.identifier
+    ) AS enrollments
+FROM
+    declarations d
+WHERE
+    `+cond+`
+ORDER BY
+    d.identifier;`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []storage.DeclarationUsage
+	idx := make(map[string]int)
+	for rows.Next() {
+		u := storage.DeclarationUsage{Sets: []string{}, ReferencedBy: []string{}}
+		if err = rows.Scan(&u.Identifier, &u.Enrollments); err != nil {
+			return nil, err
+		}
+		idx[u.Identifier] = len(ret)
+		ret = append(ret, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for _, id := range declarationIDs {
+		if _, ok := idx[id]; !ok {
+			return nil, fmt.Errorf("%w: %s", storage.ErrDeclarationNotFound, id)
+		}
+	}
+
+	// collect the sets and referencing declarations
+	for _, q := range []struct {
+		sql    string
+		column string
+		add    func(u *storage.DeclarationUsage, v string)
+	}{
+		{
+			`SELECT declaration_identifier, set_name FROM set_declarations WHERE %s ORDER BY set_name;`,
+			"declaration_identifier",
+			func(u *storage.DeclarationUsage, v string) { u.Sets = append(u.Sets, v) },
+		},
+		{
+			`SELECT declaration_reference, declaration_identifier FROM declaration_references WHERE %s ORDER BY declaration_identifier;`,
+			"declaration_reference",
+			func(u *storage.DeclarationUsage, v string) { u.ReferencedBy = append(u.ReferencedBy, v) },
+		},
+	} {
+		cond, args := inIDs(q.column, declarationIDs)
+		if err = s.collectDeclarationUsage(ctx, fmt.Sprintf(q.sql, cond), args, func(id, v string) {
+			if i, ok := idx[id]; ok {
+				q.add(&ret[i], v)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// collectDeclarationUsage calls add with the two string columns of each row of query.
+func (s *MySQLStorage) collectDeclarationUsage(ctx context.Context, query string, args []interface{}, add func(id, v string)) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, v string
+		if err = rows.Scan(&id, &v); err != nil {
+			return err
+		}
+		add(id, v)
+	}
+	return rows.Err()
+}
@@ -2,8 +2,12 @@ package mysql
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // RetrieveEnrollmentSets retrieves the list of sets an enrollment is assigned to.
@@ -19,6 +23,9 @@ func (s *MySQLStorage) RetrieveEnrollmentSets(ctx context.Context, enrollmentID
 // StoreEnrollmentSet creates the association between an enrollment and a set.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) StoreEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalStoreEnrollmentSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
 	result, err := s.db.ExecContext(
 		ctx, `
 INSERT INTO enrollment_sets
@@ -40,6 +47,9 @@ UPDATE
 // RemoveEnrollmentSet removes the association between an enrollment and a set.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) RemoveEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalRemoveEnrollmentSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
 	result, err := s.db.ExecContext(
 		ctx, `
 DELETE FROM enrollment_sets
@@ -92,6 +102,20 @@ func (s *MySQLStorage) RetrieveEnrollmentIDs(ctx context.Context, declarations [
 	if len(params) < 1 {
 		return nil, errors.New("no parameters provided")
 	}
+	var registeredSQL string
+	if len(ids) > 0 {
+		// registered enrollments are known before their first sync
+		r, p := qAndP(ids)
+		registeredSQL = `
+UNION
+SELECT
+    enrollment_id
+FROM
+    enrollment_registrations
+WHERE
+    enrollment_id IN (` + r + `)`
+		params = append(params, p...)
+	}
 	rows, err := s.db.QueryContext(
 		ctx, `
 SELECT DISTINCT
@@ -102,7 +126,7 @@ FROM
         ON d.identifier = sd.declaration_identifier
     INNER JOIN enrollment_sets es
         ON sd.set_name = es.set_name
-    WHERE `+strings.Join(where, " OR "),
+    WHERE `+strings.Join(where, " OR ")+registeredSQL,
 		params...,
 	)
 	if err != nil {
@@ -123,3 +147,81 @@ FROM
 	}
 	return retIDs, err
 }
+
+// RetrieveEnrollmentDeclarations retrieves the declarations enrollmentID is entitled to.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentDeclarations(ctx context.Context, enrollmentID string) ([]storage.EnrollmentDeclaration, error) {
+	setsSQL, setsArgs, err := s.enrollmentSetsSQL(ctx, "sd.set_name", enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    d.identifier,
+    d.type,
+    d.server_token,
+    sd.set_name,
+    sd.condition_path,
+    sd.condition_op,
+    sd.condition_value
+FROM
+    declarations d
+    INNER JOIN set_declarations sd
+        ON d.identifier = sd.declaration_identifier
+WHERE
+    `+setsSQL+`
+ORDER BY
+    d.identifier, sd.set_name;`,
+		setsArgs...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var decls []storage.EnrollmentDeclaration
+	conds := make(map[string][]*storage.DeclarationCondition)
+	for rows.Next() {
+		var d storage.EnrollmentDeclaration
+		var setName string
+		var path, op, value sql.NullString
+		err = rows.Scan(
+			&d.Identifier,
+			&d.Type,
+			&d.ServerToken,
+			&setName,
+			&path,
+			&op,
+			&value,
+		)
+		if err != nil {
+			break
+		}
+		conds[d.Identifier] = append(conds[d.Identifier], nullCondition(path, op, value))
+		if len(decls) > 0 && decls[len(decls)-1].Identifier == d.Identifier {
+			// rows are ordered by identifier so the same declaration
+			// in multiple sets is adjacent.
+			decls[len(decls)-1].Sets = append(decls[len(decls)-1].Sets, setName)
+			continue
+		}
+		d.Sets = []string{setName}
+		decls = append(decls, d)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	if err != nil {
+		return nil, err
+	}
+	served, err := s.servedDeclarations(ctx, enrollmentID, conds)
+	if err != nil {
+		return nil, fmt.Errorf("evaluating declaration conditions: %w", err)
+	}
+	var ret []storage.EnrollmentDeclaration
+	for _, d := range decls {
+		if served[d.Identifier] {
+			ret = append(ret, d)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,114 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreEnrollmentFreeze freezes an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentFreeze(ctx context.Context, freeze *storage.EnrollmentFreeze) error {
+	if err := s.appendJournal(ctx, storage.JournalStoreEnrollmentFreeze, nil, nil, []string{freeze.EnrollmentID}); err != nil {
+		return err
+	}
+	declarationsJSON, err := json.Marshal(freeze.Declarations)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO enrollment_freezes
+    (enrollment_id, tokens, declaration_items, declarations, created_at)
+VALUES
+    (?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    tokens = new.tokens,
+    declaration_items = new.declaration_items,
+    declarations = new.declarations,
+    created_at = new.created_at;`,
+		freeze.EnrollmentID,
+		[]byte(freeze.TokensJSON),
+		[]byte(freeze.DeclarationItemsJSON),
+		declarationsJSON,
+		freeze.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// RetrieveEnrollmentFreeze retrieves the freeze of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentFreeze(ctx context.Context, enrollmentID string) (*storage.EnrollmentFreeze, error) {
+	freeze := &storage.EnrollmentFreeze{EnrollmentID: enrollmentID}
+	var declarationsJSON []byte
+	var dbTimestamp string
+	err := s.db.QueryRowContext(
+		ctx, `
+SELECT
+    tokens,
+    declaration_items,
+    declarations,
+    created_at
+FROM
+    enrollment_freezes
+WHERE
+    enrollment_id = ?;`,
+		enrollmentID,
+	).Scan(
+		&freeze.TokensJSON,
+		&freeze.DeclarationItemsJSON,
+		&declarationsJSON,
+		&dbTimestamp,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(declarationsJSON, &freeze.Declarations); err != nil {
+		return nil, err
+	}
+	freeze.Timestamp, err = time.Parse(mysqlTimeFormat, dbTimestamp)
+	return freeze, err
+}
+
+// DeleteEnrollmentFreeze unfreezes an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteEnrollmentFreeze(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalDeleteEnrollmentFreeze, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM enrollment_freezes WHERE enrollment_id = ?;`,
+		enrollmentID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// RetrieveFrozenEnrollmentIDs retrieves the frozen enrollments of enrollmentIDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveFrozenEnrollmentIDs(ctx context.Context, enrollmentIDs []string) ([]string, error) {
+	var frozen []string
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		cond, args := inIDs("enrollment_id", chunk)
+		ids, err := s.singleStringColumn(
+			ctx,
+			`SELECT enrollment_id FROM enrollment_freezes WHERE `+cond+`;`,
+			args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		frozen = append(frozen, ids...)
+	}
+	return frozen, nil
+}
@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// storeStatusValueHistory records the values that changed since they
+// were last recorded and then trims the history of each path.
+func (s *MySQLStorage) storeStatusValueHistory(ctx context.Context, enrollmentID, statusID, now string, values []ddm.StatusValue) error {
+	if len(values) < 1 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		var lastType, lastValue string
+		err = tx.QueryRowContext(
+			ctx, `
+SELECT
+    value_type,
+    value
+FROM
+    status_value_history
+WHERE
+    enrollment_id = ? AND
+    path = ?
+ORDER BY
+    id DESC
+LIMIT 1;`,
+			enrollmentID,
+			v.Path,
+		).Scan(&lastType, &lastValue)
+		if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		} else if err == nil && lastType == v.ValueType && lastValue == string(v.Value) {
+			// unchanged
+			continue
+		}
+
+		if err == nil {
+			_, err = tx.ExecContext(
+				ctx, `
+INSERT INTO status_value_history
+    (enrollment_id, path, value_type, value, status_id, created_at)
+VALUES
+    (?, ?, ?, ?, ?, ?);`,
+				enrollmentID,
+				v.Path,
+				v.ValueType,
+				v.Value,
+				sql.NullString{
+					String: statusID,
+					Valid:  len(statusID) > 0,
+				},
+				now,
+			)
+		}
+
+		if err == nil {
+			// the derived table works around MySQL not allowing a
+			// subquery to select from the table being deleted from
+			_, err = tx.ExecContext(
+				ctx, `
+DELETE FROM
+    status_value_history
+WHERE
+    enrollment_id = ? AND
+    path = ? AND
+    id <= (
+        SELECT id FROM (
+            SELECT id
+            FROM status_value_history
+            WHERE enrollment_id = ? AND path = ?
+            ORDER BY id DESC
+            LIMIT 1 OFFSET ?
+        ) AS oldest
+    );`,
+				enrollmentID,
+				v.Path,
+				enrollmentID,
+				v.Path,
+				s.history.Max,
+			)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RetrieveStatusValueHistory retrieves the recorded values of path for enrollmentIDs.
+// Large numbers of enrollment IDs are queried in chunks.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveStatusValueHistory(ctx context.Context, enrollmentIDs []string, path string) (map[string][]storage.StatusValue, error) {
+	resp := make(map[string][]storage.StatusValue)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveStatusValueHistory(ctx, chunk, path, resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (s *MySQLStorage) retrieveStatusValueHistory(ctx context.Context, enrollmentIDs []string, path string, resp map[string][]storage.StatusValue) error {
+	idSQL := strings.Repeat(", ?", len(enrollmentIDs))[2:]
+	args := make([]interface{}, len(enrollmentIDs), len(enrollmentIDs)+1)
+	for i, id := range enrollmentIDs {
+		args[i] = id
+	}
+	args = append(args, path)
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    enrollment_id,
+    path,
+    value_type,
+    value,
+    status_id,
+    created_at
+FROM
+    status_value_history
+WHERE
+    enrollment_id IN (`+idSQL+`) AND
+    path = ?
+ORDER BY
+    enrollment_id, id;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var id string
+	for rows.Next() {
+		sVal := storage.StatusValue{}
+		var dbTimestamp string
+		var statusID sql.NullString
+		err = rows.Scan(
+			&id,
+			&sVal.Path,
+			&sVal.Type,
+			&sVal.Value,
+			&statusID,
+			&dbTimestamp,
+		)
+		if err != nil {
+			break
+		}
+		sVal.StatusID = statusID.String
+		sVal.Timestamp, _ = time.Parse(mysqlTimeFormat, dbTimestamp)
+		resp[id] = append(resp[id], sVal)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return err
+}
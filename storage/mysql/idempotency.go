@@ -0,0 +1,80 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveIdempotentResponse retrieves the recorded response for key.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveIdempotentResponse(ctx context.Context, key string) (*storage.IdempotentResponse, error) {
+	resp := new(storage.IdempotentResponse)
+	var dbTimestamp string
+	err := s.db.QueryRowContext(
+		ctx, `
+SELECT
+    fingerprint,
+    status_code,
+    content_type,
+    body,
+    updated_at
+FROM
+    idempotency_keys
+WHERE
+    idempotency_key = ?;`,
+		key,
+	).Scan(
+		&resp.Fingerprint,
+		&resp.StatusCode,
+		&resp.ContentType,
+		&resp.Body,
+		&dbTimestamp,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	resp.Timestamp, err = time.Parse(mysqlTimeFormat, dbTimestamp)
+	return resp, err
+}
+
+// StoreIdempotentResponse records resp for key.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreIdempotentResponse(ctx context.Context, key string, resp *storage.IdempotentResponse) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO idempotency_keys
+    (idempotency_key, fingerprint, status_code, content_type, body)
+VALUES
+    (?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    fingerprint = new.fingerprint,
+    status_code = new.status_code,
+    content_type = new.content_type,
+    body = new.body,
+    updated_at = CURRENT_TIMESTAMP;`,
+		key,
+		resp.Fingerprint,
+		resp.StatusCode,
+		resp.ContentType,
+		resp.Body,
+	)
+	return err
+}
+
+// DeleteIdempotentResponses deletes the responses recorded before a time.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteIdempotentResponses(ctx context.Context, before time.Time) error {
+	_, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE updated_at < ?;`,
+		before.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
@@ -0,0 +1,85 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// appendJournal appends an entry for op to the journal.
+func (s *MySQLStorage) appendJournal(ctx context.Context, op string, declarations, sets, enrollments []string) error {
+	var lists [3][]byte
+	for i, list := range [][]string{declarations, sets, enrollments} {
+		var err error
+		if lists[i], err = json.Marshal(list); err != nil {
+			return err
+		}
+	}
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO journal
+    (op, declarations, sets, enrollments)
+VALUES
+    (?, ?, ?, ?);`,
+		op,
+		lists[0],
+		lists[1],
+		lists[2],
+	)
+	return err
+}
+
+// RetrieveJournal retrieves the journal of storage mutations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveJournal(ctx context.Context, after int64, limit int) ([]storage.JournalEntry, error) {
+	q := `
+SELECT
+    seq,
+    op,
+    declarations,
+    sets,
+    enrollments,
+    created_at
+FROM
+    journal
+WHERE
+    seq > ?
+ORDER BY
+    seq`
+	args := []interface{}{after}
+	if limit > 0 {
+		q += `
+LIMIT ?`
+		args = append(args, limit)
+	}
+	rows, err := s.db.QueryContext(ctx, q+`;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []storage.JournalEntry
+	for rows.Next() {
+		var e storage.JournalEntry
+		var declarationsJSON, setsJSON, enrollmentsJSON []byte
+		var createdAt string
+		if err = rows.Scan(&e.Seq, &e.Op, &declarationsJSON, &setsJSON, &enrollmentsJSON, &createdAt); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(declarationsJSON, &e.Declarations); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(setsJSON, &e.Sets); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(enrollmentsJSON, &e.Enrollments); err != nil {
+			return nil, err
+		}
+		if e.Timestamp, err = time.Parse(mysqlTimeFormat, createdAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
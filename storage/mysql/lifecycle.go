@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// retrieveLifecycleStages retrieves all lifecycle stages using q.
+func retrieveLifecycleStages(ctx context.Context, q querier) ([]storage.LifecycleStage, error) {
+	rows, err := q.QueryContext(
+		ctx,
+		`SELECT stage_name, sets FROM lifecycle_stages ORDER BY stage_name;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var stages []storage.LifecycleStage
+	for rows.Next() {
+		var stage storage.LifecycleStage
+		var setsJSON []byte
+		if err = rows.Scan(&stage.Name, &setsJSON); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(setsJSON, &stage.Sets); err != nil {
+			return nil, fmt.Errorf("unmarshal sets of lifecycle stage %s: %w", stage.Name, err)
+		}
+		stages = append(stages, stage)
+	}
+	return stages, rows.Err()
+}
+
+// RetrieveLifecycleStages retrieves all lifecycle stages.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveLifecycleStages(ctx context.Context) ([]storage.LifecycleStage, error) {
+	return retrieveLifecycleStages(ctx, s.db)
+}
+
+// StoreLifecycleStage stores a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreLifecycleStage(ctx context.Context, stage *storage.LifecycleStage) (bool, error) {
+	setsJSON, err := json.Marshal(stage.Sets)
+	if err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO lifecycle_stages
+    (stage_name, sets)
+VALUES
+    (?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    sets = new.sets;`,
+		stage.Name,
+		setsJSON,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// DeleteLifecycleStage deletes a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteLifecycleStage(ctx context.Context, name string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM lifecycle_stages WHERE stage_name = ?;`,
+		name,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// RetrieveEnrollmentLifecycleStage retrieves the lifecycle stage of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentLifecycleStage(ctx context.Context, enrollmentID string) (string, error) {
+	stage, err := s.singleStringColumn(
+		ctx,
+		`SELECT stage_name FROM enrollment_lifecycle_stages WHERE enrollment_id = ?;`,
+		enrollmentID,
+	)
+	if err != nil || len(stage) < 1 {
+		return "", err
+	}
+	return stage[0], nil
+}
+
+// TransitionEnrollmentLifecycleStage transitions an enrollment to a lifecycle stage.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) TransitionEnrollmentLifecycleStage(ctx context.Context, enrollmentID, name string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalTransitionEnrollmentLifecycleStage, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	var stages []storage.LifecycleStage
+	var current, add, remove []string
+	var changed bool
+	stages, err = retrieveLifecycleStages(ctx, tx)
+	if err == nil {
+		current, err = singleStringColumn(ctx, tx, `SELECT set_name FROM enrollment_sets WHERE enrollment_id = ? FOR UPDATE;`, enrollmentID)
+	}
+	if err == nil {
+		add, remove, err = storage.LifecycleTransition(stages, current, name)
+	}
+	if err == nil && len(remove) > 0 {
+		inSQL, inArgs := inIDs("set_name", remove)
+		_, err = tx.ExecContext(
+			ctx,
+			`DELETE FROM enrollment_sets WHERE enrollment_id = ? AND `+inSQL+`;`,
+			append([]interface{}{enrollmentID}, inArgs...)...,
+		)
+	}
+	if err == nil {
+		err = insertEnrollmentSets(ctx, tx, "enrollment_sets", enrollmentID, add)
+	}
+	var result sql.Result
+	if err == nil {
+		result, err = tx.ExecContext(
+			ctx, `
+INSERT INTO enrollment_lifecycle_stages
+    (enrollment_id, stage_name)
+VALUES
+    (?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    stage_name = new.stage_name;`,
+			enrollmentID,
+			name,
+		)
+	}
+	if err == nil {
+		changed, err = resultChangedRows(result)
+		changed = changed || len(add) > 0 || len(remove) > 0
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return false, fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return false, err
+	}
+	return changed, tx.Commit()
+}
@@ -4,17 +4,32 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"hash"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 const mysqlTimeFormat = "2006-01-02 15:04:05"
 
+// maxInParams is the maximum number of parameters we place into a
+// single SQL IN clause before splitting a query into chunks.
+const maxInParams = 1000
+
 // MySQLStorage implements a MySQL storage backend.
 type MySQLStorage struct {
 	db      *sql.DB
 	newHash func() hash.Hash
 	errDel  uint
 	stsDel  uint
+	history *storage.StatusValueHistory
+	limits  *storage.StatusLimits
+	clock   storage.Clock
+
+	// compress gzip compresses raw status reports
+	compress bool
 }
 
 type config struct {
@@ -23,6 +38,11 @@ type config struct {
 	db     *sql.DB
 	errDel uint
 	stsDel uint
+	hist   *storage.StatusValueHistory
+	limits *storage.StatusLimits
+	clock  storage.Clock
+
+	compress bool
 }
 
 type Option func(*config)
@@ -65,19 +85,51 @@ func WithStatusReportDeletion(count uint) Option {
 	}
 }
 
+// WithStatusReportCompression gzip compresses stored raw status reports.
+// Compressed status reports are decompressed when read whether or not
+// compression is enabled.
+func WithStatusReportCompression() Option {
+	return func(c *config) {
+		c.compress = true
+	}
+}
+
+// WithValueHistory records the changes of the status values of paths.
+// At most count values are kept per enrollment ID and path.
+func WithValueHistory(paths []string, count uint) Option {
+	return func(c *config) {
+		c.hist = &storage.StatusValueHistory{Paths: paths, Max: int(count)}
+	}
+}
+
+// WithStatusLimits limits the number of status values and errors stored.
+func WithStatusLimits(limits *storage.StatusLimits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// WithClock sets the clock used to timestamp stored status data.
+// The default is the system clock.
+func WithClock(clock storage.Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
 // New creates and initializes a new MySQL storage backend.
 // New attempts to Ping the database after opening to verify connectivity.
 func New(newHash func() hash.Hash, opts ...Option) (*MySQLStorage, error) {
 	if newHash == nil {
 		panic("nil hasher")
 	}
-	cfg := config{driver: "mysql"}
+	cfg := config{driver: "mysql", clock: storage.SystemClock}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
 	var err error
 	if cfg.db == nil {
-		cfg.db, err = sql.Open(cfg.driver, cfg.dsn)
+		cfg.db, err = openDB(cfg.driver, cfg.dsn)
 		if err != nil {
 			return nil, err
 		}
@@ -90,9 +142,62 @@ func New(newHash func() hash.Hash, opts ...Option) (*MySQLStorage, error) {
 		newHash: newHash,
 		errDel:  cfg.errDel,
 		stsDel:  cfg.stsDel,
+		history: cfg.hist,
+		limits:  cfg.limits,
+		clock:   cfg.clock,
+
+		compress: cfg.compress,
 	}, nil
 }
 
+// unavailableConnector categorizes errors connecting to the database as
+// unavailable. See also the storage package error categories.
+type unavailableConnector struct {
+	driver.Connector
+}
+
+func (c *unavailableConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	return conn, storage.Categorize(storage.ErrUnavailable, err)
+}
+
+// openDB opens the database with driverName. If the driver supports
+// connectors then errors connecting to the database are categorized.
+func openDB(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+	dc, ok := db.Driver().(driver.DriverContext)
+	if !ok {
+		return db, nil
+	}
+	connector, err := dc.OpenConnector(dsn)
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&unavailableConnector{Connector: connector}), nil
+}
+
+// categorizeError categorizes MySQL constraint errors.
+// See also the storage package error categories.
+func categorizeError(err error) error {
+	var mysqlErr *mysqldriver.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return err
+	}
+	switch mysqlErr.Number {
+	case 1062, 1451:
+		// duplicate key or a row is still referenced
+		return storage.Categorize(storage.ErrConflict, err)
+	case 1452:
+		// a referenced row does not exist
+		return storage.Categorize(storage.ErrNotFound, err)
+	}
+	return err
+}
+
 // resultChangedRows tries to tell us if if the record changed. Note that
 // MySQL has an odd special case for result rows when INSERT INTO ... ON
 // DUPLICATE KEY is used. The manual states 0 is returned for no change,
@@ -109,7 +214,18 @@ func resultChangedRows(r sql.Result) (bool, error) {
 // singleStringColumn executes sql with args using ctx and expects a single
 // column string to return all the rows in a string slice.
 func (s *MySQLStorage) singleStringColumn(ctx context.Context, sql string, args ...interface{}) ([]string, error) {
-	rows, err := s.db.QueryContext(ctx, sql, args...)
+	return singleStringColumn(ctx, s.db, sql, args...)
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func singleStringColumn(ctx context.Context, q querier, sql string, args ...interface{}) ([]string, error) {
+	rows, err := q.QueryContext(ctx, sql, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -8,8 +8,11 @@ import (
 	"flag"
 	"hash"
 	"testing"
+	"time"
 
 	"github.com/cespare/xxhash"
+	ddmtest "github.com/jessepeterson/kmfddm/http/ddm/test"
+	"github.com/jessepeterson/kmfddm/storage"
 	"github.com/jessepeterson/kmfddm/storage/test"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -22,7 +25,11 @@ func TestMySQL(t *testing.T) {
 		t.Fatal("MySQL DSN flag not provided to test")
 	}
 
-	storage, err := New(func() hash.Hash { return xxhash.New() }, WithDSN(*flDSN))
+	storage, err := New(
+		func() hash.Hash { return xxhash.New() },
+		WithDSN(*flDSN),
+		WithValueHistory([]string{test.StatusValueHistoryPath}, 3),
+	)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -30,4 +37,45 @@ func TestMySQL(t *testing.T) {
 	ctx := context.Background()
 	test.TestBasic(t, storage, ctx)
 	test.TestBasicStatus(t, "../test", storage, ctx)
+	ddmtest.TestContract(t, "../../http/ddm/test", storage, ctx)
+	test.TestStatusValueHistory(t, storage, ctx, 3)
+}
+
+func TestMySQLStatusLimits(t *testing.T) {
+	if *flDSN == "" {
+		t.Fatal("MySQL DSN flag not provided to test")
+	}
+
+	s, err := New(
+		func() hash.Hash { return xxhash.New() },
+		WithDSN(*flDSN),
+		WithStatusLimits(&storage.StatusLimits{
+			ReportValues: 3,
+			ReportErrors: 2,
+			Values:       4,
+			Errors:       3,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestStatusLimits(t, s, context.Background())
+}
+
+func TestMySQLClock(t *testing.T) {
+	if *flDSN == "" {
+		t.Fatal("MySQL DSN flag not provided to test")
+	}
+
+	s, err := New(
+		func() hash.Hash { return xxhash.New() },
+		WithDSN(*flDSN),
+		WithClock(storage.ClockFunc(func() time.Time { return test.ClockTime })),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	test.TestStatusClock(t, s, context.Background())
 }
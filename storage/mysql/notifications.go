@@ -0,0 +1,79 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveEnrollmentNotifications retrieves when enrollments were last notified.
+// Large numbers of enrollment IDs are queried in chunks.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentNotifications(ctx context.Context, enrollmentIDs []string) (map[string]*storage.EnrollmentNotification, error) {
+	ret := make(map[string]*storage.EnrollmentNotification)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		cond, args := inIDs("enrollment_id", chunk)
+		rows, err := s.db.QueryContext(
+			ctx,
+			`SELECT enrollment_id, tokens_hash, notified_at FROM enrollment_notifications WHERE `+cond+`;`,
+			args...,
+		)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			n := new(storage.EnrollmentNotification)
+			var notifiedAt string
+			if err = rows.Scan(&n.EnrollmentID, &n.TokensHash, &notifiedAt); err != nil {
+				break
+			}
+			if n.Timestamp, err = time.Parse(mysqlTimeFormat, notifiedAt); err != nil {
+				break
+			}
+			ret[n.EnrollmentID] = n
+		}
+		if err == nil {
+			err = rows.Err()
+		}
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// StoreEnrollmentNotifications stores when enrollments were last notified.
+// Large numbers of notifications are stored in chunks.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentNotifications(ctx context.Context, notifications []*storage.EnrollmentNotification) error {
+	const maxRows = maxInParams / 3
+	for i := 0; i < len(notifications); i += maxRows {
+		end := i + maxRows
+		if end > len(notifications) {
+			end = len(notifications)
+		}
+		chunk := notifications[i:end]
+		args := make([]interface{}, 0, len(chunk)*3)
+		for _, n := range chunk {
+			args = append(args, n.EnrollmentID, n.TokensHash, n.Timestamp.UTC().Format(mysqlTimeFormat))
+		}
+		_, err := s.db.ExecContext(
+			ctx, `
+INSERT INTO enrollment_notifications
+    (enrollment_id, tokens_hash, notified_at)
+VALUES
+    `+strings.Repeat(", (?, ?, ?)", len(chunk))[2:]+` AS new
+ON DUPLICATE KEY UPDATE
+    tokens_hash = new.tokens_hash,
+    notified_at = new.notified_at;`,
+			args...,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
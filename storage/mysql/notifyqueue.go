@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreNotificationBatch stores a queued notification batch.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreNotificationBatch(ctx context.Context, batch *storage.NotificationBatch) error {
+	if err := batch.Validate(); err != nil {
+		return err
+	}
+	idsJSON, err := json.Marshal(batch.EnrollmentIDs)
+	if err != nil {
+		return fmt.Errorf("marshal enrollment IDs: %w", err)
+	}
+	var notBefore sql.NullString
+	if !batch.NotBefore.IsZero() {
+		notBefore = sql.NullString{String: batch.NotBefore.UTC().Format(mysqlTimeFormat), Valid: true}
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO notification_batches
+    (id, priority, enrollment_ids, queued_at, not_before)
+VALUES
+    (?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY UPDATE
+    priority = new.priority,
+    enrollment_ids = new.enrollment_ids,
+    queued_at = new.queued_at,
+    not_before = new.not_before;`,
+		batch.ID,
+		batch.Priority,
+		idsJSON,
+		batch.Timestamp.UTC().Format(mysqlTimeFormat),
+		notBefore,
+	)
+	return err
+}
+
+// RetrieveNotificationBatches retrieves the queued notification batches.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveNotificationBatches(ctx context.Context) ([]*storage.NotificationBatch, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT id, priority, enrollment_ids, queued_at, not_before FROM notification_batches ORDER BY seq;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []*storage.NotificationBatch
+	for rows.Next() {
+		b := new(storage.NotificationBatch)
+		var idsJSON []byte
+		var queuedAt string
+		var notBefore sql.NullString
+		if err = rows.Scan(&b.ID, &b.Priority, &idsJSON, &queuedAt, &notBefore); err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(idsJSON, &b.EnrollmentIDs); err != nil {
+			return nil, fmt.Errorf("unmarshal enrollment IDs of %s: %w", b.ID, err)
+		}
+		if b.Timestamp, err = time.Parse(mysqlTimeFormat, queuedAt); err != nil {
+			return nil, err
+		}
+		if notBefore.Valid {
+			if b.NotBefore, err = time.Parse(mysqlTimeFormat, notBefore.String); err != nil {
+				return nil, err
+			}
+		}
+		ret = append(ret, b)
+	}
+	return ret, rows.Err()
+}
+
+// DeleteNotificationBatch deletes a queued notification batch.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteNotificationBatch(ctx context.Context, id string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM notification_batches WHERE id = ?;`, id)
+	return err
+}
@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+)
+
+// StoreOwner records the owner of an object.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreOwner(ctx context.Context, kind, id, principal string) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO owners
+    (kind, id, principal)
+VALUES
+    (?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    principal = new.principal;`,
+		kind,
+		id,
+		principal,
+	)
+	return err
+}
+
+// RetrieveOwned retrieves the identifiers of the objects owned by a principal.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveOwned(ctx context.Context, kind, principal string) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT id FROM owners WHERE kind = ? AND principal = ?;`,
+		kind,
+		principal,
+	)
+}
@@ -0,0 +1,130 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+const pendingChangeColumns = `
+    id,
+    principal,
+    method,
+    path,
+    raw_query,
+    content_type,
+    body,
+    if_match,
+    created_at`
+
+// scanPendingChange scans a row of pendingChangeColumns.
+func scanPendingChange(scan func(...interface{}) error) (*storage.PendingChange, error) {
+	change := new(storage.PendingChange)
+	var dbTimestamp string
+	err := scan(
+		&change.ID,
+		&change.Principal,
+		&change.Method,
+		&change.Path,
+		&change.RawQuery,
+		&change.ContentType,
+		&change.Body,
+		&change.IfMatch,
+		&dbTimestamp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	change.Timestamp, err = time.Parse(mysqlTimeFormat, dbTimestamp)
+	return change, err
+}
+
+// StorePendingChange stores change using its ID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StorePendingChange(ctx context.Context, change *storage.PendingChange) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO pending_changes
+    (id, principal, method, path, raw_query, content_type, body, if_match)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    principal = new.principal,
+    method = new.method,
+    path = new.path,
+    raw_query = new.raw_query,
+    content_type = new.content_type,
+    body = new.body,
+    if_match = new.if_match;`,
+		change.ID,
+		change.Principal,
+		change.Method,
+		change.Path,
+		change.RawQuery,
+		change.ContentType,
+		change.Body,
+		change.IfMatch,
+	)
+	return err
+}
+
+// RetrievePendingChanges retrieves all pending changes.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrievePendingChanges(ctx context.Context) ([]*storage.PendingChange, error) {
+	rows, err := s.db.QueryContext(
+		ctx,
+		`SELECT`+pendingChangeColumns+` FROM pending_changes ORDER BY created_at, id;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var changes []*storage.PendingChange
+	for rows.Next() {
+		change, err := scanPendingChange(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// RetrievePendingChange retrieves the pending change with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrievePendingChange(ctx context.Context, id string) (*storage.PendingChange, error) {
+	change, err := scanPendingChange(s.db.QueryRowContext(
+		ctx,
+		`SELECT`+pendingChangeColumns+` FROM pending_changes WHERE id = ?;`,
+		id,
+	).Scan)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrPendingChangeNotFound
+	}
+	return change, err
+}
+
+// DeletePendingChange deletes the pending change with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeletePendingChange(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM pending_changes WHERE id = ?;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := resultChangedRows(result)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return storage.ErrPendingChangeNotFound
+	}
+	return nil
+}
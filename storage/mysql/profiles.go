@@ -0,0 +1,42 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreProfile stores the raw configuration profile with profileID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreProfile(ctx context.Context, profileID string, raw []byte) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO profiles
+    (profile_id, profile)
+VALUES
+    (?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    profile = new.profile;`,
+		profileID,
+		raw,
+	)
+	return err
+}
+
+// RetrieveProfile retrieves the raw configuration profile with profileID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveProfile(ctx context.Context, profileID string) ([]byte, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT profile FROM profiles WHERE profile_id = ?;`,
+		profileID,
+	).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrProfileNotFound
+	}
+	return raw, err
+}
@@ -0,0 +1,146 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// storeStatusQuarantine appends fragments to the quarantined status
+// fragments of enrollmentID keeping only the newest.
+func (s *MySQLStorage) storeStatusQuarantine(ctx context.Context, enrollmentID, statusID, now string, fragments []ddm.StatusFragment) error {
+	if len(fragments) < 1 {
+		return nil
+	}
+	argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(fragments))[2:]
+	const argLen = 6
+	args := make([]interface{}, len(fragments)*argLen)
+	for i, f := range fragments {
+		args[i*argLen] = enrollmentID
+		args[i*argLen+1] = f.Path
+		args[i*argLen+2] = f.Error
+		args[i*argLen+3] = f.JSON
+		args[i*argLen+4] = sql.NullString{
+			String: statusID,
+			Valid:  len(statusID) > 0,
+		}
+		args[i*argLen+5] = now
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
+		ctx, `
+INSERT INTO status_quarantine
+    (
+        enrollment_id,
+        path,
+        error,
+        fragment,
+        status_id,
+        created_at
+    )
+VALUES
+    `+argSQL+`;`,
+		args...,
+	)
+
+	if err == nil {
+		// the derived table is required to select from the table
+		// being deleted from.
+		_, err = tx.ExecContext(
+			ctx, `
+DELETE FROM
+    status_quarantine
+WHERE
+    enrollment_id = ? AND
+    id <= (
+        SELECT id FROM (
+            SELECT id
+            FROM status_quarantine
+            WHERE enrollment_id = ?
+            ORDER BY id DESC
+            LIMIT 1 OFFSET ?
+        ) AS oldest
+    );`,
+			enrollmentID,
+			enrollmentID,
+			storage.StatusQuarantineMax,
+		)
+	}
+
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RetrieveStatusQuarantine retrieves the quarantined status report fragments of enrollmentIDs.
+// Large numbers of enrollment IDs are queried in chunks.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string) (map[string][]storage.QuarantinedStatus, error) {
+	if len(enrollmentIDs) < 1 {
+		return nil, errors.New("no enrollment IDs provided")
+	}
+	ret := make(map[string][]storage.QuarantinedStatus)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveStatusQuarantine(ctx, chunk, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (s *MySQLStorage) retrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string, ret map[string][]storage.QuarantinedStatus) error {
+	inSQL, args := inIDs("enrollment_id", enrollmentIDs)
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    enrollment_id,
+    path,
+    error,
+    fragment,
+    COALESCE(status_id, ''),
+    created_at
+FROM
+    status_quarantine
+WHERE
+    `+inSQL+`
+ORDER BY
+    id;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var enrollmentID, createdAt string
+		var q storage.QuarantinedStatus
+		var fragment []byte
+		if err = rows.Scan(&enrollmentID, &q.Path, &q.Error, &fragment, &q.StatusID, &createdAt); err != nil {
+			return err
+		}
+		if len(fragment) > 0 {
+			q.JSON = fragment
+		}
+		if q.Timestamp, err = time.Parse(mysqlTimeFormat, createdAt); err != nil {
+			return err
+		}
+		ret[enrollmentID] = append(ret[enrollmentID], q)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveEnrollmentRegistration retrieves the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentRegistration(ctx context.Context, enrollmentID string) (*storage.EnrollmentRegistration, error) {
+	r := &storage.EnrollmentRegistration{EnrollmentID: enrollmentID}
+	var metadataJSON []byte
+	var registeredAt string
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT metadata, registered_at FROM enrollment_registrations WHERE enrollment_id = ?;`,
+		enrollmentID,
+	).Scan(&metadataJSON, &registeredAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrEnrollmentRegistrationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(metadataJSON, &r.Metadata); err != nil {
+		return nil, err
+	}
+	if r.Timestamp, err = time.Parse(mysqlTimeFormat, registeredAt); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// StoreEnrollmentRegistration stores the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentRegistration(ctx context.Context, registration *storage.EnrollmentRegistration) error {
+	metadataJSON, err := json.Marshal(registration.Metadata)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO enrollment_registrations
+    (enrollment_id, metadata, registered_at)
+VALUES
+    (?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    metadata = new.metadata,
+    registered_at = new.registered_at;`,
+		registration.EnrollmentID,
+		metadataJSON,
+		registration.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// DeleteEnrollmentRegistration deletes the registration of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteEnrollmentRegistration(ctx context.Context, enrollmentID string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM enrollment_registrations WHERE enrollment_id = ?;`,
+		enrollmentID,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
@@ -0,0 +1,44 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RepairEnrollmentDDM reports that no enrollment DDM data is mismatched.
+// The declaration-items and tokens JSON are built from the set and
+// declaration associations for each request and so are never stale.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RepairEnrollmentDDM(ctx context.Context, dryRun bool) (*storage.DDMRepairReport, error) {
+	report := &storage.DDMRepairReport{Repaired: !dryRun}
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(DISTINCT enrollment_id) FROM enrollment_sets;`,
+	).Scan(&report.Enrollments)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// RefreshEnrollmentDDM does nothing as the declaration-items and tokens
+// JSON are built from the set and declaration associations for each request.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RefreshEnrollmentDDM(_ context.Context, _ string) error {
+	return nil
+}
+
+// RetrieveDDMRebuilds retrieves no enrollments as the declaration-items
+// and tokens JSON are built from the set and declaration associations
+// for each request and so never fail to be rebuilt.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveDDMRebuilds(_ context.Context) ([]storage.DDMRebuild, error) {
+	return nil, nil
+}
+
+// RetryDDMRebuilds does nothing as no enrollments fail to be rebuilt.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetryDDMRebuilds(_ context.Context) ([]string, error) {
+	return nil, nil
+}
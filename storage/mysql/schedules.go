@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// nullTime formats t for a nullable DATETIME column.
+func nullTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(mysqlTimeFormat), Valid: true}
+}
+
+// parseNullTime parses a nullable DATETIME column.
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(mysqlTimeFormat, s.String)
+	return &t, err
+}
+
+// StoreAssignmentSchedule stores schedule using its ID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreAssignmentSchedule(ctx context.Context, schedule *storage.AssignmentSchedule) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO assignment_schedules
+    (id, kind, set_name, declaration_identifier, enrollment_id, starts_at, ends_at, active)
+VALUES
+    (?, ?, ?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    kind = new.kind,
+    set_name = new.set_name,
+    declaration_identifier = new.declaration_identifier,
+    enrollment_id = new.enrollment_id,
+    starts_at = new.starts_at,
+    ends_at = new.ends_at,
+    active = new.active;`,
+		schedule.ID,
+		schedule.Kind,
+		schedule.Set,
+		sql.NullString{String: schedule.Declaration, Valid: schedule.Declaration != ""},
+		sql.NullString{String: schedule.Enrollment, Valid: schedule.Enrollment != ""},
+		nullTime(schedule.Start),
+		nullTime(schedule.End),
+		schedule.Active,
+	)
+	return err
+}
+
+// RetrieveAssignmentSchedules retrieves all assignment schedules.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveAssignmentSchedules(ctx context.Context) ([]*storage.AssignmentSchedule, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    id,
+    kind,
+    set_name,
+    declaration_identifier,
+    enrollment_id,
+    starts_at,
+    ends_at,
+    active
+FROM
+    assignment_schedules
+ORDER BY
+    id;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var schedules []*storage.AssignmentSchedule
+	for rows.Next() {
+		a := new(storage.AssignmentSchedule)
+		var declarationID, enrollmentID, start, end sql.NullString
+		if err = rows.Scan(
+			&a.ID,
+			&a.Kind,
+			&a.Set,
+			&declarationID,
+			&enrollmentID,
+			&start,
+			&end,
+			&a.Active,
+		); err != nil {
+			return nil, err
+		}
+		a.Declaration = declarationID.String
+		a.Enrollment = enrollmentID.String
+		if a.Start, err = parseNullTime(start); err != nil {
+			return nil, err
+		}
+		if a.End, err = parseNullTime(end); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, a)
+	}
+	return schedules, rows.Err()
+}
+
+// DeleteAssignmentSchedule deletes the assignment schedule with id.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteAssignmentSchedule(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM assignment_schedules WHERE id = ?;`,
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	deleted, err := resultChangedRows(result)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return storage.ErrAssignmentScheduleNotFound
+	}
+	return nil
+}
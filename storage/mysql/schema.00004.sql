@@ -0,0 +1 @@
+-- CREATE TABLE idempotency_keys ... (see schema.sql)
\ No newline at end of file
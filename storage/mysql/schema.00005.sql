@@ -0,0 +1 @@
+-- CREATE TABLE counters ... (see schema.sql)
\ No newline at end of file
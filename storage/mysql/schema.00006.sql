@@ -0,0 +1 @@
+-- CREATE TABLE status_value_history ... (see schema.sql)
\ No newline at end of file
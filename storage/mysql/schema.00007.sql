@@ -0,0 +1 @@
+-- CREATE TABLE profiles ... (see schema.sql)
\ No newline at end of file
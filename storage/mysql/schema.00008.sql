@@ -0,0 +1 @@
+-- CREATE TABLE set_snapshots ... (see schema.sql)
\ No newline at end of file
@@ -0,0 +1 @@
+-- CREATE TABLE pending_changes ... (see schema.sql)
\ No newline at end of file
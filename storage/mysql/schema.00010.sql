@@ -0,0 +1 @@
+-- CREATE TABLE usage_daily ... (see schema.sql)
\ No newline at end of file
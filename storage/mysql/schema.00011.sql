@@ -0,0 +1,3 @@
+ALTER TABLE set_declarations ADD COLUMN condition_path VARCHAR(255) NULL;
+ALTER TABLE set_declarations ADD COLUMN condition_op VARCHAR(2) NULL;
+ALTER TABLE set_declarations ADD COLUMN condition_value VARCHAR(255) NULL;
\ No newline at end of file
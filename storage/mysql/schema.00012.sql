@@ -0,0 +1 @@
+-- CREATE TABLE enrollment_freezes ... (see schema.sql)
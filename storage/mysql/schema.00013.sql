@@ -0,0 +1 @@
+-- CREATE TABLE assignment_schedules ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE status_quarantine ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE enrollment_annotations ... (see schema.sql)
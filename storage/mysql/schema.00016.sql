@@ -0,0 +1 @@
+-- CREATE TABLE set_patterns ... (see schema.sql)
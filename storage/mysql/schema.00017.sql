@@ -0,0 +1 @@
+-- CREATE TABLE journal ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE enrollment_staged_sets ... (see schema.sql)
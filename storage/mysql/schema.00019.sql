@@ -0,0 +1,2 @@
+-- CREATE TABLE lifecycle_stages ... (see schema.sql)
+-- CREATE TABLE enrollment_lifecycle_stages ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE enrollment_registrations ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE credentials ... (see schema.sql)
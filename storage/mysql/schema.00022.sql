@@ -0,0 +1,2 @@
+-- CREATE TABLE compliance_requirements ... (see schema.sql)
+-- CREATE TABLE enrollment_compliance ... (see schema.sql)
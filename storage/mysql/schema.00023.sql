@@ -0,0 +1 @@
+-- CREATE TABLE served_history ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE notification_batches ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE enrollment_notifications ... (see schema.sql)
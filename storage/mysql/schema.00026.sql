@@ -0,0 +1,6 @@
+ALTER TABLE status_errors ADD COLUMN error_hash CHAR(64) NULL;
+ALTER TABLE status_errors ADD COLUMN occurrences INT DEFAULT 1 NOT NULL;
+ALTER TABLE status_errors ADD COLUMN last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL;
+UPDATE status_errors SET last_seen_at = created_at;
+ALTER TABLE status_errors ADD UNIQUE (enrollment_id, error_hash);
+ALTER TABLE status_errors ADD INDEX (last_seen_at);
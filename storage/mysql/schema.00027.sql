@@ -0,0 +1 @@
+ALTER TABLE status_declarations ADD INDEX (declaration_identifier, valid, active);
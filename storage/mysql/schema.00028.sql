@@ -0,0 +1 @@
+ALTER TABLE status_reports ADD COLUMN status_report_gz MEDIUMBLOB NULL;
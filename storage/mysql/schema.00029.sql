@@ -0,0 +1 @@
+-- CREATE TABLE set_metadata ... (see schema.sql)
@@ -0,0 +1 @@
+-- CREATE TABLE declaration_tags ... (see schema.sql)
@@ -0,0 +1 @@
+ALTER TABLE notification_batches ADD COLUMN not_before DATETIME NULL;
@@ -0,0 +1 @@
+ALTER TABLE pending_changes ADD COLUMN if_match VARCHAR(1024) NOT NULL DEFAULT '';
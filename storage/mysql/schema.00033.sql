@@ -0,0 +1 @@
+-- CREATE TABLE owners ... (see schema.sql)
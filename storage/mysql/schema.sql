@@ -43,6 +43,11 @@ CREATE TABLE set_declarations (
     set_name               VARCHAR(255) NOT NULL,
     declaration_identifier VARCHAR(255) NOT NULL,
 
+    -- optional condition over the status values of enrollments
+    condition_path  VARCHAR(255) NULL,
+    condition_op    VARCHAR(2) NULL,
+    condition_value VARCHAR(255) NULL,
+
     PRIMARY KEY (set_name, declaration_identifier),
 
     CHECK (set_name != ''),
@@ -89,6 +94,7 @@ CREATE TABLE status_declarations (
     status_id VARCHAR(255) NULL,
 
     PRIMARY KEY (enrollment_id, declaration_identifier),
+    INDEX (declaration_identifier, valid, active),
 
     CHECK (enrollment_id != ''),
     CHECK (declaration_identifier != ''),
@@ -124,15 +130,22 @@ CREATE TABLE status_errors (
     path VARCHAR(255) NOT NULL,
     error JSON NOT NULL,
 
+    -- identifies repeated errors (of the same path and error)
+    error_hash CHAR(64) NULL,
+    occurrences INT DEFAULT 1 NOT NULL,
+
     status_id VARCHAR(255) NULL,
     row_count INT DEFAULT 0 NOT NULL,
 
     INDEX (enrollment_id),
+    UNIQUE (enrollment_id, error_hash),
 
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
     updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL,
+    last_seen_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
 
     INDEX (created_at),
+    INDEX (last_seen_at),
     INDEX (enrollment_id, row_count)
 );
 
@@ -140,6 +153,8 @@ CREATE TABLE status_reports (
     enrollment_id   VARCHAR(255) NOT NULL,
 
     status_report JSON,
+    -- gzip compressed status report in place of status_report
+    status_report_gz MEDIUMBLOB NULL,
 
     status_id VARCHAR(255) NULL,
     row_count INT DEFAULT 0 NOT NULL,
@@ -155,3 +170,443 @@ CREATE TABLE status_reports (
     INDEX (created_at),
     INDEX (enrollment_id, row_count)
 );
+
+CREATE TABLE idempotency_keys (
+    idempotency_key VARCHAR(255) NOT NULL,
+
+    -- identifies the request (method, path, query, body) that was made
+    -- with this key so re-use of a key for a different request is detected.
+    fingerprint  CHAR(64) NOT NULL,
+    status_code  INT NOT NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body         MEDIUMBLOB NULL,
+    if_match     VARCHAR(1024) NOT NULL DEFAULT '',
+
+    PRIMARY KEY (idempotency_key),
+
+    CHECK (idempotency_key != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL,
+
+    INDEX (updated_at)
+);
+
+
+CREATE TABLE counters (
+    counter_scope VARCHAR(31)  NOT NULL,
+    counter_id    VARCHAR(255) NOT NULL,
+    counter_name  VARCHAR(63)  NOT NULL,
+    counter_value BIGINT DEFAULT 0 NOT NULL,
+
+    PRIMARY KEY (counter_scope, counter_id, counter_name),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE status_value_history (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+
+    enrollment_id VARCHAR(128) NOT NULL,
+
+    path       VARCHAR(255) NOT NULL,
+    value_type VARCHAR(7)   NOT NULL, -- string|number|boolean
+    value      VARCHAR(255) NOT NULL,
+
+    status_id VARCHAR(255) NULL,
+
+    PRIMARY KEY (id),
+
+    INDEX (enrollment_id, path, id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE profiles (
+    profile_id VARCHAR(127) NOT NULL,
+    profile    MEDIUMBLOB   NOT NULL,
+
+    PRIMARY KEY (profile_id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE set_snapshots (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+
+    set_name     VARCHAR(255) NOT NULL,
+    declarations JSON         NOT NULL,
+
+    PRIMARY KEY (id),
+
+    INDEX (set_name, id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE pending_changes (
+    id        VARCHAR(127) NOT NULL,
+    principal VARCHAR(255) NOT NULL,
+
+    method       VARCHAR(16)   NOT NULL,
+    path         VARCHAR(1024) NOT NULL,
+    raw_query    TEXT          NOT NULL,
+    content_type VARCHAR(255) NOT NULL DEFAULT '',
+    body         MEDIUMBLOB NULL,
+
+    PRIMARY KEY (id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+
+    INDEX (created_at)
+);
+
+
+CREATE TABLE usage_daily (
+    day      DATE         NOT NULL,
+    set_name VARCHAR(255) NOT NULL,
+
+    syncs          BIGINT NOT NULL DEFAULT 0,
+    status_reports BIGINT NOT NULL DEFAULT 0,
+    declarations   BIGINT NOT NULL DEFAULT 0,
+
+    PRIMARY KEY (day, set_name),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE enrollment_freezes (
+    enrollment_id VARCHAR(255) NOT NULL,
+
+    tokens            MEDIUMBLOB NOT NULL,
+    declaration_items MEDIUMBLOB NOT NULL,
+    declarations      LONGBLOB   NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE assignment_schedules (
+    id       VARCHAR(127) NOT NULL,
+    kind     VARCHAR(31)  NOT NULL,
+    set_name VARCHAR(255) NOT NULL,
+
+    declaration_identifier VARCHAR(255) NULL,
+    enrollment_id          VARCHAR(255) NULL,
+
+    starts_at DATETIME NULL,
+    ends_at   DATETIME NULL,
+    active    BOOLEAN DEFAULT FALSE NOT NULL,
+
+    PRIMARY KEY (id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE status_quarantine (
+    id            BIGINT AUTO_INCREMENT NOT NULL,
+    enrollment_id VARCHAR(255) NOT NULL,
+
+    path  VARCHAR(255) NOT NULL,
+    error TEXT NOT NULL,
+
+    -- not a JSON column: the fragment failed to parse or store
+    fragment MEDIUMBLOB NULL,
+
+    status_id VARCHAR(255) NULL,
+
+    PRIMARY KEY (id),
+    INDEX (enrollment_id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_annotations (
+    enrollment_id VARCHAR(255) NOT NULL,
+
+    notes   TEXT NOT NULL,
+    owner   VARCHAR(255) NOT NULL,
+    contact VARCHAR(255) NOT NULL,
+
+    -- JSON array of strings
+    asset_tags TEXT NOT NULL,
+
+    annotated_at DATETIME NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE set_patterns (
+    set_name VARCHAR(255) NOT NULL,
+    kind     VARCHAR(31)  NOT NULL,
+    pattern  VARCHAR(255) NOT NULL,
+
+    PRIMARY KEY (set_name, kind, pattern),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE journal (
+    seq BIGINT NOT NULL AUTO_INCREMENT,
+    op  VARCHAR(63) NOT NULL,
+
+    -- JSON arrays of strings
+    declarations TEXT NOT NULL,
+    sets         TEXT NOT NULL,
+    enrollments  TEXT NOT NULL,
+
+    PRIMARY KEY (seq),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_staged_sets (
+    enrollment_id VARCHAR(255) NOT NULL,
+    set_name      VARCHAR(255) NOT NULL,
+
+    PRIMARY KEY (enrollment_id, set_name),
+
+    CHECK (enrollment_id != ''),
+    CHECK (set_name != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE lifecycle_stages (
+    stage_name VARCHAR(255) NOT NULL,
+
+    -- JSON array of set names
+    sets TEXT NOT NULL,
+
+    PRIMARY KEY (stage_name),
+
+    CHECK (stage_name != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_lifecycle_stages (
+    enrollment_id VARCHAR(255) NOT NULL,
+    stage_name    VARCHAR(255) NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    CHECK (enrollment_id != ''),
+    CHECK (stage_name != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_registrations (
+    enrollment_id VARCHAR(255) NOT NULL,
+
+    -- JSON object of strings
+    metadata TEXT NOT NULL,
+
+    registered_at DATETIME NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    CHECK (enrollment_id != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE credentials (
+    credential_name VARCHAR(255) NOT NULL,
+    content_type    VARCHAR(255) NOT NULL,
+    sha256          CHAR(64)     NOT NULL,
+    size            INTEGER      NOT NULL,
+    data            MEDIUMBLOB   NOT NULL,
+
+    stored_at DATETIME NOT NULL,
+
+    PRIMARY KEY (credential_name),
+
+    INDEX (sha256),
+
+    CHECK (credential_name != ''),
+    CHECK (content_type != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE compliance_requirements (
+    set_name VARCHAR(255) NOT NULL,
+
+    -- JSON arrays of declaration identifiers and conditions
+    declarations TEXT NOT NULL,
+    conditions   TEXT NOT NULL,
+
+    PRIMARY KEY (set_name),
+
+    CHECK (set_name != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_compliance (
+    enrollment_id VARCHAR(255) NOT NULL,
+    compliant     BOOLEAN      NOT NULL,
+    met           INTEGER      NOT NULL,
+    total         INTEGER      NOT NULL,
+    score         DOUBLE       NOT NULL,
+
+    -- JSON array of compliance failures
+    failures TEXT NOT NULL,
+
+    evaluated_at DATETIME NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    INDEX (compliant),
+
+    CHECK (enrollment_id != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE served_history (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+
+    enrollment_id VARCHAR(255) NOT NULL,
+    kind          VARCHAR(31)  NOT NULL, -- tokens|declaration-items
+
+    -- hex-encoded SHA-256 hash of the served document
+    hash CHAR(64)   NOT NULL,
+    body MEDIUMBLOB NULL,
+
+    served_at DATETIME NOT NULL,
+
+    PRIMARY KEY (id),
+
+    INDEX (enrollment_id, kind, id),
+
+    CHECK (enrollment_id != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE notification_batches (
+    seq BIGINT NOT NULL AUTO_INCREMENT,
+
+    id       VARCHAR(255) NOT NULL,
+    priority VARCHAR(15)  NOT NULL,
+
+    -- JSON array of enrollment IDs
+    enrollment_ids MEDIUMTEXT NOT NULL,
+
+    queued_at DATETIME NOT NULL,
+
+    -- when a batch deferred to a notification window may be notified
+    not_before DATETIME NULL,
+
+    PRIMARY KEY (seq),
+
+    UNIQUE (id),
+
+    CHECK (id != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE enrollment_notifications (
+    enrollment_id VARCHAR(255) NOT NULL,
+
+    -- hash of the tokens of the enrollment when notified
+    tokens_hash VARCHAR(255) NOT NULL,
+
+    notified_at DATETIME NOT NULL,
+
+    PRIMARY KEY (enrollment_id),
+
+    CHECK (enrollment_id != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE set_metadata (
+    set_name VARCHAR(255) NOT NULL,
+
+    description TEXT NOT NULL,
+    owner       VARCHAR(255) NOT NULL,
+    color       VARCHAR(7) NOT NULL,
+
+    -- JSON array of strings
+    tags TEXT NOT NULL,
+
+    described_at DATETIME NOT NULL,
+
+    PRIMARY KEY (set_name),
+
+    CHECK (set_name != ''),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE declaration_tags (
+    declaration_identifier VARCHAR(255) NOT NULL,
+    tag                    VARCHAR(255) NOT NULL,
+
+    PRIMARY KEY (declaration_identifier, tag),
+    INDEX (tag),
+
+    CHECK (tag != ''),
+
+    FOREIGN KEY (declaration_identifier)
+        REFERENCES declarations (identifier)
+        ON DELETE CASCADE,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+
+CREATE TABLE owners (
+    kind VARCHAR(31) NOT NULL,
+    id   VARCHAR(255) NOT NULL,
+
+    principal VARCHAR(255) NOT NULL,
+
+    PRIMARY KEY (kind, id),
+    INDEX (kind, principal),
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP NOT NULL
+);
@@ -0,0 +1,142 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreServedDocument records doc as served to enrollmentID if it changed.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreServedDocument(ctx context.Context, enrollmentID string, doc *storage.ServedDocument, max int) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var lastHash string
+	err = tx.QueryRowContext(
+		ctx, `
+SELECT
+    hash
+FROM
+    served_history
+WHERE
+    enrollment_id = ? AND
+    kind = ?
+ORDER BY
+    id DESC
+LIMIT 1;`,
+		enrollmentID,
+		doc.Kind,
+	).Scan(&lastHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	} else if err == nil && lastHash == doc.Hash {
+		// unchanged
+		return false, tx.Rollback()
+	}
+
+	if err == nil {
+		var body []byte
+		if len(doc.Body) > 0 {
+			body = doc.Body
+		}
+		_, err = tx.ExecContext(
+			ctx, `
+INSERT INTO served_history
+    (enrollment_id, kind, hash, body, served_at)
+VALUES
+    (?, ?, ?, ?, ?);`,
+			enrollmentID,
+			doc.Kind,
+			doc.Hash,
+			body,
+			doc.Timestamp.UTC().Format(mysqlTimeFormat),
+		)
+	}
+
+	if err == nil && max > 0 {
+		// the derived table works around MySQL not allowing a
+		// subquery to select from the table being deleted from
+		_, err = tx.ExecContext(
+			ctx, `
+DELETE FROM
+    served_history
+WHERE
+    enrollment_id = ? AND
+    kind = ? AND
+    id <= (
+        SELECT id FROM (
+            SELECT id
+            FROM served_history
+            WHERE enrollment_id = ? AND kind = ?
+            ORDER BY id DESC
+            LIMIT 1 OFFSET ?
+        ) AS oldest
+    );`,
+			enrollmentID,
+			doc.Kind,
+			enrollmentID,
+			doc.Kind,
+			max,
+		)
+	}
+
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return false, fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// RetrieveServedHistory retrieves the documents served to enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveServedHistory(ctx context.Context, enrollmentID, kind string) ([]storage.ServedDocument, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    kind,
+    hash,
+    body,
+    served_at
+FROM
+    served_history
+WHERE
+    enrollment_id = ? AND
+    (? = '' OR kind = ?)
+ORDER BY
+    id DESC;`,
+		enrollmentID,
+		kind,
+		kind,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []storage.ServedDocument
+	for rows.Next() {
+		var doc storage.ServedDocument
+		var body []byte
+		var servedAt string
+		if err = rows.Scan(&doc.Kind, &doc.Hash, &body, &servedAt); err != nil {
+			return nil, err
+		}
+		if len(body) > 0 {
+			doc.Body = body
+		}
+		if doc.Timestamp, err = time.Parse(mysqlTimeFormat, servedAt); err != nil {
+			return nil, err
+		}
+		ret = append(ret, doc)
+	}
+	return ret, rows.Err()
+}
@@ -0,0 +1,104 @@
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StoreSetMetadata stores the metadata of a set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreSetMetadata(ctx context.Context, metadata *storage.SetMetadata) error {
+	tagsJSON, err := json.Marshal(metadata.Tags)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(
+		ctx, `
+INSERT INTO set_metadata
+    (set_name, description, owner, color, tags, described_at)
+VALUES
+    (?, ?, ?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    description = new.description,
+    owner = new.owner,
+    color = new.color,
+    tags = new.tags,
+    described_at = new.described_at;`,
+		metadata.SetName,
+		metadata.Description,
+		metadata.Owner,
+		metadata.Color,
+		tagsJSON,
+		metadata.Timestamp.UTC().Format(mysqlTimeFormat),
+	)
+	return err
+}
+
+// RetrieveSetMetadata retrieves the metadata of sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetMetadata(ctx context.Context, setNames []string) (map[string]*storage.SetMetadata, error) {
+	ret := make(map[string]*storage.SetMetadata)
+	for _, chunk := range storage.ChunkStrings(setNames, maxInParams) {
+		if err := s.retrieveSetMetadata(ctx, chunk, ret); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+func (s *MySQLStorage) retrieveSetMetadata(ctx context.Context, setNames []string, ret map[string]*storage.SetMetadata) error {
+	inSQL, args := inIDs("set_name", setNames)
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    set_name,
+    description,
+    owner,
+    color,
+    tags,
+    described_at
+FROM
+    set_metadata
+WHERE
+    `+inSQL+`;`,
+		args...,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		m := new(storage.SetMetadata)
+		var tagsJSON []byte
+		var describedAt string
+		if err = rows.Scan(&m.SetName, &m.Description, &m.Owner, &m.Color, &tagsJSON, &describedAt); err != nil {
+			return err
+		}
+		if err = json.Unmarshal(tagsJSON, &m.Tags); err != nil {
+			return err
+		}
+		if m.Timestamp, err = time.Parse(mysqlTimeFormat, describedAt); err != nil {
+			return err
+		}
+		ret[m.SetName] = m
+	}
+	return rows.Err()
+}
+
+// DeleteSetMetadata deletes the metadata of a set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) DeleteSetMetadata(ctx context.Context, setName string) (bool, error) {
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM set_metadata WHERE set_name = ?;`,
+		setName,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
@@ -0,0 +1,106 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// enrollmentSetsSQL returns an SQL condition on the set name column
+// that matches the explicit sets of enrollmentID and the sets with a
+// pattern matching enrollmentID.
+func (s *MySQLStorage) enrollmentSetsSQL(ctx context.Context, column, enrollmentID string) (string, []interface{}, error) {
+	patterns, err := s.RetrieveSetPatterns(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	compiled, err := storage.CompileSetPatterns(patterns)
+	if err != nil {
+		return "", nil, err
+	}
+	cond := column + ` IN (SELECT set_name FROM enrollment_sets WHERE enrollment_id = ?)`
+	args := []interface{}{enrollmentID}
+	if sets := compiled.Sets(enrollmentID); len(sets) > 0 {
+		inSQL, inArgs := inIDs(column, sets)
+		cond = `(` + cond + ` OR ` + inSQL + `)`
+		args = append(args, inArgs...)
+	}
+	return cond, args, nil
+}
+
+// RetrieveSetPatterns retrieves the patterns of all sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetPatterns(ctx context.Context) ([]storage.SetPattern, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    set_name,
+    kind,
+    pattern
+FROM
+    set_patterns
+ORDER BY
+    set_name, kind, pattern;`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var patterns []storage.SetPattern
+	for rows.Next() {
+		var p storage.SetPattern
+		if err = rows.Scan(&p.Set, &p.Kind, &p.Pattern); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// StoreSetPattern adds a pattern to its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreSetPattern(ctx context.Context, pattern *storage.SetPattern) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalStoreSetPattern, nil, []string{pattern.Set}, nil); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO set_patterns
+    (set_name, kind, pattern)
+VALUES
+    (?, ?, ?)
+ON DUPLICATE KEY
+UPDATE
+    set_name = set_name;`,
+		pattern.Set,
+		pattern.Kind,
+		pattern.Pattern,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// RemoveSetPattern removes a pattern from its set.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RemoveSetPattern(ctx context.Context, pattern *storage.SetPattern) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalRemoveSetPattern, nil, []string{pattern.Set}, nil); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+DELETE FROM set_patterns
+WHERE
+    set_name = ? AND
+    kind = ? AND
+    pattern = ?;`,
+		pattern.Set,
+		pattern.Kind,
+		pattern.Pattern,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
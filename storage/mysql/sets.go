@@ -2,6 +2,9 @@ package mysql
 
 import (
 	"context"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/storage"
 )
 
 // RetrieveSetDeclarations retrieves the list of declarations a set is associated with.
@@ -17,6 +20,9 @@ func (s *MySQLStorage) RetrieveSetDeclarations(ctx context.Context, setName stri
 // StoreSetDeclaration creates the association between a declaration and a set.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) StoreSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalStoreSetDeclaration, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
 	result, err := s.db.ExecContext(
 		ctx, `
 INSERT INTO set_declarations
@@ -30,14 +36,23 @@ UPDATE
 		setName,
 	)
 	if err != nil {
-		return false, err
+		return false, categorizeError(err)
 	}
-	return resultChangedRows(result)
+	changed, err := resultChangedRows(result)
+	if err == nil && changed {
+		if err = s.recordSetChange(ctx, setName, declarationID, true); err != nil {
+			err = fmt.Errorf("recording set snapshot: %w", err)
+		}
+	}
+	return changed, err
 }
 
 // RemoveSetDeclaration removes the association between a declaration and a set.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) RemoveSetDeclaration(ctx context.Context, setName, declarationID string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalRemoveSetDeclaration, []string{declarationID}, []string{setName}, nil); err != nil {
+		return false, err
+	}
 	result, err := s.db.ExecContext(
 		ctx, `
 DELETE FROM set_declarations
@@ -50,7 +65,13 @@ WHERE
 	if err != nil {
 		return false, err
 	}
-	return resultChangedRows(result)
+	changed, err := resultChangedRows(result)
+	if err == nil && changed {
+		if err = s.recordSetChange(ctx, setName, declarationID, false); err != nil {
+			err = fmt.Errorf("recording set snapshot: %w", err)
+		}
+	}
+	return changed, err
 }
 
 // RetrieveSets retrieves the list of sets.
@@ -61,3 +82,14 @@ func (s *MySQLStorage) RetrieveSets(ctx context.Context) ([]string, error) {
 		`SELECT DISTINCT set_name FROM set_declarations;`,
 	)
 }
+
+// RetrieveSetsPage retrieves a page of the sorted list of sets.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetsPage(ctx context.Context, after string, limit int) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT DISTINCT set_name FROM set_declarations WHERE set_name > ? ORDER BY set_name LIMIT ?;`,
+		after,
+		limit,
+	)
+}
@@ -0,0 +1,244 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// recordSetSnapshot records current as the declarations of setName.
+// If the set has no snapshots yet then before (if not empty) is
+// recorded first so that the change can be undone.
+func recordSetSnapshot(ctx context.Context, q querier, setName string, current, before []string) error {
+	var count int
+	err := q.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM set_snapshots WHERE set_name = ?;`,
+		setName,
+	).Scan(&count)
+	if err != nil {
+		return err
+	}
+	lists := [][]string{current}
+	if count < 1 && len(before) > 0 {
+		lists = [][]string{before, current}
+	}
+	for _, list := range lists {
+		if list == nil {
+			// encode as an empty JSON array
+			list = []string{}
+		}
+		listJSON, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		_, err = q.ExecContext(
+			ctx,
+			`INSERT INTO set_snapshots (set_name, declarations) VALUES (?, ?);`,
+			setName,
+			listJSON,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	// the derived table works around MySQL not allowing a subquery to
+	// select from the table being deleted from
+	_, err = q.ExecContext(
+		ctx, `
+DELETE FROM
+    set_snapshots
+WHERE
+    set_name = ? AND
+    id <= (
+        SELECT id FROM (
+            SELECT id
+            FROM set_snapshots
+            WHERE set_name = ?
+            ORDER BY id DESC
+            LIMIT 1 OFFSET ?
+        ) AS oldest
+    );`,
+		setName,
+		setName,
+		storage.MaxSetSnapshots,
+	)
+	return err
+}
+
+// recordSetChange records a snapshot of setName after declarationID
+// was added to or removed from it.
+func (s *MySQLStorage) recordSetChange(ctx context.Context, setName, declarationID string, added bool) error {
+	current, err := singleStringColumn(
+		ctx,
+		s.db,
+		`SELECT declaration_identifier FROM set_declarations WHERE set_name = ?;`,
+		setName,
+	)
+	if err != nil {
+		return err
+	}
+	var before []string
+	for _, id := range current {
+		if id != declarationID {
+			before = append(before, id)
+		}
+	}
+	if !added {
+		before = append(before, declarationID)
+	}
+	return recordSetSnapshot(ctx, s.db, setName, current, before)
+}
+
+// RetrieveSetSnapshots retrieves the snapshots of setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetSnapshots(ctx context.Context, setName string) ([]storage.SetSnapshot, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    id,
+    declarations,
+    created_at
+FROM
+    set_snapshots
+WHERE
+    set_name = ?
+ORDER BY
+    id DESC;`,
+		setName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var snapshots []storage.SetSnapshot
+	for rows.Next() {
+		var id int64
+		var listJSON []byte
+		var dbTimestamp string
+		if err = rows.Scan(&id, &listJSON, &dbTimestamp); err != nil {
+			return nil, err
+		}
+		snapshot := storage.SetSnapshot{ID: strconv.FormatInt(id, 10)}
+		if err = json.Unmarshal(listJSON, &snapshot.Declarations); err != nil {
+			return nil, fmt.Errorf("unmarshal snapshot declarations: %w", err)
+		}
+		snapshot.Timestamp, _ = time.Parse(mysqlTimeFormat, dbTimestamp)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// RestoreSetSnapshot replaces the declarations of setName with those of a snapshot.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error) {
+	id, err := strconv.ParseInt(snapshotID, 10, 64)
+	if err != nil {
+		return false, storage.ErrSetSnapshotNotFound
+	}
+	if err = s.appendJournal(ctx, storage.JournalRestoreSetSnapshot, nil, []string{setName}, nil); err != nil {
+		return false, err
+	}
+
+	var listJSON []byte
+	err = s.db.QueryRowContext(
+		ctx,
+		`SELECT declarations FROM set_snapshots WHERE set_name = ? AND id = ?;`,
+		setName,
+		id,
+	).Scan(&listJSON)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, storage.ErrSetSnapshotNotFound
+	} else if err != nil {
+		return false, err
+	}
+	var declarationIDs []string
+	if err = json.Unmarshal(listJSON, &declarationIDs); err != nil {
+		return false, fmt.Errorf("unmarshal snapshot declarations: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := restoreSetDeclarations(ctx, tx, setName, declarationIDs)
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return false, fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return false, err
+	}
+
+	return changed, tx.Commit()
+}
+
+// restoreSetDeclarations replaces the declarations of setName with
+// declarationIDs and records a snapshot if the set changed.
+func restoreSetDeclarations(ctx context.Context, tx *sql.Tx, setName string, declarationIDs []string) (bool, error) {
+	before, err := singleStringColumn(
+		ctx,
+		tx,
+		`SELECT declaration_identifier FROM set_declarations WHERE set_name = ? FOR UPDATE;`,
+		setName,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	args := []interface{}{setName}
+	notInSQL := ""
+	if len(declarationIDs) > 0 {
+		notInSQL = ` AND declaration_identifier NOT IN (` + strings.Repeat(", ?", len(declarationIDs))[2:] + `)`
+		for _, id := range declarationIDs {
+			args = append(args, id)
+		}
+	}
+	result, err := tx.ExecContext(
+		ctx,
+		`DELETE FROM set_declarations WHERE set_name = ?`+notInSQL+`;`,
+		args...,
+	)
+	if err != nil {
+		return false, err
+	}
+	changed, err := resultChangedRows(result)
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range declarationIDs {
+		result, err = tx.ExecContext(
+			ctx, `
+INSERT INTO set_declarations
+    (declaration_identifier, set_name)
+VALUES
+    (?, ?)
+ON DUPLICATE KEY
+UPDATE
+    set_name = set_name;`,
+			id,
+			setName,
+		)
+		if err != nil {
+			return false, fmt.Errorf("restoring declaration %s: %w", id, err)
+		}
+		var added bool
+		if added, err = resultChangedRows(result); err != nil {
+			return false, err
+		}
+		changed = changed || added
+	}
+
+	if !changed {
+		return false, nil
+	}
+	return true, recordSetSnapshot(ctx, tx, setName, declarationIDs, before)
+}
@@ -0,0 +1,120 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveEnrollmentStagedSets retrieves the staged sets of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveEnrollmentStagedSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	return s.singleStringColumn(
+		ctx,
+		`SELECT set_name FROM enrollment_staged_sets WHERE enrollment_id = ?;`,
+		enrollmentID,
+	)
+}
+
+// StoreEnrollmentStagedSet stages a set for an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalStoreEnrollmentStagedSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO enrollment_staged_sets
+    (enrollment_id, set_name)
+VALUES
+    (?, ?)
+ON DUPLICATE KEY
+UPDATE
+    enrollment_id = enrollment_id;`,
+		enrollmentID,
+		setName,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// RemoveEnrollmentStagedSet removes a staged set of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RemoveEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalRemoveEnrollmentStagedSet, nil, []string{setName}, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	result, err := s.db.ExecContext(
+		ctx, `
+DELETE FROM enrollment_staged_sets
+WHERE
+    enrollment_id = ? AND
+    set_name = ?;`,
+		enrollmentID,
+		setName,
+	)
+	if err != nil {
+		return false, err
+	}
+	return resultChangedRows(result)
+}
+
+// insertEnrollmentSets inserts the sets of enrollmentID into table.
+func insertEnrollmentSets(ctx context.Context, q querier, table, enrollmentID string, setNames []string) error {
+	if len(setNames) < 1 {
+		return nil
+	}
+	vals := make([]interface{}, len(setNames)*2)
+	for i, setName := range setNames {
+		vals[i*2] = enrollmentID
+		vals[i*2+1] = setName
+	}
+	_, err := q.ExecContext(
+		ctx,
+		`INSERT INTO `+table+` (enrollment_id, set_name) VALUES`+strings.Repeat(", (?, ?)", len(setNames))[1:]+`;`,
+		vals...,
+	)
+	return err
+}
+
+// SwapEnrollmentStagedSets exchanges the sets and staged sets of an enrollment.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) SwapEnrollmentStagedSets(ctx context.Context, enrollmentID string) (bool, error) {
+	if err := s.appendJournal(ctx, storage.JournalSwapEnrollmentStagedSets, nil, nil, []string{enrollmentID}); err != nil {
+		return false, err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	var current, staged []string
+	current, err = singleStringColumn(ctx, tx, `SELECT set_name FROM enrollment_sets WHERE enrollment_id = ? ORDER BY set_name FOR UPDATE;`, enrollmentID)
+	if err == nil {
+		staged, err = singleStringColumn(ctx, tx, `SELECT set_name FROM enrollment_staged_sets WHERE enrollment_id = ? ORDER BY set_name FOR UPDATE;`, enrollmentID)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM enrollment_sets WHERE enrollment_id = ?;`, enrollmentID)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM enrollment_staged_sets WHERE enrollment_id = ?;`, enrollmentID)
+	}
+	if err == nil {
+		err = insertEnrollmentSets(ctx, tx, "enrollment_sets", enrollmentID, staged)
+	}
+	if err == nil {
+		err = insertEnrollmentSets(ctx, tx, "enrollment_staged_sets", enrollmentID, current)
+	}
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return false, fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return false, err
+	}
+	// both are ordered by set name
+	changed := strings.Join(current, "\n") != strings.Join(staged, "\n")
+	return changed, tx.Commit()
+}
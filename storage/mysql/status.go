@@ -13,12 +13,12 @@ import (
 	"github.com/jessepeterson/kmfddm/storage"
 )
 
-func (s *MySQLStorage) storeStatusDeclarations(ctx context.Context, enrollmentID, statusID string, declarations []ddm.DeclarationStatus) error {
+func (s *MySQLStorage) storeStatusDeclarations(ctx context.Context, enrollmentID, statusID, now string, declarations []ddm.DeclarationStatus) error {
 	if len(declarations) < 1 {
 		return nil
 	}
-	argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(declarations))[1:]
-	const argLen = 8
+	argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", len(declarations))[1:]
+	const argLen = 10
 	args := make([]interface{}, len(declarations)*argLen)
 	for i, d := range declarations {
 		args[i*argLen] = enrollmentID
@@ -32,8 +32,15 @@ func (s *MySQLStorage) storeStatusDeclarations(ctx context.Context, enrollmentID
 			String: statusID,
 			Valid:  len(statusID) > 0,
 		}
+		args[i*argLen+8] = now
+		args[i*argLen+9] = now
 	}
-	_, err := s.db.ExecContext(
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(
 		ctx, `
 INSERT INTO status_declarations
     (
@@ -44,7 +51,9 @@ INSERT INTO status_declarations
         valid,
         server_token,
         reasons,
-        status_id
+        status_id,
+        created_at,
+        updated_at
     )
 VALUES
     `+argSQL+` AS new
@@ -55,19 +64,42 @@ UPDATE
     valid = new.valid,
     server_token = new.server_token,
     reasons = new.reasons,
-    status_id = new.status_id;`,
+    status_id = new.status_id,
+    updated_at = new.updated_at;`,
 		args...,
 	)
 
-	return err
+	if err == nil {
+		// the report lists all of the enrollment's declarations so
+		// remove the status of any declarations no longer reported.
+		idArgs := make([]interface{}, 1, len(declarations)+1)
+		idArgs[0] = enrollmentID
+		for _, d := range declarations {
+			idArgs = append(idArgs, d.Identifier)
+		}
+		_, err = tx.ExecContext(
+			ctx,
+			`DELETE FROM status_declarations WHERE enrollment_id = ? AND declaration_identifier NOT IN (`+strings.Repeat(", ?", len(declarations))[2:]+`);`,
+			idArgs...,
+		)
+	}
+
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("rollback error: %w; while trying to handle error: %v", rbErr, err)
+		}
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (s *MySQLStorage) storeStatusValues(ctx context.Context, enrollmentID, statusID string, values []ddm.StatusValue) error {
+func (s *MySQLStorage) storeStatusValues(ctx context.Context, enrollmentID, statusID, now string, values []ddm.StatusValue) error {
 	if len(values) < 1 {
 		return nil
 	}
-	argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?)", len(values))[2:]
-	const argLen = 6
+	argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(values))[2:]
+	const argLen = 8
 	args := make([]interface{}, len(values)*argLen)
 	for i, v := range values {
 		args[i*argLen] = enrollmentID
@@ -79,6 +111,8 @@ func (s *MySQLStorage) storeStatusValues(ctx context.Context, enrollmentID, stat
 			String: statusID,
 			Valid:  len(statusID) > 0,
 		}
+		args[i*argLen+6] = now
+		args[i*argLen+7] = now
 	}
 	_, err := s.db.ExecContext(
 		ctx, `
@@ -89,20 +123,22 @@ INSERT INTO status_values
         container_type,
         value_type,
         value,
-        status_id
+        status_id,
+        created_at,
+        updated_at
     )
 VALUES
     `+argSQL+` as new
 ON DUPLICATE KEY
 UPDATE
-    updated_at = CURRENT_TIMESTAMP,
+    updated_at = new.updated_at,
     status_id = new.status_id;`,
 		args...,
 	)
 	return err
 }
 
-func (s *MySQLStorage) storeStatusErrors(ctx context.Context, enrollmentID, statusID string, errors []ddm.StatusError) error {
+func (s *MySQLStorage) storeStatusErrors(ctx context.Context, enrollmentID, statusID, now string, errors []ddm.StatusError) error {
 	if len(errors) < 1 {
 		return nil
 	}
@@ -119,17 +155,23 @@ func (s *MySQLStorage) storeStatusErrors(ctx context.Context, enrollmentID, stat
 	)
 
 	if err == nil {
-		argSQL := strings.Repeat(", (?, ?, ?, ?)", len(errors))[2:]
-		const argLen = 4
+		// errors already stored (with the same path and error) are
+		// counted and their last seen time updated
+		argSQL := strings.Repeat(", (?, ?, ?, ?, ?, ?, ?, ?)", len(errors))[2:]
+		const argLen = 8
 		args := make([]interface{}, len(errors)*argLen)
 		for i, e := range errors {
 			args[i*argLen] = enrollmentID
 			args[i*argLen+1] = e.Path
 			args[i*argLen+2] = e.ErrorJSON
-			args[i*argLen+3] = sql.NullString{
+			args[i*argLen+3] = storage.StatusErrorHash(e.Path, e.ErrorJSON)
+			args[i*argLen+4] = sql.NullString{
 				String: statusID,
 				Valid:  len(statusID) > 0,
 			}
+			args[i*argLen+5] = now
+			args[i*argLen+6] = now
+			args[i*argLen+7] = now
 		}
 		_, err = tx.ExecContext(
 			ctx, `
@@ -138,10 +180,20 @@ INSERT INTO status_errors
         enrollment_id,
         path,
         error,
-        status_id
+        error_hash,
+        status_id,
+        created_at,
+        updated_at,
+        last_seen_at
     )
 VALUES
-    `+argSQL+`;`,
+    `+argSQL+` AS new
+ON DUPLICATE KEY
+UPDATE
+    status_id = new.status_id,
+    last_seen_at = new.last_seen_at,
+    occurrences = status_errors.occurrences + 1,
+    row_count = 0;`,
 			args...,
 		)
 	}
@@ -165,7 +217,7 @@ VALUES
 	return tx.Commit()
 }
 
-func (s *MySQLStorage) storeStatusReport(ctx context.Context, enrollmentID, statusID string, raw []byte) error {
+func (s *MySQLStorage) storeStatusReport(ctx context.Context, enrollmentID, statusID, now string, raw []byte) error {
 	if len(raw) < 1 {
 		return errors.New("empty raw status report")
 	}
@@ -181,6 +233,14 @@ func (s *MySQLStorage) storeStatusReport(ctx context.Context, enrollmentID, stat
 		enrollmentID,
 	)
 
+	// compressed status reports are stored in place of the JSON
+	var rawGz []byte
+	if err == nil && s.compress {
+		if rawGz, err = storage.CompressJSON(raw); err == nil {
+			raw = nil
+		}
+	}
+
 	if err == nil {
 		_, err = tx.ExecContext(
 			ctx, `
@@ -188,13 +248,19 @@ INSERT INTO status_reports
     (
         enrollment_id,
         status_id,
-        status_report
+        status_report,
+        status_report_gz,
+        created_at,
+        updated_at
     )
 VALUES
-    (?, ?, ?);`,
+    (?, ?, ?, ?, ?, ?);`,
 			enrollmentID,
 			statusID,
 			raw,
+			rawGz,
+			now,
+			now,
 		)
 	}
 
@@ -217,42 +283,114 @@ VALUES
 	return tx.Commit()
 }
 
+// deleteOldestStatusRows deletes the oldest rows (by orderBy) of
+// enrollmentID from table so that at most max rows remain. Zero max is
+// unlimited. The number of deleted rows is returned.
+func (s *MySQLStorage) deleteOldestStatusRows(ctx context.Context, table, orderBy, enrollmentID string, max int) (int, error) {
+	if max < 1 {
+		return 0, nil
+	}
+	var count int
+	err := s.db.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM `+table+` WHERE enrollment_id = ?;`,
+		enrollmentID,
+	).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	excess := storage.Excess(count, max)
+	if excess < 1 {
+		return 0, nil
+	}
+	result, err := s.db.ExecContext(
+		ctx,
+		`DELETE FROM `+table+` WHERE enrollment_id = ? ORDER BY `+orderBy+` LIMIT ?;`,
+		enrollmentID,
+		excess,
+	)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
+
 // StoreDeclarationStatus stores the status report from enrollmentID.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error {
-	err := s.storeStatusReport(ctx, enrollmentID, status.ID, status.Raw)
+	s.limits.TruncateReport(status)
+	now := s.clock.Now().UTC().Format(mysqlTimeFormat)
+	err := s.storeStatusReport(ctx, enrollmentID, status.ID, now, status.Raw)
 	if err != nil {
 		return fmt.Errorf("storing status report: %w", err)
 	}
-	err = s.storeStatusDeclarations(ctx, enrollmentID, status.ID, status.Declarations)
+	// the parsed sections are stored independently so that a failure
+	// of one quarantines it rather than failing the status report.
+	err = s.storeStatusDeclarations(ctx, enrollmentID, status.ID, now, status.Declarations)
+	if err != nil {
+		storage.QuarantineStatusSection(status, ddm.StatusPathDeclarations, status.Declarations, fmt.Errorf("storing declaration status: %w", err))
+	}
+	err = s.storeStatusValues(ctx, enrollmentID, status.ID, now, status.Values)
 	if err != nil {
-		return fmt.Errorf("storing declaration status: %w", err)
+		storage.QuarantineStatusSection(status, ddm.StatusPathItems, status.Values, fmt.Errorf("storing status values: %w", err))
+	} else if len(status.Values) > 0 {
+		// re-reported values have their updated_at bumped
+		dropped, err := s.deleteOldestStatusRows(ctx, "status_values", "updated_at, path, value", enrollmentID, s.limits.MaxValues())
+		status.DroppedValues += dropped
+		if err != nil {
+			storage.QuarantineStatusSection(status, ddm.StatusPathItems, nil, fmt.Errorf("limiting status values: %w", err))
+		}
 	}
-	err = s.storeStatusValues(ctx, enrollmentID, status.ID, status.Values)
+	err = s.storeStatusErrors(ctx, enrollmentID, status.ID, now, status.Errors)
 	if err != nil {
-		return fmt.Errorf("storing status values: %w", err)
+		storage.QuarantineStatusSection(status, ddm.StatusPathErrors, status.Errors, fmt.Errorf("storing status errors: %w", err))
+	} else if len(status.Errors) > 0 {
+		// row_count is incremented for the older errors of each report
+		dropped, err := s.deleteOldestStatusRows(ctx, "status_errors", "row_count DESC, last_seen_at", enrollmentID, s.limits.MaxErrors())
+		status.DroppedErrors += dropped
+		if err != nil {
+			storage.QuarantineStatusSection(status, ddm.StatusPathErrors, nil, fmt.Errorf("limiting status errors: %w", err))
+		}
 	}
-	err = s.storeStatusErrors(ctx, enrollmentID, status.ID, status.Errors)
+	historyValues := s.history.Values(status)
+	err = s.storeStatusValueHistory(ctx, enrollmentID, status.ID, now, historyValues)
 	if err != nil {
-		return fmt.Errorf("storing status errors: %w", err)
+		storage.QuarantineStatusSection(status, ddm.StatusPathItems, historyValues, fmt.Errorf("storing status value history: %w", err))
+	}
+	err = s.storeStatusQuarantine(ctx, enrollmentID, status.ID, now, status.Quarantined)
+	if err != nil {
+		return fmt.Errorf("storing status quarantine: %w", err)
 	}
 	return nil
 }
 
 // RetrieveDeclarationStatus retrieves the status of declarations for enrollmentIDs.
+// Large numbers of enrollment IDs are queried in chunks.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) RetrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
 	if len(enrollmentIDs) < 1 {
 		return nil, errors.New("no enrollment IDs provided")
 	}
+	resp := make(map[string][]ddm.DeclarationQueryStatus)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveDeclarationStatus(ctx, chunk, resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (s *MySQLStorage) retrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string, resp map[string][]ddm.DeclarationQueryStatus) error {
 	idSQL := strings.Repeat(", ?", len(enrollmentIDs))[2:]
 	valSQL := make([]interface{}, len(enrollmentIDs))
 	for i, id := range enrollmentIDs {
 		valSQL[i] = id
 	}
-	// intent is to query only the declaration statuses from the given
-	// enrollment ids and only those that are currently actively
-	// enabled and managed via an enrollment's configured sets
+	// query all of the declaration statuses reported by the given
+	// enrollment ids and mark those that are no longer enabled and
+	// managed via an enrollment's configured sets as unassigned. the
+	// reported identifier may also no longer exist in declarations.
 	rows, err := s.db.QueryContext(
 		ctx, `
 SELECT
@@ -263,32 +401,39 @@ SELECT
     COALESCE(statusd.reasons, 'null'),
     statusd.server_token,
     statusd.updated_at,
-    statusd.server_token = d.server_token AS current,
-    statusd.status_id
+    COALESCE(statusd.server_token = d.server_token, FALSE) AS current,
+    statusd.status_id,
+    EXISTS (
+        SELECT 1
+        FROM
+            set_declarations sd
+            INNER JOIN enrollment_sets es
+                ON sd.set_name = es.set_name
+        WHERE
+            sd.declaration_identifier = statusd.declaration_identifier AND
+            es.enrollment_id = statusd.enrollment_id
+    ) AS assigned
 FROM
     status_declarations statusd
-    INNER JOIN declarations d
+    LEFT JOIN declarations d
         ON statusd.declaration_identifier = d.identifier
-    INNER JOIN set_declarations sd
-        ON d.identifier = sd.declaration_identifier
-    INNER JOIN enrollment_sets es
-        ON sd.set_name = es.set_name AND statusd.enrollment_id = es.enrollment_id
 WHERE
     statusd.enrollment_id IN (`+idSQL+`)
 ORDER BY
-    statusd.enrollment_id;`,
+    statusd.enrollment_id,
+    statusd.declaration_identifier;`,
 		valSQL...,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	resp := make(map[string][]ddm.DeclarationQueryStatus)
 	defer rows.Close()
 	for rows.Next() {
 		var id, updatedAt string
 		var reasonJSON []byte
 		var status ddm.DeclarationQueryStatus
 		var statusID sql.NullString
+		var assigned bool
 		err = rows.Scan(
 			&id,
 			&status.Identifier,
@@ -299,11 +444,16 @@ ORDER BY
 			&updatedAt,
 			&status.Current,
 			&statusID,
+			&assigned,
 		)
 		if err != nil {
 			break
 		}
 		status.StatusID = statusID.String
+		if !assigned {
+			status.Unassigned = true
+			status.Current = false
+		}
 		status.StatusReceived, err = time.Parse(mysqlTimeFormat, updatedAt)
 		if err != nil {
 			break
@@ -320,64 +470,123 @@ ORDER BY
 	if err == nil {
 		err = rows.Err()
 	}
-	return resp, err
+	return err
+}
+
+// RetrievePendingRemovals retrieves the unassigned declarations last reported as active for enrollmentIDs.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error) {
+	statuses, err := s.RetrieveDeclarationStatus(ctx, enrollmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PendingRemovals(statuses), nil
 }
 
 // RetrieveStatusErrors retrieves the reported status errors for enrollmentIDs.
+// Large numbers of enrollment IDs are queried in chunks.
 // See also the storage package for documentation on the storage interfaces.
-func (s *MySQLStorage) RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, offset, limit int) (map[string][]storage.StatusError, error) {
+func (s *MySQLStorage) RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]storage.StatusError, error) {
+	resp := make(map[string][]storage.StatusError)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveStatusErrors(ctx, chunk, since, until, offset, limit, resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// retrieveStatusErrors retrieves the status errors of enrollmentIDs
+// into resp. The offset and limit apply to the errors of each
+// enrollment ordered by when they were last seen.
+func (s *MySQLStorage) retrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int, resp map[string][]storage.StatusError) error {
 	idSQL := strings.Repeat(", ?", len(enrollmentIDs))[2:]
-	args := make([]interface{}, len(enrollmentIDs), len(enrollmentIDs)+2)
+	args := make([]interface{}, len(enrollmentIDs), len(enrollmentIDs)+4)
 	for i, id := range enrollmentIDs {
 		args[i] = id
 	}
-	args = append(args, offset, limit)
+	var rangeSQL string
+	if !since.IsZero() {
+		rangeSQL += " AND last_seen_at >= ?"
+		args = append(args, since.UTC().Format(mysqlTimeFormat))
+	}
+	if !until.IsZero() {
+		rangeSQL += " AND last_seen_at <= ?"
+		args = append(args, until.UTC().Format(mysqlTimeFormat))
+	}
+	args = append(args, offset, offset+limit)
 	rows, err := s.db.QueryContext(
 		ctx, `
 SELECT
     enrollment_id,
     path,
     error,
-	status_id,
-	created_at
+    status_id,
+    created_at,
+    last_seen_at,
+    occurrences
 FROM
-    status_errors
+    (
+        SELECT
+            enrollment_id,
+            path,
+            error,
+            status_id,
+            created_at,
+            last_seen_at,
+            occurrences,
+            ROW_NUMBER() OVER (PARTITION BY enrollment_id ORDER BY last_seen_at) AS n
+        FROM
+            status_errors
+        WHERE
+            enrollment_id IN (`+idSQL+`)`+rangeSQL+`
+    ) AS e
 WHERE
-    enrollment_id IN (`+idSQL+`)
+    n > ? AND n <= ?
 ORDER BY
-    enrollment_id, created_at
-LIMIT ?, ?;`,
+    enrollment_id, last_seen_at;`,
 		args...,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
-	resp := make(map[string][]storage.StatusError)
-	var id, dbTimestamp string
+	var id, dbFirstSeen, dbTimestamp string
 	var dbErrorJSON []byte
 	var statusID sql.NullString
 	for rows.Next() {
 		sErr := storage.StatusError{}
-		err = rows.Scan(&id, &sErr.Path, &dbErrorJSON, &statusID, &dbTimestamp)
+		err = rows.Scan(&id, &sErr.Path, &dbErrorJSON, &statusID, &dbFirstSeen, &dbTimestamp, &sErr.Count)
 		if err != nil {
 			break
 		}
 		_ = json.Unmarshal(dbErrorJSON, &sErr.Error)
 		sErr.StatusID = statusID.String
+		sErr.FirstSeen, _ = time.Parse(mysqlTimeFormat, dbFirstSeen)
 		sErr.Timestamp, _ = time.Parse(mysqlTimeFormat, dbTimestamp)
 		resp[id] = append(resp[id], sErr)
 	}
 	if err == nil {
 		err = rows.Err()
 	}
-	return resp, err
+	return err
 }
 
 // RetrieveStatusValues retrieves the status values for enrollmentIDs.
 // The search can be filtered with pathPrefix by using SQL LIKE syntax.
+// Large numbers of enrollment IDs are queried in chunks.
 // See also the storage package for documentation on the storage interfaces.
 func (s *MySQLStorage) RetrieveStatusValues(ctx context.Context, enrollmentIDs []string, pathPrefix string) (map[string][]storage.StatusValue, error) {
+	resp := make(map[string][]storage.StatusValue)
+	for _, chunk := range storage.ChunkStrings(enrollmentIDs, maxInParams) {
+		if err := s.retrieveStatusValues(ctx, chunk, pathPrefix, resp); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+func (s *MySQLStorage) retrieveStatusValues(ctx context.Context, enrollmentIDs []string, pathPrefix string, resp map[string][]storage.StatusValue) error {
 	idSQL := strings.Repeat(", ?", len(enrollmentIDs))[2:]
 	args := make([]interface{}, len(enrollmentIDs))
 	for i, id := range enrollmentIDs {
@@ -393,6 +602,7 @@ func (s *MySQLStorage) RetrieveStatusValues(ctx context.Context, enrollmentIDs [
 SELECT
     enrollment_id,
     path,
+    value_type,
     value,
     status_id,
     updated_at
@@ -405,10 +615,9 @@ ORDER BY
 		args...,
 	)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	defer rows.Close()
-	resp := make(map[string][]storage.StatusValue)
 	var id string
 	for rows.Next() {
 		sVal := storage.StatusValue{}
@@ -417,6 +626,7 @@ ORDER BY
 		err = rows.Scan(
 			&id,
 			&sVal.Path,
+			&sVal.Type,
 			&sVal.Value,
 			&statusID,
 			&dbTimestamp,
@@ -431,7 +641,7 @@ ORDER BY
 	if err == nil {
 		err = rows.Err()
 	}
-	return resp, err
+	return err
 }
 
 // RetrieveStatusValues retrieves the status report for an enrollment ID.
@@ -449,7 +659,7 @@ func (s *MySQLStorage) RetrieveStatusReport(ctx context.Context, q storage.Statu
 	}
 	if q.StatusID != nil {
 		if where != "" {
-			where += " AND"
+			where += " AND "
 		}
 		where += "status_id = ?"
 		args = append(args, *q.StatusID)
@@ -459,6 +669,7 @@ func (s *MySQLStorage) RetrieveStatusReport(ctx context.Context, q storage.Statu
 	}
 	report := new(storage.StoredStatusReport)
 	var dbTimestamp string
+	var rawGz []byte
 	err := s.db.QueryRowContext(
 		ctx,
 		`
@@ -466,7 +677,8 @@ SELECT
     status_id,
 	created_at,
 	row_count,
-    status_report
+    status_report,
+    status_report_gz
 FROM
     status_reports
 WHERE
@@ -478,10 +690,67 @@ LIMIT 1;`,
 		&dbTimestamp,
 		&report.Index,
 		&report.Raw,
+		&rawGz,
 	)
-	if err != nil {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, storage.ErrStatusReportNotFound
+	} else if err != nil {
 		return report, err
 	}
+	if rawGz != nil {
+		if report.Raw, err = storage.DecompressJSON(rawGz); err != nil {
+			return report, fmt.Errorf("status report: %w", err)
+		}
+	}
 	report.Timestamp, _ = time.Parse(mysqlTimeFormat, dbTimestamp)
 	return report, err
 }
+
+// RetrieveSetStatusSummary summarizes the reported status of the declarations in setName.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveSetStatusSummary(ctx context.Context, setName string) ([]storage.DeclarationStatusSummary, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    sd.declaration_identifier,
+    COUNT(es.enrollment_id),
+    COALESCE(SUM(statusd.active AND statusd.valid = 'valid'), 0),
+    COALESCE(SUM(NOT (statusd.active AND statusd.valid = 'valid')), 0)
+FROM
+    set_declarations sd
+    LEFT JOIN enrollment_sets es
+        ON sd.set_name = es.set_name
+    LEFT JOIN status_declarations statusd
+        ON es.enrollment_id = statusd.enrollment_id AND sd.declaration_identifier = statusd.declaration_identifier
+WHERE
+    sd.set_name = ?
+GROUP BY
+    sd.declaration_identifier
+ORDER BY
+    sd.declaration_identifier;`,
+		setName,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []storage.DeclarationStatusSummary
+	for rows.Next() {
+		var summary storage.DeclarationStatusSummary
+		err = rows.Scan(
+			&summary.Identifier,
+			&summary.Enrollments,
+			&summary.ActiveValid,
+			&summary.Failing,
+		)
+		if err != nil {
+			break
+		}
+		summary.Unknown = summary.Enrollments - summary.ActiveValid - summary.Failing
+		ret = append(ret, summary)
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	return ret, err
+}
@@ -0,0 +1,40 @@
+package mysql
+
+import (
+	"context"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// RetrieveDeclarationStatusEnrollments retrieves enrollments by their reported status of a declaration.
+// The status_declarations table is indexed by declaration identifier and status.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveDeclarationStatusEnrollments(ctx context.Context, q *storage.DeclarationStatusQuery) ([]string, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	query := `SELECT enrollment_id FROM status_declarations WHERE declaration_identifier = ?`
+	args := []interface{}{q.Identifier}
+	if q.Valid != "" {
+		query += ` AND valid = ?`
+		args = append(args, q.Valid)
+	}
+	if q.Active != nil {
+		query += ` AND active = ?`
+		args = append(args, *q.Active)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY enrollment_id;`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ret []string
+	for rows.Next() {
+		var enrollmentID string
+		if err = rows.Scan(&enrollmentID); err != nil {
+			return nil, err
+		}
+		ret = append(ret, enrollmentID)
+	}
+	return ret, rows.Err()
+}
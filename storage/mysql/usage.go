@@ -0,0 +1,88 @@
+package mysql
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// IncrementUsage adds to the usage of setNames on day.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) IncrementUsage(ctx context.Context, day string, setNames []string, syncs, statusReports int64) error {
+	if len(setNames) < 1 {
+		return nil
+	}
+	args := make([]interface{}, 0, len(setNames)*4)
+	for _, setName := range setNames {
+		args = append(args, day, setName, syncs, statusReports)
+	}
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO usage_daily
+    (day, set_name, syncs, status_reports)
+VALUES
+    `+strings.Repeat("(?, ?, ?, ?), ", len(setNames)-1)+`(?, ?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    syncs = usage_daily.syncs + new.syncs,
+    status_reports = usage_daily.status_reports + new.status_reports;`,
+		args...,
+	)
+	return err
+}
+
+// StoreUsageDeclarations sets the number of declarations in the usage of setName on day.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) StoreUsageDeclarations(ctx context.Context, day, setName string, declarations int64) error {
+	_, err := s.db.ExecContext(
+		ctx, `
+INSERT INTO usage_daily
+    (day, set_name, declarations)
+VALUES
+    (?, ?, ?) AS new
+ON DUPLICATE KEY
+UPDATE
+    declarations = new.declarations;`,
+		day,
+		setName,
+		declarations,
+	)
+	return err
+}
+
+// RetrieveUsage retrieves the usage of the days from through to.
+// See also the storage package for documentation on the storage interfaces.
+func (s *MySQLStorage) RetrieveUsage(ctx context.Context, from, to string) ([]storage.UsageRecord, error) {
+	rows, err := s.db.QueryContext(
+		ctx, `
+SELECT
+    day,
+    set_name,
+    syncs,
+    status_reports,
+    declarations
+FROM
+    usage_daily
+WHERE
+    day BETWEEN ? AND ?
+ORDER BY
+    day,
+    set_name;`,
+		from,
+		to,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var records []storage.UsageRecord
+	for rows.Next() {
+		var u storage.UsageRecord
+		if err = rows.Scan(&u.Day, &u.Set, &u.Syncs, &u.StatusReports, &u.Declarations); err != nil {
+			return nil, err
+		}
+		records = append(records, u)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,28 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// EnrollmentNotification is when an enrollment was last notified.
+type EnrollmentNotification struct {
+	EnrollmentID string `json:"enrollment_id"`
+
+	// TokensHash is the hash of the tokens of the enrollment when it was notified.
+	TokensHash string `json:"tokens_hash"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// EnrollmentNotificationStorage stores when enrollments were last notified.
+type EnrollmentNotificationStorage interface {
+	// RetrieveEnrollmentNotifications retrieves when enrollmentIDs were
+	// last notified keyed by enrollment ID. Enrollments that were not
+	// notified are omitted.
+	RetrieveEnrollmentNotifications(ctx context.Context, enrollmentIDs []string) (map[string]*EnrollmentNotification, error)
+
+	// StoreEnrollmentNotifications stores when enrollments were last notified.
+	// Any previous notification of each enrollment is replaced.
+	StoreEnrollmentNotifications(ctx context.Context, notifications []*EnrollmentNotification) error
+}
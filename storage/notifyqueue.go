@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// NotificationBatch is a batch of enrollments queued to be notified.
+type NotificationBatch struct {
+	ID            string    `json:"id"`
+	Priority      string    `json:"priority,omitempty"`
+	EnrollmentIDs []string  `json:"enrollment_ids"`
+	Timestamp     time.Time `json:"timestamp"`
+
+	// NotBefore is when the enrollments of a batch deferred to a
+	// notification window may be notified. It is zero for batches
+	// that are not deferred.
+	NotBefore time.Time `json:"not_before,omitempty"`
+}
+
+// Validate checks the batch for errors.
+func (b *NotificationBatch) Validate() error {
+	if b == nil {
+		return Categorize(ErrInvalid, errors.New("nil notification batch"))
+	} else if err := ValidateIdentifier("notification batch ID", b.ID); err != nil {
+		return err
+	} else if len(b.EnrollmentIDs) < 1 {
+		return Categorize(ErrInvalid, errors.New("no enrollment IDs in notification batch"))
+	}
+	return nil
+}
+
+// NotificationQueueStorage persists queued notification batches so
+// that they can be resumed after a restart.
+type NotificationQueueStorage interface {
+	// StoreNotificationBatch stores a queued notification batch.
+	StoreNotificationBatch(ctx context.Context, batch *NotificationBatch) error
+
+	// RetrieveNotificationBatches retrieves the queued notification
+	// batches in the order they were stored.
+	RetrieveNotificationBatches(ctx context.Context) ([]*NotificationBatch, error)
+
+	// DeleteNotificationBatch deletes the notification batch id.
+	// Deleting a batch that does not exist is not an error.
+	DeleteNotificationBatch(ctx context.Context, id string) error
+}
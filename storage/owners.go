@@ -0,0 +1,22 @@
+package storage
+
+import "context"
+
+// Kinds of objects owned by principals.
+const (
+	OwnedDeclaration = "declaration"
+	OwnedSet         = "set"
+)
+
+// OwnerStorage records the principals that created declarations and
+// sets (e.g. to count them against per-principal quotas).
+type OwnerStorage interface {
+	// StoreOwner records principal as the owner of the object of kind
+	// (e.g. OwnedDeclaration) with id. Any existing owner is replaced.
+	StoreOwner(ctx context.Context, kind, id, principal string) error
+
+	// RetrieveOwned retrieves the identifiers of the objects of kind
+	// owned by principal. Owners are not removed when objects are
+	// deleted so identifiers of deleted objects may be included.
+	RetrieveOwned(ctx context.Context, kind, principal string) ([]string, error)
+}
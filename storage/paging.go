@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"sort"
+)
+
+// DeclarationsPager retrieves the list of declarations a page at a time.
+type DeclarationsPager interface {
+	// RetrieveDeclarationsPage retrieves up to limit declaration
+	// identifiers in sorted order that sort after the identifier after.
+	// An empty after starts at the first declaration.
+	RetrieveDeclarationsPage(ctx context.Context, after string, limit int) ([]string, error)
+}
+
+// SetsPager retrieves the list of sets a page at a time.
+type SetsPager interface {
+	// RetrieveSetsPage retrieves up to limit set names in sorted order
+	// that sort after the set name after.
+	// An empty after starts at the first set.
+	RetrieveSetsPage(ctx context.Context, after string, limit int) ([]string, error)
+}
+
+// PageStrings returns up to limit of the sorted strings that sort after after.
+func PageStrings(sorted []string, after string, limit int) []string {
+	i := sort.Search(len(sorted), func(i int) bool { return sorted[i] > after })
+	sorted = sorted[i:]
+	if len(sorted) > limit {
+		sorted = sorted[:limit]
+	}
+	return sorted
+}
+
+// ChunkStrings splits strs into slices of at most n strings.
+func ChunkStrings(strs []string, n int) (chunks [][]string) {
+	for i := 0; i < len(strs); i += n {
+		end := i + n
+		if end > len(strs) {
+			end = len(strs)
+		}
+		chunks = append(chunks, strs[i:end])
+	}
+	return
+}
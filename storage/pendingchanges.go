@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrPendingChangeNotFound is returned when a pending change does not exist.
+var ErrPendingChangeNotFound = Categorize(ErrNotFound, errors.New("pending change not found"))
+
+// PendingChange is a mutating API request that is held until it is
+// approved by a different principal than the one that made it.
+type PendingChange struct {
+	ID        string `json:"id"`
+	Principal string `json:"principal"`
+
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	RawQuery    string `json:"raw_query,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+
+	// IfMatch is the If-Match header of the request (e.g. the ETag of
+	// a set) so that its precondition is checked when it is replayed.
+	IfMatch string `json:"if_match,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+type PendingChangeStorage interface {
+	// StorePendingChange stores change using its ID.
+	StorePendingChange(ctx context.Context, change *PendingChange) error
+
+	// RetrievePendingChanges retrieves all pending changes ordered
+	// from oldest to newest.
+	RetrievePendingChanges(ctx context.Context) ([]*PendingChange, error)
+
+	// RetrievePendingChange retrieves the pending change with id.
+	// ErrPendingChangeNotFound is returned if it does not exist.
+	RetrievePendingChange(ctx context.Context, id string) (*PendingChange, error)
+
+	// DeletePendingChange deletes the pending change with id.
+	// ErrPendingChangeNotFound is returned if it does not exist so
+	// that only one of concurrent approvals or rejections succeeds.
+	DeletePendingChange(ctx context.Context, id string) error
+}
@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrProfileNotFound is returned when a hosted profile does not exist.
+var ErrProfileNotFound = Categorize(ErrNotFound, errors.New("profile not found"))
+
+// ProfileStorage stores and retrieves hosted configuration profiles.
+type ProfileStorage interface {
+	// StoreProfile stores the raw configuration profile with profileID.
+	// Storing a profile with an existing ID replaces it.
+	StoreProfile(ctx context.Context, profileID string, raw []byte) error
+
+	// RetrieveProfile retrieves the raw configuration profile with profileID.
+	// ErrProfileNotFound is returned if the profile does not exist.
+	RetrieveProfile(ctx context.Context, profileID string) ([]byte, error)
+}
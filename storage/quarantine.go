@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// StatusQuarantineMax is the maximum number of quarantined status
+// report fragments kept per enrollment. Older fragments are discarded.
+const StatusQuarantineMax = 50
+
+// QuarantinedStatus is a quarantined fragment of a status report.
+type QuarantinedStatus struct {
+	// Path is the path of the fragment in the status report.
+	Path  string `json:"path"`
+	Error string `json:"error"`
+
+	// JSON is the fragment. For fragments that failed to store it is
+	// the parsed fragment rather than the fragment as reported.
+	JSON json.RawMessage `json:"json,omitempty"`
+
+	Timestamp time.Time `json:"timestamp"`
+	StatusID  string    `json:"status_id,omitempty"`
+}
+
+// QuarantineStatusSection quarantines section, the parsed data of the
+// section of status at path, which failed to store with err.
+func QuarantineStatusSection(status *ddm.StatusReport, path string, section interface{}, err error) {
+	f := ddm.StatusFragment{Path: path, Error: err.Error()}
+	// the fragment is for inspection only so encoding is best-effort
+	f.JSON, _ = json.Marshal(section)
+	status.Quarantined = append(status.Quarantined, f)
+}
+
+type StatusQuarantineRetriever interface {
+	// RetrieveStatusQuarantine retrieves the quarantined status report
+	// fragments of enrollmentIDs, oldest first.
+	RetrieveStatusQuarantine(ctx context.Context, enrollmentIDs []string) (map[string][]QuarantinedStatus, error)
+}
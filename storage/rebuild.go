@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// DDMRebuild is an enrollment whose derived DDM data failed to be
+// rebuilt when its declarations or sets changed.
+type DDMRebuild struct {
+	EnrollmentID string `json:"enrollment_id"`
+
+	// Error is the error of the last failed rebuild.
+	Error string `json:"error"`
+
+	// Attempts is the number of failed rebuilds.
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DDMRebuildStorage tracks and retries the failed rebuilds of the
+// derived DDM data of enrollments. When a change to a declaration or
+// set rebuilds the derived data of many enrollments a failure for one
+// enrollment is recorded rather than leaving the remaining enrollments
+// stale. Backends that do not store derived data never fail rebuilds.
+type DDMRebuildStorage interface {
+	// RetrieveDDMRebuilds retrieves the enrollments whose derived DDM
+	// data failed to be rebuilt and has not since been rebuilt.
+	RetrieveDDMRebuilds(ctx context.Context) ([]DDMRebuild, error)
+
+	// RetryDDMRebuilds rebuilds the derived DDM data of the enrollments
+	// that failed to be rebuilt and returns the rebuilt enrollment IDs.
+	// Enrollments that fail again remain to be retried.
+	RetryDDMRebuilds(ctx context.Context) ([]string, error)
+}
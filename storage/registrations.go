@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrEnrollmentRegistrationNotFound is returned when an enrollment is not registered.
+var ErrEnrollmentRegistrationNotFound = Categorize(ErrNotFound, errors.New("enrollment registration not found"))
+
+// EnrollmentRegistration explicitly registers an enrollment ID, for
+// example by the MDM server when a device enrolls. Registered
+// enrollments are known (e.g. they can be notified and matched by set
+// patterns) before their first DDM sync.
+type EnrollmentRegistration struct {
+	EnrollmentID string            `json:"enrollment_id"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Timestamp    time.Time         `json:"timestamp"`
+}
+
+// Validate checks the registration for errors.
+func (r *EnrollmentRegistration) Validate() error {
+	return Categorize(ErrInvalid, r.validate())
+}
+
+func (r *EnrollmentRegistration) validate() error {
+	if r == nil {
+		return errors.New("nil registration")
+	} else if r.EnrollmentID == "" {
+		return errors.New("missing enrollment ID")
+	} else if err := ValidateIdentifier("enrollment ID", r.EnrollmentID); err != nil {
+		return err
+	}
+	for k := range r.Metadata {
+		if k == "" {
+			return errors.New("empty metadata key")
+		}
+	}
+	return nil
+}
+
+// EnrollmentRegistrationStorage stores the registrations of enrollments.
+// Registered enrollment IDs are also returned by RetrieveEnrollmentIDs.
+type EnrollmentRegistrationStorage interface {
+	// RetrieveEnrollmentRegistration retrieves the registration of enrollmentID.
+	// ErrEnrollmentRegistrationNotFound is returned if the enrollment is not registered.
+	RetrieveEnrollmentRegistration(ctx context.Context, enrollmentID string) (*EnrollmentRegistration, error)
+
+	// StoreEnrollmentRegistration stores the registration of its enrollment.
+	// Any existing registration of the enrollment is replaced.
+	StoreEnrollmentRegistration(ctx context.Context, registration *EnrollmentRegistration) error
+
+	// DeleteEnrollmentRegistration deletes the registration of enrollmentID.
+	// Returns true if the enrollment was registered.
+	DeleteEnrollmentRegistration(ctx context.Context, enrollmentID string) (bool, error)
+}
@@ -0,0 +1,105 @@
+// Package registry is a registry of named storage backend constructors.
+// Backends register themselves (typically in an init function) so that
+// they can be selected by name without changing the code that selects
+// them, similar to database/sql drivers.
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/log"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ErrUnknownStorage is returned when no storage backend is registered with a name.
+var ErrUnknownStorage = errors.New("unknown storage name")
+
+// Config configures a storage backend.
+type Config struct {
+	// DSN is the backend data source name (e.g. a connection string).
+	DSN string
+
+	// Options are backend-specific options.
+	Options map[string]string
+
+	// History configures the recording of status value history.
+	// It may be nil.
+	History *storage.StatusValueHistory
+
+	// Limits limits the number of status values and errors stored.
+	// It may be nil.
+	Limits *storage.StatusLimits
+
+	// NewHash is the hash used to compute tokens.
+	NewHash ddm.NewHash
+
+	// PreviousHashes are hashes that stored tokens may have been
+	// computed with. Backends that support it recognize such tokens
+	// as unchanged and lazily recompute them with NewHash.
+	PreviousHashes []ddm.NewHash
+
+	// Clock is used to timestamp stored data.
+	Clock storage.Clock
+
+	Logger log.Logger
+}
+
+// Factory creates a storage backend from config.
+// The returned storage should implement as many of the storage
+// interfaces as the caller requires.
+type Factory func(config *Config) (interface{}, error)
+
+var (
+	mu        sync.RWMutex
+	factories = make(map[string]Factory)
+)
+
+// Register makes a storage backend factory available by name.
+// It panics if factory is nil or if name is already registered.
+func Register(name string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	if factory == nil {
+		panic("registry: nil storage factory")
+	}
+	if _, ok := factories[name]; ok {
+		panic("registry: storage registered twice: " + name)
+	}
+	factories[name] = factory
+}
+
+// New creates the storage backend registered as name from config.
+func New(name string, config *Config) (interface{}, error) {
+	mu.RLock()
+	factory, ok := factories[name]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownStorage, name)
+	}
+	if config == nil {
+		config = new(Config)
+	}
+	if config.Logger == nil {
+		config.Logger = log.NopLogger
+	}
+	if config.Clock == nil {
+		config.Clock = storage.SystemClock
+	}
+	return factory(config)
+}
+
+// Names returns the sorted names of the registered storage backends.
+func Names() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
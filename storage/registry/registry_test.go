@@ -0,0 +1,42 @@
+package registry
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	Register("test_golang_registry", func(config *Config) (interface{}, error) {
+		if config.Logger == nil {
+			t.Error("nil logger")
+		}
+		if config.Clock == nil {
+			t.Error("nil clock")
+		}
+		return config.DSN, nil
+	})
+
+	s, err := New("test_golang_registry", &Config{DSN: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := s, "foo"; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	if _, err = New("test_golang_registry_unknown", nil); !errors.Is(err, ErrUnknownStorage) {
+		t.Errorf("have: %v, want: %v", err, ErrUnknownStorage)
+	}
+
+	if have, want := Names(), []string{"test_golang_registry"}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic registering twice")
+		}
+	}()
+	Register("test_golang_registry", func(*Config) (interface{}, error) { return nil, nil })
+}
@@ -0,0 +1,54 @@
+package storage
+
+import "context"
+
+const (
+	// DDMDeclarationItems names mismatched declaration-items JSON.
+	DDMDeclarationItems = "declaration-items"
+
+	// DDMTokens names mismatched tokens JSON.
+	DDMTokens = "tokens"
+
+	// DDMDeclarations names mismatched enrollment declaration references.
+	DDMDeclarations = "declarations"
+)
+
+// DDMMismatch is an enrollment whose derived DDM data did not match
+// that computed from its sets and their declarations.
+type DDMMismatch struct {
+	EnrollmentID string `json:"enrollment_id"`
+
+	// Mismatched names the derived data that did not match.
+	// See DDMDeclarationItems, DDMTokens, and DDMDeclarations.
+	Mismatched []string `json:"mismatched"`
+}
+
+// DDMRepairReport reports the outcome of checking the derived DDM data of enrollments.
+type DDMRepairReport struct {
+	// Enrollments is the number of enrollments checked.
+	Enrollments int           `json:"enrollments"`
+	Mismatches  []DDMMismatch `json:"mismatches"`
+
+	// Repaired is true if the mismatched derived data was rewritten.
+	Repaired bool `json:"repaired"`
+}
+
+// DDMRepairer checks and repairs the derived DDM data of enrollments.
+// Derived data (such as the declaration-items and tokens JSON) may be
+// written non-transactionally and can be left stale by partial failures.
+type DDMRepairer interface {
+	// RepairEnrollmentDDM recomputes the derived DDM data of all
+	// enrollments from the set and declaration associations and
+	// reports the enrollments whose derived data did not match.
+	// Mismatched derived data is rewritten unless dryRun is true.
+	// Backends that do not store derived data report no mismatches.
+	RepairEnrollmentDDM(ctx context.Context, dryRun bool) (*DDMRepairReport, error)
+}
+
+// EnrollmentDDMRefresher regenerates the derived DDM data of an enrollment.
+type EnrollmentDDMRefresher interface {
+	// RefreshEnrollmentDDM recomputes and rewrites the derived DDM data
+	// of enrollmentID from its set and declaration associations.
+	// Backends that do not store derived data do nothing.
+	RefreshEnrollmentDDM(ctx context.Context, enrollmentID string) error
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrAssignmentScheduleNotFound is returned when an assignment schedule does not exist.
+var ErrAssignmentScheduleNotFound = Categorize(ErrNotFound, errors.New("assignment schedule not found"))
+
+const (
+	// AssignmentSetDeclaration is the kind of schedule that associates a declaration with a set.
+	AssignmentSetDeclaration = "set-declaration"
+
+	// AssignmentEnrollmentSet is the kind of schedule that associates an enrollment with a set.
+	AssignmentEnrollmentSet = "enrollment-set"
+)
+
+// AssignmentSchedule time-bounds a set-declaration or enrollment-set
+// association. The association is made at Start (or as soon as
+// possible if Start is nil) and removed at End (or never if End is nil).
+type AssignmentSchedule struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	Set  string `json:"set"`
+
+	// Declaration is the declaration identifier of a set-declaration schedule.
+	Declaration string `json:"declaration,omitempty"`
+
+	// Enrollment is the enrollment ID of an enrollment-set schedule.
+	Enrollment string `json:"enrollment,omitempty"`
+
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+
+	// Active is true once the association has been made.
+	Active bool `json:"active"`
+}
+
+// Validate checks a for an unknown kind, missing association
+// identifiers, or an end that is not after the start.
+func (a *AssignmentSchedule) Validate() error {
+	return Categorize(ErrInvalid, a.validate())
+}
+
+func (a *AssignmentSchedule) validate() error {
+	if a == nil {
+		return errors.New("nil assignment schedule")
+	}
+	if a.Set == "" {
+		return errors.New("empty set name")
+	}
+	switch a.Kind {
+	case AssignmentSetDeclaration:
+		if a.Declaration == "" || a.Enrollment != "" {
+			return errors.New("set-declaration schedule requires only a declaration")
+		}
+	case AssignmentEnrollmentSet:
+		if a.Enrollment == "" || a.Declaration != "" {
+			return errors.New("enrollment-set schedule requires only an enrollment")
+		}
+	default:
+		return fmt.Errorf("unknown assignment schedule kind: %q", a.Kind)
+	}
+	if err := ValidateIdentifier("set name", a.Set); err != nil {
+		return err
+	}
+	if a.Declaration != "" {
+		if err := ValidateIdentifier("declaration identifier", a.Declaration); err != nil {
+			return err
+		}
+	} else if err := ValidateIdentifier("enrollment ID", a.Enrollment); err != nil {
+		return err
+	}
+	if a.Start != nil && a.End != nil && !a.End.After(*a.Start) {
+		return errors.New("schedule end not after start")
+	}
+	return nil
+}
+
+// AssignmentScheduleStorage stores assignment schedules.
+type AssignmentScheduleStorage interface {
+	// StoreAssignmentSchedule stores schedule using its ID.
+	// Any existing schedule with the ID is replaced.
+	StoreAssignmentSchedule(ctx context.Context, schedule *AssignmentSchedule) error
+
+	// RetrieveAssignmentSchedules retrieves all schedules ordered by ID.
+	RetrieveAssignmentSchedules(ctx context.Context) ([]*AssignmentSchedule, error)
+
+	// DeleteAssignmentSchedule deletes the schedule with id.
+	// ErrAssignmentScheduleNotFound is returned if it does not exist.
+	// Deleting a schedule does not change its association.
+	DeleteAssignmentSchedule(ctx context.Context, id string) error
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Kinds of DDM documents served to enrollments.
+const (
+	ServedTokens           = "tokens"
+	ServedDeclarationItems = "declaration-items"
+)
+
+// DefaultServedHistoryMax is the default number of served documents
+// of each kind kept per enrollment.
+const DefaultServedHistoryMax = 50
+
+// ServedDocument is a DDM document served to an enrollment.
+type ServedDocument struct {
+	Kind string `json:"kind"`
+
+	// Hash is the hex-encoded SHA-256 hash of the served document.
+	Hash string `json:"hash"`
+
+	// Body is the served document. It is only recorded if configured.
+	Body json.RawMessage `json:"body,omitempty"`
+
+	// Timestamp is when the document was first served.
+	// That is, when the served content last changed.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// NewServedDocument creates a new served document of kind from body.
+// If keepBody is false only the hash of body is kept.
+func NewServedDocument(kind string, body []byte, keepBody bool, now time.Time) (*ServedDocument, error) {
+	switch kind {
+	case ServedTokens, ServedDeclarationItems:
+	default:
+		return nil, Categorize(ErrInvalid, fmt.Errorf("invalid served document kind: %q", kind))
+	}
+	sum := sha256.Sum256(body)
+	doc := &ServedDocument{
+		Kind:      kind,
+		Hash:      hex.EncodeToString(sum[:]),
+		Timestamp: now,
+	}
+	if keepBody {
+		doc.Body = append(json.RawMessage(nil), body...)
+	}
+	return doc, nil
+}
+
+// ServedHistoryStorage records the DDM documents served to enrollments.
+type ServedHistoryStorage interface {
+	// StoreServedDocument records doc as served to enrollmentID if its
+	// hash differs from the most recently recorded document of its kind.
+	// The history of its kind is then trimmed to max documents.
+	// Returns true if doc was recorded.
+	StoreServedDocument(ctx context.Context, enrollmentID string, doc *ServedDocument, max int) (bool, error)
+
+	// RetrieveServedHistory retrieves the documents of kind recorded as
+	// served to enrollmentID, most recent first.
+	// If kind is empty the documents of all kinds are retrieved.
+	RetrieveServedHistory(ctx context.Context, enrollmentID, kind string) ([]ServedDocument, error)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// colorRe matches hex RGB colors (e.g. "#1E90FF").
+var colorRe = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// SetMetadata is descriptive metadata of a set so that large numbers
+// of sets remain navigable by humans and UIs. It has no effect on the
+// DDM an enrollment is served.
+type SetMetadata struct {
+	SetName     string `json:"set_name"`
+	Description string `json:"description,omitempty"`
+	Owner       string `json:"owner,omitempty"`
+
+	// Color is a hex RGB color (e.g. "#1E90FF") for displaying the set.
+	Color string `json:"color,omitempty"`
+
+	Tags      []string  `json:"tags,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Validate checks the metadata for errors.
+func (m *SetMetadata) Validate() error {
+	return Categorize(ErrInvalid, m.validate())
+}
+
+func (m *SetMetadata) validate() error {
+	if m == nil {
+		return errors.New("nil set metadata")
+	} else if m.SetName == "" {
+		return errors.New("missing set name")
+	} else if err := ValidateIdentifier("set name", m.SetName); err != nil {
+		return err
+	}
+	if m.Color != "" && !colorRe.MatchString(m.Color) {
+		return fmt.Errorf("invalid color: %q", m.Color)
+	}
+	for _, tag := range m.Tags {
+		if tag == "" {
+			return errors.New("empty tag")
+		}
+	}
+	return nil
+}
+
+// SetMetadataRetriever retrieves the metadata of sets.
+type SetMetadataRetriever interface {
+	// RetrieveSetMetadata retrieves the metadata of setNames keyed by
+	// set name. Sets without metadata are not included.
+	RetrieveSetMetadata(ctx context.Context, setNames []string) (map[string]*SetMetadata, error)
+}
+
+// SetMetadataStorage stores the metadata of sets.
+type SetMetadataStorage interface {
+	SetMetadataRetriever
+
+	// StoreSetMetadata stores the metadata of its set.
+	// Any existing metadata of the set is replaced.
+	StoreSetMetadata(ctx context.Context, metadata *SetMetadata) error
+
+	// DeleteSetMetadata deletes the metadata of setName.
+	// Returns true if the set had metadata.
+	DeleteSetMetadata(ctx context.Context, setName string) (bool, error)
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Set pattern kinds.
+const (
+	// SetPatternPrefix matches enrollment IDs that start with the pattern.
+	SetPatternPrefix = "prefix"
+
+	// SetPatternGlob matches enrollment IDs using the pattern syntax of path.Match.
+	SetPatternGlob = "glob"
+
+	// SetPatternRegex matches enrollment IDs using the RE2 regular expression syntax.
+	// The expression is unanchored; use ^ and $ to match the whole ID.
+	SetPatternRegex = "regex"
+)
+
+// SetPattern makes the enrollments whose IDs match a pattern members
+// of a set. Pattern membership is evaluated when the DDM of an
+// enrollment is generated and is in addition to explicit enrollment sets.
+type SetPattern struct {
+	Set     string `json:"set"`
+	Kind    string `json:"kind"`
+	Pattern string `json:"pattern"`
+}
+
+// Validate checks the set pattern for errors.
+func (p *SetPattern) Validate() error {
+	return Categorize(ErrInvalid, p.validate())
+}
+
+func (p *SetPattern) validate() error {
+	if p == nil {
+		return errors.New("nil set pattern")
+	} else if p.Set == "" {
+		return errors.New("empty set name")
+	} else if p.Pattern == "" {
+		return errors.New("empty pattern")
+	} else if err := ValidateIdentifier("set name", p.Set); err != nil {
+		return err
+	}
+	_, err := p.compile()
+	return err
+}
+
+// compile returns a function that matches enrollment IDs against p.
+func (p *SetPattern) compile() (func(string) bool, error) {
+	switch p.Kind {
+	case SetPatternPrefix:
+		prefix := p.Pattern
+		return func(id string) bool { return strings.HasPrefix(id, prefix) }, nil
+	case SetPatternGlob:
+		if _, err := path.Match(p.Pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob pattern: %w", err)
+		}
+		pattern := p.Pattern
+		return func(id string) bool {
+			ok, _ := path.Match(pattern, id)
+			return ok
+		}, nil
+	case SetPatternRegex:
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex pattern: %w", err)
+		}
+		return re.MatchString, nil
+	default:
+		return nil, fmt.Errorf("invalid set pattern kind: %q", p.Kind)
+	}
+}
+
+type compiledSetPattern struct {
+	set   string
+	match func(string) bool
+}
+
+// SetPatterns matches enrollment IDs against compiled set patterns.
+type SetPatterns []compiledSetPattern
+
+// CompileSetPatterns compiles patterns for matching enrollment IDs.
+func CompileSetPatterns(patterns []SetPattern) (SetPatterns, error) {
+	ret := make(SetPatterns, 0, len(patterns))
+	for _, p := range patterns {
+		match, err := p.compile()
+		if err != nil {
+			return nil, fmt.Errorf("compiling pattern for set %s: %w", p.Set, err)
+		}
+		ret = append(ret, compiledSetPattern{set: p.Set, match: match})
+	}
+	return ret, nil
+}
+
+// Sets returns the sorted names of the sets with a pattern matching enrollmentID.
+func (sp SetPatterns) Sets(enrollmentID string) []string {
+	var sets []string
+	for _, p := range sp {
+		if contains(sets, p.set) || !p.match(enrollmentID) {
+			continue
+		}
+		sets = append(sets, p.set)
+	}
+	sort.Strings(sets)
+	return sets
+}
+
+// Matches reports whether enrollmentID matches a pattern of setName.
+func (sp SetPatterns) Matches(setName, enrollmentID string) bool {
+	for _, p := range sp {
+		if p.set == setName && p.match(enrollmentID) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSet reports whether setName has any patterns.
+func (sp SetPatterns) HasSet(setName string) bool {
+	for _, p := range sp {
+		if p.set == setName {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPatternStorage stores the enrollment ID patterns of sets.
+type SetPatternStorage interface {
+	// RetrieveSetPatterns retrieves the patterns of all sets ordered
+	// by set name, kind, and pattern.
+	RetrieveSetPatterns(ctx context.Context) ([]SetPattern, error)
+
+	// StoreSetPattern adds pattern to its set.
+	// Returns true if the pattern was added.
+	StoreSetPattern(ctx context.Context, pattern *SetPattern) (bool, error)
+
+	// RemoveSetPattern removes pattern from its set.
+	// Returns true if the pattern was removed.
+	RemoveSetPattern(ctx context.Context, pattern *SetPattern) (bool, error)
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrSetSnapshotNotFound is returned when a set snapshot does not exist.
+var ErrSetSnapshotNotFound = Categorize(ErrNotFound, errors.New("set snapshot not found"))
+
+// MaxSetSnapshots is the maximum number of snapshots kept per set.
+// The oldest snapshots are removed first.
+const MaxSetSnapshots = 100
+
+// SetSnapshot is the list of declarations of a set at a point in time.
+type SetSnapshot struct {
+	ID           string    `json:"id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Declarations []string  `json:"declarations"`
+}
+
+// SetSnapshotStorage records the declarations of sets when they change
+// and restores sets to previous snapshots.
+type SetSnapshotStorage interface {
+	// RetrieveSetSnapshots retrieves the snapshots of setName ordered
+	// from newest to oldest.
+	RetrieveSetSnapshots(ctx context.Context, setName string) ([]SetSnapshot, error)
+
+	// RestoreSetSnapshot replaces the declarations of setName with
+	// those of the snapshot with snapshotID. Enrollments of the set are
+	// updated once. Restoring records a new snapshot if the set changed.
+	// ErrSetSnapshotNotFound is returned if the snapshot does not exist.
+	RestoreSetSnapshot(ctx context.Context, setName, snapshotID string) (bool, error)
+}
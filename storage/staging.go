@@ -0,0 +1,26 @@
+package storage
+
+import "context"
+
+// EnrollmentStagedSetStorage stores the staged sets of enrollments.
+// Staged sets are an alternate set assignment of an enrollment that is
+// not served until it is swapped with the current sets of the
+// enrollment. As the DDM is computed from the sets of an enrollment
+// swapping serves a different (hash-distinct) token set. Swapping again
+// restores the previous sets (i.e. a rollback).
+type EnrollmentStagedSetStorage interface {
+	// RetrieveEnrollmentStagedSets retrieves the staged sets of enrollmentID.
+	RetrieveEnrollmentStagedSets(ctx context.Context, enrollmentID string) ([]string, error)
+
+	// StoreEnrollmentStagedSet stages setName for enrollmentID.
+	// Returns true if the set was not already staged.
+	StoreEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error)
+
+	// RemoveEnrollmentStagedSet removes the staged setName of enrollmentID.
+	// Returns true if the set was staged.
+	RemoveEnrollmentStagedSet(ctx context.Context, enrollmentID, setName string) (bool, error)
+
+	// SwapEnrollmentStagedSets atomically exchanges the sets and the
+	// staged sets of enrollmentID. Returns true if they differed.
+	SwapEnrollmentStagedSets(ctx context.Context, enrollmentID string) (bool, error)
+}
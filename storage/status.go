@@ -1,27 +1,77 @@
 package storage
 
 import (
+	"crypto/sha256"
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
 )
 
 var (
-	ErrStatusReportNotFound = errors.New("status report not found")
-	ErrDeclarationNotFound  = errors.New("declaration not found")
+	ErrStatusReportNotFound = Categorize(ErrNotFound, errors.New("status report not found"))
+	ErrDeclarationNotFound  = Categorize(ErrNotFound, errors.New("declaration not found"))
 )
 
+// StatusError is a reported status error. Repeated reports of the same
+// error (the same path and error) are stored once with a count.
 type StatusError struct {
-	Path      string      `json:"path"`
-	Error     interface{} `json:"error"`
-	Timestamp time.Time   `json:"timestamp"`
-	StatusID  string      `json:"status_id,omitempty"`
+	Path  string      `json:"path"`
+	Error interface{} `json:"error"`
+
+	// Timestamp is when the error was last reported.
+	Timestamp time.Time `json:"timestamp"`
+	StatusID  string    `json:"status_id,omitempty"`
+
+	// FirstSeen is when the error was first reported.
+	FirstSeen time.Time `json:"first_seen"`
+
+	// Count is the number of times the error was reported.
+	Count int `json:"count,omitempty"`
+}
+
+// StatusErrorHash returns a hex digest that identifies the status
+// error with errorJSON at path for deduplication.
+func StatusErrorHash(path string, errorJSON []byte) string {
+	h := sha256.New()
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(errorJSON)
+	return fmt.Sprintf("%x", h.Sum(nil))
 }
 
 type StatusValue struct {
-	Path      string    `json:"path"`
+	Path string `json:"path"`
+
+	// Type is the type of Value as reported (e.g. "number").
+	Type string `json:"type,omitempty"`
+
 	Value     string    `json:"value"`
 	Timestamp time.Time `json:"timestamp"`
 	StatusID  string    `json:"status_id,omitempty"`
+
+	// EnrollmentID is the enrollment that reported the value when
+	// values of related enrollments are merged (see MergeDeviceStatusValues).
+	EnrollmentID string `json:"enrollment_id,omitempty"`
+}
+
+// DeclarationStatusSummary summarizes the reported status of a
+// declaration across the enrollments of a set.
+type DeclarationStatusSummary struct {
+	Identifier string `json:"identifier"`
+
+	// Enrollments is the count of enrollments in the set.
+	Enrollments int `json:"enrollments"`
+
+	// ActiveValid is the count of enrollments reporting the declaration as active and valid.
+	ActiveValid int `json:"active_valid"`
+
+	// Failing is the count of enrollments reporting the declaration as inactive or not valid.
+	Failing int `json:"failing"`
+
+	// Unknown is the count of enrollments that have not reported status for the declaration.
+	Unknown int `json:"unknown"`
 }
 
 // StoredStatusReport represents a stored status report by StoreDeclarationStatus.
@@ -48,3 +98,35 @@ func (q StatusReportQuery) Valid() error {
 	}
 	return nil
 }
+
+// PendingRemovals filters statuses to the declarations that are no
+// longer assigned to their enrollment but are still reported as active.
+// Enrollments without any are omitted.
+func PendingRemovals(statuses map[string][]ddm.DeclarationQueryStatus) map[string][]ddm.DeclarationQueryStatus {
+	ret := make(map[string][]ddm.DeclarationQueryStatus)
+	for enrollmentID, enrollmentStatuses := range statuses {
+		for _, status := range enrollmentStatuses {
+			if status.Unassigned && status.Active {
+				ret[enrollmentID] = append(ret[enrollmentID], status)
+			}
+		}
+	}
+	return ret
+}
+
+// DeclarationStatusAsOf filters statuses to those reported at or
+// before asOf. Earlier reports of a declaration's status are not kept:
+// a declaration whose status was last reported after asOf is omitted
+// because its status at asOf is unknown. Current and Unassigned still
+// reflect the present. Enrollments without any are omitted.
+func DeclarationStatusAsOf(statuses map[string][]ddm.DeclarationQueryStatus, asOf time.Time) map[string][]ddm.DeclarationQueryStatus {
+	ret := make(map[string][]ddm.DeclarationQueryStatus)
+	for enrollmentID, enrollmentStatuses := range statuses {
+		for _, status := range enrollmentStatuses {
+			if !status.StatusReceived.After(asOf) {
+				ret[enrollmentID] = append(ret[enrollmentID], status)
+			}
+		}
+	}
+	return ret
+}
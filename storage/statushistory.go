@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// DefaultStatusValueHistoryMax is the default maximum number of
+// recorded values kept per enrollment and status path.
+const DefaultStatusValueHistoryMax = 100
+
+// StatusValueHistoryRetriever retrieves the recorded history of status values.
+type StatusValueHistoryRetriever interface {
+	// RetrieveStatusValueHistory retrieves the recorded values of path
+	// for enrollmentIDs ordered from oldest to newest. Only the paths
+	// configured for history recording in the storage backend have
+	// recorded values.
+	RetrieveStatusValueHistory(ctx context.Context, enrollmentIDs []string, path string) (map[string][]StatusValue, error)
+}
+
+// StatusValueHistory configures which status value paths have their
+// changes recorded over time.
+type StatusValueHistory struct {
+	// Paths are the exact status paths to record
+	// (e.g. ".StatusItems.device.operating-system.version").
+	Paths []string
+
+	// Max is the maximum number of values kept per enrollment and path.
+	// The oldest values are removed first.
+	Max int
+}
+
+// Enabled reports whether any paths are recorded.
+func (h *StatusValueHistory) Enabled() bool {
+	return h != nil && len(h.Paths) > 0 && h.Max > 0
+}
+
+// Values returns the values of status that are recorded. Only values
+// of tracked paths that are not members of arrays are recorded.
+func (h *StatusValueHistory) Values(status *ddm.StatusReport) []ddm.StatusValue {
+	if !h.Enabled() || status == nil {
+		return nil
+	}
+	var ret []ddm.StatusValue
+	for _, v := range status.Values {
+		if v.ContainerType == "array" {
+			continue
+		}
+		for _, path := range h.Paths {
+			if v.Path == path {
+				ret = append(ret, v)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// StatusValuesAsOf reconstructs the status values of an enrollment as
+// they were at asOf. Values reported at or before asOf are returned as
+// is. Values reported later are replaced by the newest value recorded
+// at or before asOf in history, which maps status paths to their
+// recorded values ordered from oldest to newest. Values without any
+// such recorded value are omitted as their value at asOf is unknown.
+func StatusValuesAsOf(values []StatusValue, history map[string][]StatusValue, asOf time.Time) []StatusValue {
+	var ret []StatusValue
+	for _, v := range values {
+		if !v.Timestamp.After(asOf) {
+			ret = append(ret, v)
+			continue
+		}
+		recorded := history[v.Path]
+		for i := len(recorded) - 1; i >= 0; i-- {
+			if !recorded[i].Timestamp.After(asOf) {
+				ret = append(ret, recorded[i])
+				break
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// DeclarationStatusQuery selects enrollments by the status they last
+// reported for a declaration.
+type DeclarationStatusQuery struct {
+	Identifier string
+
+	// Valid matches the reported validity (e.g. "valid", "invalid",
+	// or "unknown") if not empty.
+	Valid string
+
+	// Active matches the reported active state if not nil.
+	Active *bool
+}
+
+// Validate checks that q has a declaration identifier.
+func (q *DeclarationStatusQuery) Validate() error {
+	if q == nil {
+		return errors.New("nil declaration status query")
+	}
+	return ValidateIdentifier("declaration identifier", q.Identifier)
+}
+
+// DeclarationStatusEnrollmentsRetriever retrieves enrollments by the
+// status they last reported for a declaration. Storage maintains an
+// index of the reported declaration status as status is stored so that
+// enrollments need not be scanned.
+type DeclarationStatusEnrollmentsRetriever interface {
+	// RetrieveDeclarationStatusEnrollments retrieves the sorted IDs of
+	// the enrollments whose last reported status of the declaration
+	// matches q.
+	RetrieveDeclarationStatusEnrollments(ctx context.Context, q *DeclarationStatusQuery) ([]string, error)
+}
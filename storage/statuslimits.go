@@ -0,0 +1,70 @@
+package storage
+
+import "github.com/jessepeterson/kmfddm/ddm"
+
+// StatusLimits limits the number of status values and errors stored so
+// that a chatty enrollment cannot grow storage without bound. When a
+// limit is exceeded the newest records are kept and the number of
+// dropped records is added to the status report. Zero is unlimited.
+type StatusLimits struct {
+	// ReportValues is the maximum number of values stored per status report.
+	ReportValues int
+
+	// ReportErrors is the maximum number of errors stored per status report.
+	ReportErrors int
+
+	// Values is the maximum number of values kept per enrollment.
+	Values int
+
+	// Errors is the maximum number of errors kept per enrollment.
+	Errors int
+}
+
+// Enabled reports whether any limit is set.
+func (l *StatusLimits) Enabled() bool {
+	return l != nil && (l.ReportValues > 0 || l.ReportErrors > 0 || l.Values > 0 || l.Errors > 0)
+}
+
+// Excess returns how many of n records exceed max.
+// Zero is returned if max is zero (unlimited).
+func Excess(n, max int) int {
+	if max < 1 || n <= max {
+		return 0
+	}
+	return n - max
+}
+
+// TruncateReport truncates the values and errors of status to the per
+// report limits. The values and errors that appear last in the status
+// report are kept.
+func (l *StatusLimits) TruncateReport(status *ddm.StatusReport) {
+	if !l.Enabled() || status == nil {
+		return
+	}
+	if n := Excess(len(status.Values), l.ReportValues); n > 0 {
+		status.Values = status.Values[n:]
+		status.DroppedValues += n
+	}
+	if n := Excess(len(status.Errors), l.ReportErrors); n > 0 {
+		status.Errors = status.Errors[n:]
+		status.DroppedErrors += n
+	}
+}
+
+// MaxValues returns the maximum number of values kept per enrollment.
+// Zero (unlimited) is returned for a nil l.
+func (l *StatusLimits) MaxValues() int {
+	if l == nil {
+		return 0
+	}
+	return l.Values
+}
+
+// MaxErrors returns the maximum number of errors kept per enrollment.
+// Zero (unlimited) is returned for a nil l.
+func (l *StatusLimits) MaxErrors() int {
+	if l == nil {
+		return 0
+	}
+	return l.Errors
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status value types as parsed from status reports.
+const (
+	StatusValueTypeString  = "string"
+	StatusValueTypeNumber  = "number"
+	StatusValueTypeBoolean = "boolean"
+)
+
+// Status value filter comparison operators.
+const (
+	OpEqual              = "eq"
+	OpNotEqual           = "ne"
+	OpLessThan           = "lt"
+	OpLessThanOrEqual    = "le"
+	OpGreaterThan        = "gt"
+	OpGreaterThanOrEqual = "ge"
+)
+
+// StatusValueFilter matches status values by comparing their typed
+// values to Value using Op. See CompareStatusValue for how values of
+// each type are compared.
+type StatusValueFilter struct {
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// Validate checks f for an unknown operator.
+func (f *StatusValueFilter) Validate() error {
+	return Categorize(ErrInvalid, f.validate())
+}
+
+func (f *StatusValueFilter) validate() error {
+	switch f.Op {
+	case OpEqual, OpNotEqual, OpLessThan, OpLessThanOrEqual, OpGreaterThan, OpGreaterThanOrEqual:
+		return nil
+	}
+	return fmt.Errorf("invalid status value filter operator: %q", f.Op)
+}
+
+// Match reports whether v matches f.
+func (f *StatusValueFilter) Match(v StatusValue) bool {
+	c, ok := CompareStatusValue(v.Type, v.Value, f.Value)
+	if !ok {
+		// values that can not be compared only match inequality
+		return f.Op == OpNotEqual
+	}
+	switch f.Op {
+	case OpEqual:
+		return c == 0
+	case OpNotEqual:
+		return c != 0
+	case OpLessThan:
+		return c < 0
+	case OpLessThanOrEqual:
+		return c <= 0
+	case OpGreaterThan:
+		return c > 0
+	case OpGreaterThanOrEqual:
+		return c >= 0
+	}
+	return false
+}
+
+// FilterStatusValues returns the values that match f.
+func FilterStatusValues(values []StatusValue, f *StatusValueFilter) []StatusValue {
+	var ret []StatusValue
+	for _, v := range values {
+		if f.Match(v) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+func parseBool(s string) (bool, bool) {
+	switch s {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+// CompareStatusValue compares a and b as values of valueType. The
+// result is -1, 0, or 1 if a is less than, equal to, or greater than b
+// and ok is false if the values can not be compared as valueType.
+//
+// Numbers are compared numerically and booleans order false before
+// true. Strings are compared as timestamps if both are RFC 3339
+// timestamps, as versions if both are dotted version numbers (e.g.
+// "17.1"), and lexically otherwise.
+func CompareStatusValue(valueType, a, b string) (c int, ok bool) {
+	switch valueType {
+	case StatusValueTypeNumber:
+		aF, errA := strconv.ParseFloat(a, 64)
+		bF, errB := strconv.ParseFloat(b, 64)
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case aF < bF:
+			return -1, true
+		case aF > bF:
+			return 1, true
+		}
+		return 0, true
+	case StatusValueTypeBoolean:
+		aB, okA := parseBool(a)
+		bB, okB := parseBool(b)
+		if !okA || !okB {
+			return 0, false
+		}
+		switch {
+		case aB == bB:
+			return 0, true
+		case bB:
+			return -1, true
+		}
+		return 1, true
+	}
+	aT, errA := time.Parse(time.RFC3339, a)
+	bT, errB := time.Parse(time.RFC3339, b)
+	if errA == nil && errB == nil {
+		switch {
+		case aT.Before(bT):
+			return -1, true
+		case aT.After(bT):
+			return 1, true
+		}
+		return 0, true
+	}
+	if aV, bV := parseVersion(a), parseVersion(b); aV != nil && bV != nil {
+		return compareVersions(aV, bV), true
+	}
+	return strings.Compare(a, b), true
+}
+
+// parseVersion parses the dotted version number s (e.g. "17.1.2").
+// Nil is returned if s is not a version number.
+func parseVersion(s string) []uint64 {
+	parts := strings.Split(s, ".")
+	ret := make([]uint64, len(parts))
+	for i, part := range parts {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil
+		}
+		ret[i] = n
+	}
+	return ret
+}
+
+// compareVersions compares the versions a and b component-wise.
+// Missing components compare as zero so that "17" equals "17.0".
+func compareVersions(a, b []uint64) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var aN, bN uint64
+		if i < len(a) {
+			aN = a[i]
+		}
+		if i < len(b) {
+			bN = b[i]
+		}
+		switch {
+		case aN < bN:
+			return -1
+		case aN > bN:
+			return 1
+		}
+	}
+	return 0
+}
+
+// SortStatusValues sorts values by path, type, and then by their typed values.
+// Values that can not be compared keep their relative order.
+func SortStatusValues(values []StatusValue, descending bool) {
+	sort.SliceStable(values, func(i, j int) bool {
+		if values[i].Path != values[j].Path {
+			return values[i].Path < values[j].Path
+		}
+		if values[i].Type != values[j].Type {
+			return values[i].Type < values[j].Type
+		}
+		c, _ := CompareStatusValue(values[i].Type, values[i].Value, values[j].Value)
+		if descending {
+			return c > 0
+		}
+		return c < 0
+	})
+}
+
+// MergeDeviceStatusValues merges the status values of the enrollments
+// in values (keyed by enrollment ID) into one logical view per device
+// keyed by device enrollment ID (see DeviceEnrollmentID). Each merged
+// value is tagged with the enrollment that reported it. The values of
+// the device channel come first followed by those of its user channels
+// in enrollment ID order.
+func MergeDeviceStatusValues(values map[string][]StatusValue) map[string][]StatusValue {
+	enrollmentIDs := make([]string, 0, len(values))
+	for enrollmentID := range values {
+		enrollmentIDs = append(enrollmentIDs, enrollmentID)
+	}
+	sort.Slice(enrollmentIDs, func(i, j int) bool {
+		// the device channel sorts before its user channels
+		di, dj := DeviceEnrollmentID(enrollmentIDs[i]), DeviceEnrollmentID(enrollmentIDs[j])
+		if di != dj {
+			return di < dj
+		} else if (di == enrollmentIDs[i]) != (dj == enrollmentIDs[j]) {
+			return di == enrollmentIDs[i]
+		}
+		return enrollmentIDs[i] < enrollmentIDs[j]
+	})
+	ret := make(map[string][]StatusValue)
+	for _, enrollmentID := range enrollmentIDs {
+		deviceID := DeviceEnrollmentID(enrollmentID)
+		for _, v := range values[enrollmentID] {
+			v.EnrollmentID = enrollmentID
+			ret[deviceID] = append(ret[deviceID], v)
+		}
+	}
+	return ret
+}
@@ -78,6 +78,10 @@ type EnrollmentDeclarationStorage interface {
 type StatusStorer interface {
 	// StoreDeclarationStatus stores the status report details.
 	// For later retrieval by the StatusAPIStorage interface(s).
+	// Sections of the status report that fail to store are appended
+	// to its quarantined fragments rather than returning an error.
+	// The quarantined fragments are stored for later retrieval by
+	// StatusQuarantineRetriever.
 	StoreDeclarationStatus(ctx context.Context, enrollmentID string, status *ddm.StatusReport) error
 }
 
@@ -151,6 +155,12 @@ type EnrollmentSetRemover interface {
 	RemoveEnrollmentSet(ctx context.Context, enrollmentID, setName string) (bool, error)
 }
 
+type EnrollmentDeclarationsRetriever interface {
+	// RetrieveEnrollmentDeclarations retrieves the declarations that
+	// enrollmentID is entitled to by way of its sets.
+	RetrieveEnrollmentDeclarations(ctx context.Context, enrollmentID string) ([]EnrollmentDeclaration, error)
+}
+
 // EnrollmentSetStorage are storage interfaces related to MDM enrollment IDs.
 type EnrollmentSetStorage interface {
 	EnrollmentSetsRetriever
@@ -160,12 +170,17 @@ type EnrollmentSetStorage interface {
 
 type StatusDeclarationsRetriever interface {
 	// RetrieveDeclarationStatus retrieves the status of the declarations for enrollmentIDs.
+	// Statuses reported for declarations no longer assigned to an
+	// enrollment (e.g. removed from its sets) are included and marked unassigned.
 	RetrieveDeclarationStatus(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error)
 }
 
 type StatusErrorsRetriever interface {
 	// RetrieveStatusErrors retrieves the collected errors for enrollmentIDs.
-	RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, offset, limit int) (map[string][]StatusError, error)
+	// Errors are limited to those with timestamps from since through until
+	// (inclusive). A zero since or until leaves that end of the range open.
+	// The offset and limit apply to the errors of each enrollment.
+	RetrieveStatusErrors(ctx context.Context, enrollmentIDs []string, since, until time.Time, offset, limit int) (map[string][]StatusError, error)
 }
 
 type StatusValuesRetriever interface {
@@ -177,6 +192,20 @@ type StatusReportRetriever interface {
 	RetrieveStatusReport(ctx context.Context, q StatusReportQuery) (*StoredStatusReport, error)
 }
 
+type PendingRemovalsRetriever interface {
+	// RetrievePendingRemovals retrieves the declarations that are no
+	// longer assigned to enrollmentIDs but which the enrollments last
+	// reported as active. That is, their removal has not yet been
+	// confirmed by the enrollment.
+	RetrievePendingRemovals(ctx context.Context, enrollmentIDs []string) (map[string][]ddm.DeclarationQueryStatus, error)
+}
+
+type SetStatusSummaryRetriever interface {
+	// RetrieveSetStatusSummary summarizes the reported status of the
+	// declarations in setName across the enrollments in setName.
+	RetrieveSetStatusSummary(ctx context.Context, setName string) ([]DeclarationStatusSummary, error)
+}
+
 // StatusAPIStorage are storage interfaces related to retrieving status channel data.
 type StatusAPIStorage interface {
 	StatusDeclarationsRetriever
@@ -0,0 +1,71 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testEnrollmentAnnotations(t *testing.T, store storage.EnrollmentAnnotationStorage, ctx context.Context) {
+	const enrollmentID, otherID = "test_golang_annotation_enrollment", "test_golang_annotation_other"
+
+	// storage may persist between test runs so start unannotated
+	if _, err := store.DeleteEnrollmentAnnotation(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	annotations, err := store.RetrieveEnrollmentAnnotations(ctx, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) > 0 {
+		t.Errorf("have: %v, want: none", annotations)
+	}
+
+	want := &storage.EnrollmentAnnotation{
+		EnrollmentID: enrollmentID,
+		Notes:        "replaced",
+		Timestamp:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err = store.StoreEnrollmentAnnotation(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	want = &storage.EnrollmentAnnotation{
+		EnrollmentID: enrollmentID,
+		Notes:        "loaner laptop",
+		Owner:        "Jane Appleseed",
+		Contact:      "jane@example.com",
+		AssetTags:    []string{"A-1234", "B-5678"},
+		Timestamp:    time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+	}
+	if err = store.StoreEnrollmentAnnotation(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if annotations, err = store.RetrieveEnrollmentAnnotations(ctx, []string{otherID, enrollmentID}); err != nil {
+		t.Fatal(err)
+	}
+	if len(annotations) != 1 {
+		t.Fatalf("annotations: have %d, want 1", len(annotations))
+	}
+	a := annotations[enrollmentID]
+	if a == nil || !a.Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("have: %v, want: %v", a, want)
+	}
+	a.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(a, want) {
+		t.Errorf("have: %v, want: %v", a, want)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteEnrollmentAnnotation(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+}
@@ -22,6 +22,43 @@ type allTestStorage interface {
 	storage.TokensDeclarationItemsRetriever
 	storage.EnrollmentIDRetriever
 	storage.DeclarationAPIStorage
+	storage.IdempotencyStorage
+	storage.EnrollmentDeclarationsRetriever
+	storage.CounterStorage
+	storage.ProfileStorage
+	storage.SetSnapshotStorage
+	storage.PendingChangeStorage
+	storage.UsageStorage
+	storage.DDMRepairer
+	storage.EnrollmentDDMRefresher
+	storage.DDMRebuildStorage
+	storage.PendingRemovalsRetriever
+	storage.DeclarationsPager
+	storage.SetsPager
+	storage.SetDeclarationConditionStorage
+	storage.DeclarationUsageRetriever
+	storage.HashMigrator
+	storage.EnrollmentFreezeStorage
+	storage.AssignmentScheduleStorage
+	storage.EnrollmentAnnotationStorage
+	storage.SetPatternStorage
+	storage.JournalRetriever
+	storage.EnrollmentStagedSetStorage
+	storage.LifecycleStageStorage
+	storage.EnrollmentRegistrationStorage
+	storage.CredentialStorage
+	storage.ComplianceStorage
+	storage.ServedHistoryStorage
+	storage.NotificationQueueStorage
+	storage.EnrollmentNotificationStorage
+	storage.DeclarationStatusEnrollmentsRetriever
+	storage.SetMetadataStorage
+	storage.DeclarationTagStorage
+	storage.OwnerStorage
+	storage.StatusErrorsRetriever
+	storage.StatusValuesRetriever
+	storage.StatusStorer
+	storage.DeclarationRetriever
 }
 
 func TestBasic(t *testing.T, storage allTestStorage, ctx context.Context) {
@@ -49,4 +86,123 @@ func TestBasic(t *testing.T, storage allTestStorage, ctx context.Context) {
 	t.Run("DeleteDeclaration", func(t *testing.T) {
 		testDeleteDeclaration(t, storage, ctx, decl.Identifier)
 	})
+
+	t.Run("Idempotency", func(t *testing.T) {
+		testIdempotency(t, storage, ctx)
+	})
+
+	t.Run("Counters", func(t *testing.T) {
+		testCounters(t, storage, ctx)
+	})
+
+	t.Run("Profiles", func(t *testing.T) {
+		testProfiles(t, storage, ctx)
+	})
+
+	t.Run("SetSnapshots", func(t *testing.T) {
+		testSetSnapshots(t, storage, ctx, decl)
+	})
+
+	t.Run("PendingChanges", func(t *testing.T) {
+		testPendingChanges(t, storage, ctx)
+	})
+
+	t.Run("Usage", func(t *testing.T) {
+		testUsage(t, storage, ctx)
+	})
+
+	t.Run("RepairEnrollmentDDM", func(t *testing.T) {
+		testRepairEnrollmentDDM(t, storage, ctx)
+	})
+
+	t.Run("DDMRebuilds", func(t *testing.T) {
+		testDDMRebuilds(t, storage, ctx)
+	})
+
+	t.Run("Paging", func(t *testing.T) {
+		testPaging(t, storage, ctx)
+	})
+
+	t.Run("SetDeclarationConditions", func(t *testing.T) {
+		testSetDeclarationConditions(t, storage, ctx)
+	})
+
+	t.Run("DeclarationUsage", func(t *testing.T) {
+		testDeclarationUsage(t, storage, ctx)
+	})
+
+	t.Run("EnrollmentFreeze", func(t *testing.T) {
+		testEnrollmentFreeze(t, storage, ctx)
+	})
+
+	t.Run("AssignmentSchedules", func(t *testing.T) {
+		testAssignmentSchedules(t, storage, ctx)
+	})
+
+	t.Run("EnrollmentAnnotations", func(t *testing.T) {
+		testEnrollmentAnnotations(t, storage, ctx)
+	})
+
+	t.Run("SetPatterns", func(t *testing.T) {
+		testSetPatterns(t, storage, ctx)
+	})
+
+	t.Run("Journal", func(t *testing.T) {
+		testJournal(t, storage, ctx)
+	})
+
+	t.Run("EnrollmentStagedSets", func(t *testing.T) {
+		testEnrollmentStagedSets(t, storage, ctx)
+	})
+	t.Run("LifecycleStages", func(t *testing.T) {
+		testLifecycleStages(t, storage, ctx)
+	})
+	t.Run("EnrollmentRegistrations", func(t *testing.T) {
+		testEnrollmentRegistrations(t, storage, ctx)
+	})
+	t.Run("Credentials", func(t *testing.T) {
+		testCredentials(t, storage, ctx)
+	})
+	t.Run("Compliance", func(t *testing.T) {
+		testCompliance(t, storage, ctx)
+	})
+	t.Run("ServedHistory", func(t *testing.T) {
+		testServedHistory(t, storage, ctx)
+	})
+	t.Run("NotificationQueue", func(t *testing.T) {
+		testNotificationQueue(t, storage, ctx)
+	})
+	t.Run("EnrollmentNotifications", func(t *testing.T) {
+		testEnrollmentNotifications(t, storage, ctx)
+	})
+	t.Run("SetMetadata", func(t *testing.T) {
+		testSetMetadata(t, storage, ctx)
+	})
+	t.Run("DeclarationTags", func(t *testing.T) {
+		testDeclarationTags(t, storage, ctx)
+	})
+
+	t.Run("Owners", func(t *testing.T) {
+		testOwners(t, storage, ctx)
+	})
+
+	t.Run("StatusErrorDedup", func(t *testing.T) {
+		testStatusErrorDedup(t, storage, ctx)
+	})
+
+	t.Run("StatusErrorLimits", func(t *testing.T) {
+		testStatusErrorLimits(t, storage, ctx)
+	})
+
+	t.Run("DeclarationStatusEnrollments", func(t *testing.T) {
+		testDeclarationStatusEnrollments(t, storage, ctx)
+	})
+
+	t.Run("DeviceStatusValues", func(t *testing.T) {
+		testDeviceStatusValues(t, storage, ctx)
+	})
+
+	t.Run("ErrorCategories", func(t *testing.T) {
+		testErrorCategories(t, storage, ctx)
+	})
 }
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// ClockTime is the time of the clock that TestStatusClock expects storage to use.
+var ClockTime = time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+
+// TestStatusClock tests that stored status errors are timestamped
+// with a storage clock of ClockTime.
+func TestStatusClock(t *testing.T, store statusLimitsStorage, ctx context.Context) {
+	// storage may persist between test runs so use an enrollment unique to this run
+	enrollmentID := fmt.Sprintf("test_golang_clock_%d", time.Now().UnixNano())
+
+	_, status, err := ddm.ParseStatus([]byte(`{"StatusItems":{"device":{"a":"1"}},"Errors":[{"n":1}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		t.Fatal(err)
+	}
+
+	// the backdated errors are only found in their time range
+	for _, r := range []struct {
+		since, until time.Time
+		want         int
+	}{
+		{time.Time{}, time.Time{}, 1},
+		{ClockTime.Add(-time.Minute), ClockTime.Add(time.Minute), 1},
+		{ClockTime.Add(time.Minute), time.Time{}, 0},
+	} {
+		errs, err := store.RetrieveStatusErrors(ctx, []string{enrollmentID}, r.since, r.until, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(errs[enrollmentID]), r.want; have != want {
+			t.Fatalf("errors between %v and %v: have %d, want %d", r.since, r.until, have, want)
+		}
+		for _, e := range errs[enrollmentID] {
+			if !e.Timestamp.Equal(ClockTime) {
+				t.Errorf("have: %v, want: %v", e.Timestamp, ClockTime)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testCompliance(t *testing.T, store storage.ComplianceStorage, ctx context.Context) {
+	const setName = "test_golang_compliance_set"
+	const enrollmentID = "test_golang_compliance_enrollment"
+
+	// storage may persist between test runs so start without a requirement
+	if _, err := store.DeleteComplianceRequirement(ctx, setName); err != nil {
+		t.Fatal(err)
+	}
+
+	want := &storage.ComplianceRequirement{
+		Set:          setName,
+		Declarations: []string{"test_golang_compliance_decl"},
+		Conditions: []storage.DeclarationCondition{{
+			Path:              ".StatusItems.device.operating-system.version",
+			StatusValueFilter: storage.StatusValueFilter{Op: storage.OpGreaterThanOrEqual, Value: "17"},
+		}},
+	}
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.StoreComplianceRequirement(ctx, want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	requirements, err := store.RetrieveComplianceRequirements(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, r := range requirements {
+		if r.Set == setName {
+			found = true
+			if !reflect.DeepEqual(&r, want) {
+				t.Errorf("have: %v, want: %v", r, want)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("requirement not found: %s", setName)
+	}
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteComplianceRequirement(ctx, setName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+
+	compliance := storage.EvaluateCompliance(enrollmentID, []storage.ComplianceRequirement{*want}, nil, nil)
+	compliance.Timestamp = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if compliance.Compliant || compliance.Total != 2 || len(compliance.Failures) != 2 {
+		t.Fatalf("unexpected compliance: %v", compliance)
+	}
+	if err = store.StoreEnrollmentCompliance(ctx, compliance); err != nil {
+		t.Fatal(err)
+	}
+	for _, ids := range [][]string{{enrollmentID}, nil} {
+		states, err := store.RetrieveEnrollmentCompliance(ctx, ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found = false
+		for _, c := range states {
+			if c.EnrollmentID != enrollmentID {
+				continue
+			}
+			found = true
+			if !c.Timestamp.Equal(compliance.Timestamp) {
+				t.Errorf("timestamp: have: %v, want: %v", c.Timestamp, compliance.Timestamp)
+			}
+			c.Timestamp = compliance.Timestamp
+			if !reflect.DeepEqual(c, compliance) {
+				t.Errorf("have: %v, want: %v", c, compliance)
+			}
+		}
+		if !found {
+			t.Errorf("compliance not found: %s", enrollmentID)
+		}
+	}
+}
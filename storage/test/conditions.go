@@ -0,0 +1,186 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type conditionStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.EnrollmentSetStorage
+	storage.TokensDeclarationItemsRetriever
+	storage.DeclarationRetriever
+	storage.EnrollmentDeclarationsRetriever
+	storage.SetDeclarationConditionStorage
+	storage.StatusStorer
+}
+
+func storeOSVersion(t *testing.T, store storage.StatusStorer, ctx context.Context, enrollmentID, version string) {
+	t.Helper()
+	raw := fmt.Sprintf(`{"StatusItems":{"device":{"operating-system":{"version":%q}}},"Errors":[]}`, version)
+	_, status, err := ddm.ParseStatus([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status.ID = "testSetDeclarationConditions-" + version
+	if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedDeclaration(t *testing.T, store conditionStorage, ctx context.Context, enrollmentID, declarationID string) bool {
+	t.Helper()
+	diJSON, err := store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inItems := strings.Contains(string(diJSON), `"`+declarationID+`"`)
+	decls, err := store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var inDecls bool
+	for _, d := range decls {
+		if d.Identifier == declarationID {
+			inDecls = true
+		}
+	}
+	if inItems != inDecls {
+		t.Errorf("declaration items (%v) and enrollment declarations (%v) disagree", inItems, inDecls)
+	}
+	return inItems
+}
+
+func testSetDeclarationConditions(t *testing.T, store conditionStorage, ctx context.Context) {
+	const (
+		enrollmentID = "test_golang_cond_enrollment"
+		setName      = "test_golang_cond_set"
+	)
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_", "test_golang_cond_", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, enrollmentID, setName); err != nil {
+		t.Fatal(err)
+	}
+
+	// storage may persist between test runs so start unconditional
+	if _, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, nil); err != nil {
+		t.Fatal(err)
+	}
+	storeOSVersion(t, store, ctx, enrollmentID, "9.0")
+	if !servedDeclaration(t, store, ctx, enrollmentID, decl.Identifier) {
+		t.Error("unconditional declaration not served")
+	}
+
+	cond := &storage.DeclarationCondition{
+		Path:              StatusValueHistoryPath,
+		StatusValueFilter: storage.StatusValueFilter{Op: storage.OpGreaterThanOrEqual, Value: "17"},
+	}
+	changed, err := store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, cond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected changed")
+	}
+	changed, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, cond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("expected not changed")
+	}
+
+	conds, err := store.RetrieveSetDeclarationConditions(ctx, setName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := conds[decl.Identifier]; have == nil || *have != *cond {
+		t.Errorf("condition: have: %v, want: %v", have, cond)
+	}
+
+	// versions compare numerically: 9.0 < 17
+	if servedDeclaration(t, store, ctx, enrollmentID, decl.Identifier) {
+		t.Error("declaration served with non-matching condition")
+	}
+	if _, err = store.RetrieveEnrollmentDeclarationJSON(ctx, decl.Identifier, ddm.ManifestType(decl.Type), enrollmentID); err == nil {
+		t.Error("expected error retrieving declaration with non-matching condition")
+	}
+
+	storeOSVersion(t, store, ctx, enrollmentID, "17.1")
+	if !servedDeclaration(t, store, ctx, enrollmentID, decl.Identifier) {
+		t.Error("declaration not served with matching condition")
+	}
+	if _, err = store.RetrieveEnrollmentDeclarationJSON(ctx, decl.Identifier, ddm.ManifestType(decl.Type), enrollmentID); err != nil {
+		t.Errorf("retrieving declaration with matching condition: %v", err)
+	}
+
+	// the most recently reported value is used
+	storeOSVersion(t, store, ctx, enrollmentID, "9.0")
+	if servedDeclaration(t, store, ctx, enrollmentID, decl.Identifier) {
+		t.Error("declaration served after downgrade")
+	}
+
+	_, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, &storage.DeclarationCondition{Path: cond.Path})
+	if err == nil {
+		t.Error("expected error for invalid condition")
+	}
+
+	_, err = store.StoreSetDeclarationCondition(ctx, setName+"_invalid", decl.Identifier, cond)
+	if !errors.Is(err, storage.ErrSetDeclarationNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrSetDeclarationNotFound)
+	}
+
+	changed, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected changed")
+	}
+	if !servedDeclaration(t, store, ctx, enrollmentID, decl.Identifier) {
+		t.Error("declaration not served after removing condition")
+	}
+
+	// conditions do not outlive the association
+	if _, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, cond); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	conds, err = store.RetrieveSetDeclarationConditions(ctx, setName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := conds[decl.Identifier]; have != nil {
+		t.Errorf("condition after re-association: %v", have)
+	}
+
+	if _, err = store.RemoveEnrollmentSet(ctx, enrollmentID, setName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteDeclaration(ctx, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+}
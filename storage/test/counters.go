@@ -0,0 +1,64 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testCounters(t *testing.T, store storage.CounterStorage, ctx context.Context) {
+	const declID = "test_golang_counter_decl"
+
+	// storage may persist between test runs so compare deltas
+	before, err := store.RetrieveCounters(ctx, storage.CounterScopeDeclaration, declID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalBefore, err := store.RetrieveCounters(ctx, storage.CounterScopeGlobal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.IncrementCounters(ctx, []storage.CounterIncrement{
+		{Scope: storage.CounterScopeGlobal, Name: storage.CounterNotifications, Delta: 3},
+		{Scope: storage.CounterScopeDeclaration, ID: declID, Name: storage.CounterNotifications, Delta: 2},
+		{Scope: storage.CounterScopeDeclaration, ID: declID, Name: storage.CounterDeclarationsServed, Delta: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.IncrementCounters(ctx, []storage.CounterIncrement{
+		{Scope: storage.CounterScopeDeclaration, ID: declID, Name: storage.CounterNotifications, Delta: 5},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := store.RetrieveCounters(ctx, storage.CounterScopeDeclaration, declID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := after[storage.CounterNotifications]-before[storage.CounterNotifications], int64(7); have != want {
+		t.Errorf("declaration notifications: have %d, want %d", have, want)
+	}
+	if have, want := after[storage.CounterDeclarationsServed]-before[storage.CounterDeclarationsServed], int64(1); have != want {
+		t.Errorf("declarations served: have %d, want %d", have, want)
+	}
+
+	globalAfter, err := store.RetrieveCounters(ctx, storage.CounterScopeGlobal, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := globalAfter[storage.CounterNotifications]-globalBefore[storage.CounterNotifications], int64(3); have != want {
+		t.Errorf("global notifications: have %d, want %d", have, want)
+	}
+
+	missing, err := store.RetrieveCounters(ctx, storage.CounterScopeSet, "test_golang_counter_missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing == nil || len(missing) != 0 {
+		t.Errorf("expected empty counters, got: %v", missing)
+	}
+}
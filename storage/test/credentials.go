@@ -0,0 +1,87 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testCredentials(t *testing.T, store storage.CredentialStorage, ctx context.Context) {
+	const name = "test_golang_credential"
+
+	// storage may persist between test runs so start without the credential
+	if _, err := store.DeleteCredential(ctx, name); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.RetrieveCredential(ctx, name)
+	if !errors.Is(err, storage.ErrCredentialNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrCredentialNotFound)
+	}
+
+	var prev string
+	for i, data := range [][]byte{[]byte("test_golang_cert_1"), []byte("test_golang_cert_2")} {
+		sum := sha256.Sum256(data)
+		want := &storage.Credential{
+			Name:        name,
+			ContentType: "application/pkix-cert",
+			SHA256:      hex.EncodeToString(sum[:]),
+			Size:        len(data),
+			Timestamp:   time.Date(2024, 1, 2, 3, 4, 5+i, 0, time.UTC),
+		}
+		if err = store.StoreCredential(ctx, want, data); err != nil {
+			t.Fatal(err)
+		}
+		c, err := store.RetrieveCredential(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SHA256 != want.SHA256 || c.Size != want.Size || c.ContentType != want.ContentType || !c.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("have: %v, want: %v", c, want)
+		}
+		credentials, err := store.RetrieveCredentials(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found bool
+		for _, c := range credentials {
+			found = found || c.Name == name
+		}
+		if !found {
+			t.Errorf("credential not found in list: %s", name)
+		}
+		stored, err := store.RetrieveCredentialData(ctx, want.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(stored, data) {
+			t.Errorf("data: have: %q, want: %q", stored, data)
+		}
+
+		// rotated data is replaced
+		if prev != "" {
+			if _, err = store.RetrieveCredentialData(ctx, prev); !errors.Is(err, storage.ErrCredentialNotFound) {
+				t.Errorf("have: %v, want: %v", err, storage.ErrCredentialNotFound)
+			}
+		}
+		prev = want.SHA256
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteCredential(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	if _, err = store.RetrieveCredentialData(ctx, prev); !errors.Is(err, storage.ErrCredentialNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrCredentialNotFound)
+	}
+}
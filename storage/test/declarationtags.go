@@ -0,0 +1,99 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type declarationTagStorage interface {
+	storage.DeclarationAPIStorage
+	storage.DeclarationTagStorage
+}
+
+func testDeclarationTags(t *testing.T, store declarationTagStorage, ctx context.Context) {
+	const tag1, tag2 = "test_golang_tag_baseline", "test_golang_tag_other"
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_", "test_golang_tag_", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = store.StoreDeclarationTag(ctx, decl.Identifier+"_invalid", tag1); err == nil {
+		t.Error("tagging missing declaration: should be an error")
+	}
+
+	for _, tag := range []string{tag1, tag2} {
+		for _, wantChanged := range []bool{true, false} {
+			changed, err := store.StoreDeclarationTag(ctx, decl.Identifier, tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != wantChanged {
+				t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+			}
+		}
+	}
+
+	tags, err := store.RetrieveDeclarationTags(ctx, decl.Identifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(tags)
+	if want := []string{tag1, tag2}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags: have: %v, want: %v", tags, want)
+	}
+
+	declarationIDs, err := store.RetrieveTagDeclarations(ctx, tag1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{decl.Identifier}; !reflect.DeepEqual(declarationIDs, want) {
+		t.Errorf("tag declarations: have: %v, want: %v", declarationIDs, want)
+	}
+
+	if tags, err = store.RetrieveTags(ctx); err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, tag := range tags {
+		found[tag] = true
+	}
+	if !found[tag1] || !found[tag2] {
+		t.Errorf("tags: missing tags in: %v", tags)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.RemoveDeclarationTag(ctx, decl.Identifier, tag2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	if declarationIDs, err = store.RetrieveTagDeclarations(ctx, tag2); err != nil {
+		t.Fatal(err)
+	}
+	if len(declarationIDs) > 0 {
+		t.Errorf("tag declarations: have: %v, want: none", declarationIDs)
+	}
+
+	// tags do not outlive the declaration
+	if _, err = store.DeleteDeclaration(ctx, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if declarationIDs, err = store.RetrieveTagDeclarations(ctx, tag1); err != nil {
+		t.Fatal(err)
+	}
+	if len(declarationIDs) > 0 {
+		t.Errorf("tag declarations: have: %v, want: none", declarationIDs)
+	}
+}
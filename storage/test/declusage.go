@@ -0,0 +1,113 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type declarationUsageStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.EnrollmentSetStorage
+	storage.DeclarationUsageRetriever
+}
+
+const testDeclUsageActDecl = `{
+    "Type": "com.apple.activation.simple",
+    "Payload": {
+        "StandardConfigurations": ["test_golang_declusage_decl"]
+    },
+    "Identifier": "test_golang_declusage_act"
+}`
+
+func testDeclarationUsage(t *testing.T, store declarationUsageStorage, ctx context.Context) {
+	const (
+		set1, set2 = "test_golang_declusage_set1", "test_golang_declusage_set2"
+		enr1, enr2 = "test_golang_declusage_enrollment1", "test_golang_declusage_enrollment2"
+	)
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_9e6a3aa7-5e4b-4d38-aacf-0f8058b2a899", "test_golang_declusage_decl", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	act, err := ddm.ParseDeclaration([]byte(testDeclUsageActDecl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []*ddm.Declaration{decl, act} {
+		if _, err = store.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, setName := range []string{set1, set2} {
+		if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// both enrollments are in set1 and should only be counted once
+	for _, es := range [][2]string{{enr1, set1}, {enr2, set1}, {enr2, set2}} {
+		if _, err = store.StoreEnrollmentSet(ctx, es[0], es[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := store.RetrieveDeclarationUsage(ctx, []string{act.Identifier, decl.Identifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []storage.DeclarationUsage{
+		{Identifier: act.Identifier, Sets: []string{}, ReferencedBy: []string{}},
+		{Identifier: decl.Identifier, Sets: []string{set1, set2}, Enrollments: 2, ReferencedBy: []string{act.Identifier}},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("have: %v, want: %v", usage, want)
+	}
+
+	// the usage of all declarations includes ours
+	usage, err = store.RetrieveDeclarationUsage(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for i, u := range usage {
+		if i > 0 && usage[i-1].Identifier > u.Identifier {
+			t.Errorf("usage not ordered: %v before %v", usage[i-1].Identifier, u.Identifier)
+		}
+		if u.Identifier == decl.Identifier {
+			found = true
+			if !reflect.DeepEqual(u, want[1]) {
+				t.Errorf("have: %v, want: %v", u, want[1])
+			}
+		}
+	}
+	if !found {
+		t.Errorf("declaration usage not found: %s", decl.Identifier)
+	}
+
+	_, err = store.RetrieveDeclarationUsage(ctx, []string{decl.Identifier, "test_golang_declusage_invalid"})
+	if !errors.Is(err, storage.ErrDeclarationNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrDeclarationNotFound)
+	}
+
+	// cleanup
+	for _, es := range [][2]string{{enr1, set1}, {enr2, set1}, {enr2, set2}} {
+		if _, err = store.RemoveEnrollmentSet(ctx, es[0], es[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, setName := range []string{set1, set2} {
+		if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range []*ddm.Declaration{act, decl} {
+		if _, err = store.DeleteDeclaration(ctx, d.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
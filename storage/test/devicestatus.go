@@ -0,0 +1,55 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type deviceStatusStorage interface {
+	storage.StatusStorer
+	storage.StatusValuesRetriever
+}
+
+func testDeviceStatusValues(t *testing.T, store deviceStatusStorage, ctx context.Context) {
+	// storage may persist between test runs so use an enrollment unique to this run
+	deviceID := fmt.Sprintf("test_golang_devicestatus_%d", time.Now().UnixNano())
+	userID := deviceID + storage.UserChannelSeparator + "user1"
+
+	for _, r := range []struct{ enrollmentID, raw string }{
+		{deviceID, `{"StatusItems":{"device":{"model":{"family":"Mac"}}}}`},
+		{userID, `{"StatusItems":{"device":{"operating-system":{"family":"macOS"}}}}`},
+	} {
+		_, status, err := ddm.ParseStatus([]byte(r.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.StoreDeclarationStatus(ctx, r.enrollmentID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values, err := store.RetrieveStatusValues(ctx, []string{userID, deviceID}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := storage.MergeDeviceStatusValues(values)
+	if have, want := len(merged), 1; have != want {
+		t.Fatalf("devices: have: %v, want: %v", have, want)
+	}
+	deviceValues := merged[deviceID]
+	if have, want := len(deviceValues), len(values[deviceID])+len(values[userID]); have != want || have < 2 {
+		t.Fatalf("merged values: have: %v, want: %v", have, want)
+	}
+	// device channel values come first
+	if have, want := deviceValues[0].EnrollmentID, deviceID; have != want {
+		t.Errorf("first value enrollment: have: %v, want: %v", have, want)
+	}
+	if have, want := deviceValues[len(deviceValues)-1].EnrollmentID, userID; have != want {
+		t.Errorf("last value enrollment: have: %v, want: %v", have, want)
+	}
+}
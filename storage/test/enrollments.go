@@ -14,6 +14,7 @@ type myStorage interface {
 	storage.EnrollmentIDRetriever
 	storage.DeclarationAPIStorage
 	storage.EnrollmentSetStorage
+	storage.EnrollmentDeclarationsRetriever
 }
 
 func testEnrollments(t *testing.T, store myStorage, ctx context.Context, d *ddm.Declaration, enrollmentID, setName string) {
@@ -70,6 +71,24 @@ func testEnrollments(t *testing.T, store myStorage, ctx context.Context, d *ddm.
 		t.Error("did not find ID in transitive list")
 	}
 
+	enrDecls, err := store.RetrieveEnrollmentDeclarations(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found = false
+	for _, enrDecl := range enrDecls {
+		if enrDecl.Identifier == d.Identifier {
+			found = true
+			if len(enrDecl.Sets) != 1 || enrDecl.Sets[0] != setName {
+				t.Errorf("invalid enrollment declaration sets: %v", enrDecl.Sets)
+			}
+			break
+		}
+	}
+	if !found {
+		t.Error("did not find declaration in enrollment declarations")
+	}
+
 	b, err := store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,59 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type errorsStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.SetDeclarationConditionStorage
+}
+
+func testErrorCategories(t *testing.T, store errorsStorage, ctx context.Context) {
+	const setName = "test_golang_errors_set"
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_9e6a3aa7-5e4b-4d38-aacf-0f8058b2a899", "test_golang_errors_decl", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.RetrieveDeclaration(ctx, decl.Identifier+"_missing")
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("retrieve missing declaration: expected not found: %v", err)
+	}
+
+	_, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier+"_missing")
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("store missing declaration in set: expected not found: %v", err)
+	}
+
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = store.StoreSetDeclarationCondition(ctx, setName, decl.Identifier, &storage.DeclarationCondition{})
+	if !errors.Is(err, storage.ErrInvalid) {
+		t.Errorf("store invalid condition: expected invalid: %v", err)
+	}
+
+	_, err = store.DeleteDeclaration(ctx, decl.Identifier)
+	if !errors.Is(err, storage.ErrConflict) {
+		t.Errorf("delete declaration in set: expected conflict: %v", err)
+	}
+
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteDeclaration(ctx, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+}
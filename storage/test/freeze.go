@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testEnrollmentFreeze(t *testing.T, store storage.EnrollmentFreezeStorage, ctx context.Context) {
+	const enrollmentID, otherID = "test_golang_freeze_enrollment", "test_golang_freeze_other"
+
+	// storage may persist between test runs so start unfrozen
+	if _, err := store.DeleteEnrollmentFreeze(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	f, err := store.RetrieveEnrollmentFreeze(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f != nil {
+		t.Errorf("have: %v, want: nil", f)
+	}
+
+	want := &storage.EnrollmentFreeze{
+		EnrollmentID:         enrollmentID,
+		Timestamp:            time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		TokensJSON:           json.RawMessage(`{"SyncTokens":{"DeclarationsToken":"a"}}`),
+		DeclarationItemsJSON: json.RawMessage(`{"DeclarationsToken":"a"}`),
+		Declarations:         map[string]json.RawMessage{"test_golang_freeze_decl": json.RawMessage(`{"Identifier":"test_golang_freeze_decl"}`)},
+	}
+	for i := 0; i < 2; i++ {
+		// storing twice replaces
+		if err = store.StoreEnrollmentFreeze(ctx, want); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f, err = store.RetrieveEnrollmentFreeze(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if f == nil || !f.Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("have: %v, want: %v", f, want)
+	}
+	f.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(f, want) {
+		t.Errorf("have: %v, want: %v", f, want)
+	}
+
+	frozen, err := store.RetrieveFrozenEnrollmentIDs(ctx, []string{otherID, enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := frozen, []string{enrollmentID}; !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteEnrollmentFreeze(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	if frozen, err = store.RetrieveFrozenEnrollmentIDs(ctx, []string{enrollmentID}); err != nil {
+		t.Fatal(err)
+	} else if len(frozen) > 0 {
+		t.Errorf("have: %v, want: none", frozen)
+	}
+}
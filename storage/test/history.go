@@ -0,0 +1,112 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// StatusValueHistoryPath is the status path that TestStatusValueHistory
+// expects the storage backend to record the history of.
+const StatusValueHistoryPath = ".StatusItems.device.operating-system.version"
+
+type historyStorage interface {
+	storage.StatusStorer
+	storage.StatusValueHistoryRetriever
+}
+
+// TestStatusValueHistory tests the recording of status value history.
+// The store should be configured to record StatusValueHistoryPath
+// keeping max values.
+func TestStatusValueHistory(t *testing.T, store historyStorage, ctx context.Context, max int) {
+	const enrollmentID = "test_golang_history_enrollment"
+
+	// storage may persist between test runs so use versions unique to this run
+	before, err := store.RetrieveStatusValueHistory(ctx, []string{enrollmentID}, StatusValueHistoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := len(before[enrollmentID])
+
+	var want []string
+	for i := 0; i < max+2; i++ {
+		version := fmt.Sprintf("14.%d.%d", run, i)
+		want = append(want, version)
+		raw := fmt.Sprintf(`{"StatusItems":{"device":{"operating-system":{"version":%q,"family":"macOS"}}},"Errors":[]}`, version)
+		// report each version twice; unchanged values are not recorded
+		for j := 0; j < 2; j++ {
+			_, status, err := ddm.ParseStatus([]byte(raw))
+			if err != nil {
+				t.Fatal(err)
+			}
+			status.ID = fmt.Sprintf("TestStatusValueHistory-%d-%d", i, j)
+			if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	want = want[len(want)-max:]
+
+	history, err := store.RetrieveStatusValueHistory(ctx, []string{enrollmentID}, StatusValueHistoryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var have []string
+	for _, v := range history[enrollmentID] {
+		have = append(have, v.Value)
+		if v.Type != storage.StatusValueTypeString {
+			t.Errorf("value type: have: %v, want: %v", v.Type, storage.StatusValueTypeString)
+		}
+	}
+	if fmt.Sprint(have) != fmt.Sprint(want) {
+		t.Errorf("history: have: %v, want: %v", have, want)
+	}
+
+	// untracked paths have no history
+	history, err = store.RetrieveStatusValueHistory(ctx, []string{enrollmentID}, ".StatusItems.device.operating-system.family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history[enrollmentID]) > 0 {
+		t.Errorf("untracked path has history: %v", history[enrollmentID])
+	}
+
+	testStatusValuesAsOf(t)
+}
+
+func testStatusValuesAsOf(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	values := []storage.StatusValue{
+		{Path: StatusValueHistoryPath, Value: "14.2", Timestamp: t0.Add(3 * time.Hour)},
+		{Path: ".StatusItems.device.operating-system.family", Value: "macOS", Timestamp: t0},
+		{Path: ".StatusItems.device.model.identifier", Value: "Mac14,2", Timestamp: t0.Add(2 * time.Hour)},
+	}
+	history := map[string][]storage.StatusValue{
+		StatusValueHistoryPath: {
+			{Path: StatusValueHistoryPath, Value: "14.0", Timestamp: t0},
+			{Path: StatusValueHistoryPath, Value: "14.1", Timestamp: t0.Add(time.Hour)},
+			{Path: StatusValueHistoryPath, Value: "14.2", Timestamp: t0.Add(3 * time.Hour)},
+		},
+	}
+	for _, tc := range []struct {
+		asOf time.Time
+		want string
+	}{
+		{t0.Add(-time.Hour), "[]"},
+		{t0.Add(90 * time.Minute), "[14.1 macOS]"},
+		{t0.Add(2 * time.Hour), "[14.1 macOS Mac14,2]"},
+		{t0.Add(4 * time.Hour), "[14.2 macOS Mac14,2]"},
+	} {
+		var have []string
+		for _, v := range storage.StatusValuesAsOf(values, history, tc.asOf) {
+			have = append(have, v.Value)
+		}
+		if fmt.Sprint(have) != tc.want {
+			t.Errorf("as of %s: have: %v, want: %v", tc.asOf, have, tc.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testIdempotency(t *testing.T, store storage.IdempotencyStorage, ctx context.Context) {
+	const key = "test_golang_idempotency_key"
+
+	resp, err := store.RetrieveIdempotentResponse(ctx, key+"_missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Error("response should be nil for missing key")
+	}
+
+	err = store.StoreIdempotentResponse(ctx, key, &storage.IdempotentResponse{
+		Fingerprint: "fingerprint1",
+		StatusCode:  204,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = store.StoreIdempotentResponse(ctx, key, &storage.IdempotentResponse{
+		Fingerprint: "fingerprint2",
+		StatusCode:  400,
+		ContentType: "application/json",
+		Body:        []byte(`{"error":"test"}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = store.RetrieveIdempotentResponse(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Fatal("nil response")
+	}
+	if have, want := resp.Fingerprint, "fingerprint2"; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := resp.StatusCode, 400; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := resp.Body, []byte(`{"error":"test"}`); !bytes.Equal(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	err = store.StoreIdempotentResponse(ctx, key, &storage.IdempotentResponse{
+		Fingerprint: "fingerprint3",
+		StatusCode:  204,
+		Timestamp:   time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// responses recorded since are kept
+	if err = store.DeleteIdempotentResponses(ctx, time.Now().Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = store.RetrieveIdempotentResponse(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if resp == nil {
+		t.Error("response deleted: should be kept")
+	}
+
+	if err = store.DeleteIdempotentResponses(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err = store.RetrieveIdempotentResponse(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil {
+		t.Error("response should be deleted")
+	}
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type journalStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.EnrollmentSetStorage
+	storage.JournalRetriever
+}
+
+func testJournal(t *testing.T, store journalStorage, ctx context.Context) {
+	const (
+		setName = "test_golang_journal_set"
+		enrID   = "test_golang_journal_enr"
+	)
+
+	var last int64
+	entries, err := store.RetrieveJournal(ctx, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) > 0 {
+		last = entries[len(entries)-1].Seq
+	}
+
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_9e6a3aa7-5e4b-4d38-aacf-0f8058b2a899", "test_golang_journal_decl", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, enrID, setName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.RemoveEnrollmentSet(ctx, enrID, setName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteDeclaration(ctx, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = store.RetrieveJournal(ctx, last, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []storage.JournalEntry{
+		{Op: storage.JournalStoreDeclaration, Declarations: []string{decl.Identifier}},
+		{Op: storage.JournalStoreSetDeclaration, Declarations: []string{decl.Identifier}, Sets: []string{setName}},
+		{Op: storage.JournalStoreEnrollmentSet, Sets: []string{setName}, Enrollments: []string{enrID}},
+		{Op: storage.JournalRemoveEnrollmentSet, Sets: []string{setName}, Enrollments: []string{enrID}},
+		{Op: storage.JournalRemoveSetDeclaration, Declarations: []string{decl.Identifier}, Sets: []string{setName}},
+		{Op: storage.JournalDeleteDeclaration, Declarations: []string{decl.Identifier}},
+	}
+	if have, want := len(entries), len(expected); have != want {
+		t.Fatalf("journal entries: have: %v, want: %v", have, want)
+	}
+	for i, entry := range entries {
+		if entry.Seq <= last {
+			t.Errorf("entry %d: sequence not increasing: %d after %d", i, entry.Seq, last)
+		}
+		last = entry.Seq
+		if have, want := entry.Op, expected[i].Op; have != want {
+			t.Errorf("entry %d: op: have: %v, want: %v", i, have, want)
+		}
+		if have, want := strings.Join(entry.Declarations, ","), strings.Join(expected[i].Declarations, ","); have != want {
+			t.Errorf("entry %d: declarations: have: %v, want: %v", i, have, want)
+		}
+		if have, want := strings.Join(entry.Sets, ","), strings.Join(expected[i].Sets, ","); have != want {
+			t.Errorf("entry %d: sets: have: %v, want: %v", i, have, want)
+		}
+		if have, want := strings.Join(entry.Enrollments, ","), strings.Join(expected[i].Enrollments, ","); have != want {
+			t.Errorf("entry %d: enrollments: have: %v, want: %v", i, have, want)
+		}
+	}
+
+	entries, err = store.RetrieveJournal(ctx, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(entries), 1; have != want {
+		t.Errorf("limited journal entries: have: %v, want: %v", have, want)
+	}
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type lifecycleStorage interface {
+	storage.EnrollmentSetStorage
+	storage.LifecycleStageStorage
+}
+
+func testLifecycleStages(t *testing.T, store lifecycleStorage, ctx context.Context) {
+	const (
+		setBase      = "test_golang_lifecycle_set_base"
+		setSetup     = "test_golang_lifecycle_set_setup"
+		setApps      = "test_golang_lifecycle_set_apps"
+		setOther     = "test_golang_lifecycle_set_other"
+		enrollmentID = "test_golang_lifecycle_enrollment"
+	)
+	stages := []storage.LifecycleStage{
+		{Name: "test_golang_lifecycle_provisioning", Sets: []string{setBase, setSetup}},
+		{Name: "test_golang_lifecycle_production", Sets: []string{setApps, setBase}},
+		{Name: "test_golang_lifecycle_offboarding", Sets: []string{}},
+	}
+	for i := range stages {
+		if _, err := store.StoreLifecycleStage(ctx, &stages[i]); err != nil {
+			t.Fatal(err)
+		}
+		changed, err := store.StoreLifecycleStage(ctx, &stages[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed {
+			t.Errorf("stage %s: expected storing again to not change", stages[i].Name)
+		}
+	}
+
+	retrieved, err := store.RetrieveLifecycleStages(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, stage := range retrieved {
+		for _, want := range stages {
+			if stage.Name == want.Name {
+				found++
+				if len(stage.Sets) != len(want.Sets) {
+					t.Errorf("stage %s: have: %v, want: %v", stage.Name, stage.Sets, want.Sets)
+				}
+			}
+		}
+	}
+	if found != len(stages) {
+		t.Errorf("found stages: have: %d, want: %d", found, len(stages))
+	}
+
+	// storage may persist between test runs so start from offboarding
+	if _, err = store.TransitionEnrollmentLifecycleStage(ctx, enrollmentID, stages[2].Name); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, enrollmentID, setOther); err != nil {
+		t.Fatal(err)
+	}
+
+	sets := func() []string {
+		t.Helper()
+		sets, err := store.RetrieveEnrollmentSets(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(sets)
+		return sets
+	}
+
+	for _, tc := range []struct {
+		stage   string
+		changed bool
+		sets    []string
+	}{
+		{stages[0].Name, true, []string{setBase, setOther, setSetup}},
+		{stages[0].Name, false, []string{setBase, setOther, setSetup}},
+		{stages[1].Name, true, []string{setApps, setBase, setOther}},
+		{stages[2].Name, true, []string{setOther}},
+	} {
+		changed, err := store.TransitionEnrollmentLifecycleStage(ctx, enrollmentID, tc.stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != tc.changed {
+			t.Errorf("%s: changed: have: %v, want: %v", tc.stage, changed, tc.changed)
+		}
+		if have := sets(); !reflect.DeepEqual(have, tc.sets) {
+			t.Errorf("%s: have: %v, want: %v", tc.stage, have, tc.sets)
+		}
+		stage, err := store.RetrieveEnrollmentLifecycleStage(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stage != tc.stage {
+			t.Errorf("have: %s, want: %s", stage, tc.stage)
+		}
+	}
+
+	_, err = store.TransitionEnrollmentLifecycleStage(ctx, enrollmentID, "test_golang_lifecycle_missing")
+	if !errors.Is(err, storage.ErrLifecycleStageNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrLifecycleStageNotFound)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteLifecycleStage(ctx, stages[1].Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("delete: changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testEnrollmentNotifications(t *testing.T, store storage.EnrollmentNotificationStorage, ctx context.Context) {
+	const enrollmentID = "test_golang_notified_enrollment"
+	const missingID = "test_golang_never_notified_enrollment"
+	now := time.Now().UTC().Truncate(time.Second)
+
+	for _, hash := range []string{"aaaa", "bbbb"} {
+		want := &storage.EnrollmentNotification{EnrollmentID: enrollmentID, TokensHash: hash, Timestamp: now}
+		if err := store.StoreEnrollmentNotifications(ctx, []*storage.EnrollmentNotification{want}); err != nil {
+			t.Fatal(err)
+		}
+		notifications, err := store.RetrieveEnrollmentNotifications(ctx, []string{enrollmentID, missingID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := notifications[enrollmentID]; !reflect.DeepEqual(have, want) {
+			t.Errorf("have: %v, want: %v", have, want)
+		}
+		if _, ok := notifications[missingID]; ok {
+			t.Error("expected no notification of never notified enrollment")
+		}
+	}
+}
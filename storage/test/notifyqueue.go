@@ -0,0 +1,74 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testNotificationQueue(t *testing.T, store storage.NotificationQueueStorage, ctx context.Context) {
+	now := time.Now().UTC().Truncate(time.Second)
+	batches := []*storage.NotificationBatch{
+		{ID: "test_golang_batch_1", Priority: "normal", EnrollmentIDs: []string{"E1", "E2"}, Timestamp: now},
+		{ID: "test_golang_batch_2", Priority: "high", EnrollmentIDs: []string{"E3"}, Timestamp: now},
+		{ID: "test_golang_batch_3", Priority: "normal", EnrollmentIDs: []string{"E4"}, Timestamp: now, NotBefore: now.Add(time.Hour)},
+	}
+
+	// storage may persist between test runs so start without the batches
+	for _, b := range batches {
+		if err := store.DeleteNotificationBatch(ctx, b.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := store.StoreNotificationBatch(ctx, &storage.NotificationBatch{ID: "test_golang_batch_empty"}); err == nil {
+		t.Error("expected error storing batch without enrollment IDs")
+	}
+
+	for _, b := range batches {
+		if err := store.StoreNotificationBatch(ctx, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// retrieve finds the test batches in order
+	find := func() []*storage.NotificationBatch {
+		t.Helper()
+		all, err := store.RetrieveNotificationBatches(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []*storage.NotificationBatch
+		for _, b := range all {
+			for _, want := range batches {
+				if b.ID == want.ID {
+					found = append(found, b)
+				}
+			}
+		}
+		return found
+	}
+
+	if have, want := find(), batches; !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	if err := store.DeleteNotificationBatch(ctx, batches[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := find(), batches[1:]; !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	for _, b := range batches[1:] {
+		if err := store.DeleteNotificationBatch(ctx, b.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := find(); len(have) != 0 {
+		t.Errorf("expected no batches, have: %v", have)
+	}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testOwners(t *testing.T, store storage.OwnerStorage, ctx context.Context) {
+	const alice, bob = "test_golang_owner_alice", "test_golang_owner_bob"
+	const decl1, decl2 = "test_golang_owned_decl1", "test_golang_owned_decl2"
+
+	for _, id := range []string{decl1, decl2} {
+		if err := store.StoreOwner(ctx, storage.OwnedDeclaration, id, alice); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.StoreOwner(ctx, storage.OwnedSet, decl1, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	// replace the owner
+	if err := store.StoreOwner(ctx, storage.OwnedDeclaration, decl2, bob); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		kind, principal string
+		want            []string
+	}{
+		{storage.OwnedDeclaration, alice, []string{decl1}},
+		{storage.OwnedDeclaration, bob, []string{decl2}},
+		{storage.OwnedSet, alice, nil},
+		{storage.OwnedSet, bob, []string{decl1}},
+	} {
+		ids, err := store.RetrieveOwned(ctx, tc.kind, tc.principal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(ids)
+		if have, want := ids, tc.want; !reflect.DeepEqual(have, want) {
+			t.Errorf("%s of %s: have: %v, want: %v", tc.kind, tc.principal, have, want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package test
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type pageFunc func(ctx context.Context, after string, limit int) ([]string, error)
+
+// testPages pages through page with limit and compares to all.
+func testPages(t *testing.T, ctx context.Context, page pageFunc, all []string, limit int) {
+	var paged []string
+	var after string
+	for {
+		keys, err := page(ctx, after, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) > limit {
+			t.Fatalf("page too large: have: %v, want: <= %v", len(keys), limit)
+		}
+		if len(keys) < 1 {
+			break
+		}
+		paged = append(paged, keys...)
+		after = keys[len(keys)-1]
+		if len(paged) > len(all) {
+			t.Fatalf("too many keys paged: have: %v, want: %v", len(paged), len(all))
+		}
+	}
+	// the backend may sort differently (e.g. by database collation)
+	sort.Strings(paged)
+	sort.Strings(all)
+	if have, want := len(paged), len(all); have != want {
+		t.Fatalf("have: %v, want: %v", have, want)
+	}
+	for i := range all {
+		if paged[i] != all[i] {
+			t.Errorf("key %d: have: %v, want: %v", i, paged[i], all[i])
+		}
+	}
+}
+
+type pagingStorage interface {
+	storage.DeclarationsRetriever
+	storage.DeclarationsPager
+	storage.SetRetreiver
+	storage.SetsPager
+}
+
+func testPaging(t *testing.T, store pagingStorage, ctx context.Context) {
+	declarations, err := store.RetrieveDeclarations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(declarations) < 1 {
+		t.Fatal("no declarations to page")
+	}
+	testPages(t, ctx, store.RetrieveDeclarationsPage, declarations, 2)
+
+	sets, err := store.RetrieveSets(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testPages(t, ctx, store.RetrieveSetsPage, sets, 2)
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testPendingChanges(t *testing.T, store storage.PendingChangeStorage, ctx context.Context) {
+	const changeID = "test_golang_pending_change"
+
+	if _, err := store.RetrievePendingChange(ctx, changeID+"_missing"); !errors.Is(err, storage.ErrPendingChangeNotFound) {
+		t.Errorf("expected pending change not found, got: %v", err)
+	}
+	if err := store.DeletePendingChange(ctx, changeID+"_missing"); !errors.Is(err, storage.ErrPendingChangeNotFound) {
+		t.Errorf("expected pending change not found, got: %v", err)
+	}
+
+	err := store.StorePendingChange(ctx, &storage.PendingChange{
+		ID:          changeID,
+		Principal:   "alice",
+		Method:      "PUT",
+		Path:        "/v1/set-declarations/default",
+		RawQuery:    "declaration=com.example.test",
+		ContentType: "application/json",
+		Body:        []byte(`{"test":true}`),
+		IfMatch:     `"etag"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change, err := store.RetrievePendingChange(ctx, changeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := change.Principal, "alice"; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := change.RawQuery, "declaration=com.example.test"; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+	if have, want := change.Body, []byte(`{"test":true}`); !bytes.Equal(have, want) {
+		t.Errorf("have: %s, want: %s", have, want)
+	}
+	if have, want := change.IfMatch, `"etag"`; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	changes, err := store.RetrievePendingChanges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range changes {
+		if c.ID == changeID {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("pending change not in list")
+	}
+
+	if err = store.DeletePendingChange(ctx, changeID); err != nil {
+		t.Fatal(err)
+	}
+	if err = store.DeletePendingChange(ctx, changeID); !errors.Is(err, storage.ErrPendingChangeNotFound) {
+		t.Errorf("expected pending change not found, got: %v", err)
+	}
+	if _, err = store.RetrievePendingChange(ctx, changeID); !errors.Is(err, storage.ErrPendingChangeNotFound) {
+		t.Errorf("expected pending change not found, got: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testProfiles(t *testing.T, store storage.ProfileStorage, ctx context.Context) {
+	const profileID = "test_golang_profile"
+
+	if _, err := store.RetrieveProfile(ctx, "test_golang_profile_nonexistent"); !errors.Is(err, storage.ErrProfileNotFound) {
+		t.Errorf("expected profile not found, got: %v", err)
+	}
+
+	for _, raw := range [][]byte{[]byte("profile1"), []byte("profile2")} {
+		if err := store.StoreProfile(ctx, profileID, raw); err != nil {
+			t.Fatal(err)
+		}
+		have, err := store.RetrieveProfile(ctx, profileID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(have, raw) {
+			t.Errorf("profile: have: %s, want: %s", have, raw)
+		}
+	}
+}
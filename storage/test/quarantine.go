@@ -0,0 +1,56 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testStatusQuarantine(t *testing.T, store statusStorage, ctx context.Context) {
+	const enrollmentID = "test_golang_quarantine_enrollment"
+
+	// the declarations are malformed but the device values are not
+	_, status, err := ddm.ParseStatus([]byte(`{"StatusItems":{"device":{"model":{"family":"iPhone"}},"management":{"declarations":["x"]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status.ID = "test_golang_quarantine_status"
+	if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := store.RetrieveStatusValues(ctx, []string{enrollmentID}, ".StatusItems.device.model.family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := getPathValue(values[enrollmentID], ".StatusItems.device.model.family"), "iPhone"; have != want {
+		t.Errorf("status value: have %q, want %q", have, want)
+	}
+
+	quarantine, err := store.RetrieveStatusQuarantine(ctx, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := quarantine[enrollmentID]
+	if len(q) < 1 {
+		t.Fatal("no quarantined status")
+	}
+	last := q[len(q)-1]
+	if have, want := last.Path, ddm.StatusPathDeclarations; have != want {
+		t.Errorf("path: have %q, want %q", have, want)
+	}
+	if have, want := last.StatusID, status.ID; have != want {
+		t.Errorf("status ID: have %q, want %q", have, want)
+	}
+	if have, want := string(last.JSON), `["x"]`; have != want {
+		t.Errorf("fragment: have %s, want %s", have, want)
+	}
+	if last.Error == "" || last.Timestamp.IsZero() {
+		t.Errorf("incomplete quarantined status: %v", last)
+	}
+	if len(q) > storage.StatusQuarantineMax {
+		t.Errorf("quarantined: have %d, want at most %d", len(q), storage.StatusQuarantineMax)
+	}
+}
@@ -0,0 +1,68 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type registrationStorage interface {
+	storage.EnrollmentIDRetriever
+	storage.EnrollmentRegistrationStorage
+}
+
+func testEnrollmentRegistrations(t *testing.T, store registrationStorage, ctx context.Context) {
+	const enrollmentID = "test_golang_registration_enrollment"
+
+	// storage may persist between test runs so start unregistered
+	if _, err := store.DeleteEnrollmentRegistration(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	_, err := store.RetrieveEnrollmentRegistration(ctx, enrollmentID)
+	if !errors.Is(err, storage.ErrEnrollmentRegistrationNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrEnrollmentRegistrationNotFound)
+	}
+
+	want := &storage.EnrollmentRegistration{
+		EnrollmentID: enrollmentID,
+		Metadata:     map[string]string{"serial_number": "C02XYZ", "model": "Mac14,2"},
+		Timestamp:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err = store.StoreEnrollmentRegistration(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.RetrieveEnrollmentRegistration(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r == nil || !r.Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("have: %v, want: %v", r, want)
+	}
+	r.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("have: %v, want: %v", r, want)
+	}
+
+	// registered enrollments are known before their first sync
+	ids, err := store.RetrieveEnrollmentIDs(ctx, nil, nil, []string{enrollmentID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{enrollmentID}) {
+		t.Errorf("have: %v, want: %v", ids, []string{enrollmentID})
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteEnrollmentRegistration(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+}
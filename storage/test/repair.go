@@ -0,0 +1,48 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testRepairEnrollmentDDM(t *testing.T, store storage.DDMRepairer, ctx context.Context) {
+	// storage may persist between test runs so repair first
+	report, err := store.RepairEnrollmentDDM(ctx, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Repaired {
+		t.Error("expected repaired")
+	}
+
+	report2, err := store.RepairEnrollmentDDM(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report2.Repaired {
+		t.Error("expected not repaired for dry run")
+	}
+	if have, want := report2.Enrollments, report.Enrollments; have != want {
+		t.Errorf("enrollments: have: %v, want: %v", have, want)
+	}
+	if have, want := len(report2.Mismatches), 0; have != want {
+		t.Errorf("mismatches after repair: have: %v, want: %v: %v", have, want, report2.Mismatches)
+	}
+}
+
+func testDDMRebuilds(t *testing.T, store storage.DDMRebuildStorage, ctx context.Context) {
+	// storage may persist between test runs so retry first
+	if _, err := store.RetryDDMRebuilds(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilds, err := store.RetrieveDDMRebuilds(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(rebuilds), 0; have != want {
+		t.Errorf("rebuilds after retry: have: %v, want: %v: %v", have, want, rebuilds)
+	}
+}
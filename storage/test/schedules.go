@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testAssignmentSchedules(t *testing.T, store storage.AssignmentScheduleStorage, ctx context.Context) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	end := start.Add(time.Hour)
+	want := []*storage.AssignmentSchedule{
+		{ID: "test_golang_schedule_1", Kind: storage.AssignmentSetDeclaration, Set: "test_golang_schedule_set", Declaration: "test_golang_schedule_decl", Start: &start, End: &end},
+		{ID: "test_golang_schedule_2", Kind: storage.AssignmentEnrollmentSet, Set: "test_golang_schedule_set", Enrollment: "test_golang_schedule_enrollment", End: &end},
+	}
+
+	// retrieve only our schedules as others may exist in storage
+	retrieve := func() []*storage.AssignmentSchedule {
+		t.Helper()
+		schedules, err := store.RetrieveAssignmentSchedules(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ours []*storage.AssignmentSchedule
+		for _, a := range schedules {
+			if a.ID == want[0].ID || a.ID == want[1].ID {
+				ours = append(ours, a)
+			}
+		}
+		return ours
+	}
+
+	for i := len(want) - 1; i >= 0; i-- {
+		if err := store.StoreAssignmentSchedule(ctx, want[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := retrieve(); !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	// storing again replaces
+	want[1].Active = true
+	if err := store.StoreAssignmentSchedule(ctx, want[1]); err != nil {
+		t.Fatal(err)
+	}
+	if have := retrieve(); !reflect.DeepEqual(have, want) {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	for _, a := range want {
+		if err := store.DeleteAssignmentSchedule(ctx, a.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := retrieve(); len(have) != 0 {
+		t.Errorf("have: %v, want: none", have)
+	}
+	err := store.DeleteAssignmentSchedule(ctx, want[0].ID)
+	if !errors.Is(err, storage.ErrAssignmentScheduleNotFound) {
+		t.Errorf("have: %v, want: %v", err, storage.ErrAssignmentScheduleNotFound)
+	}
+}
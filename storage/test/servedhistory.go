@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testServedHistory(t *testing.T, store storage.ServedHistoryStorage, ctx context.Context) {
+	const enrollmentID = "test_golang_served_history_enrollment"
+	const max = 2
+
+	// storage may persist between test runs so serve unique documents
+	nonce := time.Now().UnixNano()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var docs []*storage.ServedDocument
+	for i := 0; i < 3; i++ {
+		body := []byte(fmt.Sprintf(`{"SyncTokens":{"DeclarationsToken":"%d-%d"}}`, nonce, i))
+		doc, err := storage.NewServedDocument(storage.ServedTokens, body, i == 2, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, doc)
+		for _, wantChanged := range []bool{true, false} {
+			changed, err := store.StoreServedDocument(ctx, enrollmentID, doc, max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != wantChanged {
+				t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+			}
+		}
+	}
+
+	body := []byte(fmt.Sprintf(`{"Declarations":{"Activations":[]},"DeclarationsToken":"%d"}`, nonce))
+	doc, err := storage.NewServedDocument(storage.ServedDeclarationItems, body, false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreServedDocument(ctx, enrollmentID, doc, max); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := store.RetrieveServedHistory(ctx, enrollmentID, storage.ServedTokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(history), max; have != want {
+		t.Fatalf("history length: have: %v, want: %v", have, want)
+	}
+	// most recent first
+	for i, want := range []*storage.ServedDocument{docs[2], docs[1]} {
+		if have := history[i]; have.Hash != want.Hash || string(have.Body) != string(want.Body) || !have.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("history[%d]: have: %v, want: %v", i, have, want)
+		}
+	}
+
+	history, err = store.RetrieveServedHistory(ctx, enrollmentID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[string]int)
+	for _, d := range history {
+		kinds[d.Kind]++
+	}
+	if kinds[storage.ServedTokens] != max || kinds[storage.ServedDeclarationItems] < 1 {
+		t.Errorf("history kinds: have: %v", kinds)
+	}
+}
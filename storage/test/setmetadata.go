@@ -0,0 +1,71 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+func testSetMetadata(t *testing.T, store storage.SetMetadataStorage, ctx context.Context) {
+	const setName, otherName = "test_golang_metadata_set", "test_golang_metadata_other"
+
+	// storage may persist between test runs so start without metadata
+	if _, err := store.DeleteSetMetadata(ctx, setName); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := store.RetrieveSetMetadata(ctx, []string{setName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) > 0 {
+		t.Errorf("have: %v, want: none", metadata)
+	}
+
+	want := &storage.SetMetadata{
+		SetName:     setName,
+		Description: "replaced",
+		Timestamp:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err = store.StoreSetMetadata(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+	want = &storage.SetMetadata{
+		SetName:     setName,
+		Description: "Laptops of the sales team",
+		Owner:       "IT",
+		Color:       "#1E90FF",
+		Tags:        []string{"sales", "laptops"},
+		Timestamp:   time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC),
+	}
+	if err = store.StoreSetMetadata(ctx, want); err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata, err = store.RetrieveSetMetadata(ctx, []string{otherName, setName}); err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 1 {
+		t.Fatalf("metadata: have %d, want 1", len(metadata))
+	}
+	m := metadata[setName]
+	if m == nil || !m.Timestamp.Equal(want.Timestamp) {
+		t.Fatalf("have: %v, want: %v", m, want)
+	}
+	m.Timestamp = want.Timestamp
+	if !reflect.DeepEqual(m, want) {
+		t.Errorf("have: %v, want: %v", m, want)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.DeleteSetMetadata(ctx, setName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type setPatternStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.TokensDeclarationItemsRetriever
+	storage.DeclarationRetriever
+	storage.EnrollmentDeclarationsRetriever
+	storage.SetPatternStorage
+}
+
+func testSetPatterns(t *testing.T, store setPatternStorage, ctx context.Context) {
+	const (
+		setName = "test_golang_setpattern_set"
+		enr1    = "test_golang_setpattern_school1-dev1"
+		enr2    = "test_golang_setpattern_school2-dev1"
+	)
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_9e6a3aa7-5e4b-4d38-aacf-0f8058b2a899", "test_golang_setpattern_decl", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []storage.SetPattern{
+		{Set: setName, Kind: "invalid", Pattern: "a"},
+		{Set: setName, Kind: storage.SetPatternGlob, Pattern: "["},
+		{Set: setName, Kind: storage.SetPatternRegex, Pattern: "("},
+		{Kind: storage.SetPatternPrefix, Pattern: "a"},
+	} {
+		if err = invalid.Validate(); err == nil {
+			t.Errorf("expected error validating %v", invalid)
+		}
+	}
+
+	// storage may persist between test runs so start without patterns
+	patterns := []*storage.SetPattern{
+		{Set: setName, Kind: storage.SetPatternPrefix, Pattern: "test_golang_setpattern_school1-"},
+		{Set: setName, Kind: storage.SetPatternRegex, Pattern: `^test_golang_setpattern_school1-dev\d$`},
+	}
+	for _, p := range patterns {
+		if _, err = store.RemoveSetPattern(ctx, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hasDecl := func(enrollmentID string) bool {
+		t.Helper()
+		diJSON, err := store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Contains(string(diJSON), decl.Identifier)
+	}
+
+	// retrieving the declaration items first makes the enrollment known
+	if hasDecl(enr1) {
+		t.Error("declaration served before pattern stored")
+	}
+
+	for _, p := range patterns {
+		for _, wantChanged := range []bool{true, false} {
+			changed, err := store.StoreSetPattern(ctx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != wantChanged {
+				t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+			}
+		}
+	}
+
+	all, err := store.RetrieveSetPatterns(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found int
+	for _, p := range all {
+		if p.Set == setName {
+			found++
+		}
+	}
+	if found != len(patterns) {
+		t.Errorf("patterns: have: %d, want: %d", found, len(patterns))
+	}
+
+	if !hasDecl(enr1) {
+		t.Error("declaration not served to matching enrollment")
+	}
+	if hasDecl(enr2) {
+		t.Error("declaration served to non-matching enrollment")
+	}
+	if _, err = store.RetrieveEnrollmentDeclarationJSON(ctx, decl.Identifier, "configuration", enr1); err != nil {
+		t.Errorf("retrieving declaration: %v", err)
+	}
+	decls, err := store.RetrieveEnrollmentDeclarations(ctx, enr1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(decls) != 1 {
+		t.Fatalf("declarations: have: %d, want: 1", len(decls))
+	}
+	want := []storage.EnrollmentDeclaration{{
+		Identifier:  decl.Identifier,
+		Type:        decl.Type,
+		ServerToken: decls[0].ServerToken,
+		Sets:        []string{setName},
+	}}
+	if !reflect.DeepEqual(decls, want) {
+		t.Errorf("have: %v, want: %v", decls, want)
+	}
+
+	for _, p := range patterns {
+		if changed, err := store.RemoveSetPattern(ctx, p); err != nil {
+			t.Fatal(err)
+		} else if !changed {
+			t.Errorf("pattern not removed: %v", p)
+		}
+	}
+	if hasDecl(enr1) {
+		t.Error("declaration served after patterns removed")
+	}
+
+	// cleanup
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.DeleteDeclaration(ctx, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+}
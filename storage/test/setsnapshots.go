@@ -0,0 +1,81 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type setSnapshotStorage interface {
+	storage.DeclarationStorer
+	storage.SetDeclarationStorage
+	storage.SetSnapshotStorage
+}
+
+func testSetSnapshots(t *testing.T, store setSnapshotStorage, ctx context.Context, decl *ddm.Declaration) {
+	const setName = "test_golang_snapshot_set"
+
+	if _, err := store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	// storage may persist between test runs so start from an empty set
+	if _, err := store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.StoreSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err := store.RetrieveSetSnapshots(ctx, setName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) < 1 || len(snapshots[0].Declarations) != 1 || snapshots[0].Declarations[0] != decl.Identifier {
+		t.Fatalf("newest snapshot does not contain declaration: %v", snapshots)
+	}
+	snapshotID := snapshots[0].ID
+
+	// the security baseline was removed at 2am
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = store.RetrieveSetSnapshots(ctx, setName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) < 2 || len(snapshots[0].Declarations) != 0 || snapshots[1].ID != snapshotID {
+		t.Errorf("unexpected snapshots after removal: %v", snapshots)
+	}
+
+	changed, err := store.RestoreSetSnapshot(ctx, setName, snapshotID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected restore to change the set")
+	}
+	declarationIDs, err := store.RetrieveSetDeclarations(ctx, setName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(declarationIDs) != 1 || declarationIDs[0] != decl.Identifier {
+		t.Errorf("restored set declarations: %v", declarationIDs)
+	}
+
+	if changed, err = store.RestoreSetSnapshot(ctx, setName, snapshotID); err != nil {
+		t.Fatal(err)
+	} else if changed {
+		t.Error("expected restoring the current declarations to not change the set")
+	}
+
+	if _, err = store.RestoreSetSnapshot(ctx, setName, "nonexistent"); !errors.Is(err, storage.ErrSetSnapshotNotFound) {
+		t.Errorf("expected snapshot not found, got: %v", err)
+	}
+
+	if _, err = store.RemoveSetDeclaration(ctx, setName, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,127 @@
+package test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type stagedSetStorage interface {
+	setAndDeclStorage
+	storage.DeclarationAPIStorage
+	storage.EnrollmentSetStorage
+	storage.TokensDeclarationItemsRetriever
+	storage.EnrollmentStagedSetStorage
+}
+
+func testEnrollmentStagedSets(t *testing.T, store stagedSetStorage, ctx context.Context) {
+	const (
+		setA         = "test_golang_staging_set_a"
+		setB         = "test_golang_staging_set_b"
+		enrollmentID = "test_golang_staging_enrollment"
+	)
+	decl, err := ddm.ParseDeclaration([]byte(strings.Replace(testDecl, "test_golang_9e6a3aa7-5e4b-4d38-aacf-0f8058b2a899", "test_golang_staging_decl", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreDeclaration(ctx, decl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreSetDeclaration(ctx, setA, decl.Identifier); err != nil {
+		t.Fatal(err)
+	}
+
+	// storage may persist between test runs so start with only setA current
+	for _, setName := range []string{setA, setB} {
+		if _, err = store.RemoveEnrollmentStagedSet(ctx, enrollmentID, setName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = store.RemoveEnrollmentSet(ctx, enrollmentID, setB); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.StoreEnrollmentSet(ctx, enrollmentID, setA); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := func() string {
+		t.Helper()
+		b, err := store.RetrieveTokensJSON(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	sets := func() ([]string, []string) {
+		t.Helper()
+		current, err := store.RetrieveEnrollmentSets(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		staged, err := store.RetrieveEnrollmentStagedSets(ctx, enrollmentID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return current, staged
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.StoreEnrollmentStagedSet(ctx, enrollmentID, setB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	if current, staged := sets(); !reflect.DeepEqual(current, []string{setA}) || !reflect.DeepEqual(staged, []string{setB}) {
+		t.Errorf("have: %v and %v, want: %v and %v", current, staged, []string{setA}, []string{setB})
+	}
+
+	tokensA := tokens()
+	if !strings.Contains(tokensA, "DeclarationsToken") {
+		t.Fatalf("tokens: %s", tokensA)
+	}
+
+	changed, err := store.SwapEnrollmentStagedSets(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected swap to change sets")
+	}
+	if current, staged := sets(); !reflect.DeepEqual(current, []string{setB}) || !reflect.DeepEqual(staged, []string{setA}) {
+		t.Errorf("have: %v and %v, want: %v and %v", current, staged, []string{setB}, []string{setA})
+	}
+	if tokensB := tokens(); tokensB == tokensA {
+		t.Error("tokens did not change after swap")
+	}
+
+	// swapping again rolls back
+	if _, err = store.SwapEnrollmentStagedSets(ctx, enrollmentID); err != nil {
+		t.Fatal(err)
+	}
+	if current, staged := sets(); !reflect.DeepEqual(current, []string{setA}) || !reflect.DeepEqual(staged, []string{setB}) {
+		t.Errorf("have: %v and %v, want: %v and %v", current, staged, []string{setA}, []string{setB})
+	}
+	if have := tokens(); have != tokensA {
+		t.Errorf("have: %s, want: %s", have, tokensA)
+	}
+
+	for _, wantChanged := range []bool{true, false} {
+		changed, err := store.RemoveEnrollmentStagedSet(ctx, enrollmentID, setB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != wantChanged {
+			t.Errorf("changed: have: %v, want: %v", changed, wantChanged)
+		}
+	}
+	if _, staged := sets(); len(staged) != 0 {
+		t.Errorf("have: %v, want: none", staged)
+	}
+}
@@ -2,9 +2,11 @@ package test
 
 import (
 	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/jessepeterson/kmfddm/ddm"
 	"github.com/jessepeterson/kmfddm/storage"
@@ -16,12 +18,16 @@ type statusStorage interface {
 	storage.SetDeclarationStorage
 	storage.EnrollmentSetStorer
 	storage.StatusAPIStorage
+	storage.SetStatusSummaryRetriever
+	storage.PendingRemovalsRetriever
+	storage.StatusQuarantineRetriever
 }
 
 const statusFile1 = "testdata/status.1st.json"
 const statusFile2 = "testdata/status.D0.error.json"
 const statusFileID1 = "go.test.A047820F-FC6B-4104-BED0-466876D82BB8"
 const statusFileID2 = "go.test.D0463AF6-D0BF-5D06-BBBC-4A9A1386D613"
+const statusFileID3 = "go.test.7D1C3E0A-3F3B-4C51-9B0E-UNASSIGNED01"
 
 const testDecl2 = `{
     "Type": "com.apple.configuration.management.test",
@@ -68,6 +74,11 @@ func TestBasicStatus(t *testing.T, pathToDDMTestdata string, store statusStorage
 		t.Error("report raw is zero")
 	}
 
+	q = storage.StatusReportQuery{EnrollmentID: "TestBasicStatus-NoReport", Index: &zero}
+	if _, err = store.RetrieveStatusReport(ctx, q); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("status report of unknown enrollment: have: %v, want: %v", err, storage.ErrNotFound)
+	}
+
 	enrollmentValues, err := store.RetrieveStatusValues(ctx, []string{statusFileID1}, "")
 	if err != nil {
 		t.Fatal(err)
@@ -86,6 +97,12 @@ func TestBasicStatus(t *testing.T, pathToDDMTestdata string, store statusStorage
 		t.Errorf("have: %v, want: %v", have, want)
 	}
 
+	for _, v := range values {
+		if v.Path == ".StatusItems.device.operating-system.family" && v.Type != storage.StatusValueTypeString {
+			t.Errorf("value type: have: %v, want: %v", v.Type, storage.StatusValueTypeString)
+		}
+	}
+
 	jsonBytes, err = os.ReadFile(filepath.Join(pathToDDMTestdata, statusFile2))
 	if err != nil {
 		t.Fatal(err)
@@ -135,7 +152,7 @@ func TestBasicStatus(t *testing.T, pathToDDMTestdata string, store statusStorage
 		t.Error("report raw is zero")
 	}
 
-	ddmErrorMap, err := store.RetrieveStatusErrors(ctx, []string{statusFileID2}, 0, 10)
+	ddmErrorMap, err := store.RetrieveStatusErrors(ctx, []string{statusFileID2}, time.Time{}, time.Time{}, 0, 10)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -157,6 +174,29 @@ func TestBasicStatus(t *testing.T, pathToDDMTestdata string, store statusStorage
 		t.Errorf("have: %v, want: %v", have, want)
 	}
 
+	// time ranges that exclude the errors
+	for _, r := range [][2]time.Time{
+		{time.Now().Add(time.Hour), {}},
+		{{}, time.Date(1971, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		ddmErrorMap, err = store.RetrieveStatusErrors(ctx, []string{statusFileID2}, r[0], r[1], 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(ddmErrorMap[statusFileID2]), 0; have != want {
+			t.Errorf("have: %v, want: %v", have, want)
+		}
+	}
+
+	// a time range that includes the errors
+	ddmErrorMap, err = store.RetrieveStatusErrors(ctx, []string{statusFileID2}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ddmErrorMap[statusFileID2]) < 1 {
+		t.Error("too few errors")
+	}
+
 	declStatuses, err := store.RetrieveDeclarationStatus(ctx, []string{statusFileID2})
 	if err != nil {
 		t.Fatal(err)
@@ -187,4 +227,123 @@ func TestBasicStatus(t *testing.T, pathToDDMTestdata string, store statusStorage
 		t.Errorf("have: %v, want: %v", have, want)
 	}
 
+	summaries, err := store.RetrieveSetStatusSummary(ctx, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary *storage.DeclarationStatusSummary
+	for i := range summaries {
+		if summaries[i].Identifier == d.Identifier {
+			summary = &summaries[i]
+		}
+	}
+
+	if summary == nil {
+		t.Fatal("declaration not found in set status summary")
+	}
+
+	if have, want := summary.Failing, 1; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := summary.Enrollments, summary.ActiveValid+summary.Failing+summary.Unknown; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	// an enrollment not in any sets still reporting the declaration
+	err = store.StoreDeclarationStatus(ctx, statusFileID3, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declStatuses, err = store.RetrieveDeclarationStatus(ctx, []string{statusFileID3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	declStatus = declStatuses[statusFileID3]
+	if have, want := len(declStatus), 1; have != want {
+		t.Fatalf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := declStatus[0].Identifier, d.Identifier; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := declStatus[0].Unassigned, true; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := declStatus[0].Current, false; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	// the enrollment still reports the unassigned declaration as active
+	status.ID = "TestBasicStatus-StatusID3"
+	status.Declarations = []ddm.DeclarationStatus{{
+		Identifier:   d.Identifier,
+		Active:       true,
+		Valid:        "valid",
+		ServerToken:  "pending-removal",
+		ManifestType: "configuration",
+	}}
+	err = store.StoreDeclarationStatus(ctx, statusFileID3, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removals, err := store.RetrievePendingRemovals(ctx, []string{statusFileID3, statusFileID2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(removals), 1; have != want {
+		t.Fatalf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := len(removals[statusFileID3]), 1; have != want {
+		t.Fatalf("have: %v, want: %v", have, want)
+	}
+
+	if have, want := removals[statusFileID3][0].Identifier, d.Identifier; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	// the enrollment no longer reports the declaration
+	status.ID = "TestBasicStatus-StatusID4"
+	status.Declarations = []ddm.DeclarationStatus{{
+		Identifier:   "com.example.test.inactive",
+		Valid:        "unknown",
+		ServerToken:  "pending-removal",
+		ManifestType: "configuration",
+	}}
+	err = store.StoreDeclarationStatus(ctx, statusFileID3, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	removals, err = store.RetrievePendingRemovals(ctx, []string{statusFileID3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if have, want := len(removals[statusFileID3]), 0; have != want {
+		t.Errorf("have: %v, want: %v", have, want)
+	}
+
+	declStatuses, err = store.RetrieveDeclarationStatus(ctx, []string{statusFileID3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, declStatus := range declStatuses[statusFileID3] {
+		if declStatus.Identifier == d.Identifier {
+			t.Error("status of no longer reported declaration retrieved")
+		}
+	}
+
+	t.Run("StatusQuarantine", func(t *testing.T) {
+		testStatusQuarantine(t, store, ctx)
+	})
 }
@@ -0,0 +1,101 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type statusErrorStorage interface {
+	storage.StatusStorer
+	storage.StatusErrorsRetriever
+}
+
+func testStatusErrorDedup(t *testing.T, store statusErrorStorage, ctx context.Context) {
+	// storage may persist between test runs so use an enrollment unique to this run
+	enrollmentID := fmt.Sprintf("test_golang_errdedup_%d", time.Now().UnixNano())
+
+	for i, raw := range []string{
+		`{"Errors":[{"n":1},{"n":2}]}`,
+		`{"Errors":[{"n":1}]}`,
+		`{"Errors":[{"n":1}]}`,
+	} {
+		_, status, err := ddm.ParseStatus([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status.ID = fmt.Sprintf("testStatusErrorDedup-%d", i)
+		if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	errs, err := store.RetrieveStatusErrors(ctx, []string{enrollmentID}, time.Time{}, time.Time{}, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(errs[enrollmentID]), 2; have != want {
+		t.Fatalf("errors: have: %v, want: %v", have, want)
+	}
+	counts := make(map[string]int)
+	for _, e := range errs[enrollmentID] {
+		counts[fmt.Sprint(e.Error)] = e.Count
+		if e.FirstSeen.IsZero() || e.Timestamp.Before(e.FirstSeen) {
+			t.Errorf("first seen %v not before last seen %v", e.FirstSeen, e.Timestamp)
+		}
+	}
+	if have, want := counts[fmt.Sprint(map[string]interface{}{"n": 1.0})], 3; have != want {
+		t.Errorf("repeated error count: have: %v, want: %v", have, want)
+	}
+	if have, want := counts[fmt.Sprint(map[string]interface{}{"n": 2.0})], 1; have != want {
+		t.Errorf("error count: have: %v, want: %v", have, want)
+	}
+}
+
+func testStatusErrorLimits(t *testing.T, store statusErrorStorage, ctx context.Context) {
+	// storage may persist between test runs so use enrollments unique to this run
+	id1 := fmt.Sprintf("test_golang_errlimit1_%d", time.Now().UnixNano())
+	id2 := fmt.Sprintf("test_golang_errlimit2_%d", time.Now().UnixNano())
+
+	for id, raw := range map[string]string{
+		id1: `{"Errors":[{"n":1},{"n":2},{"n":3}]}`,
+		id2: `{"Errors":[{"n":1}]}`,
+	} {
+		_, status, err := ddm.ParseStatus([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status.ID = "testStatusErrorLimits"
+		if err = store.StoreDeclarationStatus(ctx, id, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the limit applies to each enrollment
+	errs, err := store.RetrieveStatusErrors(ctx, []string{id1, id2}, time.Time{}, time.Time{}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(errs[id1]), 2; have != want {
+		t.Errorf("errors of %s: have: %v, want: %v", id1, have, want)
+	}
+	if have, want := len(errs[id2]), 1; have != want {
+		t.Errorf("errors of %s: have: %v, want: %v", id2, have, want)
+	}
+
+	// as does the offset
+	errs, err = store.RetrieveStatusErrors(ctx, []string{id1, id2}, time.Time{}, time.Time{}, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := len(errs[id1]), 2; have != want {
+		t.Errorf("errors of %s: have: %v, want: %v", id1, have, want)
+	}
+	if have, want := len(errs[id2]), 0; have != want {
+		t.Errorf("errors of %s: have: %v, want: %v", id2, have, want)
+	}
+}
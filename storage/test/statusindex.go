@@ -0,0 +1,80 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type statusIndexStorage interface {
+	storage.StatusStorer
+	storage.DeclarationStatusEnrollmentsRetriever
+}
+
+func testDeclarationStatusEnrollments(t *testing.T, store statusIndexStorage, ctx context.Context) {
+	// storage may persist between test runs so use declarations and enrollments unique to this run
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	declarationID := "test_golang_statusindex_" + suffix
+	enrollmentIDs := []string{"test_golang_statusindex_a_" + suffix, "test_golang_statusindex_b_" + suffix}
+
+	storeStatus := func(enrollmentID string, active bool, valid string) {
+		t.Helper()
+		_, status, err := ddm.ParseStatus([]byte(fmt.Sprintf(
+			`{"StatusItems":{"management":{"declarations":{"configurations":[{"identifier":%q,"active":%v,"valid":%q,"server-token":"a"}]}}}}`,
+			declarationID, active, valid,
+		)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := func(valid string, active *bool, want []string) {
+		t.Helper()
+		have, err := store.RetrieveDeclarationStatusEnrollments(ctx, &storage.DeclarationStatusQuery{Identifier: declarationID, Valid: valid, Active: active})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(have) == 0 && len(want) == 0 {
+			return
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("valid=%q: have: %v, want: %v", valid, have, want)
+		}
+	}
+
+	storeStatus(enrollmentIDs[0], true, "valid")
+	storeStatus(enrollmentIDs[1], false, "invalid")
+
+	yes := true
+	query("", nil, enrollmentIDs)
+	query("invalid", nil, enrollmentIDs[1:])
+	query("", &yes, enrollmentIDs[:1])
+	query("valid", &yes, enrollmentIDs[:1])
+
+	// a changed status moves the enrollment
+	storeStatus(enrollmentIDs[1], true, "valid")
+	query("invalid", nil, nil)
+	query("valid", nil, enrollmentIDs)
+
+	// a declaration no longer reported is removed
+	_, status, err := ddm.ParseStatus([]byte(`{"StatusItems":{"management":{"declarations":{"configurations":[{"identifier":"test_golang_statusindex_other","active":true,"valid":"valid","server-token":"a"}]}}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = store.StoreDeclarationStatus(ctx, enrollmentIDs[0], status); err != nil {
+		t.Fatal(err)
+	}
+	query("", nil, enrollmentIDs[1:])
+
+	if _, err = store.RetrieveDeclarationStatusEnrollments(ctx, &storage.DeclarationStatusQuery{}); err == nil {
+		t.Error("expected error for missing declaration identifier")
+	}
+}
@@ -0,0 +1,82 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+type statusLimitsStorage interface {
+	storage.StatusStorer
+	storage.StatusValuesRetriever
+	storage.StatusErrorsRetriever
+}
+
+// TestStatusLimits tests storing status with limits of 3 values and 2
+// errors per report and 4 values and 3 errors per enrollment.
+func TestStatusLimits(t *testing.T, store statusLimitsStorage, ctx context.Context) {
+	// storage may persist between test runs so use an enrollment unique to this run
+	enrollmentID := fmt.Sprintf("test_golang_limits_%d", time.Now().UnixNano())
+
+	for i, report := range []struct {
+		raw                   string
+		droppedValues, errors int
+		values                []string
+		droppedErrors         int
+	}{
+		{
+			raw:           `{"StatusItems":{"device":{"a":"1","b":"2","c":"3","d":"4","e":"5"}},"Errors":[{"n":1},{"n":2},{"n":3}]}`,
+			droppedValues: 2,
+			values:        []string{"c", "d", "e"},
+			droppedErrors: 1,
+			errors:        2,
+		},
+		{
+			raw:           `{"StatusItems":{"device":{"f":"6","g":"7"}},"Errors":[{"n":4},{"n":5}]}`,
+			droppedValues: 1,
+			values:        []string{"d", "e", "f", "g"},
+			droppedErrors: 1,
+			errors:        3,
+		},
+	} {
+		_, status, err := ddm.ParseStatus([]byte(report.raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status.ID = fmt.Sprintf("TestStatusLimits-%d", i)
+		if err = store.StoreDeclarationStatus(ctx, enrollmentID, status); err != nil {
+			t.Fatal(err)
+		}
+		if have, want := status.DroppedValues, report.droppedValues; have != want {
+			t.Errorf("report %d: dropped values: have %d, want %d", i, have, want)
+		}
+		if have, want := status.DroppedErrors, report.droppedErrors; have != want {
+			t.Errorf("report %d: dropped errors: have %d, want %d", i, have, want)
+		}
+
+		values, err := store.RetrieveStatusValues(ctx, []string{enrollmentID}, ".StatusItems.device.%")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(values[enrollmentID]), len(report.values); have != want {
+			t.Errorf("report %d: values: have %d, want %d", i, have, want)
+		}
+		for _, name := range report.values {
+			if getPathValue(values[enrollmentID], ".StatusItems.device."+name) == "" {
+				t.Errorf("report %d: value not found: %s", i, name)
+			}
+		}
+
+		errs, err := store.RetrieveStatusErrors(ctx, []string{enrollmentID}, time.Time{}, time.Time{}, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, want := len(errs[enrollmentID]), report.errors; have != want {
+			t.Errorf("report %d: errors: have %d, want %d", i, have, want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// retrieveTestUsage retrieves the usage from through to of the sets keyed by day and set name.
+func retrieveTestUsage(t *testing.T, store storage.UsageStorage, ctx context.Context, from, to string, setNames ...string) map[[2]string]storage.UsageRecord {
+	t.Helper()
+	records, err := store.RetrieveUsage(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[[2]string]storage.UsageRecord)
+	for i, u := range records {
+		if i > 0 && (records[i-1].Day > u.Day || (records[i-1].Day == u.Day && records[i-1].Set > u.Set)) {
+			t.Errorf("usage not ordered: %v before %v", records[i-1], u)
+		}
+		for _, setName := range setNames {
+			if u.Set == setName {
+				ret[[2]string{u.Day, u.Set}] = u
+			}
+		}
+	}
+	return ret
+}
+
+func testUsage(t *testing.T, store storage.UsageStorage, ctx context.Context) {
+	// far in the past so as to not collide with real usage
+	const day1, day2, day3 = "1971-01-01", "1971-01-02", "1971-01-03"
+	const set1, set2 = "test_golang_usage_set1", "test_golang_usage_set2"
+
+	// storage may persist between test runs so compare deltas
+	before := retrieveTestUsage(t, store, ctx, day1, day2, set1, set2)
+
+	if err := store.IncrementUsage(ctx, day1, []string{set1, set2}, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.IncrementUsage(ctx, day1, []string{set1}, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreUsageDeclarations(ctx, day1, set1, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreUsageDeclarations(ctx, day1, set1, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreUsageDeclarations(ctx, day2, set2, 1); err != nil {
+		t.Fatal(err)
+	}
+	// outside of the retrieved days
+	if err := store.IncrementUsage(ctx, day3, []string{set1}, 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	after := retrieveTestUsage(t, store, ctx, day1, day2, set1, set2)
+	if have, want := len(after), 3; have != want {
+		t.Fatalf("have: %v, want: %v", have, want)
+	}
+	for _, want := range []storage.UsageRecord{
+		{Day: day1, Set: set1, Syncs: 3, StatusReports: 3, Declarations: 4},
+		{Day: day1, Set: set2, Syncs: 1},
+		{Day: day2, Set: set2, Declarations: 1},
+	} {
+		key := [2]string{want.Day, want.Set}
+		have := after[key]
+		have.Syncs -= before[key].Syncs
+		have.StatusReports -= before[key].StatusReports
+		if have != want {
+			t.Errorf("have: %v, want: %v", have, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import "context"
+
+// UsageDayFormat is the time format of usage days (in UTC).
+const UsageDayFormat = "2006-01-02"
+
+// UsageRecord is the usage of a set on a day.
+type UsageRecord struct {
+	Day string `json:"day"`
+	Set string `json:"set"`
+
+	// Syncs counts declaration items documents served to enrollments in the set.
+	Syncs int64 `json:"syncs"`
+
+	// StatusReports counts status reports received from enrollments in the set.
+	StatusReports int64 `json:"status_reports"`
+
+	// Declarations is the number of declarations in the set on the day.
+	Declarations int64 `json:"declarations"`
+}
+
+// UsageStorage tallies the daily usage of sets for metering.
+type UsageStorage interface {
+	// IncrementUsage adds syncs and statusReports to the usage of each
+	// of setNames on day. Usage that does not exist is created.
+	IncrementUsage(ctx context.Context, day string, setNames []string, syncs, statusReports int64) error
+
+	// StoreUsageDeclarations sets the number of declarations in the
+	// usage of setName on day. Usage that does not exist is created.
+	StoreUsageDeclarations(ctx context.Context, day, setName string, declarations int64) error
+
+	// RetrieveUsage retrieves the usage of the days from through to
+	// (inclusive) ordered by day and then set name.
+	RetrieveUsage(ctx context.Context, from, to string) ([]UsageRecord, error)
+}
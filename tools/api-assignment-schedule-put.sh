@@ -0,0 +1,15 @@
+#!/bin/sh
+
+# usage: api-assignment-schedule-put.sh [schedule.json]
+# reads the JSON assignment schedule from stdin if no file is given.
+
+URL="${BASE_URL}/v1/assignment-schedules"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H 'Content-Type: application/json' \
+    --data-binary @"${1:--}" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,13 @@
+#!/bin/sh
+
+# usage: api-compliance-requirement-put.sh <set-name> <requirement.json>
+
+URL="${BASE_URL}/v1/compliance-requirements/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -T "$2" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/credentials/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X DELETE \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
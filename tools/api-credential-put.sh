@@ -0,0 +1,19 @@
+#!/bin/sh
+
+# usage: api-credential-put.sh <name> <file> [content-type]
+# the content type defaults to application/pkix-cert (DER certificate).
+
+URL="${BASE_URL}/v1/credentials/$1"
+
+CONTENT_TYPE="application/pkix-cert"
+if [ "$3" != "" ]; then
+	CONTENT_TYPE="$3"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H "Content-Type: $CONTENT_TYPE" \
+    -T "$2" \
+    "$URL"
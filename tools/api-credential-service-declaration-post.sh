@@ -0,0 +1,11 @@
+#!/bin/sh
+
+# usage: api-credential-service-declaration-post.sh <service-name> <identifier>
+
+URL="${BASE_URL}/v1/credential-services/$1/declaration?identifier=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    "$URL"
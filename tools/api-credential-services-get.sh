@@ -0,0 +1,8 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/credential-services"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
@@ -0,0 +1,12 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/credentials"
+
+if [ "$1" != "" ]; then
+	URL="${URL}/$1"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
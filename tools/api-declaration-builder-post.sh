@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/declaration-builders/$1?identifier=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    -T "$3" \
+    "$URL"
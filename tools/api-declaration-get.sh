@@ -2,6 +2,20 @@
 
 URL="${BASE_URL}/v1/declarations/$1"
 
+QUERY=""
+if [ "$RAW" != "" ]; then
+    QUERY="${QUERY}&raw=1"
+fi
+if [ "$PRETTY" != "" ]; then
+    QUERY="${QUERY}&pretty=1"
+fi
+if [ "$NOTOKEN" != "" ]; then
+    QUERY="${QUERY}&notoken=1"
+fi
+if [ "$QUERY" != "" ]; then
+    URL="${URL}?${QUERY#&}"
+fi
+
 curl \
     $CURL_OPTS \
     -u kmfddm:$API_KEY \
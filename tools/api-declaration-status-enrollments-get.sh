@@ -0,0 +1,12 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/declaration-status-enrollments/$1"
+
+if [ "x$2" != "x" ]; then
+    URL="${URL}?valid=$2"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/declaration-tags/$1?tag=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
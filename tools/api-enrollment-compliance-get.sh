@@ -0,0 +1,19 @@
+#!/bin/sh
+
+# usage: api-enrollment-compliance-get.sh [set-name]
+# set COMPLIANT=true or COMPLIANT=false to filter by compliance.
+
+URL="${BASE_URL}/v1/enrollment-compliance?set=$1"
+
+if [ "$1" = "" ]; then
+    URL="${BASE_URL}/v1/enrollment-compliance?"
+fi
+
+if [ "$COMPLIANT" != "" ]; then
+    URL="${URL}&compliant=$COMPLIANT"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
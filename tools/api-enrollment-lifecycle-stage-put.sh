@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/enrollment-lifecycle-stage/$1?stage=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
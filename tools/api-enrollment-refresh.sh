@@ -0,0 +1,9 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/enrollment-refresh/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    "$URL"
@@ -0,0 +1,17 @@
+#!/bin/sh
+
+# usage: api-enrollment-registration-delete.sh <enrollment-id>
+# set SETS=1 to also dissociate the enrollment from all of its sets.
+
+URL="${BASE_URL}/v1/enrollment-registrations/$1"
+
+if [ "$SETS" != "" ]; then
+    URL="${URL}?sets=1"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X DELETE \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,15 @@
+#!/bin/sh
+
+# usage: api-enrollment-registration-put.sh <enrollment-id> [registration.json]
+# reads the JSON registration from stdin if no file is given.
+
+URL="${BASE_URL}/v1/enrollment-registrations/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H 'Content-Type: application/json' \
+    --data-binary @"${2:--}" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,11 @@
+#!/bin/sh
+
+# usage: api-enrollment-served-history-get.sh <enrollment-id> [kind]
+# kind is "tokens" or "declaration-items"
+
+URL="${BASE_URL}/v1/enrollment-served-history/$1?kind=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
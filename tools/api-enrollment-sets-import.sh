@@ -0,0 +1,19 @@
+#!/bin/sh
+
+# usage: api-enrollment-sets-import.sh [assignments.csv]
+# reads the CSV of enrollment ID and set name records from stdin if no
+# file is given. set DRYRUN=1 to validate without assigning.
+
+URL="${BASE_URL}/v1/enrollment-sets-import"
+
+if [ "$DRYRUN" != "" ]; then
+    URL="${URL}?dryrun=1"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    -H 'Content-Type: text/csv' \
+    --data-binary @"${1:--}" \
+    "$URL"
@@ -0,0 +1,14 @@
+#!/bin/sh
+
+# usage: api-job-get.sh [job-id]
+
+URL="${BASE_URL}/v1/jobs/$1"
+
+if [ "$1" = "" ]; then
+    URL="${BASE_URL}/v1/jobs"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
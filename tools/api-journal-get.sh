@@ -0,0 +1,12 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/journal?after=${1:-0}"
+
+if [ "$LIMIT" != "" ]; then
+    URL="${URL}&limit=${LIMIT}"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
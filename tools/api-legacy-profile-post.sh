@@ -0,0 +1,14 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/legacy-profiles"
+
+if [ "$2" != "" ]; then
+	URL="${URL}?identifier=$2"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    -T "$1" \
+    "$URL"
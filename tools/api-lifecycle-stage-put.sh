@@ -0,0 +1,20 @@
+#!/bin/sh
+
+# usage: api-lifecycle-stage-put.sh <stage> [set ...]
+
+STAGE="$1"
+shift
+
+QUERY=""
+for SET in "$@"; do
+    QUERY="${QUERY}&set=${SET}"
+done
+
+URL="${BASE_URL}/v1/lifecycle-stages/${STAGE}?${QUERY#&}"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,9 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/maintenance"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    "$URL"
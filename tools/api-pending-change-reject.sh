@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/pending-changes/$1/reject"
+
+curl \
+    $CURL_OPTS \
+    -u ${API_USER:-kmfddm}:$API_KEY \
+    -X POST \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
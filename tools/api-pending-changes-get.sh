@@ -0,0 +1,8 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/pending-changes"
+
+curl \
+    $CURL_OPTS \
+    -u ${API_USER:-kmfddm}:$API_KEY \
+    "$URL"
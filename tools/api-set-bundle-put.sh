@@ -0,0 +1,11 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/set-bundle"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H 'Content-Type: application/gzip' \
+    --data-binary "@$1" \
+    "$URL"
@@ -0,0 +1,12 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/set-declaration-conditions/$1?declaration=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H 'Content-Type: application/json' \
+    -d "{\"path\":\"$3\",\"op\":\"$4\",\"value\":\"$5\"}" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
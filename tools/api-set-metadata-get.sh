@@ -0,0 +1,11 @@
+#!/bin/sh
+
+# usage: api-set-metadata-get.sh [set-name[,set-name...]]
+# retrieves the metadata of all sets if no set name is given.
+
+URL="${BASE_URL}/v1/set-metadata${1:+/$1}"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    "$URL"
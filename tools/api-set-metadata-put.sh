@@ -0,0 +1,15 @@
+#!/bin/sh
+
+# usage: api-set-metadata-put.sh <set-name> [metadata.json]
+# reads the JSON metadata from stdin if no file is given.
+
+URL="${BASE_URL}/v1/set-metadata/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X PUT \
+    -H 'Content-Type: application/json' \
+    --data-binary @"${2:--}" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,15 @@
+#!/bin/sh
+
+# usage: api-set-pattern-delete.sh <set> <prefix|glob|regex> <pattern>
+
+URL="${BASE_URL}/v1/set-patterns/$1"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X DELETE \
+    -G \
+    --data-urlencode "kind=$2" \
+    --data-urlencode "pattern=$3" \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,10 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/set-snapshots/$1?snapshot=$2"
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    -w "Response HTTP Code: %{http_code}\n" \
+    "$URL"
@@ -0,0 +1,13 @@
+#!/bin/sh
+
+URL="${BASE_URL}/v1/sync"
+
+if [ "$1" != "" ]; then
+	URL="${URL}?dryrun=1"
+fi
+
+curl \
+    $CURL_OPTS \
+    -u kmfddm:$API_KEY \
+    -X POST \
+    "$URL"
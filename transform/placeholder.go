@@ -0,0 +1,71 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+)
+
+// EnrollmentIDPlaceholder is replaced with the enrollment ID by the
+// EnrollmentIDTemplate transformer.
+const EnrollmentIDPlaceholder = "${EnrollmentID}"
+
+// ReplaceStrings replaces old with new in all of the string values
+// (but not the keys) of v, a value decoded from JSON.
+func ReplaceStrings(v interface{}, old, new string) interface{} {
+	switch tv := v.(type) {
+	case string:
+		return strings.ReplaceAll(tv, old, new)
+	case map[string]interface{}:
+		for k, child := range tv {
+			tv[k] = ReplaceStrings(child, old, new)
+		}
+	case []interface{}:
+		for i, child := range tv {
+			tv[i] = ReplaceStrings(child, old, new)
+		}
+	}
+	return v
+}
+
+// Template returns a Transformer that replaces placeholder in the
+// string values of declaration payloads with the value returned by
+// valueFn for the enrollment ID the declaration is served to.
+// valueFn is only called for payloads containing placeholder.
+func Template(placeholder string, valueFn func(ctx context.Context, enrollmentID string) (string, error)) Transformer {
+	return TransformerFunc(func(ctx context.Context, enrollmentID string, d *ddm.Declaration) error {
+		if !bytes.Contains(d.PayloadJSON, []byte(placeholder)) {
+			return nil
+		}
+		value, err := valueFn(ctx, enrollmentID)
+		if err != nil {
+			return fmt.Errorf("template value of %s: %w", placeholder, err)
+		}
+		dec := json.NewDecoder(bytes.NewReader(d.PayloadJSON))
+		// keep numbers as they were
+		dec.UseNumber()
+		var payload interface{}
+		if err := dec.Decode(&payload); err != nil {
+			return fmt.Errorf("decoding payload: %w", err)
+		}
+		b, err := json.Marshal(ReplaceStrings(payload, placeholder, value))
+		if err != nil {
+			return fmt.Errorf("encoding payload: %w", err)
+		}
+		d.PayloadJSON = b
+		return nil
+	})
+}
+
+// EnrollmentIDTemplate returns a Transformer that replaces
+// EnrollmentIDPlaceholder in the string values of declaration payloads
+// with the enrollment ID the declaration is served to.
+func EnrollmentIDTemplate() Transformer {
+	return Template(EnrollmentIDPlaceholder, func(_ context.Context, enrollmentID string) (string, error) {
+		return enrollmentID, nil
+	})
+}
@@ -0,0 +1,186 @@
+// Package transform modifies declarations just before they are served
+// to enrollments.
+//
+// Transformers are applied to the declarations of an enrollment when
+// they are served by way of the DDM protocol (e.g. templating, URL
+// rewriting, or payload injection). The ServerToken of a transformed
+// declaration is computed after transformation so that the declaration
+// items and sync tokens of the enrollment stay consistent with the
+// declarations it receives.
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage"
+)
+
+// ErrIdentityChanged is returned when a transformer changes the identifier or type of a declaration.
+var ErrIdentityChanged = errors.New("identifier or type changed")
+
+// Transformer modifies d before it is served to enrollmentID.
+// Transformers may change the PayloadJSON of d. Changes to the
+// Identifier, Type, or ServerToken of d are rejected or ignored.
+type Transformer interface {
+	Transform(ctx context.Context, enrollmentID string, d *ddm.Declaration) error
+}
+
+// TransformerFunc is an adapter to allow the use of ordinary functions as Transformers.
+type TransformerFunc func(ctx context.Context, enrollmentID string, d *ddm.Declaration) error
+
+// Transform calls f(ctx, enrollmentID, d).
+func (f TransformerFunc) Transform(ctx context.Context, enrollmentID string, d *ddm.Declaration) error {
+	return f(ctx, enrollmentID, d)
+}
+
+// Storage is the DDM protocol storage that Store wraps.
+type Storage interface {
+	storage.DeclarationRetriever
+	storage.TokensDeclarationItemsRetriever
+}
+
+// Store applies transformers to the declarations served from an
+// underlying Storage. It implements Storage itself.
+//
+// As the ServerTokens of transformed declarations are only known after
+// transformation the declaration items and sync tokens are rebuilt by
+// retrieving and transforming every declaration of the enrollment.
+type Store struct {
+	store        Storage
+	newHash      ddm.NewHash
+	transformers []Transformer
+}
+
+// New creates a new Store that applies transformers, in order, to the
+// declarations served from store. It panics if store or newHash are nil.
+func New(store Storage, newHash ddm.NewHash, transformers ...Transformer) *Store {
+	if store == nil || newHash == nil {
+		panic("nil store or hasher")
+	}
+	return &Store{
+		store:        store,
+		newHash:      newHash,
+		transformers: transformers,
+	}
+}
+
+// served is the JSON form of a served declaration.
+type served struct {
+	Identifier  string
+	Type        string
+	Payload     json.RawMessage
+	ServerToken string
+}
+
+// transform retrieves the declaration for enrollmentID and applies the
+// transformers to it. The ServerToken is replaced if the payload changed.
+func (s *Store) transform(ctx context.Context, declarationID, declarationType, enrollmentID string) (*ddm.Declaration, error) {
+	raw, err := s.store.RetrieveEnrollmentDeclarationJSON(ctx, declarationID, declarationType, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	d, err := ddm.ParseDeclaration(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing declaration: %w", err)
+	}
+	orig := *d
+	for _, t := range s.transformers {
+		if err = t.Transform(ctx, enrollmentID, d); err != nil {
+			return nil, fmt.Errorf("transforming declaration %s: %w", orig.Identifier, err)
+		}
+	}
+	if d.Identifier != orig.Identifier || d.Type != orig.Type {
+		return nil, fmt.Errorf("transforming declaration %s: %w", orig.Identifier, ErrIdentityChanged)
+	}
+	d.ServerToken = orig.ServerToken
+	if bytes.Equal(d.PayloadJSON, orig.PayloadJSON) {
+		// untransformed declarations are served as-is
+		return d, nil
+	}
+
+	// derive the token from the original token so that it changes when
+	// either the declaration or its transformation changes.
+	h := s.newHash()
+	h.Write([]byte(orig.ServerToken))
+	h.Write(d.PayloadJSON)
+	d.ServerToken = fmt.Sprintf("%x", h.Sum(nil))
+
+	if d.Raw, err = json.Marshal(&served{
+		Identifier:  d.Identifier,
+		Type:        d.Type,
+		Payload:     d.PayloadJSON,
+		ServerToken: d.ServerToken,
+	}); err != nil {
+		return nil, fmt.Errorf("marshaling transformed declaration: %w", err)
+	}
+	return d, nil
+}
+
+// RetrieveEnrollmentDeclarationJSON retrieves the transformed declaration JSON for enrollmentID.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveEnrollmentDeclarationJSON(ctx context.Context, declarationID, declarationType, enrollmentID string) ([]byte, error) {
+	d, err := s.transform(ctx, declarationID, declarationType, enrollmentID)
+	if err != nil {
+		return nil, err
+	}
+	return d.Raw, nil
+}
+
+// build transforms the declarations of enrollmentID and adds them to b.
+func (s *Store) build(ctx context.Context, b interface{ Add(*ddm.Declaration) }, enrollmentID string) error {
+	diJSON, err := s.store.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		return err
+	}
+	di := new(ddm.DeclarationItems)
+	if err = json.Unmarshal(diJSON, di); err != nil {
+		return fmt.Errorf("unmarshaling declaration items: %w", err)
+	}
+	for _, m := range []struct {
+		manifestType string
+		items        []ddm.ManifestDeclaration
+	}{
+		{"activation", di.Declarations.Activations},
+		{"asset", di.Declarations.Assets},
+		{"configuration", di.Declarations.Configurations},
+		{"management", di.Declarations.Management},
+	} {
+		for _, item := range m.items {
+			d, err := s.transform(ctx, item.Identifier, m.manifestType, enrollmentID)
+			if err != nil {
+				return err
+			}
+			b.Add(d)
+		}
+	}
+	return nil
+}
+
+// RetrieveDeclarationItemsJSON generates the Declaration Items for enrollmentID
+// from its transformed declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveDeclarationItemsJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	b := ddm.NewDIBuilder(s.newHash)
+	if err := s.build(ctx, b, enrollmentID); err != nil {
+		return nil, err
+	}
+	b.Finalize()
+	return json.Marshal(&b.DeclarationItems)
+}
+
+// RetrieveTokensJSON generates the Sync Tokens for enrollmentID
+// from its transformed declarations.
+// See also the storage package for documentation on the storage interfaces.
+func (s *Store) RetrieveTokensJSON(ctx context.Context, enrollmentID string) ([]byte, error) {
+	b := ddm.NewTokensBuilder(s.newHash)
+	if err := s.build(ctx, b, enrollmentID); err != nil {
+		return nil, err
+	}
+	b.Finalize()
+	return json.Marshal(&b.TokensResponse)
+}
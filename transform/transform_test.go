@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash"
+	"testing"
+
+	"github.com/cespare/xxhash"
+	"github.com/jessepeterson/kmfddm/ddm"
+	"github.com/jessepeterson/kmfddm/storage/file"
+)
+
+func newHash() hash.Hash { return xxhash.New() }
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	const enrollmentID = "EB9DE86C-2E95-4F73-80A3-34F1D8111FA2"
+	fs, err := file.New(t.TempDir(), newHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range []string{
+		`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.templated","Payload":{"Echo":"id=${EnrollmentID}","Num":1}}`,
+		`{"Type":"com.apple.configuration.management.test","Identifier":"com.example.plain","Payload":{"Echo":"plain"}}`,
+	} {
+		d, err := ddm.ParseDeclaration([]byte(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fs.StoreDeclaration(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = fs.StoreSetDeclaration(ctx, "default", d.Identifier); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err = fs.StoreEnrollmentSet(ctx, enrollmentID, "default"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(fs, newHash, EnrollmentIDTemplate())
+
+	raw, err := s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.templated", "configuration", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := ddm.ParseDeclaration(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(d.PayloadJSON), `{"Echo":"id=`+enrollmentID+`","Num":1}`; have != want {
+		t.Errorf("payload: have: %v, want: %v", have, want)
+	}
+	origRaw, err := fs.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.templated", "configuration", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ddm.ParseDeclaration(origRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ServerToken == "" || d.ServerToken == orig.ServerToken {
+		t.Errorf("server token not recomputed: %q", d.ServerToken)
+	}
+
+	// untransformed declarations are served as-is
+	raw, err = s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.plain", "configuration", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	origRaw, err = fs.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.plain", "configuration", enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(raw, origRaw) {
+		t.Errorf("untransformed declaration changed: %s", raw)
+	}
+
+	diJSON, err := s.RetrieveDeclarationItemsJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	di := new(ddm.DeclarationItems)
+	if err = json.Unmarshal(diJSON, di); err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, md := range di.Declarations.Configurations {
+		if md.Identifier == d.Identifier {
+			found = true
+			if md.ServerToken != d.ServerToken {
+				t.Errorf("declaration items server token: have: %v, want: %v", md.ServerToken, d.ServerToken)
+			}
+		}
+	}
+	if !found {
+		t.Error("transformed declaration not in declaration items")
+	}
+
+	tokensJSON, err := s.RetrieveTokensJSON(ctx, enrollmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := new(ddm.TokensResponse)
+	if err = json.Unmarshal(tokensJSON, tokens); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := tokens.SyncTokens.DeclarationsToken, di.DeclarationsToken; have != want {
+		t.Errorf("declarations token: have: %v, want: %v", have, want)
+	}
+
+	// transformers may not change the identity of declarations
+	s = New(fs, newHash, TransformerFunc(func(_ context.Context, _ string, d *ddm.Declaration) error {
+		d.Type = "com.apple.configuration.other"
+		return nil
+	}))
+	_, err = s.RetrieveEnrollmentDeclarationJSON(ctx, "com.example.plain", "configuration", enrollmentID)
+	if !errors.Is(err, ErrIdentityChanged) {
+		t.Errorf("have: %v, want: %v", err, ErrIdentityChanged)
+	}
+}